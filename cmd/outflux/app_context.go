@@ -3,49 +3,78 @@ package main
 import (
 	"github.com/timescale/outflux/internal/cli"
 	"github.com/timescale/outflux/internal/connections"
+	"github.com/timescale/outflux/internal/continuousqueries"
 	"github.com/timescale/outflux/internal/extraction"
 	"github.com/timescale/outflux/internal/ingestion"
 	"github.com/timescale/outflux/internal/schemamanagement"
 	"github.com/timescale/outflux/internal/schemamanagement/influx/discovery"
 	"github.com/timescale/outflux/internal/schemamanagement/influx/influxqueries"
+	"github.com/timescale/outflux/internal/schemamanagement/influxv2"
+	"github.com/timescale/outflux/internal/schemamanagement/influxv2/fluxqueries"
+	"github.com/timescale/outflux/internal/schemamanagement/lineprotocol"
+	"github.com/timescale/outflux/internal/schemamanagement/tsm"
 )
 
 type appContext struct {
-	ics                   connections.InfluxConnectionService
-	tscs                  connections.TSConnectionService
-	pipeService           cli.PipeService
-	influxQueryService    influxqueries.InfluxQueryService
-	influxTagExplorer     discovery.TagExplorer
-	influxFieldExplorer   discovery.FieldExplorer
-	influxMeasureExplorer discovery.MeasureExplorer
-	extractorService      extraction.ExtractorService
-	schemaManagerService  schemamanagement.SchemaManagerService
-	transformerService    cli.TransformerService
+	ics                       connections.InfluxConnectionService
+	icsV2                     connections.InfluxV2ConnectionService
+	tscs                      connections.TSConnectionService
+	pipeService               cli.PipeService
+	influxQueryService        influxqueries.InfluxQueryService
+	influxTagExplorer         discovery.TagExplorer
+	influxCardinalityExplorer discovery.TagCardinalityExplorer
+	influxFieldExplorer       discovery.FieldExplorer
+	influxDensityExplorer     discovery.PointDensityExplorer
+	influxTimeRangeExplorer   discovery.PointTimeRangeExplorer
+	influxMeasureExplorer     discovery.MeasureExplorer
+	influxRPExplorer          discovery.RetentionPolicyExplorer
+	influxDbExplorer          discovery.DatabaseExplorer
+	extractorService          extraction.ExtractorService
+	schemaManagerService      schemamanagement.SchemaManagerService
+	transformerService        cli.TransformerService
+	cqExplorer                continuousqueries.Explorer
+	influxV2Explorer          influxv2.Explorer
+	lineProtocolExplorer      lineprotocol.Explorer
+	tsmExplorer               tsm.Explorer
 }
 
 func initAppContext() *appContext {
 	tscs := connections.NewTSConnectionService()
 	ics := connections.NewInfluxConnectionService()
+	icsV2 := connections.NewInfluxV2ConnectionService()
 	ingestorService := ingestion.NewIngestorService()
 	influxQueryService := influxqueries.NewInfluxQueryService()
 	influxTagExplorer := discovery.NewTagExplorer(influxQueryService)
 	influxFieldExplorer := discovery.NewFieldExplorer(influxQueryService)
 	influxMeasureExplorer := discovery.NewMeasureExplorer(influxQueryService, influxFieldExplorer)
-	schemaManagerService := schemamanagement.NewSchemaManagerService(influxMeasureExplorer, influxTagExplorer, influxFieldExplorer)
+	influxV2Explorer := influxv2.NewExplorer(fluxqueries.NewFluxQueryService())
+	lineProtocolExplorer := lineprotocol.NewExplorer()
+	tsmExplorer := tsm.NewExplorer()
+	schemaManagerService := schemamanagement.NewSchemaManagerService(influxMeasureExplorer, influxTagExplorer, influxFieldExplorer, influxV2Explorer, lineProtocolExplorer, tsmExplorer)
 	extractorService := extraction.NewExtractorService(schemaManagerService)
 
-	transformerService := cli.NewTransformerService(influxTagExplorer, influxFieldExplorer)
+	transformerService := cli.NewTransformerService(influxTagExplorer, influxFieldExplorer, influxV2Explorer, lineProtocolExplorer, tsmExplorer)
 	pipeService := cli.NewPipeService(ingestorService, extractorService, transformerService)
 	return &appContext{
-		ics:                   ics,
-		tscs:                  tscs,
-		pipeService:           pipeService,
-		influxQueryService:    influxQueryService,
-		extractorService:      extractorService,
-		schemaManagerService:  schemaManagerService,
-		transformerService:    transformerService,
-		influxTagExplorer:     influxTagExplorer,
-		influxFieldExplorer:   influxFieldExplorer,
-		influxMeasureExplorer: influxMeasureExplorer,
+		ics:                       ics,
+		icsV2:                     icsV2,
+		tscs:                      tscs,
+		pipeService:               pipeService,
+		influxQueryService:        influxQueryService,
+		extractorService:          extractorService,
+		schemaManagerService:      schemaManagerService,
+		transformerService:        transformerService,
+		influxTagExplorer:         influxTagExplorer,
+		influxCardinalityExplorer: discovery.NewTagCardinalityExplorer(influxQueryService),
+		influxFieldExplorer:       influxFieldExplorer,
+		influxDensityExplorer:     discovery.NewPointDensityExplorer(influxQueryService),
+		influxTimeRangeExplorer:   discovery.NewPointTimeRangeExplorer(influxQueryService),
+		influxMeasureExplorer:     influxMeasureExplorer,
+		influxRPExplorer:          discovery.NewRetentionPolicyExplorer(influxQueryService),
+		influxDbExplorer:          discovery.NewDatabaseExplorer(influxQueryService),
+		cqExplorer:                continuousqueries.NewExplorer(influxQueryService),
+		influxV2Explorer:          influxV2Explorer,
+		lineProtocolExplorer:      lineProtocolExplorer,
+		tsmExplorer:               tsmExplorer,
 	}
 }
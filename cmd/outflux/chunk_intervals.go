@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/timescale/outflux/internal/cli"
+	"github.com/timescale/outflux/internal/schemamanagement/ts"
+)
+
+// resolveChunkTimeIntervals sets the chunk_time_interval of the hypertable of each measure, so a chunk holds about
+// the target chunk size. The rate of the points is sampled in a window ending with the newest point of the measure,
+// and the size of a row is estimated from the types of its columns. Measures without points keep the default interval
+func resolveChunkTimeIntervals(app *appContext, connArgs *cli.ConnectionConfig, args *cli.MigrationConfig, measures []string) error {
+	if !args.AutoChunkTimeInterval {
+		return nil
+	}
+
+	args.MeasureChunkTimeIntervals = make(map[string]string, len(measures))
+	if len(measures) == 0 {
+		return nil
+	}
+
+	influxConn, err := app.ics.NewConnection(influxConnParams(connArgs))
+	if err != nil {
+		return fmt.Errorf("could not open connection to Influx Server\n%v", err)
+	}
+
+	defer influxConn.Close()
+	db, rp := connArgs.InputDb, args.RetentionPolicy
+	schemaManager := app.schemaManagerService.Influx(influxConn, db, rp, args.OnConflictConvertIntToFloat)
+	for _, measure := range measures {
+		density, err := app.influxDensityExplorer.SamplePointDensity(influxConn, db, rp, measure, args.DensitySampleWindow)
+		if err != nil {
+			return fmt.Errorf("could not sample the point density of measure '%s'\n%v", measure, err)
+		}
+
+		if density == nil || density.Points == 0 {
+			log.Printf("Measure '%s' has no points to size its chunks by, the default chunk_time_interval is kept", measure)
+			continue
+		}
+
+		dataSet, err := schemaManager.FetchDataSet(measure)
+		if err != nil {
+			return fmt.Errorf("could not discover the columns of measure '%s'\n%v", measure, err)
+		}
+
+		rowBytes := ts.EstimateRowBytes(dataSet.Columns)
+		interval := ts.ChunkTimeIntervalForDensity(density.PointsPerSecond(), rowBytes, args.ChunkTargetSize)
+		args.MeasureChunkTimeIntervals[measure] = fmt.Sprintf(secondsIntervalTemplate, int64(interval/time.Second))
+		log.Printf(
+			"Measure '%s' has %d points in %s (%.3f points/s) of about %d bytes, its chunk_time_interval will be %s",
+			measure, density.Points, density.Window, density.PointsPerSecond(), rowBytes, interval)
+	}
+
+	return nil
+}
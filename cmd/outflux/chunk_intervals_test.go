@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/cli"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/influx/discovery"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+)
+
+func TestResolveChunkTimeIntervals(t *testing.T) {
+	densities := map[string]*discovery.PointDensity{
+		// 1 point per second
+		"cpu": {Points: 86400, Window: 24 * time.Hour},
+		"mem": nil,
+	}
+	// a row of 28 + 8 + 8 bytes
+	columns := []*idrf.Column{{Name: "time", DataType: idrf.IDRFTimestamptz}, {Name: "value", DataType: idrf.IDRFDouble}}
+	testCases := []struct {
+		desc       string
+		args       *cli.MigrationConfig
+		densityErr error
+		expected   map[string]string
+		expectErr  bool
+	}{
+		{
+			desc: "not sized",
+			args: &cli.MigrationConfig{ChunkTimeInterval: "1 day"},
+		}, {
+			desc:     "sized from the density",
+			args:     &cli.MigrationConfig{ChunkTimeInterval: "1 day", AutoChunkTimeInterval: true, ChunkTargetSize: 44 * 3600, DensitySampleWindow: 24 * time.Hour},
+			expected: map[string]string{"cpu": "3600 seconds"},
+		}, {
+			desc:       "error sampling the density",
+			args:       &cli.MigrationConfig{AutoChunkTimeInterval: true, ChunkTargetSize: 44 * 3600, DensitySampleWindow: 24 * time.Hour},
+			densityErr: fmt.Errorf("error"),
+			expectErr:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		conn := &mockInfConn{}
+		app := &appContext{
+			ics:                   &mockService{inflConn: conn},
+			schemaManagerService:  &mockService{inflSchemMngr: &columnsSchemaManager{columns: columns}},
+			influxDensityExplorer: &mockDensityExplorer{densities: densities, err: tc.densityErr},
+		}
+		err := resolveChunkTimeIntervals(app, &cli.ConnectionConfig{}, tc.args, []string{"cpu", "mem"})
+		if tc.expectErr {
+			assert.Error(t, err, tc.desc)
+			continue
+		}
+
+		assert.NoError(t, err, tc.desc)
+		assert.Equal(t, tc.expected, tc.args.MeasureChunkTimeIntervals, tc.desc)
+		assert.Equal(t, tc.expected != nil, conn.closeCalled, tc.desc)
+		assert.Equal(t, "1 day", tc.args.MeasureChunkTimeInterval("mem"), tc.desc)
+	}
+}
+
+type mockDensityExplorer struct {
+	densities map[string]*discovery.PointDensity
+	err       error
+}
+
+func (m *mockDensityExplorer) SamplePointDensity(influxClient influx.Client, database, rp, measure string, window time.Duration) (*discovery.PointDensity, error) {
+	return m.densities[measure], m.err
+}
+
+type columnsSchemaManager struct {
+	columns []*idrf.Column
+}
+
+func (c *columnsSchemaManager) DiscoverDataSets() ([]string, error) { return nil, nil }
+func (c *columnsSchemaManager) FetchDataSet(dataSetIdentifier string) (*idrf.DataSet, error) {
+	return &idrf.DataSet{DataSetName: dataSetIdentifier, Columns: c.columns, TimeColumn: "time"}, nil
+}
+func (c *columnsSchemaManager) PrepareDataSet(dataSet *idrf.DataSet, strategy schemaconfig.SchemaStrategy) error {
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/timescale/outflux/internal/cli"
+	"github.com/timescale/outflux/internal/cli/flagparsers"
+	"github.com/timescale/outflux/internal/continuousqueries"
+)
+
+const (
+	cqHeaderTemplate      = "-- continuous query '%s': %s\n"
+	cqUnsupportedTemplate = "-- unsupported: %s\n"
+)
+
+func initContinuousQueriesCmd() *cobra.Command {
+	cqCmd := &cobra.Command{
+		Use:   "continuous-queries database",
+		Short: "Translate the continuous queries of an InfluxDB database into TimescaleDB continuous aggregates",
+		Long: "Translate the continuous queries of an InfluxDB database into TimescaleDB continuous aggregates over the migrated" +
+			" hypertables. The statements are printed to STDOUT, with the parts of the queries that could not be translated" +
+			" as comments. With --" + flagparsers.ExecuteFlag + " they are also executed on the output database",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			app := initAppContext()
+			connArgs, cqArgs, err := flagparsers.FlagsToContinuousQueriesConfig(cmd.Flags(), args)
+			if err != nil {
+				log.Fatal(err)
+				return
+			}
+
+			err = translateContinuousQueries(app, connArgs, cqArgs, os.Stdout)
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	flagparsers.AddInfluxServerFlagsToCmd(cqCmd)
+	flagparsers.AddOutputConnFlagToCmd(cqCmd)
+	cqCmd.PersistentFlags().String(flagparsers.OutputSchemaFlag, flagparsers.DefaultOutputSchema, "The schema of the output database that holds the migrated hypertables, the continuous aggregates are created in it")
+	cqCmd.PersistentFlags().Bool(flagparsers.ExecuteFlag, flagparsers.DefaultExecute, "If specified the continuous aggregates are created in the output database, otherwise the statements are only printed")
+	return cqCmd
+}
+
+// translateContinuousQueries writes the statements that create a continuous aggregate for each continuous
+// query of the input database to out, and executes them if requested. Continuous aggregates can't be
+// created in a transaction, so each statement is executed on its own
+func translateContinuousQueries(app *appContext, connArgs *cli.ConnectionConfig, args *cli.ContinuousQueriesConfig, out io.Writer) error {
+	if args.Quiet {
+		log.SetFlags(0)
+		log.SetOutput(ioutil.Discard)
+	}
+
+	influxConn, err := app.ics.NewConnection(influxConnParams(connArgs))
+	if err != nil {
+		return fmt.Errorf("could not open connection to Influx Server\n%v", err)
+	}
+
+	defer influxConn.Close()
+	queries, err := app.cqExplorer.FetchContinuousQueries(influxConn, connArgs.InputDb)
+	if err != nil {
+		return fmt.Errorf("could not fetch the continuous queries of database '%s'\n%v", connArgs.InputDb, err)
+	}
+
+	log.Printf("Found %d continuous queries in database '%s'", len(queries), connArgs.InputDb)
+	translations := make([]*continuousqueries.Translation, len(queries))
+	for i, query := range queries {
+		if translations[i], err = continuousqueries.Translate(query, args.OutputSchema); err != nil {
+			return err
+		}
+
+		writeTranslation(out, translations[i])
+	}
+
+	if !args.Execute {
+		return nil
+	}
+
+	tsConn, err := app.tscs.NewConnection(connArgs.OutputDbConnString)
+	if err != nil {
+		return fmt.Errorf("could not open connection to TimescaleDB Server\n%v", err)
+	}
+
+	defer tsConn.Close()
+	for _, translation := range translations {
+		if len(translation.Statements) == 0 {
+			log.Printf("Continuous query '%s' could not be translated", translation.ContinuousQuery.Name)
+			continue
+		}
+
+		for _, statement := range translation.Statements {
+			if _, err = tsConn.Exec(statement); err != nil {
+				return fmt.Errorf("could not create continuous aggregate for continuous query '%s'\n%v", translation.ContinuousQuery.Name, err)
+			}
+		}
+
+		log.Printf("Created continuous aggregate '%s' for continuous query '%s'", translation.ViewName, translation.ContinuousQuery.Name)
+	}
+
+	return nil
+}
+
+func writeTranslation(out io.Writer, translation *continuousqueries.Translation) {
+	query := translation.ContinuousQuery
+	fmt.Fprintf(out, cqHeaderTemplate, query.Name, strings.Replace(query.Query, "\n", " ", -1))
+	for _, unsupported := range translation.Unsupported {
+		fmt.Fprintf(out, cqUnsupportedTemplate, unsupported)
+	}
+
+	for _, statement := range translation.Statements {
+		fmt.Fprintf(out, "%s;\n", statement)
+	}
+
+	fmt.Fprintln(out)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/cli"
+	"github.com/timescale/outflux/internal/continuousqueries"
+)
+
+func TestTranslateContinuousQueries(t *testing.T) {
+	queries := []*continuousqueries.ContinuousQuery{
+		{Name: "cq1", Query: "CREATE CONTINUOUS QUERY cq1 ON db BEGIN SELECT mean(a) INTO m_1h FROM m GROUP BY time(1h) END"},
+		{Name: "cq2", Query: "CREATE CONTINUOUS QUERY cq2 ON db BEGIN SELECT mean(a) INTO t FROM m WHERE host = 'a' GROUP BY time(1h) END"},
+	}
+	connArgs := &cli.ConnectionConfig{InputDb: "db"}
+	expected := "-- continuous query 'cq1': " + queries[0].Query + "\n" +
+		"CREATE MATERIALIZED VIEW \"m_1h\" WITH (timescaledb.continuous) AS\n" +
+		"SELECT time_bucket(INTERVAL '1 hour', \"time\") AS \"time\", avg(\"a\") AS \"mean\"\n" +
+		"FROM \"m\"\n" +
+		"GROUP BY time_bucket(INTERVAL '1 hour', \"time\");\n" +
+		"SELECT add_continuous_aggregate_policy('\"m_1h\"', start_offset => INTERVAL '2 hours', end_offset => INTERVAL '1 hour', schedule_interval => INTERVAL '1 hour');\n\n" +
+		"-- continuous query 'cq2': " + queries[1].Query + "\n" +
+		"-- unsupported: WHERE conditions are not supported\n\n"
+
+	app := &appContext{
+		ics:        &mockService{inflConn: &mockInfConn{}},
+		cqExplorer: &mockCQExplorer{queries: queries},
+	}
+	out := &bytes.Buffer{}
+	err := translateContinuousQueries(app, connArgs, &cli.ContinuousQueriesConfig{Quiet: true}, out)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, out.String())
+
+	// executing fails when the output database can't be reached
+	app.tscs = &mockTsConnSer{tsConnErr: fmt.Errorf("error")}
+	err = translateContinuousQueries(app, connArgs, &cli.ContinuousQueriesConfig{Quiet: true, Execute: true}, &bytes.Buffer{})
+	assert.Error(t, err)
+}
+
+func TestTranslateContinuousQueriesErrors(t *testing.T) {
+	connArgs := &cli.ConnectionConfig{InputDb: "db"}
+	args := &cli.ContinuousQueriesConfig{Quiet: true}
+	app := &appContext{ics: &mockService{inflConnErr: fmt.Errorf("error")}}
+	assert.Error(t, translateContinuousQueries(app, connArgs, args, &bytes.Buffer{}))
+
+	app = &appContext{
+		ics:        &mockService{inflConn: &mockInfConn{}},
+		cqExplorer: &mockCQExplorer{err: fmt.Errorf("error")},
+	}
+	assert.Error(t, translateContinuousQueries(app, connArgs, args, &bytes.Buffer{}))
+
+	app.cqExplorer = &mockCQExplorer{queries: []*continuousqueries.ContinuousQuery{{Name: "cq", Query: "SELECT * FROM m"}}}
+	assert.Error(t, translateContinuousQueries(app, connArgs, args, &bytes.Buffer{}))
+}
+
+type mockCQExplorer struct {
+	queries []*continuousqueries.ContinuousQuery
+	err     error
+}
+
+func (m *mockCQExplorer) FetchContinuousQueries(influxClient influx.Client, db string) ([]*continuousqueries.ContinuousQuery, error) {
+	return m.queries, m.err
+}
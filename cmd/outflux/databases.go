@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/timescale/outflux/internal/cli"
+)
+
+// databasesToMigrate returns the input databases given as argument, or all databases of the input server
+func databasesToMigrate(app *appContext, connArgs *cli.ConnectionConfig) ([]string, error) {
+	if !connArgs.AllDatabases {
+		if len(connArgs.InputDatabases) == 0 {
+			return []string{connArgs.InputDb}, nil
+		}
+
+		return connArgs.InputDatabases, nil
+	}
+
+	influxConn, err := app.ics.NewConnection(influxConnParams(connArgs))
+	if err != nil {
+		return nil, fmt.Errorf("could not open connection to Influx Server\n%v", err)
+	}
+
+	defer influxConn.Close()
+	databases, err := app.influxDbExplorer.FetchDatabases(influxConn)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch the databases of the input server\n%v", err)
+	}
+
+	if len(databases) == 0 {
+		return nil, fmt.Errorf("the input server has no databases")
+	}
+
+	return databases, nil
+}
+
+// databaseArgs returns copies of the connection and migration config that read from the input database. The
+// database placeholder is replaced with its name in the output connection string, schema, directory, script and
+// Influx database. A schema named after the database is created if it doesn't exist
+func databaseArgs(connArgs *cli.ConnectionConfig, args *cli.MigrationConfig, database string) (*cli.ConnectionConfig, *cli.MigrationConfig) {
+	dbConnArgs := *connArgs
+	dbConnArgs.InputDb = database
+	dbConnArgs.OutputDbConnString = strings.Replace(connArgs.OutputDbConnString, cli.DatabasePlaceholder, database, -1)
+
+	dbArgs := *args
+	if strings.Contains(args.OutputSchema, cli.DatabasePlaceholder) {
+		dbArgs.OutputSchema = strings.Replace(args.OutputSchema, cli.DatabasePlaceholder, database, -1)
+		dbArgs.CreateOutputSchema = true
+	}
+
+	if args.FileOutput != nil {
+		fileOutput := *args.FileOutput
+		fileOutput.Directory = strings.Replace(fileOutput.Directory, cli.DatabasePlaceholder, database, -1)
+		dbArgs.FileOutput = &fileOutput
+	}
+
+	if args.ScriptOutput != nil {
+		scriptOutput := *args.ScriptOutput
+		scriptOutput.Path = strings.Replace(scriptOutput.Path, cli.DatabasePlaceholder, database, -1)
+		dbArgs.ScriptOutput = &scriptOutput
+	}
+
+	if args.InfluxOutput != nil {
+		influxOutput := *args.InfluxOutput
+		influxOutput.Database = strings.Replace(influxOutput.Database, cli.DatabasePlaceholder, database, -1)
+		dbArgs.InfluxOutput = &influxOutput
+	}
+
+	return &dbConnArgs, &dbArgs
+}
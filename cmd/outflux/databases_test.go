@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/jackc/pgx"
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/cli"
+	"github.com/timescale/outflux/internal/ingestion/config"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+)
+
+func TestDatabasesToMigrate(t *testing.T) {
+	app := &appContext{ics: &mockService{inflConn: &mockInfConn{}}}
+
+	databases, err := databasesToMigrate(app, &cli.ConnectionConfig{InputDb: "db"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db"}, databases)
+
+	databases, err = databasesToMigrate(app, &cli.ConnectionConfig{InputDb: "db1", InputDatabases: []string{"db1", "db2"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db1", "db2"}, databases)
+
+	connArgs := &cli.ConnectionConfig{AllDatabases: true}
+	app.influxDbExplorer = &mockDbExplorer{databases: []string{"db1", "db2", "db3"}}
+	databases, err = databasesToMigrate(app, connArgs)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db1", "db2", "db3"}, databases)
+
+	app.influxDbExplorer = &mockDbExplorer{databases: []string{}}
+	_, err = databasesToMigrate(app, connArgs)
+	assert.Error(t, err)
+
+	app.influxDbExplorer = &mockDbExplorer{err: fmt.Errorf("error")}
+	_, err = databasesToMigrate(app, connArgs)
+	assert.Error(t, err)
+
+	app.ics = &mockService{inflConnErr: fmt.Errorf("error")}
+	_, err = databasesToMigrate(app, connArgs)
+	assert.Error(t, err)
+}
+
+func TestDatabaseArgs(t *testing.T) {
+	connArgs := &cli.ConnectionConfig{InputDb: "db1", OutputDbConnString: "dbname={database} user=postgres"}
+	args := &cli.MigrationConfig{OutputSchema: "public"}
+	dbConnArgs, dbArgs := databaseArgs(connArgs, args, "db2")
+	assert.Equal(t, "db2", dbConnArgs.InputDb)
+	assert.Equal(t, "dbname=db2 user=postgres", dbConnArgs.OutputDbConnString)
+	assert.Equal(t, "public", dbArgs.OutputSchema)
+	assert.False(t, dbArgs.CreateOutputSchema)
+	// the original config is not changed
+	assert.Equal(t, "db1", connArgs.InputDb)
+	assert.Equal(t, "dbname={database} user=postgres", connArgs.OutputDbConnString)
+
+	args = &cli.MigrationConfig{OutputSchema: "influx_{database}"}
+	_, dbArgs = databaseArgs(connArgs, args, "db2")
+	assert.Equal(t, "influx_db2", dbArgs.OutputSchema)
+	assert.True(t, dbArgs.CreateOutputSchema)
+	assert.Equal(t, "influx_{database}", args.OutputSchema)
+
+	args = &cli.MigrationConfig{OutputSchema: "public", FileOutput: &config.FileOutput{Directory: "out/{database}"}}
+	_, dbArgs = databaseArgs(connArgs, args, "db2")
+	assert.Equal(t, "out/db2", dbArgs.FileOutput.Directory)
+	assert.Equal(t, "out/{database}", args.FileOutput.Directory)
+
+	args = &cli.MigrationConfig{OutputSchema: "public", ScriptOutput: &config.ScriptOutput{Path: "out/{database}.sql"}}
+	_, dbArgs = databaseArgs(connArgs, args, "db2")
+	assert.Equal(t, "out/db2.sql", dbArgs.ScriptOutput.Path)
+	assert.Equal(t, "out/{database}.sql", args.ScriptOutput.Path)
+
+	args = &cli.MigrationConfig{InfluxOutput: &config.InfluxOutput{Server: "http://localhost:8086", Database: "{database}_copy"}}
+	_, dbArgs = databaseArgs(connArgs, args, "db2")
+	assert.Equal(t, "db2_copy", dbArgs.InfluxOutput.Database)
+	assert.Equal(t, "{database}_copy", args.InfluxOutput.Database)
+}
+
+func TestMigrationJobOutputTable(t *testing.T) {
+	connArgs := &cli.ConnectionConfig{InputDb: "db", OutputDbConnString: "dbname=db"}
+	job := &migrationJob{connArgs: connArgs, args: &cli.MigrationConfig{RetentionPolicy: "rp", OutputSchema: "public"}, measure: "cpu"}
+	assert.Equal(t, "db.rp.cpu", job.String())
+	assert.Equal(t, "dbname=db/public.cpu", job.outputTable())
+	job.args.RetentionPolicyMapping = schemaconfig.RPToTablePrefix
+	assert.Equal(t, "dbname=db/public.rp_cpu", job.outputTable())
+	job.args.FileOutput = &config.FileOutput{Directory: "out"}
+	assert.Equal(t, filepath.Join("out", "public", "rp_cpu"), job.outputTable())
+	job.args.FileOutput = nil
+	job.args.ScriptOutput = &config.ScriptOutput{Path: "out.sql"}
+	assert.Equal(t, "out.sql/public.rp_cpu", job.outputTable())
+}
+
+func TestMigrateSeveralDatabases(t *testing.T) {
+	pipe := &countingPipe{}
+	app := &appContext{
+		ics:              &mockService{inflConn: &mockInfConn{}},
+		tscs:             &mockTsConnSer{tsConn: &pgx.Conn{}},
+		pipeService:      &mockService{pipe: pipe},
+		influxDbExplorer: &mockDbExplorer{databases: []string{"db1", "db2"}},
+	}
+
+	conn := &cli.ConnectionConfig{AllDatabases: true, InputMeasures: []string{"a", "b"}, OutputDbConnString: "dbname={database}"}
+	mig := &cli.MigrationConfig{RetentionPolicy: "autogen", MaxParallel: 2, Quiet: true}
+	if err := migrate(app, conn, mig); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if pipe.runs != 4 {
+		t.Errorf("expected 4 pipelines to run, %d ran", pipe.runs)
+	}
+
+	// the errors of all pipelines are reported with the database they migrate
+	app.pipeService = &mockService{pipe: &mockPipe{runErr: fmt.Errorf("error")}}
+	err := migrate(app, conn, mig)
+	if err == nil {
+		t.Fatal("expected error, none received")
+	}
+
+	for _, job := range []string{"db1.autogen.a", "db1.autogen.b", "db2.autogen.a", "db2.autogen.b"} {
+		if !strings.Contains(err.Error(), job) {
+			t.Errorf("expected error of '%s' in:\n%v", job, err)
+		}
+	}
+}
+
+func TestRunMigrationJobsSharingATable(t *testing.T) {
+	pipe := &countingPipe{delay: 10 * time.Millisecond}
+	app := &appContext{
+		ics:         &mockService{inflConn: &mockInfConn{}},
+		tscs:        &mockTsConnSer{tsConn: &pgx.Conn{}},
+		pipeService: &mockService{pipe: pipe},
+	}
+
+	connArgs := &cli.ConnectionConfig{InputDb: "db"}
+	jobs := []*migrationJob{
+		{connArgs: connArgs, args: &cli.MigrationConfig{RetentionPolicy: "rp1", RetentionPolicyMapping: schemaconfig.RPToColumn}, measure: "cpu"},
+		{connArgs: connArgs, args: &cli.MigrationConfig{RetentionPolicy: "rp2", RetentionPolicyMapping: schemaconfig.RPToColumn}, measure: "cpu"},
+	}
+
+	pipeErrors := runMigrationJobs(app, jobs, nil, 2)
+	assert.Equal(t, []error{nil, nil}, pipeErrors)
+	assert.Equal(t, 2, pipe.runs)
+	assert.Equal(t, 1, pipe.maxRunning, "jobs writing to the same table ran at the same time")
+}
+
+type mockDbExplorer struct {
+	databases []string
+	err       error
+}
+
+func (m *mockDbExplorer) FetchDatabases(influxClient influx.Client) ([]string, error) {
+	return m.databases, m.err
+}
+
+// countingPipe counts its runs, and how many of them run at the same time
+type countingPipe struct {
+	lock       sync.Mutex
+	delay      time.Duration
+	runs       int
+	running    int
+	maxRunning int
+}
+
+func (p *countingPipe) ID() string { return "id" }
+func (p *countingPipe) Run() error {
+	p.lock.Lock()
+	p.runs++
+	p.running++
+	if p.running > p.maxRunning {
+		p.maxRunning = p.running
+	}
+	p.lock.Unlock()
+
+	time.Sleep(p.delay)
+	p.lock.Lock()
+	p.running--
+	p.lock.Unlock()
+	return nil
+}
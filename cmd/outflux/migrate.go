@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	influx "github.com/influxdata/influxdb/client/v2"
@@ -12,17 +15,21 @@ import (
 	"github.com/timescale/outflux/internal/cli"
 	"github.com/timescale/outflux/internal/cli/flagparsers"
 	"github.com/timescale/outflux/internal/connections"
+	"github.com/timescale/outflux/internal/pipeline"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+	"github.com/timescale/outflux/internal/schemamanagement/tsm/tsmstorage"
+	"github.com/timescale/outflux/internal/transformation/rpmapping"
 	"golang.org/x/sync/semaphore"
 )
 
 func initMigrateCmd() *cobra.Command {
 	migrateCmd := &cobra.Command{
-		Use:   "migrate database [measure1 measure2 ...]",
+		Use:   "migrate database[,database2 ...] [measure1 measure2 ...]",
 		Short: "Migrate the schema and data from InfluxDB measurements into TimescaleDB hypertables",
 		Long: "Migrate the data from InfluxDB measurements into TimescaleDB. Schema discovery detects the required" +
 			" table definition to be present in the target TimescaleDB and prepares it according to the selected startegy." +
 			" Then the data is transferred, each measurement in a separate hyper-table",
-		Args: cobra.MinimumNArgs(1),
+		Args: cobra.ArbitraryArgs,
 		Run: func(cmd *cobra.Command, args []string) {
 			app := initAppContext()
 			connArgs, migrateArgs, err := flagparsers.FlagsToMigrateConfig(cmd.Flags(), args)
@@ -37,120 +44,266 @@ func initMigrateCmd() *cobra.Command {
 			}
 		},
 	}
-	flagparsers.AddConnectionFlagsToCmd(migrateCmd)
-	migrateCmd.PersistentFlags().String(flagparsers.RetentionPolicyFlag, flagparsers.DefaultRetentionPolicy, "The retention policy to select the data from")
-	migrateCmd.PersistentFlags().String(flagparsers.SchemaStrategyFlag, flagparsers.DefaultSchemaStrategy.String(), "Strategy to use for preparing the schema of the output database. Valid options: ValidateOnly, CreateIfMissing, DropAndCreate, DropCascadeAndCreate")
-	migrateCmd.PersistentFlags().String(flagparsers.FromFlag, "", "If specified will export data with a timestamp >= of it's value. Accepted format: RFC3339")
-	migrateCmd.PersistentFlags().String(flagparsers.ToFlag, "", "If specified will export data with a timestamp <= of it's value. Accepted format: RFC3339")
-	migrateCmd.PersistentFlags().Uint64(flagparsers.LimitFlag, flagparsers.DefaultLimit, "If specified will limit the export points to it's value. 0 = NO LIMIT")
-	migrateCmd.PersistentFlags().Uint16(flagparsers.ChunkSizeFlag, flagparsers.DefaultChunkSize, "The export query will request the data in chunks of this size. Must be > 0")
-	migrateCmd.PersistentFlags().Uint16(flagparsers.DataBufferFlag, flagparsers.DefaultDataBufferSize, "Size of the buffer holding exported data ready to be inserted in the output database")
-	migrateCmd.PersistentFlags().Uint8(flagparsers.MaxParallelFlag, flagparsers.DefaultMaxParallel, "Number of parallel measure extractions. One InfluxDB measure is exported using 1 worker")
-	migrateCmd.PersistentFlags().Bool(flagparsers.RollbackOnExternalErrorFlag, flagparsers.DefaultRollbackOnExternalError, "If this flag is set, when an error occurs while extracting the data, the insertion will be rollbacked. Otherwise it will try to commit")
-	migrateCmd.PersistentFlags().String(flagparsers.CommitStrategyFlag, flagparsers.DefaultCommitStrategy.String(), "Determines whether to commit on each chunk extracted from Influx, or at the end. Valid options: CommitOnEnd and CommitOnEachBatch")
-	migrateCmd.PersistentFlags().Uint16(flagparsers.BatchSizeFlag, flagparsers.DefaultBatchSize, "The size of the batch inserted in to the output database")
-	migrateCmd.PersistentFlags().Bool(flagparsers.TagsAsJSONFlag, flagparsers.DefaultTagsAsJSON, "If this flag is set to true, then the Tags of the influx measures being exported will be combined into a single JSONb column in Timescale")
-	migrateCmd.PersistentFlags().String(flagparsers.TagsColumnFlag, flagparsers.DefaultTagsColumn, "When "+flagparsers.TagsAsJSONFlag+" is set, this column specifies the name of the JSON column for the tags")
-	migrateCmd.PersistentFlags().Bool(flagparsers.FieldsAsJSONFlag, flagparsers.DefaultFieldsAsJSON, "If this flag is set to true, then the Fields of the influx measures being exported will be combined into a single JSONb column in Timescale")
-	migrateCmd.PersistentFlags().String(flagparsers.FieldsColumnFlag, flagparsers.DefaultFieldsColumn, "When "+flagparsers.FieldsAsJSONFlag+" is set, this column specifies the name of the JSON column for the fields")
-	migrateCmd.PersistentFlags().String(flagparsers.OutputSchemaFlag, flagparsers.DefaultOutputSchema, "The schema of the output database that the data will be inserted into")
-	migrateCmd.PersistentFlags().Bool(flagparsers.MultishardIntFloatCast, flagparsers.DefaultMultishardIntFloatCast, "If a field is Int64 in one shard, and Float64 in another, with this flag it will be cast to Float64 despite possible data loss")
-	migrateCmd.PersistentFlags().String(flagparsers.ChunkTimeIntervalFlag, flagparsers.DefaultChunkTimeInterval, "chunk_time_interval of the hypertables created by Outflux")
-
+	addMigrateFlagsToCmd(migrateCmd)
+	migrateCmd.PersistentFlags().StringArray(flagparsers.DownsampleFlag, []string{}, "Extracts the aggregates of the fields grouped by time(interval) and all tags instead of the raw points. Format: interval[:older-than] with Go durations, e.g. 1h:720h aggregates the points older than 30 days to 1h. Repeat the flag for several resolutions, points newer than all older-than durations are extracted raw")
+	migrateCmd.PersistentFlags().String(flagparsers.DownsampleAggregatesFlag, flagparsers.DefaultDownsampleAggregates, "Aggregate each field is wrapped in when downsampling, by field type. Valid aggregates: mean, max, min, last, sum, count. Strings and booleans only support last and count")
+	migrateCmd.PersistentFlags().Bool(flagparsers.CompressFlag, flagparsers.DefaultCompress, "Enables TimescaleDB compression on the created hypertables")
+	migrateCmd.PersistentFlags().String(flagparsers.CompressSegmentByFlag, flagparsers.DefaultCompressSegmentBy, "Comma separated columns the compressed hypertables are segmented by. If not set, they are segmented by their tag columns, set it to an empty string to not segment them")
+	migrateCmd.PersistentFlags().String(flagparsers.CompressOrderByFlag, flagparsers.DefaultCompressOrderBy, "Order of the rows in the compressed chunks, e.g. 'time DESC'. If not set, TimescaleDB orders them by time descending")
+	migrateCmd.PersistentFlags().Uint64(flagparsers.CompressMaxCardinalityFlag, flagparsers.DefaultCompressMaxCardinality, "If > 0, only the tags with at most this many values segment the compressed hypertables, as reported by SHOW TAG VALUES CARDINALITY. Requires the v1 API of an input server")
+	migrateCmd.PersistentFlags().Duration(flagparsers.CompressAfterFlag, flagparsers.DefaultCompressAfter, "If > 0, a compression policy compressing chunks older than this is added to the created hypertables, and the chunks older than this are compressed once a measure is migrated")
+	migrateCmd.PersistentFlags().String(flagparsers.OutputDirFlag, flagparsers.DefaultOutputDir, "If specified, the measures are written to files in this directory instead of the output database, in a directory for each measure, in a directory for the output schema if one is set. Replaces '"+flagparsers.OutputConnFlag+"'")
+	migrateCmd.PersistentFlags().String(flagparsers.OutputFormatFlag, flagparsers.DefaultOutputFormat.String(), "Format of the files written to '"+flagparsers.OutputDirFlag+"'. Valid options: CSV, Parquet")
+	migrateCmd.PersistentFlags().Bool(flagparsers.OutputGzipFlag, flagparsers.DefaultOutputGzip, "If specified, the CSV files written to '"+flagparsers.OutputDirFlag+"' are gzipped")
+	migrateCmd.PersistentFlags().String(flagparsers.OutputPeriodFlag, flagparsers.DefaultOutputPeriod.String(), "Period of time covered by each file written to '"+flagparsers.OutputDirFlag+"', the files are named after its start in UTC. Valid options: Hour, Day, Month, Year")
+	migrateCmd.PersistentFlags().String(flagparsers.OutputScriptFlag, flagparsers.DefaultOutputScript, "If specified, the statements creating the tables and the rows as COPY blocks are written to this SQL script instead of the output database, to be applied with psql. '{measure}' in the path writes a script for each measure. Replaces '"+flagparsers.OutputConnFlag+"'")
+	migrateCmd.PersistentFlags().String(flagparsers.OutputInfluxFlag, flagparsers.DefaultOutputInflux, "If specified, the measures are written back as points to the InfluxDB server at this URL instead of the output database, with their tag columns as tags. Replaces '"+flagparsers.OutputConnFlag+"'")
+	migrateCmd.PersistentFlags().String(flagparsers.OutputInfluxDBFlag, flagparsers.DefaultOutputInfluxDB, "Database of '"+flagparsers.OutputInfluxFlag+"' the points are written to, must exist. '"+cli.DatabasePlaceholder+"' is replaced with the name of the input database")
+	migrateCmd.PersistentFlags().String(flagparsers.OutputInfluxRPFlag, flagparsers.DefaultOutputInfluxRP, "Retention policy of '"+flagparsers.OutputInfluxDBFlag+"' the points are written to. If not specified, the default retention policy of the database")
+	migrateCmd.PersistentFlags().String(flagparsers.OutputInfluxUserFlag, flagparsers.DefaultOutputInfluxUser, "Username to use when writing to '"+flagparsers.OutputInfluxFlag+"'")
+	migrateCmd.PersistentFlags().String(flagparsers.OutputInfluxPassFlag, flagparsers.DefaultOutputInfluxPass, "Password to use when writing to '"+flagparsers.OutputInfluxFlag+"'")
+	migrateCmd.PersistentFlags().String(flagparsers.OutputInfluxPrecisionFlag, flagparsers.DefaultOutputInfluxPrecision.String(), "Precision the timestamps of the points written to '"+flagparsers.OutputInfluxFlag+"' are truncated to. Valid options: ns, u, ms, s")
+	migrateCmd.PersistentFlags().Uint(flagparsers.OutputInfluxRetriesFlag, flagparsers.DefaultOutputInfluxRetries, "Number of times a batch of points is written again to '"+flagparsers.OutputInfluxFlag+"' after a network or server error")
+	migrateCmd.PersistentFlags().Duration(flagparsers.OutputInfluxRetryFlag, flagparsers.DefaultOutputInfluxRetry, "Wait before the first retry of a batch of points written to '"+flagparsers.OutputInfluxFlag+"', doubled with each retry")
+	migrateCmd.PersistentFlags().String(flagparsers.InputConnFlag, flagparsers.DefaultInputConn, "If specified, the tables of the TimescaleDB or PostgreSQL database at this connection string are read instead of InfluxDB, the database argument is their schema. Requires '"+flagparsers.OutputInfluxFlag+"'")
+	migrateCmd.PersistentFlags().String(flagparsers.InputTagColumnsFlag, flagparsers.DefaultInputTagColumns, "Comma separated columns of the tables read from '"+flagparsers.InputConnFlag+"' that are written as tags. If not specified, the text columns")
+	migrateCmd.PersistentFlags().String(flagparsers.OutputTypeFlag, flagparsers.DefaultOutputType.String(), "Type of the output database. PostgreSQL creates tables range partitioned on the time column instead of hypertables, with partitions covering the migrated time range. Valid options: TimescaleDB, PostgreSQL")
+	migrateCmd.PersistentFlags().Duration(flagparsers.PartitionIntervalFlag, flagparsers.DefaultPartitionInterval, "Time covered by each partition of the tables created with the "+schemaconfig.PostgreSQLOutput.String()+" output type, in whole seconds")
+	migrateCmd.PersistentFlags().Bool(flagparsers.ResumeFlag, flagparsers.DefaultResume, "If specified, each measurement will be migrated starting from the checkpoint recorded in the output database by a previous run. Replaces the value of '"+flagparsers.FromFlag+"' when a checkpoint exists")
 	return migrateCmd
 }
 
+// addMigrateFlagsToCmd adds the flags shared by the commands that transfer data
+func addMigrateFlagsToCmd(cmd *cobra.Command) {
+	flagparsers.AddConnectionFlagsToCmd(cmd)
+	cmd.PersistentFlags().String(flagparsers.RetentionPolicyFlag, flagparsers.DefaultRetentionPolicy, "The retention policy to select the data from, or '"+cli.AllRetentionPolicies+"' for every retention policy. If not specified, the default retention policy of the input database")
+	cmd.PersistentFlags().String(flagparsers.RetentionPolicyMappingFlag, flagparsers.DefaultRetentionPolicyMapping.String(), "How the data of a retention policy is kept apart from the other retention policies. Schema creates the tables in a schema named after the retention policy, Prefix prefixes the table names with it, Column adds a '"+rpmapping.ColumnName+"' column to tables shared by the retention policies. Valid options: None, Schema, Prefix, Column")
+	cmd.PersistentFlags().String(flagparsers.SchemaStrategyFlag, flagparsers.DefaultSchemaStrategy.String(), "Strategy to use for preparing the schema of the output database. Valid options: ValidateOnly, CreateIfMissing, DropAndCreate, DropCascadeAndCreate")
+	cmd.PersistentFlags().String(flagparsers.FromFlag, "", "If specified will export data with a timestamp >= of it's value. Accepted format: RFC3339")
+	cmd.PersistentFlags().String(flagparsers.ToFlag, "", "If specified will export data with a timestamp <= of it's value. Accepted format: RFC3339")
+	cmd.PersistentFlags().Uint64(flagparsers.LimitFlag, flagparsers.DefaultLimit, "If specified will limit the export points to it's value. 0 = NO LIMIT")
+	cmd.PersistentFlags().Uint16(flagparsers.ChunkSizeFlag, flagparsers.DefaultChunkSize, "The export query will request the data in chunks of this size. Must be > 0")
+	cmd.PersistentFlags().Uint16(flagparsers.DataBufferFlag, flagparsers.DefaultDataBufferSize, "Size of the buffer holding exported data ready to be inserted in the output database")
+	cmd.PersistentFlags().Uint8(flagparsers.MaxParallelFlag, flagparsers.DefaultMaxParallel, "Number of parallel measure extractions. One InfluxDB measure is exported using the number of workers set by extraction-workers")
+	cmd.PersistentFlags().Uint8(flagparsers.ExtractionWorkersFlag, flagparsers.DefaultExtractionWorkers, "Number of concurrent queries extracting a single measure. If > 1 each measure is split in time windows aligned to the shard groups")
+	cmd.PersistentFlags().StringToInt(flagparsers.MeasureWorkersFlag, map[string]int{}, "Overrides '"+flagparsers.ExtractionWorkersFlag+"' for specific measures. Format: measure1=workers1,measure2=workers2")
+	cmd.PersistentFlags().Duration(flagparsers.ExtractionWindowFlag, flagparsers.DefaultExtractionWindow, "If > 0, measures extracted with multiple workers, or with the v2 input API, are split in fixed time windows of this size instead of the shard groups")
+	cmd.PersistentFlags().Uint8(flagparsers.IngestionWorkersFlag, flagparsers.DefaultIngestionWorkers, "Number of connections inserting the batches of a single measure at the same time. If > 1 each batch is committed in its own transaction, the commit strategy must be CommitOnEachBatch")
+	cmd.PersistentFlags().StringToInt(flagparsers.MeasureIngestionWorkersFlag, map[string]int{}, "Overrides '"+flagparsers.IngestionWorkersFlag+"' for specific measures. Format: measure1=workers1,measure2=workers2")
+	cmd.PersistentFlags().Bool(flagparsers.RollbackOnExternalErrorFlag, flagparsers.DefaultRollbackOnExternalError, "If this flag is set, when an error occurs while extracting the data, the insertion will be rollbacked. Otherwise it will try to commit")
+	cmd.PersistentFlags().String(flagparsers.CommitStrategyFlag, flagparsers.DefaultCommitStrategy.String(), "Determines whether to commit on each chunk extracted from Influx, or at the end. Valid options: CommitOnEnd and CommitOnEachBatch")
+	cmd.PersistentFlags().String(flagparsers.OnConflictFlag, flagparsers.DefaultOnConflict.String(), "How rows with the time and tags of an existing row are handled. None copies all rows into the tables, DoNothing keeps the existing rows and DoUpdate overwrites their fields like InfluxDB, upserting the rows on a unique index through a staging table. Valid options: None, DoNothing, DoUpdate")
+	cmd.PersistentFlags().Uint16(flagparsers.BatchSizeFlag, flagparsers.DefaultBatchSize, "The size of the batch inserted in to the output database")
+	cmd.PersistentFlags().Bool(flagparsers.TagsAsJSONFlag, flagparsers.DefaultTagsAsJSON, "If this flag is set to true, then the Tags of the influx measures being exported will be combined into a single JSONb column in Timescale")
+	cmd.PersistentFlags().String(flagparsers.TagsColumnFlag, flagparsers.DefaultTagsColumn, "When "+flagparsers.TagsAsJSONFlag+" is set, this column specifies the name of the JSON column for the tags")
+	cmd.PersistentFlags().Bool(flagparsers.FieldsAsJSONFlag, flagparsers.DefaultFieldsAsJSON, "If this flag is set to true, then the Fields of the influx measures being exported will be combined into a single JSONb column in Timescale")
+	cmd.PersistentFlags().String(flagparsers.FieldsColumnFlag, flagparsers.DefaultFieldsColumn, "When "+flagparsers.FieldsAsJSONFlag+" is set, this column specifies the name of the JSON column for the fields")
+	cmd.PersistentFlags().String(flagparsers.OutputSchemaFlag, flagparsers.DefaultOutputSchema, "The schema of the output database that the data will be inserted into")
+	cmd.PersistentFlags().Bool(flagparsers.MultishardIntFloatCast, flagparsers.DefaultMultishardIntFloatCast, "If a field is Int64 in one shard, and Float64 in another, with this flag it will be cast to Float64 despite possible data loss")
+	cmd.PersistentFlags().String(flagparsers.ChunkTimeIntervalFlag, flagparsers.DefaultChunkTimeInterval, "chunk_time_interval of the hypertables created by Outflux")
+	cmd.PersistentFlags().Bool(flagparsers.AutoChunkTimeIntervalFlag, flagparsers.DefaultAutoChunkTimeInterval, "If specified, the chunk_time_interval of each created hypertable is sized from the point rate and row width of its measure, so a chunk holds about '"+flagparsers.ChunkTargetSizeFlag+"'. Only with the "+cli.InputAPIV1+" input API of an input server")
+	cmd.PersistentFlags().Uint64(flagparsers.ChunkTargetSizeFlag, flagparsers.DefaultChunkTargetSize, "Size in MB of the chunks '"+flagparsers.AutoChunkTimeIntervalFlag+"' sizes the chunk_time_interval for")
+	cmd.PersistentFlags().Duration(flagparsers.DensitySampleWindowFlag, flagparsers.DefaultDensitySampleWindow, "Time window before the newest point of a measure in which '"+flagparsers.AutoChunkTimeIntervalFlag+"' counts its points")
+	cmd.PersistentFlags().Bool(flagparsers.CreateIndexesFlag, flagparsers.DefaultCreateIndexes, "If specified, once the data of a measure is loaded its hypertable gets a (tag, time DESC) index for each tag column, and a GIN index for each JSONB column. Skipped with the ValidateOnly schema strategy")
+	cmd.PersistentFlags().String(flagparsers.IndexColumnsFlag, flagparsers.DefaultIndexColumns, "Comma separated tag columns indexed by '"+flagparsers.CreateIndexesFlag+"' instead of all tag columns")
+	cmd.PersistentFlags().StringToString(flagparsers.SpacePartitioningFlag, map[string]string{}, "Hash partitions the hypertables created for specific measures by a column, in addition to the time column. Existing hypertables must be partitioned the same way. Format: measure1=column1:partitions1,measure2=column2:partitions2")
+	cmd.PersistentFlags().Bool(flagparsers.AddRetentionPolicyFlag, flagparsers.DefaultAddRetentionPolicy, "If specified, the created hypertables get a retention policy with the duration of the InfluxDB retention policy, and its shard group duration is the default '"+flagparsers.ChunkTimeIntervalFlag+"'")
+	cmd.PersistentFlags().String(flagparsers.WhereFlag, flagparsers.DefaultWhere, "If specified will export only the points matching this InfluxQL condition on tags and fields, e.g. \"region = 'eu'\". The time range is set with '"+flagparsers.FromFlag+"' and '"+flagparsers.ToFlag+"'")
+	cmd.PersistentFlags().String(flagparsers.WhereFileFlag, flagparsers.DefaultWhereFile, "JSON file with InfluxQL conditions for specific measures, ANDed with '"+flagparsers.WhereFlag+"'. Format: {\"measure1\": \"condition1\", \"measure2\": \"condition2\"}")
+	cmd.PersistentFlags().String(flagparsers.TimeFormatFlag, flagparsers.DefaultTimeFormat.String(), "Representation of the time column in the output database. Timestamptz has microsecond precision, TimestamptzWithNanos adds a column with the truncated nanoseconds and EpochNanos stores the nanoseconds since the Unix epoch in a BIGINT column. Valid options: Timestamptz, TimestamptzWithNanos, EpochNanos")
+}
+
 func migrate(app *appContext, connArgs *cli.ConnectionConfig, args *cli.MigrationConfig) error {
 	if args.Quiet {
 		log.SetFlags(0)
 		log.SetOutput(ioutil.Discard)
 	}
 
-	if len(connArgs.InputMeasures) == 0 {
-		influxConn, err := app.ics.NewConnection(influxConnParams(connArgs))
-		if err != nil {
-			return fmt.Errorf("could not open connection to Influx Server\n%v", err)
+	startTime := time.Now()
+	databases, err := databasesToMigrate(app, connArgs)
+	if err != nil {
+		return err
+	}
+
+	storage, err := openInputStorage(connArgs)
+	if err != nil {
+		return err
+	}
+
+	defer storage.Close()
+	jobs := []*migrationJob{}
+	for _, database := range databases {
+		dbConnArgs, dbArgs := databaseArgs(connArgs, args, database)
+		if len(databases) > 1 {
+			log.Printf("Preparing the migration of database '%s'", database)
 		}
-		connArgs.InputMeasures, err = discoverMeasures(app, influxConn, connArgs.InputDb, args.RetentionPolicy, args.OnConflictConvertIntToFloat)
-		influxConn.Close()
+
+		dbJobs, err := planDatabaseMigration(app, dbConnArgs, dbArgs, storage)
 		if err != nil {
-			return fmt.Errorf("could not discover the available measures for the input db '%s'", connArgs.InputDb)
+			return err
 		}
-	}
-
-	startTime := time.Now()
-	pipelineSemaphore := semaphore.NewWeighted(int64(args.MaxParallel))
-	ctx := context.Background()
-	pipeChannels := makePipeChannels(len(connArgs.InputMeasures))
 
-	// schedule all pipelines, as soon a value in the semaphore is available, execution will start
-	for i, measure := range connArgs.InputMeasures {
-		go pipeRoutine(ctx, pipelineSemaphore, app, connArgs, args, measure, pipeChannels[i])
+		jobs = append(jobs, dbJobs...)
 	}
 
-	log.Println("All pipelines scheduled")
-	hasError := false
-	pipeErrors := make([]error, len(pipeChannels))
-	for i, pipeChannel := range pipeChannels {
-		pipeErrors[i] = <-pipeChannel
-		if pipeErrors[i] != nil {
-			hasError = true
+	pipeErrors := runMigrationJobs(app, jobs, storage, args.MaxParallel)
+	failed := 0
+	for _, pipeError := range pipeErrors {
+		if pipeError != nil {
+			failed++
 		}
 	}
 
-	log.Println("All pipelines finished")
+	log.Printf("Migrated %d of %d measures from %d databases", len(jobs)-failed, len(jobs), len(databases))
+	for _, job := range jobs {
+		if interval, ok := job.args.MeasureChunkTimeIntervals[job.measure]; ok {
+			log.Printf("Hypertable of '%s' was sized with chunk_time_interval '%s'", job, interval)
+		}
+	}
 
 	executionTime := time.Since(startTime).Seconds()
 	log.Printf("Migration execution time: %.3f seconds\n", executionTime)
-	if hasError {
+	if failed > 0 {
 		return preparePipeErrors(pipeErrors)
 	}
 
 	return nil
 }
 
-func pipeRoutine(
-	ctx context.Context,
-	semaphore *semaphore.Weighted,
-	app *appContext,
-	connArgs *cli.ConnectionConfig,
-	args *cli.MigrationConfig,
-	measure string,
-	pipeChannel chan error) {
-	_ = semaphore.Acquire(ctx, 1)
+// migrationJob is the migration of a measure of an input database and retention policy by one pipeline
+type migrationJob struct {
+	connArgs *cli.ConnectionConfig
+	args     *cli.MigrationConfig
+	measure  string
+}
 
-	infConn, pgConn, err := openConnections(app, connArgs)
+func (j *migrationJob) String() string {
+	return fmt.Sprintf("%s.%s.%s", j.connArgs.InputDb, j.args.RetentionPolicy, j.measure)
+}
 
-	if err != nil {
-		pipeChannel <- fmt.Errorf("could not open connections to input and output database\n%v", err)
-		return
+// outputTable identifies the table the job writes to, with the retention policy mapping applied
+func (j *migrationJob) outputTable() string {
+	table := j.measure
+	if j.args.RetentionPolicyMapping == schemaconfig.RPToTablePrefix {
+		table = fmt.Sprintf(rpmapping.TablePrefixTemplate, j.args.RetentionPolicy, j.measure)
 	}
-	defer infConn.Close()
-	defer pgConn.Close()
-	pipe, err := app.pipeService.Create(infConn, pgConn, measure, connArgs.InputDb, args)
-	if err != nil {
-		pipeChannel <- fmt.Errorf("could not create execution pipeline for measure '%s'\n%v", measure, err)
-		return
+
+	if j.args.FileOutput != nil {
+		return filepath.Join(j.args.FileOutput.Directory, j.args.OutputSchema, table)
 	}
 
-	log.Printf("%s starting execution\n", pipe.ID())
-	err = pipe.Run()
+	if j.args.ScriptOutput != nil {
+		return fmt.Sprintf("%s/%s.%s", j.args.ScriptOutput.Path, j.args.OutputSchema, table)
+	}
+
+	if j.args.InfluxOutput != nil {
+		return fmt.Sprintf("%s/%s.%s.%s", j.args.InfluxOutput.Server, j.args.InfluxOutput.Database, j.args.InfluxOutput.RetentionPolicy, table)
+	}
+
+	return fmt.Sprintf("%s/%s.%s", j.connArgs.OutputDbConnString, j.args.OutputSchema, table)
+}
+
+// planDatabaseMigration resolves the retention policies and measures of an input database, prepares the
+// output schemas they are mapped to, and returns a job for each measure of each retention policy
+func planDatabaseMigration(app *appContext, connArgs *cli.ConnectionConfig, args *cli.MigrationConfig, storage *tsmstorage.Storage) ([]*migrationJob, error) {
+	policies, err := retentionPoliciesToMigrate(app, connArgs, args)
 	if err != nil {
-		log.Printf("%s: %v\n", pipe.ID(), err)
-		pipeChannel <- err
+		return nil, err
 	}
 
-	close(pipeChannel)
-	semaphore.Release(1)
+	jobs := []*migrationJob{}
+	for i, policy := range policies {
+		rpArgs := retentionPolicyArgs(args, policy, i == 0)
+		if len(policies) > 1 {
+			log.Printf("Preparing the migration of retention policy '%s'", policy.Name)
+		}
+
+		measures, err := resolveMeasures(app, connArgs, rpArgs, storage)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = resolveTagColumns(app, connArgs, rpArgs, storage, measures); err != nil {
+			return nil, err
+		}
+
+		if err = resolveChunkTimeIntervals(app, connArgs, rpArgs, measures); err != nil {
+			return nil, err
+		}
+
+		if err = resolveTimeRanges(app, connArgs, rpArgs, measures); err != nil {
+			return nil, err
+		}
+
+		if err = createOutputSchema(app, connArgs, rpArgs); err != nil {
+			return nil, err
+		}
+
+		for _, measure := range measures {
+			jobs = append(jobs, &migrationJob{connArgs: connArgs, args: rpArgs, measure: measure})
+		}
+	}
+
+	return jobs, nil
+}
+
+// runMigrationJobs runs the pipelines of all jobs, at most maxParallel at the same time, and returns
+// the error of each job. Jobs that write to the same output table run one after the other in the order
+// they were planned, so a table dropped and created for one retention policy is not written to by another
+func runMigrationJobs(app *appContext, jobs []*migrationJob, storage *tsmstorage.Storage, maxParallel uint8) []error {
+	chainIndexes := make(map[string]int)
+	chains := [][]int{}
+	for i, job := range jobs {
+		table := job.outputTable()
+		chainIndex, ok := chainIndexes[table]
+		if !ok {
+			chainIndex = len(chains)
+			chainIndexes[table] = chainIndex
+			chains = append(chains, []int{})
+		}
+
+		chains[chainIndex] = append(chains[chainIndex], i)
+	}
+
+	pipelineSemaphore := semaphore.NewWeighted(int64(maxParallel))
+	ctx := context.Background()
+	pipeErrors := make([]error, len(jobs))
+	wg := &sync.WaitGroup{}
+	wg.Add(len(chains))
+
+	// schedule all pipelines, as soon a value in the semaphore is available, execution will start
+	for _, chain := range chains {
+		go func(chain []int) {
+			defer wg.Done()
+			for _, i := range chain {
+				pipeErrors[i] = pipeRoutine(ctx, pipelineSemaphore, app, jobs[i], storage)
+			}
+		}(chain)
+	}
+
+	log.Println("All pipelines scheduled")
+	wg.Wait()
+	log.Println("All pipelines finished")
+	return pipeErrors
 }
 
-func makePipeChannels(numChannels int) []chan error {
-	channels := make([]chan error, numChannels)
-	for i := 0; i < numChannels; i++ {
-		channels[i] = make(chan error)
+func pipeRoutine(ctx context.Context, semaphore *semaphore.Weighted, app *appContext, job *migrationJob, storage *tsmstorage.Storage) error {
+	_ = semaphore.Acquire(ctx, 1)
+	defer semaphore.Release(1)
+
+	pipe, closeConnections, err := createPipe(app, job.connArgs, job.args, storage, job.measure)
+	if err != nil {
+		return fmt.Errorf("%s: %v", job, err)
 	}
+	defer closeConnections()
 
-	return channels
+	log.Printf("%s starting execution\n", pipe.ID())
+	if err = pipe.Run(); err != nil {
+		log.Printf("%s: %v\n", pipe.ID(), err)
+		return fmt.Errorf("%s: %v", job, err)
+	}
+
+	return nil
 }
 
 func preparePipeErrors(errors []error) error {
@@ -179,6 +332,252 @@ func openConnections(app *appContext, connArgs *cli.ConnectionConfig) (influx.Cl
 	return influxConn, tsConn, nil
 }
 
+func openV2Connections(app *appContext, connArgs *cli.ConnectionConfig) (connections.InfluxV2Client, connections.PgxWrap, error) {
+	v2Conn, err := app.icsV2.NewConnection(influxV2ConnParams(connArgs))
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not open connection to the v2 API of the Influx Server\n%v", err)
+	}
+
+	tsConn, err := app.tscs.NewConnection(connArgs.OutputDbConnString)
+	if err != nil {
+		v2Conn.Close()
+		return nil, nil, fmt.Errorf("could not open connection to TimescaleDB Server\n%v", err)
+	}
+
+	return v2Conn, tsConn, nil
+}
+
+// openInputStorage finds the TSM shards of the input data directory or portable backup,
+// and returns nil if none is set. Closing a nil storage is a no-op
+func openInputStorage(connArgs *cli.ConnectionConfig) (*tsmstorage.Storage, error) {
+	if connArgs.InputDataDir == "" {
+		return nil, nil
+	}
+
+	storage, err := tsmstorage.Open(connArgs.InputDataDir, connArgs.InputWALDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not open the shards in '%s'\n%v", connArgs.InputDataDir, err)
+	}
+
+	return storage, nil
+}
+
+// openWorkerConnections opens the connections to the output database that insert the batches
+// of a measure at the same time. None are opened for a single worker, the batches are then
+// inserted with the connection of the pipeline
+func openWorkerConnections(app *appContext, connArgs *cli.ConnectionConfig, workers uint8) ([]connections.PgxWrap, error) {
+	if workers <= 1 {
+		return nil, nil
+	}
+
+	workerConns := make([]connections.PgxWrap, 0, workers)
+	for i := uint8(0); i < workers; i++ {
+		conn, err := app.tscs.NewConnection(connArgs.OutputDbConnString)
+		if err != nil {
+			closeWorkerConnections(workerConns)
+			return nil, fmt.Errorf("could not open connection %d of %d to TimescaleDB Server\n%v", i+1, workers, err)
+		}
+
+		workerConns = append(workerConns, conn)
+	}
+
+	return workerConns, nil
+}
+
+func closeWorkerConnections(workerConns []connections.PgxWrap) {
+	for _, conn := range workerConns {
+		conn.Close()
+	}
+}
+
+// createPipe opens the connections to the input and output database, with the input API
+// selected in the connection config, and creates the pipeline for a measure. When an input
+// file or data directory is set only the output database is connected to, the shards of
+// the data directory are read from the opened storage. If the measure is ingested by more than
+// one worker, a connection to the output database is opened for each of them.
+// The returned function closes the connections
+func createPipe(app *appContext, connArgs *cli.ConnectionConfig, args *cli.MigrationConfig, storage *tsmstorage.Storage, measure string) (pipeline.Pipe, func(), error) {
+	if args.FileOutput != nil || args.ScriptOutput != nil || args.InfluxOutput != nil {
+		return createFilePipe(app, connArgs, args, storage, measure)
+	}
+
+	workerConns, err := openWorkerConnections(app, connArgs, args.MeasureIngestionWorkerCount(measure))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var pipe pipeline.Pipe
+	var closeConnections func()
+	if connArgs.InputFile != "" || storage != nil {
+		pgConn, connErr := app.tscs.NewConnection(connArgs.OutputDbConnString)
+		if connErr != nil {
+			closeWorkerConnections(workerConns)
+			return nil, nil, fmt.Errorf("could not open connection to TimescaleDB Server\n%v", connErr)
+		}
+
+		closeConnections = func() {
+			pgConn.Close()
+			closeWorkerConnections(workerConns)
+		}
+		if storage != nil {
+			pipe, err = app.pipeService.CreateFromTSM(storage, pgConn, workerConns, measure, connArgs.InputDb, args)
+		} else {
+			pipe, err = app.pipeService.CreateFromFile(connArgs.InputFile, pgConn, workerConns, measure, connArgs.InputDb, args)
+		}
+	} else if connArgs.InputAPI == cli.InputAPIV2 {
+		v2Conn, pgConn, connErr := openV2Connections(app, connArgs)
+		if connErr != nil {
+			closeWorkerConnections(workerConns)
+			return nil, nil, fmt.Errorf("could not open connections to input and output database\n%v", connErr)
+		}
+
+		closeConnections = func() {
+			v2Conn.Close()
+			pgConn.Close()
+			closeWorkerConnections(workerConns)
+		}
+		pipe, err = app.pipeService.CreateV2(v2Conn, pgConn, workerConns, measure, connArgs.InputDb, args)
+	} else {
+		infConn, pgConn, connErr := openConnections(app, connArgs)
+		if connErr != nil {
+			closeWorkerConnections(workerConns)
+			return nil, nil, fmt.Errorf("could not open connections to input and output database\n%v", connErr)
+		}
+
+		closeConnections = func() {
+			infConn.Close()
+			pgConn.Close()
+			closeWorkerConnections(workerConns)
+		}
+		pipe, err = app.pipeService.Create(infConn, pgConn, workerConns, measure, connArgs.InputDb, args)
+	}
+	if err != nil {
+		closeConnections()
+		return nil, nil, fmt.Errorf("could not create execution pipeline for measure '%s'\n%v", measure, err)
+	}
+
+	return pipe, closeConnections, nil
+}
+
+// createFilePipe creates the pipeline for a measure written to files, a script or InfluxDB. Only the input database
+// is connected to, the pipeline gets no connection to the output database.
+// The returned function closes the connection
+func createFilePipe(app *appContext, connArgs *cli.ConnectionConfig, args *cli.MigrationConfig, storage *tsmstorage.Storage, measure string) (pipeline.Pipe, func(), error) {
+	var pipe pipeline.Pipe
+	var err error
+	closeConnections := func() {}
+	if connArgs.InputConn != "" {
+		pgConn, connErr := app.tscs.NewConnection(connArgs.InputConn)
+		if connErr != nil {
+			return nil, nil, fmt.Errorf("could not open connection to the input database\n%v", connErr)
+		}
+
+		closeConnections = func() { pgConn.Close() }
+		pipe, err = app.pipeService.CreateFromTimescale(pgConn, measure, connArgs.InputDb, args)
+	} else if storage != nil {
+		pipe, err = app.pipeService.CreateFromTSM(storage, nil, nil, measure, connArgs.InputDb, args)
+	} else if connArgs.InputFile != "" {
+		pipe, err = app.pipeService.CreateFromFile(connArgs.InputFile, nil, nil, measure, connArgs.InputDb, args)
+	} else if connArgs.InputAPI == cli.InputAPIV2 {
+		v2Conn, connErr := app.icsV2.NewConnection(influxV2ConnParams(connArgs))
+		if connErr != nil {
+			return nil, nil, fmt.Errorf("could not open connection to the v2 API of the Influx Server\n%v", connErr)
+		}
+
+		closeConnections = func() { v2Conn.Close() }
+		pipe, err = app.pipeService.CreateV2(v2Conn, nil, nil, measure, connArgs.InputDb, args)
+	} else {
+		infConn, connErr := app.ics.NewConnection(influxConnParams(connArgs))
+		if connErr != nil {
+			return nil, nil, fmt.Errorf("could not open connection to Influx Server\n%v", connErr)
+		}
+
+		closeConnections = func() { infConn.Close() }
+		pipe, err = app.pipeService.Create(infConn, nil, nil, measure, connArgs.InputDb, args)
+	}
+	if err != nil {
+		closeConnections()
+		return nil, nil, fmt.Errorf("could not create execution pipeline for measure '%s'\n%v", measure, err)
+	}
+
+	return pipe, closeConnections, nil
+}
+
+// resolveMeasures returns the measures given as arguments, or the measures discovered in the input
+// if none were given, narrowed down by the include and exclude patterns of the connection config
+func resolveMeasures(app *appContext, connArgs *cli.ConnectionConfig, args *cli.MigrationConfig, storage *tsmstorage.Storage) ([]string, error) {
+	measures := connArgs.InputMeasures
+	if len(measures) == 0 {
+		log.Printf("No measurements explicitly specified. Discovering automatically")
+		var err error
+		measures, err = discoverInputMeasures(app, connArgs, args, storage)
+		if err != nil {
+			return nil, fmt.Errorf("could not discover the available measures for the input db '%s'\n%v", connArgs.InputDb, err)
+		}
+	}
+
+	selected, err := connArgs.MeasureSelection.Select(measures)
+	if err != nil {
+		return nil, fmt.Errorf("could not select the measures of the input db '%s'\n%v", connArgs.InputDb, err)
+	}
+
+	log.Printf("Selected %d measurements: %s", len(selected), strings.Join(selected, ", "))
+	return selected, nil
+}
+
+// discoverInputMeasures lists the measures of the input database with the input API selected in the connection config,
+// the measures of the database in the input file or shards, or the tables of the schema of the input database
+func discoverInputMeasures(app *appContext, connArgs *cli.ConnectionConfig, args *cli.MigrationConfig, storage *tsmstorage.Storage) ([]string, error) {
+	if connArgs.InputConn != "" {
+		pgConn, err := app.tscs.NewConnection(connArgs.InputConn)
+		if err != nil {
+			return nil, fmt.Errorf("could not open connection to the input database\n%v", err)
+		}
+
+		defer pgConn.Close()
+		schemaManager := app.schemaManagerService.TimeScale(pgConn, connArgs.InputDb, "", 0, nil, nil, nil)
+		return schemaManager.DiscoverDataSets()
+	}
+
+	if storage != nil {
+		schemaManager := app.schemaManagerService.TSM(storage, connArgs.InputDb, args.RetentionPolicy, args.OnConflictConvertIntToFloat)
+		return schemaManager.DiscoverDataSets()
+	}
+
+	if connArgs.InputFile != "" {
+		schemaManager := app.schemaManagerService.LineProtocol(connArgs.InputFile, connArgs.InputDb, args.RetentionPolicy, args.OnConflictConvertIntToFloat)
+		return schemaManager.DiscoverDataSets()
+	}
+
+	if connArgs.InputAPI == cli.InputAPIV2 {
+		v2Conn, err := app.icsV2.NewConnection(influxV2ConnParams(connArgs))
+		if err != nil {
+			return nil, fmt.Errorf("could not open connection to the v2 API of the Influx Server\n%v", err)
+		}
+
+		defer v2Conn.Close()
+		schemaManager := app.schemaManagerService.InfluxV2(v2Conn, connArgs.InputDb, args.OnConflictConvertIntToFloat)
+		return schemaManager.DiscoverDataSets()
+	}
+
+	influxConn, err := app.ics.NewConnection(influxConnParams(connArgs))
+	if err != nil {
+		return nil, fmt.Errorf("could not open connection to Influx Server\n%v", err)
+	}
+
+	defer influxConn.Close()
+	return discoverMeasures(app, influxConn, connArgs.InputDb, args.RetentionPolicy, args.OnConflictConvertIntToFloat)
+}
+
+func influxV2ConnParams(connParams *cli.ConnectionConfig) *connections.InfluxV2ConnectionParams {
+	return &connections.InfluxV2ConnectionParams{
+		Server:      connParams.InputHost,
+		Token:       connParams.InputToken,
+		Org:         connParams.InputOrg,
+		UnsafeHTTPS: connParams.InputUnsafeHTTPS,
+	}
+}
+
 func influxConnParams(connParams *cli.ConnectionConfig) *connections.InfluxConnectionParams {
 	return &connections.InfluxConnectionParams{
 		Server:      connParams.InputHost,
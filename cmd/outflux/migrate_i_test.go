@@ -71,6 +71,92 @@ func TestMigrateSingleValue(t *testing.T) {
 	}
 }
 
+func TestMigrateTwiceWithUpsert(t *testing.T) {
+	db := "test_upsert"
+	measure := "test"
+	tags := map[string]string{"tag1": "1"}
+	noTags := map[string]string{}
+	fieldValues := map[string]interface{}{"field1": 1}
+	if err := testutils.PrepareServersForITest(db); err != nil {
+		t.Fatalf("could not prepare servers: %v", err)
+	}
+	defer testutils.ClearServersAfterITest(db)
+
+	// the series without tag1 has NULL in its column
+	err := testutils.CreateInfluxMeasure(db, measure, []*map[string]string{&tags, &noTags}, []*map[string]interface{}{&fieldValues, &fieldValues})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the second run finds the rows of the first one
+	appContext := initAppContext()
+	for _, onConflict := range []ingestionConfig.ConflictStrategy{ingestionConfig.ConflictDoNothing, ingestionConfig.ConflictDoUpdate} {
+		connConf, config := defaultConfig(db, measure)
+		config.OnConflict = onConflict
+		if err = migrate(appContext, connConf, config); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dbConn, err := testutils.OpenTSConn(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbConn.Close()
+
+	var count int
+	if err = dbConn.QueryRow("SELECT count(*) FROM " + measure).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 2 {
+		t.Errorf("expected 2 rows after migrating twice, got %d", count)
+	}
+}
+
+func TestMigrateWithIngestionWorkers(t *testing.T) {
+	db := "test_ingestion_workers"
+	measure := "test"
+	numPoints := 10
+	tags := make([]*map[string]string, numPoints)
+	fieldValues := make([]*map[string]interface{}, numPoints)
+	for i := 0; i < numPoints; i++ {
+		tags[i] = &map[string]string{"tag1": fmt.Sprintf("%d", i)}
+		fieldValues[i] = &map[string]interface{}{"field1": i}
+	}
+
+	if err := testutils.PrepareServersForITest(db); err != nil {
+		t.Fatalf("could not prepare servers: %v", err)
+	}
+	defer testutils.ClearServersAfterITest(db)
+
+	if err := testutils.CreateInfluxMeasure(db, measure, tags, fieldValues); err != nil {
+		t.Fatal(err)
+	}
+
+	// each of the single row batches is inserted by one of the workers
+	connConf, config := defaultConfig(db, measure)
+	config.IngestionWorkers = 3
+	if err := migrate(initAppContext(), connConf, config); err != nil {
+		t.Fatal(err)
+	}
+
+	dbConn, err := testutils.OpenTSConn(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbConn.Close()
+
+	var count int
+	if err = dbConn.QueryRow("SELECT count(*) FROM " + measure).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+
+	if count != numPoints {
+		t.Errorf("expected %d rows inserted by the workers, got %d", numPoints, count)
+	}
+}
+
 func defaultConfig(db string, measure string) (*cli.ConnectionConfig, *cli.MigrationConfig) {
 	connConfig := &cli.ConnectionConfig{
 		InputHost:          testutils.InfluxHost,
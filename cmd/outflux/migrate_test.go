@@ -2,6 +2,11 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
 	"sync"
 	"testing"
 
@@ -10,6 +15,8 @@ import (
 
 	"github.com/timescale/outflux/internal/cli"
 	"github.com/timescale/outflux/internal/connections"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/ingestion/config"
 )
 
 func TestPreparePipeErrors(t *testing.T) {
@@ -40,13 +47,45 @@ func TestMigrateErrorOnDiscoverMeasures(t *testing.T) {
 	}
 
 	conn := &cli.ConnectionConfig{}
-	mig := &cli.MigrationConfig{Quiet: true}
+	mig := &cli.MigrationConfig{RetentionPolicy: "autogen", Quiet: true}
 	err := migrate(app, conn, mig)
 	if err == nil {
 		t.Error("expected error, none received")
 	}
 }
 
+func TestResolveMeasures(t *testing.T) {
+	schemaManager := &tdmsm{m: []string{"cpu", "cpu_load", "mem"}}
+	app := &appContext{
+		ics:                  &mockService{inflConn: &mockInfConn{}},
+		schemaManagerService: &mockService{inflSchemMngr: schemaManager},
+	}
+	conn := &cli.ConnectionConfig{
+		MeasureSelection: cli.MeasureSelection{Include: []*regexp.Regexp{regexp.MustCompile("^cpu")}},
+	}
+	measures, err := resolveMeasures(app, conn, &cli.MigrationConfig{}, nil)
+	if err != nil || !schemaManager.discoverCalled {
+		t.Fatalf("expected measures to be discovered, got error: %v", err)
+	}
+
+	if !reflect.DeepEqual(measures, []string{"cpu", "cpu_load"}) {
+		t.Errorf("unexpected selected measures: %v", measures)
+	}
+
+	// measures given as arguments are not discovered
+	schemaManager.discoverCalled = false
+	conn.InputMeasures = []string{"cpu", "disk"}
+	measures, err = resolveMeasures(app, conn, &cli.MigrationConfig{}, nil)
+	if err != nil || schemaManager.discoverCalled || !reflect.DeepEqual(measures, []string{"cpu"}) {
+		t.Errorf("unexpected result: %v, %v", measures, err)
+	}
+
+	conn.MeasureSelection.Exclude = []*regexp.Regexp{regexp.MustCompile("^swap")}
+	if _, err = resolveMeasures(app, conn, &cli.MigrationConfig{}, nil); err == nil {
+		t.Error("expected error for a pattern that matches nothing, none received")
+	}
+}
+
 func TestOpenConnectionsReturnsError(t *testing.T) {
 	app := &appContext{
 		ics: &mockService{inflConnErr: fmt.Errorf("error")},
@@ -55,7 +94,7 @@ func TestOpenConnectionsReturnsError(t *testing.T) {
 	conn := &cli.ConnectionConfig{
 		InputMeasures: []string{"a"},
 	}
-	mig := &cli.MigrationConfig{MaxParallel: 1}
+	mig := &cli.MigrationConfig{RetentionPolicy: "autogen", MaxParallel: 1}
 	err := migrate(app, conn, mig)
 	if err == nil {
 		t.Error("expected error, none received")
@@ -72,7 +111,7 @@ func TestMigrateCreatePipeReturnsError(t *testing.T) {
 	conn := &cli.ConnectionConfig{
 		InputMeasures: []string{"a"},
 	}
-	mig := &cli.MigrationConfig{MaxParallel: 1}
+	mig := &cli.MigrationConfig{RetentionPolicy: "autogen", MaxParallel: 1}
 	err := migrate(app, conn, mig)
 	if err == nil {
 		t.Error("expected error, none received")
@@ -88,7 +127,7 @@ func TestMigratePipeReturnsError(t *testing.T) {
 		},
 	}
 	conn := &cli.ConnectionConfig{InputMeasures: []string{"a"}}
-	mig := &cli.MigrationConfig{MaxParallel: 1}
+	mig := &cli.MigrationConfig{RetentionPolicy: "autogen", MaxParallel: 1}
 	err := migrate(app, conn, mig)
 	if err == nil {
 		t.Errorf("expected error, none received")
@@ -107,7 +146,7 @@ func TestMigratePipesWaitForSemaphore(t *testing.T) {
 		tscs: &mockTsConnSer{tsConn: &pgx.Conn{}},
 	}
 	conn := &cli.ConnectionConfig{InputMeasures: []string{"a", "b", "c"}}
-	mig := &cli.MigrationConfig{MaxParallel: 2}
+	mig := &cli.MigrationConfig{RetentionPolicy: "autogen", MaxParallel: 2}
 	err := migrate(app, conn, mig)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
@@ -167,3 +206,232 @@ type multiConnMock struct {
 func (m *multiConnMock) NewConnection(p *connections.InfluxConnectionParams) (influx.Client, error) {
 	return &mockInfConn{}, nil
 }
+
+func TestOpenV2Connections(t *testing.T) {
+	// error on new influx v2 conn
+	app := &appContext{icsV2: &mockInfV2ConnSer{connErr: fmt.Errorf("some error")}}
+	_, _, err := openV2Connections(app, &cli.ConnectionConfig{InputAPI: cli.InputAPIV2})
+	if err == nil {
+		t.Errorf("expected error, none received")
+	}
+
+	// error on open ts conn
+	v2Conn := &mockInfV2Conn{}
+	app = &appContext{
+		icsV2: &mockInfV2ConnSer{conn: v2Conn},
+		tscs:  &mockTsConnSer{tsConnErr: fmt.Errorf("error")},
+	}
+	_, _, err = openV2Connections(app, &cli.ConnectionConfig{InputAPI: cli.InputAPIV2})
+	if err == nil {
+		t.Error("expected error, none received")
+	} else if !v2Conn.closeCalled {
+		t.Error("close not called on influx v2 connection")
+	}
+}
+
+func TestOpenWorkerConnections(t *testing.T) {
+	app := &appContext{tscs: &mockTsConnSer{tsConn: &connections.MockPgxW{}}}
+	// a single worker inserts with the connection of the pipeline
+	workerConns, err := openWorkerConnections(app, &cli.ConnectionConfig{}, 1)
+	if err != nil || workerConns != nil {
+		t.Errorf("expected no connections and no error, got %v and %v", workerConns, err)
+	}
+
+	workerConns, err = openWorkerConnections(app, &cli.ConnectionConfig{}, 3)
+	if err != nil || len(workerConns) != 3 {
+		t.Errorf("expected 3 connections and no error, got %v and %v", workerConns, err)
+	}
+
+	app = &appContext{tscs: &mockTsConnSer{tsConnErr: fmt.Errorf("error")}}
+	if _, err = openWorkerConnections(app, &cli.ConnectionConfig{}, 2); err == nil {
+		t.Error("expected error, none received")
+	}
+}
+
+func TestMigrateWithInputAPIV2(t *testing.T) {
+	v2Conn := &mockInfV2Conn{}
+	pipe := &mockPipe{counter: &runCounter{lock: &sync.Mutex{}}}
+	mockAll := &mockService{pipe: pipe, inflSchemMngr: &tdmsm{m: []string{"a"}}}
+	app := &appContext{
+		icsV2:                &mockInfV2ConnSer{conn: v2Conn},
+		tscs:                 &mockTsConnSer{tsConn: &pgx.Conn{}},
+		pipeService:          mockAll,
+		schemaManagerService: mockAll,
+	}
+
+	conn := &cli.ConnectionConfig{InputAPI: cli.InputAPIV2, InputDb: "bucket"}
+	mig := &cli.MigrationConfig{RetentionPolicy: "autogen", MaxParallel: 1, Quiet: true}
+	err := migrate(app, conn, mig)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if pipe.counter.maxRunning != 1 {
+		t.Errorf("pipe didn't run")
+	}
+
+	if !v2Conn.closeCalled {
+		t.Errorf("close not called on influx v2 connection")
+	}
+}
+
+func TestMigrateFromInputFile(t *testing.T) {
+	pipe := &mockPipe{counter: &runCounter{lock: &sync.Mutex{}}}
+	mockAll := &mockService{pipe: pipe, inflSchemMngr: &tdmsm{m: []string{"a"}}}
+	app := &appContext{
+		tscs:                 &mockTsConnSer{tsConn: &pgx.Conn{}},
+		pipeService:          mockAll,
+		schemaManagerService: mockAll,
+	}
+
+	conn := &cli.ConnectionConfig{InputFile: "export.gz", InputDb: "db"}
+	mig := &cli.MigrationConfig{RetentionPolicy: "autogen", MaxParallel: 1, Quiet: true}
+	err := migrate(app, conn, mig)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if pipe.counter.maxRunning != 1 {
+		t.Errorf("pipe didn't run")
+	}
+
+	// error on open ts conn
+	app.tscs = &mockTsConnSer{tsConnErr: fmt.Errorf("error")}
+	if err = migrate(app, conn, mig); err == nil {
+		t.Error("expected error, none received")
+	}
+}
+
+func TestMigrateFromInputDataDir(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "outflux_data")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dataDir)
+	shardDir := filepath.Join(dataDir, "db", "autogen", "1")
+	os.MkdirAll(shardDir, 0700)
+	ioutil.WriteFile(filepath.Join(shardDir, "000000001-000000001.tsm"), []byte{}, 0600)
+
+	pipe := &mockPipe{counter: &runCounter{lock: &sync.Mutex{}}}
+	mockAll := &mockService{pipe: pipe, inflSchemMngr: &tdmsm{m: []string{"a"}}}
+	app := &appContext{
+		tscs:                 &mockTsConnSer{tsConn: &pgx.Conn{}},
+		pipeService:          mockAll,
+		schemaManagerService: mockAll,
+	}
+
+	conn := &cli.ConnectionConfig{InputDataDir: dataDir, InputDb: "db"}
+	mig := &cli.MigrationConfig{RetentionPolicy: "autogen", MaxParallel: 1, Quiet: true}
+	if err = migrate(app, conn, mig); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if pipe.counter.maxRunning != 1 {
+		t.Errorf("pipe didn't run")
+	}
+
+	// no shards in the data dir
+	conn.InputDataDir = shardDir
+	if err = migrate(app, conn, mig); err == nil {
+		t.Error("expected error, none received")
+	}
+}
+
+func TestMigrateToFiles(t *testing.T) {
+	infConn := &mockInfConn{}
+	pipe := &mockPipe{counter: &runCounter{lock: &sync.Mutex{}}}
+	app := &appContext{
+		ics: &mockService{inflConn: infConn},
+		// no connection to the output database is opened
+		tscs:        &mockTsConnSer{tsConnErr: fmt.Errorf("error")},
+		pipeService: &mockService{pipe: pipe},
+	}
+
+	conn := &cli.ConnectionConfig{InputMeasures: []string{"a"}}
+	mig := &cli.MigrationConfig{
+		RetentionPolicy: "autogen",
+		MaxParallel:     1,
+		Quiet:           true,
+		FileOutput:      &config.FileOutput{Directory: "out", Format: config.CSVFormat, Period: config.DayPeriod},
+	}
+	if err := migrate(app, conn, mig); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if pipe.counter.maxRunning != 1 {
+		t.Errorf("pipe didn't run")
+	}
+
+	if !infConn.closeCalled {
+		t.Errorf("close not called on influx connection")
+	}
+
+	// error on open influx conn
+	app.ics = &mockService{inflConnErr: fmt.Errorf("error")}
+	if err := migrate(app, conn, mig); err == nil {
+		t.Error("expected error, none received")
+	}
+}
+
+func TestMigrateToScript(t *testing.T) {
+	infConn := &mockInfConn{}
+	pipe := &mockPipe{counter: &runCounter{lock: &sync.Mutex{}}}
+	app := &appContext{
+		ics: &mockService{inflConn: infConn},
+		// no connection to the output database is opened
+		tscs:        &mockTsConnSer{tsConnErr: fmt.Errorf("error")},
+		pipeService: &mockService{pipe: pipe},
+	}
+
+	conn := &cli.ConnectionConfig{InputMeasures: []string{"a"}}
+	mig := &cli.MigrationConfig{
+		RetentionPolicy: "autogen",
+		MaxParallel:     1,
+		Quiet:           true,
+		ScriptOutput:    &config.ScriptOutput{Path: "out.sql"},
+	}
+	if err := migrate(app, conn, mig); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if pipe.counter.maxRunning != 1 {
+		t.Errorf("pipe didn't run")
+	}
+
+	if !infConn.closeCalled {
+		t.Errorf("close not called on influx connection")
+	}
+}
+
+func TestMigrateToInflux(t *testing.T) {
+	infConn := &mockInfConn{}
+	pipe := &mockPipe{counter: &runCounter{lock: &sync.Mutex{}}}
+	app := &appContext{
+		ics: &mockService{inflConn: infConn},
+		// no connection to the output database is opened
+		tscs:        &mockTsConnSer{tsConnErr: fmt.Errorf("error")},
+		pipeService: &mockService{pipe: pipe},
+		// the tags of the points are the tag columns of the measure
+		influxTagExplorer: &mockTagExplorer{tags: map[string][]*idrf.Column{"a": {{Name: "host"}}}},
+	}
+
+	conn := &cli.ConnectionConfig{InputMeasures: []string{"a"}}
+	mig := &cli.MigrationConfig{
+		RetentionPolicy: "autogen",
+		MaxParallel:     1,
+		Quiet:           true,
+		InfluxOutput:    &config.InfluxOutput{Server: "http://localhost:8086", Database: "copy"},
+	}
+	if err := migrate(app, conn, mig); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if pipe.counter.maxRunning != 1 {
+		t.Errorf("pipe didn't run")
+	}
+
+	if !infConn.closeCalled {
+		t.Errorf("close not called on influx connection")
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"io"
 	"sync"
 	"time"
 
@@ -9,6 +10,8 @@ import (
 	"github.com/timescale/outflux/internal/connections"
 	"github.com/timescale/outflux/internal/pipeline"
 	"github.com/timescale/outflux/internal/schemamanagement"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+	"github.com/timescale/outflux/internal/schemamanagement/tsm/tsmstorage"
 )
 
 type mockService struct {
@@ -19,7 +22,23 @@ type mockService struct {
 	inflSchemMngr schemamanagement.SchemaManager
 }
 
-func (m *mockService) Create(infConn influx.Client, tsConn connections.PgxWrap, measure, inputDb string, conf *cli.MigrationConfig) (pipeline.Pipe, error) {
+func (m *mockService) Create(infConn influx.Client, tsConn connections.PgxWrap, workerConns []connections.PgxWrap, measure, inputDb string, conf *cli.MigrationConfig) (pipeline.Pipe, error) {
+	return m.pipe, m.pipeErr
+}
+
+func (m *mockService) CreateV2(v2Conn connections.InfluxV2Client, tsConn connections.PgxWrap, workerConns []connections.PgxWrap, measure, bucket string, conf *cli.MigrationConfig) (pipeline.Pipe, error) {
+	return m.pipe, m.pipeErr
+}
+
+func (m *mockService) CreateFromFile(path string, tsConn connections.PgxWrap, workerConns []connections.PgxWrap, measure, inputDb string, conf *cli.MigrationConfig) (pipeline.Pipe, error) {
+	return m.pipe, m.pipeErr
+}
+
+func (m *mockService) CreateFromTSM(storage *tsmstorage.Storage, tsConn connections.PgxWrap, workerConns []connections.PgxWrap, measure, inputDb string, conf *cli.MigrationConfig) (pipeline.Pipe, error) {
+	return m.pipe, m.pipeErr
+}
+
+func (m *mockService) CreateFromTimescale(inputConn connections.PgxWrap, table, schema string, conf *cli.MigrationConfig) (pipeline.Pipe, error) {
 	return m.pipe, m.pipeErr
 }
 
@@ -31,7 +50,19 @@ func (m *mockService) Influx(c influx.Client, db, rp string, convertIntToFloat b
 	return m.inflSchemMngr
 }
 
-func (m *mockService) TimeScale(dbConn connections.PgxWrap, schema, chunkInterval string) schemamanagement.SchemaManager {
+func (m *mockService) InfluxV2(c connections.InfluxV2Client, bucket string, convertIntToFloat bool) schemamanagement.SchemaManager {
+	return m.inflSchemMngr
+}
+
+func (m *mockService) LineProtocol(path, db, rp string, convertIntToFloat bool) schemamanagement.SchemaManager {
+	return m.inflSchemMngr
+}
+
+func (m *mockService) TSM(storage *tsmstorage.Storage, db, rp string, convertIntToFloat bool) schemamanagement.SchemaManager {
+	return m.inflSchemMngr
+}
+
+func (m *mockService) TimeScale(dbConn connections.PgxWrap, schema, chunkInterval string, retentionPeriod time.Duration, compression *schemaconfig.Compression, spacePartitioning *schemaconfig.SpacePartitioning, indexes *schemaconfig.Indexes) schemamanagement.SchemaManager {
 	return nil
 }
 
@@ -82,3 +113,22 @@ func (m *mockInfConn) Close() error {
 	m.closeCalled = true
 	return nil
 }
+
+type mockInfV2ConnSer struct {
+	conn    connections.InfluxV2Client
+	connErr error
+}
+
+func (m *mockInfV2ConnSer) NewConnection(params *connections.InfluxV2ConnectionParams) (connections.InfluxV2Client, error) {
+	return m.conn, m.connErr
+}
+
+type mockInfV2Conn struct {
+	closeCalled bool
+}
+
+func (m *mockInfV2Conn) Query(flux string) (io.ReadCloser, error) { return nil, nil }
+func (m *mockInfV2Conn) Close() error {
+	m.closeCalled = true
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/timescale/outflux/internal/cli"
+	"github.com/timescale/outflux/internal/schemamanagement/influx/discovery"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+)
+
+const (
+	// inputs without retention policy metadata read this one if none was selected
+	fallbackRetentionPolicy = "autogen"
+	secondsIntervalTemplate = "%d seconds"
+	createSchemaTemplate    = `CREATE SCHEMA IF NOT EXISTS "%s"`
+)
+
+// retentionPoliciesToMigrate returns the retention policies the data is read from. The policies of the
+// input server are read for 'all', when none was selected to find the default one, and when they are
+// mapped to TimescaleDB retention policies. Files, TSM shards and tables are read from 'autogen' if none was selected
+func retentionPoliciesToMigrate(app *appContext, connArgs *cli.ConnectionConfig, args *cli.MigrationConfig) ([]*discovery.RetentionPolicy, error) {
+	selected := args.RetentionPolicy
+	if connArgs.InputFile != "" || connArgs.InputDataDir != "" || connArgs.InputConn != "" || connArgs.InputAPI == cli.InputAPIV2 {
+		if selected == "" {
+			selected = fallbackRetentionPolicy
+		}
+
+		return []*discovery.RetentionPolicy{{Name: selected}}, nil
+	}
+
+	if selected != "" && selected != cli.AllRetentionPolicies && !args.AddRetentionPolicy {
+		return []*discovery.RetentionPolicy{{Name: selected}}, nil
+	}
+
+	influxConn, err := app.ics.NewConnection(influxConnParams(connArgs))
+	if err != nil {
+		return nil, fmt.Errorf("could not open connection to Influx Server\n%v", err)
+	}
+
+	defer influxConn.Close()
+	policies, err := app.influxRPExplorer.FetchRetentionPolicies(influxConn, connArgs.InputDb)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch the retention policies of the input db '%s'\n%v", connArgs.InputDb, err)
+	}
+
+	if selected == cli.AllRetentionPolicies {
+		if len(policies) == 0 {
+			return nil, fmt.Errorf("the input db '%s' has no retention policies", connArgs.InputDb)
+		}
+
+		return policies, nil
+	}
+
+	for _, policy := range policies {
+		if policy.Name == selected || selected == "" && policy.Default {
+			return []*discovery.RetentionPolicy{policy}, nil
+		}
+	}
+
+	if selected == "" {
+		return nil, fmt.Errorf("the input db '%s' has no default retention policy", connArgs.InputDb)
+	}
+
+	return nil, fmt.Errorf("retention policy '%s' not found in the input db '%s'", selected, connArgs.InputDb)
+}
+
+// retentionPolicyArgs returns a copy of the migration config that reads from the retention policy.
+// With the RPToSchema mapping the output schema is named after it. When the retention policy is
+// mapped to a TimescaleDB retention policy, its duration is the retention period of the created
+// hypertables, and its shard group duration their chunk_time_interval if none was given.
+// Retention policies mapped to a column share the tables, so only the first one can drop them
+func retentionPolicyArgs(args *cli.MigrationConfig, policy *discovery.RetentionPolicy, first bool) *cli.MigrationConfig {
+	rpArgs := *args
+	rpArgs.RetentionPolicy = policy.Name
+	if args.RetentionPolicyMapping == schemaconfig.RPToSchema {
+		rpArgs.OutputSchema = policy.Name
+		rpArgs.CreateOutputSchema = true
+	}
+
+	if args.AddRetentionPolicy {
+		rpArgs.RetentionPeriod = policy.Duration
+		if rpArgs.ChunkTimeInterval == "" {
+			rpArgs.ChunkTimeInterval = fmt.Sprintf(secondsIntervalTemplate, int64(policy.ShardGroupDuration/time.Second))
+		}
+
+		log.Printf("Retention policy '%s': retention period %s, chunk_time_interval '%s'", policy.Name, policy.Duration, rpArgs.ChunkTimeInterval)
+	}
+
+	dropStrategy := args.OutputSchemaStrategy == schemaconfig.DropAndCreate || args.OutputSchemaStrategy == schemaconfig.DropCascadeAndCreate
+	if !first && dropStrategy && args.RetentionPolicyMapping == schemaconfig.RPToColumn {
+		rpArgs.OutputSchemaStrategy = schemaconfig.CreateIfMissing
+	}
+
+	return &rpArgs
+}
+
+// createOutputSchema creates the schema a retention policy or input database is mapped to, if it doesn't exist.
+// When writing to files the schema is a directory, created by the ingestors, a script creates the schema itself,
+// and InfluxDB has no schemas
+func createOutputSchema(app *appContext, connArgs *cli.ConnectionConfig, args *cli.MigrationConfig) error {
+	if !args.CreateOutputSchema || args.OutputSchemaStrategy == schemaconfig.ValidateOnly || args.FileOutput != nil || args.ScriptOutput != nil || args.InfluxOutput != nil {
+		return nil
+	}
+
+	tsConn, err := app.tscs.NewConnection(connArgs.OutputDbConnString)
+	if err != nil {
+		return fmt.Errorf("could not open connection to TimescaleDB Server\n%v", err)
+	}
+
+	defer tsConn.Close()
+	if _, err = tsConn.Exec(fmt.Sprintf(createSchemaTemplate, args.OutputSchema)); err != nil {
+		return fmt.Errorf("could not create schema '%s'\n%v", args.OutputSchema, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/jackc/pgx"
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/cli"
+	"github.com/timescale/outflux/internal/connections"
+	"github.com/timescale/outflux/internal/schemamanagement/influx/discovery"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+)
+
+func TestRetentionPoliciesToMigrate(t *testing.T) {
+	autogen := &discovery.RetentionPolicy{Name: "autogen", ShardGroupDuration: 7 * 24 * time.Hour}
+	oneMonth := &discovery.RetentionPolicy{Name: "one_month", Duration: 30 * 24 * time.Hour, ShardGroupDuration: 24 * time.Hour, Default: true}
+	testCases := []struct {
+		desc        string
+		connArgs    *cli.ConnectionConfig
+		args        *cli.MigrationConfig
+		explorer    *mockRPExplorer
+		expected    []*discovery.RetentionPolicy
+		expectFetch bool
+		expectErr   bool
+	}{
+		{
+			desc:     "file without selected policy reads autogen",
+			connArgs: &cli.ConnectionConfig{InputFile: "file"},
+			args:     &cli.MigrationConfig{},
+			explorer: &mockRPExplorer{},
+			expected: []*discovery.RetentionPolicy{{Name: "autogen"}},
+		}, {
+			desc:     "selected policy is not read",
+			connArgs: &cli.ConnectionConfig{},
+			args:     &cli.MigrationConfig{RetentionPolicy: "one_month"},
+			explorer: &mockRPExplorer{},
+			expected: []*discovery.RetentionPolicy{{Name: "one_month"}},
+		}, {
+			desc:        "selected policy is read to add a retention policy",
+			connArgs:    &cli.ConnectionConfig{},
+			args:        &cli.MigrationConfig{RetentionPolicy: "autogen", AddRetentionPolicy: true},
+			explorer:    &mockRPExplorer{policies: []*discovery.RetentionPolicy{autogen, oneMonth}},
+			expected:    []*discovery.RetentionPolicy{autogen},
+			expectFetch: true,
+		}, {
+			desc:        "default policy",
+			connArgs:    &cli.ConnectionConfig{},
+			args:        &cli.MigrationConfig{},
+			explorer:    &mockRPExplorer{policies: []*discovery.RetentionPolicy{autogen, oneMonth}},
+			expected:    []*discovery.RetentionPolicy{oneMonth},
+			expectFetch: true,
+		}, {
+			desc:        "all policies",
+			connArgs:    &cli.ConnectionConfig{},
+			args:        &cli.MigrationConfig{RetentionPolicy: cli.AllRetentionPolicies},
+			explorer:    &mockRPExplorer{policies: []*discovery.RetentionPolicy{autogen, oneMonth}},
+			expected:    []*discovery.RetentionPolicy{autogen, oneMonth},
+			expectFetch: true,
+		}, {
+			desc:        "no default policy",
+			connArgs:    &cli.ConnectionConfig{},
+			args:        &cli.MigrationConfig{},
+			explorer:    &mockRPExplorer{policies: []*discovery.RetentionPolicy{autogen}},
+			expectFetch: true,
+			expectErr:   true,
+		}, {
+			desc:        "missing policy",
+			connArgs:    &cli.ConnectionConfig{},
+			args:        &cli.MigrationConfig{RetentionPolicy: "missing", AddRetentionPolicy: true},
+			explorer:    &mockRPExplorer{policies: []*discovery.RetentionPolicy{autogen}},
+			expectFetch: true,
+			expectErr:   true,
+		}, {
+			desc:        "error fetching",
+			connArgs:    &cli.ConnectionConfig{},
+			args:        &cli.MigrationConfig{},
+			explorer:    &mockRPExplorer{err: fmt.Errorf("error")},
+			expectFetch: true,
+			expectErr:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		app := &appContext{ics: &mockService{inflConn: &mockInfConn{}}, influxRPExplorer: tc.explorer}
+		policies, err := retentionPoliciesToMigrate(app, tc.connArgs, tc.args)
+		assert.Equal(t, tc.expectFetch, tc.explorer.called, tc.desc)
+		if tc.expectErr {
+			assert.Error(t, err, tc.desc)
+			continue
+		}
+
+		assert.NoError(t, err, tc.desc)
+		assert.Equal(t, tc.expected, policies, tc.desc)
+	}
+}
+
+func TestRetentionPolicyArgs(t *testing.T) {
+	policy := &discovery.RetentionPolicy{Name: "one_month", Duration: 30 * 24 * time.Hour, ShardGroupDuration: 24 * time.Hour}
+	args := &cli.MigrationConfig{
+		OutputSchema:           "public",
+		OutputSchemaStrategy:   schemaconfig.DropAndCreate,
+		RetentionPolicyMapping: schemaconfig.RPToSchema,
+		AddRetentionPolicy:     true,
+	}
+	rpArgs := retentionPolicyArgs(args, policy, true)
+	assert.Equal(t, "one_month", rpArgs.RetentionPolicy)
+	assert.Equal(t, "one_month", rpArgs.OutputSchema)
+	assert.True(t, rpArgs.CreateOutputSchema)
+	assert.Equal(t, 30*24*time.Hour, rpArgs.RetentionPeriod)
+	assert.Equal(t, "86400 seconds", rpArgs.ChunkTimeInterval)
+	assert.Equal(t, schemaconfig.DropAndCreate, rpArgs.OutputSchemaStrategy)
+	// the original config is not changed
+	assert.Equal(t, "public", args.OutputSchema)
+	assert.Equal(t, "", args.ChunkTimeInterval)
+
+	// a given chunk interval is kept, tables shared by the policies are dropped only once
+	args = &cli.MigrationConfig{
+		OutputSchema:           "public",
+		OutputSchemaStrategy:   schemaconfig.DropAndCreate,
+		RetentionPolicyMapping: schemaconfig.RPToColumn,
+		AddRetentionPolicy:     true,
+		ChunkTimeInterval:      "1 day",
+	}
+	rpArgs = retentionPolicyArgs(args, policy, true)
+	assert.Equal(t, "public", rpArgs.OutputSchema)
+	assert.False(t, rpArgs.CreateOutputSchema)
+	assert.Equal(t, "1 day", rpArgs.ChunkTimeInterval)
+	assert.Equal(t, schemaconfig.DropAndCreate, rpArgs.OutputSchemaStrategy)
+	rpArgs = retentionPolicyArgs(args, policy, false)
+	assert.Equal(t, schemaconfig.CreateIfMissing, rpArgs.OutputSchemaStrategy)
+}
+
+func TestCreateOutputSchema(t *testing.T) {
+	connArgs := &cli.ConnectionConfig{}
+	args := &cli.MigrationConfig{RetentionPolicy: "rp", OutputSchema: "rp", CreateOutputSchema: true}
+	db := &connections.MockPgxW{ExecRes: []pgx.CommandTag{""}, ExecErrs: []error{nil}}
+	app := &appContext{tscs: &mockTsConnSer{tsConn: db}}
+	assert.NoError(t, createOutputSchema(app, connArgs, args))
+	assert.Equal(t, []string{`CREATE SCHEMA IF NOT EXISTS "rp"`}, db.ExpExec)
+
+	// nothing is created for a given schema or when only validating
+	app.tscs = &mockTsConnSer{tsConnErr: fmt.Errorf("error")}
+	args.OutputSchemaStrategy = schemaconfig.ValidateOnly
+	assert.NoError(t, createOutputSchema(app, connArgs, args))
+	args.OutputSchemaStrategy = schemaconfig.CreateIfMissing
+	args.CreateOutputSchema = false
+	assert.NoError(t, createOutputSchema(app, connArgs, args))
+	args.CreateOutputSchema = true
+	assert.Error(t, createOutputSchema(app, connArgs, args))
+}
+
+type mockRPExplorer struct {
+	policies []*discovery.RetentionPolicy
+	err      error
+	called   bool
+}
+
+func (m *mockRPExplorer) FetchRetentionPolicies(influxClient influx.Client, database string) ([]*discovery.RetentionPolicy, error) {
+	m.called = true
+	return m.policies, m.err
+}
@@ -35,4 +35,13 @@ func init() {
 
 	schemaTransferCmd := initSchemaTransferCmd()
 	RootCmd.AddCommand(schemaTransferCmd)
+
+	syncCmd := initSyncCmd()
+	RootCmd.AddCommand(syncCmd)
+
+	continuousQueriesCmd := initContinuousQueriesCmd()
+	RootCmd.AddCommand(continuousQueriesCmd)
+
+	translateCmd := initTranslateCmd()
+	RootCmd.AddCommand(translateCmd)
 }
@@ -8,18 +8,19 @@ import (
 
 	influx "github.com/influxdata/influxdb/client/v2"
 	"github.com/timescale/outflux/internal/cli"
-	"github.com/timescale/outflux/internal/connections"
 
 	"github.com/spf13/cobra"
 	"github.com/timescale/outflux/internal/cli/flagparsers"
+	"github.com/timescale/outflux/internal/schemamanagement/tsm/tsmstorage"
+	"github.com/timescale/outflux/internal/transformation/rpmapping"
 )
 
 func initSchemaTransferCmd() *cobra.Command {
 	schemaTransferCmd := &cobra.Command{
-		Use:   "schema-transfer database [measure1 measure2 ...]",
+		Use:   "schema-transfer database[,database2 ...] [measure1 measure2 ...]",
 		Short: "Discover the schema of measurements and validate or prepare a TimescaleDB hyper-table with the discovered schema",
 		Long:  "Discover the schema of measurements and validate or prepare a TimescaleDB hyper-table with the discovered schema",
-		Args:  cobra.MinimumNArgs(1),
+		Args:  cobra.ArbitraryArgs,
 		Run: func(cmd *cobra.Command, args []string) {
 			app := initAppContext()
 			connArgs, migArgs, err := flagparsers.FlagsToSchemaTransferConfig(cmd.Flags(), args)
@@ -36,7 +37,8 @@ func initSchemaTransferCmd() *cobra.Command {
 	}
 
 	flagparsers.AddConnectionFlagsToCmd(schemaTransferCmd)
-	schemaTransferCmd.PersistentFlags().String(flagparsers.RetentionPolicyFlag, flagparsers.DefaultRetentionPolicy, "The retention policy to select the fields and tags from")
+	schemaTransferCmd.PersistentFlags().String(flagparsers.RetentionPolicyFlag, flagparsers.DefaultRetentionPolicy, "The retention policy to select the fields and tags from, or '"+cli.AllRetentionPolicies+"' for every retention policy. If not specified, the default retention policy of the input database")
+	schemaTransferCmd.PersistentFlags().String(flagparsers.RetentionPolicyMappingFlag, flagparsers.DefaultRetentionPolicyMapping.String(), "How the data of a retention policy is kept apart from the other retention policies. Schema creates the tables in a schema named after the retention policy, Prefix prefixes the table names with it, Column adds a '"+rpmapping.ColumnName+"' column to tables shared by the retention policies. Valid options: None, Schema, Prefix, Column")
 	schemaTransferCmd.PersistentFlags().String(flagparsers.SchemaStrategyFlag, flagparsers.DefaultSchemaStrategy.String(), "Strategy to use for preparing the schema of the output database. Valid options: ValidateOnly, CreateIfMissing, DropAndCreate, DropCascadeAndCreate")
 	schemaTransferCmd.PersistentFlags().Bool(flagparsers.TagsAsJSONFlag, flagparsers.DefaultTagsAsJSON, "If this flag is set to true, then the Tags of the influx measures being exported will be combined into a single JSONb column in Timescale")
 	schemaTransferCmd.PersistentFlags().String(flagparsers.TagsColumnFlag, flagparsers.DefaultTagsColumn, "When "+flagparsers.TagsAsJSONFlag+" is set, this column specifies the name of the JSON column for the tags")
@@ -45,6 +47,19 @@ func initSchemaTransferCmd() *cobra.Command {
 	schemaTransferCmd.PersistentFlags().String(flagparsers.OutputSchemaFlag, flagparsers.DefaultOutputSchema, "The schema of the output database that the data will be inserted into")
 	schemaTransferCmd.PersistentFlags().Bool(flagparsers.MultishardIntFloatCast, flagparsers.DefaultMultishardIntFloatCast, "If a field is Int64 in one shard, and Float64 in another, with this flag it will be cast to Float64 despite possible data loss")
 	schemaTransferCmd.PersistentFlags().String(flagparsers.ChunkTimeIntervalFlag, flagparsers.DefaultChunkTimeInterval, "chunk_time_interval of the hypertables created by Outflux")
+	schemaTransferCmd.PersistentFlags().Bool(flagparsers.AutoChunkTimeIntervalFlag, flagparsers.DefaultAutoChunkTimeInterval, "If specified, the chunk_time_interval of each created hypertable is sized from the point rate and row width of its measure, so a chunk holds about '"+flagparsers.ChunkTargetSizeFlag+"'. Only with the "+cli.InputAPIV1+" input API of an input server")
+	schemaTransferCmd.PersistentFlags().Uint64(flagparsers.ChunkTargetSizeFlag, flagparsers.DefaultChunkTargetSize, "Size in MB of the chunks '"+flagparsers.AutoChunkTimeIntervalFlag+"' sizes the chunk_time_interval for")
+	schemaTransferCmd.PersistentFlags().Duration(flagparsers.DensitySampleWindowFlag, flagparsers.DefaultDensitySampleWindow, "Time window before the newest point of a measure in which '"+flagparsers.AutoChunkTimeIntervalFlag+"' counts its points")
+	schemaTransferCmd.PersistentFlags().StringToString(flagparsers.SpacePartitioningFlag, map[string]string{}, "Hash partitions the hypertables created for specific measures by a column, in addition to the time column. Existing hypertables must be partitioned the same way. Format: measure1=column1:partitions1,measure2=column2:partitions2")
+	schemaTransferCmd.PersistentFlags().Bool(flagparsers.AddRetentionPolicyFlag, flagparsers.DefaultAddRetentionPolicy, "If specified, the created hypertables get a retention policy with the duration of the InfluxDB retention policy, and its shard group duration is the default '"+flagparsers.ChunkTimeIntervalFlag+"'")
+	schemaTransferCmd.PersistentFlags().StringArray(flagparsers.DownsampleFlag, []string{}, "Extracts the aggregates of the fields grouped by time(interval) and all tags instead of the raw points. Format: interval[:older-than] with Go durations, e.g. 1h:720h aggregates the points older than 30 days to 1h. Repeat the flag for several resolutions, points newer than all older-than durations are extracted raw")
+	schemaTransferCmd.PersistentFlags().String(flagparsers.DownsampleAggregatesFlag, flagparsers.DefaultDownsampleAggregates, "Aggregate each field is wrapped in when downsampling, by field type. Valid aggregates: mean, max, min, last, sum, count. Strings and booleans only support last and count")
+	schemaTransferCmd.PersistentFlags().Bool(flagparsers.CompressFlag, flagparsers.DefaultCompress, "Enables TimescaleDB compression on the created hypertables")
+	schemaTransferCmd.PersistentFlags().String(flagparsers.CompressSegmentByFlag, flagparsers.DefaultCompressSegmentBy, "Comma separated columns the compressed hypertables are segmented by. If not set, they are segmented by their tag columns, set it to an empty string to not segment them")
+	schemaTransferCmd.PersistentFlags().String(flagparsers.CompressOrderByFlag, flagparsers.DefaultCompressOrderBy, "Order of the rows in the compressed chunks, e.g. 'time DESC'. If not set, TimescaleDB orders them by time descending")
+	schemaTransferCmd.PersistentFlags().Uint64(flagparsers.CompressMaxCardinalityFlag, flagparsers.DefaultCompressMaxCardinality, "If > 0, only the tags with at most this many values segment the compressed hypertables, as reported by SHOW TAG VALUES CARDINALITY. Requires the v1 API of an input server")
+	schemaTransferCmd.PersistentFlags().Duration(flagparsers.CompressAfterFlag, flagparsers.DefaultCompressAfter, "If > 0, a compression policy compressing chunks older than this is added to the created hypertables")
+	schemaTransferCmd.PersistentFlags().String(flagparsers.TimeFormatFlag, flagparsers.DefaultTimeFormat.String(), "Representation of the time column in the output database. Timestamptz has microsecond precision, TimestamptzWithNanos adds a column with the truncated nanoseconds and EpochNanos stores the nanoseconds since the Unix epoch in a BIGINT column. Valid options: Timestamptz, TimestamptzWithNanos, EpochNanos")
 	return schemaTransferCmd
 }
 
@@ -55,40 +70,69 @@ func transferSchema(app *appContext, connArgs *cli.ConnectionConfig, args *cli.M
 	}
 
 	startTime := time.Now()
-	influxDb := connArgs.InputDb
-	log.Printf("Selected input database: %s\n", influxDb)
-	var err error
-
-	// connect to input and output database
-	infConn, pgConn, err := openConnections(app, connArgs)
+	databases, err := databasesToMigrate(app, connArgs)
 	if err != nil {
-		return fmt.Errorf("could not open connections to input and output database\n%v", err)
+		return err
 	}
-	defer infConn.Close()
-	defer pgConn.Close()
 
-	// transfer the schema for all measures
-	if len(connArgs.InputMeasures) == 0 {
-		log.Printf("No measurements explicitly specified. Discovering automatically")
-		connArgs.InputMeasures, err = discoverMeasures(app, infConn, connArgs.InputDb, args.RetentionPolicy, args.OnConflictConvertIntToFloat)
+	for _, database := range databases {
+		log.Printf("Selected input database: %s\n", database)
+		dbConnArgs, dbArgs := databaseArgs(connArgs, args, database)
+		policies, err := retentionPoliciesToMigrate(app, dbConnArgs, dbArgs)
 		if err != nil {
-			return fmt.Errorf("could not discover the available measures for the input db '%s'", connArgs.InputDb)
+			return err
 		}
-		if len(connArgs.InputMeasures) == 0 {
-			log.Printf("No candidate measurements discovered. Exiting")
-			return nil
+
+		for i, policy := range policies {
+			if err = transferRetentionPolicySchema(app, dbConnArgs, retentionPolicyArgs(dbArgs, policy, i == 0)); err != nil {
+				return err
+			}
 		}
 	}
 
-	for _, measure := range connArgs.InputMeasures {
-		err := transfer(app, connArgs.InputDb, args, infConn, pgConn, measure)
+	executionTime := time.Since(startTime).Seconds()
+	log.Printf("Schema Transfer complete in: %.3f seconds\n", executionTime)
+	return nil
+}
+
+// transferRetentionPolicySchema transfers the schema of the measures of a single retention policy
+func transferRetentionPolicySchema(app *appContext, connArgs *cli.ConnectionConfig, args *cli.MigrationConfig) error {
+	storage, err := openInputStorage(connArgs)
+	if err != nil {
+		return err
+	}
+
+	defer storage.Close()
+	// transfer the schema for all measures
+	measures, err := resolveMeasures(app, connArgs, args, storage)
+	if err != nil {
+		return err
+	}
+
+	if err = resolveTagColumns(app, connArgs, args, storage, measures); err != nil {
+		return err
+	}
+
+	if err = resolveChunkTimeIntervals(app, connArgs, args, measures); err != nil {
+		return err
+	}
+
+	if len(measures) == 0 {
+		log.Printf("No candidate measurements discovered in retention policy '%s'", args.RetentionPolicy)
+		return nil
+	}
+
+	if err = createOutputSchema(app, connArgs, args); err != nil {
+		return err
+	}
+
+	for _, measure := range measures {
+		err := transfer(app, connArgs, args, storage, measure)
 		if err != nil {
 			return fmt.Errorf("could not transfer schema for measurement '%s'\n%v", measure, err)
 		}
 	}
 
-	executionTime := time.Since(startTime).Seconds()
-	log.Printf("Schema Transfer complete in: %.3f seconds\n", executionTime)
 	return nil
 }
 
@@ -97,19 +141,13 @@ func discoverMeasures(app *appContext, influxConn influx.Client, db, rp string,
 	return schemaManager.DiscoverDataSets()
 }
 
-func transfer(
-	app *appContext,
-	inputDb string,
-	args *cli.MigrationConfig,
-	infConn influx.Client,
-	pgConn connections.PgxWrap,
-	measure string) error {
-
-	pipe, err := app.pipeService.Create(infConn, pgConn, measure, inputDb, args)
+func transfer(app *appContext, connArgs *cli.ConnectionConfig, args *cli.MigrationConfig, storage *tsmstorage.Storage, measure string) error {
+	pipe, closeConnections, err := createPipe(app, connArgs, args, storage, measure)
 	if err != nil {
-		return fmt.Errorf("could not create execution pipeline for measure '%s'\n%v", measure, err)
+		return err
 	}
 
+	defer closeConnections()
 	log.Printf("%s starting execution\n", pipe.ID())
 	return pipe.Run()
 }
@@ -35,7 +35,7 @@ func TestTransferSchemaErrorOnDiscoverMeasures(t *testing.T) {
 	mockAll := &mockService{inflConnErr: fmt.Errorf("error")}
 	app := &appContext{ics: mockAll}
 	connArgs := &cli.ConnectionConfig{}
-	stArgs := &cli.MigrationConfig{}
+	stArgs := &cli.MigrationConfig{RetentionPolicy: "autogen"}
 	err := transferSchema(app, connArgs, stArgs)
 	if err == nil {
 		t.Errorf("expected err, none got")
@@ -54,7 +54,7 @@ func TestSchemaTransferErrorOnOpenConn(t *testing.T) {
 	mockTsConn := &mockTsConnSer{tsConnErr: fmt.Errorf("error")}
 	app := &appContext{ics: mockAll, tscs: mockTsConn, pipeService: mockAll, schemaManagerService: mockAll}
 	connArgs := &cli.ConnectionConfig{}
-	stArgs := &cli.MigrationConfig{Quiet: true}
+	stArgs := &cli.MigrationConfig{RetentionPolicy: "autogen", Quiet: true}
 	err := transferSchema(app, connArgs, stArgs)
 	if err == nil {
 		t.Errorf("expected err, none got")
@@ -73,7 +73,7 @@ func TestTransferSchemaErrorOnRun(t *testing.T) {
 	mockTsConn := &mockTsConnSer{tsConn: &pgx.Conn{}}
 	app := &appContext{ics: mockAll, tscs: mockTsConn, pipeService: mockAll, schemaManagerService: mockAll}
 	connArgs := &cli.ConnectionConfig{}
-	stArgs := &cli.MigrationConfig{Quiet: true}
+	stArgs := &cli.MigrationConfig{RetentionPolicy: "autogen", Quiet: true}
 	err := transferSchema(app, connArgs, stArgs)
 	if err == nil {
 		t.Errorf("expected err, none got")
@@ -95,7 +95,7 @@ func TestErrorOnPipeCreate(t *testing.T) {
 	mockTsConn := &mockTsConnSer{tsConn: &pgx.Conn{}}
 	app := &appContext{ics: mockAll, tscs: mockTsConn, pipeService: mockAll, schemaManagerService: mockAll}
 	connArgs := &cli.ConnectionConfig{}
-	stArgs := &cli.MigrationConfig{Quiet: true}
+	stArgs := &cli.MigrationConfig{RetentionPolicy: "autogen", Quiet: true}
 	err := transferSchema(app, connArgs, stArgs)
 	if err == nil {
 		t.Errorf("expected err, none got")
@@ -109,7 +109,7 @@ func TestTransferSchema(t *testing.T) {
 	}
 	app := &appContext{ics: mockAll, tscs: &mockTsConnSer{tsConn: &pgx.Conn{}}, pipeService: mockAll, schemaManagerService: mockAll}
 	connArgs := &cli.ConnectionConfig{InputMeasures: []string{"a"}}
-	stArgs := &cli.MigrationConfig{}
+	stArgs := &cli.MigrationConfig{RetentionPolicy: "autogen"}
 	err := transferSchema(app, connArgs, stArgs)
 	if err != nil {
 		t.Errorf("unexpected error:%v", err)
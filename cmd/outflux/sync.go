@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/timescale/outflux/internal/checkpoint"
+	"github.com/timescale/outflux/internal/cli"
+	"github.com/timescale/outflux/internal/cli/flagparsers"
+)
+
+func initSyncCmd() *cobra.Command {
+	syncCmd := &cobra.Command{
+		Use:   "sync database [measure1 measure2 ...]",
+		Short: "Continuously replicate the data from InfluxDB measurements into TimescaleDB hypertables",
+		Long: "Continuously replicate the data from InfluxDB measurements into TimescaleDB. Each sync cycle migrates" +
+			" the points newer than the checkpoint recorded by the previous cycle, re-reading an overlap window to catch" +
+			" late arriving points. Interrupting the command drains the remaining points and stops",
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			app := initAppContext()
+			connArgs, migrateArgs, syncArgs, err := flagparsers.FlagsToSyncConfig(cmd.Flags(), args)
+			if err != nil {
+				log.Fatal(err)
+				return
+			}
+
+			stop := make(chan os.Signal, 1)
+			signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+			err = syncData(app, connArgs, migrateArgs, syncArgs, stop)
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	addMigrateFlagsToCmd(syncCmd)
+	syncCmd.PersistentFlags().Duration(flagparsers.SyncIntervalFlag, flagparsers.DefaultSyncInterval, "Time between the start of two sync cycles")
+	syncCmd.PersistentFlags().Duration(flagparsers.SyncOverlapFlag, flagparsers.DefaultSyncOverlap, "Each cycle re-reads this window before the last replicated point to catch late arriving points")
+	syncCmd.PersistentFlags().Bool(flagparsers.SyncDrainFlag, flagparsers.DefaultSyncDrain, "If specified, sync cycles run back to back until no new points are replicated and then the command stops")
+	return syncCmd
+}
+
+// syncData runs migration cycles that resume from the recorded checkpoints until
+// drained. Draining starts when requested in the config, or when a value is received on
+// the stop channel. A second stop value while draining aborts the sync after the current cycle.
+func syncData(app *appContext, connArgs *cli.ConnectionConfig, args *cli.MigrationConfig, syncArgs *cli.SyncConfig, stop <-chan os.Signal) error {
+	// the checkpoints are recorded for the retention policy and in its output database and schema, so they are resolved once
+	connArgs, args = databaseArgs(connArgs, args, connArgs.InputDb)
+	policies, err := retentionPoliciesToMigrate(app, connArgs, args)
+	if err != nil {
+		return err
+	}
+
+	args = retentionPolicyArgs(args, policies[0], true)
+	requestedMeasures := connArgs.InputMeasures
+	draining := syncArgs.Drain
+	for cycle := 1; ; cycle++ {
+		cycleStart := time.Now()
+		// if no measures were requested, rediscover them each cycle to pick up new measurements
+		connArgs.InputMeasures = requestedMeasures
+		if connArgs.InputMeasures, err = resolveMeasures(app, connArgs, args, nil); err != nil {
+			return err
+		}
+
+		before, err := loadCheckpoints(app, connArgs, args)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("Starting sync cycle %d", cycle)
+		if err = migrate(app, connArgs, args); err != nil {
+			return fmt.Errorf("sync cycle %d failed\n%v", cycle, err)
+		}
+
+		after, err := loadCheckpoints(app, connArgs, args)
+		if err != nil {
+			return err
+		}
+
+		reportLag(connArgs.InputMeasures, after)
+		if draining && !checkpointsAdvanced(before, after) {
+			log.Printf("No new points replicated in sync cycle %d. Sync drained", cycle)
+			return nil
+		}
+
+		if draining {
+			select {
+			case <-stop:
+				return fmt.Errorf("sync aborted while draining after cycle %d", cycle)
+			default:
+				continue
+			}
+		}
+
+		select {
+		case <-stop:
+			log.Printf("Stop requested. Draining the remaining points")
+			draining = true
+		case <-time.After(syncArgs.Interval - time.Since(cycleStart)):
+		}
+	}
+}
+
+func loadCheckpoints(app *appContext, connArgs *cli.ConnectionConfig, args *cli.MigrationConfig) (map[string]time.Time, error) {
+	tsConn, err := app.tscs.NewConnection(connArgs.OutputDbConnString)
+	if err != nil {
+		return nil, fmt.Errorf("could not open connection to TimescaleDB Server\n%v", err)
+	}
+	defer tsConn.Close()
+
+	store := checkpoint.NewStore(tsConn, args.OutputSchema)
+	if err = store.Init(); err != nil {
+		return nil, err
+	}
+
+	checkpoints := make(map[string]time.Time)
+	for _, measure := range connArgs.InputMeasures {
+		key := checkpoint.Key{Database: connArgs.InputDb, RetentionPolicy: args.RetentionPolicy, Measure: measure}
+		lastTime, found, err := store.Load(key)
+		if err != nil {
+			return nil, err
+		}
+
+		if found {
+			checkpoints[measure] = lastTime
+		}
+	}
+
+	return checkpoints, nil
+}
+
+func checkpointsAdvanced(before, after map[string]time.Time) bool {
+	for measure, lastTime := range after {
+		previous, existed := before[measure]
+		if !existed || lastTime.After(previous) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func reportLag(measures []string, checkpoints map[string]time.Time) {
+	now := time.Now()
+	for _, measure := range measures {
+		lastTime, ok := checkpoints[measure]
+		if !ok {
+			log.Printf("Measure '%s': no points replicated yet", measure)
+			continue
+		}
+
+		lag := now.Sub(lastTime).Round(time.Millisecond)
+		log.Printf("Measure '%s': replicated up to %s, replication lag %s", measure, lastTime.Format(time.RFC3339Nano), lag)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timescale/outflux/internal/cli"
+)
+
+func TestCheckpointsAdvanced(t *testing.T) {
+	early := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	late := early.Add(time.Second)
+	testCases := []struct {
+		desc   string
+		before map[string]time.Time
+		after  map[string]time.Time
+		exp    bool
+	}{
+		{desc: "nothing replicated", before: map[string]time.Time{}, after: map[string]time.Time{}},
+		{desc: "first checkpoint", before: map[string]time.Time{}, after: map[string]time.Time{"a": early}, exp: true},
+		{desc: "unchanged", before: map[string]time.Time{"a": early}, after: map[string]time.Time{"a": early}},
+		{desc: "one moved", before: map[string]time.Time{"a": early, "b": early}, after: map[string]time.Time{"a": early, "b": late}, exp: true},
+	}
+
+	for _, tc := range testCases {
+		if res := checkpointsAdvanced(tc.before, tc.after); res != tc.exp {
+			t.Errorf("%s: expected %v, got %v", tc.desc, tc.exp, res)
+		}
+	}
+}
+
+func TestSyncErrorOnLoadCheckpoints(t *testing.T) {
+	app := &appContext{
+		tscs: &mockTsConnSer{tsConnErr: fmt.Errorf("error")},
+	}
+	conn := &cli.ConnectionConfig{InputMeasures: []string{"a"}}
+	mig := &cli.MigrationConfig{RetentionPolicy: "autogen", Quiet: true, MaxParallel: 1}
+	err := syncData(app, conn, mig, &cli.SyncConfig{Interval: time.Second}, make(chan os.Signal))
+	if err == nil {
+		t.Error("expected error, none received")
+	}
+}
+
+func TestSyncDiscoversMeasuresBeforeLoadingCheckpoints(t *testing.T) {
+	app := &appContext{
+		ics:  &mockService{inflConnErr: fmt.Errorf("error")},
+		tscs: &mockTsConnSer{tsConnErr: fmt.Errorf("error")},
+	}
+	conn := &cli.ConnectionConfig{InputDb: "db"}
+	mig := &cli.MigrationConfig{Quiet: true, MaxParallel: 1}
+	err := syncData(app, conn, mig, &cli.SyncConfig{Interval: time.Second}, make(chan os.Signal))
+	// the measures are discovered in the input database before the checkpoints are loaded
+	if err == nil || !strings.Contains(err.Error(), "could not open connection to Influx Server") {
+		t.Errorf("expected the measures to be discovered before the checkpoints are loaded, got: %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/timescale/outflux/internal/cli"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+	"github.com/timescale/outflux/internal/schemamanagement/tsm/tsmstorage"
+	"github.com/timescale/outflux/internal/transformation/rpmapping"
+)
+
+// tagDiscoverer returns the names of the tag columns of a measure, and the tags with a cardinality up to a limit
+type tagDiscoverer func(measure string) (tags []string, lowCardinalityTags []string, err error)
+
+// resolveTagColumns sets the tag columns of each measure that are indexed once its data is loaded, that identify
+// its upserted rows or are the tags of its points written to InfluxDB, and the columns its compressed hypertable
+// is segmented by, when they are the tags. With a maximum cardinality, only the tags with at most that many values
+// segment the hypertable, ordered from the lowest to the highest cardinality
+func resolveTagColumns(app *appContext, connArgs *cli.ConnectionConfig, args *cli.MigrationConfig, storage *tsmstorage.Storage, measures []string) error {
+	segmentByTags := args.Compression != nil && args.CompressSegmentByTags
+	indexTags := args.CreateIndexes && args.IndexColumns == nil
+	// the tags of the points written to InfluxDB are rebuilt from the tag columns
+	keyTags := args.OnConflict.Upserts() || args.InfluxOutput != nil
+	if !segmentByTags && !indexTags && !keyTags {
+		return nil
+	}
+
+	if segmentByTags {
+		args.MeasureSegmentBy = make(map[string][]string, len(measures))
+	}
+
+	if indexTags {
+		args.MeasureIndexedTags = make(map[string][]string, len(measures))
+	}
+
+	if keyTags {
+		args.MeasureKeyTags = make(map[string][]string, len(measures))
+	}
+
+	if args.TagsAsJSON {
+		log.Printf("The tags are combined in the '%s' column, their columns are not indexed and don't segment compressed hypertables", args.TagsCol)
+		return nil
+	}
+
+	maxCardinality := uint64(0)
+	if segmentByTags {
+		maxCardinality = args.CompressMaxTagCardinality
+	}
+
+	discoverTags, closeConn, err := newTagDiscoverer(app, connArgs, args, storage, maxCardinality)
+	if err != nil {
+		return err
+	}
+
+	defer closeConn()
+	for _, measure := range measures {
+		tags, lowCardinalityTags, err := discoverTags(measure)
+		if err != nil {
+			return fmt.Errorf("could not discover the tags of measure '%s'\n%v", measure, err)
+		}
+
+		if indexTags {
+			args.MeasureIndexedTags[measure] = tags
+			log.Printf("Measure '%s' will be indexed by: [%s]", measure, strings.Join(tags, ", "))
+		}
+
+		if keyTags {
+			args.MeasureKeyTags[measure] = tags
+		}
+
+		if !segmentByTags {
+			continue
+		}
+
+		segmentBy := []string{}
+		if args.RetentionPolicyMapping == schemaconfig.RPToColumn {
+			segmentBy = append(segmentBy, rpmapping.ColumnName)
+		}
+
+		segmentBy = append(segmentBy, lowCardinalityTags...)
+		args.MeasureSegmentBy[measure] = segmentBy
+		log.Printf("Measure '%s' will be compressed segmented by: [%s]", measure, strings.Join(segmentBy, ", "))
+	}
+
+	return nil
+}
+
+// newTagDiscoverer returns a tag discoverer for the input, and a function that closes the connection it uses.
+// The cardinality of the tags is only computed if the maximum is > 0
+func newTagDiscoverer(app *appContext, connArgs *cli.ConnectionConfig, args *cli.MigrationConfig, storage *tsmstorage.Storage, maxCardinality uint64) (tagDiscoverer, func(), error) {
+	db, rp := connArgs.InputDb, args.RetentionPolicy
+	if connArgs.InputConn != "" {
+		return newTableTagDiscoverer(app, connArgs)
+	}
+
+	if storage != nil {
+		return func(measure string) ([]string, []string, error) {
+			return allTags(app.tsmExplorer.DiscoverMeasurementTags(storage, db, rp, measure))
+		}, func() {}, nil
+	}
+
+	if connArgs.InputFile != "" {
+		return func(measure string) ([]string, []string, error) {
+			return allTags(app.lineProtocolExplorer.DiscoverMeasurementTags(connArgs.InputFile, db, rp, measure))
+		}, func() {}, nil
+	}
+
+	if connArgs.InputAPI == cli.InputAPIV2 {
+		v2Conn, err := app.icsV2.NewConnection(influxV2ConnParams(connArgs))
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not open connection to the v2 API of the Influx Server\n%v", err)
+		}
+
+		return func(measure string) ([]string, []string, error) {
+			return allTags(app.influxV2Explorer.DiscoverMeasurementTags(v2Conn, db, measure))
+		}, func() { v2Conn.Close() }, nil
+	}
+
+	influxConn, err := app.ics.NewConnection(influxConnParams(connArgs))
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not open connection to Influx Server\n%v", err)
+	}
+
+	closeConn := func() { influxConn.Close() }
+	return func(measure string) ([]string, []string, error) {
+		tags, _, err := allTags(app.influxTagExplorer.DiscoverMeasurementTags(influxConn, db, rp, measure))
+		if err != nil || maxCardinality == 0 {
+			return tags, tags, err
+		}
+
+		ranked, err := app.influxCardinalityExplorer.RankTagsByCardinality(influxConn, db, rp, measure, tags)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		kept := []string{}
+		for _, tag := range ranked {
+			if tag.Cardinality > maxCardinality {
+				log.Printf("Tag '%s' of measure '%s' has %d values, it doesn't segment the compressed hypertable", tag.Tag, measure, tag.Cardinality)
+				continue
+			}
+
+			kept = append(kept, tag.Tag)
+		}
+
+		return tags, kept, nil
+	}, closeConn, nil
+}
+
+// newTableTagDiscoverer returns a tag discoverer for the tables of the input database, the tags are the
+// columns given in the connection config or else the text columns of each table
+func newTableTagDiscoverer(app *appContext, connArgs *cli.ConnectionConfig) (tagDiscoverer, func(), error) {
+	if connArgs.InputTagColumns != nil {
+		return func(measure string) ([]string, []string, error) {
+			return connArgs.InputTagColumns, connArgs.InputTagColumns, nil
+		}, func() {}, nil
+	}
+
+	pgConn, err := app.tscs.NewConnection(connArgs.InputConn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not open connection to the input database\n%v", err)
+	}
+
+	schemaManager := app.schemaManagerService.TimeScale(pgConn, connArgs.InputDb, "", 0, nil, nil, nil)
+	return func(measure string) ([]string, []string, error) {
+		dataSet, err := schemaManager.FetchDataSet(measure)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		textColumns := []*idrf.Column{}
+		for _, column := range dataSet.Columns {
+			if column.DataType == idrf.IDRFString {
+				textColumns = append(textColumns, column)
+			}
+		}
+
+		return allTags(textColumns, nil)
+	}, func() { pgConn.Close() }, nil
+}
+
+// allTags returns the names of the tag columns, all of them are kept regardless of their cardinality
+func allTags(columns []*idrf.Column, err error) ([]string, []string, error) {
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = column.Name
+	}
+
+	return names, names, nil
+}
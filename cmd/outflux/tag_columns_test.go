@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/cli"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/ingestion/config"
+	"github.com/timescale/outflux/internal/schemamanagement/influx/discovery"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+)
+
+func TestResolveTagColumns(t *testing.T) {
+	tags := map[string][]*idrf.Column{
+		"cpu": {{Name: "host"}, {Name: "region"}},
+		"mem": {},
+	}
+	cardinalities := map[string][]*discovery.TagCardinality{
+		"cpu": {{Tag: "region", Cardinality: 3}, {Tag: "host", Cardinality: 5000}},
+	}
+	testCases := []struct {
+		desc            string
+		args            *cli.MigrationConfig
+		tagErr          error
+		expected        map[string][]string
+		expectedIndexed map[string][]string
+		expectedKeyTags map[string][]string
+		expectErr       bool
+	}{
+		{
+			desc: "no compression",
+			args: &cli.MigrationConfig{},
+		}, {
+			desc: "given segmentby columns",
+			args: &cli.MigrationConfig{Compression: &schemaconfig.Compression{SegmentBy: []string{"host"}}},
+		}, {
+			desc:     "tags",
+			args:     &cli.MigrationConfig{Compression: &schemaconfig.Compression{}, CompressSegmentByTags: true},
+			expected: map[string][]string{"cpu": {"host", "region"}, "mem": {}},
+		}, {
+			desc:     "tags with the lowest cardinality",
+			args:     &cli.MigrationConfig{Compression: &schemaconfig.Compression{}, CompressSegmentByTags: true, CompressMaxTagCardinality: 100},
+			expected: map[string][]string{"cpu": {"region"}, "mem": {}},
+		}, {
+			desc: "retention policy column",
+			args: &cli.MigrationConfig{
+				Compression:            &schemaconfig.Compression{},
+				CompressSegmentByTags:  true,
+				RetentionPolicyMapping: schemaconfig.RPToColumn,
+			},
+			expected: map[string][]string{"cpu": {"rp", "host", "region"}, "mem": {"rp"}},
+		}, {
+			desc:     "tags as json",
+			args:     &cli.MigrationConfig{Compression: &schemaconfig.Compression{}, CompressSegmentByTags: true, TagsAsJSON: true, TagsCol: "tags"},
+			expected: map[string][]string{},
+		}, {
+			desc:            "indexed tags",
+			args:            &cli.MigrationConfig{CreateIndexes: true},
+			expectedIndexed: map[string][]string{"cpu": {"host", "region"}, "mem": {}},
+		}, {
+			desc: "given indexed columns",
+			args: &cli.MigrationConfig{CreateIndexes: true, IndexColumns: []string{"host"}},
+		}, {
+			desc: "indexed tags and tags with the lowest cardinality",
+			args: &cli.MigrationConfig{
+				Compression:               &schemaconfig.Compression{},
+				CompressSegmentByTags:     true,
+				CompressMaxTagCardinality: 100,
+				CreateIndexes:             true,
+			},
+			expected:        map[string][]string{"cpu": {"region"}, "mem": {}},
+			expectedIndexed: map[string][]string{"cpu": {"host", "region"}, "mem": {}},
+		}, {
+			desc:            "key tags of upserted rows",
+			args:            &cli.MigrationConfig{OnConflict: config.ConflictDoUpdate},
+			expectedKeyTags: map[string][]string{"cpu": {"host", "region"}, "mem": {}},
+		}, {
+			desc:            "tags of the points written to InfluxDB",
+			args:            &cli.MigrationConfig{InfluxOutput: &config.InfluxOutput{}},
+			expectedKeyTags: map[string][]string{"cpu": {"host", "region"}, "mem": {}},
+		}, {
+			desc: "rows are not upserted",
+			args: &cli.MigrationConfig{OnConflict: config.NoConflictAction},
+		}, {
+			desc:      "error discovering tags",
+			args:      &cli.MigrationConfig{Compression: &schemaconfig.Compression{}, CompressSegmentByTags: true},
+			tagErr:    fmt.Errorf("error"),
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		conn := &mockInfConn{}
+		app := &appContext{
+			ics:                       &mockService{inflConn: conn},
+			influxTagExplorer:         &mockTagExplorer{tags: tags, err: tc.tagErr},
+			influxCardinalityExplorer: &mockCardinalityExplorer{ranked: cardinalities},
+		}
+		err := resolveTagColumns(app, &cli.ConnectionConfig{}, tc.args, nil, []string{"cpu", "mem"})
+		if tc.expectErr {
+			assert.Error(t, err, tc.desc)
+			continue
+		}
+
+		assert.NoError(t, err, tc.desc)
+		assert.Equal(t, tc.expected, tc.args.MeasureSegmentBy, tc.desc)
+		assert.Equal(t, tc.expectedIndexed, tc.args.MeasureIndexedTags, tc.desc)
+		assert.Equal(t, tc.expectedKeyTags, tc.args.MeasureKeyTags, tc.desc)
+		assert.Equal(t, (tc.expected != nil || tc.expectedIndexed != nil || tc.expectedKeyTags != nil) && !tc.args.TagsAsJSON, conn.closeCalled, tc.desc)
+	}
+}
+
+type mockTagExplorer struct {
+	tags map[string][]*idrf.Column
+	err  error
+}
+
+func (m *mockTagExplorer) DiscoverMeasurementTags(influxClient influx.Client, database, rp, measure string) ([]*idrf.Column, error) {
+	return m.tags[measure], m.err
+}
+
+type mockCardinalityExplorer struct {
+	ranked map[string][]*discovery.TagCardinality
+}
+
+func (m *mockCardinalityExplorer) RankTagsByCardinality(influxClient influx.Client, database, rp, measure string, tags []string) ([]*discovery.TagCardinality, error) {
+	return m.ranked[measure], nil
+}
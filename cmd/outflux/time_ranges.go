@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/timescale/outflux/internal/cli"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+)
+
+// resolveTimeRanges sets the time range the partitions of the table of each measure are created for, when migrating
+// to plain PostgreSQL. The range is bounded by the 'from' and 'to' flags when set. Otherwise it starts with the oldest
+// point of the measure, and ends with its newest point or the current time, whichever is later, so points written
+// during the migration have a partition as well. Measures without points get no partitions
+func resolveTimeRanges(app *appContext, connArgs *cli.ConnectionConfig, args *cli.MigrationConfig, measures []string) error {
+	if args.OutputType != schemaconfig.PostgreSQLOutput || args.OutputSchemaStrategy == schemaconfig.ValidateOnly {
+		return nil
+	}
+
+	args.MeasureTimeRanges = make(map[string]*schemaconfig.TimeRange, len(measures))
+	if len(measures) == 0 {
+		return nil
+	}
+
+	// the flags were validated when parsed
+	var from, to time.Time
+	if args.From != "" {
+		from, _ = time.Parse(time.RFC3339, args.From)
+	}
+
+	if args.To != "" {
+		to, _ = time.Parse(time.RFC3339, args.To)
+	}
+
+	if args.From != "" && args.To != "" {
+		for _, measure := range measures {
+			args.MeasureTimeRanges[measure] = &schemaconfig.TimeRange{From: from, To: to}
+		}
+
+		return nil
+	}
+
+	influxConn, err := app.ics.NewConnection(influxConnParams(connArgs))
+	if err != nil {
+		return fmt.Errorf("could not open connection to Influx Server\n%v", err)
+	}
+
+	defer influxConn.Close()
+	now := time.Now()
+	for _, measure := range measures {
+		points, err := app.influxTimeRangeExplorer.FetchPointTimeRange(influxConn, connArgs.InputDb, args.RetentionPolicy, measure)
+		if err != nil {
+			return fmt.Errorf("could not discover the time range of measure '%s'\n%v", measure, err)
+		}
+
+		if points == nil {
+			log.Printf("Measure '%s' has no points, its table will have no partitions", measure)
+			continue
+		}
+
+		timeRange := &schemaconfig.TimeRange{From: from, To: to}
+		if args.From == "" {
+			timeRange.From = points.Oldest
+		}
+
+		if args.To == "" {
+			timeRange.To = points.Newest
+			if now.After(timeRange.To) {
+				timeRange.To = now
+			}
+		}
+
+		if timeRange.To.Before(timeRange.From) {
+			log.Printf("Measure '%s' has no points in the migrated time range, its table will have no partitions", measure)
+			continue
+		}
+
+		args.MeasureTimeRanges[measure] = timeRange
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/cli"
+	"github.com/timescale/outflux/internal/schemamanagement/influx/discovery"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+)
+
+func TestResolveTimeRanges(t *testing.T) {
+	oldest := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	newest := time.Date(2019, time.February, 1, 0, 0, 0, 0, time.UTC)
+	future := time.Now().Add(24 * time.Hour)
+	flagFrom := time.Date(2019, time.January, 15, 0, 0, 0, 0, time.UTC)
+	pointRanges := map[string]*discovery.PointTimeRange{
+		"cpu":    {Oldest: oldest, Newest: newest},
+		"future": {Oldest: oldest, Newest: future},
+		"mem":    nil,
+	}
+	pgArgs := func(from, to string) *cli.MigrationConfig {
+		return &cli.MigrationConfig{OutputType: schemaconfig.PostgreSQLOutput, OutputSchemaStrategy: schemaconfig.CreateIfMissing, From: from, To: to}
+	}
+	testCases := []struct {
+		desc      string
+		args      *cli.MigrationConfig
+		rangeErr  error
+		expected  map[string]*schemaconfig.TimeRange
+		expectNow bool
+		expectErr bool
+	}{
+		{
+			desc: "TimescaleDB output",
+			args: &cli.MigrationConfig{OutputType: schemaconfig.TimescaleDBOutput, OutputSchemaStrategy: schemaconfig.CreateIfMissing},
+		}, {
+			desc: "validate only",
+			args: &cli.MigrationConfig{OutputType: schemaconfig.PostgreSQLOutput, OutputSchemaStrategy: schemaconfig.ValidateOnly},
+		}, {
+			desc: "from the flags",
+			args: pgArgs("2019-01-15T00:00:00Z", "2019-03-01T00:00:00Z"),
+			expected: map[string]*schemaconfig.TimeRange{
+				"cpu":    {From: flagFrom, To: time.Date(2019, time.March, 1, 0, 0, 0, 0, time.UTC)},
+				"future": {From: flagFrom, To: time.Date(2019, time.March, 1, 0, 0, 0, 0, time.UTC)},
+				"mem":    {From: flagFrom, To: time.Date(2019, time.March, 1, 0, 0, 0, 0, time.UTC)},
+			},
+		}, {
+			desc: "from the points",
+			args: pgArgs("", "2019-01-20T00:00:00Z"),
+			expected: map[string]*schemaconfig.TimeRange{
+				"cpu":    {From: oldest, To: time.Date(2019, time.January, 20, 0, 0, 0, 0, time.UTC)},
+				"future": {From: oldest, To: time.Date(2019, time.January, 20, 0, 0, 0, 0, time.UTC)},
+			},
+		}, {
+			desc:      "to the newest point or now",
+			args:      pgArgs("2019-01-15T00:00:00Z", ""),
+			expected:  map[string]*schemaconfig.TimeRange{"future": {From: flagFrom, To: future}},
+			expectNow: true,
+		}, {
+			desc:      "error fetching the time range",
+			args:      pgArgs("", ""),
+			rangeErr:  fmt.Errorf("error"),
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		app := &appContext{
+			ics:                     &mockService{inflConn: &mockInfConn{}},
+			influxTimeRangeExplorer: &mockTimeRangeExplorer{ranges: pointRanges, err: tc.rangeErr},
+		}
+		before := time.Now()
+		err := resolveTimeRanges(app, &cli.ConnectionConfig{}, tc.args, []string{"cpu", "future", "mem"})
+		if tc.expectErr {
+			assert.Error(t, err, tc.desc)
+			continue
+		}
+
+		assert.NoError(t, err, tc.desc)
+		if !tc.expectNow {
+			assert.Equal(t, tc.expected, tc.args.MeasureTimeRanges, tc.desc)
+			continue
+		}
+
+		// the newest point of 'cpu' is older than the time of the migration
+		cpu := tc.args.MeasureTimeRanges["cpu"]
+		assert.Equal(t, flagFrom, cpu.From, tc.desc)
+		assert.False(t, cpu.To.Before(before), tc.desc)
+		assert.Equal(t, tc.expected["future"], tc.args.MeasureTimeRanges["future"], tc.desc)
+		assert.Nil(t, tc.args.MeasureTimeRanges["mem"], tc.desc)
+	}
+}
+
+type mockTimeRangeExplorer struct {
+	ranges map[string]*discovery.PointTimeRange
+	err    error
+}
+
+func (m *mockTimeRangeExplorer) FetchPointTimeRange(influxClient influx.Client, database, rp, measure string) (*discovery.PointTimeRange, error) {
+	return m.ranges[measure], m.err
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/timescale/outflux/internal/cli"
+	"github.com/timescale/outflux/internal/cli/flagparsers"
+	"github.com/timescale/outflux/internal/querytranslation"
+)
+
+const (
+	queryHeaderTemplate      = "-- influxql: %s\n"
+	queryUnsupportedTemplate = "-- unsupported: %s\n"
+)
+
+func initTranslateCmd() *cobra.Command {
+	translateCmd := &cobra.Command{
+		Use:   "translate [query ...]",
+		Short: "Translate InfluxQL queries, or the InfluxDB queries of a Grafana dashboard, into SQL",
+		Long: "Translate InfluxQL SELECT and SHOW TAG VALUES queries into SQL queries of the migrated data. The queries are" +
+			" read from the arguments, or from STDIN if none are given, and the SQL is printed to STDOUT with the parts of the" +
+			" queries that could not be translated as comments. With --" + flagparsers.DashboardFlag + " the InfluxDB queries of a" +
+			" Grafana dashboard are rewritten as queries of the PostgreSQL data source given with --" + flagparsers.DatasourceFlag +
+			", and the rewritten dashboard is printed to STDOUT. The layout flags must match the ones used for the migration",
+		Run: func(cmd *cobra.Command, args []string) {
+			translateArgs, err := flagparsers.FlagsToTranslateConfig(cmd.Flags(), args)
+			if err != nil {
+				log.Fatal(err)
+				return
+			}
+
+			err = translate(translateArgs, os.Stdin, os.Stdout)
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	translateCmd.PersistentFlags().String(flagparsers.OutputSchemaFlag, flagparsers.DefaultOutputSchema, "The schema of the output database that holds the migrated tables")
+	translateCmd.PersistentFlags().Bool(flagparsers.TagsAsJSONFlag, flagparsers.DefaultTagsAsJSON, "If specified the tags are queried from the JSONb column they were migrated to")
+	translateCmd.PersistentFlags().String(flagparsers.TagsColumnFlag, flagparsers.DefaultTagsColumn, "When "+flagparsers.TagsAsJSONFlag+" is set, this column specifies the name of the JSON column for the tags")
+	translateCmd.PersistentFlags().Bool(flagparsers.FieldsAsJSONFlag, flagparsers.DefaultFieldsAsJSON, "If specified the fields are queried from the JSONb column they were migrated to")
+	translateCmd.PersistentFlags().String(flagparsers.FieldsColumnFlag, flagparsers.DefaultFieldsColumn, "When "+flagparsers.FieldsAsJSONFlag+" is set, this column specifies the name of the JSON column for the fields")
+	translateCmd.PersistentFlags().String(flagparsers.DashboardFlag, flagparsers.DefaultDashboard, "Path of a Grafana dashboard JSON whose InfluxDB queries are rewritten")
+	translateCmd.PersistentFlags().String(flagparsers.DatasourceFlag, flagparsers.DefaultDatasource, "The uid of the PostgreSQL data source the rewritten dashboard queries (its name for dashboards that reference data sources by name)")
+	return translateCmd
+}
+
+// translate writes the SQL of the queries, or the rewritten dashboard, to out. The queries are read from in when none are given
+func translate(args *cli.TranslateConfig, in io.Reader, out io.Writer) error {
+	if args.Quiet {
+		log.SetFlags(0)
+		log.SetOutput(ioutil.Discard)
+	}
+
+	layout := &querytranslation.Layout{Schema: args.OutputSchema, TagsColumn: args.TagsColumn, FieldsColumn: args.FieldsColumn}
+	if args.Dashboard != "" {
+		return translateDashboard(args.Dashboard, layout, args.Datasource, out)
+	}
+
+	queries := args.Queries
+	if len(queries) == 0 {
+		content, err := ioutil.ReadAll(in)
+		if err != nil {
+			return fmt.Errorf("could not read the queries from STDIN\n%v", err)
+		}
+
+		queries = []string{string(content)}
+	}
+
+	for _, query := range queries {
+		translations, err := querytranslation.Translate(query, layout)
+		if err != nil {
+			return fmt.Errorf("could not translate query '%s'\n%v", query, err)
+		}
+
+		for _, translation := range translations {
+			writeQueryTranslation(out, translation)
+		}
+	}
+
+	return nil
+}
+
+func translateDashboard(path string, layout *querytranslation.Layout, datasource string, out io.Writer) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read dashboard '%s'\n%v", path, err)
+	}
+
+	translated, report, err := querytranslation.TranslateDashboard(content, layout, datasource)
+	if err != nil {
+		return fmt.Errorf("could not translate dashboard '%s'\n%v", path, err)
+	}
+
+	log.Printf("Translated %d queries of dashboard '%s'", report.Translated, path)
+	for _, flagged := range report.Flagged {
+		log.Printf("Flagged %s", flagged)
+	}
+
+	_, err = fmt.Fprintln(out, string(translated))
+	return err
+}
+
+func writeQueryTranslation(out io.Writer, translation *querytranslation.Translation) {
+	fmt.Fprintf(out, queryHeaderTemplate, strings.Replace(translation.InfluxQL, "\n", " ", -1))
+	for _, unsupported := range translation.Unsupported {
+		fmt.Fprintf(out, queryUnsupportedTemplate, unsupported)
+	}
+
+	if translation.SQL != "" {
+		fmt.Fprintf(out, "%s;\n", translation.SQL)
+	}
+
+	fmt.Fprintln(out)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/cli"
+)
+
+func TestTranslateQueries(t *testing.T) {
+	args := &cli.TranslateConfig{Queries: []string{"SELECT a FROM m", "SELECT b FROM n; SHOW SERIES"}, TagsColumn: "tags", Quiet: true}
+	expected := "-- influxql: SELECT a FROM m\n" +
+		"SELECT \"time\", \"a\"\nFROM \"m\"\nORDER BY \"time\";\n\n" +
+		"-- influxql: SELECT b FROM n\n" +
+		"SELECT \"time\", \"b\"\nFROM \"n\"\nORDER BY \"time\";\n\n" +
+		"-- influxql: SHOW SERIES\n" +
+		"-- unsupported: only SELECT and SHOW TAG VALUES statements can be translated\n\n"
+	out := &bytes.Buffer{}
+	assert.NoError(t, translate(args, &bytes.Buffer{}, out))
+	assert.Equal(t, expected, out.String())
+
+	// the queries are read from the input when none are given
+	args = &cli.TranslateConfig{OutputSchema: "s", TagsColumn: "tags", Quiet: true}
+	out = &bytes.Buffer{}
+	assert.NoError(t, translate(args, strings.NewReader("SELECT count(a) FROM m WHERE host = 'x'\n"), out))
+	assert.Equal(t, "-- influxql: SELECT count(a) FROM m WHERE host = 'x'\n"+
+		"SELECT count(\"a\") AS \"count\"\nFROM \"s\".\"m\"\nWHERE \"tags\"->>'host' = 'x';\n\n", out.String())
+
+	args = &cli.TranslateConfig{Queries: []string{"SELECT FROM"}, Quiet: true}
+	assert.Error(t, translate(args, &bytes.Buffer{}, &bytes.Buffer{}))
+}
+
+func TestTranslateDashboardFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outflux")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dashboard.json")
+	dashboard := `{"panels": [{"title": "p", "datasource": {"type": "influxdb", "uid": "i"},` +
+		` "targets": [{"refId": "A", "rawQuery": true, "query": "SELECT a FROM m WHERE $timeFilter"}]}]}`
+	if !assert.NoError(t, ioutil.WriteFile(path, []byte(dashboard), 0644)) {
+		return
+	}
+
+	args := &cli.TranslateConfig{Dashboard: path, Datasource: "pg", Quiet: true}
+	out := &bytes.Buffer{}
+	assert.NoError(t, translate(args, &bytes.Buffer{}, out))
+	var translated map[string]interface{}
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &translated))
+	panel := translated["panels"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "postgres", "uid": "pg"}, panel["datasource"])
+
+	args.Dashboard = filepath.Join(dir, "missing.json")
+	assert.Error(t, translate(args, &bytes.Buffer{}, &bytes.Buffer{}))
+}
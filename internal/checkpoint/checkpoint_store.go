@@ -0,0 +1,109 @@
+// Package checkpoint keeps track of the migration progress of each measurement
+// in a bookkeeping table in the output database, so an interrupted migration
+// can be resumed from the last committed point
+package checkpoint
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/timescale/outflux/internal/connections"
+)
+
+const (
+	// TableName is the name of the bookkeeping table holding the checkpoints
+	TableName                   = "outflux_checkpoint"
+	tableNameTemplate           = `"%s"`
+	tableNameWithSchemaTemplate = `"%s"."%s"`
+	createTableQueryTemplate    = `CREATE TABLE IF NOT EXISTS %s(
+		database TEXT NOT NULL,
+		retention_policy TEXT NOT NULL,
+		measure TEXT NOT NULL,
+		last_time TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY (database, retention_policy, measure))`
+	loadQueryTemplate = `SELECT last_time FROM %s WHERE database = $1 AND retention_policy = $2 AND measure = $3`
+	saveQueryTemplate = `INSERT INTO %s AS c VALUES ($1, $2, $3, $4)
+		ON CONFLICT (database, retention_policy, measure)
+		DO UPDATE SET last_time = EXCLUDED.last_time WHERE c.last_time < EXCLUDED.last_time`
+)
+
+// Key identifies the source of the migrated data a checkpoint refers to
+type Key struct {
+	Database        string
+	RetentionPolicy string
+	Measure         string
+}
+
+func (k Key) String() string {
+	return fmt.Sprintf("%s.%s.%s", k.Database, k.RetentionPolicy, k.Measure)
+}
+
+// Store defines methods for reading and writing checkpoints
+type Store interface {
+	// Init creates the bookkeeping table if it doesn't exist
+	Init() error
+	// Load returns the last committed timestamp for a key. The returned bool
+	// is false if no checkpoint was recorded for the key
+	Load(key Key) (time.Time, bool, error)
+	// Save records a timestamp for a key. The checkpoint never moves back in time.
+	// If called while a transaction is open, the checkpoint is committed with it
+	Save(key Key, lastTime time.Time) error
+}
+
+// NewStore creates a Store that keeps the checkpoints in a table in the specified schema
+func NewStore(dbConn connections.PgxWrap, schema string) Store {
+	var tableName string
+	if schema != "" {
+		tableName = fmt.Sprintf(tableNameWithSchemaTemplate, schema, TableName)
+	} else {
+		tableName = fmt.Sprintf(tableNameTemplate, TableName)
+	}
+
+	return &defaultStore{dbConn: dbConn, tableName: tableName}
+}
+
+type defaultStore struct {
+	dbConn    connections.PgxWrap
+	tableName string
+}
+
+func (s *defaultStore) Init() error {
+	query := fmt.Sprintf(createTableQueryTemplate, s.tableName)
+	log.Printf("Preparing checkpoint table %s", s.tableName)
+	if _, err := s.dbConn.Exec(query); err != nil {
+		return fmt.Errorf("could not create checkpoint table %s\n%v", s.tableName, err)
+	}
+
+	return nil
+}
+
+func (s *defaultStore) Load(key Key) (time.Time, bool, error) {
+	query := fmt.Sprintf(loadQueryTemplate, s.tableName)
+	rows, err := s.dbConn.Query(query, key.Database, key.RetentionPolicy, key.Measure)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("could not load checkpoint for '%s'\n%v", key, err)
+	}
+
+	defer rows.Close()
+	if !rows.Next() {
+		return time.Time{}, false, rows.Err()
+	}
+
+	var lastTime time.Time
+	if err = rows.Scan(&lastTime); err != nil {
+		return time.Time{}, false, fmt.Errorf("could not load checkpoint for '%s'\n%v", key, err)
+	}
+
+	return lastTime, true, nil
+}
+
+func (s *defaultStore) Save(key Key, lastTime time.Time) error {
+	query := fmt.Sprintf(saveQueryTemplate, s.tableName)
+	_, err := s.dbConn.Exec(query, key.Database, key.RetentionPolicy, key.Measure, lastTime)
+	if err != nil {
+		return fmt.Errorf("could not save checkpoint for '%s'\n%v", key, err)
+	}
+
+	return nil
+}
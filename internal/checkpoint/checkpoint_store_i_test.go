@@ -0,0 +1,59 @@
+// +build integration
+
+package checkpoint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/timescale/outflux/internal/connections"
+	"github.com/timescale/outflux/internal/testutils"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	db := "test_checkpoint_store"
+	if err := testutils.DeleteTimescaleDb(db); err != nil {
+		t.Fatalf("could not prepare db: %v", err)
+	}
+
+	if err := testutils.CreateTimescaleDb(db); err != nil {
+		t.Fatalf("could not prepare db: %v", err)
+	}
+
+	defer testutils.DeleteTimescaleDb(db)
+	dbConn, err := testutils.OpenTSConn(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbConn.Close()
+
+	store := NewStore(connections.NewPgxWrapper(dbConn), "")
+	if err = store.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	key := Key{Database: "db", RetentionPolicy: "rp", Measure: "m"}
+	if _, found, err := store.Load(key); err != nil || found {
+		t.Fatalf("expected no checkpoint, got found: %v, err: %v", found, err)
+	}
+
+	later := time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC)
+	earlier := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err = store.Save(key, later); err != nil {
+		t.Fatal(err)
+	}
+
+	// checkpoint must not move back in time
+	if err = store.Save(key, earlier); err != nil {
+		t.Fatal(err)
+	}
+
+	lastTime, found, err := store.Load(key)
+	if err != nil || !found {
+		t.Fatalf("expected checkpoint, got found: %v, err: %v", found, err)
+	}
+
+	if !lastTime.Equal(later) {
+		t.Errorf("expected checkpoint %v, got %v", later, lastTime)
+	}
+}
@@ -0,0 +1,60 @@
+package checkpoint
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/connections"
+)
+
+func TestNewStore(t *testing.T) {
+	store := NewStore(nil, "").(*defaultStore)
+	assert.Equal(t, `"outflux_checkpoint"`, store.tableName)
+	store = NewStore(nil, "some schema").(*defaultStore)
+	assert.Equal(t, `"some schema"."outflux_checkpoint"`, store.tableName)
+}
+
+func TestInit(t *testing.T) {
+	mock := &connections.MockPgxW{
+		ExecRes:  []pgx.CommandTag{""},
+		ExecErrs: []error{errors.New("generic error")},
+	}
+	store := NewStore(mock, "")
+	assert.Error(t, store.Init())
+	mock = &connections.MockPgxW{
+		ExecRes:  []pgx.CommandTag{""},
+		ExecErrs: []error{nil},
+	}
+	store = NewStore(mock, "")
+	assert.NoError(t, store.Init())
+	assert.Contains(t, mock.ExpExec[0], `CREATE TABLE IF NOT EXISTS "outflux_checkpoint"`)
+}
+
+func TestSave(t *testing.T) {
+	key := Key{Database: "db", RetentionPolicy: "rp", Measure: "m"}
+	now := time.Now()
+	mock := &connections.MockPgxW{
+		ExecRes:  []pgx.CommandTag{"", ""},
+		ExecErrs: []error{errors.New("generic error"), nil},
+	}
+	store := NewStore(mock, "s")
+	assert.Error(t, store.Save(key, now))
+	assert.NoError(t, store.Save(key, now))
+	assert.Contains(t, mock.ExpExec[1], `INSERT INTO "s"."outflux_checkpoint"`)
+	assert.Equal(t, []interface{}{"db", "rp", "m", now}, mock.ExpExecArgs[1])
+}
+
+func TestLoadQueryError(t *testing.T) {
+	mock := &connections.MockPgxW{
+		QueryRes:  []*pgx.Rows{nil},
+		QueryErrs: []error{errors.New("generic error")},
+	}
+	store := NewStore(mock, "")
+	_, found, err := store.Load(Key{Database: "db", RetentionPolicy: "rp", Measure: "m"})
+	assert.Error(t, err)
+	assert.False(t, found)
+	assert.Equal(t, []interface{}{"db", "rp", "m"}, mock.ExpQArgs[0])
+}
@@ -1,12 +1,34 @@
 package cli
 
+// Values of the input API setting. InfluxDB 1.x servers and the 1.x compatibility API of
+// InfluxDB 2.x are accessed with InfluxQL, the v2 API is accessed with Flux
+const (
+	InputAPIV1 = "v1"
+	InputAPIV2 = "v2"
+)
+
+// DatabasePlaceholder is replaced with the name of the input database in the output
+// connection string and schema, so each migrated database can have its own target
+const DatabasePlaceholder = "{database}"
+
 // ConnectionConfig holds all arguments required to establish a connection to an input and output db
 type ConnectionConfig struct {
 	InputHost          string
 	InputDb            string
+	InputDatabases     []string
+	AllDatabases       bool
 	InputMeasures      []string
 	InputUser          string
 	InputPass          string
 	InputUnsafeHTTPS   bool
+	InputAPI           string
+	InputToken         string
+	InputOrg           string
+	InputFile          string
+	InputDataDir       string
+	InputWALDir        string
+	InputConn          string
+	InputTagColumns    []string
+	MeasureSelection   MeasureSelection
 	OutputDbConnString string
 }
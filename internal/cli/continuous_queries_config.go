@@ -0,0 +1,11 @@
+package cli
+
+// ContinuousQueriesConfig contains the configurable parameters for translating the continuous
+// queries of an InfluxDB database into TimescaleDB continuous aggregates
+type ContinuousQueriesConfig struct {
+	// OutputSchema holds the migrated hypertables, the continuous aggregates are created in it
+	OutputSchema string
+	// Execute creates the continuous aggregates in the output database, instead of only printing the statements
+	Execute bool
+	Quiet   bool
+}
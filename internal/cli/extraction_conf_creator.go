@@ -17,6 +17,11 @@ type extractionConfCreator interface {
 type defaultExtractionConfCreator struct{}
 
 func (d *defaultExtractionConfCreator) create(pipeID, db, measure string, conf *MigrationConfig) *config.ExtractionConfig {
+	workers := conf.ExtractionWorkers
+	if measureWorkers, ok := conf.MeasureExtractionWorkers[measure]; ok {
+		workers = measureWorkers
+	}
+
 	measureExtractionConf := &config.MeasureExtraction{
 		Database:                    db,
 		Measure:                     measure,
@@ -27,6 +32,10 @@ func (d *defaultExtractionConfCreator) create(pipeID, db, measure string, conf *
 		SchemaOnly:                  conf.SchemaOnly,
 		RetentionPolicy:             conf.RetentionPolicy,
 		OnConflictConvertIntToFloat: conf.OnConflictConvertIntToFloat,
+		Workers:                     workers,
+		WindowSize:                  conf.ExtractionWindow,
+		Where:                       config.CombineWhere(conf.Where, conf.MeasureWhere[measure]),
+		Downsampling:                conf.Downsampling,
 	}
 
 	ex := &config.ExtractionConfig{
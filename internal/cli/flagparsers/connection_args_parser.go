@@ -1,16 +1,24 @@
 package flagparsers
 
 import (
+	"bufio"
 	"fmt"
+	"os"
+	"regexp"
+	"strings"
 
 	"github.com/spf13/pflag"
 	"github.com/timescale/outflux/internal/cli"
 )
 
+const databaseSeparator = ","
+
 // FlagsToConnectionConfig extracts flags related to establishing the connection to input and output database
 func FlagsToConnectionConfig(flags *pflag.FlagSet, args []string) (*cli.ConnectionConfig, error) {
-	if args[0] == "" {
-		return nil, fmt.Errorf("input database name not specified")
+	allDatabases, _ := flags.GetBool(AllDatabasesFlag)
+	databases, measures, err := parseDatabases(args, allDatabases)
+	if err != nil {
+		return nil, err
 	}
 
 	inputUser, _ := flags.GetString(InputUserFlag)
@@ -18,13 +26,192 @@ func FlagsToConnectionConfig(flags *pflag.FlagSet, args []string) (*cli.Connecti
 	inputHost, _ := flags.GetString(InputServerFlag)
 	inputUnsafe, _ := flags.GetBool(InputUnsafeHTTPSFlag)
 	outputConnString, _ := flags.GetString(OutputConnFlag)
+	inputAPI, _ := flags.GetString(InputAPIFlag)
+	if inputAPI != cli.InputAPIV1 && inputAPI != cli.InputAPIV2 {
+		return nil, fmt.Errorf("value for the '%s' flag must be '%s' or '%s'", InputAPIFlag, cli.InputAPIV1, cli.InputAPIV2)
+	}
+
+	inputFile, _ := flags.GetString(InputFileFlag)
+	if inputFile != "" && inputAPI == cli.InputAPIV2 {
+		return nil, fmt.Errorf("the '%s' and '%s' flags can't be used together", InputFileFlag, InputAPIFlag)
+	}
+
+	inputDataDir, _ := flags.GetString(InputDataDirFlag)
+	inputWALDir, _ := flags.GetString(InputWALDirFlag)
+	if inputDataDir != "" && inputFile != "" {
+		return nil, fmt.Errorf("the '%s' and '%s' flags can't be used together", InputDataDirFlag, InputFileFlag)
+	} else if inputDataDir != "" && inputAPI == cli.InputAPIV2 {
+		return nil, fmt.Errorf("the '%s' and '%s' flags can't be used together", InputDataDirFlag, InputAPIFlag)
+	} else if inputWALDir != "" && inputDataDir == "" {
+		return nil, fmt.Errorf("the '%s' flag requires the '%s' flag", InputWALDirFlag, InputDataDirFlag)
+	}
+
+	inputConn, _ := flags.GetString(InputConnFlag)
+	if inputConn != "" && (inputFile != "" || inputDataDir != "" || inputAPI == cli.InputAPIV2) {
+		return nil, fmt.Errorf("the '%s' flag can't be used with the '%s', '%s' or '%s' flags", InputConnFlag, InputFileFlag, InputDataDirFlag, InputAPIFlag)
+	}
+
+	inputTagColumns, err := parseInputTagColumns(flags, inputConn)
+	if err != nil {
+		return nil, err
+	}
+
+	if allDatabases && (inputFile != "" || inputDataDir != "" || inputConn != "" || inputAPI == cli.InputAPIV2) {
+		return nil, fmt.Errorf("the '%s' flag can only be used with the '%s' input API of an input server", AllDatabasesFlag, cli.InputAPIV1)
+	}
+
+	if allDatabases || len(databases) > 1 {
+		outputSchema, _ := flags.GetString(OutputSchemaFlag)
+		// the rows are written to files, a script or InfluxDB instead of the output database if a directory,
+		// a script or an InfluxDB server is given
+		output, outputFlag := outputConnString, OutputConnFlag
+		if outputDir, _ := flags.GetString(OutputDirFlag); outputDir != "" {
+			output, outputFlag = outputDir, OutputDirFlag
+		} else if outputScript, _ := flags.GetString(OutputScriptFlag); outputScript != "" {
+			output, outputFlag = outputScript, OutputScriptFlag
+		} else if outputInflux, _ := flags.GetString(OutputInfluxFlag); outputInflux != "" {
+			output, _ = flags.GetString(OutputInfluxDBFlag)
+			outputFlag = OutputInfluxDBFlag
+		}
+
+		if !strings.Contains(output, cli.DatabasePlaceholder) && !strings.Contains(outputSchema, cli.DatabasePlaceholder) {
+			return nil, fmt.Errorf("when migrating several databases the '%s' or the '%s' flag must contain '%s', so the measures of different databases don't collide", outputFlag, OutputSchemaFlag, cli.DatabasePlaceholder)
+		}
+	}
+
+	measurementsFile, _ := flags.GetString(MeasurementsFileFlag)
+	if measurementsFile != "" {
+		fromFile, err := readMeasurementsFile(measurementsFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the file of the '%s' flag\n%v", MeasurementsFileFlag, err)
+		}
+
+		measures = append(measures, fromFile...)
+	}
+
+	include, err := parsePatterns(flags, IncludeFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	exclude, err := parsePatterns(flags, ExcludeFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	inputDb := ""
+	if len(databases) > 0 {
+		inputDb = databases[0]
+	}
+
+	inputToken, _ := flags.GetString(InputTokenFlag)
+	inputOrg, _ := flags.GetString(InputOrgFlag)
 	return &cli.ConnectionConfig{
-		InputDb:            args[0],
-		InputMeasures:      args[1:],
+		InputDb:            inputDb,
+		InputDatabases:     databases,
+		AllDatabases:       allDatabases,
+		InputMeasures:      measures,
 		InputHost:          inputHost,
 		InputUser:          inputUser,
 		InputPass:          inputPass,
 		InputUnsafeHTTPS:   inputUnsafe,
+		InputAPI:           inputAPI,
+		InputToken:         inputToken,
+		InputOrg:           inputOrg,
+		InputFile:          inputFile,
+		InputDataDir:       inputDataDir,
+		InputWALDir:        inputWALDir,
+		InputConn:          inputConn,
+		InputTagColumns:    inputTagColumns,
+		MeasureSelection:   cli.MeasureSelection{Include: include, Exclude: exclude},
 		OutputDbConnString: outputConnString,
 	}, nil
 }
+
+// parseDatabases splits the arguments in the input databases and the measures. The first argument is a comma
+// separated list of databases, the rest are measures. When all databases are migrated all arguments are measures
+func parseDatabases(args []string, allDatabases bool) ([]string, []string, error) {
+	if allDatabases {
+		return nil, args, nil
+	}
+
+	if len(args) == 0 || args[0] == "" {
+		return nil, nil, fmt.Errorf("input database name not specified")
+	}
+
+	databases := strings.Split(args[0], databaseSeparator)
+	for _, database := range databases {
+		if database == "" {
+			return nil, nil, fmt.Errorf("empty input database name in '%s'", args[0])
+		}
+	}
+
+	return databases, args[1:], nil
+}
+
+// parseInputTagColumns returns the columns of the input tables that are tags, nil if the tags are the text
+// columns of each table
+func parseInputTagColumns(flags *pflag.FlagSet, inputConn string) ([]string, error) {
+	if !flags.Changed(InputTagColumnsFlag) {
+		return nil, nil
+	}
+
+	if inputConn == "" {
+		return nil, fmt.Errorf("the '%s' flag requires the '%s' flag", InputTagColumnsFlag, InputConnFlag)
+	}
+
+	columnsAsStr, _ := flags.GetString(InputTagColumnsFlag)
+	columns := []string{}
+	for _, column := range strings.Split(columnsAsStr, ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			columns = append(columns, column)
+		}
+	}
+
+	return columns, nil
+}
+
+// readMeasurementsFile returns the measure names in a file, one per line. Empty lines and lines starting with # are skipped
+func readMeasurementsFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+	measures := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		measures = append(measures, line)
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(measures) == 0 {
+		return nil, fmt.Errorf("no measures listed in '%s'", path)
+	}
+
+	return measures, nil
+}
+
+func parsePatterns(flags *pflag.FlagSet, flag string) ([]*regexp.Regexp, error) {
+	asStrings, _ := flags.GetStringArray(flag)
+	patterns := make([]*regexp.Regexp, len(asStrings))
+	for i, asString := range asStrings {
+		pattern, err := regexp.Compile(asString)
+		if err != nil {
+			return nil, fmt.Errorf("value '%s' of the '%s' flag is not a valid regular expression\n%v", asString, flag, err)
+		}
+
+		patterns[i] = pattern
+	}
+
+	return patterns, nil
+}
@@ -6,6 +6,52 @@ import (
 
 // AddConnectionFlagsToCmd adds the flags required to connect to an Influx and Timescale database
 func AddConnectionFlagsToCmd(cmd *cobra.Command) {
+	AddInfluxServerFlagsToCmd(cmd)
+	cmd.PersistentFlags().String(
+		InputAPIFlag,
+		DefaultInputAPI,
+		"API used to read the input database. Valid options: v1 (InfluxQL) and v2 (Flux, InfluxDB 2.x only). With v2 the database argument is the bucket")
+	cmd.PersistentFlags().String(
+		InputTokenFlag,
+		DefaultInputToken,
+		"Token to use when connecting to the v2 API of the input database. If set overrides $INFLUX_TOKEN")
+	cmd.PersistentFlags().String(
+		InputOrgFlag,
+		DefaultInputOrg,
+		"Organization of the bucket when connecting to the v2 API of the input database. If set overrides $INFLUX_ORG")
+	cmd.PersistentFlags().String(
+		InputFileFlag,
+		DefaultInputFile,
+		"Line protocol file or 'influx_inspect export' dump (can be gzipped) to read instead of the input server. The database argument selects the exported database")
+	cmd.PersistentFlags().String(
+		InputDataDirFlag,
+		DefaultInputDataDir,
+		"Data directory of InfluxDB 1.x, or directory of an 'influxd backup -portable' backup, whose TSM shards are read instead of the input server")
+	cmd.PersistentFlags().String(
+		InputWALDirFlag,
+		DefaultInputWALDir,
+		"WAL directory of InfluxDB 1.x, read with the data directory to include the points not yet compacted to TSM files")
+	cmd.PersistentFlags().Bool(
+		AllDatabasesFlag,
+		DefaultAllDatabases,
+		"If specified, every database of the input server except '_internal' is transferred, and all arguments are measures. The '"+OutputConnFlag+"' or '"+OutputSchemaFlag+"' flag must contain '{database}'")
+	cmd.PersistentFlags().StringArray(
+		IncludeFlag,
+		[]string{},
+		"Regular expression selecting the measures to transfer, can be repeated. Applied to the discovered measures, or to the ones given as arguments")
+	cmd.PersistentFlags().StringArray(
+		ExcludeFlag,
+		[]string{},
+		"Regular expression of measures that are not transferred, can be repeated. Applied after '"+IncludeFlag+"'")
+	cmd.PersistentFlags().String(
+		MeasurementsFileFlag,
+		DefaultMeasurementsFile,
+		"File with the names of the measures to transfer, one per line. Lines starting with # are ignored. Added to the measures given as arguments")
+	AddOutputConnFlagToCmd(cmd)
+}
+
+// AddInfluxServerFlagsToCmd adds the flags required to connect to the InfluxQL API of an Influx server
+func AddInfluxServerFlagsToCmd(cmd *cobra.Command) {
 	cmd.PersistentFlags().String(
 		InputServerFlag,
 		DefaultInputServer,
@@ -22,6 +68,10 @@ func AddConnectionFlagsToCmd(cmd *cobra.Command) {
 		InputUnsafeHTTPSFlag,
 		DefaultInputUnsafeHTTPS,
 		"Should 'InsecureSkipVerify' be passed to the input connection")
+}
+
+// AddOutputConnFlagToCmd adds the flag required to connect to a Timescale database
+func AddOutputConnFlagToCmd(cmd *cobra.Command) {
 	cmd.PersistentFlags().String(
 		OutputConnFlag,
 		DefaultOutputConn,
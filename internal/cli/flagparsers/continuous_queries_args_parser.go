@@ -0,0 +1,40 @@
+package flagparsers
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+	"github.com/timescale/outflux/internal/cli"
+)
+
+// FlagsToContinuousQueriesConfig extracts the config for translating the continuous queries of a database
+// from the flags of the command. The continuous queries are read from the InfluxQL API of the input server
+func FlagsToContinuousQueriesConfig(flags *pflag.FlagSet, args []string) (*cli.ConnectionConfig, *cli.ContinuousQueriesConfig, error) {
+	if args[0] == "" {
+		return nil, nil, fmt.Errorf("input database name not specified")
+	}
+
+	quiet, err := flags.GetBool(QuietFlag)
+	if err != nil {
+		return nil, nil, fmt.Errorf("value for the '%s' flag must be a true or false", QuietFlag)
+	}
+
+	inputUser, _ := flags.GetString(InputUserFlag)
+	inputPass, _ := flags.GetString(InputPassFlag)
+	inputHost, _ := flags.GetString(InputServerFlag)
+	inputUnsafe, _ := flags.GetBool(InputUnsafeHTTPSFlag)
+	outputConnString, _ := flags.GetString(OutputConnFlag)
+	outputSchema, _ := flags.GetString(OutputSchemaFlag)
+	execute, _ := flags.GetBool(ExecuteFlag)
+	connArgs := &cli.ConnectionConfig{
+		InputDb:            args[0],
+		InputHost:          inputHost,
+		InputUser:          inputUser,
+		InputPass:          inputPass,
+		InputUnsafeHTTPS:   inputUnsafe,
+		InputAPI:           cli.InputAPIV1,
+		OutputDbConnString: outputConnString,
+	}
+
+	return connArgs, &cli.ContinuousQueriesConfig{OutputSchema: outputSchema, Execute: execute, Quiet: quiet}, nil
+}
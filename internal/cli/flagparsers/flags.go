@@ -1,6 +1,9 @@
 package flagparsers
 
 import (
+	"time"
+
+	"github.com/timescale/outflux/internal/cli"
 	ingestionConfig "github.com/timescale/outflux/internal/ingestion/config"
 	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
 )
@@ -12,6 +15,17 @@ const (
 	InputUserFlag               = "input-user"
 	InputPassFlag               = "input-pass"
 	InputUnsafeHTTPSFlag        = "input-unsafe-https"
+	InputAPIFlag                = "input-api"
+	InputTokenFlag              = "input-token"
+	InputOrgFlag                = "input-org"
+	InputFileFlag               = "input-file"
+	InputDataDirFlag            = "input-data-dir"
+	InputWALDirFlag             = "input-wal-dir"
+	InputConnFlag               = "input-conn"
+	InputTagColumnsFlag         = "input-tag-columns"
+	IncludeFlag                 = "include"
+	ExcludeFlag                 = "exclude"
+	MeasurementsFileFlag        = "measurements-file"
 	RetentionPolicyFlag         = "retention-policy"
 	OutputConnFlag              = "output-conn"
 	SchemaStrategyFlag          = "schema-strategy"
@@ -31,6 +45,53 @@ const (
 	FieldsAsJSONFlag            = "fields-as-json"
 	FieldsColumnFlag            = "fields-column"
 	ChunkTimeIntervalFlag       = "chunk-time-interval"
+	ResumeFlag                  = "resume"
+	SyncIntervalFlag            = "interval"
+	SyncOverlapFlag             = "overlap"
+	SyncDrainFlag               = "drain"
+	ExtractionWorkersFlag       = "extraction-workers"
+	MeasureWorkersFlag          = "measure-extraction-workers"
+	ExtractionWindowFlag        = "extraction-window"
+	IngestionWorkersFlag        = "ingestion-workers"
+	MeasureIngestionWorkersFlag = "measure-ingestion-workers"
+	TimeFormatFlag              = "time-format"
+	WhereFlag                   = "where"
+	WhereFileFlag               = "where-file"
+	ExecuteFlag                 = "execute"
+	AddRetentionPolicyFlag      = "add-retention-policy"
+	RetentionPolicyMappingFlag  = "retention-policy-mapping"
+	AllDatabasesFlag            = "all-databases"
+	DownsampleFlag              = "downsample"
+	DownsampleAggregatesFlag    = "downsample-aggregates"
+	DashboardFlag               = "dashboard"
+	DatasourceFlag              = "datasource"
+	CompressFlag                = "compress"
+	CompressSegmentByFlag       = "compress-segmentby"
+	CompressOrderByFlag         = "compress-orderby"
+	CompressMaxCardinalityFlag  = "compress-max-tag-cardinality"
+	CompressAfterFlag           = "compress-after"
+	SpacePartitioningFlag       = "space-partitioning"
+	CreateIndexesFlag           = "create-indexes"
+	IndexColumnsFlag            = "index-columns"
+	AutoChunkTimeIntervalFlag   = "auto-chunk-time-interval"
+	ChunkTargetSizeFlag         = "chunk-target-size-mb"
+	DensitySampleWindowFlag     = "density-sample-window"
+	OnConflictFlag              = "on-conflict"
+	OutputDirFlag               = "output-dir"
+	OutputFormatFlag            = "output-format"
+	OutputGzipFlag              = "output-gzip"
+	OutputPeriodFlag            = "output-period"
+	OutputTypeFlag              = "output-type"
+	PartitionIntervalFlag       = "partition-interval"
+	OutputScriptFlag            = "output-script"
+	OutputInfluxFlag            = "output-influx"
+	OutputInfluxDBFlag          = "output-influx-db"
+	OutputInfluxRPFlag          = "output-influx-rp"
+	OutputInfluxUserFlag        = "output-influx-user"
+	OutputInfluxPassFlag        = "output-influx-pass"
+	OutputInfluxPrecisionFlag   = "output-influx-precision"
+	OutputInfluxRetriesFlag     = "output-influx-retries"
+	OutputInfluxRetryFlag       = "output-influx-retry-interval"
 	// InfluxDB can have different data types for the same field accross
 	// different shards. If a field is discovered with an Int64 and a Float64 type
 	// and this flag is TRUE it will allow the field to be converted to float,
@@ -41,7 +102,16 @@ const (
 	DefaultInputUser               = ""
 	DefaultInputPass               = ""
 	DefaultInputUnsafeHTTPS        = false
-	DefaultRetentionPolicy         = "autogen"
+	DefaultInputAPI                = cli.InputAPIV1
+	DefaultInputToken              = ""
+	DefaultInputOrg                = ""
+	DefaultInputFile               = ""
+	DefaultInputDataDir            = ""
+	DefaultInputWALDir             = ""
+	DefaultInputConn               = ""
+	DefaultInputTagColumns         = ""
+	DefaultMeasurementsFile        = ""
+	DefaultRetentionPolicy         = ""
 	DefaultOutputConn              = "sslmode=disable"
 	DefaultOutputSchema            = ""
 	DefaultSchemaStrategy          = schemaconfig.CreateIfMissing
@@ -58,4 +128,47 @@ const (
 	DefaultFieldsColumn            = "fields"
 	DefaultMultishardIntFloatCast  = false
 	DefaultChunkTimeInterval       = ""
+	DefaultResume                  = false
+	DefaultSyncInterval            = time.Minute
+	DefaultSyncOverlap             = 5 * time.Minute
+	DefaultSyncDrain               = false
+	DefaultExtractionWorkers       = 1
+	DefaultExtractionWindow        = time.Duration(0)
+	DefaultIngestionWorkers        = 1
+	DefaultTimeFormat              = schemaconfig.Timestamptz
+	DefaultWhere                   = ""
+	DefaultWhereFile               = ""
+	DefaultExecute                 = false
+	DefaultAddRetentionPolicy      = false
+	DefaultRetentionPolicyMapping  = schemaconfig.NoRPMapping
+	DefaultAllDatabases            = false
+	DefaultDownsampleAggregates    = "float=mean,integer=mean,string=last,boolean=last"
+	DefaultDashboard               = ""
+	DefaultDatasource              = ""
+	DefaultCompress                = false
+	DefaultCompressSegmentBy       = ""
+	DefaultCompressOrderBy         = ""
+	DefaultCompressMaxCardinality  = 0
+	DefaultCompressAfter           = time.Duration(0)
+	DefaultCreateIndexes           = false
+	DefaultIndexColumns            = ""
+	DefaultAutoChunkTimeInterval   = false
+	DefaultChunkTargetSize         = 256
+	DefaultDensitySampleWindow     = 24 * time.Hour
+	DefaultOnConflict              = ingestionConfig.NoConflictAction
+	DefaultOutputDir               = ""
+	DefaultOutputFormat            = ingestionConfig.CSVFormat
+	DefaultOutputGzip              = false
+	DefaultOutputPeriod            = ingestionConfig.DayPeriod
+	DefaultOutputType              = schemaconfig.TimescaleDBOutput
+	DefaultPartitionInterval       = 7 * 24 * time.Hour
+	DefaultOutputScript            = ""
+	DefaultOutputInflux            = ""
+	DefaultOutputInfluxDB          = cli.DatabasePlaceholder
+	DefaultOutputInfluxRP          = ""
+	DefaultOutputInfluxUser        = ""
+	DefaultOutputInfluxPass        = ""
+	DefaultOutputInfluxPrecision   = ingestionConfig.NanosecondPrecision
+	DefaultOutputInfluxRetries     = 3
+	DefaultOutputInfluxRetry       = time.Second
 )
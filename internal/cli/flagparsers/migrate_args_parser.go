@@ -1,11 +1,17 @@
 package flagparsers
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"math"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
 	"github.com/timescale/outflux/internal/cli"
+	extractionConfig "github.com/timescale/outflux/internal/extraction/config"
 	ingestionConfig "github.com/timescale/outflux/internal/ingestion/config"
 	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
 )
@@ -23,12 +29,24 @@ func FlagsToMigrateConfig(flags *pflag.FlagSet, args []string) (*cli.ConnectionC
 		return nil, nil, err
 	}
 
+	timeFormatAsStr, _ := flags.GetString(TimeFormatFlag)
+	var timeFormat schemaconfig.TimeFormat
+	if timeFormat, err = schemaconfig.ParseTimeFormatString(timeFormatAsStr); err != nil {
+		return nil, nil, err
+	}
+
 	commitStrategyAsStr, _ := flags.GetString(CommitStrategyFlag)
 	var commitStrategy ingestionConfig.CommitStrategy
 	if commitStrategy, err = ingestionConfig.ParseStrategyString(commitStrategyAsStr); err != nil {
 		return nil, nil, err
 	}
 
+	onConflictAsStr, _ := flags.GetString(OnConflictFlag)
+	var onConflict ingestionConfig.ConflictStrategy
+	if onConflict, err = ingestionConfig.ParseConflictStrategyString(onConflictAsStr); err != nil {
+		return nil, nil, err
+	}
+
 	limit, err := flags.GetUint64(LimitFlag)
 	if err != nil {
 		return nil, nil, err
@@ -78,9 +96,102 @@ func FlagsToMigrateConfig(flags *pflag.FlagSet, args []string) (*cli.ConnectionC
 		return nil, nil, fmt.Errorf("When the '%s' flag is set, the '%s' must also have a value", FieldsAsJSONFlag, FieldsColumnFlag)
 	}
 	outputSchema, _ := flags.GetString(OutputSchemaFlag)
-	rp, _ := flags.GetString(RetentionPolicyFlag)
+	rp, rpMapping, err := parseRetentionPolicy(flags, connectionArgs)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	intToFloat, _ := flags.GetBool(MultishardIntFloatCast)
 	chunkTimeInterval, _ := flags.GetString(ChunkTimeIntervalFlag)
+	resume, _ := flags.GetBool(ResumeFlag)
+	if resume && (strategy == schemaconfig.DropAndCreate || strategy == schemaconfig.DropCascadeAndCreate) {
+		return nil, nil, fmt.Errorf("the '%s' flag can't be used with the '%s' schema strategy", ResumeFlag, strategy)
+	}
+
+	if resume && rpMapping == schemaconfig.RPToColumn {
+		return nil, nil, fmt.Errorf("the '%s' flag can't be used with the '%s' retention policy mapping, the retention policies share the tables", ResumeFlag, rpMapping)
+	}
+
+	if resume && connectionArgs.InputFile != "" {
+		return nil, nil, fmt.Errorf("the '%s' flag can't be used with the '%s' flag, the points of a file are not sorted by time", ResumeFlag, InputFileFlag)
+	}
+
+	if resume && connectionArgs.InputDataDir != "" {
+		return nil, nil, fmt.Errorf("the '%s' flag can't be used with the '%s' flag, the points of TSM shards are not read in time order", ResumeFlag, InputDataDirFlag)
+	}
+
+	extractionWorkers, err := flags.GetUint8(ExtractionWorkersFlag)
+	if err != nil || extractionWorkers == 0 {
+		return nil, nil, fmt.Errorf("value for the '%s' flag must be an integer > 0 and <= %d", ExtractionWorkersFlag, math.MaxUint8)
+	}
+
+	measureWorkers, err := parseMeasureWorkers(flags, MeasureWorkersFlag)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	concurrentExtraction := extractionWorkers > 1
+	for _, workers := range measureWorkers {
+		concurrentExtraction = concurrentExtraction || workers > 1
+	}
+
+	if concurrentExtraction && connectionArgs.InputAPI == cli.InputAPIV2 {
+		return nil, nil, fmt.Errorf("value for the '%s' and '%s' flags must be 1 with the '%s' input API, the time windows of a measure are extracted one at a time", ExtractionWorkersFlag, MeasureWorkersFlag, cli.InputAPIV2)
+	}
+
+	extractionWindow, err := flags.GetDuration(ExtractionWindowFlag)
+	if err != nil || extractionWindow < 0 {
+		return nil, nil, fmt.Errorf("value for the '%s' flag must be a duration >= 0", ExtractionWindowFlag)
+	}
+
+	ingestionWorkers, measureIngestionWorkers, err := parseIngestionWorkers(flags, commitStrategy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	where, measureWhere, err := parseWhere(flags)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if (where != "" || len(measureWhere) > 0) && connectionArgs.InputAPI == cli.InputAPIV2 {
+		return nil, nil, fmt.Errorf("the '%s' and '%s' flags can't be used with the '%s' input API, the conditions are InfluxQL", WhereFlag, WhereFileFlag, cli.InputAPIV2)
+	}
+
+	addRetentionPolicy, err := parseAddRetentionPolicy(flags, connectionArgs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	downsampling, err := parseDownsampling(flags, connectionArgs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if downsampling != nil && limit > 0 {
+		return nil, nil, fmt.Errorf("the '%s' flag can't be used with the '%s' flag, the points are grouped by time", DownsampleFlag, LimitFlag)
+	}
+
+	compression, segmentByTags, maxTagCardinality, err := parseCompression(flags, connectionArgs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	spacePartitioning, err := parseSpacePartitioning(flags)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	createIndexes, indexColumns, err := parseIndexes(flags)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	autoChunkTimeInterval, chunkTargetSize, densityWindow, err := parseAutoChunkTimeInterval(flags, connectionArgs, downsampling)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	migrateArgs := &cli.MigrationConfig{
 		RetentionPolicy:                      rp,
 		OutputSchemaStrategy:                 strategy,
@@ -101,7 +212,710 @@ func FlagsToMigrateConfig(flags *pflag.FlagSet, args []string) (*cli.ConnectionC
 		FieldsCol:                            fieldsColumn,
 		OnConflictConvertIntToFloat:          intToFloat,
 		ChunkTimeInterval:                    chunkTimeInterval,
+		Resume:                               resume,
+		ExtractionWorkers:                    extractionWorkers,
+		MeasureExtractionWorkers:             measureWorkers,
+		ExtractionWindow:                     extractionWindow,
+		IngestionWorkers:                     ingestionWorkers,
+		MeasureIngestionWorkers:              measureIngestionWorkers,
+		TimeFormat:                           timeFormat,
+		Where:                                where,
+		MeasureWhere:                         measureWhere,
+		AddRetentionPolicy:                   addRetentionPolicy,
+		RetentionPolicyMapping:               rpMapping,
+		Downsampling:                         downsampling,
+		Compression:                          compression,
+		CompressSegmentByTags:                segmentByTags,
+		CompressMaxTagCardinality:            maxTagCardinality,
+		MeasureSpacePartitioning:             spacePartitioning,
+		CreateIndexes:                        createIndexes,
+		IndexColumns:                         indexColumns,
+		AutoChunkTimeInterval:                autoChunkTimeInterval,
+		ChunkTargetSize:                      chunkTargetSize,
+		DensitySampleWindow:                  densityWindow,
+		OnConflict:                           onConflict,
+	}
+
+	if migrateArgs.FileOutput, err = parseFileOutput(flags, migrateArgs); err != nil {
+		return nil, nil, err
+	}
+
+	if migrateArgs.ScriptOutput, err = parseScriptOutput(flags, migrateArgs); err != nil {
+		return nil, nil, err
+	}
+
+	if migrateArgs.InfluxOutput, err = parseInfluxOutput(flags, migrateArgs); err != nil {
+		return nil, nil, err
+	}
+
+	if migrateArgs.OutputType, migrateArgs.PartitionInterval, err = parseOutputType(flags, connectionArgs, migrateArgs); err != nil {
+		return nil, nil, err
+	}
+
+	if err = checkInputConn(connectionArgs, migrateArgs); err != nil {
+		return nil, nil, err
 	}
 
 	return connectionArgs, migrateArgs, nil
 }
+
+// checkInputConn checks that the rows of the tables of an input database are written back to InfluxDB. The tables
+// have no retention policies, the points are written to the retention policy of the Influx output
+func checkInputConn(connectionArgs *cli.ConnectionConfig, args *cli.MigrationConfig) error {
+	if connectionArgs.InputConn == "" {
+		return nil
+	}
+
+	if args.InfluxOutput == nil {
+		return fmt.Errorf("the '%s' flag requires the '%s' flag, the rows of the input tables are written back to InfluxDB", InputConnFlag, OutputInfluxFlag)
+	}
+
+	if args.RetentionPolicy != "" || args.RetentionPolicyMapping != schemaconfig.NoRPMapping {
+		return fmt.Errorf("the '%s' and '%s' flags can't be used with the '%s' flag, the points are written to the retention policy of the '%s' flag", RetentionPolicyFlag, RetentionPolicyMappingFlag, InputConnFlag, OutputInfluxRPFlag)
+	}
+
+	return nil
+}
+
+// outputFlag is a flag that only applies when migrating to the tables of the output database
+type outputFlag struct {
+	name string
+	set  bool
+	// needsConnection is true if the flag can't apply to the statements of a SQL script either
+	needsConnection bool
+}
+
+// timescaleFlags returns the flags that only apply to TimescaleDB tables, the outputs without
+// them reject the flags that are set
+func timescaleFlags(args *cli.MigrationConfig) []outputFlag {
+	return []outputFlag{
+		{name: ResumeFlag, set: args.Resume, needsConnection: true},
+		{name: OnConflictFlag, set: args.OnConflict.Upserts(), needsConnection: true},
+		{name: IngestionWorkersFlag, set: args.IngestionWorkers > 1 || len(args.MeasureIngestionWorkers) > 0, needsConnection: true},
+		{name: ChunkTimeIntervalFlag, set: args.ChunkTimeInterval != ""},
+		{name: CompressFlag, set: args.Compression != nil},
+		{name: CreateIndexesFlag, set: args.CreateIndexes},
+		{name: SpacePartitioningFlag, set: len(args.MeasureSpacePartitioning) > 0},
+		{name: AddRetentionPolicyFlag, set: args.AddRetentionPolicy},
+		{name: AutoChunkTimeIntervalFlag, set: args.AutoChunkTimeInterval},
+	}
+}
+
+// parseFileOutput returns the files the rows are written to instead of the output database, or nil if no
+// output directory was given. The flags that only apply to TimescaleDB tables can't be used with it
+func parseFileOutput(flags *pflag.FlagSet, args *cli.MigrationConfig) (*ingestionConfig.FileOutput, error) {
+	if !registered(flags, OutputDirFlag) {
+		return nil, nil
+	}
+
+	dir, err := flags.GetString(OutputDirFlag)
+	if err != nil || dir == "" {
+		return nil, err
+	}
+
+	formatAsStr, err := flags.GetString(OutputFormatFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := ingestionConfig.ParseFileFormatString(formatAsStr)
+	if err != nil {
+		return nil, err
+	}
+
+	gzip, err := flags.GetBool(OutputGzipFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	if gzip && format != ingestionConfig.CSVFormat {
+		return nil, fmt.Errorf("the '%s' flag only applies to the '%s' output format", OutputGzipFlag, ingestionConfig.CSVFormat)
+	}
+
+	periodAsStr, err := flags.GetString(OutputPeriodFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	period, err := ingestionConfig.ParseFilePeriodString(periodAsStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if args.OutputSchemaStrategy == schemaconfig.ValidateOnly {
+		return nil, fmt.Errorf("the '%s' schema strategy can't be used with the '%s' flag, there are no tables to validate", args.OutputSchemaStrategy, OutputDirFlag)
+	}
+
+	for _, timescaleFlag := range timescaleFlags(args) {
+		if timescaleFlag.set {
+			return nil, fmt.Errorf("the '%s' flag can't be used with the '%s' flag, it only applies to TimescaleDB", timescaleFlag.name, OutputDirFlag)
+		}
+	}
+
+	return &ingestionConfig.FileOutput{Directory: dir, Format: format, Gzip: gzip, Period: period}, nil
+}
+
+// parseScriptOutput returns the SQL script the rows are written to instead of the output database, or nil if no
+// script was given. The flags that need a connection to the output database can't be used with it
+func parseScriptOutput(flags *pflag.FlagSet, args *cli.MigrationConfig) (*ingestionConfig.ScriptOutput, error) {
+	if !registered(flags, OutputScriptFlag) {
+		return nil, nil
+	}
+
+	path, err := flags.GetString(OutputScriptFlag)
+	if err != nil || path == "" {
+		return nil, err
+	}
+
+	if args.FileOutput != nil {
+		return nil, fmt.Errorf("the '%s' flag can't be used with the '%s' flag", OutputScriptFlag, OutputDirFlag)
+	}
+
+	for _, timescaleFlag := range timescaleFlags(args) {
+		if timescaleFlag.set && timescaleFlag.needsConnection {
+			return nil, fmt.Errorf("the '%s' flag can't be used with the '%s' flag, no connection to the output database is opened", timescaleFlag.name, OutputScriptFlag)
+		}
+	}
+
+	return &ingestionConfig.ScriptOutput{Path: path}, nil
+}
+
+// parseInfluxOutput returns the InfluxDB server the rows are written to as points instead of the output database,
+// or nil if no server was given. The flags that only apply to PostgreSQL tables can't be used with it
+func parseInfluxOutput(flags *pflag.FlagSet, args *cli.MigrationConfig) (*ingestionConfig.InfluxOutput, error) {
+	if !registered(flags, OutputInfluxFlag) {
+		return nil, nil
+	}
+
+	server, err := flags.GetString(OutputInfluxFlag)
+	if err != nil || server == "" {
+		return nil, err
+	}
+
+	if args.FileOutput != nil {
+		return nil, fmt.Errorf("the '%s' flag can't be used with the '%s' flag", OutputInfluxFlag, OutputDirFlag)
+	}
+
+	if args.ScriptOutput != nil {
+		return nil, fmt.Errorf("the '%s' flag can't be used with the '%s' flag", OutputInfluxFlag, OutputScriptFlag)
+	}
+
+	if _, err := url.ParseRequestURI(server); err != nil {
+		return nil, fmt.Errorf("value for the '%s' flag must be the URL of an InfluxDB server\n%v", OutputInfluxFlag, err)
+	}
+
+	database, err := flags.GetString(OutputInfluxDBFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	if database == "" {
+		return nil, fmt.Errorf("value for the '%s' flag can't be empty", OutputInfluxDBFlag)
+	}
+
+	precisionAsStr, err := flags.GetString(OutputInfluxPrecisionFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	precision, err := ingestionConfig.ParsePrecisionString(precisionAsStr)
+	if err != nil {
+		return nil, err
+	}
+
+	retryInterval, err := flags.GetDuration(OutputInfluxRetryFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	if retryInterval < 0 {
+		return nil, fmt.Errorf("value for the '%s' flag can't be negative", OutputInfluxRetryFlag)
+	}
+
+	if args.OutputSchemaStrategy == schemaconfig.ValidateOnly {
+		return nil, fmt.Errorf("the '%s' schema strategy can't be used with the '%s' flag, there are no tables to validate", args.OutputSchemaStrategy, OutputInfluxFlag)
+	}
+
+	if args.OutputSchema != "" {
+		return nil, fmt.Errorf("the '%s' flag can't be used with the '%s' flag, InfluxDB has no schemas", OutputSchemaFlag, OutputInfluxFlag)
+	}
+
+	if args.RetentionPolicyMapping == schemaconfig.RPToSchema {
+		return nil, fmt.Errorf("the '%s' retention policy mapping can't be used with the '%s' flag, InfluxDB has no schemas", args.RetentionPolicyMapping, OutputInfluxFlag)
+	}
+
+	postgresFlags := append([]outputFlag{
+		{name: CommitStrategyFlag, set: args.CommitStrategy == ingestionConfig.CommitOnEnd},
+		{name: TimeFormatFlag, set: args.TimeFormat != schemaconfig.Timestamptz},
+	}, timescaleFlags(args)...)
+	for _, postgresFlag := range postgresFlags {
+		if postgresFlag.set {
+			return nil, fmt.Errorf("the '%s' flag can't be used with the '%s' flag, it only applies to PostgreSQL", postgresFlag.name, OutputInfluxFlag)
+		}
+	}
+
+	rp, err := flags.GetString(OutputInfluxRPFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := flags.GetString(OutputInfluxUserFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	pass, err := flags.GetString(OutputInfluxPassFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	retries, err := flags.GetUint(OutputInfluxRetriesFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ingestionConfig.InfluxOutput{
+		Server:          server,
+		Username:        user,
+		Password:        pass,
+		Database:        database,
+		RetentionPolicy: rp,
+		Precision:       precision,
+		Retries:         retries,
+		RetryInterval:   retryInterval,
+	}, nil
+}
+
+// parseOutputType returns the type of the output database, and the interval of the partitions of the tables
+// created in plain PostgreSQL. The flags that only apply to hypertables can't be used with plain PostgreSQL
+func parseOutputType(flags *pflag.FlagSet, connectionArgs *cli.ConnectionConfig, args *cli.MigrationConfig) (schemaconfig.OutputType, time.Duration, error) {
+	if !registered(flags, OutputTypeFlag) {
+		return DefaultOutputType, 0, nil
+	}
+
+	outputTypeAsStr, err := flags.GetString(OutputTypeFlag)
+	if err != nil {
+		return DefaultOutputType, 0, err
+	}
+
+	outputType, err := schemaconfig.ParseOutputTypeString(outputTypeAsStr)
+	if err != nil {
+		return outputType, 0, err
+	}
+
+	if outputType != schemaconfig.PostgreSQLOutput {
+		if flags.Changed(PartitionIntervalFlag) {
+			return outputType, 0, fmt.Errorf("the '%s' flag can only be used with the '%s' output type", PartitionIntervalFlag, schemaconfig.PostgreSQLOutput)
+		}
+
+		return outputType, 0, nil
+	}
+
+	if args.FileOutput != nil {
+		return outputType, 0, fmt.Errorf("the '%s' output type can't be used with the '%s' flag", outputType, OutputDirFlag)
+	}
+
+	if args.ScriptOutput != nil {
+		return outputType, 0, fmt.Errorf("the '%s' output type can't be used with the '%s' flag", outputType, OutputScriptFlag)
+	}
+
+	if args.InfluxOutput != nil {
+		return outputType, 0, fmt.Errorf("the '%s' output type can't be used with the '%s' flag", outputType, OutputInfluxFlag)
+	}
+
+	interval, err := flags.GetDuration(PartitionIntervalFlag)
+	if err != nil || interval < time.Second || interval%time.Second != 0 {
+		return outputType, 0, fmt.Errorf("value for the '%s' flag must be a duration of whole seconds > 0", PartitionIntervalFlag)
+	}
+
+	hypertableFlags := []struct {
+		flag string
+		set  bool
+	}{
+		{ChunkTimeIntervalFlag, args.ChunkTimeInterval != ""},
+		{AutoChunkTimeIntervalFlag, args.AutoChunkTimeInterval},
+		{CompressFlag, args.Compression != nil},
+		{SpacePartitioningFlag, len(args.MeasureSpacePartitioning) > 0},
+		{AddRetentionPolicyFlag, args.AddRetentionPolicy},
+	}
+	for _, hypertableFlag := range hypertableFlags {
+		if hypertableFlag.set {
+			return outputType, 0, fmt.Errorf("the '%s' flag can't be used with the '%s' output type, it only applies to hypertables", hypertableFlag.flag, outputType)
+		}
+	}
+
+	// the partitions are created for the migrated time range, only the InfluxQL API of an input server
+	// is asked for the times of the oldest and newest point of a measure
+	readsServerV1 := connectionArgs.InputFile == "" && connectionArgs.InputDataDir == "" && connectionArgs.InputAPI != cli.InputAPIV2
+	if !readsServerV1 && (args.From == "" || args.To == "") && args.OutputSchemaStrategy != schemaconfig.ValidateOnly {
+		return outputType, 0, fmt.Errorf("the '%s' output type requires the '%s' and '%s' flags when not reading from the '%s' input API of an input server", outputType, FromFlag, ToFlag, cli.InputAPIV1)
+	}
+
+	var from, to time.Time
+	if args.From != "" {
+		if from, err = time.Parse(time.RFC3339, args.From); err != nil {
+			return outputType, 0, fmt.Errorf("value for the '%s' flag must be an RFC3339 time with the '%s' output type\n%v", FromFlag, outputType, err)
+		}
+	}
+
+	if args.To != "" {
+		if to, err = time.Parse(time.RFC3339, args.To); err != nil {
+			return outputType, 0, fmt.Errorf("value for the '%s' flag must be an RFC3339 time with the '%s' output type\n%v", ToFlag, outputType, err)
+		}
+	}
+
+	if args.From != "" && args.To != "" && !from.Before(to) {
+		return outputType, 0, fmt.Errorf("value for the '%s' flag must be before the value for the '%s' flag", FromFlag, ToFlag)
+	}
+
+	return outputType, interval, nil
+}
+
+// parseWhere validates the condition for all measures, and the conditions for single measures
+// in the where file. The file holds a JSON object like: {"measure1": "condition1", "measure2": "condition2"}
+func parseWhere(flags *pflag.FlagSet) (string, map[string]string, error) {
+	where, _ := flags.GetString(WhereFlag)
+	if where != "" {
+		if _, err := extractionConfig.ParseWhere(where); err != nil {
+			return "", nil, fmt.Errorf("value for the '%s' flag is invalid\n%v", WhereFlag, err)
+		}
+	}
+
+	measureWhere := map[string]string{}
+	whereFile, _ := flags.GetString(WhereFileFlag)
+	if whereFile == "" {
+		return where, measureWhere, nil
+	}
+
+	content, err := ioutil.ReadFile(whereFile)
+	if err != nil {
+		return "", nil, fmt.Errorf("could not read the file of the '%s' flag\n%v", WhereFileFlag, err)
+	}
+
+	if err = json.Unmarshal(content, &measureWhere); err != nil {
+		return "", nil, fmt.Errorf("file '%s' must hold a JSON object of measures and their conditions\n%v", whereFile, err)
+	}
+
+	for measure, condition := range measureWhere {
+		if _, err := extractionConfig.ParseWhere(condition); err != nil {
+			return "", nil, fmt.Errorf("condition for measure '%s' in file '%s' is invalid\n%v", measure, whereFile, err)
+		}
+	}
+
+	return where, measureWhere, nil
+}
+
+// parseIngestionWorkers validates the number of connections inserting the rows of each measure. The batches
+// of several connections are committed separately, so more than one can't be used when committing on end
+func parseIngestionWorkers(flags *pflag.FlagSet, commitStrategy ingestionConfig.CommitStrategy) (uint8, map[string]uint8, error) {
+	workers, err := flags.GetUint8(IngestionWorkersFlag)
+	if err != nil || workers == 0 {
+		return 0, nil, fmt.Errorf("value for the '%s' flag must be an integer > 0 and <= %d", IngestionWorkersFlag, math.MaxUint8)
+	}
+
+	measureWorkers, err := parseMeasureWorkers(flags, MeasureIngestionWorkersFlag)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if commitStrategy != ingestionConfig.CommitOnEnd {
+		return workers, measureWorkers, nil
+	}
+
+	concurrent := workers > 1
+	for _, measureWorkers := range measureWorkers {
+		concurrent = concurrent || measureWorkers > 1
+	}
+
+	if concurrent {
+		return 0, nil, fmt.Errorf("more than one ingestion worker can't be used with the '%s' commit strategy, each worker commits its own batches", commitStrategy)
+	}
+
+	return workers, measureWorkers, nil
+}
+
+func parseMeasureWorkers(flags *pflag.FlagSet, flag string) (map[string]uint8, error) {
+	asInts, err := flags.GetStringToInt(flag)
+	if err != nil {
+		return nil, fmt.Errorf("value for the '%s' flag must be formatted as measure1=workers1,measure2=workers2\n%v", flag, err)
+	}
+
+	measureWorkers := make(map[string]uint8, len(asInts))
+	for measure, workers := range asInts {
+		if workers <= 0 || workers > math.MaxUint8 {
+			return nil, fmt.Errorf("workers for measure '%s' in the '%s' flag must be > 0 and <= %d", measure, flag, math.MaxUint8)
+		}
+
+		measureWorkers[measure] = uint8(workers)
+	}
+
+	return measureWorkers, nil
+}
+
+// parseIndexes returns whether indexes are created once the data of a measure is loaded, and the indexed
+// tag columns if they were given. Nil columns index all tags of each measure
+func parseIndexes(flags *pflag.FlagSet) (bool, []string, error) {
+	createIndexes, _ := flags.GetBool(CreateIndexesFlag)
+	if !flags.Changed(IndexColumnsFlag) {
+		return createIndexes, nil, nil
+	}
+
+	if !createIndexes {
+		return false, nil, fmt.Errorf("the '%s' flag can only be used with the '%s' flag", IndexColumnsFlag, CreateIndexesFlag)
+	}
+
+	columnsAsStr, _ := flags.GetString(IndexColumnsFlag)
+	columns := []string{}
+	for _, column := range strings.Split(columnsAsStr, ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			columns = append(columns, column)
+		}
+	}
+
+	return true, columns, nil
+}
+
+// parseSpacePartitioning returns the column and number of partitions the hypertable of each measure is hash
+// partitioned by. The flag is formatted as measure1=column1:partitions1,measure2=column2:partitions2
+func parseSpacePartitioning(flags *pflag.FlagSet) (map[string]*schemaconfig.SpacePartitioning, error) {
+	asStrings, err := flags.GetStringToString(SpacePartitioningFlag)
+	if err != nil {
+		return nil, fmt.Errorf("value for the '%s' flag must be formatted as measure1=column1:partitions1,measure2=column2:partitions2\n%v", SpacePartitioningFlag, err)
+	}
+
+	measurePartitioning := make(map[string]*schemaconfig.SpacePartitioning, len(asStrings))
+	for measure, partitioningAsStr := range asStrings {
+		partitioning, err := schemaconfig.ParseSpacePartitioningString(partitioningAsStr)
+		if err != nil {
+			return nil, fmt.Errorf("value for measure '%s' in the '%s' flag is invalid\n%v", measure, SpacePartitioningFlag, err)
+		}
+
+		measurePartitioning[measure] = partitioning
+	}
+
+	return measurePartitioning, nil
+}
+
+// parseAutoChunkTimeInterval returns whether the chunk_time_interval of each hypertable is sized from the point density
+// of its measure, the target chunk size in bytes and the sampled window. The density is counted by InfluxDB, so it can
+// only be sampled from the InfluxQL API of an input server, and not from aggregates of the points
+func parseAutoChunkTimeInterval(flags *pflag.FlagSet, connectionArgs *cli.ConnectionConfig, downsampling *extractionConfig.Downsampling) (bool, uint64, time.Duration, error) {
+	if !registered(flags, AutoChunkTimeIntervalFlag) {
+		return false, 0, 0, nil
+	}
+
+	autoChunkTimeInterval, err := flags.GetBool(AutoChunkTimeIntervalFlag)
+	if err != nil {
+		return false, 0, 0, err
+	}
+
+	if !autoChunkTimeInterval {
+		for _, flag := range []string{ChunkTargetSizeFlag, DensitySampleWindowFlag} {
+			if flags.Changed(flag) {
+				return false, 0, 0, fmt.Errorf("the '%s' flag can only be used with the '%s' flag", flag, AutoChunkTimeIntervalFlag)
+			}
+		}
+
+		return false, 0, 0, nil
+	}
+
+	if flags.Changed(ChunkTimeIntervalFlag) {
+		return false, 0, 0, fmt.Errorf("the '%s' flag can't be used with the '%s' flag", AutoChunkTimeIntervalFlag, ChunkTimeIntervalFlag)
+	}
+
+	if connectionArgs.InputFile != "" || connectionArgs.InputDataDir != "" || connectionArgs.InputAPI == cli.InputAPIV2 {
+		return false, 0, 0, fmt.Errorf("the '%s' flag can only be used with the '%s' input API of an input server, the point density is counted by InfluxDB", AutoChunkTimeIntervalFlag, cli.InputAPIV1)
+	}
+
+	if downsampling != nil {
+		return false, 0, 0, fmt.Errorf("the '%s' flag can't be used with the '%s' flag, the density of the raw points is not the density of the aggregates", AutoChunkTimeIntervalFlag, DownsampleFlag)
+	}
+
+	targetSizeMB, err := flags.GetUint64(ChunkTargetSizeFlag)
+	if err != nil || targetSizeMB == 0 {
+		return false, 0, 0, fmt.Errorf("value for the '%s' flag must be an integer > 0", ChunkTargetSizeFlag)
+	}
+
+	window, err := flags.GetDuration(DensitySampleWindowFlag)
+	if err != nil || window <= 0 {
+		return false, 0, 0, fmt.Errorf("value for the '%s' flag must be a duration > 0", DensitySampleWindowFlag)
+	}
+
+	return true, targetSizeMB * 1024 * 1024, window, nil
+}
+
+// parseAddRetentionPolicy checks that the retention policy can be read, only the InfluxQL API of an input server has it
+func parseAddRetentionPolicy(flags *pflag.FlagSet, connectionArgs *cli.ConnectionConfig) (bool, error) {
+	addRetentionPolicy, _ := flags.GetBool(AddRetentionPolicyFlag)
+	if !addRetentionPolicy {
+		return false, nil
+	}
+
+	if connectionArgs.InputFile != "" || connectionArgs.InputDataDir != "" {
+		return false, fmt.Errorf("the '%s' flag can't be used with the '%s' or '%s' flags", AddRetentionPolicyFlag, InputFileFlag, InputDataDirFlag)
+	}
+
+	if connectionArgs.InputAPI == cli.InputAPIV2 {
+		return false, fmt.Errorf("the '%s' flag can't be used with the '%s' input API", AddRetentionPolicyFlag, cli.InputAPIV2)
+	}
+
+	return true, nil
+}
+
+// parseRetentionPolicy returns the selected retention policy and how it is mapped to the output database.
+// All retention policies can only be read from an input server, and must be kept apart by a mapping
+func parseRetentionPolicy(flags *pflag.FlagSet, connectionArgs *cli.ConnectionConfig) (string, schemaconfig.RetentionPolicyMapping, error) {
+	rp, _ := flags.GetString(RetentionPolicyFlag)
+	mappingAsStr, _ := flags.GetString(RetentionPolicyMappingFlag)
+	mapping, err := schemaconfig.ParseRetentionPolicyMappingString(mappingAsStr)
+	if err != nil {
+		return "", mapping, err
+	}
+
+	outputSchema, _ := flags.GetString(OutputSchemaFlag)
+	if mapping == schemaconfig.RPToSchema && strings.Contains(outputSchema, cli.DatabasePlaceholder) {
+		return "", mapping, fmt.Errorf("the '%s' flag can't contain '%s' with the '%s' retention policy mapping, the schema is named after the retention policy", OutputSchemaFlag, cli.DatabasePlaceholder, mapping)
+	}
+
+	if rp != cli.AllRetentionPolicies {
+		return rp, mapping, nil
+	}
+
+	if mapping == schemaconfig.NoRPMapping {
+		return "", mapping, fmt.Errorf("migrating all retention policies requires the '%s' flag, so the measures of different retention policies don't collide", RetentionPolicyMappingFlag)
+	}
+
+	if connectionArgs.InputFile != "" || connectionArgs.InputDataDir != "" || connectionArgs.InputAPI == cli.InputAPIV2 {
+		return "", mapping, fmt.Errorf("all retention policies can only be read with the '%s' input API of an input server", cli.InputAPIV1)
+	}
+
+	return rp, mapping, nil
+}
+
+// parseDownsampling returns the downsampling config of the resolutions in the downsample flag, or nil if none was given.
+// Each resolution is formatted as interval[:older-than], e.g. '1h:720h' aggregates the points older than 30 days to 1h
+func parseDownsampling(flags *pflag.FlagSet, connectionArgs *cli.ConnectionConfig) (*extractionConfig.Downsampling, error) {
+	if !registered(flags, DownsampleFlag) {
+		return nil, nil
+	}
+
+	resolutionsAsStr, err := flags.GetStringArray(DownsampleFlag)
+	if err != nil || len(resolutionsAsStr) == 0 {
+		return nil, err
+	}
+
+	if connectionArgs.InputFile != "" || connectionArgs.InputDataDir != "" || connectionArgs.InputConn != "" || connectionArgs.InputAPI == cli.InputAPIV2 {
+		return nil, fmt.Errorf("the '%s' flag can only be used with the '%s' input API of an input server, the aggregates are computed by InfluxDB", DownsampleFlag, cli.InputAPIV1)
+	}
+
+	resolutions := make([]extractionConfig.Resolution, len(resolutionsAsStr))
+	for i, resolutionAsStr := range resolutionsAsStr {
+		parts := strings.SplitN(resolutionAsStr, ":", 2)
+		interval, err := time.ParseDuration(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("value '%s' of the '%s' flag must be formatted as interval[:older-than], e.g. 1h:720h\n%v", resolutionAsStr, DownsampleFlag, err)
+		}
+
+		resolutions[i].Interval = interval
+		if len(parts) == 1 {
+			continue
+		}
+
+		if resolutions[i].OlderThan, err = time.ParseDuration(parts[1]); err != nil {
+			return nil, fmt.Errorf("value '%s' of the '%s' flag must be formatted as interval[:older-than], e.g. 1h:720h\n%v", resolutionAsStr, DownsampleFlag, err)
+		}
+	}
+
+	aggregatesAsStr, err := flags.GetString(DownsampleAggregatesFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	aggregates := make(map[string]string)
+	for _, pair := range strings.Split(aggregatesAsStr, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("value for the '%s' flag must be formatted as type1=aggregate1,type2=aggregate2", DownsampleAggregatesFlag)
+		}
+
+		aggregates[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	downsampling, err := extractionConfig.NewDownsampling(resolutions, aggregates, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("values of the '%s' and '%s' flags are invalid\n%v", DownsampleFlag, DownsampleAggregatesFlag, err)
+	}
+
+	return downsampling, nil
+}
+
+// parseCompression returns the compression settings of the created hypertables, or nil if the compress flag is not set.
+// Without the segmentby flag the hypertables are segmented by their tag columns, optionally only by the tags with a
+// cardinality up to a limit, which can only be read from the InfluxQL API of an input server
+func parseCompression(flags *pflag.FlagSet, connectionArgs *cli.ConnectionConfig) (*schemaconfig.Compression, bool, uint64, error) {
+	if !registered(flags, CompressFlag) {
+		return nil, false, 0, nil
+	}
+
+	compress, err := flags.GetBool(CompressFlag)
+	if err != nil {
+		return nil, false, 0, err
+	}
+
+	if !compress {
+		for _, flag := range []string{CompressSegmentByFlag, CompressOrderByFlag, CompressMaxCardinalityFlag, CompressAfterFlag} {
+			if flags.Changed(flag) {
+				return nil, false, 0, fmt.Errorf("the '%s' flag can only be used with the '%s' flag", flag, CompressFlag)
+			}
+		}
+
+		return nil, false, 0, nil
+	}
+
+	orderBy, err := flags.GetString(CompressOrderByFlag)
+	if err != nil {
+		return nil, false, 0, err
+	}
+
+	compressAfter, err := flags.GetDuration(CompressAfterFlag)
+	if err != nil || compressAfter < 0 {
+		return nil, false, 0, fmt.Errorf("value for the '%s' flag must be a duration >= 0", CompressAfterFlag)
+	}
+
+	maxTagCardinality, err := flags.GetUint64(CompressMaxCardinalityFlag)
+	if err != nil {
+		return nil, false, 0, fmt.Errorf("value for the '%s' flag must be an integer >= 0", CompressMaxCardinalityFlag)
+	}
+
+	compression := &schemaconfig.Compression{OrderBy: strings.TrimSpace(orderBy), CompressAfter: compressAfter}
+	if flags.Changed(CompressSegmentByFlag) {
+		if maxTagCardinality > 0 {
+			return nil, false, 0, fmt.Errorf("the '%s' flag can't be used with the '%s' flag, it selects the segmentby tags", CompressMaxCardinalityFlag, CompressSegmentByFlag)
+		}
+
+		segmentBy, err := flags.GetString(CompressSegmentByFlag)
+		if err != nil {
+			return nil, false, 0, err
+		}
+
+		for _, column := range strings.Split(segmentBy, ",") {
+			if column = strings.TrimSpace(column); column != "" {
+				compression.SegmentBy = append(compression.SegmentBy, column)
+			}
+		}
+
+		return compression, false, 0, nil
+	}
+
+	if maxTagCardinality > 0 && (connectionArgs.InputFile != "" || connectionArgs.InputDataDir != "" || connectionArgs.InputAPI == cli.InputAPIV2) {
+		return nil, false, 0, fmt.Errorf("the '%s' flag can only be used with the '%s' input API of an input server, the cardinality is computed by InfluxDB", CompressMaxCardinalityFlag, cli.InputAPIV1)
+	}
+
+	return compression, true, maxTagCardinality, nil
+}
+
+// registered returns whether the flag is registered for the command, the output and
+// hypertable flags are only registered for some commands
+func registered(flags *pflag.FlagSet, name string) bool {
+	return flags.Lookup(name) != nil
+}
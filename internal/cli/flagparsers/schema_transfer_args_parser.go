@@ -15,13 +15,23 @@ func FlagsToSchemaTransferConfig(flags *pflag.FlagSet, args []string) (*cli.Conn
 		return nil, nil, err
 	}
 
-	retentionPolicy, _ := flags.GetString(RetentionPolicyFlag)
+	retentionPolicy, rpMapping, err := parseRetentionPolicy(flags, connectionArgs)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	strategyAsStr, _ := flags.GetString(SchemaStrategyFlag)
 	var strategy schemaconfig.SchemaStrategy
 	if strategy, err = schemaconfig.ParseStrategyString(strategyAsStr); err != nil {
 		return nil, nil, err
 	}
 
+	timeFormatAsStr, _ := flags.GetString(TimeFormatFlag)
+	var timeFormat schemaconfig.TimeFormat
+	if timeFormat, err = schemaconfig.ParseTimeFormatString(timeFormatAsStr); err != nil {
+		return nil, nil, err
+	}
+
 	tagsAsJSON, _ := flags.GetBool(TagsAsJSONFlag)
 	tagsColumn, _ := flags.GetString(TagsColumnFlag)
 	if tagsAsJSON && tagsColumn == "" {
@@ -41,6 +51,31 @@ func FlagsToSchemaTransferConfig(flags *pflag.FlagSet, args []string) (*cli.Conn
 	outputSchema, _ := flags.GetString(OutputSchemaFlag)
 	intToFloat, _ := flags.GetBool(MultishardIntFloatCast)
 	chunkTimeInterval, _ := flags.GetString(ChunkTimeIntervalFlag)
+	addRetentionPolicy, err := parseAddRetentionPolicy(flags, connectionArgs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	downsampling, err := parseDownsampling(flags, connectionArgs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	compression, segmentByTags, maxTagCardinality, err := parseCompression(flags, connectionArgs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	spacePartitioning, err := parseSpacePartitioning(flags)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	autoChunkTimeInterval, chunkTargetSize, densityWindow, err := parseAutoChunkTimeInterval(flags, connectionArgs, downsampling)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	return connectionArgs, &cli.MigrationConfig{
 		RetentionPolicy:             retentionPolicy,
 		OutputSchema:                outputSchema,
@@ -54,5 +89,16 @@ func FlagsToSchemaTransferConfig(flags *pflag.FlagSet, args []string) (*cli.Conn
 		FieldsCol:                   fieldsColumn,
 		OnConflictConvertIntToFloat: intToFloat,
 		ChunkTimeInterval:           chunkTimeInterval,
+		TimeFormat:                  timeFormat,
+		AddRetentionPolicy:          addRetentionPolicy,
+		RetentionPolicyMapping:      rpMapping,
+		Downsampling:                downsampling,
+		Compression:                 compression,
+		CompressSegmentByTags:       segmentByTags,
+		CompressMaxTagCardinality:   maxTagCardinality,
+		MeasureSpacePartitioning:    spacePartitioning,
+		AutoChunkTimeInterval:       autoChunkTimeInterval,
+		ChunkTargetSize:             chunkTargetSize,
+		DensitySampleWindow:         densityWindow,
 	}, nil
 }
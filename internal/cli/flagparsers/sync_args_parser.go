@@ -0,0 +1,66 @@
+package flagparsers
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+	"github.com/timescale/outflux/internal/cli"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+)
+
+// FlagsToSyncConfig extracts the config for continuously replicating data from the flags of the command.
+// Each sync cycle is a migration that resumes from the recorded checkpoints
+func FlagsToSyncConfig(flags *pflag.FlagSet, args []string) (*cli.ConnectionConfig, *cli.MigrationConfig, *cli.SyncConfig, error) {
+	connectionArgs, migrateArgs, err := FlagsToMigrateConfig(flags, args)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if connectionArgs.InputFile != "" {
+		return nil, nil, nil, fmt.Errorf("the '%s' flag can't be used when syncing", InputFileFlag)
+	}
+
+	if connectionArgs.InputDataDir != "" {
+		return nil, nil, nil, fmt.Errorf("the '%s' flag can't be used when syncing", InputDataDirFlag)
+	}
+
+	if migrateArgs.To != "" {
+		return nil, nil, nil, fmt.Errorf("the '%s' flag can't be used when syncing", ToFlag)
+	}
+
+	if migrateArgs.Limit != 0 {
+		return nil, nil, nil, fmt.Errorf("the '%s' flag can't be used when syncing", LimitFlag)
+	}
+
+	if connectionArgs.AllDatabases || len(connectionArgs.InputDatabases) > 1 {
+		return nil, nil, nil, fmt.Errorf("several databases can't be synced, a single database must be given")
+	}
+
+	if migrateArgs.RetentionPolicy == cli.AllRetentionPolicies {
+		return nil, nil, nil, fmt.Errorf("all retention policies can't be synced, a single retention policy must be selected")
+	}
+
+	if migrateArgs.RetentionPolicyMapping == schemaconfig.RPToColumn {
+		return nil, nil, nil, fmt.Errorf("the '%s' retention policy mapping can't be used when syncing", migrateArgs.RetentionPolicyMapping)
+	}
+
+	strategy := migrateArgs.OutputSchemaStrategy
+	if strategy == schemaconfig.DropAndCreate || strategy == schemaconfig.DropCascadeAndCreate {
+		return nil, nil, nil, fmt.Errorf("the '%s' schema strategy can't be used when syncing", strategy)
+	}
+
+	interval, err := flags.GetDuration(SyncIntervalFlag)
+	if err != nil || interval <= 0 {
+		return nil, nil, nil, fmt.Errorf("value for the '%s' flag must be a positive duration", SyncIntervalFlag)
+	}
+
+	overlap, err := flags.GetDuration(SyncOverlapFlag)
+	if err != nil || overlap < 0 {
+		return nil, nil, nil, fmt.Errorf("value for the '%s' flag must be a duration >= 0", SyncOverlapFlag)
+	}
+
+	drain, _ := flags.GetBool(SyncDrainFlag)
+	migrateArgs.Resume = true
+	migrateArgs.ResumeOverlap = overlap
+	return connectionArgs, migrateArgs, &cli.SyncConfig{Interval: interval, Drain: drain}, nil
+}
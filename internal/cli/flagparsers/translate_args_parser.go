@@ -0,0 +1,58 @@
+package flagparsers
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+	"github.com/timescale/outflux/internal/cli"
+)
+
+// FlagsToTranslateConfig extracts the config for translating InfluxQL queries or a Grafana dashboard
+// from the flags of the command. The layout flags must match the ones the data was migrated with
+func FlagsToTranslateConfig(flags *pflag.FlagSet, args []string) (*cli.TranslateConfig, error) {
+	quiet, err := flags.GetBool(QuietFlag)
+	if err != nil {
+		return nil, fmt.Errorf("value for the '%s' flag must be a true or false", QuietFlag)
+	}
+
+	tagsAsJSON, _ := flags.GetBool(TagsAsJSONFlag)
+	tagsColumn, _ := flags.GetString(TagsColumnFlag)
+	if tagsAsJSON && tagsColumn == "" {
+		return nil, fmt.Errorf("When the '%s' flag is set, the '%s' must also have a value", TagsAsJSONFlag, TagsColumnFlag)
+	}
+
+	fieldsAsJSON, _ := flags.GetBool(FieldsAsJSONFlag)
+	fieldsColumn, _ := flags.GetString(FieldsColumnFlag)
+	if fieldsAsJSON && fieldsColumn == "" {
+		return nil, fmt.Errorf("When the '%s' flag is set, the '%s' must also have a value", FieldsAsJSONFlag, FieldsColumnFlag)
+	}
+
+	if !tagsAsJSON {
+		tagsColumn = ""
+	}
+
+	if !fieldsAsJSON {
+		fieldsColumn = ""
+	}
+
+	dashboard, _ := flags.GetString(DashboardFlag)
+	datasource, _ := flags.GetString(DatasourceFlag)
+	if dashboard != "" && len(args) > 0 {
+		return nil, fmt.Errorf("queries can't be specified together with the '%s' flag", DashboardFlag)
+	}
+
+	if dashboard != "" && datasource == "" {
+		return nil, fmt.Errorf("the '%s' flag must specify the PostgreSQL data source of the rewritten dashboard", DatasourceFlag)
+	}
+
+	outputSchema, _ := flags.GetString(OutputSchemaFlag)
+	return &cli.TranslateConfig{
+		Queries:      args,
+		OutputSchema: outputSchema,
+		TagsColumn:   tagsColumn,
+		FieldsColumn: fieldsColumn,
+		Dashboard:    dashboard,
+		Datasource:   datasource,
+		Quiet:        quiet,
+	}, nil
+}
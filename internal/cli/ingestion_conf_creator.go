@@ -3,6 +3,7 @@ package cli
 import (
 	"fmt"
 
+	"github.com/timescale/outflux/internal/checkpoint"
 	"github.com/timescale/outflux/internal/ingestion/config"
 )
 
@@ -11,13 +12,26 @@ const (
 )
 
 type ingestionConfCreator interface {
-	create(pipeID string, conf *MigrationConfig) *config.IngestorConfig
+	create(pipeID, db, measure string, conf *MigrationConfig) *config.IngestorConfig
 }
 
 type defaultIngestionConfCreator struct {
 }
 
-func (s *defaultIngestionConfCreator) create(pipeID string, conf *MigrationConfig) *config.IngestorConfig {
+func (s *defaultIngestionConfCreator) create(pipeID, db, measure string, conf *MigrationConfig) *config.IngestorConfig {
+	var checkpointKey *checkpoint.Key
+	if !conf.SchemaOnly {
+		checkpointKey = &checkpoint.Key{Database: db, RetentionPolicy: conf.RetentionPolicy, Measure: measure}
+	}
+
+	var scriptOutput *config.ScriptOutput
+	if conf.ScriptOutput != nil {
+		// the schema named after the retention policy or the input database is created by the script
+		output := *conf.ScriptOutput
+		output.CreateSchema = conf.CreateOutputSchema
+		scriptOutput = &output
+	}
+
 	return &config.IngestorConfig{
 		IngestorID:              fmt.Sprintf(ingestorIDTemplate, pipeID),
 		BatchSize:               conf.BatchSize,
@@ -25,6 +39,17 @@ func (s *defaultIngestionConfCreator) create(pipeID string, conf *MigrationConfi
 		CommitStrategy:          conf.CommitStrategy,
 		SchemaStrategy:          conf.OutputSchemaStrategy,
 		Schema:                  conf.OutputSchema,
-		ChunkTimeInterval:       conf.ChunkTimeInterval,
+		ChunkTimeInterval:       conf.MeasureChunkTimeInterval(measure),
+		RetentionPeriod:         conf.RetentionPeriod,
+		Compression:             conf.MeasureCompression(measure),
+		SpacePartitioning:       conf.MeasureSpacePartitioning[measure],
+		Indexes:                 conf.MeasureIndexes(measure),
+		CheckpointKey:           checkpointKey,
+		OnConflict:              conf.OnConflict,
+		ConflictColumns:         conf.MeasureConflictColumns(measure),
+		FileOutput:              conf.FileOutput,
+		RangePartitioning:       conf.MeasureRangePartitioning(measure),
+		ScriptOutput:            scriptOutput,
+		InfluxOutput:            conf.MeasureInfluxOutput(measure),
 	}
 }
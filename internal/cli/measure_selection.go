@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// MeasureSelection narrows down the measures to transfer with regular expressions on their names.
+// The zero value selects all measures
+type MeasureSelection struct {
+	Include []*regexp.Regexp
+	Exclude []*regexp.Regexp
+}
+
+// Select returns the measures that match at least one include pattern (if any were given) and
+// no exclude pattern, without duplicates and in their original order. A pattern that matches
+// none of the measures is an error, it is most likely a typo
+func (s *MeasureSelection) Select(measures []string) ([]string, error) {
+	if err := checkPatternsMatch(s.Include, measures, "include"); err != nil {
+		return nil, err
+	}
+
+	if err := checkPatternsMatch(s.Exclude, measures, "exclude"); err != nil {
+		return nil, err
+	}
+
+	selected := []string{}
+	seen := make(map[string]bool)
+	for _, measure := range measures {
+		if seen[measure] {
+			continue
+		}
+
+		seen[measure] = true
+		if len(s.Include) > 0 && !anyMatches(s.Include, measure) {
+			continue
+		}
+
+		if anyMatches(s.Exclude, measure) {
+			continue
+		}
+
+		selected = append(selected, measure)
+	}
+
+	return selected, nil
+}
+
+func checkPatternsMatch(patterns []*regexp.Regexp, measures []string, kind string) error {
+	for _, pattern := range patterns {
+		if !matchesAnyMeasure(pattern, measures) {
+			return fmt.Errorf("%s pattern '%s' matches none of the %d measures", kind, pattern, len(measures))
+		}
+	}
+
+	return nil
+}
+
+func matchesAnyMeasure(pattern *regexp.Regexp, measures []string) bool {
+	for _, measure := range measures {
+		if pattern.MatchString(measure) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func anyMatches(patterns []*regexp.Regexp, measure string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(measure) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectMeasures(t *testing.T) {
+	measures := []string{"cpu", "cpu_load", "mem", "disk", "cpu"}
+	testCases := []struct {
+		desc      string
+		selection MeasureSelection
+		expected  []string
+		expectErr bool
+	}{
+		{
+			desc:     "zero value selects all, without duplicates",
+			expected: []string{"cpu", "cpu_load", "mem", "disk"},
+		}, {
+			desc:      "include",
+			selection: MeasureSelection{Include: []*regexp.Regexp{regexp.MustCompile("^cpu"), regexp.MustCompile("^disk$")}},
+			expected:  []string{"cpu", "cpu_load", "disk"},
+		}, {
+			desc:      "exclude",
+			selection: MeasureSelection{Exclude: []*regexp.Regexp{regexp.MustCompile("_load$")}},
+			expected:  []string{"cpu", "mem", "disk"},
+		}, {
+			desc: "exclude applied after include",
+			selection: MeasureSelection{
+				Include: []*regexp.Regexp{regexp.MustCompile("^cpu")},
+				Exclude: []*regexp.Regexp{regexp.MustCompile("load")},
+			},
+			expected: []string{"cpu"},
+		}, {
+			desc:      "include pattern that matches nothing",
+			selection: MeasureSelection{Include: []*regexp.Regexp{regexp.MustCompile("^cpu"), regexp.MustCompile("^swap")}},
+			expectErr: true,
+		}, {
+			desc:      "exclude pattern that matches nothing",
+			selection: MeasureSelection{Exclude: []*regexp.Regexp{regexp.MustCompile("^swap")}},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		selected, err := tc.selection.Select(measures)
+		if tc.expectErr {
+			assert.Error(t, err, tc.desc)
+			continue
+		}
+
+		assert.NoError(t, err, tc.desc)
+		assert.Equal(t, tc.expected, selected, tc.desc)
+	}
+}
@@ -1,10 +1,22 @@
 package cli
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/timescale/outflux/internal/extraction/config"
 	ingestionConf "github.com/timescale/outflux/internal/ingestion/config"
 	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+	"github.com/timescale/outflux/internal/transformation/rpmapping"
+	"github.com/timescale/outflux/internal/transformation/timeformat"
 )
 
+// AllRetentionPolicies selects every retention policy of the input database
+const AllRetentionPolicies = "all"
+
+// the time column of the extracted data sets
+const timeColumn = "time"
+
 // MigrationConfig contains the configurable parameters for migrating an InfluxDB to TimescaleDB
 type MigrationConfig struct {
 	RetentionPolicy                      string
@@ -27,4 +39,174 @@ type MigrationConfig struct {
 	FieldsCol                            string
 	OnConflictConvertIntToFloat          bool
 	ChunkTimeInterval                    string
+	Resume                               bool
+	ResumeOverlap                        time.Duration
+	ExtractionWorkers                    uint8
+	MeasureExtractionWorkers             map[string]uint8
+	ExtractionWindow                     time.Duration
+	TimeFormat                           schemaconfig.TimeFormat
+	Where                                string
+	MeasureWhere                         map[string]string
+	// AddRetentionPolicy maps the duration of the retention policy to a retention policy of the created hypertables,
+	// and its shard group duration to their chunk_time_interval if none was given
+	AddRetentionPolicy bool
+	// RetentionPolicyMapping keeps the data of a retention policy apart from the other retention policies
+	RetentionPolicyMapping schemaconfig.RetentionPolicyMapping
+	// CreateOutputSchema is set when the output schema is named after the retention policy or the input database,
+	// and it is created before the migration if it doesn't exist
+	CreateOutputSchema bool
+	// RetentionPeriod is the duration of the retention policy, set before the migration when AddRetentionPolicy is true
+	RetentionPeriod time.Duration
+	// Downsampling extracts the GROUP BY time() aggregates of the fields instead of the raw points, nil extracts all points raw
+	Downsampling *config.Downsampling
+	// Compression enables the compression of the created hypertables, nil leaves them uncompressed
+	Compression *schemaconfig.Compression
+	// CompressSegmentByTags segments the compressed hypertables by their tag columns instead of the columns in Compression
+	CompressSegmentByTags bool
+	// CompressMaxTagCardinality if > 0, only the tags with at most this many values segment the compressed hypertables
+	CompressMaxTagCardinality uint64
+	// MeasureSegmentBy holds the tag columns each hypertable is segmented by, set before the migration when CompressSegmentByTags is true
+	MeasureSegmentBy map[string][]string
+	// MeasureSpacePartitioning holds the column and number of partitions the hypertable of a measure is
+	// hash partitioned by, in addition to the time column
+	MeasureSpacePartitioning map[string]*schemaconfig.SpacePartitioning
+	// CreateIndexes indexes the tag columns and the JSONB columns of each hypertable once the data of its measure is loaded
+	CreateIndexes bool
+	// IndexColumns are the indexed tag columns of all measures, nil indexes all tag columns of each measure
+	IndexColumns []string
+	// MeasureIndexedTags holds the tag columns of each measure, set before the migration when CreateIndexes is true and IndexColumns is nil
+	MeasureIndexedTags map[string][]string
+	// AutoChunkTimeInterval sizes the chunk_time_interval of each hypertable from the point density of its measure
+	AutoChunkTimeInterval bool
+	// ChunkTargetSize is the size in bytes of the chunks the chunk_time_interval is sized for
+	ChunkTargetSize uint64
+	// DensitySampleWindow is the time window before the newest point of a measure its point density is sampled in
+	DensitySampleWindow time.Duration
+	// MeasureChunkTimeIntervals holds the chunk_time_interval of each hypertable, set before the migration when
+	// AutoChunkTimeInterval is true. A measure without points keeps ChunkTimeInterval
+	MeasureChunkTimeIntervals map[string]string
+	// OnConflict selects how rows with the time and tags of an existing row are handled
+	OnConflict ingestionConf.ConflictStrategy
+	// MeasureKeyTags holds the tag columns of each measure, set before the migration when OnConflict upserts the rows
+	// or InfluxOutput is set
+	MeasureKeyTags map[string][]string
+	// IngestionWorkers is the number of connections inserting the batches of a measure at the same time
+	IngestionWorkers uint8
+	// MeasureIngestionWorkers overrides IngestionWorkers for single measures
+	MeasureIngestionWorkers map[string]uint8
+	// FileOutput if not nil, the rows are written to files in its directory instead of the output database.
+	// The output schema is a directory in it
+	FileOutput *ingestionConf.FileOutput
+	// OutputType selects whether the tables are hypertables of TimescaleDB or range partitioned tables of plain PostgreSQL
+	OutputType schemaconfig.OutputType
+	// PartitionInterval is the interval of time held by each partition of the tables created in plain PostgreSQL
+	PartitionInterval time.Duration
+	// MeasureTimeRanges holds the time range of each measure its partitions are created for, set before the migration
+	// when OutputType is PostgreSQL. A measure without points has no time range
+	MeasureTimeRanges map[string]*schemaconfig.TimeRange
+	// ScriptOutput if not nil, the rows are written to a SQL script instead of the output database
+	ScriptOutput *ingestionConf.ScriptOutput
+	// InfluxOutput if not nil, the rows are written as points to InfluxDB instead of the output database
+	InfluxOutput *ingestionConf.InfluxOutput
+}
+
+// MeasureConflictColumns returns the columns besides the time column that identify a point of a measure, or nil if the
+// rows are not upserted: the nanoseconds of the time, the retention policy column, and the tag columns or their JSONB column
+func (m *MigrationConfig) MeasureConflictColumns(measure string) []string {
+	if !m.OnConflict.Upserts() {
+		return nil
+	}
+
+	columns := []string{}
+	if m.TimeFormat == schemaconfig.TimestamptzWithNanos {
+		columns = append(columns, fmt.Sprintf(timeformat.NanosColumnTemplate, timeColumn))
+	}
+
+	if m.RetentionPolicyMapping == schemaconfig.RPToColumn {
+		columns = append(columns, rpmapping.ColumnName)
+	}
+
+	if m.TagsAsJSON {
+		return append(columns, m.TagsCol)
+	}
+
+	return append(columns, m.MeasureKeyTags[measure]...)
+}
+
+// MeasureIngestionWorkerCount returns the number of connections inserting the batches of a measure
+func (m *MigrationConfig) MeasureIngestionWorkerCount(measure string) uint8 {
+	if workers, ok := m.MeasureIngestionWorkers[measure]; ok {
+		return workers
+	}
+
+	return m.IngestionWorkers
+}
+
+// MeasureChunkTimeInterval returns the chunk_time_interval of the hypertable a measure is migrated to
+func (m *MigrationConfig) MeasureChunkTimeInterval(measure string) string {
+	if interval, ok := m.MeasureChunkTimeIntervals[measure]; ok {
+		return interval
+	}
+
+	return m.ChunkTimeInterval
+}
+
+// MeasureRangePartitioning returns the partitioning of the plain PostgreSQL table a measure is migrated to,
+// or nil if it is migrated to a hypertable
+func (m *MigrationConfig) MeasureRangePartitioning(measure string) *schemaconfig.RangePartitioning {
+	if m.OutputType != schemaconfig.PostgreSQLOutput {
+		return nil
+	}
+
+	return &schemaconfig.RangePartitioning{Interval: m.PartitionInterval, Range: m.MeasureTimeRanges[measure]}
+}
+
+// MeasureIndexes returns the indexes created on the hypertable of a measure once its data is loaded, or nil if none are created
+func (m *MigrationConfig) MeasureIndexes(measure string) *schemaconfig.Indexes {
+	if !m.CreateIndexes {
+		return nil
+	}
+
+	if m.IndexColumns != nil {
+		return &schemaconfig.Indexes{Columns: m.IndexColumns}
+	}
+
+	return &schemaconfig.Indexes{Columns: m.MeasureIndexedTags[measure]}
+}
+
+// MeasureCompression returns the compression of the hypertable a measure is migrated to, or nil if it is not compressed
+func (m *MigrationConfig) MeasureCompression(measure string) *schemaconfig.Compression {
+	if m.Compression == nil || !m.CompressSegmentByTags {
+		return m.Compression
+	}
+
+	compression := *m.Compression
+	compression.SegmentBy = m.MeasureSegmentBy[measure]
+	return &compression
+}
+
+// MeasureInfluxOutput returns the Influx output of a measure with the roles of its columns, or nil if the rows are not
+// written to InfluxDB. The retention policy column and the tag columns are tags, the keys of the JSON column of the
+// tags are tags and the keys of the JSON column of the fields are fields
+func (m *MigrationConfig) MeasureInfluxOutput(measure string) *ingestionConf.InfluxOutput {
+	if m.InfluxOutput == nil {
+		return nil
+	}
+
+	output := *m.InfluxOutput
+	output.TagColumns = []string{}
+	if m.RetentionPolicyMapping == schemaconfig.RPToColumn {
+		output.TagColumns = append(output.TagColumns, rpmapping.ColumnName)
+	}
+
+	output.TagColumns = append(output.TagColumns, m.MeasureKeyTags[measure]...)
+	if m.TagsAsJSON {
+		output.JSONTagsColumn = m.TagsCol
+	}
+
+	if m.FieldsAsJSON {
+		output.JSONFieldsColumn = m.FieldsCol
+	}
+
+	return &output
 }
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	ingestionConf "github.com/timescale/outflux/internal/ingestion/config"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+)
+
+func TestMeasureConflictColumns(t *testing.T) {
+	keyTags := map[string][]string{"cpu": {"host", "region"}}
+	testCases := []struct {
+		desc     string
+		conf     *MigrationConfig
+		expected []string
+	}{
+		{
+			desc: "rows are not upserted",
+			conf: &MigrationConfig{OnConflict: ingestionConf.NoConflictAction, MeasureKeyTags: keyTags},
+		}, {
+			desc:     "tag columns",
+			conf:     &MigrationConfig{OnConflict: ingestionConf.ConflictDoNothing, MeasureKeyTags: keyTags},
+			expected: []string{"host", "region"},
+		}, {
+			desc:     "tags as json",
+			conf:     &MigrationConfig{OnConflict: ingestionConf.ConflictDoUpdate, TagsAsJSON: true, TagsCol: "tags", MeasureKeyTags: keyTags},
+			expected: []string{"tags"},
+		}, {
+			desc: "nanoseconds and retention policy columns",
+			conf: &MigrationConfig{
+				OnConflict:             ingestionConf.ConflictDoUpdate,
+				TimeFormat:             schemaconfig.TimestamptzWithNanos,
+				RetentionPolicyMapping: schemaconfig.RPToColumn,
+				MeasureKeyTags:         keyTags,
+			},
+			expected: []string{"time_ns", "rp", "host", "region"},
+		},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, tc.conf.MeasureConflictColumns("cpu"), tc.desc)
+	}
+}
+
+func TestMeasureIngestionWorkerCount(t *testing.T) {
+	conf := &MigrationConfig{IngestionWorkers: 2, MeasureIngestionWorkers: map[string]uint8{"cpu": 4}}
+	assert.Equal(t, uint8(4), conf.MeasureIngestionWorkerCount("cpu"))
+	assert.Equal(t, uint8(2), conf.MeasureIngestionWorkerCount("mem"))
+}
+
+func TestMeasureRangePartitioning(t *testing.T) {
+	timeRange := &schemaconfig.TimeRange{From: time.Unix(0, 0), To: time.Unix(3600, 0)}
+	conf := &MigrationConfig{OutputType: schemaconfig.TimescaleDBOutput, PartitionInterval: time.Hour, MeasureTimeRanges: map[string]*schemaconfig.TimeRange{"cpu": timeRange}}
+	assert.Nil(t, conf.MeasureRangePartitioning("cpu"))
+
+	conf.OutputType = schemaconfig.PostgreSQLOutput
+	assert.Equal(t, &schemaconfig.RangePartitioning{Interval: time.Hour, Range: timeRange}, conf.MeasureRangePartitioning("cpu"))
+	// a measure without points gets a table without partitions
+	assert.Equal(t, &schemaconfig.RangePartitioning{Interval: time.Hour}, conf.MeasureRangePartitioning("mem"))
+}
+
+func TestMeasureInfluxOutput(t *testing.T) {
+	conf := &MigrationConfig{MeasureKeyTags: map[string][]string{"cpu": {"host"}}}
+	assert.Nil(t, conf.MeasureInfluxOutput("cpu"))
+
+	conf.InfluxOutput = &ingestionConf.InfluxOutput{Server: "http://localhost:8086", Database: "copy"}
+	expected := &ingestionConf.InfluxOutput{Server: "http://localhost:8086", Database: "copy", TagColumns: []string{"host"}}
+	assert.Equal(t, expected, conf.MeasureInfluxOutput("cpu"))
+	// a measure without tags
+	assert.Equal(t, []string{}, conf.MeasureInfluxOutput("mem").TagColumns)
+
+	conf.RetentionPolicyMapping = schemaconfig.RPToColumn
+	conf.TagsAsJSON, conf.TagsCol = true, "tags"
+	conf.FieldsAsJSON, conf.FieldsCol = true, "fields"
+	expected = &ingestionConf.InfluxOutput{
+		Server:           "http://localhost:8086",
+		Database:         "copy",
+		TagColumns:       []string{"rp", "host"},
+		JSONTagsColumn:   "tags",
+		JSONFieldsColumn: "fields",
+	}
+	assert.Equal(t, expected, conf.MeasureInfluxOutput("cpu"))
+	// the output of the migration is not changed
+	assert.Nil(t, conf.InfluxOutput.TagColumns)
+}
@@ -2,13 +2,20 @@ package cli
 
 import (
 	"fmt"
+	"log"
+	"time"
 
 	influx "github.com/influxdata/influxdb/client/v2"
 
+	"github.com/timescale/outflux/internal/checkpoint"
 	"github.com/timescale/outflux/internal/connections"
 	"github.com/timescale/outflux/internal/extraction"
+	extrConfig "github.com/timescale/outflux/internal/extraction/config"
 	"github.com/timescale/outflux/internal/ingestion"
+	ingConfig "github.com/timescale/outflux/internal/ingestion/config"
 	"github.com/timescale/outflux/internal/pipeline"
+	"github.com/timescale/outflux/internal/schemamanagement/tsm/tsmstorage"
+	"github.com/timescale/outflux/internal/transformation"
 )
 
 const (
@@ -17,7 +24,16 @@ const (
 
 // PipeService defines methods for creating pipelines
 type PipeService interface {
-	Create(infConn influx.Client, pgConn connections.PgxWrap, measure, inputDb string, conf *MigrationConfig) (pipeline.Pipe, error)
+	Create(infConn influx.Client, pgConn connections.PgxWrap, workerConns []connections.PgxWrap, measure, inputDb string, conf *MigrationConfig) (pipeline.Pipe, error)
+	// CreateV2 creates a pipeline that extracts the measure from an InfluxDB 2.x bucket through the v2 API
+	CreateV2(v2Conn connections.InfluxV2Client, pgConn connections.PgxWrap, workerConns []connections.PgxWrap, measure, bucket string, conf *MigrationConfig) (pipeline.Pipe, error)
+	// CreateFromFile creates a pipeline that reads the measure of a database from a line protocol file
+	CreateFromFile(path string, pgConn connections.PgxWrap, workerConns []connections.PgxWrap, measure, inputDb string, conf *MigrationConfig) (pipeline.Pipe, error)
+	// CreateFromTSM creates a pipeline that decodes the measure of a database from TSM shards
+	CreateFromTSM(storage *tsmstorage.Storage, pgConn connections.PgxWrap, workerConns []connections.PgxWrap, measure, inputDb string, conf *MigrationConfig) (pipeline.Pipe, error)
+	// CreateFromTimescale creates a pipeline that selects the rows of a table in a schema of TimescaleDB or PostgreSQL,
+	// and writes them to a file, script or InfluxDB output
+	CreateFromTimescale(inputConn connections.PgxWrap, table, schema string, conf *MigrationConfig) (pipeline.Pipe, error)
 }
 
 type pipeService struct {
@@ -42,11 +58,13 @@ func NewPipeService(
 	}
 }
 
-func (s *pipeService) Create(infConn influx.Client, tsConn connections.PgxWrap, measure, inputDb string, conf *MigrationConfig) (pipeline.Pipe, error) {
-	pipeID := fmt.Sprintf(pipeIDTemplate, measure)
-	extractionConf := s.extractionConfCreator.create(pipeID, inputDb, measure, conf)
-	ingestionConf := s.ingestionConfCreator.create(pipeID, conf)
-	extractor, ingestor, err := s.createElements(infConn, tsConn, extractionConf, ingestionConf)
+func (s *pipeService) Create(infConn influx.Client, tsConn connections.PgxWrap, workerConns []connections.PgxWrap, measure, inputDb string, conf *MigrationConfig) (pipeline.Pipe, error) {
+	pipeID, extractionConf, ingestionConf, err := s.createConfs(tsConn, measure, inputDb, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	extractor, ingestor, err := s.createElements(infConn, tsConn, workerConns, extractionConf, ingestionConf)
 	if err != nil {
 		return nil, fmt.Errorf("%s: could not create extractor and ingestor:\n%v", pipeID, err)
 	}
@@ -58,3 +76,132 @@ func (s *pipeService) Create(infConn influx.Client, tsConn connections.PgxWrap,
 
 	return pipeline.NewPipe(pipeID, ingestor, extractor, transformers, conf.SchemaOnly), nil
 }
+
+func (s *pipeService) CreateV2(v2Conn connections.InfluxV2Client, tsConn connections.PgxWrap, workerConns []connections.PgxWrap, measure, bucket string, conf *MigrationConfig) (pipeline.Pipe, error) {
+	pipeID, extractionConf, ingestionConf, err := s.createConfs(tsConn, measure, bucket, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	extractor, ingestor, err := s.createV2Elements(v2Conn, tsConn, workerConns, extractionConf, ingestionConf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: could not create extractor and ingestor:\n%v", pipeID, err)
+	}
+
+	transformers, err := s.createV2Transformers(pipeID, v2Conn, measure, bucket, conf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: could not create transformers:\n%v", pipeID, err)
+	}
+
+	return pipeline.NewPipe(pipeID, ingestor, extractor, transformers, conf.SchemaOnly), nil
+}
+
+func (s *pipeService) CreateFromFile(path string, tsConn connections.PgxWrap, workerConns []connections.PgxWrap, measure, inputDb string, conf *MigrationConfig) (pipeline.Pipe, error) {
+	pipeID, extractionConf, ingestionConf, err := s.createConfs(tsConn, measure, inputDb, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// the points of a file are not sorted by time, so the time of the last ingested
+	// row can't be used as a checkpoint
+	ingestionConf.CheckpointKey = nil
+	ingestionConf.UnsortedRows = true
+	extractor, ingestor, err := s.createFileElements(path, tsConn, workerConns, extractionConf, ingestionConf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: could not create extractor and ingestor:\n%v", pipeID, err)
+	}
+
+	transformers, err := s.createFileTransformers(pipeID, path, measure, inputDb, conf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: could not create transformers:\n%v", pipeID, err)
+	}
+
+	return pipeline.NewPipe(pipeID, ingestor, extractor, transformers, conf.SchemaOnly), nil
+}
+
+func (s *pipeService) CreateFromTSM(storage *tsmstorage.Storage, tsConn connections.PgxWrap, workerConns []connections.PgxWrap, measure, inputDb string, conf *MigrationConfig) (pipeline.Pipe, error) {
+	pipeID, extractionConf, ingestionConf, err := s.createConfs(tsConn, measure, inputDb, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// the points are extracted shard by shard and series by series, not sorted by time
+	ingestionConf.CheckpointKey = nil
+	ingestionConf.UnsortedRows = true
+	extractor, ingestor, err := s.createTSMElements(storage, tsConn, workerConns, extractionConf, ingestionConf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: could not create extractor and ingestor:\n%v", pipeID, err)
+	}
+
+	transformers, err := s.createTSMTransformers(pipeID, storage, measure, inputDb, conf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: could not create transformers:\n%v", pipeID, err)
+	}
+
+	return pipeline.NewPipe(pipeID, ingestor, extractor, transformers, conf.SchemaOnly), nil
+}
+
+func (s *pipeService) CreateFromTimescale(inputConn connections.PgxWrap, table, schema string, conf *MigrationConfig) (pipeline.Pipe, error) {
+	pipeID, extractionConf, ingestionConf, err := s.createConfs(nil, table, schema, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	extractor, ingestor, err := s.createTimescaleElements(inputConn, extractionConf, ingestionConf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: could not create extractor and ingestor:\n%v", pipeID, err)
+	}
+
+	// the tags, fields and retention policy are already in the columns of the table
+	return pipeline.NewPipe(pipeID, ingestor, extractor, []transformation.Transformer{}, conf.SchemaOnly), nil
+}
+
+// createConfs creates the extraction and ingestion config for a measure, resuming from
+// the recorded checkpoint if requested
+func (s *pipeService) createConfs(
+	tsConn connections.PgxWrap,
+	measure, inputDb string,
+	conf *MigrationConfig) (string, *extrConfig.ExtractionConfig, *ingConfig.IngestorConfig, error) {
+	pipeID := fmt.Sprintf(pipeIDTemplate, measure)
+	extractionConf := s.extractionConfCreator.create(pipeID, inputDb, measure, conf)
+	ingestionConf := s.ingestionConfCreator.create(pipeID, inputDb, measure, conf)
+	if conf.Resume && ingestionConf.CheckpointKey != nil {
+		if err := s.resume(pipeID, tsConn, conf.OutputSchema, conf.ResumeOverlap, *ingestionConf.CheckpointKey, extractionConf.MeasureExtraction, ingestionConf); err != nil {
+			return "", nil, nil, err
+		}
+	}
+
+	return pipeID, extractionConf, ingestionConf, nil
+}
+
+// resume loads the checkpoint for the measure, and if one exists sets it (minus the overlap window)
+// as the lower bound of the extraction and as the point from which the ingestor replaces the existing data
+func (s *pipeService) resume(
+	pipeID string,
+	tsConn connections.PgxWrap,
+	schema string,
+	overlap time.Duration,
+	key checkpoint.Key,
+	extractionConf *extrConfig.MeasureExtraction,
+	ingestionConf *ingConfig.IngestorConfig) error {
+	store := checkpoint.NewStore(tsConn, schema)
+	if err := store.Init(); err != nil {
+		return fmt.Errorf("%s: could not prepare checkpoint table\n%v", pipeID, err)
+	}
+
+	lastTime, found, err := store.Load(key)
+	if err != nil {
+		return fmt.Errorf("%s: could not load checkpoint\n%v", pipeID, err)
+	}
+
+	if !found {
+		log.Printf("%s: no checkpoint found for '%s', starting from the beginning", pipeID, key)
+		return nil
+	}
+
+	log.Printf("%s: resuming '%s' from checkpoint %s", pipeID, key, lastTime.Format(time.RFC3339Nano))
+	resumeFrom := lastTime.Add(-overlap)
+	extractionConf.ResumeFrom = resumeFrom.UTC().Format(time.RFC3339Nano)
+	ingestionConf.ResumeFrom = &resumeFrom
+	return nil
+}
@@ -9,11 +9,13 @@ import (
 	extrConfig "github.com/timescale/outflux/internal/extraction/config"
 	"github.com/timescale/outflux/internal/ingestion"
 	ingConfig "github.com/timescale/outflux/internal/ingestion/config"
+	"github.com/timescale/outflux/internal/schemamanagement/tsm/tsmstorage"
 )
 
 func (p *pipeService) createElements(
 	infConn influx.Client,
 	tsConn connections.PgxWrap,
+	workerConns []connections.PgxWrap,
 	extrConf *extrConfig.ExtractionConfig,
 	ingConf *ingConfig.IngestorConfig) (extraction.Extractor, ingestion.Ingestor, error) {
 	extractor, err := p.extractorService.InfluxExtractor(infConn, extrConf)
@@ -21,6 +23,85 @@ func (p *pipeService) createElements(
 		return nil, nil, fmt.Errorf("could not create extractor\n%v", err)
 	}
 
-	ingestor := p.ingestorService.NewTimescaleIngestor(tsConn, ingConf)
+	ingestor := p.newIngestor(tsConn, workerConns, ingConf)
 	return extractor, ingestor, nil
 }
+
+func (p *pipeService) createV2Elements(
+	v2Conn connections.InfluxV2Client,
+	tsConn connections.PgxWrap,
+	workerConns []connections.PgxWrap,
+	extrConf *extrConfig.ExtractionConfig,
+	ingConf *ingConfig.IngestorConfig) (extraction.Extractor, ingestion.Ingestor, error) {
+	extractor, err := p.extractorService.InfluxV2Extractor(v2Conn, extrConf)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create extractor\n%v", err)
+	}
+
+	ingestor := p.newIngestor(tsConn, workerConns, ingConf)
+	return extractor, ingestor, nil
+}
+
+func (p *pipeService) createFileElements(
+	path string,
+	tsConn connections.PgxWrap,
+	workerConns []connections.PgxWrap,
+	extrConf *extrConfig.ExtractionConfig,
+	ingConf *ingConfig.IngestorConfig) (extraction.Extractor, ingestion.Ingestor, error) {
+	extractor, err := p.extractorService.LineProtocolExtractor(path, extrConf)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create extractor\n%v", err)
+	}
+
+	ingestor := p.newIngestor(tsConn, workerConns, ingConf)
+	return extractor, ingestor, nil
+}
+
+func (p *pipeService) createTSMElements(
+	storage *tsmstorage.Storage,
+	tsConn connections.PgxWrap,
+	workerConns []connections.PgxWrap,
+	extrConf *extrConfig.ExtractionConfig,
+	ingConf *ingConfig.IngestorConfig) (extraction.Extractor, ingestion.Ingestor, error) {
+	extractor, err := p.extractorService.TSMExtractor(storage, extrConf)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create extractor\n%v", err)
+	}
+
+	ingestor := p.newIngestor(tsConn, workerConns, ingConf)
+	return extractor, ingestor, nil
+}
+
+func (p *pipeService) createTimescaleElements(
+	inputConn connections.PgxWrap,
+	extrConf *extrConfig.ExtractionConfig,
+	ingConf *ingConfig.IngestorConfig) (extraction.Extractor, ingestion.Ingestor, error) {
+	extractor, err := p.extractorService.TimescaleExtractor(inputConn, extrConf)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create extractor\n%v", err)
+	}
+
+	ingestor := p.newIngestor(nil, nil, ingConf)
+	return extractor, ingestor, nil
+}
+
+// newIngestor creates an ingestor that writes to files if the config has a file output, to a SQL script
+// if it has a script output, to InfluxDB if it has an Influx output, or to TimescaleDB
+func (p *pipeService) newIngestor(
+	tsConn connections.PgxWrap,
+	workerConns []connections.PgxWrap,
+	ingConf *ingConfig.IngestorConfig) ingestion.Ingestor {
+	if ingConf.FileOutput != nil {
+		return p.ingestorService.NewFileIngestor(ingConf)
+	}
+
+	if ingConf.ScriptOutput != nil {
+		return p.ingestorService.NewScriptIngestor(ingConf)
+	}
+
+	if ingConf.InfluxOutput != nil {
+		return p.ingestorService.NewInfluxIngestor(ingConf)
+	}
+
+	return p.ingestorService.NewTimescaleIngestor(tsConn, workerConns, ingConf)
+}
@@ -4,7 +4,12 @@ import (
 	"fmt"
 
 	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/timescale/outflux/internal/connections"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+	"github.com/timescale/outflux/internal/schemamanagement/tsm/tsmstorage"
 	"github.com/timescale/outflux/internal/transformation"
+	"github.com/timescale/outflux/internal/transformation/rpmapping"
+	"github.com/timescale/outflux/internal/transformation/timeformat"
 )
 
 const (
@@ -12,11 +17,57 @@ const (
 )
 
 func (p *pipeService) createTransformers(pipeID string, infConn influx.Client, measure string, inputDb string, conf *MigrationConfig) ([]transformation.Transformer, error) {
+	tagsAsJSON := func(id string) (transformation.Transformer, error) {
+		return p.transformerService.TagsAsJSON(infConn, id, inputDb, conf.RetentionPolicy, measure, conf.TagsCol)
+	}
+	fieldsAsJSON := func(id string) (transformation.Transformer, error) {
+		return p.transformerService.FieldsAsJSON(infConn, id, inputDb, conf.RetentionPolicy, measure, conf.FieldsCol)
+	}
+
+	return combineTransformers(pipeID, conf, tagsAsJSON, fieldsAsJSON)
+}
+
+func (p *pipeService) createV2Transformers(pipeID string, v2Conn connections.InfluxV2Client, measure string, bucket string, conf *MigrationConfig) ([]transformation.Transformer, error) {
+	tagsAsJSON := func(id string) (transformation.Transformer, error) {
+		return p.transformerService.TagsAsJSONV2(v2Conn, id, bucket, measure, conf.TagsCol)
+	}
+	fieldsAsJSON := func(id string) (transformation.Transformer, error) {
+		return p.transformerService.FieldsAsJSONV2(v2Conn, id, bucket, measure, conf.FieldsCol)
+	}
+
+	return combineTransformers(pipeID, conf, tagsAsJSON, fieldsAsJSON)
+}
+
+func (p *pipeService) createFileTransformers(pipeID string, path string, measure string, inputDb string, conf *MigrationConfig) ([]transformation.Transformer, error) {
+	tagsAsJSON := func(id string) (transformation.Transformer, error) {
+		return p.transformerService.TagsAsJSONFromFile(path, id, inputDb, conf.RetentionPolicy, measure, conf.TagsCol)
+	}
+	fieldsAsJSON := func(id string) (transformation.Transformer, error) {
+		return p.transformerService.FieldsAsJSONFromFile(path, id, inputDb, conf.RetentionPolicy, measure, conf.FieldsCol)
+	}
+
+	return combineTransformers(pipeID, conf, tagsAsJSON, fieldsAsJSON)
+}
+
+func (p *pipeService) createTSMTransformers(pipeID string, storage *tsmstorage.Storage, measure string, inputDb string, conf *MigrationConfig) ([]transformation.Transformer, error) {
+	tagsAsJSON := func(id string) (transformation.Transformer, error) {
+		return p.transformerService.TagsAsJSONFromTSM(storage, id, inputDb, conf.RetentionPolicy, measure, conf.TagsCol)
+	}
+	fieldsAsJSON := func(id string) (transformation.Transformer, error) {
+		return p.transformerService.FieldsAsJSONFromTSM(storage, id, inputDb, conf.RetentionPolicy, measure, conf.FieldsCol)
+	}
+
+	return combineTransformers(pipeID, conf, tagsAsJSON, fieldsAsJSON)
+}
+
+type createTransformerFn func(id string) (transformation.Transformer, error)
+
+func combineTransformers(pipeID string, conf *MigrationConfig, tagsAsJSON, fieldsAsJSON createTransformerFn) ([]transformation.Transformer, error) {
 	transformers := []transformation.Transformer{}
 
 	if conf.TagsAsJSON {
 		id := fmt.Sprintf(transformerIDTemplate, pipeID, "tagsAsJSON")
-		tagsTransformer, err := p.transformerService.TagsAsJSON(infConn, id, inputDb, conf.RetentionPolicy, measure, conf.TagsCol)
+		tagsTransformer, err := tagsAsJSON(id)
 		if err != nil {
 			return nil, err
 		}
@@ -28,12 +79,31 @@ func (p *pipeService) createTransformers(pipeID string, infConn influx.Client, m
 
 	if conf.FieldsAsJSON {
 		id := fmt.Sprintf(transformerIDTemplate, pipeID, "fieldsAsJSON")
-		fieldsTransformer, err := p.transformerService.FieldsAsJSON(infConn, id, inputDb, conf.RetentionPolicy, measure, conf.FieldsCol)
+		fieldsTransformer, err := fieldsAsJSON(id)
 		if err != nil {
 			return nil, err
 		}
 		transformers = append(transformers, fieldsTransformer)
 	}
 
+	if conf.RetentionPolicyMapping == schemaconfig.RPToTablePrefix || conf.RetentionPolicyMapping == schemaconfig.RPToColumn {
+		id := fmt.Sprintf(transformerIDTemplate, pipeID, "rpMapping")
+		rpTransformer, err := rpmapping.NewTransformer(id, conf.RetentionPolicyMapping, conf.RetentionPolicy)
+		if err != nil {
+			return nil, err
+		}
+		transformers = append(transformers, rpTransformer)
+	}
+
+	// the time is converted last, the other transformers expect a timestamp
+	if conf.TimeFormat == schemaconfig.TimestamptzWithNanos || conf.TimeFormat == schemaconfig.EpochNanos {
+		id := fmt.Sprintf(transformerIDTemplate, pipeID, "timeFormat")
+		timeTransformer, err := timeformat.NewTransformer(id, conf.TimeFormat)
+		if err != nil {
+			return nil, err
+		}
+		transformers = append(transformers, timeTransformer)
+	}
+
 	return transformers, nil
 }
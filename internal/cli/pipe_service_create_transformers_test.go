@@ -5,7 +5,10 @@ import (
 	"testing"
 
 	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/timescale/outflux/internal/connections"
 	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+	"github.com/timescale/outflux/internal/schemamanagement/tsm/tsmstorage"
 	"github.com/timescale/outflux/internal/transformation"
 )
 
@@ -48,6 +51,20 @@ func TestCreateTransformers(t *testing.T) {
 			expectedTransIds: []string{"t", "f"},
 			conf:             &MigrationConfig{FieldsAsJSON: true, TagsAsJSON: true},
 			connConf:         &ConnectionConfig{},
+		}, {
+			desc: "time format transformer created after the others",
+			mock: &psctMockService{
+				tagsT: &psctMockTrans{id: "t"},
+			},
+			expectedTransIds: []string{"t", "id_transfomer_timeFormat"},
+			conf:             &MigrationConfig{TagsAsJSON: true, TimeFormat: schemaconfig.EpochNanos},
+			connConf:         &ConnectionConfig{},
+		}, {
+			desc:             "no transformer for the default time format",
+			mock:             &psctMockService{},
+			expectedTransIds: []string{},
+			conf:             &MigrationConfig{TimeFormat: schemaconfig.Timestamptz},
+			connConf:         &ConnectionConfig{},
 		},
 	}
 	for _, tc := range testCases {
@@ -56,24 +73,34 @@ func TestCreateTransformers(t *testing.T) {
 		}
 
 		trans, err := ps.createTransformers("id", nil, "measure", "inputDb", tc.conf)
-		if err == nil && tc.expectErr {
-			t.Fatalf("%s:expected error, none got", tc.desc)
-		} else if err != nil && !tc.expectErr {
-			t.Fatalf("%s: unexpected err: %v", tc.desc, err)
-		}
+		checkCreatedTransformers(t, tc.desc, trans, err, tc.expectErr, tc.expectedTransIds)
+		trans, err = ps.createV2Transformers("id", nil, "measure", "bucket", tc.conf)
+		checkCreatedTransformers(t, tc.desc+" (v2)", trans, err, tc.expectErr, tc.expectedTransIds)
+		trans, err = ps.createFileTransformers("id", "file", "measure", "inputDb", tc.conf)
+		checkCreatedTransformers(t, tc.desc+" (file)", trans, err, tc.expectErr, tc.expectedTransIds)
+		trans, err = ps.createTSMTransformers("id", nil, "measure", "inputDb", tc.conf)
+		checkCreatedTransformers(t, tc.desc+" (tsm)", trans, err, tc.expectErr, tc.expectedTransIds)
+	}
+}
 
-		if tc.expectErr {
-			continue
-		}
+func checkCreatedTransformers(t *testing.T, desc string, trans []transformation.Transformer, err error, expectErr bool, expectedTransIds []string) {
+	if err == nil && expectErr {
+		t.Fatalf("%s:expected error, none got", desc)
+	} else if err != nil && !expectErr {
+		t.Fatalf("%s: unexpected err: %v", desc, err)
+	}
 
-		if len(trans) != len(tc.expectedTransIds) {
-			t.Fatalf("%s: expected %d transformers, got %d", tc.desc, len(tc.expectedTransIds), len(trans))
-		}
+	if expectErr {
+		return
+	}
+
+	if len(trans) != len(expectedTransIds) {
+		t.Fatalf("%s: expected %d transformers, got %d", desc, len(expectedTransIds), len(trans))
+	}
 
-		for i, returnedTrans := range trans {
-			if returnedTrans.ID() != tc.expectedTransIds[i] {
-				t.Fatalf("%s: expected trans id '%s', got '%s'", tc.desc, returnedTrans.ID(), tc.expectedTransIds[i])
-			}
+	for i, returnedTrans := range trans {
+		if returnedTrans.ID() != expectedTransIds[i] {
+			t.Fatalf("%s: expected trans id '%s', got '%s'", desc, returnedTrans.ID(), expectedTransIds[i])
 		}
 	}
 }
@@ -89,10 +116,34 @@ func (p *psctMockService) TagsAsJSON(infConn influx.Client, id, db, rp, measure
 	return p.tagsT, p.tagsErr
 }
 
+func (p *psctMockService) TagsAsJSONV2(client connections.InfluxV2Client, id, bucket, measure string, resultCol string) (transformation.Transformer, error) {
+	return p.tagsT, p.tagsErr
+}
+
+func (p *psctMockService) FieldsAsJSONV2(client connections.InfluxV2Client, id, bucket, measure string, resultCol string) (transformation.Transformer, error) {
+	return p.fieldsT, p.fieldsErr
+}
+
 func (p *psctMockService) FieldsAsJSON(infConn influx.Client, id, db, rp, measure string, resultCol string) (transformation.Transformer, error) {
 	return p.fieldsT, p.fieldsErr
 }
 
+func (p *psctMockService) TagsAsJSONFromFile(path, id, db, rp, measure string, resultCol string) (transformation.Transformer, error) {
+	return p.tagsT, p.tagsErr
+}
+
+func (p *psctMockService) FieldsAsJSONFromFile(path, id, db, rp, measure string, resultCol string) (transformation.Transformer, error) {
+	return p.fieldsT, p.fieldsErr
+}
+
+func (p *psctMockService) TagsAsJSONFromTSM(storage *tsmstorage.Storage, id, db, rp, measure string, resultCol string) (transformation.Transformer, error) {
+	return p.tagsT, p.tagsErr
+}
+
+func (p *psctMockService) FieldsAsJSONFromTSM(storage *tsmstorage.Storage, id, db, rp, measure string, resultCol string) (transformation.Transformer, error) {
+	return p.fieldsT, p.fieldsErr
+}
+
 type psctMockTrans struct {
 	id string
 }
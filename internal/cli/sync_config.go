@@ -0,0 +1,12 @@
+package cli
+
+import "time"
+
+// SyncConfig contains the configurable parameters for continuously replicating
+// an InfluxDB database to TimescaleDB
+type SyncConfig struct {
+	// Interval between the start of two sync cycles
+	Interval time.Duration
+	// Drain makes the sync run cycles back to back until no new points are replicated, and then stop
+	Drain bool
+}
@@ -5,8 +5,13 @@ import (
 	"log"
 
 	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/timescale/outflux/internal/connections"
 	"github.com/timescale/outflux/internal/idrf"
 	"github.com/timescale/outflux/internal/schemamanagement/influx/discovery"
+	"github.com/timescale/outflux/internal/schemamanagement/influxv2"
+	"github.com/timescale/outflux/internal/schemamanagement/lineprotocol"
+	"github.com/timescale/outflux/internal/schemamanagement/tsm"
+	"github.com/timescale/outflux/internal/schemamanagement/tsm/tsmstorage"
 	"github.com/timescale/outflux/internal/transformation"
 	jsonCombiner "github.com/timescale/outflux/internal/transformation/jsoncombiner"
 )
@@ -15,19 +20,36 @@ import (
 type TransformerService interface {
 	TagsAsJSON(infConn influx.Client, id, db, rp, measure string, resultCol string) (transformation.Transformer, error)
 	FieldsAsJSON(infConn influx.Client, id, db, rp, measure string, resultCol string) (transformation.Transformer, error)
+	TagsAsJSONV2(client connections.InfluxV2Client, id, bucket, measure string, resultCol string) (transformation.Transformer, error)
+	FieldsAsJSONV2(client connections.InfluxV2Client, id, bucket, measure string, resultCol string) (transformation.Transformer, error)
+	TagsAsJSONFromFile(path, id, db, rp, measure string, resultCol string) (transformation.Transformer, error)
+	FieldsAsJSONFromFile(path, id, db, rp, measure string, resultCol string) (transformation.Transformer, error)
+	TagsAsJSONFromTSM(storage *tsmstorage.Storage, id, db, rp, measure string, resultCol string) (transformation.Transformer, error)
+	FieldsAsJSONFromTSM(storage *tsmstorage.Storage, id, db, rp, measure string, resultCol string) (transformation.Transformer, error)
 }
 
 // NewTransformerService creates a new implementation of the TransformerService interface
-func NewTransformerService(influxTagExplorer discovery.TagExplorer, influxFieldExplorer discovery.FieldExplorer) TransformerService {
+func NewTransformerService(
+	influxTagExplorer discovery.TagExplorer,
+	influxFieldExplorer discovery.FieldExplorer,
+	influxV2Explorer influxv2.Explorer,
+	lineProtocolExplorer lineprotocol.Explorer,
+	tsmExplorer tsm.Explorer) TransformerService {
 	return &transformerService{
-		influxTagExplorer:   influxTagExplorer,
-		influxFieldExplorer: influxFieldExplorer,
+		influxTagExplorer:    influxTagExplorer,
+		influxFieldExplorer:  influxFieldExplorer,
+		influxV2Explorer:     influxV2Explorer,
+		lineProtocolExplorer: lineProtocolExplorer,
+		tsmExplorer:          tsmExplorer,
 	}
 }
 
 type transformerService struct {
-	influxTagExplorer   discovery.TagExplorer
-	influxFieldExplorer discovery.FieldExplorer
+	influxTagExplorer    discovery.TagExplorer
+	influxFieldExplorer  discovery.FieldExplorer
+	influxV2Explorer     influxv2.Explorer
+	lineProtocolExplorer lineprotocol.Explorer
+	tsmExplorer          tsm.Explorer
 }
 
 // TagsAsJSON returns a transformer that combines the tags into a single JSONb column.
@@ -35,49 +57,106 @@ type transformerService struct {
 // Returns an error if the tags couldn't be discovered or the instance of the transformer
 // could not be created.
 func (t *transformerService) TagsAsJSON(infConn influx.Client, id, db, rp, measure string, resultCol string) (transformation.Transformer, error) {
-	log.Printf("Tags for measure '%s' will be combined into a single JSONB column", measure)
-	tags, err := t.fetchTags(infConn, db, rp, measure)
-	if err != nil {
-		return nil, fmt.Errorf("could not create the transformer for measure '%s'\n%v", measure, err)
+	fetchFn := func() ([]*idrf.Column, error) {
+		return t.influxTagExplorer.DiscoverMeasurementTags(infConn, db, rp, measure)
 	}
 
-	if len(tags) == 0 {
-		log.Printf("%s: measure '%s' doesn't have any tags, will not be transformed", id, measure)
-		return nil, nil
-	}
-	return jsonCombiner.NewTransformer(id, tags, resultCol)
+	return tagsAsJSON(id, measure, resultCol, fetchFn)
 }
 
 // FieldsAsJSON returns a transformer that combines the fields into a single JSONb column.
 func (t *transformerService) FieldsAsJSON(infConn influx.Client, id, db, rp, measure string, resultCol string) (transformation.Transformer, error) {
-	log.Printf("Fields for measure '%s' will be combined into a single JSONB column", measure)
-	fields, err := t.fetchFields(infConn, db, rp, measure)
-	if err != nil {
-		return nil, fmt.Errorf("could not create the transformer for measure '%s'\n%v", measure, err)
+	// Because the columns are combined in a JSON it doesn't matter if they are
+	// int or float in different shards
+	onConflictConvertIntToFloat := true
+	fetchFn := func() ([]*idrf.Column, error) {
+		return t.influxFieldExplorer.DiscoverMeasurementFields(infConn, db, rp, measure, onConflictConvertIntToFloat)
 	}
 
-	return jsonCombiner.NewTransformer(id, fields, resultCol)
+	return fieldsAsJSON(id, measure, resultCol, fetchFn)
 }
 
-type fetchColumnsFn func() ([]*idrf.Column, error)
+// TagsAsJSONV2 is the same as TagsAsJSON for a measure in an InfluxDB 2.x bucket
+func (t *transformerService) TagsAsJSONV2(client connections.InfluxV2Client, id, bucket, measure string, resultCol string) (transformation.Transformer, error) {
+	fetchFn := func() ([]*idrf.Column, error) {
+		return t.influxV2Explorer.DiscoverMeasurementTags(client, bucket, measure)
+	}
 
-func (t *transformerService) fetchTags(infConn influx.Client, db, rp, measure string) ([]string, error) {
+	return tagsAsJSON(id, measure, resultCol, fetchFn)
+}
+
+// FieldsAsJSONV2 is the same as FieldsAsJSON for a measure in an InfluxDB 2.x bucket
+func (t *transformerService) FieldsAsJSONV2(client connections.InfluxV2Client, id, bucket, measure string, resultCol string) (transformation.Transformer, error) {
+	onConflictConvertIntToFloat := true
 	fetchFn := func() ([]*idrf.Column, error) {
-		return t.influxTagExplorer.DiscoverMeasurementTags(infConn, db, rp, measure)
+		return t.influxV2Explorer.DiscoverMeasurementFields(client, bucket, measure, onConflictConvertIntToFloat)
 	}
 
-	return fetch(fetchFn)
+	return fieldsAsJSON(id, measure, resultCol, fetchFn)
 }
 
-func (t *transformerService) fetchFields(infConn influx.Client, db, rp, measure string) ([]string, error) {
-	// Because the columns are combined in a JSON it doesn't matter if they are
-	// int or float in different shards
+// TagsAsJSONFromFile is the same as TagsAsJSON for a measure in a line protocol file
+func (t *transformerService) TagsAsJSONFromFile(path, id, db, rp, measure string, resultCol string) (transformation.Transformer, error) {
+	fetchFn := func() ([]*idrf.Column, error) {
+		return t.lineProtocolExplorer.DiscoverMeasurementTags(path, db, rp, measure)
+	}
+
+	return tagsAsJSON(id, measure, resultCol, fetchFn)
+}
+
+// FieldsAsJSONFromFile is the same as FieldsAsJSON for a measure in a line protocol file
+func (t *transformerService) FieldsAsJSONFromFile(path, id, db, rp, measure string, resultCol string) (transformation.Transformer, error) {
 	onConflictConvertIntToFloat := true
 	fetchFn := func() ([]*idrf.Column, error) {
-		return t.influxFieldExplorer.DiscoverMeasurementFields(infConn, db, rp, measure, onConflictConvertIntToFloat)
+		return t.lineProtocolExplorer.DiscoverMeasurementFields(path, db, rp, measure, onConflictConvertIntToFloat)
 	}
 
-	return fetch(fetchFn)
+	return fieldsAsJSON(id, measure, resultCol, fetchFn)
+}
+
+// TagsAsJSONFromTSM is the same as TagsAsJSON for a measure stored in TSM shards
+func (t *transformerService) TagsAsJSONFromTSM(storage *tsmstorage.Storage, id, db, rp, measure string, resultCol string) (transformation.Transformer, error) {
+	fetchFn := func() ([]*idrf.Column, error) {
+		return t.tsmExplorer.DiscoverMeasurementTags(storage, db, rp, measure)
+	}
+
+	return tagsAsJSON(id, measure, resultCol, fetchFn)
+}
+
+// FieldsAsJSONFromTSM is the same as FieldsAsJSON for a measure stored in TSM shards
+func (t *transformerService) FieldsAsJSONFromTSM(storage *tsmstorage.Storage, id, db, rp, measure string, resultCol string) (transformation.Transformer, error) {
+	onConflictConvertIntToFloat := true
+	fetchFn := func() ([]*idrf.Column, error) {
+		return t.tsmExplorer.DiscoverMeasurementFields(storage, db, rp, measure, onConflictConvertIntToFloat)
+	}
+
+	return fieldsAsJSON(id, measure, resultCol, fetchFn)
+}
+
+type fetchColumnsFn func() ([]*idrf.Column, error)
+
+func tagsAsJSON(id, measure, resultCol string, fetchTags fetchColumnsFn) (transformation.Transformer, error) {
+	log.Printf("Tags for measure '%s' will be combined into a single JSONB column", measure)
+	tags, err := fetch(fetchTags)
+	if err != nil {
+		return nil, fmt.Errorf("could not create the transformer for measure '%s'\n%v", measure, err)
+	}
+
+	if len(tags) == 0 {
+		log.Printf("%s: measure '%s' doesn't have any tags, will not be transformed", id, measure)
+		return nil, nil
+	}
+	return jsonCombiner.NewTransformer(id, tags, resultCol)
+}
+
+func fieldsAsJSON(id, measure, resultCol string, fetchFields fetchColumnsFn) (transformation.Transformer, error) {
+	log.Printf("Fields for measure '%s' will be combined into a single JSONB column", measure)
+	fields, err := fetch(fetchFields)
+	if err != nil {
+		return nil, fmt.Errorf("could not create the transformer for measure '%s'\n%v", measure, err)
+	}
+
+	return jsonCombiner.NewTransformer(id, fields, resultCol)
 }
 
 func fetch(fetch fetchColumnsFn) ([]string, error) {
@@ -0,0 +1,19 @@
+package cli
+
+// TranslateConfig contains the configurable parameters for translating InfluxQL queries, or
+// the InfluxDB queries of a Grafana dashboard, into SQL queries of the migrated data
+type TranslateConfig struct {
+	// Queries to translate, read from STDIN when empty and no dashboard is specified
+	Queries []string
+	// OutputSchema holds the migrated tables
+	OutputSchema string
+	// TagsColumn is the JSONB column of the tags, empty if the tags were migrated as columns
+	TagsColumn string
+	// FieldsColumn is the JSONB column of the fields, empty if the fields were migrated as columns
+	FieldsColumn string
+	// Dashboard is the path of the Grafana dashboard JSON to rewrite
+	Dashboard string
+	// Datasource is the uid (or name) of the PostgreSQL data source of the rewritten dashboard
+	Datasource string
+	Quiet      bool
+}
@@ -0,0 +1,159 @@
+package connections
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// Environment variable names to be used for the InfluxDB 2.x connection
+const (
+	TokenEnvVar = "INFLUX_TOKEN"
+	OrgEnvVar   = "INFLUX_ORG"
+)
+
+const (
+	queryPath        = "/api/v2/query"
+	authHeaderPrefix = "Token "
+	fluxQueryType    = "flux"
+)
+
+// csvAnnotations are requested with each query, they describe the type and default value of each column
+var csvAnnotations = []string{"datatype", "group", "default"}
+
+// InfluxV2ConnectionParams represents the parameters required to connect to the v2 API of an InfluxDB server
+type InfluxV2ConnectionParams struct {
+	Server      string
+	Token       string
+	Org         string
+	UnsafeHTTPS bool
+}
+
+// InfluxV2Client sends Flux queries to the v2 API of an InfluxDB server
+type InfluxV2Client interface {
+	// Query executes a Flux query and returns the response as annotated CSV.
+	// The caller is responsible for closing the returned reader
+	Query(flux string) (io.ReadCloser, error)
+	Close() error
+}
+
+// InfluxV2ConnectionService creates new clients connected to the v2 API of some InfluxDB server
+type InfluxV2ConnectionService interface {
+	NewConnection(*InfluxV2ConnectionParams) (InfluxV2Client, error)
+}
+
+type defaultInfluxV2ConnectionService struct{}
+
+// NewInfluxV2ConnectionService creates a new instance of the service
+func NewInfluxV2ConnectionService() InfluxV2ConnectionService {
+	return &defaultInfluxV2ConnectionService{}
+}
+
+func (s *defaultInfluxV2ConnectionService) NewConnection(params *InfluxV2ConnectionParams) (InfluxV2Client, error) {
+	if params == nil {
+		return nil, fmt.Errorf("Connection params shouldn't be nil")
+	}
+
+	serverURL, err := url.Parse(params.Server)
+	if err != nil || serverURL.Scheme == "" || serverURL.Host == "" {
+		return nil, fmt.Errorf("server address '%s' is not a valid http(s)://location:port url", params.Server)
+	}
+
+	token := params.Token
+	if token == "" {
+		token = os.Getenv(TokenEnvVar)
+	}
+
+	org := params.Org
+	if org == "" {
+		org = os.Getenv(OrgEnvVar)
+	}
+
+	if token == "" || org == "" {
+		return nil, fmt.Errorf("a token and an organization are required to connect to the InfluxDB v2 API")
+	}
+
+	queryURL := *serverURL
+	queryURL.Path = queryPath
+	queryURL.RawQuery = url.Values{"org": []string{org}}.Encode()
+	transport := &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: params.UnsafeHTTPS},
+	}
+
+	return &influxV2Client{
+		httpClient: &http.Client{Transport: transport},
+		queryURL:   queryURL.String(),
+		token:      token,
+	}, nil
+}
+
+type influxV2Client struct {
+	httpClient *http.Client
+	queryURL   string
+	token      string
+}
+
+type queryRequest struct {
+	Query   string       `json:"query"`
+	Type    string       `json:"type"`
+	Dialect queryDialect `json:"dialect"`
+}
+
+type queryDialect struct {
+	Header      bool     `json:"header"`
+	Annotations []string `json:"annotations"`
+}
+
+type errorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (c *influxV2Client) Query(flux string) (io.ReadCloser, error) {
+	body, err := json.Marshal(&queryRequest{
+		Query:   flux,
+		Type:    fluxQueryType,
+		Dialect: queryDialect{Header: true, Annotations: csvAnnotations},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, c.queryURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Authorization", authHeaderPrefix+c.token)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/csv")
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusOK {
+		return response.Body, nil
+	}
+
+	defer response.Body.Close()
+	responseBody, _ := ioutil.ReadAll(response.Body)
+	errResponse := &errorResponse{}
+	if err = json.Unmarshal(responseBody, errResponse); err != nil || errResponse.Message == "" {
+		return nil, fmt.Errorf("query failed with status %s: %s", response.Status, string(responseBody))
+	}
+
+	return nil, fmt.Errorf("query failed with status %s: %s", response.Status, errResponse.Message)
+}
+
+func (c *influxV2Client) Close() error {
+	c.httpClient.CloseIdleConnections()
+	return nil
+}
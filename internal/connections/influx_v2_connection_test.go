@@ -0,0 +1,59 @@
+package connections
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInfluxV2ConnectionServiceNewConnection(t *testing.T) {
+	service := &defaultInfluxV2ConnectionService{}
+	_, err := service.NewConnection(nil)
+	assert.Error(t, err)
+	_, err = service.NewConnection(&InfluxV2ConnectionParams{Server: "someaddress", Token: "t", Org: "o"})
+	assert.Error(t, err, "server address without a scheme should not be accepted")
+	_, err = service.NewConnection(&InfluxV2ConnectionParams{Server: "http://someaddress"})
+	assert.Error(t, err, "token and org are required")
+	client, err := service.NewConnection(&InfluxV2ConnectionParams{Server: "http://someaddress", Token: "t", Org: "o"})
+	assert.NoError(t, err)
+	assert.Equal(t, "http://someaddress/api/v2/query?org=o", client.(*influxV2Client).queryURL)
+}
+
+func TestInfluxV2ClientQuery(t *testing.T) {
+	csv := "#datatype,string,long\n,result,table\n,_result,0\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v2/query", r.URL.Path)
+		assert.Equal(t, "my org", r.URL.Query().Get("org"))
+		assert.Equal(t, "Token secret", r.Header.Get("Authorization"))
+		request := &queryRequest{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(request))
+		assert.Equal(t, fluxQueryType, request.Type)
+		assert.Equal(t, csvAnnotations, request.Dialect.Annotations)
+		if request.Query == "bad" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"code":"invalid","message":"compilation failed"}`))
+			return
+		}
+
+		_, _ = w.Write([]byte(csv))
+	}))
+	defer server.Close()
+
+	service := NewInfluxV2ConnectionService()
+	client, err := service.NewConnection(&InfluxV2ConnectionParams{Server: server.URL, Token: "secret", Org: "my org"})
+	assert.NoError(t, err)
+	defer client.Close()
+
+	response, err := client.Query("good")
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(response)
+	response.Close()
+	assert.Equal(t, csv, string(body))
+
+	_, err = client.Query("bad")
+	assert.EqualError(t, err, "query failed with status 400 Bad Request: compilation failed")
+}
@@ -0,0 +1,82 @@
+// Package continuousqueries translates the continuous queries of an InfluxDB database into
+// continuous aggregates of TimescaleDB, over the hypertables created for the migrated measurements
+package continuousqueries
+
+import (
+	"fmt"
+
+	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/timescale/outflux/internal/schemamanagement/influx/influxqueries"
+)
+
+const (
+	showContinuousQueriesQuery = "SHOW CONTINUOUS QUERIES"
+	cqNameColumn               = "name"
+	cqQueryColumn              = "query"
+)
+
+// ContinuousQuery holds the name and the CREATE CONTINUOUS QUERY statement of a continuous query
+type ContinuousQuery struct {
+	Name  string
+	Query string
+}
+
+// Explorer defines an API for discovering the continuous queries of an InfluxDB database
+type Explorer interface {
+	FetchContinuousQueries(influxClient influx.Client, db string) ([]*ContinuousQuery, error)
+}
+
+type defaultExplorer struct {
+	queryService influxqueries.InfluxQueryService
+}
+
+// NewExplorer creates a new implementation of the continuous query Explorer API
+func NewExplorer(queryService influxqueries.InfluxQueryService) Explorer {
+	return &defaultExplorer{queryService}
+}
+
+// FetchContinuousQueries returns the continuous queries of a database. 'SHOW CONTINUOUS QUERIES'
+// lists the queries of all databases, each database is a separate series named after it
+func (e *defaultExplorer) FetchContinuousQueries(influxClient influx.Client, db string) ([]*ContinuousQuery, error) {
+	results, err := e.queryService.ExecuteQuery(influxClient, db, showContinuousQueriesQuery)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %s\n%v", showContinuousQueriesQuery, err)
+	}
+
+	if len(results) != 1 {
+		return nil, fmt.Errorf("'%s' returned an unexpected number of results", showContinuousQueriesQuery)
+	}
+
+	queries := []*ContinuousQuery{}
+	for _, series := range results[0].Series {
+		if series.Name != db {
+			continue
+		}
+
+		nameIndex, queryIndex := -1, -1
+		for i, column := range series.Columns {
+			switch column {
+			case cqNameColumn:
+				nameIndex = i
+			case cqQueryColumn:
+				queryIndex = i
+			}
+		}
+
+		if nameIndex == -1 || queryIndex == -1 {
+			return nil, fmt.Errorf("'%s' returned no '%s' or '%s' column", showContinuousQueriesQuery, cqNameColumn, cqQueryColumn)
+		}
+
+		for _, row := range series.Values {
+			name, nameOk := row[nameIndex].(string)
+			query, queryOk := row[queryIndex].(string)
+			if !nameOk || !queryOk {
+				return nil, fmt.Errorf("'%s' returned a continuous query that is not a string", showContinuousQueriesQuery)
+			}
+
+			queries = append(queries, &ContinuousQuery{Name: name, Query: query})
+		}
+	}
+
+	return queries, nil
+}
@@ -0,0 +1,91 @@
+package continuousqueries
+
+import (
+	"fmt"
+	"testing"
+
+	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/influxdata/influxdb/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/schemamanagement/influx/influxqueries"
+)
+
+func TestFetchContinuousQueries(t *testing.T) {
+	columns := []string{"name", "query"}
+	testCases := []struct {
+		desc      string
+		results   []influx.Result
+		queryErr  bool
+		expected  []*ContinuousQuery
+		expectErr bool
+	}{
+		{
+			desc:      "query fails",
+			queryErr:  true,
+			expectErr: true,
+		}, {
+			desc:      "no results",
+			results:   []influx.Result{},
+			expectErr: true,
+		}, {
+			desc: "missing column",
+			results: []influx.Result{{Series: []models.Row{
+				{Name: "db", Columns: []string{"name"}, Values: [][]interface{}{{"cq"}}},
+			}}},
+			expectErr: true,
+		}, {
+			desc: "query is not a string",
+			results: []influx.Result{{Series: []models.Row{
+				{Name: "db", Columns: columns, Values: [][]interface{}{{"cq", 1}}},
+			}}},
+			expectErr: true,
+		}, {
+			desc: "only the queries of the database",
+			results: []influx.Result{{Series: []models.Row{
+				{Name: "_internal", Columns: columns},
+				{Name: "other", Columns: columns, Values: [][]interface{}{{"cq0", "CREATE CONTINUOUS QUERY cq0 ON other"}}},
+				{Name: "db", Columns: columns, Values: [][]interface{}{
+					{"cq1", "CREATE CONTINUOUS QUERY cq1 ON db"},
+					{"cq2", "CREATE CONTINUOUS QUERY cq2 ON db"},
+				}},
+			}}},
+			expected: []*ContinuousQuery{
+				{Name: "cq1", Query: "CREATE CONTINUOUS QUERY cq1 ON db"},
+				{Name: "cq2", Query: "CREATE CONTINUOUS QUERY cq2 ON db"},
+			},
+		}, {
+			desc:     "no queries",
+			results:  []influx.Result{{Series: []models.Row{{Name: "db", Columns: columns}}}},
+			expected: []*ContinuousQuery{},
+		},
+	}
+
+	for _, tc := range testCases {
+		explorer := NewExplorer(&mockQueryService{results: tc.results, err: tc.queryErr})
+		queries, err := explorer.FetchContinuousQueries(nil, "db")
+		if tc.expectErr {
+			assert.Error(t, err, tc.desc)
+			continue
+		}
+
+		assert.NoError(t, err, tc.desc)
+		assert.Equal(t, tc.expected, queries, tc.desc)
+	}
+}
+
+type mockQueryService struct {
+	results []influx.Result
+	err     bool
+}
+
+func (m *mockQueryService) ExecuteQuery(client influx.Client, database, command string) ([]influx.Result, error) {
+	if m.err {
+		return nil, fmt.Errorf("generic error")
+	}
+
+	return m.results, nil
+}
+
+func (m *mockQueryService) ExecuteShowQuery(influxClient influx.Client, database, query string) (*influxqueries.InfluxShowResult, error) {
+	panic("should not come here")
+}
@@ -0,0 +1,200 @@
+package continuousqueries
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxql"
+	"github.com/jackc/pgx"
+	"github.com/timescale/outflux/internal/querytranslation"
+)
+
+const (
+	timeColumn              = "time"
+	createViewTemplate      = "CREATE MATERIALIZED VIEW %s WITH (timescaledb.continuous) AS\nSELECT %s\nFROM %s\nGROUP BY %s"
+	timeBucketTemplate      = "time_bucket(%s, %s)"
+	timeBucketWithOffset    = "time_bucket(%s, %s, %s)"
+	aliasTemplate           = "%s AS %s"
+	refreshPolicyTemplate   = "SELECT add_continuous_aggregate_policy('%s', start_offset => %s, end_offset => %s, schedule_interval => %s)"
+	unsupportedFuncTemplate = "function '%s' is not supported, field '%s' is skipped"
+)
+
+// aggregates maps the InfluxQL aggregate functions to SQL, the argument is the quoted field
+var aggregates = map[string]func(field string) string{
+	"count":  func(field string) string { return fmt.Sprintf("count(%s)", field) },
+	"sum":    func(field string) string { return fmt.Sprintf("sum(%s)", field) },
+	"mean":   func(field string) string { return fmt.Sprintf("avg(%s)", field) },
+	"min":    func(field string) string { return fmt.Sprintf("min(%s)", field) },
+	"max":    func(field string) string { return fmt.Sprintf("max(%s)", field) },
+	"spread": func(field string) string { return fmt.Sprintf("max(%s) - min(%s)", field, field) },
+	"stddev": func(field string) string { return fmt.Sprintf("stddev_samp(%s)", field) },
+	"first":  func(field string) string { return fmt.Sprintf("first(%s, %s)", field, quote(timeColumn)) },
+	"last":   func(field string) string { return fmt.Sprintf("last(%s, %s)", field, quote(timeColumn)) },
+}
+
+// Translation holds the statements that create the continuous aggregate of a continuous query,
+// and the parts of the query that could not be translated. If the query can't be translated
+// at all, there are no statements
+type Translation struct {
+	ContinuousQuery *ContinuousQuery
+	ViewName        string
+	Statements      []string
+	Unsupported     []string
+}
+
+// Translate creates a continuous aggregate in the schema (optional) for a continuous query like:
+// CREATE CONTINUOUS QUERY name ON db [RESAMPLE [EVERY every] [FOR for]] BEGIN
+// SELECT aggregate(field) [, ...] INTO target FROM measure GROUP BY time(interval[, offset])[, tag ...] END.
+// The aggregate is named after the target measurement and reads the hypertable of the source measurement.
+// It is refreshed every 'every' for the completed buckets in the last 'for', like the continuous query
+func Translate(cq *ContinuousQuery, schema string) (*Translation, error) {
+	statement, err := influxql.ParseStatement(cq.Query)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse continuous query '%s'\n%v", cq.Name, err)
+	}
+
+	createCQ, ok := statement.(*influxql.CreateContinuousQueryStatement)
+	if !ok {
+		return nil, fmt.Errorf("'%s' is not a CREATE CONTINUOUS QUERY statement", cq.Query)
+	}
+
+	translation := &Translation{ContinuousQuery: cq}
+	selectStatement := createCQ.Source
+	source, target, err := checkStructure(selectStatement)
+	if err != nil {
+		translation.Unsupported = append(translation.Unsupported, err.Error())
+		return translation, nil
+	}
+
+	interval, _ := selectStatement.GroupByInterval()
+	offset, _ := selectStatement.GroupByOffset()
+	bucket := fmt.Sprintf(timeBucketTemplate, querytranslation.FormatInterval(interval), quote(timeColumn))
+	if offset != 0 {
+		bucket = fmt.Sprintf(timeBucketWithOffset, querytranslation.FormatInterval(interval), quote(timeColumn), querytranslation.FormatInterval(offset))
+	}
+
+	projection := []string{fmt.Sprintf(aliasTemplate, bucket, quote(timeColumn))}
+	grouping := []string{bucket}
+	for _, dimension := range selectStatement.Dimensions {
+		if tag, ok := dimension.Expr.(*influxql.VarRef); ok {
+			projection = append(projection, quote(tag.Val))
+			grouping = append(grouping, quote(tag.Val))
+		}
+	}
+
+	columnNames := selectStatement.ColumnNames()
+	columnNames = columnNames[len(columnNames)-len(selectStatement.Fields):]
+	aggregated := 0
+	for i, field := range selectStatement.Fields {
+		expression, err := translateField(field)
+		if err != nil {
+			translation.Unsupported = append(translation.Unsupported, err.Error())
+			continue
+		}
+
+		projection = append(projection, fmt.Sprintf(aliasTemplate, expression, quote(columnNames[i])))
+		aggregated++
+	}
+
+	if aggregated == 0 {
+		translation.Unsupported = append(translation.Unsupported, "no field can be translated")
+		return translation, nil
+	}
+
+	translation.ViewName = target
+	view := qualifiedName(schema, target)
+	translation.Statements = []string{
+		fmt.Sprintf(createViewTemplate, view, strings.Join(projection, ", "), qualifiedName(schema, source), strings.Join(grouping, ", ")),
+		refreshPolicy(view, interval, createCQ.ResampleEvery, createCQ.ResampleFor),
+	}
+
+	return translation, nil
+}
+
+// checkStructure returns the source and target measurements of a query that selects from a single
+// measurement into another one, grouped by time and tags without any other condition
+func checkStructure(selectStatement *influxql.SelectStatement) (string, string, error) {
+	if selectStatement.Target == nil || selectStatement.Target.Measurement.Name == "" {
+		return "", "", fmt.Errorf("the INTO clause must name the target measurement, back references are not supported")
+	}
+
+	if len(selectStatement.Sources) != 1 {
+		return "", "", fmt.Errorf("only queries with a single source measurement are supported")
+	}
+
+	source, ok := selectStatement.Sources[0].(*influxql.Measurement)
+	if !ok || source.Name == "" || source.Regex != nil {
+		return "", "", fmt.Errorf("the source must be a measurement, regular expressions and subqueries are not supported")
+	}
+
+	if selectStatement.Condition != nil {
+		return "", "", fmt.Errorf("WHERE conditions are not supported")
+	}
+
+	interval, err := selectStatement.GroupByInterval()
+	if err != nil || interval == 0 {
+		return "", "", fmt.Errorf("the query must be grouped by time()")
+	}
+
+	for _, dimension := range selectStatement.Dimensions {
+		switch dimension.Expr.(type) {
+		case *influxql.Call, *influxql.VarRef:
+		default:
+			return "", "", fmt.Errorf("GROUP BY %s is not supported, tags must be listed by name", dimension)
+		}
+	}
+
+	return source.Name, selectStatement.Target.Measurement.Name, nil
+}
+
+// translateField returns the SQL aggregate for an InfluxQL aggregate of a single field
+func translateField(field *influxql.Field) (string, error) {
+	call, ok := field.Expr.(*influxql.Call)
+	if !ok {
+		return "", fmt.Errorf("field '%s' is not a call of an aggregate function and is skipped", field)
+	}
+
+	aggregate, ok := aggregates[call.Name]
+	if !ok {
+		return "", fmt.Errorf(unsupportedFuncTemplate, call.Name, field)
+	}
+
+	if len(call.Args) != 1 {
+		return "", fmt.Errorf("field '%s' must have a single argument and is skipped", field)
+	}
+
+	arg, ok := call.Args[0].(*influxql.VarRef)
+	if !ok {
+		return "", fmt.Errorf("argument of field '%s' must be a field name, the field is skipped", field)
+	}
+
+	return aggregate(quote(arg.Val)), nil
+}
+
+// refreshPolicy refreshes the aggregate on each 'every' (default: the GROUP BY interval), for the
+// completed buckets in the last 'for' (default: the GROUP BY interval). The refresh window ends one
+// bucket before now, so the current incomplete bucket is left out as in InfluxDB
+func refreshPolicy(view string, interval, every, resampleFor time.Duration) string {
+	if every == 0 {
+		every = interval
+	}
+
+	if resampleFor == 0 {
+		resampleFor = interval
+	}
+
+	return fmt.Sprintf(refreshPolicyTemplate, view, querytranslation.FormatInterval(resampleFor+interval), querytranslation.FormatInterval(interval), querytranslation.FormatInterval(every))
+}
+
+func qualifiedName(schema, name string) string {
+	if schema == "" {
+		return quote(name)
+	}
+
+	return pgx.Identifier{schema, name}.Sanitize()
+}
+
+func quote(name string) string {
+	return pgx.Identifier{name}.Sanitize()
+}
@@ -0,0 +1,78 @@
+package continuousqueries
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslate(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		query       string
+		schema      string
+		statements  []string
+		unsupported []string
+	}{
+		{
+			desc:   "aggregates grouped by time and tags",
+			query:  `CREATE CONTINUOUS QUERY cq ON db BEGIN SELECT mean(usage), max(usage) AS peak, last(up) INTO cpu_1h FROM cpu GROUP BY time(1h), host END`,
+			schema: "s",
+			statements: []string{
+				"CREATE MATERIALIZED VIEW \"s\".\"cpu_1h\" WITH (timescaledb.continuous) AS\n" +
+					`SELECT time_bucket(INTERVAL '1 hour', "time") AS "time", "host", avg("usage") AS "mean", max("usage") AS "peak", last("up", "time") AS "last"` + "\n" +
+					`FROM "s"."cpu"` + "\n" +
+					`GROUP BY time_bucket(INTERVAL '1 hour', "time"), "host"`,
+				`SELECT add_continuous_aggregate_policy('"s"."cpu_1h"', start_offset => INTERVAL '2 hours', end_offset => INTERVAL '1 hour', schedule_interval => INTERVAL '1 hour')`,
+			},
+		}, {
+			desc:  "offset, resample and conflicting names",
+			query: `CREATE CONTINUOUS QUERY cq ON db RESAMPLE EVERY 30m FOR 2d BEGIN SELECT sum(a), sum(b), median(c) INTO "rp"."m 1d" FROM "rp"."m" GROUP BY time(1d, 6h) END`,
+			statements: []string{
+				"CREATE MATERIALIZED VIEW \"m 1d\" WITH (timescaledb.continuous) AS\n" +
+					`SELECT time_bucket(INTERVAL '1 day', "time", INTERVAL '6 hours') AS "time", sum("a") AS "sum", sum("b") AS "sum_1"` + "\n" +
+					`FROM "m"` + "\n" +
+					`GROUP BY time_bucket(INTERVAL '1 day', "time", INTERVAL '6 hours')`,
+				`SELECT add_continuous_aggregate_policy('"m 1d"', start_offset => INTERVAL '3 days', end_offset => INTERVAL '1 day', schedule_interval => INTERVAL '30 minutes')`,
+			},
+			unsupported: []string{"function 'median' is not supported, field 'median(c)' is skipped"},
+		}, {
+			desc:        "no supported field",
+			query:       `CREATE CONTINUOUS QUERY cq ON db BEGIN SELECT percentile(a, 95) INTO m_1h FROM m GROUP BY time(1h) END`,
+			unsupported: []string{"function 'percentile' is not supported, field 'percentile(a, 95)' is skipped", "no field can be translated"},
+		}, {
+			desc:        "back reference",
+			query:       `CREATE CONTINUOUS QUERY cq ON db BEGIN SELECT mean(*) INTO "rp".:MEASUREMENT FROM /.*/ GROUP BY time(1h) END`,
+			unsupported: []string{"the INTO clause must name the target measurement, back references are not supported"},
+		}, {
+			desc:        "regex source",
+			query:       `CREATE CONTINUOUS QUERY cq ON db BEGIN SELECT mean(a) INTO t FROM /.*/ GROUP BY time(1h) END`,
+			unsupported: []string{"the source must be a measurement, regular expressions and subqueries are not supported"},
+		}, {
+			desc:        "where condition",
+			query:       `CREATE CONTINUOUS QUERY cq ON db BEGIN SELECT mean(a) INTO t FROM m WHERE host = 'a' GROUP BY time(1h) END`,
+			unsupported: []string{"WHERE conditions are not supported"},
+		}, {
+			desc:        "all tags",
+			query:       `CREATE CONTINUOUS QUERY cq ON db BEGIN SELECT mean(a) INTO t FROM m GROUP BY time(1h), * END`,
+			unsupported: []string{"GROUP BY * is not supported, tags must be listed by name"},
+		},
+	}
+
+	for _, tc := range testCases {
+		translation, err := Translate(&ContinuousQuery{Name: "cq", Query: tc.query}, tc.schema)
+		if !assert.NoError(t, err, tc.desc) {
+			continue
+		}
+
+		assert.Equal(t, tc.statements, translation.Statements, tc.desc)
+		assert.Equal(t, tc.unsupported, translation.Unsupported, tc.desc)
+	}
+}
+
+func TestTranslateErrors(t *testing.T) {
+	_, err := Translate(&ContinuousQuery{Query: "CREATE CONTINUOUS QUERY"}, "")
+	assert.Error(t, err)
+	_, err = Translate(&ContinuousQuery{Query: "SELECT * FROM m"}, "")
+	assert.Error(t, err)
+}
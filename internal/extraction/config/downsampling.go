@@ -0,0 +1,163 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/timescale/outflux/internal/idrf"
+)
+
+// Aggregate functions the fields of a downsampled measure can be wrapped in
+const (
+	AggregateMean  = "mean"
+	AggregateMax   = "max"
+	AggregateMin   = "min"
+	AggregateLast  = "last"
+	AggregateSum   = "sum"
+	AggregateCount = "count"
+)
+
+// fieldTypes maps the names of the InfluxDB field types to their IDRF data types
+var fieldTypes = map[string]idrf.DataType{
+	"float":   idrf.IDRFDouble,
+	"integer": idrf.IDRFInteger64,
+	"string":  idrf.IDRFString,
+	"boolean": idrf.IDRFBoolean,
+}
+
+// Resolution selects the interval the points older than a duration are aggregated to
+type Resolution struct {
+	// Interval of the GROUP BY time() clause
+	Interval time.Duration
+	// OlderThan is relative to the start of the migration, 0 applies the resolution to all points
+	OlderThan time.Duration
+}
+
+// Downsampling holds the resolutions of the time ranges of a measure, and the aggregate
+// each field is wrapped in depending on its type. Points not covered by a resolution
+// are extracted raw
+type Downsampling struct {
+	// Resolutions sorted by OlderThan
+	Resolutions []Resolution
+	Aggregates  map[idrf.DataType]string
+	// Now is the time the OlderThan durations are subtracted from, the same for all measures
+	Now time.Time
+}
+
+// NewDownsampling creates the downsampling config from the resolutions and the aggregates of each
+// InfluxDB field type ('float', 'integer', 'string' or 'boolean'). Strings and booleans can only be
+// aggregated with 'last' and 'count'. The resolutions must have different OlderThan durations
+func NewDownsampling(resolutions []Resolution, aggregates map[string]string, now time.Time) (*Downsampling, error) {
+	if len(resolutions) == 0 {
+		return nil, fmt.Errorf("at least one resolution must be specified")
+	}
+
+	sorted := make([]Resolution, len(resolutions))
+	copy(sorted, resolutions)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].OlderThan < sorted[j].OlderThan })
+	for i, resolution := range sorted {
+		if resolution.Interval <= 0 || resolution.OlderThan < 0 {
+			return nil, fmt.Errorf("the interval of a resolution must be > 0 and the duration it applies after >= 0")
+		}
+
+		if i > 0 && sorted[i-1].OlderThan == resolution.OlderThan {
+			return nil, fmt.Errorf("several resolutions apply to the points older than %s", resolution.OlderThan)
+		}
+	}
+
+	typeAggregates := make(map[idrf.DataType]string)
+	for fieldType, aggregate := range aggregates {
+		dataType, ok := fieldTypes[fieldType]
+		if !ok {
+			return nil, fmt.Errorf("unknown field type '%s', valid types are: float, integer, string, boolean", fieldType)
+		}
+
+		if err := validateAggregate(aggregate, dataType); err != nil {
+			return nil, fmt.Errorf("fields of type '%s' can't be aggregated\n%v", fieldType, err)
+		}
+
+		// raw and aggregated points are written to the same columns, a raw value is not a count
+		if aggregate == AggregateCount && sorted[0].OlderThan > 0 {
+			return nil, fmt.Errorf("'%s' can't be used when the newest points are extracted raw", AggregateCount)
+		}
+
+		typeAggregates[dataType] = aggregate
+	}
+
+	return &Downsampling{Resolutions: sorted, Aggregates: typeAggregates, Now: now}, nil
+}
+
+func validateAggregate(aggregate string, dataType idrf.DataType) error {
+	switch aggregate {
+	case AggregateLast, AggregateCount:
+		return nil
+	case AggregateMean, AggregateMax, AggregateMin, AggregateSum:
+		if dataType == idrf.IDRFDouble || dataType == idrf.IDRFInteger64 {
+			return nil
+		}
+
+		return fmt.Errorf("'%s' can only aggregate numeric fields", aggregate)
+	default:
+		valid := []string{AggregateMean, AggregateMax, AggregateMin, AggregateLast, AggregateSum, AggregateCount}
+		return fmt.Errorf("unknown aggregate '%s', valid aggregates are: %s", aggregate, strings.Join(valid, ", "))
+	}
+}
+
+// AggregateDataType returns the type of the values of an aggregate of a field. The mean of
+// integers is a float, and the count is always an integer
+func AggregateDataType(aggregate string, fieldType idrf.DataType) idrf.DataType {
+	switch aggregate {
+	case AggregateMean:
+		return idrf.IDRFDouble
+	case AggregateCount:
+		return idrf.IDRFInteger64
+	default:
+		return fieldType
+	}
+}
+
+// ExtractsRaw returns true if the points newer than the smallest OlderThan are extracted without aggregation
+func (d *Downsampling) ExtractsRaw() bool {
+	return d.Resolutions[0].OlderThan > 0
+}
+
+// IntervalAt returns the interval the points at a time are aggregated to, or 0 if they are extracted raw.
+// A zero time is the beginning of time
+func (d *Downsampling) IntervalAt(at time.Time) time.Duration {
+	interval := time.Duration(0)
+	for _, resolution := range d.Resolutions {
+		if resolution.OlderThan == 0 || at.IsZero() || at.Before(d.Boundary(resolution)) {
+			interval = resolution.Interval
+		}
+	}
+
+	return interval
+}
+
+// Boundary returns the time from which the points are no longer aggregated to the resolution. It
+// is aligned to the interval, so the last group of the resolution is not split
+func (d *Downsampling) Boundary(resolution Resolution) time.Time {
+	boundary := d.Now.Add(-resolution.OlderThan).UnixNano()
+	interval := int64(resolution.Interval)
+	// the groups of GROUP BY time() are aligned to the Unix epoch
+	offset := boundary % interval
+	if offset < 0 {
+		offset += interval
+	}
+
+	return time.Unix(0, boundary-offset).UTC()
+}
+
+// Boundaries returns the times where the resolution changes
+func (d *Downsampling) Boundaries() []time.Time {
+	boundaries := []time.Time{}
+	for _, resolution := range d.Resolutions {
+		if resolution.OlderThan > 0 {
+			boundaries = append(boundaries, d.Boundary(resolution))
+		}
+	}
+
+	return boundaries
+}
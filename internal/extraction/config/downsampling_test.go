@@ -0,0 +1,101 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/idrf"
+)
+
+func TestNewDownsampling(t *testing.T) {
+	now := time.Date(2019, 1, 31, 12, 30, 0, 0, time.UTC)
+	numeric := map[string]string{"float": AggregateMean, "integer": AggregateMax}
+	testCases := []struct {
+		desc        string
+		resolutions []Resolution
+		aggregates  map[string]string
+		expErr      bool
+	}{
+		{desc: "no resolutions", aggregates: numeric, expErr: true},
+		{desc: "zero interval", resolutions: []Resolution{{}}, aggregates: numeric, expErr: true},
+		{desc: "negative older than", resolutions: []Resolution{{Interval: time.Hour, OlderThan: -time.Hour}}, aggregates: numeric, expErr: true},
+		{
+			desc:        "same older than",
+			resolutions: []Resolution{{Interval: time.Hour, OlderThan: time.Hour}, {Interval: time.Minute, OlderThan: time.Hour}},
+			aggregates:  numeric,
+			expErr:      true,
+		},
+		{desc: "unknown field type", resolutions: []Resolution{{Interval: time.Hour}}, aggregates: map[string]string{"double": AggregateMean}, expErr: true},
+		{desc: "unknown aggregate", resolutions: []Resolution{{Interval: time.Hour}}, aggregates: map[string]string{"float": "median"}, expErr: true},
+		{desc: "mean of strings", resolutions: []Resolution{{Interval: time.Hour}}, aggregates: map[string]string{"string": AggregateMean}, expErr: true},
+		{desc: "count of newest raw points", resolutions: []Resolution{{Interval: time.Hour, OlderThan: time.Hour}}, aggregates: map[string]string{"float": AggregateCount}, expErr: true},
+		{desc: "count of all points", resolutions: []Resolution{{Interval: time.Hour}}, aggregates: map[string]string{"boolean": AggregateCount}},
+		{
+			desc:        "several resolutions",
+			resolutions: []Resolution{{Interval: time.Hour, OlderThan: 24 * time.Hour}, {Interval: time.Minute, OlderThan: time.Hour}},
+			aggregates:  map[string]string{"float": AggregateMean, "string": AggregateLast},
+		},
+	}
+
+	for _, tc := range testCases {
+		res, err := NewDownsampling(tc.resolutions, tc.aggregates, now)
+		if tc.expErr {
+			assert.Error(t, err, tc.desc)
+			continue
+		}
+
+		assert.NoError(t, err, tc.desc)
+		assert.Equal(t, now, res.Now, tc.desc)
+		assert.Equal(t, len(tc.aggregates), len(res.Aggregates), tc.desc)
+		for i := 1; i < len(res.Resolutions); i++ {
+			assert.True(t, res.Resolutions[i-1].OlderThan < res.Resolutions[i].OlderThan, tc.desc)
+		}
+	}
+}
+
+func TestAggregateDataType(t *testing.T) {
+	assert.Equal(t, idrf.IDRFDouble, AggregateDataType(AggregateMean, idrf.IDRFInteger64))
+	assert.Equal(t, idrf.IDRFInteger64, AggregateDataType(AggregateCount, idrf.IDRFString))
+	assert.Equal(t, idrf.IDRFInteger64, AggregateDataType(AggregateMax, idrf.IDRFInteger64))
+	assert.Equal(t, idrf.IDRFBoolean, AggregateDataType(AggregateLast, idrf.IDRFBoolean))
+}
+
+func TestDownsamplingBoundariesAndIntervals(t *testing.T) {
+	now := time.Date(2019, 1, 31, 12, 30, 0, 0, time.UTC)
+	aggregates := map[string]string{"float": AggregateMean}
+	resolutions := []Resolution{
+		{Interval: 24 * time.Hour, OlderThan: 30 * 24 * time.Hour},
+		{Interval: time.Hour, OlderThan: 24 * time.Hour},
+	}
+	downsampling, err := NewDownsampling(resolutions, aggregates, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assert.True(t, downsampling.ExtractsRaw())
+	hourly := time.Date(2019, 1, 30, 12, 0, 0, 0, time.UTC)
+	daily := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, []time.Time{hourly, daily}, downsampling.Boundaries())
+
+	testCases := []struct {
+		at  time.Time
+		exp time.Duration
+	}{
+		{at: time.Time{}, exp: 24 * time.Hour},
+		{at: daily.Add(-time.Nanosecond), exp: 24 * time.Hour},
+		{at: daily, exp: time.Hour},
+		{at: hourly.Add(-time.Nanosecond), exp: time.Hour},
+		{at: hourly, exp: 0},
+		{at: now, exp: 0},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.exp, downsampling.IntervalAt(tc.at), tc.at.String())
+	}
+
+	downsampling, _ = NewDownsampling([]Resolution{{Interval: time.Minute}}, aggregates, now)
+	assert.False(t, downsampling.ExtractsRaw())
+	assert.Equal(t, []time.Time{}, downsampling.Boundaries())
+	assert.Equal(t, time.Minute, downsampling.IntervalAt(now))
+}
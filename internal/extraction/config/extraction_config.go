@@ -15,17 +15,27 @@ type MeasureExtraction struct {
 	Measure                     string
 	From                        string
 	To                          string
+	ResumeFrom                  string
 	ChunkSize                   uint16
 	Limit                       uint64
 	SchemaOnly                  bool
 	RetentionPolicy             string
 	OnConflictConvertIntToFloat bool
+	Workers                     uint8
+	WindowSize                  time.Duration
+	Where                       string
+	Downsampling                *Downsampling
 }
 
 // ValidateMeasureExtractionConfig validates the fields
 // 'chunkSize' must be positive, specifies the number of rows the database server sends to the client at once
 // 'limit' if > 0 limits the number of points extracted from the measure, if == 0 all data is requested
 // 'from' and 'to' are timestamps and optional. If specified request data only between these timescamps
+// 'resumeFrom' is an optional timestamp loaded from a checkpoint, if specified it replaces 'from'
+// 'workers' if > 1 the measure is split in time windows that are extracted concurrently
+// 'windowSize' if > 0 the windows have a fixed size, if == 0 they are aligned to the shard groups
+// 'where' is an optional InfluxQL condition, if specified only the points matching it are extracted
+// 'downsampling' is optional, if specified the fields of the points are aggregated in groups of time
 func ValidateMeasureExtractionConfig(config *MeasureExtraction) error {
 	if config.Database == "" || config.Measure == "" {
 		return fmt.Errorf("database and measure can't be empty")
@@ -45,6 +55,25 @@ func ValidateMeasureExtractionConfig(config *MeasureExtraction) error {
 		return fmt.Errorf("'to' time must be formatted as %s", acceptedTimeFormat)
 	}
 
+	_, formatError = time.Parse(acceptedTimeFormat, config.ResumeFrom)
+	if config.ResumeFrom != "" && formatError != nil {
+		return fmt.Errorf("'resume from' time must be formatted as %s", acceptedTimeFormat)
+	}
+
+	if config.WindowSize < 0 {
+		return fmt.Errorf("window size can't be negative")
+	}
+
+	if config.Where != "" {
+		if _, err := ParseWhere(config.Where); err != nil {
+			return err
+		}
+	}
+
+	if config.Downsampling != nil && config.Limit > 0 {
+		return fmt.Errorf("the extracted points can't be limited when they are downsampled")
+	}
+
 	return nil
 }
 
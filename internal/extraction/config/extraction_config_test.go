@@ -2,6 +2,7 @@ package config
 
 import (
 	"testing"
+	"time"
 )
 
 func TestNewMeasureExtractionConfig(t *testing.T) {
@@ -13,6 +14,9 @@ func TestNewMeasureExtractionConfig(t *testing.T) {
 		{Database: "Db", Measure: "measure", From: "2019-01-01", ChunkSize: 1},
 		{Database: "Db", Measure: "measure", To: "2019-01-01T00:00:00", ChunkSize: 1},
 		{Database: "Db", Measure: "measure", To: "2019-01-01", ChunkSize: 1},
+		{Database: "Db", Measure: "measure", ResumeFrom: "2019-01-01", ChunkSize: 1},
+		{Database: "Db", Measure: "measure", ChunkSize: 1, Workers: 2, WindowSize: -time.Hour},
+		{Database: "Db", Measure: "measure", ChunkSize: 1, Where: "region ="},
 	}
 
 	for _, badCase := range badCases {
@@ -31,6 +35,9 @@ func TestNewMeasureExtractionConfig(t *testing.T) {
 		{Database: "Database", Measure: "Measure", ChunkSize: 1, From: "2019-01-01T00:00:00-01:00"},
 		{Database: "Database", Measure: "Measure", ChunkSize: 1, To: "2019-01-01T00:00:00-01:00"},
 		{Database: "Database", Measure: "Measure", ChunkSize: 1, From: "2019-01-01T00:00:00-01:00", To: "2019-01-01T00:00:00+01:00"},
+		{Database: "Database", Measure: "Measure", ChunkSize: 1, ResumeFrom: "2019-01-01T00:00:00.123456789Z"},
+		{Database: "Database", Measure: "Measure", ChunkSize: 1, Workers: 4, WindowSize: time.Hour},
+		{Database: "Database", Measure: "Measure", ChunkSize: 1, Where: "region = 'eu'"},
 	}
 
 	for _, goodCase := range goodCases {
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/influxdata/influxql"
+)
+
+const (
+	timeVarRef         = "time"
+	whereGroupTemplate = "(%s)"
+)
+
+// ParseWhere parses an InfluxQL condition that filters the extracted points, like: region = 'eu' AND usage > 0.
+// The time range is selected with 'from' and 'to', so the condition can't reference the time
+func ParseWhere(where string) (influxql.Expr, error) {
+	expr, err := influxql.ParseExpr(where)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' is not a valid InfluxQL condition\n%v", where, err)
+	}
+
+	if !isCondition(expr) {
+		return nil, fmt.Errorf("'%s' is not an InfluxQL condition", where)
+	}
+
+	var invalid error
+	influxql.WalkFunc(expr, func(node influxql.Node) {
+		switch typed := node.(type) {
+		case *influxql.VarRef:
+			if strings.EqualFold(typed.Val, timeVarRef) {
+				invalid = fmt.Errorf("'%s' can't reference the time, use 'from' and 'to' to select a time range", where)
+			}
+		case *influxql.Call:
+			invalid = fmt.Errorf("'%s' can't call functions, only tags and fields can be compared", where)
+		}
+	})
+
+	if invalid != nil {
+		return nil, invalid
+	}
+
+	return expr, nil
+}
+
+// CombineWhere ANDs the non-empty conditions. When there are several of them, each one
+// is put in parentheses so their operators don't mix
+func CombineWhere(conditions ...string) string {
+	nonEmpty := []string{}
+	for _, condition := range conditions {
+		if condition != "" {
+			nonEmpty = append(nonEmpty, condition)
+		}
+	}
+
+	if len(nonEmpty) == 1 {
+		return nonEmpty[0]
+	}
+
+	for i, condition := range nonEmpty {
+		nonEmpty[i] = fmt.Sprintf(whereGroupTemplate, condition)
+	}
+
+	return strings.Join(nonEmpty, " AND ")
+}
+
+func isCondition(expr influxql.Expr) bool {
+	switch typed := expr.(type) {
+	case *influxql.ParenExpr:
+		return isCondition(typed.Expr)
+	case *influxql.BinaryExpr:
+		switch typed.Op {
+		case influxql.AND, influxql.OR:
+			return isCondition(typed.LHS) && isCondition(typed.RHS)
+		case influxql.EQ, influxql.NEQ, influxql.EQREGEX, influxql.NEQREGEX,
+			influxql.LT, influxql.LTE, influxql.GT, influxql.GTE:
+			return true
+		}
+	case *influxql.BooleanLiteral:
+		return true
+	}
+
+	return false
+}
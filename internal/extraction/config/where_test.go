@@ -0,0 +1,41 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWhere(t *testing.T) {
+	valid := []string{
+		"region = 'eu'",
+		`"host name" =~ /^web/ AND (usage > 0.5 OR up = true)`,
+		"usage >= 1 and usage <= 2",
+		"true",
+	}
+	for _, where := range valid {
+		_, err := ParseWhere(where)
+		assert.NoError(t, err, where)
+	}
+
+	invalid := []string{
+		"region =",
+		"region",
+		"usage + 1",
+		"mean(usage) > 1",
+		"region = 'eu' AND usage",
+		"time > now() - 1h",
+		"region = 'eu' OR TIME > '2019-01-01T00:00:00Z'",
+	}
+	for _, where := range invalid {
+		_, err := ParseWhere(where)
+		assert.Error(t, err, where)
+	}
+}
+
+func TestCombineWhere(t *testing.T) {
+	assert.Equal(t, "", CombineWhere())
+	assert.Equal(t, "", CombineWhere("", ""))
+	assert.Equal(t, "a = 1 OR b = 2", CombineWhere("", "a = 1 OR b = 2"))
+	assert.Equal(t, "(a = 1 OR b = 2) AND (c = 3)", CombineWhere("a = 1 OR b = 2", "c = 3"))
+}
@@ -4,14 +4,27 @@ import (
 	"fmt"
 
 	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/timescale/outflux/internal/connections"
 	"github.com/timescale/outflux/internal/extraction/config"
 	influxExtraction "github.com/timescale/outflux/internal/extraction/influx"
+	influxV2Extraction "github.com/timescale/outflux/internal/extraction/influxv2"
+	lineProtocolExtraction "github.com/timescale/outflux/internal/extraction/lineprotocol"
+	tsExtraction "github.com/timescale/outflux/internal/extraction/ts"
+	tsmExtraction "github.com/timescale/outflux/internal/extraction/tsm"
 	"github.com/timescale/outflux/internal/schemamanagement"
+	"github.com/timescale/outflux/internal/schemamanagement/influx/discovery"
+	"github.com/timescale/outflux/internal/schemamanagement/influx/influxqueries"
+	"github.com/timescale/outflux/internal/schemamanagement/influxv2/fluxqueries"
+	"github.com/timescale/outflux/internal/schemamanagement/tsm/tsmstorage"
 )
 
 // ExtractorService defines methods for creating extractor instances
 type ExtractorService interface {
 	InfluxExtractor(influx.Client, *config.ExtractionConfig) (Extractor, error)
+	InfluxV2Extractor(connections.InfluxV2Client, *config.ExtractionConfig) (Extractor, error)
+	LineProtocolExtractor(path string, conf *config.ExtractionConfig) (Extractor, error)
+	TSMExtractor(storage *tsmstorage.Storage, conf *config.ExtractionConfig) (Extractor, error)
+	TimescaleExtractor(conn connections.PgxWrap, conf *config.ExtractionConfig) (Extractor, error)
 }
 
 // NewExtractorService creates a new instance of the service that can create extractors
@@ -36,5 +49,81 @@ func (e *extractorService) InfluxExtractor(conn influx.Client, conf *config.Extr
 		Config:       conf,
 		SM:           sm,
 		DataProducer: dataProducer,
+		Windows:      influxExtraction.NewWindowPlanner(conn, influxqueries.NewInfluxQueryService()),
+		TagExplorer:  discovery.NewTagExplorer(influxqueries.NewInfluxQueryService()),
+		Client:       conn,
+	}, nil
+}
+
+// InfluxV2Extractor creates an extractor that pulls the data of a measure out of an InfluxDB 2.x
+// bucket. The bucket is specified as the database of the measure extraction config
+func (e *extractorService) InfluxV2Extractor(client connections.InfluxV2Client, conf *config.ExtractionConfig) (Extractor, error) {
+	exConf := conf.MeasureExtraction
+	err := config.ValidateMeasureExtractionConfig(exConf)
+	if err != nil {
+		return nil, fmt.Errorf("measure extraction config is not valid: %s", err.Error())
+	}
+
+	sm := e.schemaManagerService.InfluxV2(client, exConf.Database, exConf.OnConflictConvertIntToFloat)
+	return &influxV2Extraction.Extractor{
+		Config:       conf,
+		SM:           sm,
+		Client:       client,
+		QueryService: fluxqueries.NewFluxQueryService(),
+	}, nil
+}
+
+// LineProtocolExtractor creates an extractor that reads the points of a measure from a line protocol
+// file or an 'influx_inspect export' dump
+func (e *extractorService) LineProtocolExtractor(path string, conf *config.ExtractionConfig) (Extractor, error) {
+	exConf := conf.MeasureExtraction
+	err := config.ValidateMeasureExtractionConfig(exConf)
+	if err != nil {
+		return nil, fmt.Errorf("measure extraction config is not valid: %s", err.Error())
+	}
+
+	sm := e.schemaManagerService.LineProtocol(path, exConf.Database, exConf.RetentionPolicy, exConf.OnConflictConvertIntToFloat)
+	// the schema manager records where the points of each measure are while discovering the schema,
+	// so each extractor reads only those parts of the file
+	sections, _ := sm.(lineProtocolExtraction.SectionFinder)
+	return &lineProtocolExtraction.Extractor{
+		Config:   conf,
+		SM:       sm,
+		Sections: sections,
+		Path:     path,
+	}, nil
+}
+
+// TSMExtractor creates an extractor that decodes the points of a measure from the TSM shards
+// and WAL of InfluxDB 1.x, or from a portable backup
+func (e *extractorService) TSMExtractor(storage *tsmstorage.Storage, conf *config.ExtractionConfig) (Extractor, error) {
+	exConf := conf.MeasureExtraction
+	err := config.ValidateMeasureExtractionConfig(exConf)
+	if err != nil {
+		return nil, fmt.Errorf("measure extraction config is not valid: %s", err.Error())
+	}
+
+	sm := e.schemaManagerService.TSM(storage, exConf.Database, exConf.RetentionPolicy, exConf.OnConflictConvertIntToFloat)
+	return &tsmExtraction.Extractor{
+		Config:  conf,
+		SM:      sm,
+		Storage: storage,
+	}, nil
+}
+
+// TimescaleExtractor creates an extractor that selects the rows of a table of TimescaleDB or PostgreSQL.
+// The schema of the table is specified as the database of the measure extraction config
+func (e *extractorService) TimescaleExtractor(conn connections.PgxWrap, conf *config.ExtractionConfig) (Extractor, error) {
+	exConf := conf.MeasureExtraction
+	err := config.ValidateMeasureExtractionConfig(exConf)
+	if err != nil {
+		return nil, fmt.Errorf("measure extraction config is not valid: %s", err.Error())
+	}
+
+	sm := e.schemaManagerService.TimeScale(conn, exConf.Database, "", 0, nil, nil, nil)
+	return &tsExtraction.Extractor{
+		Config: conf,
+		SM:     sm,
+		Conn:   conn,
 	}, nil
 }
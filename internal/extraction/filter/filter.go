@@ -0,0 +1,48 @@
+// Package filter evaluates the InfluxQL condition of an extraction on the rows read from
+// inputs that can't be queried with InfluxQL, like files and TSM shards
+package filter
+
+import (
+	"github.com/influxdata/influxql"
+	"github.com/timescale/outflux/internal/extraction/config"
+	"github.com/timescale/outflux/internal/idrf"
+)
+
+// Filter matches the rows of a data set against an InfluxQL condition.
+// A nil filter matches all rows
+type Filter struct {
+	expr    influxql.Expr
+	dataSet *idrf.DataSet
+}
+
+// New parses the condition for the rows of the data set. If the condition is empty, nil is returned
+func New(where string, dataSet *idrf.DataSet) (*Filter, error) {
+	if where == "" {
+		return nil, nil
+	}
+
+	expr, err := config.ParseWhere(where)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Filter{expr: expr, dataSet: dataSet}, nil
+}
+
+// Matches evaluates the condition with the tags and fields of the row. Like in InfluxDB,
+// a comparison with a tag or field that has no value in the row is false
+func (f *Filter) Matches(row idrf.Row) bool {
+	if f == nil {
+		return true
+	}
+
+	values := make(influxql.MapValuer, len(row))
+	for i, column := range f.dataSet.Columns {
+		if column.Name != f.dataSet.TimeColumn && row[i] != nil {
+			values[column.Name] = row[i]
+		}
+	}
+
+	eval := influxql.ValuerEval{Valuer: values}
+	return eval.EvalBool(f.expr)
+}
@@ -0,0 +1,52 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/idrf"
+)
+
+func TestMatches(t *testing.T) {
+	dataSet := &idrf.DataSet{
+		Columns: []*idrf.Column{
+			{Name: "time", DataType: idrf.IDRFTimestamptz},
+			{Name: "host name", DataType: idrf.IDRFString},
+			{Name: "usage", DataType: idrf.IDRFDouble},
+			{Name: "count", DataType: idrf.IDRFInteger64},
+			{Name: "up", DataType: idrf.IDRFBoolean},
+		},
+		TimeColumn: "time",
+	}
+	now := time.Now()
+	row := idrf.Row{now, "web1", 0.5, int64(3), true}
+	testCases := []struct {
+		where   string
+		row     idrf.Row
+		matches bool
+	}{
+		{where: `"host name" = 'web1'`, row: row, matches: true},
+		{where: `"host name" =~ /^db/`, row: row, matches: false},
+		{where: "usage > 0.1 AND count >= 3", row: row, matches: true},
+		{where: "usage > 1 OR up = false", row: row, matches: false},
+		{where: "count < 3.5", row: row, matches: true},
+		{where: "usage > 0.1", row: idrf.Row{now, "web1", nil, nil, nil}, matches: false},
+	}
+
+	for _, tc := range testCases {
+		filter, err := New(tc.where, dataSet)
+		assert.NoError(t, err, tc.where)
+		assert.Equal(t, tc.matches, filter.Matches(tc.row), tc.where)
+	}
+}
+
+func TestNew(t *testing.T) {
+	filter, err := New("", &idrf.DataSet{})
+	assert.NoError(t, err)
+	assert.Nil(t, filter)
+	assert.True(t, filter.Matches(idrf.Row{"anything"}), "nil filter matches all rows")
+
+	_, err = New("time > now()", &idrf.DataSet{})
+	assert.Error(t, err)
+}
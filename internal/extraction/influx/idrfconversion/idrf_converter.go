@@ -3,6 +3,7 @@ package idrfconversion
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/timescale/outflux/internal/idrf"
@@ -32,34 +33,83 @@ func (conv *defaultIdrfConverter) Convert(row []interface{}) (idrf.Row, error) {
 
 	converted := make([]interface{}, len(row))
 	for i, item := range row {
-		converted[i] = convertByType(item, conv.dataSet.Columns[i].DataType)
+		column := conv.dataSet.Columns[i]
+		value, err := convertByType(item, column.DataType)
+		if err != nil {
+			return nil, fmt.Errorf("could not convert value of column '%s'\n%v", column.Name, err)
+		}
+
+		converted[i] = value
 	}
 
 	return converted, nil
 }
 
-func convertByType(rawValue interface{}, expected idrf.DataType) interface{} {
+// convertByType converts a value decoded from the JSON response of InfluxDB. Numbers are
+// decoded as json.Number and times as nanoseconds since the Unix epoch, when the query
+// requests the 'ns' epoch, or as RFC3339 strings otherwise
+func convertByType(rawValue interface{}, expected idrf.DataType) (interface{}, error) {
 	if rawValue == nil {
-		return nil
+		return nil, nil
 	}
 
 	switch {
 	case expected == idrf.IDRFInteger32:
-		valAsInt64, _ := rawValue.(json.Number).Int64()
-		return int32(valAsInt64)
+		valAsInt64, err := asNumber(rawValue).Int64()
+		if err != nil || valAsInt64 < math.MinInt32 || valAsInt64 > math.MaxInt32 {
+			return nil, fmt.Errorf("value %v is not a 32bit integer", rawValue)
+		}
+
+		return int32(valAsInt64), nil
 	case expected == idrf.IDRFInteger64:
-		valAsInt64, _ := rawValue.(json.Number).Int64()
-		return valAsInt64
+		valAsInt64, err := asNumber(rawValue).Int64()
+		if err != nil {
+			return nil, fmt.Errorf("value %v is not a 64bit integer", rawValue)
+		}
+
+		return valAsInt64, nil
 	case expected == idrf.IDRFDouble:
-		valAsFloat64, _ := rawValue.(json.Number).Float64()
-		return valAsFloat64
+		valAsFloat64, err := asNumber(rawValue).Float64()
+		if err != nil {
+			return nil, fmt.Errorf("value %v is not a number", rawValue)
+		}
+
+		return valAsFloat64, nil
 	case expected == idrf.IDRFSingle:
-		valAsFloat64, _ := rawValue.(json.Number).Float64()
-		return float32(valAsFloat64)
+		valAsFloat64, err := asNumber(rawValue).Float64()
+		if err != nil {
+			return nil, fmt.Errorf("value %v is not a number", rawValue)
+		}
+
+		return float32(valAsFloat64), nil
 	case expected == idrf.IDRFTimestamptz || expected == idrf.IDRFTimestamp:
-		ts, _ := time.Parse(time.RFC3339, rawValue.(string))
-		return ts
+		return convertTime(rawValue)
 	default:
-		return rawValue
+		return rawValue, nil
+	}
+}
+
+func convertTime(rawValue interface{}) (time.Time, error) {
+	if asString, ok := rawValue.(string); ok {
+		ts, err := time.Parse(time.RFC3339Nano, asString)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("value %v is not a RFC3339 time", rawValue)
+		}
+
+		return ts, nil
+	}
+
+	nanos, err := asNumber(rawValue).Int64()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("value %v is not a time in nanoseconds", rawValue)
 	}
+
+	return time.Unix(0, nanos).UTC(), nil
+}
+
+// asNumber returns the value as a json.Number, or an empty one that fails to
+// convert if the value is not a number
+func asNumber(rawValue interface{}) json.Number {
+	number, _ := rawValue.(json.Number)
+	return number
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/timescale/outflux/internal/idrf"
 )
@@ -25,7 +26,11 @@ func TestConvertByType(t *testing.T) {
 	}
 
 	for _, tc := range tcs {
-		res := convertByType(tc.inVal, tc.inType)
+		res, err := convertByType(tc.inVal, tc.inType)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
 		if tc.inVal == nil {
 			if res != nil {
 				t.Errorf("nil expected, got: %v", res)
@@ -42,6 +47,43 @@ func TestConvertByType(t *testing.T) {
 	}
 }
 
+func TestConvertTime(t *testing.T) {
+	expected := time.Date(2019, 1, 1, 0, 0, 0, 123456789, time.UTC)
+	res, err := convertByType(json.Number("1546300800123456789"), idrf.IDRFTimestamptz)
+	if err != nil || res != expected {
+		t.Errorf("expected: %v, got: %v, err: %v", expected, res, err)
+	}
+
+	res, err = convertByType("2019-01-01T00:00:00.123456789Z", idrf.IDRFTimestamptz)
+	if err != nil || !res.(time.Time).Equal(expected) {
+		t.Errorf("expected: %v, got: %v, err: %v", expected, res, err)
+	}
+}
+
+func TestConvertByTypeErrors(t *testing.T) {
+	tcs := []struct {
+		inVal  interface{}
+		inType idrf.DataType
+	}{
+		{json.Number("1.5"), idrf.IDRFInteger64},
+		{json.Number("3000000000"), idrf.IDRFInteger32},
+		{"1", idrf.IDRFDouble},
+		{"2019-01-01", idrf.IDRFTimestamptz},
+		{json.Number("1.5"), idrf.IDRFTimestamp},
+	}
+
+	for _, tc := range tcs {
+		if _, err := convertByType(tc.inVal, tc.inType); err == nil {
+			t.Errorf("expected an error converting %v to %s, none received", tc.inVal, tc.inType)
+		}
+	}
+
+	conv := NewIdrfConverter(&idrf.DataSet{Columns: []*idrf.Column{{Name: "time", DataType: idrf.IDRFTimestamptz}}})
+	if _, err := conv.Convert([]interface{}{"not a time"}); err == nil {
+		t.Error("expected an error, none received")
+	}
+}
+
 func TestConvertValues(t *testing.T) {
 	testIn := make([]interface{}, 1)
 	testIn[0] = "1"
@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"sort"
+	"time"
 
+	"github.com/influxdata/influxdb/models"
 	"github.com/timescale/outflux/internal/extraction/influx/idrfconversion"
 
 	influx "github.com/influxdata/influxdb/client/v2"
@@ -33,6 +36,62 @@ type producerArgs struct {
 	errChannel  chan error
 	query       *influx.Query
 	converter   idrfconversion.IdrfConverter
+	// grouping is set for queries grouped by time and tags
+	grouping *seriesGrouping
+}
+
+// seriesGrouping aligns the series of a query grouped by all tags to the columns of the
+// data set. Each series holds the time and the aggregated fields, the tags are the same for
+// all of its rows
+type seriesGrouping struct {
+	columns   []string
+	tags      map[string]bool
+	timeIndex int
+}
+
+func newSeriesGrouping(dataSet *idrf.DataSet, tags map[string]bool) *seriesGrouping {
+	grouping := &seriesGrouping{columns: make([]string, len(dataSet.Columns)), tags: tags}
+	for i, column := range dataSet.Columns {
+		grouping.columns[i] = column.Name
+		if column.Name == dataSet.TimeColumn {
+			grouping.timeIndex = i
+		}
+	}
+
+	return grouping
+}
+
+// align returns the values of the series in the order of the data set columns
+func (g *seriesGrouping) align(series models.Row) ([][]interface{}, error) {
+	seriesColumns := make(map[string]int, len(series.Columns))
+	for i, column := range series.Columns {
+		seriesColumns[column] = i
+	}
+
+	aligned := make([][]interface{}, len(series.Values))
+	for i, values := range series.Values {
+		row := make([]interface{}, len(g.columns))
+		for j, column := range g.columns {
+			if g.tags[column] {
+				// series without a tag have it set to an empty string
+				if tag := series.Tags[column]; tag != "" {
+					row[j] = tag
+				}
+				continue
+			}
+
+			index, ok := seriesColumns[column]
+			if !ok || index >= len(values) {
+				return nil, fmt.Errorf("column '%s' not found in the series of the query", column)
+			}
+
+			row[j] = values[index]
+		}
+
+		aligned[i] = row
+	}
+
+	return aligned, nil
 }
 
 // Executes the select query and receives the chunked response, piping it to a data channel.
@@ -50,6 +109,7 @@ func (dp *defaultDataProducer) Fetch(args *producerArgs) error {
 	defer chunkResponse.Close()
 
 	totalRows := 0
+	grouped := []idrf.Row{}
 	for {
 		// Before requesting the next chunk, check if an error occurred in some other goroutine
 		if err = checkError(args.errChannel); err != nil {
@@ -59,7 +119,7 @@ func (dp *defaultDataProducer) Fetch(args *producerArgs) error {
 		response, err := chunkResponse.NextResponse()
 		if err != nil {
 			if err == io.EOF {
-				return nil
+				return dp.sendGrouped(args, grouped)
 			}
 
 			// If we got an error while decoding the response, send that back.
@@ -72,6 +132,18 @@ func (dp *defaultDataProducer) Fetch(args *producerArgs) error {
 		}
 
 		series := response.Results[0].Series
+		if args.grouping != nil {
+			if len(series) == 0 {
+				return dp.sendGrouped(args, grouped)
+			}
+
+			if grouped, err = dp.convertGrouped(args, series, grouped); err != nil {
+				return err
+			}
+
+			continue
+		}
+
 		if len(series) > 1 {
 			return fmt.Errorf("extractor '%s': returned response had an unexpected format", dp.extractorID)
 		} else if len(series) == 0 {
@@ -90,5 +162,51 @@ func (dp *defaultDataProducer) Fetch(args *producerArgs) error {
 			args.dataChannel <- convertedRow
 		}
 	}
+}
+
+// convertGrouped converts the rows of the series of a grouped query and appends them to the converted rows
+func (dp *defaultDataProducer) convertGrouped(args *producerArgs, series []models.Row, converted []idrf.Row) ([]idrf.Row, error) {
+	for _, rows := range series {
+		aligned, err := args.grouping.align(rows)
+		if err != nil {
+			return nil, fmt.Errorf("extractor '%s': returned response had an unexpected format\n%v", dp.extractorID, err)
+		}
+
+		for _, valRow := range aligned {
+			convertedRow, err := args.converter.Convert(valRow)
+			if err != nil {
+				return nil, fmt.Errorf("extractor '%s': could not convert influx result to IDRF row\n%v", dp.extractorID, err)
+			}
+
+			converted = append(converted, convertedRow)
+		}
+	}
+
+	log.Printf("%s: Extracted %d grouped rows from Influx", dp.extractorID, len(converted))
+	return converted, nil
+}
+
+// sendGrouped sends the rows of a grouped query ordered by time. The series of the query are
+// ordered by tags, so the rows can only be sent after all of them are received
+func (dp *defaultDataProducer) sendGrouped(args *producerArgs, rows []idrf.Row) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	timeIndex := args.grouping.timeIndex
+	sort.SliceStable(rows, func(i, j int) bool {
+		first, _ := rows[i][timeIndex].(time.Time)
+		second, _ := rows[j][timeIndex].(time.Time)
+		return first.Before(second)
+	})
+
+	for _, row := range rows {
+		if err := checkError(args.errChannel); err != nil {
+			return nil
+		}
+
+		args.dataChannel <- row
+	}
 
+	return nil
 }
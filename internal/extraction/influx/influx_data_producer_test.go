@@ -0,0 +1,68 @@
+package influx
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/extraction/influx/idrfconversion"
+	"github.com/timescale/outflux/internal/idrf"
+)
+
+func TestSeriesGroupingAlign(t *testing.T) {
+	dataSet := &idrf.DataSet{
+		DataSetName: "m",
+		Columns:     []*idrf.Column{{Name: "time"}, {Name: "host"}, {Name: "dc"}, {Name: "usage"}, {Name: "state"}},
+		TimeColumn:  "time",
+	}
+	grouping := newSeriesGrouping(dataSet, map[string]bool{"host": true, "dc": true})
+	series := models.Row{
+		Tags:    map[string]string{"host": "h1", "dc": ""},
+		Columns: []string{"time", "state", "usage"},
+		Values:  [][]interface{}{{1, "on", 1.5}, {2, nil, 2.5}},
+	}
+
+	aligned, err := grouping.align(series)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{1, "h1", nil, 1.5, "on"}, {2, "h1", nil, 2.5, nil}}, aligned)
+
+	series.Columns = []string{"time", "usage"}
+	series.Values = [][]interface{}{{1, 1.5}}
+	_, err = grouping.align(series)
+	assert.Error(t, err)
+}
+
+func TestFetchGroupedSortsByTime(t *testing.T) {
+	dataSet := &idrf.DataSet{
+		DataSetName: "m",
+		Columns:     []*idrf.Column{{Name: "time", DataType: idrf.IDRFTimestamptz}, {Name: "host", DataType: idrf.IDRFString}, {Name: "usage", DataType: idrf.IDRFDouble}},
+		TimeColumn:  "time",
+	}
+	args := &producerArgs{
+		dataChannel: make(chan idrf.Row, 4),
+		errChannel:  make(chan error, 1),
+		converter:   idrfconversion.NewIdrfConverter(dataSet),
+		grouping:    newSeriesGrouping(dataSet, map[string]bool{"host": true}),
+	}
+	series := []models.Row{
+		{Tags: map[string]string{"host": "h1"}, Columns: []string{"time", "usage"}, Values: [][]interface{}{{json.Number("0"), json.Number("1")}, {json.Number("60"), json.Number("2")}}},
+		{Tags: map[string]string{"host": "h2"}, Columns: []string{"time", "usage"}, Values: [][]interface{}{{json.Number("0"), json.Number("3")}, {json.Number("60"), json.Number("4")}}},
+	}
+
+	producer := &defaultDataProducer{extractorID: "ext"}
+	rows, err := producer.convertGrouped(args, series, []idrf.Row{})
+	assert.NoError(t, err)
+	assert.NoError(t, producer.sendGrouped(args, rows))
+	close(args.dataChannel)
+
+	t0, t1 := time.Unix(0, 0).UTC(), time.Unix(0, 60).UTC()
+	exp := []idrf.Row{{t0, "h1", 1.0}, {t0, "h2", 3.0}, {t1, "h1", 2.0}, {t1, "h2", 4.0}}
+	received := []idrf.Row{}
+	for row := range args.dataChannel {
+		received = append(received, row)
+	}
+
+	assert.Equal(t, exp, received)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/timescale/outflux/internal/extraction/influx/idrfconversion"
 	"github.com/timescale/outflux/internal/idrf"
 	"github.com/timescale/outflux/internal/schemamanagement"
+	"github.com/timescale/outflux/internal/schemamanagement/influx/discovery"
 )
 
 // Extractor is an implementation of the extraction.Extractor interface for
@@ -18,6 +19,13 @@ type Extractor struct {
 	SM                schemamanagement.SchemaManager
 	cachedElementData *idrf.Bundle
 	DataProducer      DataProducer
+	Windows           WindowPlanner
+	// TagExplorer and Client discover the tags the points are grouped by when downsampling
+	TagExplorer discovery.TagExplorer
+	Client      influx.Client
+	// aggregates holds the aggregate each field is wrapped in when downsampling
+	aggregates map[string]string
+	tags       map[string]bool
 }
 
 // ID of the extractor, useful for logging and error reporting
@@ -35,6 +43,12 @@ func (e *Extractor) Prepare() (*idrf.Bundle, error) {
 		return nil, fmt.Errorf("%s: could not fetch data set definition for measure: %s\n%v", e.ID(), measureName, err)
 	}
 
+	if e.Config.MeasureExtraction.Downsampling != nil {
+		if discoveredDataSet, err = e.aggregateDataSet(discoveredDataSet); err != nil {
+			return nil, fmt.Errorf("%s: could not downsample measure: %s\n%v", e.ID(), measureName, err)
+		}
+	}
+
 	log.Printf("Discovered: %s", discoveredDataSet.String())
 	e.cachedElementData = &idrf.Bundle{
 		DataDef:  discoveredDataSet,
@@ -56,12 +70,29 @@ func (e *Extractor) Start(errChan chan error) error {
 	measureConf := e.Config.MeasureExtraction
 
 	log.Printf("Starting extractor '%s' for measure: %s\n", id, dataDef.DataSetName)
+	if measureConf.Downsampling != nil {
+		return e.startDownsampled(errChan)
+	}
+
+	if e.extractsInParallel() {
+		windows, err := e.Windows.Plan(measureConf)
+		if err != nil {
+			close(e.cachedElementData.DataChan)
+			return fmt.Errorf("%s: could not split the measure in time windows\n%v", id, err)
+		}
+
+		if len(windows) > 1 {
+			return e.startParallel(errChan, windows, int(measureConf.Workers))
+		}
+	}
+
 	intChunkSize := int(measureConf.ChunkSize)
 
 	query := &influx.Query{
 		Command:         buildSelectCommand(measureConf, dataDef.Columns),
 		Database:        measureConf.Database,
 		RetentionPolicy: measureConf.RetentionPolicy,
+		Precision:       epochPrecision,
 		Chunked:         true,
 		ChunkSize:       intChunkSize,
 	}
@@ -80,3 +111,73 @@ func (e *Extractor) Start(errChan chan error) error {
 
 	return e.DataProducer.Fetch(producerArgs)
 }
+
+// extractsInParallel returns true if the measure should be split in time windows
+// that are extracted concurrently. A limited extraction is always done with a single query
+func (e *Extractor) extractsInParallel() bool {
+	measureConf := e.Config.MeasureExtraction
+	return e.Windows != nil && measureConf.Workers > 1 && measureConf.Limit == 0
+}
+
+// aggregateDataSet returns the data set of the downsampled measure. The fields keep their
+// names, and their types become the types of the aggregates they are wrapped in
+func (e *Extractor) aggregateDataSet(dataSet *idrf.DataSet) (*idrf.DataSet, error) {
+	measureConf := e.Config.MeasureExtraction
+	tags, err := e.TagExplorer.DiscoverMeasurementTags(e.Client, measureConf.Database, measureConf.RetentionPolicy, measureConf.Measure)
+	if err != nil {
+		return nil, err
+	}
+
+	e.tags = make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		e.tags[tag.Name] = true
+	}
+
+	e.aggregates = make(map[string]string)
+	columns := make([]*idrf.Column, len(dataSet.Columns))
+	for i, column := range dataSet.Columns {
+		if column.Name == dataSet.TimeColumn || e.tags[column.Name] {
+			columns[i] = column
+			continue
+		}
+
+		aggregate, ok := measureConf.Downsampling.Aggregates[column.DataType]
+		if !ok {
+			return nil, fmt.Errorf("no aggregate specified for field '%s' of type %s", column.Name, column.DataType)
+		}
+
+		e.aggregates[column.Name] = aggregate
+		if columns[i], err = idrf.NewColumn(column.Name, config.AggregateDataType(aggregate, column.DataType)); err != nil {
+			return nil, err
+		}
+	}
+
+	return idrf.NewDataSet(dataSet.DataSetName, columns, dataSet.TimeColumn)
+}
+
+// startDownsampled extracts the time ranges of each resolution with separate queries. The grouped
+// rows of a query are held in memory to be sorted by time, so the measure is always split in time
+// windows, even without parallel extraction the windows are extracted one after the other
+func (e *Extractor) startDownsampled(errChan chan error) error {
+	measureConf := e.Config.MeasureExtraction
+	requested, err := requestedRange(measureConf)
+	if err != nil {
+		close(e.cachedElementData.DataChan)
+		return fmt.Errorf("%s: could not parse the requested time range\n%v", e.ID(), err)
+	}
+
+	windows := []timeWindow{requested}
+	if e.Windows != nil {
+		if windows, err = e.Windows.Plan(measureConf); err != nil {
+			close(e.cachedElementData.DataChan)
+			return fmt.Errorf("%s: could not split the measure in time windows\n%v", e.ID(), err)
+		}
+	}
+
+	workers := 1
+	if e.extractsInParallel() {
+		workers = int(measureConf.Workers)
+	}
+
+	return e.startParallel(errChan, downsampleWindows(windows, measureConf.Downsampling), workers)
+}
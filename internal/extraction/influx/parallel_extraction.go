@@ -0,0 +1,141 @@
+package influx
+
+import (
+	"fmt"
+	"log"
+
+	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/timescale/outflux/internal/extraction/influx/idrfconversion"
+	"github.com/timescale/outflux/internal/idrf"
+)
+
+// windowFetch holds the channels of the producer extracting a single time window
+type windowFetch struct {
+	window timeWindow
+	rows   chan idrf.Row
+	stop   chan error
+	done   chan error
+}
+
+// startParallel extracts the windows with up to 'workers' concurrent queries. The rows of
+// each window are forwarded to the data channel only after all the rows of the previous windows,
+// so the ingestor receives the points in the same order as when extracted with a single query.
+func (e *Extractor) startParallel(errChan chan error, windows []timeWindow, workers int) error {
+	id := e.Config.ExtractorID
+	dataChan := e.cachedElementData.DataChan
+	defer close(dataChan)
+
+	log.Printf("%s: Extracting %d time windows with %d workers\n", id, len(windows), workers)
+	fetches := make([]*windowFetch, len(windows))
+	for i, window := range windows {
+		fetches[i] = &windowFetch{
+			window: window,
+			rows:   make(chan idrf.Row, e.Config.DataBufferSize),
+			stop:   make(chan error, 1),
+			done:   make(chan error, 1),
+		}
+	}
+
+	quit := make(chan struct{})
+	go e.dispatchWindows(fetches, workers, quit)
+	for i, fetch := range fetches {
+		if !forwardWindow(fetch, dataChan, errChan) {
+			abortWindows(fetches[i:], quit)
+			return nil
+		}
+
+		if err := <-fetch.done; err != nil {
+			abortWindows(fetches[i+1:], quit)
+			return fmt.Errorf("%s: could not extract the points in %s\n%v", id, fetch.window, err)
+		}
+	}
+
+	return nil
+}
+
+// dispatchWindows starts a producer for each window, never running more than
+// 'workers' producers at once. Windows not dispatched before 'quit' is closed are skipped.
+func (e *Extractor) dispatchWindows(fetches []*windowFetch, workers int, quit chan struct{}) {
+	measureConf := e.Config.MeasureExtraction
+	dataDef := e.cachedElementData.DataDef
+	semaphore := make(chan struct{}, workers)
+	for i, fetch := range fetches {
+		select {
+		case semaphore <- struct{}{}:
+		case <-quit:
+			for _, skipped := range fetches[i:] {
+				close(skipped.rows)
+				skipped.done <- nil
+			}
+			return
+		}
+
+		command := buildWindowSelectCommand(measureConf, dataDef.Columns, fetch.window)
+		var grouping *seriesGrouping
+		if fetch.window.interval > 0 {
+			command = buildDownsampledSelectCommand(measureConf, dataDef.Columns, e.aggregates, fetch.window)
+			grouping = newSeriesGrouping(dataDef, e.tags)
+		}
+
+		query := &influx.Query{
+			Command:         command,
+			Database:        measureConf.Database,
+			RetentionPolicy: measureConf.RetentionPolicy,
+			Precision:       epochPrecision,
+			Chunked:         true,
+			ChunkSize:       int(measureConf.ChunkSize),
+		}
+
+		log.Printf("%s: %s\n", e.ID(), query.Command)
+		args := &producerArgs{
+			dataChannel: fetch.rows,
+			errChannel:  fetch.stop,
+			query:       query,
+			converter:   idrfconversion.NewIdrfConverter(dataDef),
+			grouping:    grouping,
+		}
+
+		go func(fetch *windowFetch) {
+			err := e.DataProducer.Fetch(args)
+			<-semaphore
+			fetch.done <- err
+		}(fetch)
+	}
+}
+
+// forwardWindow passes the rows of a window to the data channel until the window
+// is exhausted. Returns false if an external error was received in the meantime.
+func forwardWindow(fetch *windowFetch, dataChan chan idrf.Row, errChan chan error) bool {
+	for {
+		select {
+		case row, ok := <-fetch.rows:
+			if !ok {
+				return true
+			}
+
+			select {
+			case dataChan <- row:
+			case <-errChan:
+				return false
+			}
+		case <-errChan:
+			return false
+		}
+	}
+}
+
+// abortWindows stops the producers of the remaining windows and discards the rows they
+// already extracted, so none of them stays blocked on a full channel
+func abortWindows(fetches []*windowFetch, quit chan struct{}) {
+	for _, fetch := range fetches {
+		fetch.stop <- fmt.Errorf("extraction aborted")
+	}
+
+	close(quit)
+	for _, fetch := range fetches {
+		go func(rows chan idrf.Row) {
+			for range rows {
+			}
+		}(fetch.rows)
+	}
+}
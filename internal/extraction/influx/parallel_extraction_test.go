@@ -0,0 +1,183 @@
+package influx
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/extraction/config"
+	"github.com/timescale/outflux/internal/idrf"
+)
+
+type mockWindowPlanner struct {
+	windows []timeWindow
+	err     error
+}
+
+func (m *mockWindowPlanner) Plan(conf *config.MeasureExtraction) ([]timeWindow, error) {
+	return m.windows, m.err
+}
+
+type mockWindowProducer struct {
+	rows   map[string][]idrf.Row
+	delays map[string]time.Duration
+	errs   map[string]error
+	lock   sync.Mutex
+	calls  []string
+}
+
+func (m *mockWindowProducer) Fetch(args *producerArgs) error {
+	defer close(args.dataChannel)
+	command := args.query.Command
+	m.lock.Lock()
+	m.calls = append(m.calls, command)
+	m.lock.Unlock()
+	time.Sleep(m.delays[command])
+	for _, row := range m.rows[command] {
+		if err := checkError(args.errChannel); err != nil {
+			return nil
+		}
+
+		args.dataChannel <- row
+	}
+
+	return m.errs[command]
+}
+
+func newParallelExtractor(producer DataProducer, windows []timeWindow) *Extractor {
+	dataSet := &idrf.DataSet{DataSetName: "m", Columns: []*idrf.Column{{Name: "time", DataType: idrf.IDRFTimestamp}}, TimeColumn: "time"}
+	return &Extractor{
+		Config: &config.ExtractionConfig{
+			ExtractorID:       "ext",
+			MeasureExtraction: &config.MeasureExtraction{Database: "db", Measure: "m", ChunkSize: 1, Workers: 3},
+			DataBufferSize:    1,
+		},
+		DataProducer:      producer,
+		Windows:           &mockWindowPlanner{windows: windows},
+		cachedElementData: &idrf.Bundle{DataDef: dataSet, DataChan: make(chan idrf.Row, 1)},
+	}
+}
+
+func testWindows() ([]timeWindow, []string) {
+	b1 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	b2 := b1.Add(time.Hour)
+	windows := []timeWindow{{to: b1}, {from: b1, to: b2}, {from: b2, toInclusive: true}}
+	commands := make([]string, len(windows))
+	for i, window := range windows {
+		commands[i] = buildWindowSelectCommand(&config.MeasureExtraction{Measure: "m"}, []*idrf.Column{{Name: "time"}}, window)
+	}
+
+	return windows, commands
+}
+
+func TestStartParallelKeepsWindowOrder(t *testing.T) {
+	windows, commands := testWindows()
+	producer := &mockWindowProducer{
+		rows: map[string][]idrf.Row{
+			commands[0]: {{1}, {2}},
+			commands[1]: {{3}},
+			commands[2]: {{4}, {5}, {6}},
+		},
+		delays: map[string]time.Duration{commands[0]: 20 * time.Millisecond},
+	}
+
+	extractor := newParallelExtractor(producer, windows)
+	dataChan := extractor.cachedElementData.DataChan
+	errChan := make(chan error, 1)
+	var err error
+	done := make(chan struct{})
+	go func() {
+		err = extractor.Start(errChan)
+		close(done)
+	}()
+
+	received := []idrf.Row{}
+	for row := range dataChan {
+		received = append(received, row)
+	}
+
+	<-done
+	assert.NoError(t, err)
+	assert.Equal(t, []idrf.Row{{1}, {2}, {3}, {4}, {5}, {6}}, received)
+	assert.ElementsMatch(t, commands, producer.calls)
+}
+
+func TestStartParallelWindowError(t *testing.T) {
+	windows, commands := testWindows()
+	producer := &mockWindowProducer{
+		rows: map[string][]idrf.Row{
+			commands[0]: {{1}},
+			commands[2]: {{4}, {5}, {6}},
+		},
+		errs: map[string]error{commands[1]: fmt.Errorf("generic error")},
+	}
+
+	extractor := newParallelExtractor(producer, windows)
+	dataChan := extractor.cachedElementData.DataChan
+	var err error
+	done := make(chan struct{})
+	go func() {
+		err = extractor.Start(make(chan error, 1))
+		close(done)
+	}()
+
+	received := []idrf.Row{}
+	for row := range dataChan {
+		received = append(received, row)
+	}
+
+	<-done
+	assert.Error(t, err)
+	assert.Equal(t, []idrf.Row{{1}}, received)
+}
+
+func TestStartParallelExternalError(t *testing.T) {
+	windows, commands := testWindows()
+	producer := &mockWindowProducer{
+		rows: map[string][]idrf.Row{
+			commands[0]: {{1}, {2}, {3}},
+			commands[1]: {{4}, {5}, {6}},
+			commands[2]: {{7}, {8}, {9}},
+		},
+	}
+
+	extractor := newParallelExtractor(producer, windows)
+	errChan := make(chan error, 1)
+	errChan <- fmt.Errorf("external error")
+	assert.NoError(t, extractor.Start(errChan))
+	_, open := <-extractor.cachedElementData.DataChan
+	for open {
+		_, open = <-extractor.cachedElementData.DataChan
+	}
+}
+
+func TestStartWithLimitIsNotParallel(t *testing.T) {
+	extractor := newParallelExtractor(nil, nil)
+	assert.True(t, extractor.extractsInParallel())
+	extractor.Config.MeasureExtraction.Limit = 1
+	assert.False(t, extractor.extractsInParallel())
+	extractor.Config.MeasureExtraction.Limit = 0
+	extractor.Config.MeasureExtraction.Workers = 1
+	assert.False(t, extractor.extractsInParallel())
+}
+
+func TestStartDownsampledSplitsWindowsWithOneWorker(t *testing.T) {
+	b1 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	b2 := b1.Add(24 * time.Hour)
+	downsampling, err := config.NewDownsampling([]config.Resolution{{Interval: time.Hour}}, map[string]string{}, b2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	producer := &mockWindowProducer{}
+	extractor := newParallelExtractor(producer, []timeWindow{{from: b1, to: b2}, {from: b2, toInclusive: true}})
+	extractor.Config.MeasureExtraction.Workers = 1
+	extractor.Config.MeasureExtraction.Downsampling = downsampling
+	assert.NoError(t, extractor.Start(make(chan error, 1)))
+	assert.Equal(t, 2, len(producer.calls))
+	for _, command := range producer.calls {
+		assert.Contains(t, command, "GROUP BY time(1h)")
+	}
+}
@@ -4,34 +4,46 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/influxdata/influxql"
 	"github.com/timescale/outflux/internal/extraction/config"
 	"github.com/timescale/outflux/internal/idrf"
 )
 
 const (
-	selectQueryDoubleBoundTemplate = "SELECT %s FROM %s WHERE time >= '%s' AND time <= '%s'"
-	selectQueryLowerBoundTemplate  = "SELECT %s FROM %s WHERE time >= '%s'"
-	selectQueryUpperBoundTemplate  = "SELECT %s FROM %s WHERE time <= '%s'"
-	selectQueryNoBoundTemplate     = "SELECT %s FROM %s"
-	limitSuffixTemplate            = "LIMIT %d"
-	measurementNameTemplate        = `"%s"`
-	measurementNameWithRPTemplate  = `"%s"."%s"`
+	selectQueryNoBoundTemplate    = "SELECT %s FROM %s"
+	limitSuffixTemplate           = "LIMIT %d"
+	measurementNameTemplate       = `"%s"`
+	measurementNameWithRPTemplate = `"%s"."%s"`
+	whereConditionTemplate        = "(%s)"
+	aggregateProjectionTemplate   = `%s("%s") AS "%s"`
+	// tags are returned as the tags of the series, and empty groups are skipped
+	groupByTimeTemplate = " GROUP BY time(%s), * fill(none)"
+	// times are requested as nanoseconds since the Unix epoch, so they are not rounded
+	// by the RFC3339 formatting of the response
+	epochPrecision = "ns"
 )
 
 func buildSelectCommand(config *config.MeasureExtraction, columns []*idrf.Column) string {
 	projection := buildProjection(columns)
 	measurementName := buildMeasurementName(config.RetentionPolicy, config.Measure)
-	var command string
-	if config.From != "" && config.To != "" {
-		command = fmt.Sprintf(selectQueryDoubleBoundTemplate, projection, measurementName, config.From, config.To)
-	} else if config.From != "" {
-		command = fmt.Sprintf(selectQueryLowerBoundTemplate, projection, measurementName, config.From)
-	} else if config.To != "" {
-		command = fmt.Sprintf(selectQueryUpperBoundTemplate, projection, measurementName, config.To)
-	} else {
-		command = fmt.Sprintf(selectQueryNoBoundTemplate, projection, measurementName)
+	// a checkpoint from a previous run takes precedence over the requested lower bound
+	from := config.From
+	if config.ResumeFrom != "" {
+		from = config.ResumeFrom
 	}
 
+	conditions := []string{}
+	if from != "" {
+		conditions = append(conditions, fmt.Sprintf(lowerBoundConditionTemplate, from))
+	}
+
+	if config.To != "" {
+		conditions = append(conditions, fmt.Sprintf(inclusiveUpperBoundTemplate, config.To))
+	}
+
+	command := fmt.Sprintf(selectQueryNoBoundTemplate, projection, measurementName)
+	command += buildWhereClause(append(conditions, whereConditions(config)...))
+
 	if config.Limit == 0 {
 		return command
 	}
@@ -40,6 +52,48 @@ func buildSelectCommand(config *config.MeasureExtraction, columns []*idrf.Column
 	return fmt.Sprintf("%s %s", command, limit)
 }
 
+// buildWindowSelectCommand builds a query for the points of a single time window.
+// The limit of the config is not applied, a limited extraction is never split into windows
+func buildWindowSelectCommand(config *config.MeasureExtraction, columns []*idrf.Column, window timeWindow) string {
+	projection := buildProjection(columns)
+	measurementName := buildMeasurementName(config.RetentionPolicy, config.Measure)
+	command := fmt.Sprintf(selectQueryNoBoundTemplate, projection, measurementName)
+	return command + buildWhereClause(append(window.conditions(), whereConditions(config)...))
+}
+
+// buildDownsampledSelectCommand builds a query for the points of a time window that wraps each field in its
+// aggregate, and groups the points by time in the interval of the window and by all tags
+func buildDownsampledSelectCommand(config *config.MeasureExtraction, columns []*idrf.Column, aggregates map[string]string, window timeWindow) string {
+	projection := []string{}
+	for _, column := range columns {
+		if aggregate, ok := aggregates[column.Name]; ok {
+			projection = append(projection, fmt.Sprintf(aggregateProjectionTemplate, aggregate, column.Name, column.Name))
+		}
+	}
+
+	measurementName := buildMeasurementName(config.RetentionPolicy, config.Measure)
+	command := fmt.Sprintf(selectQueryNoBoundTemplate, strings.Join(projection, ", "), measurementName)
+	command += buildWhereClause(append(window.conditions(), whereConditions(config)...))
+	return command + fmt.Sprintf(groupByTimeTemplate, influxql.FormatDuration(window.interval))
+}
+
+// whereConditions returns the condition filtering the points of the measure, ANDed with the time range
+func whereConditions(config *config.MeasureExtraction) []string {
+	if config.Where == "" {
+		return nil
+	}
+
+	return []string{fmt.Sprintf(whereConditionTemplate, config.Where)}
+}
+
+func buildWhereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
 func buildMeasurementName(rp, measurement string) string {
 	if rp != "" {
 		return fmt.Sprintf(measurementNameWithRPTemplate, rp, measurement)
@@ -2,6 +2,7 @@ package influx
 
 import (
 	"testing"
+	"time"
 
 	"github.com/timescale/outflux/internal/extraction/config"
 	"github.com/timescale/outflux/internal/idrf"
@@ -52,7 +53,9 @@ func TestBuildSelectCommand(t *testing.T) {
 		columns []*idrf.Column
 		from    string
 		to      string
+		resume  string
 		limit   uint64
+		where   string
 		exp     string
 	}{
 		{
@@ -87,6 +90,31 @@ func TestBuildSelectCommand(t *testing.T) {
 			from:    "a",
 			to:      "b",
 			exp:     `SELECT "col1" FROM "rep pol"."m" WHERE time >= 'a' AND time <= 'b'`,
+		}, {
+			measure: "m",
+			columns: []*idrf.Column{{Name: "col1"}},
+			from:    "a",
+			to:      "b",
+			resume:  "c",
+			exp:     `SELECT "col1" FROM "m" WHERE time >= 'c' AND time <= 'b'`,
+		}, {
+			measure: "m",
+			columns: []*idrf.Column{{Name: "col1"}},
+			resume:  "c",
+			exp:     `SELECT "col1" FROM "m" WHERE time >= 'c'`,
+		}, {
+			measure: "m",
+			columns: []*idrf.Column{{Name: "col1"}},
+			where:   "region = 'eu' OR host = 'a'",
+			limit:   1,
+			exp:     `SELECT "col1" FROM "m" WHERE (region = 'eu' OR host = 'a') LIMIT 1`,
+		}, {
+			measure: "m",
+			columns: []*idrf.Column{{Name: "col1"}},
+			from:    "a",
+			to:      "b",
+			where:   "region = 'eu'",
+			exp:     `SELECT "col1" FROM "m" WHERE time >= 'a' AND time <= 'b' AND (region = 'eu')`,
 		},
 	}
 
@@ -96,7 +124,9 @@ func TestBuildSelectCommand(t *testing.T) {
 			RetentionPolicy: tc.rp,
 			From:            tc.from,
 			To:              tc.to,
+			ResumeFrom:      tc.resume,
 			Limit:           tc.limit,
+			Where:           tc.where,
 		}
 
 		out := buildSelectCommand(config, tc.columns)
@@ -105,3 +135,55 @@ func TestBuildSelectCommand(t *testing.T) {
 		}
 	}
 }
+
+func TestBuildWindowSelectCommand(t *testing.T) {
+	from := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+	columns := []*idrf.Column{{Name: "col1"}}
+	testCases := []struct {
+		window timeWindow
+		exp    string
+	}{
+		{window: timeWindow{}, exp: `SELECT "col1" FROM "rp"."m"`},
+		{window: timeWindow{from: from}, exp: `SELECT "col1" FROM "rp"."m" WHERE time >= '2019-01-01T00:00:00Z'`},
+		{window: timeWindow{to: to}, exp: `SELECT "col1" FROM "rp"."m" WHERE time < '2019-01-01T01:00:00Z'`},
+		{
+			window: timeWindow{from: from, to: to, toInclusive: true},
+			exp:    `SELECT "col1" FROM "rp"."m" WHERE time >= '2019-01-01T00:00:00Z' AND time <= '2019-01-01T01:00:00Z'`,
+		},
+	}
+
+	config := &config.MeasureExtraction{Measure: "m", RetentionPolicy: "rp", From: "ignored", Limit: 10}
+	for _, tc := range testCases {
+		out := buildWindowSelectCommand(config, columns, tc.window)
+		if out != tc.exp {
+			t.Errorf("expected: %s, got: %s", tc.exp, out)
+		}
+	}
+
+	config.Where = "region = 'eu'"
+	out := buildWindowSelectCommand(config, columns, timeWindow{from: from})
+	exp := `SELECT "col1" FROM "rp"."m" WHERE time >= '2019-01-01T00:00:00Z' AND (region = 'eu')`
+	if out != exp {
+		t.Errorf("expected: %s, got: %s", exp, out)
+	}
+}
+
+func TestBuildDownsampledSelectCommand(t *testing.T) {
+	from := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	columns := []*idrf.Column{{Name: "time"}, {Name: "host"}, {Name: "usage"}, {Name: "state"}}
+	aggregates := map[string]string{"usage": config.AggregateMean, "state": config.AggregateLast}
+	config := &config.MeasureExtraction{Measure: "m", RetentionPolicy: "rp"}
+	out := buildDownsampledSelectCommand(config, columns, aggregates, timeWindow{from: from, interval: 90 * time.Minute})
+	exp := `SELECT mean("usage") AS "usage", last("state") AS "state" FROM "rp"."m" WHERE time >= '2019-01-01T00:00:00Z' GROUP BY time(90m), * fill(none)`
+	if out != exp {
+		t.Errorf("expected: %s, got: %s", exp, out)
+	}
+
+	config.Where = "region = 'eu'"
+	out = buildDownsampledSelectCommand(config, columns, aggregates, timeWindow{interval: 24 * time.Hour})
+	exp = `SELECT mean("usage") AS "usage", last("state") AS "state" FROM "rp"."m" WHERE (region = 'eu') GROUP BY time(1d), * fill(none)`
+	if out != exp {
+		t.Errorf("expected: %s, got: %s", exp, out)
+	}
+}
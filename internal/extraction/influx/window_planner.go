@@ -0,0 +1,412 @@
+package influx
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/timescale/outflux/internal/extraction/config"
+	"github.com/timescale/outflux/internal/schemamanagement/influx/influxqueries"
+)
+
+const (
+	showShardsQuery             = "SHOW SHARDS"
+	firstPointQueryTemplate     = "SELECT * FROM %s%s ORDER BY time ASC LIMIT 1"
+	lastPointQueryTemplate      = "SELECT * FROM %s%s ORDER BY time DESC LIMIT 1"
+	shardDatabaseColumn         = "database"
+	shardRetentionPolicyColumn  = "retention_policy"
+	shardStartTimeColumn        = "start_time"
+	shardEndTimeColumn          = "end_time"
+	lowerBoundConditionTemplate = "time >= '%s'"
+	upperBoundConditionTemplate = "time < '%s'"
+	inclusiveUpperBoundTemplate = "time <= '%s'"
+	// DefaultWindowSize is used to split a measure in fixed windows when the
+	// shard groups can't be read and no window size was requested
+	DefaultWindowSize = 7 * 24 * time.Hour
+	// maxGroupsPerWindow is the most groups of its interval a downsampled window holds, the grouped
+	// rows of a window are kept in memory until they are sorted by time
+	maxGroupsPerWindow = 1000
+)
+
+// timeWindow is a part of the time range of a measure, extracted with a single query.
+// A zero 'from' or 'to' leaves the window unbounded on that side. If 'interval' is > 0
+// the points of the window are downsampled to groups of that interval
+type timeWindow struct {
+	from        time.Time
+	to          time.Time
+	toInclusive bool
+	interval    time.Duration
+}
+
+func (w timeWindow) conditions() []string {
+	conditions := []string{}
+	if !w.from.IsZero() {
+		conditions = append(conditions, fmt.Sprintf(lowerBoundConditionTemplate, w.from.Format(time.RFC3339Nano)))
+	}
+
+	if w.to.IsZero() {
+		return conditions
+	}
+
+	template := upperBoundConditionTemplate
+	if w.toInclusive {
+		template = inclusiveUpperBoundTemplate
+	}
+
+	return append(conditions, fmt.Sprintf(template, w.to.Format(time.RFC3339Nano)))
+}
+
+func (w timeWindow) String() string {
+	from, to := "-inf", "+inf"
+	if !w.from.IsZero() {
+		from = w.from.Format(time.RFC3339Nano)
+	}
+
+	if !w.to.IsZero() {
+		to = w.to.Format(time.RFC3339Nano)
+	}
+
+	closing := ")"
+	if w.toInclusive {
+		closing = "]"
+	}
+
+	if w.interval > 0 {
+		return fmt.Sprintf("[%s, %s%s in groups of %s", from, to, closing, w.interval)
+	}
+
+	return fmt.Sprintf("[%s, %s%s", from, to, closing)
+}
+
+// WindowPlanner splits the requested time range of a measure into consecutive
+// windows that can be extracted concurrently
+type WindowPlanner interface {
+	Plan(conf *config.MeasureExtraction) ([]timeWindow, error)
+}
+
+// NewWindowPlanner creates a WindowPlanner that aligns the windows to the shard groups
+// of the retention policy, or to fixed windows of the configured size
+func NewWindowPlanner(influxClient influx.Client, queryService influxqueries.InfluxQueryService) WindowPlanner {
+	return &defaultWindowPlanner{influxClient, queryService}
+}
+
+type defaultWindowPlanner struct {
+	influxClient influx.Client
+	queryService influxqueries.InfluxQueryService
+}
+
+func (p *defaultWindowPlanner) Plan(conf *config.MeasureExtraction) ([]timeWindow, error) {
+	requested, err := requestedRange(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	if conf.Downsampling != nil {
+		if requested, err = p.boundRange(conf, requested); err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.WindowSize == 0 {
+		boundaries, err := p.shardGroupBoundaries(conf.Database, conf.RetentionPolicy)
+		if err == nil {
+			return splitRange(requested, boundaries), nil
+		}
+
+		log.Printf("Could not use the shard groups of '%s' as extraction windows, using windows of %s instead\n%v",
+			conf.Measure, DefaultWindowSize, err)
+	}
+
+	windowSize := conf.WindowSize
+	if windowSize == 0 {
+		windowSize = DefaultWindowSize
+	}
+
+	boundaries, err := p.fixedBoundaries(conf, requested, windowSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return splitRange(requested, boundaries), nil
+}
+
+// shardGroupBoundaries returns the start and end times of the shard groups of a retention policy
+func (p *defaultWindowPlanner) shardGroupBoundaries(database, rp string) ([]time.Time, error) {
+	if rp == "" {
+		return nil, fmt.Errorf("shard groups of different retention policies may overlap, a retention policy must be specified")
+	}
+
+	results, err := p.queryService.ExecuteQuery(p.influxClient, database, showShardsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("could not list the shards of database '%s'\n%v", database, err)
+	}
+
+	if len(results) != 1 {
+		return nil, fmt.Errorf("'%s' returned an unexpected number of results", showShardsQuery)
+	}
+
+	boundaries := []time.Time{}
+	for _, series := range results[0].Series {
+		seriesBoundaries, err := shardBoundariesFromSeries(series.Columns, series.Values, database, rp)
+		if err != nil {
+			return nil, err
+		}
+
+		boundaries = append(boundaries, seriesBoundaries...)
+	}
+
+	if len(boundaries) == 0 {
+		return nil, fmt.Errorf("no shards found for retention policy '%s' of database '%s'", rp, database)
+	}
+
+	return boundaries, nil
+}
+
+func shardBoundariesFromSeries(columns []string, values [][]interface{}, database, rp string) ([]time.Time, error) {
+	indexes := map[string]int{shardDatabaseColumn: -1, shardRetentionPolicyColumn: -1, shardStartTimeColumn: -1, shardEndTimeColumn: -1}
+	for i, column := range columns {
+		if _, ok := indexes[column]; ok {
+			indexes[column] = i
+		}
+	}
+
+	for column, index := range indexes {
+		if index == -1 {
+			return nil, fmt.Errorf("'%s' returned no '%s' column", showShardsQuery, column)
+		}
+	}
+
+	boundaries := []time.Time{}
+	for _, row := range values {
+		if row[indexes[shardDatabaseColumn]] != database || row[indexes[shardRetentionPolicyColumn]] != rp {
+			continue
+		}
+
+		for _, column := range []string{shardStartTimeColumn, shardEndTimeColumn} {
+			boundary, err := parseTimeValue(row[indexes[column]])
+			if err != nil {
+				return nil, fmt.Errorf("could not parse the '%s' of a shard\n%v", column, err)
+			}
+
+			boundaries = append(boundaries, boundary)
+		}
+	}
+
+	return boundaries, nil
+}
+
+// fixedBoundaries returns the boundaries of windows with a fixed size, covering the range between
+// the first and last point of the measure in the requested range. The boundaries are multiples of
+// the size since the Unix epoch
+func (p *defaultWindowPlanner) fixedBoundaries(conf *config.MeasureExtraction, requested timeWindow, windowSize time.Duration) ([]time.Time, error) {
+	first, found, err := p.pointTime(conf, firstPointQueryTemplate, requested)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	last := requested.to
+	if last.IsZero() {
+		if last, _, err = p.pointTime(conf, lastPointQueryTemplate, requested); err != nil {
+			return nil, err
+		}
+	}
+
+	boundaries := []time.Time{}
+	for boundary := alignToEpoch(first, windowSize).Add(windowSize); boundary.Before(last); boundary = boundary.Add(windowSize) {
+		boundaries = append(boundaries, boundary)
+	}
+
+	return boundaries, nil
+}
+
+// boundRange replaces the unset sides of the requested range with the times of the first and last point
+// of the measure, so the number of groups of a downsampled window can be bounded. A measure without points
+// in the requested range leaves it as it is
+func (p *defaultWindowPlanner) boundRange(conf *config.MeasureExtraction, requested timeWindow) (timeWindow, error) {
+	if requested.from.IsZero() {
+		first, found, err := p.pointTime(conf, firstPointQueryTemplate, requested)
+		if err != nil || !found {
+			return requested, err
+		}
+
+		requested.from = first
+	}
+
+	if requested.to.IsZero() {
+		last, found, err := p.pointTime(conf, lastPointQueryTemplate, requested)
+		if err != nil || !found {
+			return requested, err
+		}
+
+		requested.to = last
+	}
+
+	return requested, nil
+}
+
+func (p *defaultWindowPlanner) pointTime(conf *config.MeasureExtraction, queryTemplate string, requested timeWindow) (time.Time, bool, error) {
+	measurementName := buildMeasurementName(conf.RetentionPolicy, conf.Measure)
+	query := fmt.Sprintf(queryTemplate, measurementName, buildWhereClause(append(requested.conditions(), whereConditions(conf)...)))
+	results, err := p.queryService.ExecuteQuery(p.influxClient, conf.Database, query)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("could not find the time range of measure '%s'\n%v", conf.Measure, err)
+	}
+
+	if len(results) != 1 || len(results[0].Series) == 0 || len(results[0].Series[0].Values) == 0 {
+		return time.Time{}, false, nil
+	}
+
+	pointTime, err := parseTimeValue(results[0].Series[0].Values[0][0])
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("could not find the time range of measure '%s'\n%v", conf.Measure, err)
+	}
+
+	return pointTime, true, nil
+}
+
+// requestedRange returns the window bounded by the 'from' (or 'resumeFrom') and 'to' of the config
+func requestedRange(conf *config.MeasureExtraction) (timeWindow, error) {
+	requested := timeWindow{toInclusive: true}
+	from := conf.From
+	if conf.ResumeFrom != "" {
+		from = conf.ResumeFrom
+	}
+
+	var err error
+	if from != "" {
+		if requested.from, err = time.Parse(time.RFC3339, from); err != nil {
+			return requested, err
+		}
+	}
+
+	if conf.To != "" {
+		if requested.to, err = time.Parse(time.RFC3339, conf.To); err != nil {
+			return requested, err
+		}
+	}
+
+	return requested, nil
+}
+
+// splitRange splits the requested window on the boundaries that fall inside of it.
+// The windows keep the bounds of the requested window on the outer sides
+func splitRange(requested timeWindow, boundaries []time.Time) []timeWindow {
+	inside := []time.Time{}
+	for _, boundary := range boundaries {
+		if !requested.from.IsZero() && !boundary.After(requested.from) {
+			continue
+		}
+
+		if !requested.to.IsZero() && !boundary.Before(requested.to) {
+			continue
+		}
+
+		inside = append(inside, boundary)
+	}
+
+	sort.Slice(inside, func(i, j int) bool { return inside[i].Before(inside[j]) })
+	windows := []timeWindow{}
+	from := requested.from
+	for _, boundary := range inside {
+		if boundary.Equal(from) {
+			continue
+		}
+
+		windows = append(windows, timeWindow{from: from, to: boundary})
+		from = boundary
+	}
+
+	return append(windows, timeWindow{from: from, to: requested.to, toInclusive: requested.toInclusive})
+}
+
+// downsampleWindows splits the windows where the resolution of the downsampling changes,
+// and sets the interval the points of each window are aggregated to
+func downsampleWindows(windows []timeWindow, downsampling *config.Downsampling) []timeWindow {
+	downsampled := []timeWindow{}
+	for _, window := range alignWindows(windows, downsampling) {
+		for _, part := range splitRange(window, downsampling.Boundaries()) {
+			part.interval = downsampling.IntervalAt(part.from)
+			downsampled = append(downsampled, boundGroups(part)...)
+		}
+	}
+
+	return downsampled
+}
+
+// alignWindows moves the boundaries between the windows down to a multiple of the interval the points
+// at the boundary are aggregated to, so no group is split between two windows. A window left without
+// a whole group is merged into the next one
+func alignWindows(windows []timeWindow, downsampling *config.Downsampling) []timeWindow {
+	aligned := []timeWindow{}
+	var from time.Time
+	for i, window := range windows {
+		if i > 0 {
+			window.from = from
+		}
+
+		if i < len(windows)-1 {
+			if interval := downsampling.IntervalAt(window.to); interval > 0 {
+				window.to = alignToEpoch(window.to, interval)
+			}
+
+			if !window.from.IsZero() && !window.to.After(window.from) {
+				from = window.from
+				continue
+			}
+		}
+
+		aligned = append(aligned, window)
+		from = window.to
+	}
+
+	return aligned
+}
+
+// boundGroups splits a downsampled window so that each part holds at most ${maxGroupsPerWindow}
+// groups. The parts start at multiples of the interval since the Unix epoch, like the groups of
+// InfluxDB, so no group is split between two parts. The planner bounds the windows of a downsampled
+// measure by its first and last point, only a measure without points leaves a window unbounded
+func boundGroups(window timeWindow) []timeWindow {
+	if window.interval == 0 || window.from.IsZero() || window.to.IsZero() {
+		return []timeWindow{window}
+	}
+
+	if window.to.Sub(window.from)/window.interval <= maxGroupsPerWindow {
+		return []timeWindow{window}
+	}
+
+	step := window.interval * maxGroupsPerWindow
+	boundaries := []time.Time{}
+	for boundary := alignToEpoch(window.from, step).Add(step); boundary.Before(window.to); boundary = boundary.Add(step) {
+		boundaries = append(boundaries, boundary)
+	}
+
+	parts := splitRange(window, boundaries)
+	for i := range parts {
+		parts[i].interval = window.interval
+	}
+
+	return parts
+}
+
+// alignToEpoch returns the latest multiple of the duration since the Unix epoch not after the time
+func alignToEpoch(t time.Time, d time.Duration) time.Time {
+	nanos := t.UnixNano()
+	offset := nanos % int64(d)
+	if offset < 0 {
+		offset += int64(d)
+	}
+
+	return time.Unix(0, nanos-offset).UTC()
+}
+
+func parseTimeValue(value interface{}) (time.Time, error) {
+	asString, ok := value.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("expected a timestamp formatted as a string, got: %v", value)
+	}
+
+	return time.Parse(time.RFC3339Nano, asString)
+}
@@ -0,0 +1,310 @@
+package influx
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/influxdata/influxdb/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/extraction/config"
+	"github.com/timescale/outflux/internal/schemamanagement/influx/influxqueries"
+)
+
+func TestSplitRange(t *testing.T) {
+	t1 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	t3 := t2.Add(time.Hour)
+	testCases := []struct {
+		desc       string
+		requested  timeWindow
+		boundaries []time.Time
+		exp        []timeWindow
+	}{
+		{
+			desc:      "no boundaries, single unbounded window",
+			requested: timeWindow{toInclusive: true},
+			exp:       []timeWindow{{toInclusive: true}},
+		}, {
+			desc:       "unsorted duplicated boundaries",
+			requested:  timeWindow{toInclusive: true},
+			boundaries: []time.Time{t2, t1, t2},
+			exp:        []timeWindow{{to: t1}, {from: t1, to: t2}, {from: t2, toInclusive: true}},
+		}, {
+			desc:       "boundaries outside of the requested range are ignored",
+			requested:  timeWindow{from: t1, to: t3, toInclusive: true},
+			boundaries: []time.Time{t1.Add(-time.Hour), t1, t2, t3, t3.Add(time.Hour)},
+			exp:        []timeWindow{{from: t1, to: t2}, {from: t2, to: t3, toInclusive: true}},
+		},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.exp, splitRange(tc.requested, tc.boundaries), tc.desc)
+	}
+}
+
+func TestDownsampleWindows(t *testing.T) {
+	now := time.Date(2019, 1, 31, 12, 30, 0, 0, time.UTC)
+	resolutions := []config.Resolution{{Interval: time.Hour, OlderThan: 24 * time.Hour}}
+	downsampling, err := config.NewDownsampling(resolutions, map[string]string{"float": config.AggregateMean}, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	boundary := time.Date(2019, 1, 30, 12, 0, 0, 0, time.UTC)
+	t1 := boundary.Add(-48 * time.Hour)
+	testCases := []struct {
+		desc    string
+		windows []timeWindow
+		exp     []timeWindow
+	}{
+		{
+			desc:    "unbounded range is split at the boundary",
+			windows: []timeWindow{{toInclusive: true}},
+			exp:     []timeWindow{{to: boundary, interval: time.Hour}, {from: boundary, toInclusive: true}},
+		}, {
+			desc:    "windows before the boundary are only downsampled",
+			windows: []timeWindow{{to: t1}, {from: t1, to: boundary}, {from: boundary, toInclusive: true}},
+			exp: []timeWindow{
+				{to: t1, interval: time.Hour},
+				{from: t1, to: boundary, interval: time.Hour},
+				{from: boundary, toInclusive: true},
+			},
+		}, {
+			desc:    "window boundaries are moved down to the interval",
+			windows: []timeWindow{{to: t1.Add(30 * time.Minute)}, {from: t1.Add(30 * time.Minute), toInclusive: true}},
+			exp: []timeWindow{
+				{to: t1, interval: time.Hour},
+				{from: t1, to: boundary, interval: time.Hour},
+				{from: boundary, toInclusive: true},
+			},
+		}, {
+			desc: "window without a whole group is merged into the next one",
+			windows: []timeWindow{
+				{to: t1.Add(10 * time.Minute)},
+				{from: t1.Add(10 * time.Minute), to: t1.Add(20 * time.Minute)},
+				{from: t1.Add(20 * time.Minute), to: boundary},
+				{from: boundary, toInclusive: true},
+			},
+			exp: []timeWindow{
+				{to: t1, interval: time.Hour},
+				{from: t1, to: boundary, interval: time.Hour},
+				{from: boundary, toInclusive: true},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.exp, downsampleWindows(tc.windows, downsampling), tc.desc)
+	}
+}
+
+func TestBoundGroups(t *testing.T) {
+	step := maxGroupsPerWindow * time.Minute
+	start := time.Unix(0, 0).UTC().Add(500 * step)
+	from := start.Add(time.Minute)
+	to := start.Add(2*step + time.Hour)
+	testCases := []struct {
+		desc   string
+		window timeWindow
+		exp    []timeWindow
+	}{
+		{
+			desc:   "raw points are not split",
+			window: timeWindow{from: from, to: to},
+			exp:    []timeWindow{{from: from, to: to}},
+		}, {
+			desc:   "unbounded window is not split",
+			window: timeWindow{from: from, toInclusive: true, interval: time.Minute},
+			exp:    []timeWindow{{from: from, toInclusive: true, interval: time.Minute}},
+		}, {
+			desc:   "window with few groups is not split",
+			window: timeWindow{from: from, to: from.Add(step), interval: time.Minute},
+			exp:    []timeWindow{{from: from, to: from.Add(step), interval: time.Minute}},
+		}, {
+			desc:   "window is split on multiples of the interval since the epoch",
+			window: timeWindow{from: from, to: to, toInclusive: true, interval: time.Minute},
+			exp: []timeWindow{
+				{from: from, to: start.Add(step), interval: time.Minute},
+				{from: start.Add(step), to: start.Add(2 * step), interval: time.Minute},
+				{from: start.Add(2 * step), to: to, toInclusive: true, interval: time.Minute},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.exp, boundGroups(tc.window), tc.desc)
+	}
+}
+
+func TestTimeWindowConditions(t *testing.T) {
+	t1 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := time.Date(2019, 1, 1, 0, 0, 0, 1, time.UTC)
+	testCases := []struct {
+		in  timeWindow
+		exp []string
+	}{
+		{in: timeWindow{}, exp: []string{}},
+		{in: timeWindow{from: t1}, exp: []string{"time >= '2019-01-01T00:00:00Z'"}},
+		{in: timeWindow{to: t2}, exp: []string{"time < '2019-01-01T00:00:00.000000001Z'"}},
+		{in: timeWindow{from: t1, to: t2, toInclusive: true}, exp: []string{"time >= '2019-01-01T00:00:00Z'", "time <= '2019-01-01T00:00:00.000000001Z'"}},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.exp, tc.in.conditions())
+	}
+}
+
+func TestShardBoundariesFromSeries(t *testing.T) {
+	columns := []string{"id", "database", "retention_policy", "shard_group", "start_time", "end_time", "expiry_time", "owners"}
+	values := [][]interface{}{
+		{"1", "db", "autogen", "1", "2019-01-07T00:00:00Z", "2019-01-14T00:00:00Z", "2019-01-14T00:00:00Z", ""},
+		{"2", "db", "other", "2", "2019-01-08T00:00:00Z", "2019-01-09T00:00:00Z", "2019-01-09T00:00:00Z", ""},
+		{"3", "other db", "autogen", "3", "2019-01-01T00:00:00Z", "2019-01-02T00:00:00Z", "2019-01-02T00:00:00Z", ""},
+	}
+
+	boundaries, err := shardBoundariesFromSeries(columns, values, "db", "autogen")
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{
+		time.Date(2019, 1, 7, 0, 0, 0, 0, time.UTC),
+		time.Date(2019, 1, 14, 0, 0, 0, 0, time.UTC),
+	}, boundaries)
+
+	_, err = shardBoundariesFromSeries(columns[:5], values, "db", "autogen")
+	assert.Error(t, err)
+	values[0][4] = "not a time"
+	_, err = shardBoundariesFromSeries(columns, values, "db", "autogen")
+	assert.Error(t, err)
+}
+
+type mockQueryService struct {
+	results map[string][]influx.Result
+	queries []string
+}
+
+func (m *mockQueryService) ExecuteQuery(client influx.Client, database, command string) ([]influx.Result, error) {
+	m.queries = append(m.queries, command)
+	res, ok := m.results[command]
+	if !ok {
+		return nil, fmt.Errorf("generic error")
+	}
+
+	return res, nil
+}
+
+func (m *mockQueryService) ExecuteShowQuery(influxClient influx.Client, database, query string) (*influxqueries.InfluxShowResult, error) {
+	panic("should not come here")
+}
+
+func TestPlanWithShardGroups(t *testing.T) {
+	qs := &mockQueryService{results: map[string][]influx.Result{
+		showShardsQuery: {{Series: []models.Row{{
+			Name:    "db",
+			Columns: []string{"id", "database", "retention_policy", "shard_group", "start_time", "end_time", "expiry_time", "owners"},
+			Values: [][]interface{}{
+				{"1", "db", "rp", "1", "2019-01-07T00:00:00Z", "2019-01-14T00:00:00Z", "2019-01-14T00:00:00Z", ""},
+				{"2", "db", "rp", "2", "2019-01-14T00:00:00Z", "2019-01-21T00:00:00Z", "2019-01-21T00:00:00Z", ""},
+			},
+		}}}},
+	}}
+
+	planner := NewWindowPlanner(nil, qs)
+	windows, err := planner.Plan(&config.MeasureExtraction{Database: "db", RetentionPolicy: "rp", Measure: "m", From: "2019-01-10T00:00:00Z"})
+	assert.NoError(t, err)
+	from := time.Date(2019, 1, 10, 0, 0, 0, 0, time.UTC)
+	b1 := time.Date(2019, 1, 14, 0, 0, 0, 0, time.UTC)
+	b2 := time.Date(2019, 1, 21, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, []timeWindow{{from: from, to: b1}, {from: b1, to: b2}, {from: b2, toInclusive: true}}, windows)
+}
+
+func TestPlanFallsBackToFixedWindows(t *testing.T) {
+	firstQuery := `SELECT * FROM "rp"."m" WHERE time <= '2019-01-01T03:00:00Z' ORDER BY time ASC LIMIT 1`
+	qs := &mockQueryService{results: map[string][]influx.Result{
+		firstQuery: {{Series: []models.Row{{Values: [][]interface{}{{"2019-01-01T00:30:00Z", 1}}}}}},
+	}}
+
+	planner := NewWindowPlanner(nil, qs)
+	windows, err := planner.Plan(&config.MeasureExtraction{Database: "db", RetentionPolicy: "rp", Measure: "m", To: "2019-01-01T03:00:00Z"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{showShardsQuery, firstQuery}, qs.queries)
+	b1 := time.Date(2019, 1, 1, 1, 0, 0, 0, time.UTC)
+	b2 := b1.Add(time.Hour)
+	to := b2.Add(time.Hour)
+	assert.Len(t, windows, 1, "default window size is larger than the range")
+
+	qs.queries = nil
+	windows, err = planner.Plan(&config.MeasureExtraction{Database: "db", RetentionPolicy: "rp", Measure: "m", To: "2019-01-01T03:00:00Z", WindowSize: time.Hour})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{firstQuery}, qs.queries)
+	assert.Equal(t, []timeWindow{{to: b1}, {from: b1, to: b2}, {from: b2, to: to, toInclusive: true}}, windows)
+}
+
+func TestPlanFixedWindowsAlignedToTheEpoch(t *testing.T) {
+	firstQuery := `SELECT * FROM "rp"."m" WHERE time <= '2019-01-10T00:00:00Z' ORDER BY time ASC LIMIT 1`
+	qs := &mockQueryService{results: map[string][]influx.Result{
+		firstQuery: {{Series: []models.Row{{Values: [][]interface{}{{"2019-01-01T00:30:00Z", 1}}}}}},
+	}}
+
+	planner := NewWindowPlanner(nil, qs)
+	windows, err := planner.Plan(&config.MeasureExtraction{Database: "db", RetentionPolicy: "rp", Measure: "m", To: "2019-01-10T00:00:00Z", WindowSize: 7 * 24 * time.Hour})
+	assert.NoError(t, err)
+	// the Unix epoch was a Thursday
+	boundary := time.Date(2019, 1, 3, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, []timeWindow{{to: boundary}, {from: boundary, to: time.Date(2019, 1, 10, 0, 0, 0, 0, time.UTC), toInclusive: true}}, windows)
+}
+
+func TestPlanBoundsDownsampledRange(t *testing.T) {
+	firstQuery := `SELECT * FROM "rp"."m" ORDER BY time ASC LIMIT 1`
+	lastQuery := `SELECT * FROM "rp"."m" WHERE time >= '2019-01-08T00:00:00Z' ORDER BY time DESC LIMIT 1`
+	qs := &mockQueryService{results: map[string][]influx.Result{
+		showShardsQuery: {{Series: []models.Row{{
+			Name:    "db",
+			Columns: []string{"id", "database", "retention_policy", "shard_group", "start_time", "end_time", "expiry_time", "owners"},
+			Values:  [][]interface{}{{"1", "db", "rp", "1", "2019-01-07T00:00:00Z", "2019-01-14T00:00:00Z", "2019-01-14T00:00:00Z", ""}},
+		}}}},
+		firstQuery: {{Series: []models.Row{{Values: [][]interface{}{{"2019-01-08T00:00:00Z", 1}}}}}},
+		lastQuery:  {{Series: []models.Row{{Values: [][]interface{}{{"2019-01-20T00:00:00Z", 1}}}}}},
+	}}
+
+	downsampling, err := config.NewDownsampling([]config.Resolution{{Interval: time.Minute}}, map[string]string{"float": config.AggregateMean}, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	planner := NewWindowPlanner(nil, qs)
+	windows, err := planner.Plan(&config.MeasureExtraction{Database: "db", RetentionPolicy: "rp", Measure: "m", Downsampling: downsampling})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{firstQuery, lastQuery, showShardsQuery}, qs.queries)
+	first := time.Date(2019, 1, 8, 0, 0, 0, 0, time.UTC)
+	b1 := time.Date(2019, 1, 14, 0, 0, 0, 0, time.UTC)
+	last := time.Date(2019, 1, 20, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, []timeWindow{{from: first, to: b1}, {from: b1, to: last, toInclusive: true}}, windows)
+
+	qs.queries = nil
+	delete(qs.results, lastQuery)
+	_, err = planner.Plan(&config.MeasureExtraction{Database: "db", RetentionPolicy: "rp", Measure: "m", Downsampling: downsampling})
+	assert.Error(t, err)
+}
+
+func TestPlanEmptyMeasure(t *testing.T) {
+	qs := &mockQueryService{results: map[string][]influx.Result{
+		`SELECT * FROM "rp"."m" ORDER BY time ASC LIMIT 1`: {{}},
+	}}
+
+	planner := NewWindowPlanner(nil, qs)
+	windows, err := planner.Plan(&config.MeasureExtraction{Database: "db", RetentionPolicy: "rp", Measure: "m", WindowSize: time.Hour})
+	assert.NoError(t, err)
+	assert.Equal(t, []timeWindow{{toInclusive: true}}, windows)
+}
+
+func TestPlanFixedWindowsOfFilteredPoints(t *testing.T) {
+	qs := &mockQueryService{results: map[string][]influx.Result{
+		`SELECT * FROM "rp"."m" WHERE (region = 'eu') ORDER BY time ASC LIMIT 1`: {{}},
+	}}
+
+	planner := NewWindowPlanner(nil, qs)
+	windows, err := planner.Plan(&config.MeasureExtraction{Database: "db", RetentionPolicy: "rp", Measure: "m", WindowSize: time.Hour, Where: "region = 'eu'"})
+	assert.NoError(t, err)
+	assert.Equal(t, []timeWindow{{toInclusive: true}}, windows)
+}
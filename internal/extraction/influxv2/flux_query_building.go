@@ -0,0 +1,113 @@
+package influxv2
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/timescale/outflux/internal/extraction/config"
+	"github.com/timescale/outflux/internal/schemamanagement/influxv2/fluxqueries"
+)
+
+const (
+	// pivot turns the fields of each series into columns, group merges the series in a
+	// single table that is then sorted so the points are extracted in time order. The table
+	// is sorted in the memory of the server, so a query selects a single time window
+	selectFluxTemplate = `from(bucket: %s)
+  |> range(start: %s, stop: %s)
+  |> filter(fn: (r) => r._measurement == %s)
+  |> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+  |> group()
+  |> sort(columns: ["_time"])`
+	limitFluxTemplate = `
+  |> limit(n: %d)`
+	// first() and last() keep one point per series, min() and max() of their times
+	// is the time of the first or last point of the measurement
+	firstPointFluxTemplate = `from(bucket: %s)
+  |> range(start: %s, stop: %s)
+  |> filter(fn: (r) => r._measurement == %s)
+  |> first()
+  |> group()
+  |> min(column: "_time")`
+	lastPointFluxTemplate = `from(bucket: %s)
+  |> range(start: %s, stop: %s)
+  |> filter(fn: (r) => r._measurement == %s)
+  |> last()
+  |> group()
+  |> max(column: "_time")`
+)
+
+// timeWindow is a part of the time range of a measure, the bounds are those of range(),
+// 'start' is inclusive and 'stop' exclusive
+type timeWindow struct {
+	start time.Time
+	stop  time.Time
+}
+
+func (w timeWindow) String() string {
+	return fmt.Sprintf("[%s, %s)", w.start.Format(time.RFC3339Nano), w.stop.Format(time.RFC3339Nano))
+}
+
+// requestedRange returns the window bounded by the 'from' (or 'resumeFrom') and 'to' of the config.
+// An unset bound is zero
+func requestedRange(config *config.MeasureExtraction) (timeWindow, error) {
+	// a checkpoint from a previous run takes precedence over the requested lower bound
+	requested := timeWindow{}
+	from := config.From
+	if config.ResumeFrom != "" {
+		from = config.ResumeFrom
+	}
+
+	var err error
+	if from != "" {
+		if requested.start, err = time.Parse(time.RFC3339, from); err != nil {
+			return requested, fmt.Errorf("'from' time must be formatted as %s", time.RFC3339)
+		}
+	}
+
+	// the upper bound of range() is exclusive, 'to' is inclusive
+	if config.To != "" {
+		to, err := time.Parse(time.RFC3339, config.To)
+		if err != nil {
+			return requested, fmt.Errorf("'to' time must be formatted as %s", time.RFC3339)
+		}
+
+		requested.stop = to.Add(time.Nanosecond)
+	}
+
+	return requested, nil
+}
+
+// rangeBounds formats the bounds of a window for range(), an unset bound is the earliest or latest allowed time
+func rangeBounds(window timeWindow) (string, string) {
+	start, stop := fluxqueries.RangeStart, fluxqueries.RangeStop
+	if !window.start.IsZero() {
+		start = window.start.Format(time.RFC3339Nano)
+	}
+
+	if !window.stop.IsZero() {
+		stop = window.stop.Format(time.RFC3339Nano)
+	}
+
+	return start, stop
+}
+
+// buildSelectFlux builds the Flux query that extracts the points of a measurement in a time window,
+// the bucket is specified as the database of the config. If limit is > 0 at most that many points are selected
+func buildSelectFlux(config *config.MeasureExtraction, window timeWindow, limit uint64) string {
+	start, stop := rangeBounds(window)
+	bucket := fluxqueries.QuoteString(config.Database)
+	measure := fluxqueries.QuoteString(config.Measure)
+	query := fmt.Sprintf(selectFluxTemplate, bucket, start, stop, measure)
+	if limit == 0 {
+		return query
+	}
+
+	return query + fmt.Sprintf(limitFluxTemplate, limit)
+}
+
+// buildPointTimeFlux builds the Flux query that selects the time of the first or last point of a measurement
+// in a time window, with the firstPointFluxTemplate or lastPointFluxTemplate
+func buildPointTimeFlux(config *config.MeasureExtraction, template string, window timeWindow) string {
+	start, stop := rangeBounds(window)
+	return fmt.Sprintf(template, fluxqueries.QuoteString(config.Database), start, stop, fluxqueries.QuoteString(config.Measure))
+}
@@ -0,0 +1,95 @@
+package influxv2
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/extraction/config"
+)
+
+func TestRequestedRange(t *testing.T) {
+	testCases := []struct {
+		desc string
+		conf *config.MeasureExtraction
+		exp  timeWindow
+		err  bool
+	}{
+		{
+			desc: "no bounds",
+			conf: &config.MeasureExtraction{},
+		}, {
+			desc: "resume overrides from, to is inclusive",
+			conf: &config.MeasureExtraction{From: "2019-01-01T00:00:00Z", ResumeFrom: "2019-01-02T00:00:00.5Z", To: "2019-01-03T00:00:00Z"},
+			exp: timeWindow{
+				start: time.Date(2019, 1, 2, 0, 0, 0, 5e8, time.UTC),
+				stop:  time.Date(2019, 1, 3, 0, 0, 0, 1, time.UTC),
+			},
+		}, {
+			desc: "bad from",
+			conf: &config.MeasureExtraction{From: "2019-01-01"},
+			err:  true,
+		}, {
+			desc: "bad to",
+			conf: &config.MeasureExtraction{To: "2019-01-03"},
+			err:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		out, err := requestedRange(tc.conf)
+		if tc.err {
+			assert.Error(t, err, tc.desc)
+			continue
+		}
+
+		assert.NoError(t, err, tc.desc)
+		assert.True(t, tc.exp.start.Equal(out.start) && tc.exp.stop.Equal(out.stop), "%s: got %s", tc.desc, out)
+	}
+}
+
+func TestBuildSelectFlux(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		window timeWindow
+		limit  uint64
+		exp    string
+	}{
+		{
+			desc: "no bounds",
+			exp: `from(bucket: "bucket")
+  |> range(start: 1677-09-21T00:12:43.145224194Z, stop: 2262-04-11T23:47:16.854775806Z)
+  |> filter(fn: (r) => r._measurement == "c\"pu")
+  |> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+  |> group()
+  |> sort(columns: ["_time"])`,
+		}, {
+			desc:   "window and limit",
+			window: timeWindow{start: time.Date(2019, 1, 2, 0, 0, 0, 5e8, time.UTC), stop: time.Date(2019, 1, 3, 0, 0, 0, 1, time.UTC)},
+			limit:  10,
+			exp: `from(bucket: "bucket")
+  |> range(start: 2019-01-02T00:00:00.5Z, stop: 2019-01-03T00:00:00.000000001Z)
+  |> filter(fn: (r) => r._measurement == "c\"pu")
+  |> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+  |> group()
+  |> sort(columns: ["_time"])
+  |> limit(n: 10)`,
+		},
+	}
+
+	conf := &config.MeasureExtraction{Database: "bucket", Measure: `c"pu`}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.exp, buildSelectFlux(conf, tc.window, tc.limit), tc.desc)
+	}
+}
+
+func TestBuildPointTimeFlux(t *testing.T) {
+	conf := &config.MeasureExtraction{Database: "bucket", Measure: "cpu"}
+	window := timeWindow{start: time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC)}
+	assert.Equal(t, `from(bucket: "bucket")
+  |> range(start: 2019-01-02T00:00:00Z, stop: 2262-04-11T23:47:16.854775806Z)
+  |> filter(fn: (r) => r._measurement == "cpu")
+  |> last()
+  |> group()
+  |> max(column: "_time")`, buildPointTimeFlux(conf, lastPointFluxTemplate, window))
+}
@@ -0,0 +1,145 @@
+// Package influxv2 extracts the points of a measurement from an InfluxDB 2.x bucket
+// with a Flux query sent to the v2 API
+package influxv2
+
+import (
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/timescale/outflux/internal/connections"
+	"github.com/timescale/outflux/internal/extraction/config"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement"
+	"github.com/timescale/outflux/internal/schemamanagement/influxv2/fluxqueries"
+)
+
+// Extractor is an implementation of the extraction.Extractor interface for
+// pulling data out of InfluxDB 2.x
+type Extractor struct {
+	Config            *config.ExtractionConfig
+	SM                schemamanagement.SchemaManager
+	Client            connections.InfluxV2Client
+	QueryService      fluxqueries.FluxQueryService
+	cachedElementData *idrf.Bundle
+}
+
+// ID of the extractor, useful for logging and error reporting
+func (e *Extractor) ID() string {
+	return e.Config.ExtractorID
+}
+
+// Prepare discovers the data set schema for the measure in the config
+func (e *Extractor) Prepare() (*idrf.Bundle, error) {
+	measureName := e.Config.MeasureExtraction.Measure
+	log.Printf("Discovering influx schema for measurement: %s", measureName)
+
+	discoveredDataSet, err := e.SM.FetchDataSet(measureName)
+	if err != nil {
+		return nil, fmt.Errorf("%s: could not fetch data set definition for measure: %s\n%v", e.ID(), measureName, err)
+	}
+
+	log.Printf("Discovered: %s", discoveredDataSet.String())
+	e.cachedElementData = &idrf.Bundle{
+		DataDef:  discoveredDataSet,
+		DataChan: make(chan idrf.Row, e.Config.DataBufferSize),
+	}
+
+	return e.cachedElementData, nil
+}
+
+// Start pulls the data from an InfluxDB 2.x measure and feeds it to a data channel.
+// Periodically (every 'chunkSize' rows) checks for external errors and quits if it detects them
+func (e *Extractor) Start(errChan chan error) error {
+	if e.cachedElementData == nil {
+		return fmt.Errorf("%s: Prepare not called before start", e.ID())
+	}
+
+	dataChan := e.cachedElementData.DataChan
+	defer close(dataChan)
+
+	id := e.ID()
+	measureConf := e.Config.MeasureExtraction
+	log.Printf("Starting extractor '%s' for measure: %s\n", id, measureConf.Measure)
+	windows, err := e.planWindows(measureConf)
+	if err != nil {
+		return fmt.Errorf("%s: could not split the time range of the measure in windows\n%v", id, err)
+	}
+
+	log.Printf("%s: Extracting data from bucket '%s' in %d time windows\n", id, measureConf.Database, len(windows))
+	converter := newRowConverter(e.cachedElementData.DataDef)
+	totalRows := uint64(0)
+	for _, window := range windows {
+		limit := uint64(0)
+		if measureConf.Limit > 0 {
+			if totalRows >= measureConf.Limit {
+				break
+			}
+
+			limit = measureConf.Limit - totalRows
+		}
+
+		windowRows, stopped, err := e.extractWindow(errChan, converter, window, limit, totalRows)
+		if err != nil {
+			return fmt.Errorf("%s: could not extract the points in %s\n%v", id, window, err)
+		}
+
+		totalRows += windowRows
+		if stopped {
+			return nil
+		}
+	}
+
+	log.Printf("%s: Extracted %d rows from Influx", id, totalRows)
+	return nil
+}
+
+// extractWindow feeds the points of a time window to the data channel. Every 'chunkSize' rows, counted
+// from the start of the extraction, it checks for external errors and stops if it detects them
+func (e *Extractor) extractWindow(errChan chan error, converter *rowConverter, window timeWindow, limit, extracted uint64) (uint64, bool, error) {
+	id := e.ID()
+	query := buildSelectFlux(e.Config.MeasureExtraction, window, limit)
+	log.Printf("%s: %s\n", id, query)
+	response, err := e.Client.Query(query)
+	if err != nil {
+		return 0, false, fmt.Errorf("could not execute the flux query\n%v", err)
+	}
+
+	defer response.Close()
+	dataChan := e.cachedElementData.DataChan
+	reader := fluxqueries.NewAnnotatedCSVReader(response)
+	chunkSize := e.Config.MeasureExtraction.ChunkSize
+	for windowRows := uint64(0); ; windowRows++ {
+		totalRows := extracted + windowRows
+		if windowRows > 0 && totalRows%uint64(chunkSize) == 0 {
+			log.Printf("%s: Extracted %d rows from Influx", id, totalRows)
+			// check if an error occurred in some other goroutine
+			if err = checkError(errChan); err != nil {
+				return windowRows, true, nil
+			}
+		}
+
+		record, err := reader.Next()
+		if err == io.EOF {
+			return windowRows, false, nil
+		} else if err != nil {
+			return windowRows, false, fmt.Errorf("error decoding response\n%v", err)
+		}
+
+		row, err := converter.convert(record)
+		if err != nil {
+			return windowRows, false, fmt.Errorf("could not convert influx result to IDRF row\n%v", err)
+		}
+
+		dataChan <- row
+	}
+}
+
+func checkError(errorChannel chan error) error {
+	select {
+	case err := <-errorChannel:
+		return err
+	default:
+		return nil
+	}
+}
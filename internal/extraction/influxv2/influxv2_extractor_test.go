@@ -0,0 +1,261 @@
+package influxv2
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/connections"
+	"github.com/timescale/outflux/internal/extraction/config"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/influxv2/fluxqueries"
+	"github.com/timescale/outflux/internal/testutils"
+)
+
+// pivotedCSV has two tables with the fields in different columns, as when the pivoted series of a
+// measure don't have the same fields
+const pivotedCSV = `#datatype,string,long,dateTime:RFC3339,dateTime:RFC3339,dateTime:RFC3339,string,string,double
+#group,false,false,false,false,false,false,false,false
+#default,_result,,,,,,,
+,result,table,_start,_stop,_time,_measurement,host,usage
+,,0,2019-01-01T00:00:00Z,2262-04-11T23:47:16.854775806Z,2019-01-01T00:00:00Z,cpu,a,1.5
+
+#datatype,string,long,dateTime:RFC3339,dateTime:RFC3339,dateTime:RFC3339,unsignedLong,string,string
+#group,false,false,false,false,false,false,false,false
+#default,_result,,,,,,,
+,result,table,_start,_stop,_time,count,_measurement,host
+,,1,2019-01-01T00:00:00Z,2262-04-11T23:47:16.854775806Z,2019-01-01T00:00:01Z,2,cpu,b
+,,1,2019-01-01T00:00:00Z,2262-04-11T23:47:16.854775806Z,2019-01-01T00:00:02Z,3,cpu,
+`
+
+var t1 = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func cpuDataSet() *idrf.DataSet {
+	return &idrf.DataSet{
+		DataSetName: "cpu",
+		Columns: []*idrf.Column{
+			{Name: "time", DataType: idrf.IDRFTimestamptz},
+			{Name: "host", DataType: idrf.IDRFString},
+			{Name: "count", DataType: idrf.IDRFDouble},
+			{Name: "usage", DataType: idrf.IDRFDouble},
+			{Name: "missing", DataType: idrf.IDRFBoolean},
+		},
+		TimeColumn: "time",
+	}
+}
+
+// timeCSV is the response to the query of the time of the last point of cpu from 2019-01-01
+const timeCSV = `#datatype,string,long,dateTime:RFC3339,dateTime:RFC3339,dateTime:RFC3339,double,string,string
+#group,false,false,false,false,false,false,false,false
+#default,_result,,,,,,,
+,result,table,_start,_stop,_time,_value,_field,_measurement
+,,0,2019-01-01T00:00:00Z,2262-04-11T23:47:16.854775806Z,2019-01-01T00:00:02Z,3,count,cpu
+`
+
+// pointCSV returns a response with a single point of host a at the given second of 2019-01-01
+func pointCSV(second int) string {
+	return fmt.Sprintf(`#datatype,string,long,dateTime:RFC3339,string,string,double
+#group,false,false,false,false,false,false
+#default,_result,,,,,
+,result,table,_time,_measurement,host,usage
+,,0,2019-01-01T00:00:%02dZ,cpu,a,%d
+`, second, second)
+}
+
+func newClient(t *testing.T, responses []testutils.FluxResponse) (connections.InfluxV2Client, func()) {
+	server := testutils.NewInfluxV2StandIn(responses)
+	client, err := connections.NewInfluxV2ConnectionService().NewConnection(&connections.InfluxV2ConnectionParams{
+		Server: server.URL, Token: "token", Org: "org",
+	})
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+
+	return client, server.Close
+}
+
+// newExtractor creates an extractor of the cpu measure from 2019-01-01 of a stand-in that answers
+// only the queries with that range
+func newExtractor(t *testing.T, dataSet *idrf.DataSet) (*Extractor, func()) {
+	client, closeServer := newClient(t, []testutils.FluxResponse{
+		{QueryContains: "|> last()", CSV: timeCSV},
+		{QueryContains: `range(start: 2019-01-01T00:00:00Z, stop: 2019-01-01T00:00:02.000000001Z)
+  |> filter(fn: (r) => r._measurement == "cpu")`, CSV: pivotedCSV},
+	})
+	conf := &config.ExtractionConfig{
+		ExtractorID:       "ext",
+		MeasureExtraction: &config.MeasureExtraction{Database: "bucket", Measure: "cpu", From: "2019-01-01T00:00:00Z", ChunkSize: 1},
+		DataBufferSize:    10,
+	}
+	sm := &testutils.SchemaManagerStandIn{DataSet: dataSet}
+	return &Extractor{Config: conf, SM: sm, Client: client, QueryService: fluxqueries.NewFluxQueryService()}, closeServer
+}
+
+func extractRows(t *testing.T, extractor *Extractor, errChan chan error) ([]idrf.Row, error) {
+	bundle, err := extractor.Prepare()
+	if err != nil {
+		t.Fatalf("could not prepare extractor: %v", err)
+	}
+
+	err = extractor.Start(errChan)
+	rows := []idrf.Row{}
+	for row := range bundle.DataChan {
+		rows = append(rows, row)
+	}
+
+	return rows, err
+}
+
+func TestExtractPivotedTables(t *testing.T) {
+	extractor, closeServer := newExtractor(t, cpuDataSet())
+	defer closeServer()
+
+	rows, err := extractRows(t, extractor, make(chan error, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, []idrf.Row{
+		{t1, "a", nil, 1.5, nil},
+		{t1.Add(time.Second), "b", float64(2), nil, nil},
+		{t1.Add(2 * time.Second), nil, float64(3), nil, nil},
+	}, rows, "the columns of each table are mapped by name")
+}
+
+func TestExtractWindowsUpToTheLimit(t *testing.T) {
+	client, closeServer := newClient(t, []testutils.FluxResponse{
+		{QueryContains: "range(start: 2019-01-01T00:00:00Z, stop: 2019-01-01T00:00:01Z)", CSV: pointCSV(0)},
+		{QueryContains: "range(start: 2019-01-01T00:00:01Z, stop: 2019-01-01T00:00:02Z)", CSV: pointCSV(1)},
+	})
+	defer closeServer()
+
+	conf := &config.ExtractionConfig{
+		ExtractorID: "ext",
+		MeasureExtraction: &config.MeasureExtraction{
+			Database: "bucket", Measure: "cpu", From: "2019-01-01T00:00:00Z", To: "2019-01-01T00:00:02Z", WindowSize: time.Second, Limit: 2, ChunkSize: 1,
+		},
+		DataBufferSize: 10,
+	}
+	sm := &testutils.SchemaManagerStandIn{DataSet: cpuDataSet()}
+	extractor := &Extractor{Config: conf, SM: sm, Client: client, QueryService: fluxqueries.NewFluxQueryService()}
+	rows, err := extractRows(t, extractor, make(chan error, 1))
+	assert.NoError(t, err, "the window of the last second is not queried")
+	assert.Equal(t, []idrf.Row{
+		{t1, "a", nil, float64(0), nil},
+		{t1.Add(time.Second), "a", nil, float64(1), nil},
+	}, rows)
+}
+
+func TestPlanWindows(t *testing.T) {
+	firstCSV := strings.Replace(timeCSV, "00:00:02Z,3", "00:00:00.5Z,3", 1)
+	testCases := []struct {
+		desc      string
+		conf      *config.MeasureExtraction
+		responses []testutils.FluxResponse
+		exp       []timeWindow
+		err       bool
+	}{
+		{
+			desc:      "the range of the points, split at multiples of the window size",
+			conf:      &config.MeasureExtraction{WindowSize: time.Second},
+			responses: []testutils.FluxResponse{{QueryContains: "|> first()", CSV: firstCSV}, {QueryContains: "|> last()", CSV: timeCSV}},
+			exp: []timeWindow{
+				{start: t1.Add(500 * time.Millisecond), stop: t1.Add(time.Second)},
+				{start: t1.Add(time.Second), stop: t1.Add(2 * time.Second)},
+				{start: t1.Add(2 * time.Second), stop: t1.Add(2*time.Second + 1)},
+			},
+		}, {
+			desc: "requested range in a single default window",
+			conf: &config.MeasureExtraction{From: "2019-01-01T00:00:00Z", To: "2019-01-02T00:00:00Z"},
+			exp:  []timeWindow{{start: t1, stop: t1.Add(24*time.Hour + 1)}},
+		}, {
+			desc:      "no points",
+			conf:      &config.MeasureExtraction{},
+			responses: []testutils.FluxResponse{{QueryContains: "|> first()", CSV: ""}},
+		}, {
+			desc: "query error",
+			conf: &config.MeasureExtraction{From: "2019-01-01T00:00:00Z"},
+			err:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		client, closeServer := newClient(t, tc.responses)
+		extractor := &Extractor{Client: client, QueryService: fluxqueries.NewFluxQueryService()}
+		tc.conf.Database, tc.conf.Measure = "bucket", "cpu"
+		windows, err := extractor.planWindows(tc.conf)
+		closeServer()
+		if tc.err {
+			assert.Error(t, err, tc.desc)
+			continue
+		}
+
+		assert.NoError(t, err, tc.desc)
+		assert.Equal(t, tc.exp, windows, tc.desc)
+	}
+}
+
+func TestExtractErrors(t *testing.T) {
+	extractor, closeServer := newExtractor(t, cpuDataSet())
+	defer closeServer()
+	assert.Error(t, extractor.Start(make(chan error, 1)), "prepare not called")
+
+	extractor.SM = &testutils.SchemaManagerStandIn{Err: fmt.Errorf("generic error")}
+	_, err := extractor.Prepare()
+	assert.Error(t, err)
+
+	// the host tag of the first record can't be converted to an integer
+	dataSet := cpuDataSet()
+	dataSet.Columns[1].DataType = idrf.IDRFInteger64
+	extractor.SM = &testutils.SchemaManagerStandIn{DataSet: dataSet}
+	rows, err := extractRows(t, extractor, make(chan error, 1))
+	assert.Error(t, err)
+	assert.Equal(t, 0, len(rows))
+
+	extractor.SM = &testutils.SchemaManagerStandIn{DataSet: cpuDataSet()}
+	extractor.Config.MeasureExtraction.From = "2019-01-02T00:00:00Z"
+	extractor.Config.MeasureExtraction.To = "2019-01-02T00:00:00Z"
+	_, err = extractRows(t, extractor, make(chan error, 1))
+	assert.Error(t, err, "query not answered")
+
+	extractor.Config.MeasureExtraction.From = "2019-01-01T00:00:00Z"
+	extractor.Config.MeasureExtraction.To = ""
+	errChan := make(chan error, 1)
+	errChan <- fmt.Errorf("external error")
+	rows, err = extractRows(t, extractor, errChan)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(rows), "stops after the first chunk")
+}
+
+func TestConvertValue(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		in       interface{}
+		expected idrf.DataType
+		out      interface{}
+		err      bool
+	}{
+		{in: nil, expected: idrf.IDRFDouble, out: nil},
+		{in: int64(1), expected: idrf.IDRFInteger64, out: int64(1)},
+		{in: int64(1), expected: idrf.IDRFDouble, out: float64(1)},
+		{in: uint64(1), expected: idrf.IDRFInteger64, out: int64(1)},
+		{in: uint64(1), expected: idrf.IDRFDouble, out: float64(1)},
+		{in: uint64(1 << 63), expected: idrf.IDRFInteger64, err: true},
+		{in: 1.5, expected: idrf.IDRFDouble, out: 1.5},
+		{in: 1.5, expected: idrf.IDRFInteger64, err: true},
+		{in: "a", expected: idrf.IDRFString, out: "a"},
+		{in: true, expected: idrf.IDRFBoolean, out: true},
+		{in: now, expected: idrf.IDRFTimestamptz, out: now},
+		{in: now, expected: idrf.IDRFString, err: true},
+	}
+
+	for _, tc := range testCases {
+		out, err := convertValue(tc.in, tc.expected)
+		if tc.err {
+			assert.Error(t, err)
+			continue
+		}
+
+		assert.NoError(t, err)
+		assert.Equal(t, tc.out, out)
+	}
+}
@@ -0,0 +1,103 @@
+package influxv2
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/influxv2/fluxqueries"
+)
+
+const fluxTimeColumn = "_time"
+
+// rowConverter converts the records of the pivoted Flux response to IDRF rows
+// with the columns in the order of the data set
+type rowConverter struct {
+	dataSet *idrf.DataSet
+	table   int
+	indexes []int
+}
+
+func newRowConverter(dataSet *idrf.DataSet) *rowConverter {
+	return &rowConverter{dataSet: dataSet, table: -1}
+}
+
+func (c *rowConverter) convert(record *fluxqueries.Record) (idrf.Row, error) {
+	if record.Table != c.table {
+		c.mapColumns(record)
+	}
+
+	row := make(idrf.Row, len(c.dataSet.Columns))
+	for i, column := range c.dataSet.Columns {
+		if c.indexes[i] == -1 {
+			continue
+		}
+
+		value, err := convertValue(record.Values[c.indexes[i]], column.DataType)
+		if err != nil {
+			return nil, fmt.Errorf("could not convert value of column '%s'\n%v", column.Name, err)
+		}
+
+		row[i] = value
+	}
+
+	return row, nil
+}
+
+// mapColumns finds the index of each data set column in the records of a new table.
+// Tags and fields not present in the table are left empty
+func (c *rowConverter) mapColumns(record *fluxqueries.Record) {
+	c.table = record.Table
+	c.indexes = make([]int, len(c.dataSet.Columns))
+	for i, column := range c.dataSet.Columns {
+		name := column.Name
+		if name == c.dataSet.TimeColumn {
+			name = fluxTimeColumn
+		}
+
+		c.indexes[i] = record.Index(name)
+	}
+}
+
+func convertValue(value interface{}, expected idrf.DataType) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	switch typed := value.(type) {
+	case int64:
+		if expected == idrf.IDRFDouble {
+			return float64(typed), nil
+		}
+	case uint64:
+		if expected == idrf.IDRFDouble {
+			return float64(typed), nil
+		} else if expected == idrf.IDRFInteger64 && typed <= math.MaxInt64 {
+			return int64(typed), nil
+		}
+	}
+
+	if !valueFitsType(value, expected) {
+		return nil, fmt.Errorf("value %v can't be converted to %s", value, expected)
+	}
+
+	return value, nil
+}
+
+func valueFitsType(value interface{}, expected idrf.DataType) bool {
+	switch value.(type) {
+	case int64:
+		return expected == idrf.IDRFInteger64
+	case float64:
+		return expected == idrf.IDRFDouble
+	case string:
+		return expected == idrf.IDRFString
+	case bool:
+		return expected == idrf.IDRFBoolean
+	case time.Time:
+		return expected == idrf.IDRFTimestamptz
+	default:
+		return false
+	}
+}
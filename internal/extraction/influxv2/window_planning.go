@@ -0,0 +1,83 @@
+package influxv2
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/timescale/outflux/internal/extraction/config"
+)
+
+// DefaultWindowSize is the size of the time windows a measure is extracted in
+// when no window size was requested
+const DefaultWindowSize = 7 * 24 * time.Hour
+
+// planWindows splits the requested time range of a measure into consecutive windows of the configured
+// size, starting at multiples of the size since the Unix epoch. An unset bound of the range is replaced
+// by the time of the first or last point of the measure. No windows are returned if it has no points
+func (e *Extractor) planWindows(conf *config.MeasureExtraction) ([]timeWindow, error) {
+	requested, err := requestedRange(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	if requested.start.IsZero() {
+		first, found, err := e.pointTime(conf, firstPointFluxTemplate, requested)
+		if err != nil || !found {
+			return nil, err
+		}
+
+		requested.start = first
+	}
+
+	if requested.stop.IsZero() {
+		last, found, err := e.pointTime(conf, lastPointFluxTemplate, requested)
+		if err != nil || !found {
+			return nil, err
+		}
+
+		requested.stop = last.Add(time.Nanosecond)
+	}
+
+	windowSize := conf.WindowSize
+	if windowSize == 0 {
+		windowSize = DefaultWindowSize
+	}
+
+	start := requested.start.UnixNano()
+	offset := start % int64(windowSize)
+	if offset < 0 {
+		offset += int64(windowSize)
+	}
+
+	windows := []timeWindow{}
+	from := requested.start
+	for boundary := time.Unix(0, start-offset).UTC().Add(windowSize); boundary.Before(requested.stop); boundary = boundary.Add(windowSize) {
+		windows = append(windows, timeWindow{start: from, stop: boundary})
+		from = boundary
+	}
+
+	if from.Before(requested.stop) {
+		windows = append(windows, timeWindow{start: from, stop: requested.stop})
+	}
+
+	return windows, nil
+}
+
+// pointTime returns the time of the first or last point of the measure in the requested window
+func (e *Extractor) pointTime(conf *config.MeasureExtraction, template string, requested timeWindow) (time.Time, bool, error) {
+	records, err := e.QueryService.ExecuteQuery(e.Client, buildPointTimeFlux(conf, template, requested))
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("could not find the time range of measure '%s'\n%v", conf.Measure, err)
+	}
+
+	if len(records) == 0 {
+		return time.Time{}, false, nil
+	}
+
+	pointTime, ok := records[0].Value("_time").(time.Time)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("could not find the time range of measure '%s'\nexpected a time, got: %v", conf.Measure, records[0].Value("_time"))
+	}
+
+	return pointTime, true, nil
+}
@@ -0,0 +1,244 @@
+// Package lineprotocol extracts the points of a measurement from a line protocol file
+// or an 'influx_inspect export' dump
+package lineprotocol
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/timescale/outflux/internal/extraction/config"
+	"github.com/timescale/outflux/internal/extraction/filter"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement"
+	"github.com/timescale/outflux/internal/schemamanagement/lineprotocol/lpfile"
+)
+
+// Extractor is an implementation of the extraction.Extractor interface for
+// pulling data out of a line protocol file
+type Extractor struct {
+	Config *config.ExtractionConfig
+	SM     schemamanagement.SchemaManager
+	// Sections finds where the points of the measure are in the file, the whole file is read if nil
+	Sections          SectionFinder
+	Path              string
+	cachedElementData *idrf.Bundle
+}
+
+// SectionFinder returns the sections of the file that hold the points of a measure,
+// as recorded when the schema of the file was discovered
+type SectionFinder interface {
+	FetchSections(measure string) ([]lpfile.Section, error)
+}
+
+// pointReader reads the points of a line protocol file one by one
+type pointReader interface {
+	Next() (*lpfile.Point, error)
+	Close() error
+}
+
+// timeRange holds the inclusive bounds of the extracted points, a zero time is unbounded
+type timeRange struct {
+	from, to time.Time
+}
+
+func (r *timeRange) contains(t time.Time) bool {
+	return (r.from.IsZero() || !t.Before(r.from)) && (r.to.IsZero() || !t.After(r.to))
+}
+
+// ID of the extractor, useful for logging and error reporting
+func (e *Extractor) ID() string {
+	return e.Config.ExtractorID
+}
+
+// Prepare infers the data set schema for the measure in the config from the file
+func (e *Extractor) Prepare() (*idrf.Bundle, error) {
+	measureName := e.Config.MeasureExtraction.Measure
+	log.Printf("Discovering influx schema for measurement: %s", measureName)
+
+	discoveredDataSet, err := e.SM.FetchDataSet(measureName)
+	if err != nil {
+		return nil, fmt.Errorf("%s: could not fetch data set definition for measure: %s\n%v", e.ID(), measureName, err)
+	}
+
+	log.Printf("Discovered: %s", discoveredDataSet.String())
+	e.cachedElementData = &idrf.Bundle{
+		DataDef:  discoveredDataSet,
+		DataChan: make(chan idrf.Row, e.Config.DataBufferSize),
+	}
+
+	return e.cachedElementData, nil
+}
+
+// Start reads the points of a measure from the file and feeds them to a data channel in the
+// order they appear in the file. Periodically (every 'chunkSize' rows) checks for external errors
+// and quits if it detects them
+func (e *Extractor) Start(errChan chan error) error {
+	if e.cachedElementData == nil {
+		return fmt.Errorf("%s: Prepare not called before start", e.ID())
+	}
+
+	dataChan := e.cachedElementData.DataChan
+	defer close(dataChan)
+
+	id := e.ID()
+	measureConf := e.Config.MeasureExtraction
+	log.Printf("Starting extractor '%s' for measure: %s\n", id, measureConf.Measure)
+	if measureConf.Workers > 1 {
+		log.Printf("%s: extraction with multiple workers is not supported for files, reading the file once", id)
+	}
+
+	bounds, err := requestedRange(measureConf)
+	if err != nil {
+		return fmt.Errorf("%s: could not parse requested time range\n%v", id, err)
+	}
+
+	dataSet := e.cachedElementData.DataDef
+	rowFilter, err := filter.New(measureConf.Where, dataSet)
+	if err != nil {
+		return fmt.Errorf("%s: could not parse the where condition\n%v", id, err)
+	}
+
+	reader, err := e.openReader(measureConf.Measure)
+	if err != nil {
+		return fmt.Errorf("%s: could not read file '%s'\n%v", id, e.Path, err)
+	}
+
+	defer reader.Close()
+	log.Printf("%s: Extracting data from file '%s'\n", id, e.Path)
+	chunkSize := uint64(measureConf.ChunkSize)
+	totalRows := uint64(0)
+	for measureConf.Limit == 0 || totalRows < measureConf.Limit {
+		point, err := reader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("%s: error reading file\n%v", id, err)
+		}
+
+		if point.Measurement != measureConf.Measure ||
+			!point.InContext(measureConf.Database, measureConf.RetentionPolicy) ||
+			!bounds.contains(point.Time) {
+			continue
+		}
+
+		row, err := convertPoint(point, dataSet)
+		if err != nil {
+			return fmt.Errorf("%s: could not convert point to IDRF row\n%v", id, err)
+		}
+
+		if !rowFilter.Matches(row) {
+			continue
+		}
+
+		dataChan <- row
+		totalRows++
+		if totalRows%chunkSize == 0 {
+			log.Printf("%s: Extracted %d rows from file", id, totalRows)
+			// check if an error occurred in some other goroutine
+			if err = checkError(errChan); err != nil {
+				return nil
+			}
+		}
+	}
+
+	log.Printf("%s: Extracted %d rows from file", id, totalRows)
+	return nil
+}
+
+// openReader opens the file to read only the sections with the points of the measure,
+// or the whole file if the sections are not known
+func (e *Extractor) openReader(measure string) (pointReader, error) {
+	if e.Sections == nil {
+		return lpfile.Open(e.Path)
+	}
+
+	sections, err := e.Sections.FetchSections(measure)
+	if err != nil {
+		return nil, err
+	}
+
+	return lpfile.OpenSections(e.Path, sections)
+}
+
+// requestedRange returns the time bounds of the config. A checkpoint from a previous run
+// takes precedence over the requested lower bound
+func requestedRange(conf *config.MeasureExtraction) (*timeRange, error) {
+	var err error
+	bounds := &timeRange{}
+	from := conf.From
+	if conf.ResumeFrom != "" {
+		from = conf.ResumeFrom
+	}
+
+	if from != "" {
+		if bounds.from, err = time.Parse(time.RFC3339, from); err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.To != "" {
+		if bounds.to, err = time.Parse(time.RFC3339, conf.To); err != nil {
+			return nil, err
+		}
+	}
+
+	return bounds, nil
+}
+
+// convertPoint creates a row with the time, tags and fields of the point in the order of
+// the data set columns. Tags and fields missing from the point are left empty
+func convertPoint(point *lpfile.Point, dataSet *idrf.DataSet) (idrf.Row, error) {
+	row := make(idrf.Row, len(dataSet.Columns))
+	for i, column := range dataSet.Columns {
+		if column.Name == dataSet.TimeColumn {
+			row[i] = point.Time
+		} else if tag, ok := point.Tags[column.Name]; ok {
+			row[i] = tag
+		} else if field, ok := point.Fields[column.Name]; ok {
+			value, err := convertValue(field, column.DataType)
+			if err != nil {
+				return nil, fmt.Errorf("could not convert value of column '%s'\n%v", column.Name, err)
+			}
+
+			row[i] = value
+		}
+	}
+
+	return row, nil
+}
+
+// convertValue casts integer fields to float when the field has both types in the file
+func convertValue(value interface{}, expected idrf.DataType) (interface{}, error) {
+	var fits bool
+	switch typed := value.(type) {
+	case int64:
+		if expected == idrf.IDRFDouble {
+			return float64(typed), nil
+		}
+
+		fits = expected == idrf.IDRFInteger64
+	case float64:
+		fits = expected == idrf.IDRFDouble
+	case string:
+		fits = expected == idrf.IDRFString
+	case bool:
+		fits = expected == idrf.IDRFBoolean
+	}
+
+	if !fits {
+		return nil, fmt.Errorf("value %v can't be converted to %s", value, expected)
+	}
+
+	return value, nil
+}
+
+func checkError(errorChannel chan error) error {
+	select {
+	case err := <-errorChannel:
+		return err
+	default:
+		return nil
+	}
+}
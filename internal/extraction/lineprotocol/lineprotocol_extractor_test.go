@@ -0,0 +1,220 @@
+package lineprotocol
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/extraction/config"
+	"github.com/timescale/outflux/internal/idrf"
+	lpSchema "github.com/timescale/outflux/internal/schemamanagement/lineprotocol"
+	"github.com/timescale/outflux/internal/schemamanagement/lineprotocol/lpfile"
+	"github.com/timescale/outflux/internal/testutils"
+)
+
+// exportDump switches between the contexts of two databases, the cpu points of db are on both sides
+// of the points of other
+const exportDump = `# INFLUXDB EXPORT: 1677-09-21T00:12:43Z - 2262-04-11T23:47:16Z
+# DDL
+CREATE DATABASE db WITH NAME autogen
+CREATE DATABASE other WITH NAME autogen
+# DML
+# CONTEXT-DATABASE:db
+# CONTEXT-RETENTION-POLICY:autogen
+cpu,host=a usage=1i 1546300800000000000
+mem used=1i 1546300800000000000
+cpu,host=b usage=1.5,up=true 1546300801000000000
+# CONTEXT-DATABASE:other
+cpu,host=c usage=3.5 1546300801000000000
+# CONTEXT-DATABASE:db
+cpu usage=2.5 1546300802000000000
+`
+
+var t1 = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// dbRows are the cpu points of db in exportDump
+var dbRows = []idrf.Row{
+	{t1, "a", nil, float64(1)},
+	{t1.Add(time.Second), "b", true, 1.5},
+	{t1.Add(2 * time.Second), nil, nil, 2.5},
+}
+
+func cpuDataSet() *idrf.DataSet {
+	return &idrf.DataSet{
+		DataSetName: "cpu",
+		Columns: []*idrf.Column{
+			{Name: "time", DataType: idrf.IDRFTimestamptz},
+			{Name: "host", DataType: idrf.IDRFString},
+			{Name: "up", DataType: idrf.IDRFBoolean},
+			{Name: "usage", DataType: idrf.IDRFDouble},
+		},
+		TimeColumn: "time",
+	}
+}
+
+// writeFile writes the content to a temporary file, gzipped if requested
+func writeFile(t *testing.T, content string, gzipped bool) string {
+	file, err := ioutil.TempFile("", "outflux_lp")
+	if err != nil {
+		t.Fatalf("could not create file: %v", err)
+	}
+
+	defer file.Close()
+	var writer io.Writer = file
+	compressed := gzip.NewWriter(file)
+	if gzipped {
+		writer = compressed
+	}
+
+	if _, err = writer.Write([]byte(content)); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+
+	if gzipped {
+		if err = compressed.Close(); err != nil {
+			t.Fatalf("could not write file: %v", err)
+		}
+	}
+
+	return file.Name()
+}
+
+func newExtractor(path string, measureConf *config.MeasureExtraction) *Extractor {
+	conf := &config.ExtractionConfig{ExtractorID: "ext", MeasureExtraction: measureConf, DataBufferSize: 10}
+	return &Extractor{Config: conf, SM: &testutils.SchemaManagerStandIn{DataSet: cpuDataSet()}, Path: path}
+}
+
+func extractRows(t *testing.T, extractor *Extractor, errChan chan error) []idrf.Row {
+	bundle, err := extractor.Prepare()
+	if err != nil {
+		t.Fatalf("could not prepare extractor: %v", err)
+	}
+
+	assert.NoError(t, extractor.Start(errChan))
+	rows := []idrf.Row{}
+	for row := range bundle.DataChan {
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+func TestExtractPlainAndGzippedDumps(t *testing.T) {
+	for _, gzipped := range []bool{false, true} {
+		path := writeFile(t, exportDump, gzipped)
+		defer os.Remove(path)
+
+		conf := &config.MeasureExtraction{Database: "db", RetentionPolicy: "autogen", Measure: "cpu", ChunkSize: 1}
+		assert.Equal(t, dbRows, extractRows(t, newExtractor(path, conf), make(chan error, 1)), "gzipped: %v", gzipped)
+	}
+}
+
+func TestExtractFollowsExportContext(t *testing.T) {
+	path := writeFile(t, exportDump, false)
+	defer os.Remove(path)
+
+	conf := &config.MeasureExtraction{Database: "other", RetentionPolicy: "autogen", Measure: "cpu", ChunkSize: 1}
+	assert.Equal(t, []idrf.Row{{t1.Add(time.Second), "c", nil, 3.5}}, extractRows(t, newExtractor(path, conf), make(chan error, 1)))
+
+	conf = &config.MeasureExtraction{Database: "db", RetentionPolicy: "other", Measure: "cpu", ChunkSize: 1}
+	assert.Equal(t, []idrf.Row{}, extractRows(t, newExtractor(path, conf), make(chan error, 1)))
+
+	// the points of a file without export headers are in any context
+	path = writeFile(t, "cpu,host=a usage=1 1546300800000000000\n", false)
+	defer os.Remove(path)
+	assert.Equal(t, []idrf.Row{{t1, "a", nil, float64(1)}}, extractRows(t, newExtractor(path, conf), make(chan error, 1)))
+}
+
+func TestExtractSections(t *testing.T) {
+	for _, gzipped := range []bool{false, true} {
+		path := writeFile(t, exportDump, gzipped)
+		defer os.Remove(path)
+
+		conf := &config.MeasureExtraction{Database: "db", RetentionPolicy: "autogen", Measure: "cpu", ChunkSize: 1, Limit: 2}
+		extractor := newExtractor(path, conf)
+		sm := lpSchema.NewSchemaManager(path, "db", "autogen", true, lpSchema.NewExplorer())
+		extractor.SM = sm
+		extractor.Sections = sm
+		assert.Equal(t, dbRows[:2], extractRows(t, extractor, make(chan error, 1)), "gzipped: %v", gzipped)
+	}
+}
+
+func TestExtractBoundsAndWhere(t *testing.T) {
+	path := writeFile(t, exportDump, false)
+	defer os.Remove(path)
+
+	testCases := []struct {
+		desc     string
+		conf     *config.MeasureExtraction
+		expected []idrf.Row
+	}{
+		{
+			desc:     "time range",
+			conf:     &config.MeasureExtraction{From: "2019-01-01T00:00:01Z", To: "2019-01-01T00:00:01Z"},
+			expected: dbRows[1:2],
+		}, {
+			desc:     "resume overrides from",
+			conf:     &config.MeasureExtraction{From: "2019-01-01T00:00:00Z", ResumeFrom: "2019-01-01T00:00:02Z"},
+			expected: dbRows[2:],
+		}, {
+			desc:     "where condition",
+			conf:     &config.MeasureExtraction{Where: "host = 'b' OR usage > 2"},
+			expected: dbRows[1:],
+		},
+	}
+
+	for _, tc := range testCases {
+		tc.conf.Database, tc.conf.RetentionPolicy, tc.conf.Measure, tc.conf.ChunkSize = "db", "autogen", "cpu", 1
+		assert.Equal(t, tc.expected, extractRows(t, newExtractor(path, tc.conf), make(chan error, 1)), tc.desc)
+	}
+}
+
+type mockSectionFinder struct{}
+
+func (m *mockSectionFinder) FetchSections(string) ([]lpfile.Section, error) {
+	return nil, fmt.Errorf("generic error")
+}
+
+func TestExtractErrors(t *testing.T) {
+	path := writeFile(t, exportDump, false)
+	defer os.Remove(path)
+
+	conf := &config.MeasureExtraction{Database: "db", RetentionPolicy: "autogen", Measure: "cpu", ChunkSize: 1}
+	extractor := newExtractor(path, conf)
+	assert.Error(t, extractor.Start(make(chan error, 1)), "prepare not called")
+
+	// the first usage is an integer, the second a float that can't be converted to one
+	dataSet := cpuDataSet()
+	dataSet.Columns[3].DataType = idrf.IDRFInteger64
+	extractor.SM = &testutils.SchemaManagerStandIn{DataSet: dataSet}
+	bundle, err := extractor.Prepare()
+	if err != nil {
+		t.Fatalf("could not prepare extractor: %v", err)
+	}
+
+	assert.Error(t, extractor.Start(make(chan error, 1)))
+	rows := 0
+	for range bundle.DataChan {
+		rows++
+	}
+
+	assert.Equal(t, 1, rows)
+
+	extractor = newExtractor(path, conf)
+	extractor.Sections = &mockSectionFinder{}
+	extractor.Prepare()
+	assert.Error(t, extractor.Start(make(chan error, 1)))
+
+	extractor = newExtractor("/does/not/exist", conf)
+	extractor.Prepare()
+	assert.Error(t, extractor.Start(make(chan error, 1)))
+
+	errChan := make(chan error, 1)
+	errChan <- fmt.Errorf("external error")
+	assert.Equal(t, dbRows[:1], extractRows(t, newExtractor(path, conf), errChan), "stops after the first chunk")
+}
@@ -0,0 +1,219 @@
+// Package ts extracts the rows of a table of TimescaleDB or PostgreSQL, so the data migrated to
+// it can be copied back to InfluxDB
+package ts
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/timescale/outflux/internal/connections"
+	"github.com/timescale/outflux/internal/extraction/config"
+	"github.com/timescale/outflux/internal/extraction/filter"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement"
+)
+
+const (
+	selectQueryTemplate = "SELECT %s FROM %s%s ORDER BY %s"
+	limitQueryTemplate  = " LIMIT %d"
+	// JSON columns are selected as text, the ingestors expect the encoded object
+	jsonColumnTemplate = "%s::text"
+)
+
+// Extractor is an implementation of the extraction.Extractor interface for
+// pulling data out of a table of TimescaleDB or PostgreSQL. The database of the
+// measure extraction config is the schema of the table, the measure its name
+type Extractor struct {
+	Config            *config.ExtractionConfig
+	SM                schemamanagement.SchemaManager
+	Conn              connections.PgxWrap
+	cachedElementData *idrf.Bundle
+}
+
+// ID of the extractor, useful for logging and error reporting
+func (e *Extractor) ID() string {
+	return e.Config.ExtractorID
+}
+
+// Prepare describes the columns of the table of the measure in the config as a data set
+func (e *Extractor) Prepare() (*idrf.Bundle, error) {
+	measureName := e.Config.MeasureExtraction.Measure
+	log.Printf("Discovering the columns of table: %s", measureName)
+
+	discoveredDataSet, err := e.SM.FetchDataSet(measureName)
+	if err != nil {
+		return nil, fmt.Errorf("%s: could not fetch data set definition for measure: %s\n%v", e.ID(), measureName, err)
+	}
+
+	log.Printf("Discovered: %s", discoveredDataSet.String())
+	e.cachedElementData = &idrf.Bundle{
+		DataDef:  discoveredDataSet,
+		DataChan: make(chan idrf.Row, e.Config.DataBufferSize),
+	}
+
+	return e.cachedElementData, nil
+}
+
+// Start selects the rows of the table ordered by time, and feeds them to a data channel. Periodically
+// (every 'chunkSize' rows) checks for external errors and quits if it detects them
+func (e *Extractor) Start(errChan chan error) error {
+	if e.cachedElementData == nil {
+		return fmt.Errorf("%s: Prepare not called before start", e.ID())
+	}
+
+	dataChan := e.cachedElementData.DataChan
+	defer close(dataChan)
+
+	id := e.ID()
+	measureConf := e.Config.MeasureExtraction
+	log.Printf("Starting extractor '%s' for measure: %s\n", id, measureConf.Measure)
+	if measureConf.Workers > 1 {
+		log.Printf("%s: extraction with multiple workers is not supported for tables, selecting the rows with one query", id)
+	}
+
+	dataSet := e.cachedElementData.DataDef
+	rowFilter, err := filter.New(measureConf.Where, dataSet)
+	if err != nil {
+		return fmt.Errorf("%s: could not parse the where condition\n%v", id, err)
+	}
+
+	query, args, err := buildSelectQuery(measureConf, dataSet)
+	if err != nil {
+		return fmt.Errorf("%s: could not build the select query\n%v", id, err)
+	}
+
+	rows, err := e.Conn.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: could not select the rows of table '%s'\n%v", id, measureConf.Measure, err)
+	}
+
+	defer rows.Close()
+	chunkSize := uint64(measureConf.ChunkSize)
+	totalRows := uint64(0)
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return fmt.Errorf("%s: could not read a row of table '%s'\n%v", id, measureConf.Measure, err)
+		}
+
+		row := convertValues(values, dataSet)
+		if !rowFilter.Matches(row) {
+			continue
+		}
+
+		dataChan <- row
+		totalRows++
+		if measureConf.Limit != 0 && totalRows >= measureConf.Limit {
+			break
+		}
+
+		if totalRows%chunkSize == 0 {
+			log.Printf("%s: Extracted %d rows from table", id, totalRows)
+			// check if an error occurred in some other goroutine
+			if err = checkError(errChan); err != nil {
+				return nil
+			}
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("%s: could not select the rows of table '%s'\n%v", id, measureConf.Measure, err)
+	}
+
+	log.Printf("%s: Extracted %d rows from table", id, totalRows)
+	return nil
+}
+
+// buildSelectQuery builds the query selecting the columns of the data set from the table of the measure, in the
+// time range of the config ordered by time, and its arguments. A time column of nanoseconds since the Unix epoch
+// is compared to the nanoseconds of the bounds. The rows are limited by the query only without a where condition,
+// since the condition is matched after the rows are selected
+func buildSelectQuery(conf *config.MeasureExtraction, dataSet *idrf.DataSet) (string, []interface{}, error) {
+	columns := make([]string, len(dataSet.Columns))
+	var timeColumn *idrf.Column
+	for i, column := range dataSet.Columns {
+		columns[i] = pgx.Identifier{column.Name}.Sanitize()
+		if column.DataType == idrf.IDRFJson {
+			columns[i] = fmt.Sprintf(jsonColumnTemplate, columns[i])
+		}
+
+		if column.Name == dataSet.TimeColumn {
+			timeColumn = column
+		}
+	}
+
+	table := pgx.Identifier{conf.Measure}
+	if conf.Database != "" {
+		table = pgx.Identifier{conf.Database, conf.Measure}
+	}
+
+	// a checkpoint from a previous run takes precedence over the requested lower bound
+	from := conf.From
+	if conf.ResumeFrom != "" {
+		from = conf.ResumeFrom
+	}
+
+	quotedTime := pgx.Identifier{dataSet.TimeColumn}.Sanitize()
+	conditions := []string{}
+	args := []interface{}{}
+	for _, bound := range []struct {
+		value    string
+		operator string
+	}{{from, ">="}, {conf.To, "<="}} {
+		if bound.value == "" {
+			continue
+		}
+
+		parsed, err := time.Parse(time.RFC3339, bound.value)
+		if err != nil {
+			return "", nil, fmt.Errorf("time bound '%s' must be formatted as %s", bound.value, time.RFC3339)
+		}
+
+		args = append(args, parsed)
+		if timeColumn.DataType == idrf.IDRFInteger64 {
+			args[len(args)-1] = parsed.UnixNano()
+		}
+
+		conditions = append(conditions, fmt.Sprintf("%s %s $%d", quotedTime, bound.operator, len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query := fmt.Sprintf(selectQueryTemplate, strings.Join(columns, ", "), table.Sanitize(), where, quotedTime)
+	if conf.Limit > 0 && conf.Where == "" {
+		query += fmt.Sprintf(limitQueryTemplate, conf.Limit)
+	}
+
+	return query, args, nil
+}
+
+// convertValues creates a row from the values of a selected row, JSON columns selected as text are
+// converted to the bytes of the encoded object
+func convertValues(values []interface{}, dataSet *idrf.DataSet) idrf.Row {
+	row := make(idrf.Row, len(values))
+	for i, value := range values {
+		if text, ok := value.(string); ok && dataSet.Columns[i].DataType == idrf.IDRFJson {
+			row[i] = []byte(text)
+			continue
+		}
+
+		row[i] = value
+	}
+
+	return row
+}
+
+func checkError(errorChannel chan error) error {
+	select {
+	case err := <-errorChannel:
+		return err
+	default:
+		return nil
+	}
+}
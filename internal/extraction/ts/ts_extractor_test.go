@@ -0,0 +1,125 @@
+package ts
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/extraction/config"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/testutils"
+)
+
+var t1 = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func cpuDataSet(timeType idrf.DataType) *idrf.DataSet {
+	return &idrf.DataSet{
+		DataSetName: "cpu",
+		Columns: []*idrf.Column{
+			{Name: "time", DataType: timeType},
+			{Name: "host", DataType: idrf.IDRFString},
+			{Name: "fields", DataType: idrf.IDRFJson},
+		},
+		TimeColumn: "time",
+	}
+}
+
+func TestBuildSelectQuery(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		conf          *config.MeasureExtraction
+		timeType      idrf.DataType
+		expectedQuery string
+		expectedArgs  []interface{}
+		expectErr     bool
+	}{
+		{
+			desc:          "all rows of a table of the search path",
+			conf:          &config.MeasureExtraction{Measure: "cpu"},
+			timeType:      idrf.IDRFTimestamptz,
+			expectedQuery: `SELECT "time", "host", "fields"::text FROM "cpu" ORDER BY "time"`,
+			expectedArgs:  []interface{}{},
+		}, {
+			desc:          "table of a schema in a time range",
+			conf:          &config.MeasureExtraction{Database: "metrics", Measure: "cpu", From: "2019-01-01T00:00:00Z", To: "2019-01-02T00:00:00Z"},
+			timeType:      idrf.IDRFTimestamptz,
+			expectedQuery: `SELECT "time", "host", "fields"::text FROM "metrics"."cpu" WHERE "time" >= $1 AND "time" <= $2 ORDER BY "time"`,
+			expectedArgs:  []interface{}{t1, t1.Add(24 * time.Hour)},
+		}, {
+			desc:          "resume overrides from",
+			conf:          &config.MeasureExtraction{Measure: "cpu", From: "2018-01-01T00:00:00Z", ResumeFrom: "2019-01-01T00:00:00Z"},
+			timeType:      idrf.IDRFTimestamptz,
+			expectedQuery: `SELECT "time", "host", "fields"::text FROM "cpu" WHERE "time" >= $1 ORDER BY "time"`,
+			expectedArgs:  []interface{}{t1},
+		}, {
+			desc:          "time column of nanoseconds since the epoch",
+			conf:          &config.MeasureExtraction{Measure: "cpu", To: "2019-01-01T00:00:00Z"},
+			timeType:      idrf.IDRFInteger64,
+			expectedQuery: `SELECT "time", "host", "fields"::text FROM "cpu" WHERE "time" <= $1 ORDER BY "time"`,
+			expectedArgs:  []interface{}{t1.UnixNano()},
+		}, {
+			desc:          "limit without a where condition",
+			conf:          &config.MeasureExtraction{Measure: "cpu", Limit: 10},
+			timeType:      idrf.IDRFTimestamptz,
+			expectedQuery: `SELECT "time", "host", "fields"::text FROM "cpu" ORDER BY "time" LIMIT 10`,
+			expectedArgs:  []interface{}{},
+		}, {
+			desc:          "the limit is applied after the where condition",
+			conf:          &config.MeasureExtraction{Measure: "cpu", Limit: 10, Where: "host = 'a'"},
+			timeType:      idrf.IDRFTimestamptz,
+			expectedQuery: `SELECT "time", "host", "fields"::text FROM "cpu" ORDER BY "time"`,
+			expectedArgs:  []interface{}{},
+		}, {
+			desc:      "bound not formatted as RFC3339",
+			conf:      &config.MeasureExtraction{Measure: "cpu", From: "yesterday"},
+			timeType:  idrf.IDRFTimestamptz,
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		query, args, err := buildSelectQuery(tc.conf, cpuDataSet(tc.timeType))
+		if tc.expectErr {
+			assert.Error(t, err, tc.desc)
+			continue
+		}
+
+		assert.NoError(t, err, tc.desc)
+		assert.Equal(t, tc.expectedQuery, query, tc.desc)
+		assert.Equal(t, tc.expectedArgs, args, tc.desc)
+	}
+}
+
+func TestConvertValues(t *testing.T) {
+	row := convertValues([]interface{}{t1, "a", `{"usage":1}`}, cpuDataSet(idrf.IDRFTimestamptz))
+	assert.Equal(t, idrf.Row{t1, "a", []byte(`{"usage":1}`)}, row, "only the text of the JSON column is converted")
+
+	row = convertValues([]interface{}{t1, nil, nil}, cpuDataSet(idrf.IDRFTimestamptz))
+	assert.Equal(t, idrf.Row{t1, nil, nil}, row)
+}
+
+func TestExtractErrors(t *testing.T) {
+	extractor := &Extractor{
+		Config: &config.ExtractionConfig{
+			ExtractorID:       "ts",
+			MeasureExtraction: &config.MeasureExtraction{Measure: "cpu", ChunkSize: 1},
+		},
+		SM: &testutils.SchemaManagerStandIn{Err: fmt.Errorf("no table")},
+	}
+
+	assert.Error(t, extractor.Start(make(chan error, 1)), "prepare not called")
+	_, err := extractor.Prepare()
+	assert.Error(t, err)
+
+	extractor.SM = &testutils.SchemaManagerStandIn{DataSet: cpuDataSet(idrf.IDRFTimestamptz)}
+	extractor.Config.MeasureExtraction.Where = "host = "
+	bundle, err := extractor.Prepare()
+	if err != nil {
+		t.Fatalf("could not prepare extractor: %v", err)
+	}
+
+	assert.Error(t, extractor.Start(make(chan error, 1)), "where condition can't be parsed")
+	_, open := <-bundle.DataChan
+	assert.False(t, open, "the data channel is closed")
+}
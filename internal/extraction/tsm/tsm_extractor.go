@@ -0,0 +1,236 @@
+// Package tsm extracts the points of a measurement from the TSM shards and WAL of InfluxDB 1.x,
+// or from a portable backup
+package tsm
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/timescale/outflux/internal/extraction/config"
+	"github.com/timescale/outflux/internal/extraction/filter"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement"
+	"github.com/timescale/outflux/internal/schemamanagement/tsm/tsmstorage"
+)
+
+// errStopped is returned by the series callback when an error occurred in another goroutine
+var errStopped = fmt.Errorf("extraction stopped")
+
+// Extractor is an implementation of the extraction.Extractor interface for
+// pulling data out of TSM shards
+type Extractor struct {
+	Config            *config.ExtractionConfig
+	SM                schemamanagement.SchemaManager
+	Storage           *tsmstorage.Storage
+	cachedElementData *idrf.Bundle
+}
+
+// timeRange holds the inclusive bounds of the extracted points in nanoseconds
+type timeRange struct {
+	from, to int64
+}
+
+func (r *timeRange) contains(t int64) bool {
+	return t >= r.from && t <= r.to
+}
+
+// ID of the extractor, useful for logging and error reporting
+func (e *Extractor) ID() string {
+	return e.Config.ExtractorID
+}
+
+// Prepare infers the data set schema for the measure in the config from the shards
+func (e *Extractor) Prepare() (*idrf.Bundle, error) {
+	measureName := e.Config.MeasureExtraction.Measure
+	log.Printf("Discovering influx schema for measurement: %s", measureName)
+
+	discoveredDataSet, err := e.SM.FetchDataSet(measureName)
+	if err != nil {
+		return nil, fmt.Errorf("%s: could not fetch data set definition for measure: %s\n%v", e.ID(), measureName, err)
+	}
+
+	log.Printf("Discovered: %s", discoveredDataSet.String())
+	e.cachedElementData = &idrf.Bundle{
+		DataDef:  discoveredDataSet,
+		DataChan: make(chan idrf.Row, e.Config.DataBufferSize),
+	}
+
+	return e.cachedElementData, nil
+}
+
+// Start decodes the series of a measure shard by shard, and feeds their points to a data channel.
+// Points are ordered by time within a series of a shard only. Periodically (every 'chunkSize' rows)
+// checks for external errors and quits if it detects them
+func (e *Extractor) Start(errChan chan error) error {
+	if e.cachedElementData == nil {
+		return fmt.Errorf("%s: Prepare not called before start", e.ID())
+	}
+
+	dataChan := e.cachedElementData.DataChan
+	defer close(dataChan)
+
+	id := e.ID()
+	measureConf := e.Config.MeasureExtraction
+	log.Printf("Starting extractor '%s' for measure: %s\n", id, measureConf.Measure)
+	if measureConf.Workers > 1 {
+		log.Printf("%s: extraction with multiple workers is not supported for TSM shards, reading them in order", id)
+	}
+
+	bounds, err := requestedRange(measureConf)
+	if err != nil {
+		return fmt.Errorf("%s: could not parse requested time range\n%v", id, err)
+	}
+
+	dataSet := e.cachedElementData.DataDef
+	rowFilter, err := filter.New(measureConf.Where, dataSet)
+	if err != nil {
+		return fmt.Errorf("%s: could not parse the where condition\n%v", id, err)
+	}
+
+	chunkSize := uint64(measureConf.ChunkSize)
+	totalRows := uint64(0)
+	extractSeries := func(series *tsmstorage.Series) error {
+		for _, t := range series.Times() {
+			if measureConf.Limit != 0 && totalRows >= measureConf.Limit {
+				return nil
+			}
+
+			if !bounds.contains(t) {
+				continue
+			}
+
+			row, err := convertSeriesPoint(series, t, dataSet)
+			if err != nil {
+				return fmt.Errorf("could not convert point to IDRF row\n%v", err)
+			}
+
+			if !rowFilter.Matches(row) {
+				continue
+			}
+
+			dataChan <- row
+			totalRows++
+			if totalRows%chunkSize == 0 {
+				log.Printf("%s: Extracted %d rows from shards", id, totalRows)
+				// check if an error occurred in some other goroutine
+				if err = checkError(errChan); err != nil {
+					return errStopped
+				}
+			}
+		}
+
+		return nil
+	}
+
+	for _, shard := range e.Storage.Shards(measureConf.Database, measureConf.RetentionPolicy) {
+		if measureConf.Limit != 0 && totalRows >= measureConf.Limit {
+			break
+		}
+
+		log.Printf("%s: Extracting data from shard %d\n", id, shard.ID)
+		err = shard.ReadMeasurement(measureConf.Measure, extractSeries)
+		if err == errStopped {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: error reading shard %d\n%v", id, shard.ID, err)
+		}
+	}
+
+	log.Printf("%s: Extracted %d rows from shards", id, totalRows)
+	return nil
+}
+
+// requestedRange returns the time bounds of the config. A checkpoint from a previous run
+// takes precedence over the requested lower bound
+func requestedRange(conf *config.MeasureExtraction) (*timeRange, error) {
+	bounds := &timeRange{from: math.MinInt64, to: math.MaxInt64}
+	from := conf.From
+	if conf.ResumeFrom != "" {
+		from = conf.ResumeFrom
+	}
+
+	if from != "" {
+		parsed, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return nil, err
+		}
+
+		bounds.from = parsed.UnixNano()
+	}
+
+	if conf.To != "" {
+		parsed, err := time.Parse(time.RFC3339, conf.To)
+		if err != nil {
+			return nil, err
+		}
+
+		bounds.to = parsed.UnixNano()
+	}
+
+	return bounds, nil
+}
+
+// convertSeriesPoint creates a row with the time, tags and fields of the series at time t in the
+// order of the data set columns. Tags and fields without a value are left empty
+func convertSeriesPoint(series *tsmstorage.Series, t int64, dataSet *idrf.DataSet) (idrf.Row, error) {
+	row := make(idrf.Row, len(dataSet.Columns))
+	for i, column := range dataSet.Columns {
+		if column.Name == dataSet.TimeColumn {
+			row[i] = time.Unix(0, t).UTC()
+		} else if tag, ok := series.Tags[column.Name]; ok {
+			row[i] = tag
+		} else if field, ok := series.Fields[column.Name][t]; ok {
+			value, err := convertValue(field, column.DataType)
+			if err != nil {
+				return nil, fmt.Errorf("could not convert value of column '%s'\n%v", column.Name, err)
+			}
+
+			row[i] = value
+		}
+	}
+
+	return row, nil
+}
+
+// convertValue casts integer fields to float when the field has both types in the shards,
+// and unsigned integers to signed ones
+func convertValue(value interface{}, expected idrf.DataType) (interface{}, error) {
+	var fits bool
+	switch typed := value.(type) {
+	case uint64:
+		if typed > math.MaxInt64 {
+			return nil, fmt.Errorf("unsigned value %d overflows a signed integer", typed)
+		}
+
+		return convertValue(int64(typed), expected)
+	case int64:
+		if expected == idrf.IDRFDouble {
+			return float64(typed), nil
+		}
+
+		fits = expected == idrf.IDRFInteger64
+	case float64:
+		fits = expected == idrf.IDRFDouble
+	case string:
+		fits = expected == idrf.IDRFString
+	case bool:
+		fits = expected == idrf.IDRFBoolean
+	}
+
+	if !fits {
+		return nil, fmt.Errorf("value %v can't be converted to %s", value, expected)
+	}
+
+	return value, nil
+}
+
+func checkError(errorChannel chan error) error {
+	select {
+	case err := <-errorChannel:
+		return err
+	default:
+		return nil
+	}
+}
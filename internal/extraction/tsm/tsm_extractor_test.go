@@ -0,0 +1,255 @@
+package tsm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/tsdb/engine/tsm1"
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/extraction/config"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/tsm/tsmstorage"
+	"github.com/timescale/outflux/internal/testutils"
+)
+
+var t1 = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func cpuDataSet() *idrf.DataSet {
+	return &idrf.DataSet{
+		DataSetName: "cpu",
+		Columns: []*idrf.Column{
+			{Name: "time", DataType: idrf.IDRFTimestamptz},
+			{Name: "host", DataType: idrf.IDRFString},
+			{Name: "up", DataType: idrf.IDRFBoolean},
+			{Name: "usage", DataType: idrf.IDRFDouble},
+		},
+		TimeColumn: "time",
+	}
+}
+
+func at(seconds int) int64 {
+	return t1.Add(time.Duration(seconds) * time.Second).UnixNano()
+}
+
+func row(seconds int, host, up, usage interface{}) idrf.Row {
+	return idrf.Row{t1.Add(time.Duration(seconds) * time.Second), host, up, usage}
+}
+
+// writeTSM writes a TSM file of a shard of db.autogen and returns its path, keys must be sorted
+func writeTSM(t *testing.T, dataDir, shard string, values map[string][]tsm1.Value, keys ...string) string {
+	shardDir := filepath.Join(dataDir, "db", "autogen", shard)
+	if err := os.MkdirAll(shardDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(shardDir, "000000001-000000001.tsm")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer, err := tsm1.NewTSMWriter(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range keys {
+		if err = writer.Write([]byte(key), values[key]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = writer.WriteIndex(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// writeWAL writes a WAL segment of a shard of db.autogen
+func writeWAL(t *testing.T, walDir, shard string, values map[string][]tsm1.Value) {
+	wal := tsm1.NewWAL(filepath.Join(walDir, "db", "autogen", shard))
+	if err := wal.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	defer wal.Close()
+	if _, err := wal.WriteMulti(values); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// deleteRange writes a tombstone deleting the values of a key of a TSM file in the time range
+func deleteRange(t *testing.T, path, key string, min, max int64) {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := tsm1.NewTSMReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer reader.Close()
+	if err = reader.DeleteRange([][]byte{[]byte(key)}, min, max); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// openStorage creates the data and WAL directories of InfluxDB, and opens them
+func openStorage(t *testing.T, write func(dataDir, walDir string)) (*tsmstorage.Storage, func()) {
+	root, err := ioutil.TempDir("", "outflux_tsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dataDir, walDir := filepath.Join(root, "data"), filepath.Join(root, "wal")
+	write(dataDir, walDir)
+	storage, err := tsmstorage.Open(dataDir, walDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return storage, func() {
+		storage.Close()
+		os.RemoveAll(root)
+	}
+}
+
+// twoShards writes the cpu points of two shards, the first one with a mem series
+func twoShards(t *testing.T) func(dataDir, walDir string) {
+	return func(dataDir, walDir string) {
+		writeTSM(t, dataDir, "1", map[string][]tsm1.Value{
+			"cpu,host=a#!~#usage": {tsm1.NewValue(at(0), int64(1))},
+			"cpu,host=b#!~#up":    {tsm1.NewValue(at(1), true)},
+			"cpu,host=b#!~#usage": {tsm1.NewValue(at(1), 1.5)},
+			"mem#!~#used":         {tsm1.NewValue(at(0), int64(1))},
+		}, "cpu,host=a#!~#usage", "cpu,host=b#!~#up", "cpu,host=b#!~#usage", "mem#!~#used")
+		writeTSM(t, dataDir, "2", map[string][]tsm1.Value{
+			"cpu#!~#usage": {tsm1.NewValue(at(2), 2.5)},
+		}, "cpu#!~#usage")
+	}
+}
+
+func newExtractor(storage *tsmstorage.Storage, measureConf *config.MeasureExtraction) *Extractor {
+	measureConf.Database, measureConf.RetentionPolicy, measureConf.Measure, measureConf.ChunkSize = "db", "autogen", "cpu", 1
+	conf := &config.ExtractionConfig{ExtractorID: "ext", MeasureExtraction: measureConf, DataBufferSize: 10}
+	return &Extractor{Config: conf, SM: &testutils.SchemaManagerStandIn{DataSet: cpuDataSet()}, Storage: storage}
+}
+
+func extractRows(t *testing.T, extractor *Extractor, errChan chan error) []idrf.Row {
+	bundle, err := extractor.Prepare()
+	if err != nil {
+		t.Fatalf("could not prepare extractor: %v", err)
+	}
+
+	assert.NoError(t, extractor.Start(errChan))
+	rows := []idrf.Row{}
+	for row := range bundle.DataChan {
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+func TestExtractMergesWALAndTombstones(t *testing.T) {
+	storage, cleanUp := openStorage(t, func(dataDir, walDir string) {
+		path := writeTSM(t, dataDir, "1", map[string][]tsm1.Value{
+			"cpu,host=a#!~#up":    {tsm1.NewValue(at(0), true), tsm1.NewValue(at(2), false)},
+			"cpu,host=a#!~#usage": {tsm1.NewValue(at(0), 1.0), tsm1.NewValue(at(1), 2.0), tsm1.NewValue(at(2), 3.0)},
+		}, "cpu,host=a#!~#up", "cpu,host=a#!~#usage")
+		deleteRange(t, path, "cpu,host=a#!~#usage", at(2), at(2))
+		writeWAL(t, walDir, "1", map[string][]tsm1.Value{
+			"cpu,host=a#!~#usage": {tsm1.NewValue(at(1), 5.0), tsm1.NewValue(at(3), 6.0)},
+		})
+	})
+	defer cleanUp()
+
+	assert.Equal(t, []idrf.Row{
+		row(0, "a", true, 1.0),
+		row(1, "a", nil, 5.0),
+		row(2, "a", false, nil),
+		row(3, "a", nil, 6.0),
+	}, extractRows(t, newExtractor(storage, &config.MeasureExtraction{}), make(chan error, 1)),
+		"the WAL replaces the value at 1s and the tombstone deletes the usage at 2s")
+}
+
+func TestExtractShardByShard(t *testing.T) {
+	storage, cleanUp := openStorage(t, twoShards(t))
+	defer cleanUp()
+
+	testCases := []struct {
+		desc     string
+		conf     *config.MeasureExtraction
+		expected []idrf.Row
+	}{
+		{
+			desc:     "all series of each shard",
+			conf:     &config.MeasureExtraction{},
+			expected: []idrf.Row{row(0, "a", nil, float64(1)), row(1, "b", true, 1.5), row(2, nil, nil, 2.5)},
+		}, {
+			desc:     "the limit stops before the next shard",
+			conf:     &config.MeasureExtraction{Limit: 2},
+			expected: []idrf.Row{row(0, "a", nil, float64(1)), row(1, "b", true, 1.5)},
+		}, {
+			desc:     "resume overrides from",
+			conf:     &config.MeasureExtraction{From: "2019-01-01T00:00:00Z", ResumeFrom: "2019-01-01T00:00:01Z", To: "2019-01-01T00:00:01Z"},
+			expected: []idrf.Row{row(1, "b", true, 1.5)},
+		}, {
+			desc:     "where condition on a tag and a field",
+			conf:     &config.MeasureExtraction{Where: "host = 'a' OR up = true"},
+			expected: []idrf.Row{row(0, "a", nil, float64(1)), row(1, "b", true, 1.5)},
+		},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, extractRows(t, newExtractor(storage, tc.conf), make(chan error, 1)), tc.desc)
+	}
+}
+
+func TestExtractErrors(t *testing.T) {
+	storage, cleanUp := openStorage(t, twoShards(t))
+	defer cleanUp()
+
+	extractor := newExtractor(storage, &config.MeasureExtraction{})
+	assert.Error(t, extractor.Start(make(chan error, 1)), "prepare not called")
+
+	// the usage of host a is an integer, the one of host b a float that can't be converted to one
+	dataSet := cpuDataSet()
+	dataSet.Columns[3].DataType = idrf.IDRFInteger64
+	extractor.SM = &testutils.SchemaManagerStandIn{DataSet: dataSet}
+	bundle, err := extractor.Prepare()
+	if err != nil {
+		t.Fatalf("could not prepare extractor: %v", err)
+	}
+
+	assert.Error(t, extractor.Start(make(chan error, 1)))
+	rows := 0
+	for range bundle.DataChan {
+		rows++
+	}
+
+	assert.Equal(t, 1, rows)
+
+	errChan := make(chan error, 1)
+	errChan <- fmt.Errorf("external error")
+	assert.Equal(t, 1, len(extractRows(t, newExtractor(storage, &config.MeasureExtraction{}), errChan)), "stops after the first chunk")
+}
+
+func TestConvertUnsigned(t *testing.T) {
+	value, err := convertValue(uint64(5), idrf.IDRFInteger64)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), value)
+	_, err = convertValue(uint64(math.MaxUint64), idrf.IDRFInteger64)
+	assert.Error(t, err)
+}
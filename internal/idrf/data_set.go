@@ -53,8 +53,9 @@ func NewDataSet(dataSetName string, columns []*Column, timeColumn string) (*Data
 
 		columnSet[columnInfo.Name] = true
 		if columnInfo.Name == timeColumn {
-			if columnInfo.DataType != IDRFTimestamp && columnInfo.DataType != IDRFTimestamptz {
-				return nil, fmt.Errorf("time column '%s', is not of a Timestamp(tz) type", timeColumn)
+			// an Integer64 time column holds the nanoseconds since the Unix epoch
+			if columnInfo.DataType != IDRFTimestamp && columnInfo.DataType != IDRFTimestamptz && columnInfo.DataType != IDRFInteger64 {
+				return nil, fmt.Errorf("time column '%s', is not of a Timestamp(tz) or Integer64 type", timeColumn)
 			}
 
 			timeColumnDefined = true
@@ -32,6 +32,11 @@ func TestNewDataSet(t *testing.T) {
 		t.Error("Data Set should not have been created, time column not a timestamp")
 	}
 
+	epochColumn, _ := NewColumn("Col 1", IDRFInteger64)
+	if _, error := NewDataSet("Data Set", []*Column{epochColumn}, "Col 1"); error != nil {
+		t.Errorf("Data Set should have been created with an Integer64 time column. Unexpected err: %v", error)
+	}
+
 	if _, error := NewDataSet("Data Set", columns, ""); error == nil {
 		t.Error("data set should not have been created with time column empty")
 	}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// FileOutput holds the settings of an ingestor that writes the rows to files instead of a database.
+// The files of a data set are written to its own directory, one file for each period of time
+type FileOutput struct {
+	// Directory is where the directories of the data sets are created
+	Directory string
+	Format    FileFormat
+	// Gzip compresses the CSV files
+	Gzip   bool
+	Period FilePeriod
+}
+
+// FileFormat describes how the rows are encoded in the output files
+type FileFormat int
+
+// Available values for the FileFormat enum
+const (
+	CSVFormat FileFormat = iota + 1
+	ParquetFormat
+)
+
+// ParseFileFormatString returns the enum value matching the string, or an error
+func ParseFileFormatString(format string) (FileFormat, error) {
+	switch format {
+	case "CSV":
+		return CSVFormat, nil
+	case "Parquet":
+		return ParquetFormat, nil
+	default:
+		return CSVFormat, fmt.Errorf("unknown file format '%s'", format)
+	}
+}
+
+func (f FileFormat) String() string {
+	switch f {
+	case CSVFormat:
+		return "CSV"
+	case ParquetFormat:
+		return "Parquet"
+	default:
+		panic("unknown type")
+	}
+}
+
+// FilePeriod describes the period of time covered by the rows of an output file
+type FilePeriod int
+
+// Available values for the FilePeriod enum
+const (
+	HourPeriod FilePeriod = iota + 1
+	DayPeriod
+	MonthPeriod
+	YearPeriod
+)
+
+// ParseFilePeriodString returns the enum value matching the string, or an error
+func ParseFilePeriodString(period string) (FilePeriod, error) {
+	switch period {
+	case "Hour":
+		return HourPeriod, nil
+	case "Day":
+		return DayPeriod, nil
+	case "Month":
+		return MonthPeriod, nil
+	case "Year":
+		return YearPeriod, nil
+	default:
+		return DayPeriod, fmt.Errorf("unknown file period '%s'", period)
+	}
+}
+
+func (p FilePeriod) String() string {
+	switch p {
+	case HourPeriod:
+		return "Hour"
+	case DayPeriod:
+		return "Day"
+	case MonthPeriod:
+		return "Month"
+	case YearPeriod:
+		return "Year"
+	default:
+		panic("unknown type")
+	}
+}
+
+// Start returns the start of the period a time is in, in UTC
+func (p FilePeriod) Start(t time.Time) time.Time {
+	t = t.UTC()
+	switch p {
+	case HourPeriod:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, time.UTC)
+	case DayPeriod:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case MonthPeriod:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case YearPeriod:
+		return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		panic("unknown type")
+	}
+}
+
+// Name formats the start of a period as the name of its file
+func (p FilePeriod) Name(start time.Time) string {
+	switch p {
+	case HourPeriod:
+		return start.Format("2006-01-02T15")
+	case DayPeriod:
+		return start.Format("2006-01-02")
+	case MonthPeriod:
+		return start.Format("2006-01")
+	case YearPeriod:
+		return start.Format("2006")
+	default:
+		panic("unknown type")
+	}
+}
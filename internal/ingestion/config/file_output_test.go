@@ -0,0 +1,45 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileFormatParse(t *testing.T) {
+	for _, format := range []FileFormat{CSVFormat, ParquetFormat} {
+		x, err := ParseFileFormatString(format.String())
+		assert.Equal(t, format, x)
+		assert.NoError(t, err)
+	}
+
+	_, err := ParseFileFormatString("anything else")
+	assert.Error(t, err)
+}
+
+func TestFilePeriod(t *testing.T) {
+	now := time.Date(2019, time.March, 14, 15, 9, 26, 535, time.FixedZone("UTC+1", 3600))
+	testCases := []struct {
+		period FilePeriod
+		start  time.Time
+		name   string
+	}{
+		{HourPeriod, time.Date(2019, time.March, 14, 14, 0, 0, 0, time.UTC), "2019-03-14T14"},
+		{DayPeriod, time.Date(2019, time.March, 14, 0, 0, 0, 0, time.UTC), "2019-03-14"},
+		{MonthPeriod, time.Date(2019, time.March, 1, 0, 0, 0, 0, time.UTC), "2019-03"},
+		{YearPeriod, time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC), "2019"},
+	}
+
+	for _, tc := range testCases {
+		x, err := ParseFilePeriodString(tc.period.String())
+		assert.Equal(t, tc.period, x)
+		assert.NoError(t, err)
+		start := tc.period.Start(now)
+		assert.Equal(t, tc.start, start, tc.period.String())
+		assert.Equal(t, tc.name, tc.period.Name(start), tc.period.String())
+	}
+
+	_, err := ParseFilePeriodString("anything else")
+	assert.Error(t, err)
+}
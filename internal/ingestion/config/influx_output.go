@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// InfluxOutput holds the settings of an ingestor that writes the rows back to InfluxDB as points of line
+// protocol, instead of a PostgreSQL database. The columns of a row have a role in the point: the time column
+// is its timestamp, the tag columns and the keys of the JSON tags column are its tags, and all other columns
+// and the keys of the JSON fields column are its fields
+type InfluxOutput struct {
+	// Server is the URL of the InfluxDB server, the points are written to its /write endpoint
+	Server   string
+	Username string
+	Password string
+	Database string
+	// RetentionPolicy the points are written to, the default retention policy of the database if empty
+	RetentionPolicy string
+	// Precision the timestamps of the points are truncated to
+	Precision Precision
+	// Retries is how many times a request that failed with a network or server error is sent again
+	Retries uint
+	// RetryInterval is the wait before the first retry, it doubles with each retry
+	RetryInterval time.Duration
+	// TagColumns are the columns written as tags, columns the data set doesn't have are skipped
+	TagColumns []string
+	// JSONTagsColumn if not empty, is the JSON column whose keys are written as tags
+	JSONTagsColumn string
+	// JSONFieldsColumn if not empty, is the JSON column whose keys are written as fields
+	JSONFieldsColumn string
+}
+
+// Precision describes the unit of the timestamps of the points written to InfluxDB
+type Precision int
+
+// Available values for the Precision enum
+const (
+	NanosecondPrecision Precision = iota + 1
+	MicrosecondPrecision
+	MillisecondPrecision
+	SecondPrecision
+)
+
+// ParsePrecisionString returns the enum value matching the string, or an error
+func ParsePrecisionString(precision string) (Precision, error) {
+	switch precision {
+	case "ns":
+		return NanosecondPrecision, nil
+	case "u":
+		return MicrosecondPrecision, nil
+	case "ms":
+		return MillisecondPrecision, nil
+	case "s":
+		return SecondPrecision, nil
+	default:
+		return NanosecondPrecision, fmt.Errorf("unknown precision '%s'", precision)
+	}
+}
+
+func (p Precision) String() string {
+	switch p {
+	case NanosecondPrecision:
+		return "ns"
+	case MicrosecondPrecision:
+		return "u"
+	case MillisecondPrecision:
+		return "ms"
+	case SecondPrecision:
+		return "s"
+	default:
+		panic("unknown type")
+	}
+}
+
+// Timestamp returns the time in units of the precision since the Unix epoch, times between
+// two units are truncated to the earlier one
+func (p Precision) Timestamp(t time.Time) int64 {
+	var unit int64
+	switch p {
+	case NanosecondPrecision:
+		return t.UnixNano()
+	case MicrosecondPrecision:
+		unit = int64(time.Microsecond)
+	case MillisecondPrecision:
+		unit = int64(time.Millisecond)
+	case SecondPrecision:
+		unit = int64(time.Second)
+	default:
+		panic("unknown type")
+	}
+
+	nanos := t.UnixNano()
+	timestamp := nanos / unit
+	if nanos%unit < 0 {
+		timestamp--
+	}
+
+	return timestamp
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrecisionParse(t *testing.T) {
+	for _, precision := range []Precision{NanosecondPrecision, MicrosecondPrecision, MillisecondPrecision, SecondPrecision} {
+		x, err := ParsePrecisionString(precision.String())
+		assert.Equal(t, precision, x)
+		assert.NoError(t, err)
+	}
+
+	_, err := ParsePrecisionString("h")
+	assert.Error(t, err)
+}
+
+func TestPrecisionTimestamp(t *testing.T) {
+	after := time.Unix(1546300800, 123456789)
+	before := time.Unix(-1, 999999999)
+	testCases := []struct {
+		precision Precision
+		after     int64
+		before    int64
+	}{
+		{NanosecondPrecision, 1546300800123456789, -1},
+		{MicrosecondPrecision, 1546300800123456, -1},
+		{MillisecondPrecision, 1546300800123, -1},
+		{SecondPrecision, 1546300800, -1},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.after, tc.precision.Timestamp(after), tc.precision.String())
+		assert.Equal(t, tc.before, tc.precision.Timestamp(before), tc.precision.String())
+	}
+}
@@ -2,7 +2,9 @@ package config
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/timescale/outflux/internal/checkpoint"
 	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
 )
 
@@ -15,6 +17,37 @@ type IngestorConfig struct {
 	SchemaStrategy          schemaconfig.SchemaStrategy
 	Schema                  string
 	ChunkTimeInterval       string
+	// RetentionPeriod if > 0, a retention policy dropping older chunks is added to created hypertables
+	RetentionPeriod time.Duration
+	// Compression if not nil, is enabled on created hypertables
+	Compression *schemaconfig.Compression
+	// SpacePartitioning if not nil, created hypertables are also hash partitioned by its column
+	SpacePartitioning *schemaconfig.SpacePartitioning
+	// Indexes if not nil, are created on the hypertable once all the data is ingested
+	Indexes *schemaconfig.Indexes
+	// CheckpointKey identifies the source of the data in the checkpoint table.
+	// If nil, no checkpoints are recorded
+	CheckpointKey *checkpoint.Key
+	// ResumeFrom if set, rows at or after this time are deleted from the
+	// target table in the first ingestion transaction, since they will be extracted again
+	ResumeFrom *time.Time
+	// OnConflict selects what happens to rows with the time and key columns of an existing row
+	OnConflict ConflictStrategy
+	// ConflictColumns are the columns besides the time column that identify a row when upserting,
+	// columns the data set doesn't have are skipped
+	ConflictColumns []string
+	// FileOutput if not nil, the rows are written to files instead of TimescaleDB
+	FileOutput *FileOutput
+	// UnsortedRows is true if the rows are not extracted in time order, the file ingestor
+	// then keeps the files of several periods open
+	UnsortedRows bool
+	// RangePartitioning if not nil, the rows are written to range partitioned tables of plain
+	// PostgreSQL instead of hypertables
+	RangePartitioning *schemaconfig.RangePartitioning
+	// ScriptOutput if not nil, the rows are written to a SQL script instead of TimescaleDB
+	ScriptOutput *ScriptOutput
+	// InfluxOutput if not nil, the rows are written as points to InfluxDB instead of TimescaleDB
+	InfluxOutput *InfluxOutput
 }
 
 // CommitStrategy describes how the ingestor should handle the ingested data
@@ -49,3 +82,46 @@ func (s CommitStrategy) String() string {
 		panic("unknown type")
 	}
 }
+
+// ConflictStrategy describes how the ingestor handles rows with the time and tags of an existing row.
+// Rows are copied straight into the table, or upserted on a unique index through a staging table
+type ConflictStrategy int
+
+// Available values for the ConflictStrategy enum
+const (
+	NoConflictAction ConflictStrategy = iota + 1
+	ConflictDoNothing
+	ConflictDoUpdate
+)
+
+// ParseConflictStrategyString returns the enum value matching the string, or an error
+func ParseConflictStrategyString(strategy string) (ConflictStrategy, error) {
+	switch strategy {
+	case "None":
+		return NoConflictAction, nil
+	case "DoNothing":
+		return ConflictDoNothing, nil
+	case "DoUpdate":
+		return ConflictDoUpdate, nil
+	default:
+		return NoConflictAction, fmt.Errorf("unknown conflict strategy '%s'", strategy)
+	}
+}
+
+func (s ConflictStrategy) String() string {
+	switch s {
+	case NoConflictAction:
+		return "None"
+	case ConflictDoNothing:
+		return "DoNothing"
+	case ConflictDoUpdate:
+		return "DoUpdate"
+	default:
+		panic("unknown type")
+	}
+}
+
+// Upserts returns true if the rows are inserted with an ON CONFLICT clause
+func (s ConflictStrategy) Upserts() bool {
+	return s == ConflictDoNothing || s == ConflictDoUpdate
+}
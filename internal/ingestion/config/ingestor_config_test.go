@@ -16,3 +16,18 @@ func TestIngestorConfigParse(t *testing.T) {
 	x, err = ParseStrategyString("anything else")
 	assert.Error(t, err)
 }
+
+func TestConflictStrategyParse(t *testing.T) {
+	for _, strategy := range []ConflictStrategy{NoConflictAction, ConflictDoNothing, ConflictDoUpdate} {
+		x, err := ParseConflictStrategyString(strategy.String())
+		assert.Equal(t, strategy, x)
+		assert.NoError(t, err)
+	}
+
+	_, err := ParseConflictStrategyString("anything else")
+	assert.Error(t, err)
+	assert.False(t, NoConflictAction.Upserts())
+	assert.False(t, ConflictStrategy(0).Upserts())
+	assert.True(t, ConflictDoNothing.Upserts())
+	assert.True(t, ConflictDoUpdate.Upserts())
+}
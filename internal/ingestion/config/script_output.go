@@ -0,0 +1,21 @@
+package config
+
+import "strings"
+
+// MeasurePlaceholder is replaced with the name of the table of a data set in the path of the script,
+// so each data set is written to its own script
+const MeasurePlaceholder = "{measure}"
+
+// ScriptOutput holds the settings of an ingestor that writes the statements creating the table of a data set,
+// and its rows as COPY blocks, to a SQL script instead of a database. The script is meant to be applied with psql
+type ScriptOutput struct {
+	// Path of the script, the data sets written to the same path are appended to one script
+	Path string
+	// CreateSchema if set, the script creates the output schema if it doesn't exist
+	CreateSchema bool
+}
+
+// DataSetPath returns the path of the script the data set is written to
+func (s *ScriptOutput) DataSetPath(dataSet string) string {
+	return strings.Replace(s.Path, MeasurePlaceholder, dataSet, -1)
+}
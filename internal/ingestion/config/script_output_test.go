@@ -0,0 +1,12 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScriptOutputDataSetPath(t *testing.T) {
+	assert.Equal(t, "/tmp/db.sql", (&ScriptOutput{Path: "/tmp/db.sql"}).DataSetPath("cpu"))
+	assert.Equal(t, "/tmp/cpu/cpu.sql", (&ScriptOutput{Path: "/tmp/{measure}/{measure}.sql"}).DataSetPath("cpu"))
+}
@@ -0,0 +1,105 @@
+package file
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/timescale/outflux/internal/idrf"
+)
+
+// csvEncoder writes the rows to a CSV file with a header of the column names, optionally gzipped.
+// Times are formatted as RFC3339 with nanoseconds, and null values are written as empty fields.
+// Rows appended to a gzipped file are compressed in a new gzip member, readers decompress the
+// members as a single stream
+type csvEncoder struct {
+	file *os.File
+	// gzip is nil if the file is not compressed
+	gzip   *gzip.Writer
+	writer *csv.Writer
+	record []string
+}
+
+// newCSVEncoder creates the file, or opens it to append rows after the ones already written
+func newCSVEncoder(path string, appending, gzipped bool, columns []*idrf.Column) (*csvEncoder, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appending {
+		flags = os.O_WRONLY | os.O_APPEND
+	}
+
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	encoder := &csvEncoder{file: file, record: make([]string, len(columns))}
+	var out io.Writer = file
+	if gzipped {
+		encoder.gzip = gzip.NewWriter(file)
+		out = encoder.gzip
+	}
+
+	encoder.writer = csv.NewWriter(out)
+	if appending {
+		return encoder, nil
+	}
+
+	for i, column := range columns {
+		encoder.record[i] = column.Name
+	}
+
+	if err = encoder.writer.Write(encoder.record); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return encoder, nil
+}
+
+func (e *csvEncoder) encode(row idrf.Row) error {
+	for i, value := range row {
+		e.record[i] = formatCSVValue(value)
+	}
+
+	return e.writer.Write(e.record)
+}
+
+func (e *csvEncoder) close() error {
+	e.writer.Flush()
+	err := e.writer.Error()
+	if e.gzip != nil {
+		if gzipErr := e.gzip.Close(); err == nil {
+			err = gzipErr
+		}
+	}
+
+	if closeErr := e.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+func formatCSVValue(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case time.Time:
+		return value.UTC().Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(value), 'g', -1, 32)
+	case []byte:
+		// JSON columns
+		return string(value)
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,211 @@
+package file
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/ingestion/config"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+	"github.com/timescale/outflux/internal/utils"
+)
+
+const (
+	csvExtension     = ".csv"
+	gzipExtension    = ".gz"
+	parquetExtension = ".parquet"
+	// the most files kept open at once when the rows are not sorted by time
+	maxOpenFiles = 32
+)
+
+// FileIngestor implements an ingestor that writes the rows of a data set to files instead of a database.
+// The files are written to a directory named after the data set, in a directory named after the output
+// schema if one is set. Each file holds the rows of a period of time, and is named after its start
+type FileIngestor struct {
+	Config       *config.IngestorConfig
+	cachedBundle *idrf.Bundle
+}
+
+// ID returns a string identifying the ingestor instance in logs
+func (i *FileIngestor) ID() string {
+	return i.Config.IngestorID
+}
+
+// Prepare checks that the columns of the data set can be written in the output format, and creates the
+// directory of the data set. With the drop schema strategies the files already in it are removed
+func (i *FileIngestor) Prepare(bundle *idrf.Bundle) error {
+	i.cachedBundle = bundle
+	output := i.Config.FileOutput
+	if output.Format == config.ParquetFormat {
+		if _, _, err := parquetSchema(bundle.DataDef.Columns); err != nil {
+			return fmt.Errorf("%s: %v", i.Config.IngestorID, err)
+		}
+	}
+
+	dir := i.directory()
+	switch i.Config.SchemaStrategy {
+	case schemaconfig.DropAndCreate, schemaconfig.DropCascadeAndCreate:
+		log.Printf("%s: removing the files in '%s'", i.Config.IngestorID, dir)
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("%s: could not remove directory '%s'\n%v", i.Config.IngestorID, dir, err)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("%s: could not create directory '%s'\n%v", i.Config.IngestorID, dir, err)
+	}
+
+	return nil
+}
+
+// Start consumes a data channel of idrf.Row(s) and writes each row to the file of its period.
+// A file of a period that already exists is overwritten. Rows sorted by time are written one file after
+// the other, the file of a period is closed when the rows of the next period start. Otherwise up to
+// ${maxOpenFiles} files are kept open. On each ${batchSize} rows the ingestor checks for errors in the
+// other goroutines, and removes the files it wrote if it should roll back
+func (i *FileIngestor) Start(errChan chan error) error {
+	if i.cachedBundle == nil {
+		return fmt.Errorf("%s: Start called without calling Prepare first", i.Config.IngestorID)
+	}
+
+	id := i.Config.IngestorID
+	if utils.CheckError(errChan) != nil {
+		log.Printf("%s: received external error before starting data insertion. Quitting\n", id)
+		return nil
+	}
+
+	dataSet := i.cachedBundle.DataDef
+	timeIndex := timeColumnIndex(dataSet)
+	files := i.newPeriodFiles(dataSet)
+	numRows := uint(0)
+	batchRows := uint16(0)
+	log.Printf("Starting file ingestor '%s', writing %s files of a %s to '%s'", id, i.Config.FileOutput.Format, i.Config.FileOutput.Period, i.directory())
+	for row := range i.cachedBundle.DataChan {
+		batchRows++
+		if batchRows >= i.Config.BatchSize {
+			batchRows = 0
+			if i.Config.RollbackOnExternalError && utils.CheckError(errChan) != nil {
+				log.Printf("%s: Error received from outside of ingestor. Removing the written files\n", id)
+				files.closeAll()
+				removeFiles(files.paths)
+				return nil
+			}
+		}
+
+		start, err := i.periodStart(row[timeIndex])
+		if err != nil {
+			files.closeAll()
+			return fmt.Errorf("%s: %v", id, err)
+		}
+
+		encoder, err := files.encoder(start)
+		if err != nil {
+			files.closeAll()
+			return fmt.Errorf("%s: %v", id, err)
+		}
+
+		if err = encoder.encode(row); err != nil {
+			files.closeAll()
+			return fmt.Errorf("%s: could not write row\n%v", id, err)
+		}
+
+		numRows++
+	}
+
+	if err := files.closeAll(); err != nil {
+		return fmt.Errorf("%s: could not write files\n%v", id, err)
+	}
+
+	log.Printf("%s: Complete. Wrote %d rows to %d files.\n", id, numRows, len(files.paths))
+	return nil
+}
+
+// directory returns the directory the files of the data set are written to
+func (i *FileIngestor) directory() string {
+	return filepath.Join(i.Config.FileOutput.Directory, i.Config.Schema, i.cachedBundle.DataDef.DataSetName)
+}
+
+// newPeriodFiles creates the files of the periods of the data set, keeping one open at a time
+// if the rows are sorted by time
+func (i *FileIngestor) newPeriodFiles(dataSet *idrf.DataSet) *periodFiles {
+	limit := 1
+	if i.Config.UnsortedRows {
+		limit = maxOpenFiles
+	}
+
+	parts := i.Config.FileOutput.Format == config.ParquetFormat
+	return newPeriodFiles(limit, parts, i.filePath, func(path string, appending bool) (rowEncoder, error) {
+		return i.newEncoder(path, appending, dataSet)
+	})
+}
+
+// filePath returns the path of a part of the file of the period starting at a time. The first part
+// is named after the start of the period, the next parts also get their number
+func (i *FileIngestor) filePath(start time.Time, part int) string {
+	output := i.Config.FileOutput
+	name := output.Period.Name(start)
+	if part > 0 {
+		name += "." + strconv.Itoa(part)
+	}
+
+	switch {
+	case output.Format == config.ParquetFormat:
+		name += parquetExtension
+	case output.Gzip:
+		name += csvExtension + gzipExtension
+	default:
+		name += csvExtension
+	}
+
+	return filepath.Join(i.directory(), name)
+}
+
+func (i *FileIngestor) newEncoder(path string, appending bool, dataSet *idrf.DataSet) (rowEncoder, error) {
+	if i.Config.FileOutput.Format == config.ParquetFormat {
+		return newParquetEncoder(path, dataSet.Columns)
+	}
+
+	return newCSVEncoder(path, appending, i.Config.FileOutput.Gzip, dataSet.Columns)
+}
+
+// periodStart returns the start of the period of the value of the time column, a time or
+// the nanoseconds since the Unix epoch
+func (i *FileIngestor) periodStart(value interface{}) (time.Time, error) {
+	switch value := value.(type) {
+	case time.Time:
+		return i.Config.FileOutput.Period.Start(value), nil
+	case int64:
+		return i.Config.FileOutput.Period.Start(time.Unix(0, value)), nil
+	default:
+		return time.Time{}, fmt.Errorf("value %v of the time column is not a time", value)
+	}
+}
+
+// rowEncoder writes the rows of a period to its file
+type rowEncoder interface {
+	encode(row idrf.Row) error
+	// close flushes the written rows and closes the file
+	close() error
+}
+
+func removeFiles(paths []string) {
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil {
+			log.Printf("could not remove file '%s'\n%v", path, err)
+		}
+	}
+}
+
+func timeColumnIndex(dataSet *idrf.DataSet) int {
+	for i, column := range dataSet.Columns {
+		if column.Name == dataSet.TimeColumn {
+			return i
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,258 @@
+package file
+
+import (
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/ingestion/config"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+var (
+	firstDay  = time.Date(2019, time.January, 1, 10, 0, 0, 1, time.UTC)
+	secondDay = time.Date(2019, time.January, 2, 10, 0, 0, 0, time.UTC)
+	dataSet   = &idrf.DataSet{
+		DataSetName: "cpu",
+		TimeColumn:  "time",
+		Columns: []*idrf.Column{
+			{Name: "time", DataType: idrf.IDRFTimestamptz},
+			{Name: "host", DataType: idrf.IDRFString},
+			{Name: "usage", DataType: idrf.IDRFDouble},
+			{Name: "fields", DataType: idrf.IDRFJson},
+		},
+	}
+)
+
+func TestFileIngestorWritesCSVFilesByPeriod(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	for _, gzipped := range []bool{false, true} {
+		output := &config.FileOutput{Directory: dir, Format: config.CSVFormat, Gzip: gzipped, Period: config.DayPeriod}
+		ingestor := newIngestor(output, schemaconfig.DropAndCreate)
+		rows := []idrf.Row{
+			{secondDay, "b", 2.5, nil},
+			{firstDay, "a", nil, []byte(`{"x":1}`)},
+		}
+		assert.NoError(t, ingestor.Prepare(bundle(rows)))
+		assert.NoError(t, ingestor.Start(make(chan error, 1)))
+
+		extension := ".csv"
+		if gzipped {
+			extension = ".csv.gz"
+		}
+
+		// the files of the other format were removed by the drop strategy
+		files, _ := filepath.Glob(filepath.Join(dir, "public", "cpu", "*"))
+		assert.Equal(t, []string{
+			filepath.Join(dir, "public", "cpu", "2019-01-01"+extension),
+			filepath.Join(dir, "public", "cpu", "2019-01-02"+extension),
+		}, files)
+		assert.Equal(t, "time,host,usage,fields\n2019-01-01T10:00:00.000000001Z,a,,\"{\"\"x\"\":1}\"\n", readFile(t, files[0], gzipped))
+		assert.Equal(t, "time,host,usage,fields\n2019-01-02T10:00:00Z,b,2.5,\n", readFile(t, files[1], gzipped))
+	}
+}
+
+func TestFileIngestorWritesParquetFiles(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	output := &config.FileOutput{Directory: dir, Format: config.ParquetFormat, Period: config.MonthPeriod}
+	ingestor := newIngestor(output, schemaconfig.CreateIfMissing)
+	rows := []idrf.Row{
+		{firstDay, "a", 1.5, []byte(`{"x":1}`)},
+		{secondDay, nil, 2.5, nil},
+	}
+	assert.NoError(t, ingestor.Prepare(bundle(rows)))
+	assert.NoError(t, ingestor.Start(make(chan error, 1)))
+
+	file, err := local.NewLocalFileReader(filepath.Join(dir, "public", "cpu", "2019-01.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	parquetReader, err := reader.NewParquetColumnReader(file, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parquetReader.ReadStop()
+
+	assert.Equal(t, int64(2), parquetReader.GetNumRows())
+	expected := [][]interface{}{
+		{firstDay.UnixNano(), secondDay.UnixNano()},
+		{"a", nil},
+		{1.5, 2.5},
+		{`{"x":1}`, nil},
+	}
+	for i, values := range expected {
+		read, _, _, err := parquetReader.ReadColumnByIndex(int64(i), 2)
+		assert.NoError(t, err)
+		assert.Equal(t, values, read, dataSet.Columns[i].Name)
+	}
+}
+
+func TestFileIngestorReopensClosedPeriods(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// rows sorted by time keep one file open, the file of the first day is reopened for its last row
+	rows := []idrf.Row{
+		{firstDay, "a", 1.5, nil},
+		{secondDay, "b", 2.5, nil},
+		{firstDay.Add(time.Hour), "c", 3.5, nil},
+	}
+	for _, gzipped := range []bool{false, true} {
+		output := &config.FileOutput{Directory: dir, Format: config.CSVFormat, Gzip: gzipped, Period: config.DayPeriod}
+		ingestor := newIngestor(output, schemaconfig.DropAndCreate)
+		assert.NoError(t, ingestor.Prepare(bundle(rows)))
+		assert.NoError(t, ingestor.Start(make(chan error, 1)))
+
+		files, _ := filepath.Glob(filepath.Join(dir, "public", "cpu", "*"))
+		assert.Equal(t, 2, len(files))
+		expected := "time,host,usage,fields\n2019-01-01T10:00:00.000000001Z,a,1.5,\n2019-01-01T11:00:00.000000001Z,c,3.5,\n"
+		assert.Equal(t, expected, readFile(t, files[0], gzipped))
+	}
+
+	// Parquet files can't be appended to, the rows continue in a new part
+	output := &config.FileOutput{Directory: dir, Format: config.ParquetFormat, Period: config.DayPeriod}
+	ingestor := newIngestor(output, schemaconfig.DropAndCreate)
+	assert.NoError(t, ingestor.Prepare(bundle(rows)))
+	assert.NoError(t, ingestor.Start(make(chan error, 1)))
+	files, _ := filepath.Glob(filepath.Join(dir, "public", "cpu", "*"))
+	assert.Equal(t, []string{
+		filepath.Join(dir, "public", "cpu", "2019-01-01.1.parquet"),
+		filepath.Join(dir, "public", "cpu", "2019-01-01.parquet"),
+		filepath.Join(dir, "public", "cpu", "2019-01-02.parquet"),
+	}, files)
+}
+
+func TestFileIngestorPrepareErrors(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	unknown := &idrf.DataSet{DataSetName: "cpu", TimeColumn: "time", Columns: []*idrf.Column{{Name: "time", DataType: idrf.IDRFUnknown}}}
+	comma := &idrf.DataSet{DataSetName: "cpu", TimeColumn: "time", Columns: []*idrf.Column{{Name: "a,b", DataType: idrf.IDRFString}}}
+	testCases := []struct {
+		desc      string
+		format    config.FileFormat
+		dataSet   *idrf.DataSet
+		expectErr bool
+	}{
+		{desc: "unknown type in a Parquet file", format: config.ParquetFormat, dataSet: unknown, expectErr: true},
+		{desc: "comma in a Parquet column name", format: config.ParquetFormat, dataSet: comma, expectErr: true},
+		{desc: "CSV files can hold any column", format: config.CSVFormat, dataSet: comma},
+	}
+
+	for _, tc := range testCases {
+		output := &config.FileOutput{Directory: dir, Format: tc.format, Period: config.DayPeriod}
+		err := newIngestor(output, schemaconfig.CreateIfMissing).Prepare(&idrf.Bundle{DataDef: tc.dataSet})
+		assert.Equal(t, tc.expectErr, err != nil, tc.desc)
+	}
+
+	output := &config.FileOutput{Directory: dir, Format: config.CSVFormat, Period: config.DayPeriod}
+	assert.Error(t, newIngestor(output, schemaconfig.CreateIfMissing).Start(nil), "prepare not called")
+}
+
+func TestFileIngestorRemovesFilesOnExternalError(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	output := &config.FileOutput{Directory: dir, Format: config.CSVFormat, Period: config.HourPeriod}
+	ingestor := newIngestor(output, schemaconfig.CreateIfMissing)
+	ingestor.Config.BatchSize = 2
+	dataChan := make(chan idrf.Row)
+	errChan := make(chan error, 1)
+	assert.NoError(t, ingestor.Prepare(&idrf.Bundle{DataDef: dataSet, DataChan: dataChan}))
+	go func() {
+		dataChan <- idrf.Row{firstDay, "a", 1.0, nil}
+		errChan <- errors.New("extraction failed")
+		dataChan <- idrf.Row{secondDay, "a", 1.0, nil}
+		close(dataChan)
+	}()
+
+	assert.NoError(t, ingestor.Start(errChan))
+	files, _ := filepath.Glob(filepath.Join(dir, "public", "cpu", "*"))
+	assert.Empty(t, files)
+}
+
+func TestFormatCSVValue(t *testing.T) {
+	testCases := []struct {
+		value    interface{}
+		expected string
+	}{
+		{nil, ""},
+		{"a", "a"},
+		{int32(1), "1"},
+		{int64(-2), "-2"},
+		{0.1, "0.1"},
+		{float32(0.1), "0.1"},
+		{true, "true"},
+		{[]byte(`{"a":1}`), `{"a":1}`},
+		{time.Date(2019, time.January, 1, 1, 0, 0, 0, time.FixedZone("UTC+1", 3600)), "2019-01-01T00:00:00Z"},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, formatCSVValue(tc.value))
+	}
+}
+
+func newIngestor(output *config.FileOutput, strategy schemaconfig.SchemaStrategy) *FileIngestor {
+	return &FileIngestor{Config: &config.IngestorConfig{
+		IngestorID:              "ing",
+		BatchSize:               10,
+		RollbackOnExternalError: true,
+		SchemaStrategy:          strategy,
+		Schema:                  "public",
+		FileOutput:              output,
+	}}
+}
+
+func bundle(rows []idrf.Row) *idrf.Bundle {
+	dataChan := make(chan idrf.Row, len(rows))
+	for _, row := range rows {
+		dataChan <- row
+	}
+
+	close(dataChan)
+	return &idrf.Bundle{DataDef: dataSet, DataChan: dataChan}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "outflux_files")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func readFile(t *testing.T, path string, gzipped bool) string {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if !gzipped {
+		content, _ := ioutil.ReadAll(file)
+		return string(content)
+	}
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, _ := ioutil.ReadAll(gzipReader)
+	return string(content)
+}
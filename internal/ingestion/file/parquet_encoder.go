@@ -0,0 +1,124 @@
+package file
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+const (
+	// the rows of a file are marshaled to column chunks by a single goroutine
+	parquetMarshalers = 1
+	// every column is optional, a point doesn't have all fields. The columns are identified by their
+	// position in the writer, so names that differ only by case don't collide
+	parquetColumnTemplate = "name=%s, inname=Column%d, %s, repetitiontype=OPTIONAL"
+)
+
+// parquetTypes maps the IDRF data types to the physical and logical types of the Parquet columns.
+// Times are kept with nanosecond precision, as in InfluxDB
+var parquetTypes = map[idrf.DataType]string{
+	idrf.IDRFBoolean:     "type=BOOLEAN",
+	idrf.IDRFInteger32:   "type=INT32",
+	idrf.IDRFInteger64:   "type=INT64",
+	idrf.IDRFSingle:      "type=FLOAT",
+	idrf.IDRFDouble:      "type=DOUBLE",
+	idrf.IDRFString:      "type=BYTE_ARRAY, convertedtype=UTF8",
+	idrf.IDRFJson:        "type=BYTE_ARRAY, convertedtype=JSON",
+	idrf.IDRFTimestamptz: "type=INT64, logicaltype=TIMESTAMP, logicaltype.isadjustedtoutc=true, logicaltype.unit=NANOS",
+	idrf.IDRFTimestamp:   "type=INT64, logicaltype=TIMESTAMP, logicaltype.isadjustedtoutc=false, logicaltype.unit=NANOS",
+}
+
+// parquetConverter converts a value of a row to the Go type of its Parquet column
+type parquetConverter func(value interface{}) interface{}
+
+// parquetEncoder writes the rows to a Parquet file with a column for each column of the data set
+type parquetEncoder struct {
+	file       *os.File
+	writer     *writer.CSVWriter
+	converters []parquetConverter
+}
+
+func newParquetEncoder(path string, columns []*idrf.Column) (*parquetEncoder, error) {
+	schema, converters, err := parquetSchema(columns)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	parquetWriter, err := writer.NewCSVWriterFromWriter(schema, file, parquetMarshalers)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &parquetEncoder{file: file, writer: parquetWriter, converters: converters}, nil
+}
+
+func (e *parquetEncoder) encode(row idrf.Row) error {
+	record := make([]interface{}, len(row))
+	for i, value := range row {
+		if value != nil {
+			record[i] = e.converters[i](value)
+		}
+	}
+
+	return e.writer.Write(record)
+}
+
+func (e *parquetEncoder) close() error {
+	err := e.writer.WriteStop()
+	if closeErr := e.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// parquetSchema returns the definitions of the Parquet columns of a data set, and the converters
+// of their values. Returns an error if a column has a type or a name that can't be written
+func parquetSchema(columns []*idrf.Column) ([]string, []parquetConverter, error) {
+	schema := make([]string, len(columns))
+	converters := make([]parquetConverter, len(columns))
+	for i, column := range columns {
+		parquetType, ok := parquetTypes[column.DataType]
+		if !ok {
+			return nil, nil, fmt.Errorf("column '%s' of type %s can't be written to Parquet", column.Name, column.DataType)
+		}
+
+		if strings.Contains(column.Name, ",") {
+			return nil, nil, fmt.Errorf("column '%s' can't be written to Parquet, its name contains ','", column.Name)
+		}
+
+		schema[i] = fmt.Sprintf(parquetColumnTemplate, column.Name, i, parquetType)
+		switch column.DataType {
+		case idrf.IDRFTimestamptz, idrf.IDRFTimestamp:
+			converters[i] = timeToNanos
+		case idrf.IDRFJson:
+			converters[i] = bytesToString
+		default:
+			converters[i] = sameValue
+		}
+	}
+
+	return schema, converters, nil
+}
+
+func timeToNanos(value interface{}) interface{} {
+	return value.(time.Time).UnixNano()
+}
+
+func bytesToString(value interface{}) interface{} {
+	return string(value.([]byte))
+}
+
+func sameValue(value interface{}) interface{} {
+	return value
+}
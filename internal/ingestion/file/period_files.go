@@ -0,0 +1,108 @@
+package file
+
+import (
+	"container/list"
+	"fmt"
+	"time"
+)
+
+// periodFiles keeps the encoders of the files of the periods being written, at most ${limit} of them open
+// at once. When a file of another period must be opened, the least recently written one is closed.
+// If more rows of a closed period arrive, its file is reopened: CSV files are appended to, and since
+// Parquet files can't be appended to, the rows of the period continue in a new part file
+type periodFiles struct {
+	limit int
+	// newEncoder opens the file of a period, appending to it if it was written before
+	newEncoder func(path string, appending bool) (rowEncoder, error)
+	// path returns the path of a part of the file of the period starting at a time
+	path  func(start time.Time, part int) string
+	parts bool
+	files map[int64]*periodFile
+	// the open files, the most recently written in front
+	open *list.List
+	// paths of all the files written
+	paths []string
+}
+
+// periodFile is the file of a period, its encoder is nil while it is closed
+type periodFile struct {
+	start   time.Time
+	part    int
+	path    string
+	encoder rowEncoder
+	element *list.Element
+}
+
+func newPeriodFiles(limit int, parts bool, path func(time.Time, int) string, newEncoder func(string, bool) (rowEncoder, error)) *periodFiles {
+	return &periodFiles{
+		limit:      limit,
+		newEncoder: newEncoder,
+		path:       path,
+		parts:      parts,
+		files:      make(map[int64]*periodFile),
+		open:       list.New(),
+	}
+}
+
+// encoder returns the encoder of the file of the period starting at a time, opening the file if needed
+func (f *periodFiles) encoder(start time.Time) (rowEncoder, error) {
+	file, seen := f.files[start.UnixNano()]
+	if seen && file.encoder != nil {
+		f.open.MoveToFront(file.element)
+		return file.encoder, nil
+	}
+
+	if f.open.Len() >= f.limit {
+		if err := f.closeFile(f.open.Back().Value.(*periodFile)); err != nil {
+			return nil, err
+		}
+	}
+
+	appending := seen
+	if !seen {
+		file = &periodFile{start: start}
+		f.files[start.UnixNano()] = file
+	} else if f.parts {
+		file.part++
+		appending = false
+	}
+
+	if !appending {
+		file.path = f.path(start, file.part)
+		f.paths = append(f.paths, file.path)
+	}
+
+	encoder, err := f.newEncoder(file.path, appending)
+	if err != nil {
+		return nil, fmt.Errorf("could not open file '%s'\n%v", file.path, err)
+	}
+
+	file.encoder = encoder
+	file.element = f.open.PushFront(file)
+	return encoder, nil
+}
+
+// closeFile flushes the written rows of a file and closes it
+func (f *periodFiles) closeFile(file *periodFile) error {
+	f.open.Remove(file.element)
+	err := file.encoder.close()
+	file.encoder = nil
+	file.element = nil
+	if err != nil {
+		return fmt.Errorf("could not write file '%s'\n%v", file.path, err)
+	}
+
+	return nil
+}
+
+// closeAll closes the open files and returns the first error
+func (f *periodFiles) closeAll() error {
+	var firstErr error
+	for f.open.Len() > 0 {
+		if err := f.closeFile(f.open.Front().Value.(*periodFile)); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
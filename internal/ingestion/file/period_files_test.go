@@ -0,0 +1,79 @@
+package file
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/idrf"
+)
+
+type mockEncoder struct {
+	path   string
+	closed *[]string
+}
+
+func (m *mockEncoder) encode(row idrf.Row) error { return nil }
+func (m *mockEncoder) close() error {
+	*m.closed = append(*m.closed, m.path)
+	return nil
+}
+
+func TestPeriodFilesClosesLeastRecentlyWritten(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		parts  bool
+		hours  []int
+		opened []string
+		closed []string
+		paths  []string
+	}{
+		{
+			desc:   "open files are reused",
+			hours:  []int{0, 1, 0, 1},
+			opened: []string{"0.0", "1.0"},
+			closed: []string{"1.0", "0.0"},
+			paths:  []string{"0.0", "1.0"},
+		}, {
+			desc:   "the least recently written file is closed and appended to when reopened",
+			hours:  []int{0, 1, 0, 2, 1},
+			opened: []string{"0.0", "1.0", "2.0", "+1.0"},
+			closed: []string{"1.0", "0.0", "1.0", "2.0"},
+			paths:  []string{"0.0", "1.0", "2.0"},
+		}, {
+			desc:   "a closed file continues in a new part",
+			parts:  true,
+			hours:  []int{0, 1, 2, 0},
+			opened: []string{"0.0", "1.0", "2.0", "0.1"},
+			closed: []string{"0.0", "1.0", "0.1", "2.0"},
+			paths:  []string{"0.0", "1.0", "2.0", "0.1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		opened := []string{}
+		closed := []string{}
+		path := func(start time.Time, part int) string { return fmt.Sprintf("%d.%d", start.Hour(), part) }
+		files := newPeriodFiles(2, tc.parts, path, func(path string, appending bool) (rowEncoder, error) {
+			if appending {
+				opened = append(opened, "+"+path)
+			} else {
+				opened = append(opened, path)
+			}
+
+			return &mockEncoder{path: path, closed: &closed}, nil
+		})
+
+		for _, hour := range tc.hours {
+			_, err := files.encoder(time.Date(2019, time.January, 1, hour, 0, 0, 0, time.UTC))
+			assert.NoError(t, err, tc.desc)
+			assert.True(t, files.open.Len() <= 2, tc.desc)
+		}
+
+		assert.NoError(t, files.closeAll(), tc.desc)
+		assert.Equal(t, tc.opened, opened, tc.desc)
+		assert.Equal(t, tc.closed, closed, tc.desc)
+		assert.Equal(t, tc.paths, files.paths, tc.desc)
+	}
+}
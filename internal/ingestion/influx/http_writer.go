@@ -0,0 +1,147 @@
+package influx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/timescale/outflux/internal/ingestion/config"
+)
+
+const (
+	writePath               = "/write"
+	queryPath               = "/query"
+	lineProtocolContentType = "text/plain; charset=utf-8"
+	formContentType         = "application/x-www-form-urlencoded"
+	dropMeasurementTemplate = `DROP MEASUREMENT "%s"`
+	// the error messages of InfluxDB are cut to this many bytes
+	maxErrorBodyLength = 1024
+)
+
+// httpWriter sends the requests of an ingestor to the HTTP API of an InfluxDB server. A request that fails
+// with a network error, or a server error that may go away, is sent again after a wait that doubles
+// with each retry. Client errors, like a point InfluxDB can't parse, are not retried. Points written again
+// overwrite the points with the same measurement, tags and timestamp, so a retried batch has no duplicates
+type httpWriter struct {
+	client *http.Client
+	output *config.InfluxOutput
+	// sleep waits before a retry
+	sleep func(time.Duration)
+}
+
+func newHTTPWriter(client *http.Client, output *config.InfluxOutput) *httpWriter {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &httpWriter{client: client, output: output, sleep: time.Sleep}
+}
+
+// write sends the lines of a batch of points to the /write endpoint, with the precision of their timestamps
+func (w *httpWriter) write(lines []byte) error {
+	params := url.Values{}
+	params.Set("db", w.output.Database)
+	if w.output.RetentionPolicy != "" {
+		params.Set("rp", w.output.RetentionPolicy)
+	}
+
+	params.Set("precision", w.output.Precision.String())
+	_, err := w.send(writePath, params, lines, lineProtocolContentType)
+	return err
+}
+
+// dropMeasurement drops the points of a measurement from the database
+func (w *httpWriter) dropMeasurement(measurement string) error {
+	params := url.Values{}
+	params.Set("db", w.output.Database)
+	params.Set("q", fmt.Sprintf(dropMeasurementTemplate, strings.Replace(measurement, `"`, `\"`, -1)))
+	body, err := w.send(queryPath, nil, []byte(params.Encode()), formContentType)
+	if err != nil {
+		return err
+	}
+
+	// the errors of the statements are in the results of a successful response
+	response := struct {
+		Results []struct {
+			Error string `json:"error"`
+		} `json:"results"`
+	}{}
+	if err = json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("could not parse the response of InfluxDB\n%v", err)
+	}
+
+	for _, result := range response.Results {
+		if result.Error != "" {
+			return fmt.Errorf("%s", result.Error)
+		}
+	}
+
+	return nil
+}
+
+// send posts the body to an endpoint of the server and returns the body of the response, retrying the
+// request if it may succeed later
+func (w *httpWriter) send(path string, params url.Values, body []byte, contentType string) ([]byte, error) {
+	endpoint := strings.TrimSuffix(w.output.Server, "/") + path
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+
+	wait := w.output.RetryInterval
+	for attempt := uint(0); ; attempt++ {
+		responseBody, retry, err := w.post(endpoint, body, contentType)
+		if err == nil {
+			return responseBody, nil
+		}
+
+		if !retry || attempt >= w.output.Retries {
+			return nil, err
+		}
+
+		log.Printf("Request to '%s' failed, retrying in %s (%d of %d)\n%v", path, wait, attempt+1, w.output.Retries, err)
+		w.sleep(wait)
+		wait *= 2
+	}
+}
+
+// post sends a single request, and returns whether it should be retried when it fails
+func (w *httpWriter) post(endpoint string, body []byte, contentType string) ([]byte, bool, error) {
+	request, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, err
+	}
+
+	request.Header.Set("Content-Type", contentType)
+	if w.output.Username != "" {
+		request.SetBasicAuth(w.output.Username, w.output.Password)
+	}
+
+	response, err := w.client.Do(request)
+	if err != nil {
+		return nil, true, err
+	}
+
+	defer response.Body.Close()
+	responseBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, true, err
+	}
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return responseBody, false, nil
+	}
+
+	message := strings.TrimSpace(string(responseBody))
+	if len(message) > maxErrorBodyLength {
+		message = message[:maxErrorBodyLength]
+	}
+
+	retry := response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests
+	return nil, retry, fmt.Errorf("InfluxDB responded with status %s: %s", response.Status, message)
+}
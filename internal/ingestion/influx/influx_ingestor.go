@@ -0,0 +1,131 @@
+package influx
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/ingestion/config"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+	"github.com/timescale/outflux/internal/utils"
+)
+
+// InfluxIngestor implements an ingestor that writes the rows of a data set back to InfluxDB, as points of
+// a measurement named after the data set. The point of a row is rebuilt from the roles of its columns
+// described in the InfluxOutput of the config, and the points are written in batches of ${batchSize}.
+// InfluxDB has no transactions, the points of the batches already written are kept when an error occurs
+type InfluxIngestor struct {
+	Config *config.IngestorConfig
+	// Client sends the requests to InfluxDB, http.DefaultClient if nil
+	Client       *http.Client
+	cachedBundle *idrf.Bundle
+	encoder      *pointEncoder
+	writer       *httpWriter
+}
+
+// ID returns a string identifying the ingestor instance in logs
+func (i *InfluxIngestor) ID() string {
+	return i.Config.IngestorID
+}
+
+// Prepare assigns the role of each column of the data set in the points. With the drop schema
+// strategies the measurement is dropped from the output database
+func (i *InfluxIngestor) Prepare(bundle *idrf.Bundle) error {
+	i.cachedBundle = bundle
+	output := i.Config.InfluxOutput
+	encoder, err := newPointEncoder(bundle.DataDef, output)
+	if err != nil {
+		return fmt.Errorf("%s: %v", i.Config.IngestorID, err)
+	}
+
+	i.encoder = encoder
+	i.writer = newHTTPWriter(i.Client, output)
+	switch i.Config.SchemaStrategy {
+	case schemaconfig.DropAndCreate, schemaconfig.DropCascadeAndCreate:
+		measurement := bundle.DataDef.DataSetName
+		log.Printf("%s: dropping measurement '%s' from database '%s'", i.Config.IngestorID, measurement, output.Database)
+		if err = i.writer.dropMeasurement(measurement); err != nil {
+			return fmt.Errorf("%s: could not drop measurement '%s'\n%v", i.Config.IngestorID, measurement, err)
+		}
+	}
+
+	return nil
+}
+
+// Start consumes a data channel of idrf.Row(s), and writes their points to InfluxDB each ${batchSize} rows.
+// Before each batch is written the ingestor checks for errors in the other goroutines, and stops writing
+// if it should roll back
+func (i *InfluxIngestor) Start(errChan chan error) error {
+	if i.cachedBundle == nil {
+		return fmt.Errorf("%s: Start called without calling Prepare first", i.Config.IngestorID)
+	}
+
+	id := i.Config.IngestorID
+	if utils.CheckError(errChan) != nil {
+		log.Printf("%s: received external error before starting data insertion. Quitting\n", id)
+		return nil
+	}
+
+	output := i.Config.InfluxOutput
+	log.Printf("Starting Influx ingestor '%s', writing measurement '%s' to database '%s' of '%s'", id, i.cachedBundle.DataDef.DataSetName, output.Database, output.Server)
+	batch := &bytes.Buffer{}
+	numPoints := uint(0)
+	skipped := uint(0)
+	batchRows := uint16(0)
+	for row := range i.cachedBundle.DataChan {
+		written, err := i.encoder.encode(batch, row)
+		if err != nil {
+			return fmt.Errorf("%s: could not rebuild point\n%v", id, err)
+		}
+
+		if !written {
+			skipped++
+			continue
+		}
+
+		batchRows++
+		if batchRows < i.Config.BatchSize {
+			continue
+		}
+
+		if stopped, err := i.writeBatch(batch, errChan); stopped || err != nil {
+			return err
+		}
+
+		numPoints += uint(batchRows)
+		batchRows = 0
+		batch.Reset()
+	}
+
+	if batchRows > 0 {
+		if stopped, err := i.writeBatch(batch, errChan); stopped || err != nil {
+			return err
+		}
+
+		numPoints += uint(batchRows)
+	}
+
+	if skipped > 0 {
+		log.Printf("%s: %d rows without fields were not written, a point must have a field", id, skipped)
+	}
+
+	log.Printf("%s: Complete. Wrote %d points.\n", id, numPoints)
+	return nil
+}
+
+// writeBatch writes the points of a batch, unless an error was received from outside of the ingestor
+// and it should roll back. Returns true if it stopped writing
+func (i *InfluxIngestor) writeBatch(batch *bytes.Buffer, errChan chan error) (bool, error) {
+	if i.Config.RollbackOnExternalError && utils.CheckError(errChan) != nil {
+		log.Printf("%s: Error received from outside of ingestor. Stopped writing, the points already written are kept\n", i.Config.IngestorID)
+		return true, nil
+	}
+
+	if err := i.writer.write(batch.Bytes()); err != nil {
+		return false, fmt.Errorf("%s: could not write batch of points\n%v", i.Config.IngestorID, err)
+	}
+
+	return false, nil
+}
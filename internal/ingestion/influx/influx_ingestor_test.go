@@ -0,0 +1,210 @@
+package influx
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/ingestion/config"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+)
+
+var dataSet = &idrf.DataSet{
+	DataSetName: "cpu",
+	TimeColumn:  "time",
+	Columns: []*idrf.Column{
+		{Name: "time", DataType: idrf.IDRFTimestamptz},
+		{Name: "host", DataType: idrf.IDRFString},
+		{Name: "usage", DataType: idrf.IDRFDouble},
+	},
+}
+
+// influxStandIn records the requests sent to the HTTP API of InfluxDB, and responds with the statuses
+// queued for the /write endpoint, 204 once they are used up
+type influxStandIn struct {
+	lock     sync.Mutex
+	statuses []int
+	requests []*recordedRequest
+}
+
+type recordedRequest struct {
+	path  string
+	query url.Values
+	user  string
+	body  string
+}
+
+func (s *influxStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	body, _ := ioutil.ReadAll(r.Body)
+	query := r.URL.Query()
+	if r.URL.Path == queryPath {
+		query, _ = url.ParseQuery(string(body))
+	}
+
+	user, _, _ := r.BasicAuth()
+	s.requests = append(s.requests, &recordedRequest{path: r.URL.Path, query: query, user: user, body: string(body)})
+	if r.URL.Path == queryPath {
+		w.Write([]byte(`{"results":[{"statement_id":0}]}`))
+		return
+	}
+
+	status := http.StatusNoContent
+	if len(s.statuses) > 0 {
+		status, s.statuses = s.statuses[0], s.statuses[1:]
+	}
+
+	w.WriteHeader(status)
+	if status != http.StatusNoContent {
+		w.Write([]byte(`{"error":"failed"}`))
+	}
+}
+
+func TestInfluxIngestorWritesBatches(t *testing.T) {
+	standIn := &influxStandIn{}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	ingestor := newIngestor(server.URL, schemaconfig.CreateIfMissing)
+	ingestor.Config.InfluxOutput.Username = "admin"
+	ingestor.Config.InfluxOutput.RetentionPolicy = "autogen"
+	rows := []idrf.Row{
+		{time.Unix(1, 0), "a", 1.5},
+		{time.Unix(2, 0), "b", nil},
+		{time.Unix(3, 0), nil, 2.5},
+		{time.Unix(4, 0), "a", 3.5},
+	}
+	assert.NoError(t, ingestor.Prepare(bundle(rows)))
+	assert.NoError(t, ingestor.Start(make(chan error, 1)))
+
+	// the row without fields is skipped
+	assert.Equal(t, 2, len(standIn.requests))
+	assert.Equal(t, "cpu,host=a usage=1.5 1000\ncpu usage=2.5 3000\n", standIn.requests[0].body)
+	assert.Equal(t, "cpu,host=a usage=3.5 4000\n", standIn.requests[1].body)
+	for _, request := range standIn.requests {
+		assert.Equal(t, writePath, request.path)
+		assert.Equal(t, url.Values{"db": {"copy"}, "rp": {"autogen"}, "precision": {"ms"}}, request.query)
+		assert.Equal(t, "admin", request.user)
+	}
+}
+
+func TestInfluxIngestorDropsMeasurement(t *testing.T) {
+	standIn := &influxStandIn{}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	ingestor := newIngestor(server.URL+"/", schemaconfig.DropAndCreate)
+	assert.NoError(t, ingestor.Prepare(bundle([]idrf.Row{{time.Unix(1, 0), "a", 1.5}})))
+	assert.NoError(t, ingestor.Start(make(chan error, 1)))
+
+	assert.Equal(t, 2, len(standIn.requests))
+	assert.Equal(t, queryPath, standIn.requests[0].path)
+	assert.Equal(t, url.Values{"db": {"copy"}, "q": {`DROP MEASUREMENT "cpu"`}}, standIn.requests[0].query)
+	assert.Equal(t, writePath, standIn.requests[1].path)
+}
+
+func TestInfluxIngestorRetries(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		statuses []int
+		retries  uint
+		requests int
+		err      bool
+	}{
+		{desc: "server errors are retried", statuses: []int{500, 503}, retries: 2, requests: 3},
+		{desc: "too many requests are retried", statuses: []int{429}, retries: 1, requests: 2},
+		{desc: "retries run out", statuses: []int{500, 500, 500}, retries: 2, requests: 3, err: true},
+		{desc: "client errors are not retried", statuses: []int{400}, retries: 2, requests: 1, err: true},
+	}
+
+	for _, tc := range testCases {
+		standIn := &influxStandIn{statuses: tc.statuses}
+		server := httptest.NewServer(standIn)
+		ingestor := newIngestor(server.URL, schemaconfig.CreateIfMissing)
+		ingestor.Config.InfluxOutput.Retries = tc.retries
+		assert.NoError(t, ingestor.Prepare(bundle([]idrf.Row{{time.Unix(1, 0), "a", 1.5}})))
+		waits := []time.Duration{}
+		ingestor.writer.sleep = func(wait time.Duration) { waits = append(waits, wait) }
+
+		err := ingestor.Start(make(chan error, 1))
+		server.Close()
+		assert.Equal(t, tc.err, err != nil, tc.desc)
+		assert.Equal(t, tc.requests, len(standIn.requests), tc.desc)
+		if tc.requests == 3 {
+			assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, waits, tc.desc)
+		}
+	}
+}
+
+func TestInfluxIngestorRetriesNetworkErrors(t *testing.T) {
+	server := httptest.NewServer(&influxStandIn{})
+	server.Close()
+
+	ingestor := newIngestor(server.URL, schemaconfig.CreateIfMissing)
+	ingestor.Config.InfluxOutput.Retries = 1
+	assert.NoError(t, ingestor.Prepare(bundle([]idrf.Row{{time.Unix(1, 0), "a", 1.5}})))
+	waits := 0
+	ingestor.writer.sleep = func(time.Duration) { waits++ }
+	assert.Error(t, ingestor.Start(make(chan error, 1)))
+	assert.Equal(t, 1, waits)
+}
+
+func TestInfluxIngestorStopsOnExternalError(t *testing.T) {
+	standIn := &influxStandIn{}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	ingestor := newIngestor(server.URL, schemaconfig.CreateIfMissing)
+	dataChan := make(chan idrf.Row)
+	errChan := make(chan error, 1)
+	assert.NoError(t, ingestor.Prepare(&idrf.Bundle{DataDef: dataSet, DataChan: dataChan}))
+	go func() {
+		dataChan <- idrf.Row{time.Unix(1, 0), "a", 1.0}
+		dataChan <- idrf.Row{time.Unix(2, 0), "a", 1.0}
+		dataChan <- idrf.Row{time.Unix(3, 0), "a", 1.0}
+		errChan <- errors.New("extraction failed")
+		dataChan <- idrf.Row{time.Unix(4, 0), "a", 1.0}
+		close(dataChan)
+	}()
+
+	assert.NoError(t, ingestor.Start(errChan))
+	assert.Equal(t, 1, len(standIn.requests))
+}
+
+func TestInfluxIngestorStartWithoutPrepare(t *testing.T) {
+	assert.Error(t, newIngestor("http://localhost:8086", schemaconfig.CreateIfMissing).Start(nil))
+}
+
+func newIngestor(server string, strategy schemaconfig.SchemaStrategy) *InfluxIngestor {
+	return &InfluxIngestor{Config: &config.IngestorConfig{
+		IngestorID:              "ing",
+		BatchSize:               2,
+		RollbackOnExternalError: true,
+		SchemaStrategy:          strategy,
+		InfluxOutput: &config.InfluxOutput{
+			Server:        server,
+			Database:      "copy",
+			Precision:     config.MillisecondPrecision,
+			RetryInterval: time.Second,
+			TagColumns:    []string{"host"},
+		},
+	}}
+}
+
+func bundle(rows []idrf.Row) *idrf.Bundle {
+	dataChan := make(chan idrf.Row, len(rows))
+	for _, row := range rows {
+		dataChan <- row
+	}
+
+	close(dataChan)
+	return &idrf.Bundle{DataDef: dataSet, DataChan: dataChan}
+}
@@ -0,0 +1,266 @@
+package influx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/ingestion/config"
+)
+
+// the special characters of the names and tag values of line protocol are escaped with a backslash,
+// string field values are quoted
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	keyEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	stringEscaper      = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+// columnRole describes what a column of a row becomes in the point rebuilt from it
+type columnRole int
+
+const (
+	timeRole columnRole = iota + 1
+	tagRole
+	fieldRole
+	jsonTagsRole
+	jsonFieldsRole
+)
+
+// keyValue is a tag or a field of a point, with the key escaped and the value formatted
+type keyValue struct {
+	key   string
+	value string
+}
+
+// pointEncoder rebuilds the points of a data set from its rows, and writes them as lines of line protocol
+type pointEncoder struct {
+	measurement string
+	columns     []*idrf.Column
+	roles       []columnRole
+	precision   config.Precision
+	tags        []keyValue
+	fields      []keyValue
+}
+
+// newPointEncoder assigns a role to each column of the data set. Returns an error if the data set
+// has no time column, or a column of a type that can't be written to InfluxDB
+func newPointEncoder(dataSet *idrf.DataSet, output *config.InfluxOutput) (*pointEncoder, error) {
+	tagColumns := make(map[string]bool, len(output.TagColumns))
+	for _, tag := range output.TagColumns {
+		tagColumns[tag] = true
+	}
+
+	encoder := &pointEncoder{
+		measurement: measurementEscaper.Replace(dataSet.DataSetName),
+		columns:     dataSet.Columns,
+		roles:       make([]columnRole, len(dataSet.Columns)),
+		precision:   output.Precision,
+	}
+
+	hasTime := false
+	for i, column := range dataSet.Columns {
+		switch {
+		case column.Name == dataSet.TimeColumn:
+			if column.DataType != idrf.IDRFTimestamptz && column.DataType != idrf.IDRFTimestamp && column.DataType != idrf.IDRFInteger64 {
+				return nil, fmt.Errorf("time column '%s' of type %s can't be the timestamp of a point", column.Name, column.DataType)
+			}
+
+			encoder.roles[i] = timeRole
+			hasTime = true
+		case column.DataType == idrf.IDRFUnknown:
+			return nil, fmt.Errorf("column '%s' of type %s can't be written to InfluxDB", column.Name, column.DataType)
+		case tagColumns[column.Name]:
+			encoder.roles[i] = tagRole
+		case column.Name == output.JSONTagsColumn && column.DataType == idrf.IDRFJson:
+			encoder.roles[i] = jsonTagsRole
+		case column.Name == output.JSONFieldsColumn && column.DataType == idrf.IDRFJson:
+			encoder.roles[i] = jsonFieldsRole
+		default:
+			encoder.roles[i] = fieldRole
+		}
+	}
+
+	if !hasTime {
+		return nil, fmt.Errorf("data set '%s' has no time column '%s'", dataSet.DataSetName, dataSet.TimeColumn)
+	}
+
+	return encoder, nil
+}
+
+// encode writes the point of a row as a line to the buffer. Null values are left out of the point,
+// and a point without fields is not written since InfluxDB rejects it. Returns false if it was not written
+func (e *pointEncoder) encode(buf *bytes.Buffer, row idrf.Row) (bool, error) {
+	e.tags = e.tags[:0]
+	e.fields = e.fields[:0]
+	var timestamp int64
+	for i, value := range row {
+		var err error
+		switch e.roles[i] {
+		case timeRole:
+			timestamp, err = e.timestamp(value)
+		case tagRole:
+			e.tags = appendTag(e.tags, e.columns[i].Name, value)
+		case fieldRole:
+			e.fields = appendField(e.fields, e.columns[i].Name, value)
+		case jsonTagsRole:
+			err = forEachKey(value, func(key string, value interface{}) { e.tags = appendTag(e.tags, key, value) })
+		case jsonFieldsRole:
+			err = forEachKey(value, func(key string, value interface{}) { e.fields = appendField(e.fields, key, value) })
+		}
+
+		if err != nil {
+			return false, fmt.Errorf("could not write column '%s'\n%v", e.columns[i].Name, err)
+		}
+	}
+
+	if len(e.fields) == 0 {
+		return false, nil
+	}
+
+	// InfluxDB prefers the tags sorted by key
+	sort.Slice(e.tags, func(i, j int) bool { return e.tags[i].key < e.tags[j].key })
+	buf.WriteString(e.measurement)
+	for _, tag := range e.tags {
+		buf.WriteByte(',')
+		buf.WriteString(tag.key)
+		buf.WriteByte('=')
+		buf.WriteString(tag.value)
+	}
+
+	for i, field := range e.fields {
+		if i == 0 {
+			buf.WriteByte(' ')
+		} else {
+			buf.WriteByte(',')
+		}
+
+		buf.WriteString(field.key)
+		buf.WriteByte('=')
+		buf.WriteString(field.value)
+	}
+
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.FormatInt(timestamp, 10))
+	buf.WriteByte('\n')
+	return true, nil
+}
+
+// timestamp returns the timestamp of the point in units of the precision. The time is a time
+// or the nanoseconds since the Unix epoch
+func (e *pointEncoder) timestamp(value interface{}) (int64, error) {
+	switch value := value.(type) {
+	case time.Time:
+		return e.precision.Timestamp(value), nil
+	case int64:
+		return e.precision.Timestamp(time.Unix(0, value)), nil
+	default:
+		return 0, fmt.Errorf("value %v of the time column is not a time", value)
+	}
+}
+
+// forEachKey calls the function with each key of a JSON object and its value, in the order of the keys
+func forEachKey(value interface{}, call func(key string, value interface{})) error {
+	if value == nil {
+		return nil
+	}
+
+	object := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(value.([]byte)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&object); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		call(key, object[key])
+	}
+
+	return nil
+}
+
+// appendTag appends a tag with the value formatted as a string. Null and empty values are left
+// out, InfluxDB has no empty tags
+func appendTag(tags []keyValue, key string, value interface{}) []keyValue {
+	var formatted string
+	switch value := value.(type) {
+	case nil:
+		return tags
+	case string:
+		formatted = value
+	case []byte:
+		formatted = string(value)
+	case map[string]interface{}, []interface{}:
+		nested, _ := json.Marshal(value)
+		formatted = string(nested)
+	default:
+		formatted = fmt.Sprint(value)
+	}
+
+	if formatted == "" {
+		return tags
+	}
+
+	return append(tags, keyValue{key: keyEscaper.Replace(key), value: keyEscaper.Replace(formatted)})
+}
+
+// appendField appends a field with the value formatted for its type. Integers get the 'i' suffix, the
+// numbers of JSON objects are written as floats since JSON doesn't tell them apart from integers. Values
+// without a field type of InfluxDB are written as strings. Null values and floats that are not a number
+// or infinite are left out, InfluxDB can't store them
+func appendField(fields []keyValue, key string, value interface{}) []keyValue {
+	var formatted string
+	switch value := value.(type) {
+	case nil:
+		return fields
+	case float64:
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return fields
+		}
+
+		formatted = strconv.FormatFloat(value, 'g', -1, 64)
+	case float32:
+		if math.IsNaN(float64(value)) || math.IsInf(float64(value), 0) {
+			return fields
+		}
+
+		formatted = strconv.FormatFloat(float64(value), 'g', -1, 32)
+	case int64:
+		formatted = strconv.FormatInt(value, 10) + "i"
+	case int32:
+		formatted = strconv.FormatInt(int64(value), 10) + "i"
+	case bool:
+		formatted = strconv.FormatBool(value)
+	case json.Number:
+		formatted = value.String()
+	case string:
+		formatted = quote(value)
+	case []byte:
+		formatted = quote(string(value))
+	case time.Time:
+		formatted = quote(value.UTC().Format(time.RFC3339Nano))
+	case map[string]interface{}, []interface{}:
+		nested, _ := json.Marshal(value)
+		formatted = quote(string(nested))
+	default:
+		formatted = quote(fmt.Sprint(value))
+	}
+
+	return append(fields, keyValue{key: keyEscaper.Replace(key), value: formatted})
+}
+
+func quote(value string) string {
+	return `"` + stringEscaper.Replace(value) + `"`
+}
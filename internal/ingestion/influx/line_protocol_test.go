@@ -0,0 +1,134 @@
+package influx
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/ingestion/config"
+)
+
+func TestPointEncoderRoles(t *testing.T) {
+	dataSet := &idrf.DataSet{
+		DataSetName: "cpu load",
+		TimeColumn:  "time",
+		Columns: []*idrf.Column{
+			{Name: "time", DataType: idrf.IDRFTimestamptz},
+			{Name: "region", DataType: idrf.IDRFString},
+			{Name: "host", DataType: idrf.IDRFString},
+			{Name: "usage", DataType: idrf.IDRFDouble},
+			{Name: "count", DataType: idrf.IDRFInteger64},
+			{Name: "up", DataType: idrf.IDRFBoolean},
+			{Name: "note", DataType: idrf.IDRFString},
+		},
+	}
+	output := &config.InfluxOutput{Precision: config.MillisecondPrecision, TagColumns: []string{"region", "host", "missing"}}
+	encoder, err := newPointEncoder(dataSet, output)
+	assert.NoError(t, err)
+	assert.Equal(t, []columnRole{timeRole, tagRole, tagRole, fieldRole, fieldRole, fieldRole, fieldRole}, encoder.roles)
+
+	at := time.Date(2019, time.January, 1, 0, 0, 0, 1500000, time.UTC)
+	testCases := []struct {
+		row      idrf.Row
+		expected string
+		written  bool
+	}{
+		{
+			row:      idrf.Row{at, "eu,west", "a b", 1.5, int64(2), true, `say "hi" \o/`},
+			expected: `cpu\ load,host=a\ b,region=eu\,west usage=1.5,count=2i,up=true,note="say \"hi\" \\o/" 1546300800001` + "\n",
+			written:  true,
+		}, {
+			row:      idrf.Row{at, "", nil, nil, int64(-3), nil, nil},
+			expected: "cpu\\ load count=-3i 1546300800001\n",
+			written:  true,
+		}, {
+			row:     idrf.Row{at, "eu", "a", nil, nil, nil, nil},
+			written: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		buf := &bytes.Buffer{}
+		written, err := encoder.encode(buf, tc.row)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.written, written)
+		assert.Equal(t, tc.expected, buf.String())
+	}
+
+	_, err = encoder.encode(&bytes.Buffer{}, idrf.Row{"yesterday", "eu", "a", 1.0, nil, nil, nil})
+	assert.Error(t, err)
+}
+
+func TestPointEncoderJSONColumns(t *testing.T) {
+	dataSet := &idrf.DataSet{
+		DataSetName: "cpu",
+		TimeColumn:  "time",
+		Columns: []*idrf.Column{
+			{Name: "time", DataType: idrf.IDRFTimestamptz},
+			{Name: "tags", DataType: idrf.IDRFJson},
+			{Name: "rp", DataType: idrf.IDRFString},
+			{Name: "fields", DataType: idrf.IDRFJson},
+		},
+	}
+	output := &config.InfluxOutput{Precision: config.SecondPrecision, TagColumns: []string{"rp"}, JSONTagsColumn: "tags", JSONFieldsColumn: "fields"}
+	encoder, err := newPointEncoder(dataSet, output)
+	assert.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	row := idrf.Row{time.Unix(10, 0), []byte(`{"region":"eu","host":"a=b"}`), "autogen", []byte(`{"usage":2,"state":"ok","nested":{"a":1},"empty":null}`)}
+	written, err := encoder.encode(buf, row)
+	assert.NoError(t, err)
+	assert.True(t, written)
+	assert.Equal(t, `cpu,host=a\=b,region=eu,rp=autogen nested="{\"a\":1}",state="ok",usage=2 10`+"\n", buf.String())
+
+	_, err = encoder.encode(&bytes.Buffer{}, idrf.Row{time.Unix(10, 0), []byte(`not json`), nil, nil})
+	assert.Error(t, err)
+}
+
+func TestNewPointEncoderErrors(t *testing.T) {
+	output := &config.InfluxOutput{Precision: config.NanosecondPrecision}
+	noTime := &idrf.DataSet{DataSetName: "cpu", TimeColumn: "time", Columns: []*idrf.Column{{Name: "usage", DataType: idrf.IDRFDouble}}}
+	_, err := newPointEncoder(noTime, output)
+	assert.Error(t, err)
+
+	stringTime := &idrf.DataSet{DataSetName: "cpu", TimeColumn: "time", Columns: []*idrf.Column{{Name: "time", DataType: idrf.IDRFString}}}
+	_, err = newPointEncoder(stringTime, output)
+	assert.Error(t, err)
+
+	unknown := &idrf.DataSet{DataSetName: "cpu", TimeColumn: "time", Columns: []*idrf.Column{
+		{Name: "time", DataType: idrf.IDRFTimestamptz},
+		{Name: "usage", DataType: idrf.IDRFUnknown},
+	}}
+	_, err = newPointEncoder(unknown, output)
+	assert.Error(t, err)
+
+	epoch := &idrf.DataSet{DataSetName: "cpu", TimeColumn: "time", Columns: []*idrf.Column{{Name: "time", DataType: idrf.IDRFInteger64}}}
+	_, err = newPointEncoder(epoch, output)
+	assert.NoError(t, err)
+}
+
+func TestAppendField(t *testing.T) {
+	at := time.Date(2019, time.January, 1, 1, 0, 0, 0, time.FixedZone("UTC+1", 3600))
+	testCases := []struct {
+		value    interface{}
+		expected []keyValue
+	}{
+		{nil, []keyValue{}},
+		{math.NaN(), []keyValue{}},
+		{math.Inf(1), []keyValue{}},
+		{0.1, []keyValue{{"a\\ b", "0.1"}}},
+		{float32(0.1), []keyValue{{"a\\ b", "0.1"}}},
+		{1e21, []keyValue{{"a\\ b", "1e+21"}}},
+		{int32(7), []keyValue{{"a\\ b", "7i"}}},
+		{false, []keyValue{{"a\\ b", "false"}}},
+		{[]byte(`{"x":"y"}`), []keyValue{{"a\\ b", `"{\"x\":\"y\"}"`}}},
+		{at, []keyValue{{"a\\ b", `"2019-01-01T00:00:00Z"`}}},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, appendField([]keyValue{}, "a b", tc.value))
+	}
+}
@@ -1,33 +1,106 @@
 package ingestion
 
 import (
+	"github.com/timescale/outflux/internal/checkpoint"
 	"github.com/timescale/outflux/internal/connections"
 	"github.com/timescale/outflux/internal/ingestion/config"
+	"github.com/timescale/outflux/internal/ingestion/file"
+	"github.com/timescale/outflux/internal/ingestion/influx"
+	"github.com/timescale/outflux/internal/ingestion/script"
 	"github.com/timescale/outflux/internal/ingestion/ts"
+	"github.com/timescale/outflux/internal/schemamanagement"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
 	tsSchema "github.com/timescale/outflux/internal/schemamanagement/ts"
 )
 
 // IngestorService exposes methods to create new ingestors
 type IngestorService interface {
-	NewTimescaleIngestor(dbConn connections.PgxWrap, config *config.IngestorConfig) Ingestor
+	NewTimescaleIngestor(dbConn connections.PgxWrap, workerConns []connections.PgxWrap, config *config.IngestorConfig) Ingestor
+	// NewFileIngestor creates an ingestor that writes the rows to the files of the FileOutput of the config
+	NewFileIngestor(config *config.IngestorConfig) Ingestor
+	// NewScriptIngestor creates an ingestor that writes the rows to the SQL script of the ScriptOutput of the config
+	NewScriptIngestor(config *config.IngestorConfig) Ingestor
+	// NewInfluxIngestor creates an ingestor that writes the rows as points to the InfluxOutput of the config
+	NewInfluxIngestor(config *config.IngestorConfig) Ingestor
 }
 
 // NewIngestorService creates an instance of the IngestorService
 func NewIngestorService() IngestorService {
-	return &ingestorService{}
+	return &ingestorService{scripts: script.NewFiles()}
 }
 
 type ingestorService struct {
+	// scripts are shared by the script ingestors writing to the same path
+	scripts *script.Files
+}
+
+// outputSchemaManager prepares the tables of the output database, and indexes them
+type outputSchemaManager interface {
+	schemamanagement.SchemaManager
+	ts.Indexer
+	ts.UniqueIndexer
 }
 
 // NewIngestor creates a new instance of an Ingestor with a specified config, for a specified
-// data set and data channel
-func (i *ingestorService) NewTimescaleIngestor(dbConn connections.PgxWrap, config *config.IngestorConfig) Ingestor {
-	schemaManager := tsSchema.NewTSSchemaManager(dbConn, config.Schema, config.ChunkTimeInterval)
-	return &ts.TSIngestor{
+// data set and data channel. If more than one worker connection is given, the batches are
+// inserted with all of them at the same time. With a range partitioning in the config the
+// rows are inserted into partitioned tables of plain PostgreSQL
+func (i *ingestorService) NewTimescaleIngestor(dbConn connections.PgxWrap, workerConns []connections.PgxWrap, config *config.IngestorConfig) Ingestor {
+	var schemaManager outputSchemaManager
+	var compressor ts.ChunkCompressor
+	if config.RangePartitioning != nil {
+		schemaManager = tsSchema.NewPGSchemaManager(dbConn, config.Schema, config.RangePartitioning, config.Indexes)
+	} else {
+		tsSchemaManager := tsSchema.NewTSSchemaManager(dbConn, config.Schema, config.ChunkTimeInterval, config.RetentionPeriod, config.Compression, config.SpacePartitioning, config.Indexes)
+		schemaManager, compressor = tsSchemaManager, tsSchemaManager
+	}
+
+	var checkpoints checkpoint.Store
+	if config.CheckpointKey != nil {
+		checkpoints = checkpoint.NewStore(dbConn, config.Schema)
+	}
+
+	routine := ts.NewRoutine()
+	if len(workerConns) > 1 {
+		routine = ts.NewConcurrentRoutine(workerConns)
+	}
+
+	ingestor := &ts.TSIngestor{
 		DbConn:           dbConn,
 		Config:           config,
-		IngestionRoutine: ts.NewRoutine(),
+		IngestionRoutine: routine,
 		SchemaManager:    schemaManager,
+		Checkpoints:      checkpoints,
 	}
+
+	// the tables of the ValidateOnly strategy are used as they are
+	if config.Indexes != nil && config.SchemaStrategy != schemaconfig.ValidateOnly {
+		ingestor.Indexer = schemaManager
+	}
+
+	if config.OnConflict.Upserts() && config.SchemaStrategy != schemaconfig.ValidateOnly {
+		ingestor.UniqueIndexer = schemaManager
+	}
+
+	if compressor != nil && config.Compression != nil && config.Compression.CompressAfter > 0 {
+		ingestor.Compressor = compressor
+	}
+
+	return ingestor
+}
+
+// NewFileIngestor creates a new instance of an Ingestor that writes the rows to files
+func (i *ingestorService) NewFileIngestor(config *config.IngestorConfig) Ingestor {
+	return &file.FileIngestor{Config: config}
+}
+
+// NewScriptIngestor creates a new instance of an Ingestor that writes the rows to a SQL script
+func (i *ingestorService) NewScriptIngestor(config *config.IngestorConfig) Ingestor {
+	scripter := tsSchema.NewScriptSchemaManager(config.Schema, config.ChunkTimeInterval, config.ScriptOutput.CreateSchema, config.RetentionPeriod, config.Compression, config.SpacePartitioning, config.Indexes)
+	return &script.ScriptIngestor{Config: config, SchemaScripter: scripter, Scripts: i.scripts}
+}
+
+// NewInfluxIngestor creates a new instance of an Ingestor that writes the rows as points to InfluxDB
+func (i *ingestorService) NewInfluxIngestor(config *config.IngestorConfig) Ingestor {
+	return &influx.InfluxIngestor{Config: config}
 }
@@ -0,0 +1,36 @@
+package script
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// copyNull is a null value in the text format of COPY
+const copyNull = `\N`
+
+// copyEscaper escapes the characters with a special meaning in the text format of COPY
+var copyEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// formatCopyValue formats a value of a row for the text format of COPY. Times are formatted
+// as RFC3339 in UTC with nanoseconds, PostgreSQL rounds them to microseconds
+func formatCopyValue(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return copyNull
+	case string:
+		return copyEscaper.Replace(value)
+	case time.Time:
+		return value.UTC().Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(value), 'g', -1, 32)
+	case []byte:
+		// JSON columns
+		return copyEscaper.Replace(string(value))
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,89 @@
+package script
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// the script stops at the first error when applied with psql, the transaction it was in is rolled back
+const scriptHeader = "-- Written by Outflux, apply with: psql -f <script>\n\\set ON_ERROR_STOP on\n"
+
+// Files holds the scripts written by the ingestors by path, so the data sets written to the same path
+// are appended to the same script
+type Files struct {
+	lock  sync.Mutex
+	files map[string]*File
+}
+
+// NewFiles creates an empty set of scripts
+func NewFiles() *Files {
+	return &Files{files: make(map[string]*File)}
+}
+
+// get returns the script with the path
+func (f *Files) get(path string) *File {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	path = filepath.Clean(path)
+	file, ok := f.files[path]
+	if !ok {
+		file = &File{path: path}
+		f.files[path] = file
+	}
+
+	return file
+}
+
+// File is a script the sections of data sets are appended to. It's created with its header by the first
+// data set written to it, replacing the script of a previous migration
+type File struct {
+	path    string
+	lock    sync.Mutex
+	created bool
+}
+
+// create creates the script and its directory, unless it was already created
+func (f *File) create() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.created {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(f.path, []byte(scriptHeader), 0644); err != nil {
+		return err
+	}
+
+	f.created = true
+	return nil
+}
+
+// appendSection appends the content of a section file to the script, one section at a time
+func (f *File) appendSection(sectionPath string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	section, err := os.Open(sectionPath)
+	if err != nil {
+		return err
+	}
+
+	defer section.Close()
+	script, err := os.OpenFile(f.path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(script, section)
+	if closeErr := script.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
@@ -0,0 +1,223 @@
+package script
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jackc/pgx"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/ingestion/config"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+	"github.com/timescale/outflux/internal/utils"
+)
+
+// a section of the script holds the statements and rows of a data set, it's written to a temporary
+// file in the directory of the script before it's appended to the script
+const (
+	sectionFilePattern = ".outflux-section-*.sql"
+	sectionTemplate    = "\n-- %s\n"
+	statementTemplate  = "%s;\n"
+	beginStatement     = "BEGIN;\n"
+	commitStatement    = "COMMIT;\n"
+	copyTemplate       = "COPY %s(%s) FROM stdin;\n"
+	endOfCopy          = "\\.\n"
+	copyDelimiter      = "\t"
+)
+
+// SchemaScripter returns the statements preparing and indexing the table of a data set
+type SchemaScripter interface {
+	PrepareStatements(dataSet *idrf.DataSet, strategy schemaconfig.SchemaStrategy) ([]string, error)
+	IndexStatements(dataSet *idrf.DataSet) ([]string, error)
+}
+
+// ScriptIngestor implements an ingestor that writes the statements preparing the table of a data set, and its
+// rows as COPY blocks, to a SQL script instead of a database. With the CommitOnEnd strategy the section of a
+// data set is a single transaction, with CommitOnEachBatch the statements and each batch of rows are
+// committed on their own. A section is appended to the script once complete, so the data sets written to
+// the same script at the same time don't interleave
+type ScriptIngestor struct {
+	Config         *config.IngestorConfig
+	SchemaScripter SchemaScripter
+	Scripts        *Files
+	cachedBundle   *idrf.Bundle
+	statements     []string
+	script         *File
+}
+
+// ID returns a string identifying the ingestor instance in logs
+func (i *ScriptIngestor) ID() string {
+	return i.Config.IngestorID
+}
+
+// Prepare writes the statements that prepare the table of the data set for the schema strategy,
+// and creates the script of the data set if it doesn't exist yet
+func (i *ScriptIngestor) Prepare(bundle *idrf.Bundle) error {
+	i.cachedBundle = bundle
+	statements, err := i.SchemaScripter.PrepareStatements(bundle.DataDef, i.Config.SchemaStrategy)
+	if err != nil {
+		return fmt.Errorf("%s: %v", i.Config.IngestorID, err)
+	}
+
+	i.statements = statements
+	i.script = i.Scripts.get(i.Config.ScriptOutput.DataSetPath(bundle.DataDef.DataSetName))
+	if err = i.script.create(); err != nil {
+		return fmt.Errorf("%s: could not create script '%s'\n%v", i.Config.IngestorID, i.script.path, err)
+	}
+
+	return nil
+}
+
+// Start consumes a data channel of idrf.Row(s) and writes them to COPY blocks of the section of the data set.
+// On each ${batchSize} rows the ingestor checks for errors in the other goroutines, and discards the section
+// if it should roll back. The indexes are created at the end of the section, once the rows are loaded
+func (i *ScriptIngestor) Start(errChan chan error) error {
+	if i.cachedBundle == nil {
+		return fmt.Errorf("%s: Start called without calling Prepare first", i.Config.IngestorID)
+	}
+
+	id := i.Config.IngestorID
+	if utils.CheckError(errChan) != nil {
+		log.Printf("%s: received external error before starting data insertion. Quitting\n", id)
+		return nil
+	}
+
+	section, err := ioutil.TempFile(filepath.Dir(i.script.path), sectionFilePattern)
+	if err != nil {
+		return fmt.Errorf("%s: could not create section file\n%v", id, err)
+	}
+
+	defer os.Remove(section.Name())
+	defer section.Close()
+
+	dataSet := i.cachedBundle.DataDef
+	tableName := i.tableName(dataSet)
+	commitOnEnd := i.Config.CommitStrategy == config.CommitOnEnd
+	out := bufio.NewWriter(section)
+	log.Printf("Starting script ingestor '%s', writing to '%s'", id, i.script.path)
+	fmt.Fprintf(out, sectionTemplate, tableName)
+	if commitOnEnd {
+		out.WriteString(beginStatement)
+	}
+
+	writeStatements(out, i.statements, !commitOnEnd)
+	copyHeader := fmt.Sprintf(copyTemplate, tableName, columnNames(dataSet))
+	record := make([]string, len(dataSet.Columns))
+	copying := false
+	numRows := uint(0)
+	batchRows := uint16(0)
+	for row := range i.cachedBundle.DataChan {
+		if !copying {
+			if !commitOnEnd {
+				out.WriteString(beginStatement)
+			}
+
+			out.WriteString(copyHeader)
+			copying = true
+		}
+
+		for j, value := range row {
+			record[j] = formatCopyValue(value)
+		}
+
+		out.WriteString(strings.Join(record, copyDelimiter))
+		out.WriteString("\n")
+		numRows++
+		batchRows++
+		if batchRows < i.Config.BatchSize {
+			continue
+		}
+
+		batchRows = 0
+		if i.Config.RollbackOnExternalError && utils.CheckError(errChan) != nil {
+			log.Printf("%s: Error received from outside of ingestor. Discarding the section of the script\n", id)
+			return nil
+		}
+
+		if !commitOnEnd {
+			out.WriteString(endOfCopy)
+			out.WriteString(commitStatement)
+			copying = false
+		}
+
+		if err = out.Flush(); err != nil {
+			return fmt.Errorf("%s: could not write section file\n%v", id, err)
+		}
+	}
+
+	if copying {
+		out.WriteString(endOfCopy)
+		if !commitOnEnd {
+			out.WriteString(commitStatement)
+		}
+	}
+
+	// the tables of the ValidateOnly strategy are used as they are
+	if i.Config.SchemaStrategy != schemaconfig.ValidateOnly {
+		indexStatements, err := i.SchemaScripter.IndexStatements(dataSet)
+		if err != nil {
+			return fmt.Errorf("%s: %v", id, err)
+		}
+
+		writeStatements(out, indexStatements, !commitOnEnd)
+	}
+
+	if commitOnEnd {
+		out.WriteString(commitStatement)
+	}
+
+	if err = out.Flush(); err != nil {
+		return fmt.Errorf("%s: could not write section file\n%v", id, err)
+	}
+
+	if err = section.Close(); err != nil {
+		return fmt.Errorf("%s: could not write section file\n%v", id, err)
+	}
+
+	if err = i.script.appendSection(section.Name()); err != nil {
+		return fmt.Errorf("%s: could not append to script '%s'\n%v", id, i.script.path, err)
+	}
+
+	log.Printf("%s: Complete. Wrote %d rows to '%s'.\n", id, numRows, i.script.path)
+	return nil
+}
+
+func (i *ScriptIngestor) tableName(dataSet *idrf.DataSet) string {
+	if i.Config.Schema != "" {
+		return pgx.Identifier{i.Config.Schema, dataSet.DataSetName}.Sanitize()
+	}
+
+	return pgx.Identifier{dataSet.DataSetName}.Sanitize()
+}
+
+// writeStatements writes the statements, in a transaction of their own if requested
+func writeStatements(out *bufio.Writer, statements []string, inTransaction bool) {
+	if len(statements) == 0 {
+		return
+	}
+
+	if inTransaction {
+		out.WriteString(beginStatement)
+	}
+
+	for _, statement := range statements {
+		fmt.Fprintf(out, statementTemplate, statement)
+	}
+
+	if inTransaction {
+		out.WriteString(commitStatement)
+	}
+}
+
+func columnNames(dataSet *idrf.DataSet) string {
+	names := make([]string, len(dataSet.Columns))
+	for i, column := range dataSet.Columns {
+		names[i] = pgx.Identifier{column.Name}.Sanitize()
+	}
+
+	return strings.Join(names, ", ")
+}
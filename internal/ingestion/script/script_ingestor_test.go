@@ -0,0 +1,240 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/ingestion/config"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+)
+
+var (
+	firstTime  = time.Date(2019, time.January, 1, 10, 0, 0, 1, time.UTC)
+	secondTime = time.Date(2019, time.January, 2, 10, 0, 0, 0, time.UTC)
+	dataSet    = &idrf.DataSet{
+		DataSetName: "cpu",
+		TimeColumn:  "time",
+		Columns: []*idrf.Column{
+			{Name: "time", DataType: idrf.IDRFTimestamptz},
+			{Name: "host", DataType: idrf.IDRFString},
+			{Name: "usage", DataType: idrf.IDRFDouble},
+		},
+	}
+	rows = []idrf.Row{
+		{firstTime, "a\tb", 1.5},
+		{secondTime, nil, 2.5},
+	}
+)
+
+func TestScriptIngestorCommitStrategies(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	testCases := []struct {
+		strategy config.CommitStrategy
+		expected string
+	}{
+		{
+			strategy: config.CommitOnEnd,
+			expected: scriptHeader + "\n-- \"public\".\"cpu\"\nBEGIN;\nCREATE TABLE cpu;\n" +
+				"COPY \"public\".\"cpu\"(\"time\", \"host\", \"usage\") FROM stdin;\n" +
+				"2019-01-01T10:00:00.000000001Z\ta\\tb\t1.5\n2019-01-02T10:00:00Z\t\\N\t2.5\n\\.\n" +
+				"CREATE INDEX cpu;\nCOMMIT;\n",
+		}, {
+			strategy: config.CommitOnEachBatch,
+			expected: scriptHeader + "\n-- \"public\".\"cpu\"\nBEGIN;\nCREATE TABLE cpu;\nCOMMIT;\n" +
+				"BEGIN;\nCOPY \"public\".\"cpu\"(\"time\", \"host\", \"usage\") FROM stdin;\n2019-01-01T10:00:00.000000001Z\ta\\tb\t1.5\n\\.\nCOMMIT;\n" +
+				"BEGIN;\nCOPY \"public\".\"cpu\"(\"time\", \"host\", \"usage\") FROM stdin;\n2019-01-02T10:00:00Z\t\\N\t2.5\n\\.\nCOMMIT;\n" +
+				"BEGIN;\nCREATE INDEX cpu;\nCOMMIT;\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		path := filepath.Join(dir, tc.strategy.String()+".sql")
+		ingestor := newIngestor(path, NewFiles(), schemaconfig.CreateIfMissing)
+		ingestor.Config.CommitStrategy = tc.strategy
+		ingestor.Config.BatchSize = 1
+		assert.NoError(t, ingestor.Prepare(bundle(dataSet, rows)))
+		assert.NoError(t, ingestor.Start(make(chan error, 1)))
+		assert.Equal(t, tc.expected, readFile(t, path), tc.strategy.String())
+	}
+}
+
+func TestScriptIngestorAppendsDataSets(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// a previous script is replaced
+	path := filepath.Join(dir, "script", "db.sql")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.NoError(t, ioutil.WriteFile(path, []byte("previous"), 0644))
+	scripts := NewFiles()
+	mem := &idrf.DataSet{DataSetName: "mem", TimeColumn: "time", Columns: []*idrf.Column{{Name: "time", DataType: idrf.IDRFTimestamptz}}}
+	for _, data := range []*idrf.Bundle{bundle(dataSet, rows), bundle(mem, []idrf.Row{{firstTime}})} {
+		ingestor := newIngestor(path, scripts, schemaconfig.ValidateOnly)
+		assert.NoError(t, ingestor.Prepare(data))
+		assert.NoError(t, ingestor.Start(make(chan error, 1)))
+	}
+
+	expected := scriptHeader + "\n-- \"public\".\"cpu\"\nBEGIN;\n" +
+		"COPY \"public\".\"cpu\"(\"time\", \"host\", \"usage\") FROM stdin;\n" +
+		"2019-01-01T10:00:00.000000001Z\ta\\tb\t1.5\n2019-01-02T10:00:00Z\t\\N\t2.5\n\\.\nCOMMIT;\n" +
+		"\n-- \"public\".\"mem\"\nBEGIN;\nCOPY \"public\".\"mem\"(\"time\") FROM stdin;\n2019-01-01T10:00:00.000000001Z\n\\.\nCOMMIT;\n"
+	assert.Equal(t, expected, readFile(t, path))
+
+	// the sections are written to temporary files in the directory of the script
+	files, _ := filepath.Glob(filepath.Join(dir, "script", "*"))
+	assert.Equal(t, []string{path}, files)
+}
+
+func TestScriptIngestorScriptPerDataSet(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	ingestor := newIngestor(filepath.Join(dir, config.MeasurePlaceholder+".sql"), NewFiles(), schemaconfig.ValidateOnly)
+	assert.NoError(t, ingestor.Prepare(bundle(dataSet, nil)))
+	assert.NoError(t, ingestor.Start(make(chan error, 1)))
+	assert.Equal(t, scriptHeader+"\n-- \"public\".\"cpu\"\nBEGIN;\nCOMMIT;\n", readFile(t, filepath.Join(dir, "cpu.sql")))
+}
+
+func TestScriptIngestorDiscardsSectionOnExternalError(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "db.sql")
+	ingestor := newIngestor(path, NewFiles(), schemaconfig.CreateIfMissing)
+	ingestor.Config.BatchSize = 1
+	dataChan := make(chan idrf.Row)
+	errChan := make(chan error, 1)
+	assert.NoError(t, ingestor.Prepare(&idrf.Bundle{DataDef: dataSet, DataChan: dataChan}))
+	go func() {
+		dataChan <- rows[0]
+		errChan <- errors.New("extraction failed")
+		dataChan <- rows[1]
+		close(dataChan)
+	}()
+
+	assert.NoError(t, ingestor.Start(errChan))
+	assert.Equal(t, scriptHeader, readFile(t, path))
+}
+
+func TestScriptIngestorErrors(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	ingestor := newIngestor(filepath.Join(dir, "db.sql"), NewFiles(), schemaconfig.CreateIfMissing)
+	assert.Error(t, ingestor.Start(nil), "prepare not called")
+
+	testCases := []struct {
+		desc             string
+		scripter         *mockScripter
+		expectPrepareErr bool
+		expectStartErr   bool
+	}{
+		{desc: "schema statements can't be prepared", scripter: &mockScripter{prepareErr: fmt.Errorf("error")}, expectPrepareErr: true},
+		{desc: "index statements can't be prepared", scripter: &mockScripter{indexErr: fmt.Errorf("error")}, expectStartErr: true},
+	}
+
+	for _, tc := range testCases {
+		ingestor := newIngestor(filepath.Join(dir, "db.sql"), NewFiles(), schemaconfig.CreateIfMissing)
+		ingestor.SchemaScripter = tc.scripter
+		err := ingestor.Prepare(bundle(dataSet, nil))
+		assert.Equal(t, tc.expectPrepareErr, err != nil, tc.desc)
+		if err != nil {
+			continue
+		}
+
+		err = ingestor.Start(make(chan error, 1))
+		assert.Equal(t, tc.expectStartErr, err != nil, tc.desc)
+	}
+}
+
+func TestFormatCopyValue(t *testing.T) {
+	testCases := []struct {
+		value    interface{}
+		expected string
+	}{
+		{nil, `\N`},
+		{"a", "a"},
+		{"a\\b\nc\rd\te", `a\\b\nc\rd\te`},
+		{int32(1), "1"},
+		{int64(-2), "-2"},
+		{0.1, "0.1"},
+		{float32(0.1), "0.1"},
+		{true, "true"},
+		{[]byte(`{"a":"\n"}`), `{"a":"\\n"}`},
+		{time.Date(2019, time.January, 1, 1, 0, 0, 0, time.FixedZone("UTC+1", 3600)), "2019-01-01T00:00:00Z"},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, formatCopyValue(tc.value))
+	}
+}
+
+type mockScripter struct {
+	prepareErr error
+	indexErr   error
+}
+
+func (m *mockScripter) PrepareStatements(dataSet *idrf.DataSet, strategy schemaconfig.SchemaStrategy) ([]string, error) {
+	if strategy == schemaconfig.ValidateOnly {
+		return nil, m.prepareErr
+	}
+
+	return []string{"CREATE TABLE " + dataSet.DataSetName}, m.prepareErr
+}
+
+func (m *mockScripter) IndexStatements(dataSet *idrf.DataSet) ([]string, error) {
+	return []string{"CREATE INDEX " + dataSet.DataSetName}, m.indexErr
+}
+
+func newIngestor(path string, scripts *Files, strategy schemaconfig.SchemaStrategy) *ScriptIngestor {
+	return &ScriptIngestor{
+		Config: &config.IngestorConfig{
+			IngestorID:              "ing",
+			BatchSize:               10,
+			RollbackOnExternalError: true,
+			CommitStrategy:          config.CommitOnEnd,
+			SchemaStrategy:          strategy,
+			Schema:                  "public",
+			ScriptOutput:            &config.ScriptOutput{Path: path},
+		},
+		SchemaScripter: &mockScripter{},
+		Scripts:        scripts,
+	}
+}
+
+func bundle(dataSet *idrf.DataSet, rows []idrf.Row) *idrf.Bundle {
+	dataChan := make(chan idrf.Row, len(rows))
+	for _, row := range rows {
+		dataChan <- row
+	}
+
+	close(dataChan)
+	return &idrf.Bundle{DataDef: dataSet, DataChan: dataChan}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "outflux_scripts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func readFile(t *testing.T, path string) string {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
@@ -0,0 +1,252 @@
+package ts
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/jackc/pgx"
+	"github.com/timescale/outflux/internal/connections"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/utils"
+)
+
+// NewConcurrentRoutine creates a routine that inserts the batches of rows with each of the worker connections
+// at the same time. Each batch is inserted and committed in its own transaction, so the routine always
+// commits on each batch. The connection of the ingestor is kept for deleting the replaced rows and saving
+// the checkpoints
+func NewConcurrentRoutine(workerConns []connections.PgxWrap) Routine {
+	return &concurrentRoutine{workerConns: workerConns}
+}
+
+type concurrentRoutine struct {
+	workerConns []connections.PgxWrap
+}
+
+// rowBatch is a batch of rows with its position in the ingested data
+type rowBatch struct {
+	seq  int
+	rows [][]interface{}
+}
+
+func (routine *concurrentRoutine) ingest(args *ingestDataArgs) error {
+	log.Printf("Starting data ingestor '%s' with %d workers", args.ingestorID, len(routine.workerConns))
+
+	err := utils.CheckError(args.errChan)
+	if err != nil {
+		log.Printf("%s: received external error before starting data insertion. Quitting\n", args.ingestorID)
+		return nil
+	}
+
+	tableIdentifier := newTableIdentifier(args.schemaName, args.tableName)
+	if err = deleteReplacedRowsBeforeWorkers(args, tableIdentifier); err != nil {
+		return err
+	}
+
+	log.Printf("Will batch insert %d rows at once. Each batch is committed by one of the workers", args.batchSize)
+	batches := make(chan *rowBatch)
+	stop := newStopSignal()
+	tracker := newCommitTracker(args)
+	var workers sync.WaitGroup
+	for i, conn := range routine.workerConns {
+		workerArgs := *args
+		workerArgs.ingestorID = fmt.Sprintf("%s_worker_%d", args.ingestorID, i)
+		workerArgs.dbConn = conn
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			if err := insertBatches(&workerArgs, tableIdentifier, batches, stop, tracker); err != nil {
+				stop.fail(err)
+			}
+		}()
+	}
+
+	numInserts := dispatchBatches(args, batches, stop)
+	close(batches)
+	workers.Wait()
+	if stop.stopped() {
+		// an external error is already broadcast, only the error of a worker is returned
+		return stop.err
+	}
+
+	log.Printf("%s: Complete. Inserted %d rows.\n", args.ingestorID, numInserts)
+	return nil
+}
+
+// deleteReplacedRowsBeforeWorkers removes the rows that will be extracted again and commits it, before
+// any of the workers inserts a batch that could be deleted with them
+func deleteReplacedRowsBeforeWorkers(args *ingestDataArgs, identifier *pgx.Identifier) error {
+	if args.replaceFrom == nil {
+		return nil
+	}
+
+	tx, err := openTx(args)
+	if err != nil {
+		return err
+	}
+
+	if err = deleteReplacedRows(args, identifier, tx); err != nil {
+		return err
+	}
+
+	return commitTx(args, tx)
+}
+
+// dispatchBatches groups the rows of the data channel in batches and sends them to the workers,
+// until there are no more rows or the ingestion is stopped. On each batch it checks for an external
+// error and stops the ingestion if the batches are rolled back on one. Returns the number of dispatched rows
+func dispatchBatches(args *ingestDataArgs, batches chan *rowBatch, stop *stopSignal) uint {
+	numRows := uint(0)
+	seq := 0
+	batch := make([][]interface{}, 0, args.batchSize)
+	send := func() bool {
+		if args.rollbackOnExternalError && utils.CheckError(args.errChan) != nil {
+			log.Printf("%s: Error received from outside of ingestor. Rolling back\n", args.ingestorID)
+			stop.fail(nil)
+			return false
+		}
+
+		select {
+		case batches <- &rowBatch{seq: seq, rows: batch}:
+		case <-stop.done:
+			return false
+		}
+
+		numRows += uint(len(batch))
+		seq++
+		batch = make([][]interface{}, 0, args.batchSize)
+		return true
+	}
+
+	for row := range args.dataChannel {
+		batch = append(batch, row)
+		if len(batch) == int(args.batchSize) && !send() {
+			return numRows
+		}
+	}
+
+	if len(batch) > 0 {
+		send()
+	}
+
+	return numRows
+}
+
+// insertBatches inserts and commits each received batch in a transaction of the worker's connection.
+// A batch inserted after the ingestion was stopped is rolled back instead of committed
+func insertBatches(args *ingestDataArgs, identifier *pgx.Identifier, batches chan *rowBatch, stop *stopSignal, tracker *commitTracker) error {
+	stagingCreated := false
+	for batch := range batches {
+		tx, err := openTx(args)
+		if err != nil {
+			return err
+		}
+
+		if !stagingCreated {
+			if err = createStagingTable(args, tx); err != nil {
+				return err
+			}
+			stagingCreated = true
+		}
+
+		if err = insertBatch(args, identifier, tx, batch.rows); err != nil {
+			return err
+		}
+
+		if stop.stopped() {
+			_ = tx.Rollback()
+			return nil
+		}
+
+		if err = commitTx(args, tx); err != nil {
+			return err
+		}
+
+		if err = tracker.committed(batch); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stopSignal is closed once, by the first worker that fails or on an external error
+type stopSignal struct {
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+func newStopSignal() *stopSignal {
+	return &stopSignal{done: make(chan struct{})}
+}
+
+// fail stops the ingestion, keeping the error if it is the first to stop it.
+// The error is nil when the ingestion is stopped because of an external error
+func (s *stopSignal) fail(err error) {
+	s.once.Do(func() {
+		s.err = err
+		close(s.done)
+	})
+}
+
+func (s *stopSignal) stopped() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// commitTracker moves the checkpoint as the workers commit their batches. The batches can be committed
+// in any order, so the checkpoint is only moved to the last row of the batches committed without gaps.
+// Rows inserted after the checkpoint are deleted when a migration is resumed, so it is safe for
+// the checkpoint to be behind the committed rows
+type commitTracker struct {
+	lock sync.Mutex
+	// the ingestor's args, their connection is used for saving the checkpoints
+	args *ingestDataArgs
+	// sequence of the first batch not yet committed
+	next int
+	// last rows of the batches committed after the first not yet committed one
+	lastRows map[int]idrf.Row
+}
+
+func newCommitTracker(args *ingestDataArgs) *commitTracker {
+	return &commitTracker{args: args, lastRows: make(map[int]idrf.Row)}
+}
+
+// committed records that a batch is committed, and saves a checkpoint if the committed batches
+// without gaps grew with it
+func (t *commitTracker) committed(batch *rowBatch) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.lastRows[batch.seq] = batch.rows[len(batch.rows)-1]
+	var lastRow idrf.Row
+	for {
+		row, ok := t.lastRows[t.next]
+		if !ok {
+			break
+		}
+
+		delete(t.lastRows, t.next)
+		lastRow = row
+		t.next++
+	}
+
+	if t.args.checkpoints == nil || lastRow == nil {
+		return nil
+	}
+
+	lastTime, err := rowTime(t.args, lastRow)
+	if err == nil {
+		err = t.args.checkpoints.Save(t.args.checkpointKey, lastTime)
+	}
+
+	if err != nil {
+		log.Printf("%s could not save checkpoint in output db\n%v", t.args.ingestorID, err)
+	}
+
+	return err
+}
@@ -0,0 +1,93 @@
+package ts
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/checkpoint"
+	"github.com/timescale/outflux/internal/idrf"
+)
+
+func TestDispatchBatches(t *testing.T) {
+	dataChannel := make(chan idrf.Row, 5)
+	for i := 0; i < 5; i++ {
+		dataChannel <- idrf.Row{i}
+	}
+	close(dataChannel)
+
+	args := &ingestDataArgs{dataChannel: dataChannel, batchSize: 2, errChan: make(chan error, 1)}
+	batches := make(chan *rowBatch, 5)
+	numRows := dispatchBatches(args, batches, newStopSignal())
+	close(batches)
+	assert.Equal(t, uint(5), numRows)
+	expected := []*rowBatch{
+		{seq: 0, rows: [][]interface{}{{0}, {1}}},
+		{seq: 1, rows: [][]interface{}{{2}, {3}}},
+		{seq: 2, rows: [][]interface{}{{4}}},
+	}
+	received := []*rowBatch{}
+	for batch := range batches {
+		received = append(received, batch)
+	}
+	assert.Equal(t, expected, received)
+}
+
+func TestDispatchBatchesStopsOnExternalError(t *testing.T) {
+	dataChannel := make(chan idrf.Row, 2)
+	dataChannel <- idrf.Row{0}
+	dataChannel <- idrf.Row{1}
+	close(dataChannel)
+	errChan := make(chan error, 1)
+	errChan <- errors.New("err")
+
+	args := &ingestDataArgs{dataChannel: dataChannel, batchSize: 1, errChan: errChan, rollbackOnExternalError: true}
+	stop := newStopSignal()
+	assert.Equal(t, uint(0), dispatchBatches(args, make(chan *rowBatch, 2), stop))
+	assert.True(t, stop.stopped())
+	assert.NoError(t, stop.err)
+}
+
+func TestDispatchBatchesStopsWhenWorkerFails(t *testing.T) {
+	dataChannel := make(chan idrf.Row, 1)
+	dataChannel <- idrf.Row{0}
+	close(dataChannel)
+
+	args := &ingestDataArgs{dataChannel: dataChannel, batchSize: 1, errChan: make(chan error, 1)}
+	stop := newStopSignal()
+	stop.fail(errors.New("first"))
+	stop.fail(errors.New("second"))
+	// no worker receives the batch
+	assert.Equal(t, uint(0), dispatchBatches(args, make(chan *rowBatch), stop))
+	assert.EqualError(t, stop.err, "first")
+}
+
+func TestCommitTrackerSavesContiguousCheckpoints(t *testing.T) {
+	first := time.Unix(1, 0).UTC()
+	second := time.Unix(2, 0).UTC()
+	third := time.Unix(3, 0).UTC()
+	store := &mockCheckpointStore{}
+	args := &ingestDataArgs{checkpoints: store, checkpointKey: checkpoint.Key{Measure: "m"}}
+	tracker := newCommitTracker(args)
+
+	// second batch committed first, the first one is still missing
+	assert.NoError(t, tracker.committed(&rowBatch{seq: 1, rows: [][]interface{}{{second}}}))
+	assert.Empty(t, store.saved)
+	assert.NoError(t, tracker.committed(&rowBatch{seq: 0, rows: [][]interface{}{{first}}}))
+	assert.Equal(t, []time.Time{second}, store.saved)
+	assert.NoError(t, tracker.committed(&rowBatch{seq: 2, rows: [][]interface{}{{first}, {third}}}))
+	assert.Equal(t, []time.Time{second, third}, store.saved)
+	assert.Empty(t, tracker.lastRows)
+
+	assert.Error(t, tracker.committed(&rowBatch{seq: 3, rows: [][]interface{}{{"2019-01-01"}}}), "not a time")
+	assert.Equal(t, []time.Time{second, third}, store.saved)
+
+	store.saveErr = errors.New("err")
+	assert.Error(t, tracker.committed(&rowBatch{seq: 4, rows: [][]interface{}{{third}}}))
+
+	// checkpoints disabled
+	tracker = newCommitTracker(&ingestDataArgs{})
+	assert.NoError(t, tracker.committed(&rowBatch{seq: 0, rows: [][]interface{}{{first}}}))
+	assert.Equal(t, 1, tracker.next)
+}
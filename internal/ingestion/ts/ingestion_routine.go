@@ -3,14 +3,20 @@ package ts
 import (
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/jackc/pgx"
+	"github.com/timescale/outflux/internal/checkpoint"
 	"github.com/timescale/outflux/internal/connections"
 	"github.com/timescale/outflux/internal/idrf"
 	"github.com/timescale/outflux/internal/ingestion/config"
 	"github.com/timescale/outflux/internal/utils"
 )
 
+const (
+	deleteRowsFromTemplate = "DELETE FROM %s WHERE %s >= $1"
+)
+
 type ingestDataArgs struct {
 	// id of the ingestor used to subscribe and unsubscribe to errors from other goroutines
 	ingestorID string
@@ -34,6 +40,20 @@ type ingestDataArgs struct {
 	schemaName string
 	// commit strategy
 	commitStrategy config.CommitStrategy
+	// store where the time of the last committed row is recorded, nil if checkpoints are disabled
+	checkpoints checkpoint.Store
+	// identifies the checkpoint of the ingested data
+	checkpointKey checkpoint.Key
+	// index of the time column in the rows
+	timeColIndex int
+	// name of the time column
+	timeColName string
+	// the time column holds the nanoseconds since the Unix epoch instead of a timestamp
+	epochTime bool
+	// if set, existing rows at or after this time are replaced by the ingested rows
+	replaceFrom *time.Time
+	// if set, the batches are upserted through a staging table instead of copied into the table
+	upsert *upsertStatements
 }
 
 // Routine defines an interface that consumes a channel of idrf.Rows and
@@ -67,13 +87,16 @@ func (routine *defaultRoutine) ingest(args *ingestDataArgs) error {
 	batchInserts := uint16(0)
 	log.Printf("Will batch insert %d rows at once. With commit strategy: %v", args.batchSize, args.commitStrategy)
 	batch := make([][]interface{}, args.batchSize)
-	var tableIdentifier *pgx.Identifier
-	if args.schemaName != "" {
-		tableIdentifier = &pgx.Identifier{args.schemaName, args.tableName}
-	} else {
-		tableIdentifier = &pgx.Identifier{args.tableName}
+	tableIdentifier := newTableIdentifier(args.schemaName, args.tableName)
+	if err = createStagingTable(args, tx); err != nil {
+		return err
+	}
+
+	if err = deleteReplacedRows(args, tableIdentifier, tx); err != nil {
+		return err
 	}
 
+	var lastRow idrf.Row
 	for row := range args.dataChannel {
 		batch[batchInserts] = row
 		batchInserts++
@@ -89,12 +112,16 @@ func (routine *defaultRoutine) ingest(args *ingestDataArgs) error {
 
 		numInserts += uint(batchInserts)
 		batchInserts = 0
-		if err = copyToDb(args, tableIdentifier, tx, batch); err != nil {
+		if err = insertBatch(args, tableIdentifier, tx, batch); err != nil {
 			return err
 		}
+		lastRow = row
 		if args.commitStrategy != config.CommitOnEachBatch {
 			continue
 		}
+		if err = saveCheckpoint(args, tx, lastRow); err != nil {
+			return err
+		}
 		if err = commitTx(args, tx); err != nil {
 			return err
 		}
@@ -105,12 +132,16 @@ func (routine *defaultRoutine) ingest(args *ingestDataArgs) error {
 
 	if batchInserts > 0 {
 		batch = batch[:batchInserts]
-		if err = copyToDb(args, tableIdentifier, tx, batch); err != nil {
+		if err = insertBatch(args, tableIdentifier, tx, batch); err != nil {
 			return err
 		}
 		numInserts += uint(batchInserts)
+		lastRow = batch[batchInserts-1]
 	}
 
+	if err = saveCheckpoint(args, tx, lastRow); err != nil {
+		return err
+	}
 	if err = commitTx(args, tx); err != nil {
 		return err
 	}
@@ -128,6 +159,51 @@ func commitTx(args *ingestDataArgs, tx *pgx.Tx) error {
 	return err
 }
 
+func newTableIdentifier(schemaName, tableName string) *pgx.Identifier {
+	if schemaName != "" {
+		return &pgx.Identifier{schemaName, tableName}
+	}
+
+	return &pgx.Identifier{tableName}
+}
+
+// insertBatch copies the batch into the table, or upserts it through the staging table
+func insertBatch(args *ingestDataArgs, identifier *pgx.Identifier, tx *pgx.Tx, batch [][]interface{}) error {
+	if args.upsert == nil {
+		return copyToDb(args, identifier, tx, batch)
+	}
+
+	if err := copyToDb(args, &args.upsert.stagingTable, tx, batch); err != nil {
+		return err
+	}
+
+	for _, statement := range []string{args.upsert.insert, args.upsert.truncate} {
+		if _, err := args.dbConn.Exec(statement); err != nil {
+			log.Printf("%s could not upsert batch of rows in output db\n%v", args.ingestorID, err)
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createStagingTable creates the temporary table the batches are copied to before they are upserted,
+// it is kept by the connection for the following transactions
+func createStagingTable(args *ingestDataArgs, tx *pgx.Tx) error {
+	if args.upsert == nil {
+		return nil
+	}
+
+	if _, err := args.dbConn.Exec(args.upsert.createStaging); err != nil {
+		log.Printf("%s could not create staging table in output db\n%v", args.ingestorID, err)
+		_ = tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
 func copyToDb(args *ingestDataArgs, identifier *pgx.Identifier, tx *pgx.Tx, batch [][]interface{}) error {
 	source := pgx.CopyFromRows(batch)
 	_, err := args.dbConn.CopyFrom(*identifier, args.colNames, source)
@@ -139,6 +215,62 @@ func copyToDb(args *ingestDataArgs, identifier *pgx.Identifier, tx *pgx.Tx, batc
 	return err
 }
 
+// deleteReplacedRows removes the rows that will be extracted again in the open transaction,
+// so the replaced rows and the first batch of the new ones are committed together
+func deleteReplacedRows(args *ingestDataArgs, identifier *pgx.Identifier, tx *pgx.Tx) error {
+	if args.replaceFrom == nil {
+		return nil
+	}
+
+	log.Printf("%s: replacing rows at or after %s", args.ingestorID, args.replaceFrom.Format(time.RFC3339Nano))
+	query := fmt.Sprintf(deleteRowsFromTemplate, identifier.Sanitize(), pgx.Identifier{args.timeColName}.Sanitize())
+	var from interface{} = *args.replaceFrom
+	if args.epochTime {
+		from = args.replaceFrom.UnixNano()
+	}
+
+	if _, err := args.dbConn.Exec(query, from); err != nil {
+		log.Printf("%s could not delete replaced rows in output db\n%v", args.ingestorID, err)
+		_ = tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+// saveCheckpoint records the time of the last ingested row in the open transaction,
+// so the checkpoint moves forward only if the transaction is committed
+func saveCheckpoint(args *ingestDataArgs, tx *pgx.Tx, lastRow idrf.Row) error {
+	if args.checkpoints == nil || lastRow == nil {
+		return nil
+	}
+
+	lastTime, err := rowTime(args, lastRow)
+	if err == nil {
+		err = args.checkpoints.Save(args.checkpointKey, lastTime)
+	}
+
+	if err != nil {
+		log.Printf("%s could not save checkpoint in output db\n%v", args.ingestorID, err)
+		_ = tx.Rollback()
+	}
+
+	return err
+}
+
+// rowTime returns the value of the time column of a row, an error if it isn't a time
+// or nanoseconds since the epoch
+func rowTime(args *ingestDataArgs, row idrf.Row) (time.Time, error) {
+	switch value := row[args.timeColIndex].(type) {
+	case time.Time:
+		return value, nil
+	case int64:
+		return time.Unix(0, value).UTC(), nil
+	default:
+		return time.Time{}, fmt.Errorf("time column value %v of type %T can't be recorded as a checkpoint", value, value)
+	}
+}
+
 func openTx(args *ingestDataArgs) (*pgx.Tx, error) {
 	tx, err := args.dbConn.Begin()
 	if err != nil {
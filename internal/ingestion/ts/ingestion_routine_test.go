@@ -3,10 +3,13 @@ package ts
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/jackc/pgx"
 	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/checkpoint"
 	"github.com/timescale/outflux/internal/connections"
+	"github.com/timescale/outflux/internal/idrf"
 )
 
 func TestOpenTx(t *testing.T) {
@@ -44,3 +47,76 @@ func TestCopyToDb(t *testing.T) {
 	assert.Equal(t, mock.ExpCopyFromTab[0], pgx.Identifier{"x"})
 	assert.Equal(t, mock.ExpCopyFromCol, [][]string{[]string{"a"}})
 }
+
+func TestDeleteReplacedRows(t *testing.T) {
+	// nothing to replace
+	mock := &connections.MockPgxW{}
+	assert.NoError(t, deleteReplacedRows(&ingestDataArgs{dbConn: mock}, &pgx.Identifier{"x"}, &pgx.Tx{}))
+	assert.Equal(t, 0, mock.CurrentExec)
+	from := time.Now()
+	mock = &connections.MockPgxW{ExecRes: []pgx.CommandTag{""}, ExecErrs: []error{nil}}
+	args := &ingestDataArgs{dbConn: mock, replaceFrom: &from, timeColName: "time"}
+	assert.NoError(t, deleteReplacedRows(args, &pgx.Identifier{"s", "x"}, &pgx.Tx{}))
+	assert.Equal(t, []string{`DELETE FROM "s"."x" WHERE "time" >= $1`}, mock.ExpExec)
+	assert.Equal(t, [][]interface{}{{from}}, mock.ExpExecArgs)
+	// time column with nanoseconds since the epoch
+	mock = &connections.MockPgxW{ExecRes: []pgx.CommandTag{""}, ExecErrs: []error{nil}}
+	args = &ingestDataArgs{dbConn: mock, replaceFrom: &from, timeColName: "time", epochTime: true}
+	assert.NoError(t, deleteReplacedRows(args, &pgx.Identifier{"x"}, &pgx.Tx{}))
+	assert.Equal(t, [][]interface{}{{from.UnixNano()}}, mock.ExpExecArgs)
+	mock = &connections.MockPgxW{ExecRes: []pgx.CommandTag{""}, ExecErrs: []error{errors.New("err")}}
+	args.dbConn = mock
+	assert.Panics(t, func() {
+		deleteReplacedRows(args, &pgx.Identifier{"x"}, &pgx.Tx{})
+	}, "should panic because of tx.Rollback")
+}
+
+func TestSaveCheckpoint(t *testing.T) {
+	now := time.Now()
+	key := checkpoint.Key{Database: "db", RetentionPolicy: "rp", Measure: "m"}
+	// checkpoints disabled
+	assert.NoError(t, saveCheckpoint(&ingestDataArgs{}, &pgx.Tx{}, idrf.Row{now}))
+	// nothing ingested
+	store := &mockCheckpointStore{}
+	assert.NoError(t, saveCheckpoint(&ingestDataArgs{checkpoints: store}, &pgx.Tx{}, nil))
+	assert.Empty(t, store.saved)
+	// time column is second
+	args := &ingestDataArgs{checkpoints: store, checkpointKey: key, timeColIndex: 1}
+	assert.NoError(t, saveCheckpoint(args, &pgx.Tx{}, idrf.Row{"a", now}))
+	assert.Equal(t, []time.Time{now}, store.saved)
+	assert.Equal(t, key, store.key)
+	// time column with nanoseconds since the epoch
+	assert.NoError(t, saveCheckpoint(args, &pgx.Tx{}, idrf.Row{"a", int64(1)}))
+	assert.Equal(t, []time.Time{now, time.Unix(0, 1).UTC()}, store.saved)
+	// a time of another type rolls back the transaction
+	assert.Panics(t, func() {
+		saveCheckpoint(args, &pgx.Tx{}, idrf.Row{"a", "2019-01-01"})
+	}, "should panic because of tx.Rollback")
+	_, err := rowTime(args, idrf.Row{"a", "2019-01-01"})
+	assert.EqualError(t, err, "time column value 2019-01-01 of type string can't be recorded as a checkpoint")
+	// error on save rolls back the transaction
+	store = &mockCheckpointStore{saveErr: errors.New("err")}
+	args.checkpoints = store
+	assert.Panics(t, func() {
+		saveCheckpoint(args, &pgx.Tx{}, idrf.Row{"a", now})
+	}, "should panic because of tx.Rollback")
+}
+
+type mockCheckpointStore struct {
+	saveErr error
+	saved   []time.Time
+	key     checkpoint.Key
+}
+
+func (m *mockCheckpointStore) Init() error { return nil }
+func (m *mockCheckpointStore) Load(key checkpoint.Key) (time.Time, bool, error) {
+	return time.Time{}, false, nil
+}
+func (m *mockCheckpointStore) Save(key checkpoint.Key, lastTime time.Time) error {
+	m.key = key
+	if m.saveErr != nil {
+		return m.saveErr
+	}
+	m.saved = append(m.saved, lastTime)
+	return nil
+}
@@ -3,19 +3,43 @@ package ts
 import (
 	"fmt"
 
+	"github.com/timescale/outflux/internal/checkpoint"
 	"github.com/timescale/outflux/internal/connections"
 	"github.com/timescale/outflux/internal/idrf"
 	"github.com/timescale/outflux/internal/ingestion/config"
 	"github.com/timescale/outflux/internal/schemamanagement"
 )
 
+// Indexer creates the indexes of the hypertable of a data set once its data is ingested
+type Indexer interface {
+	CreateIndexes(dataSet *idrf.DataSet) error
+}
+
+// ChunkCompressor compresses the chunks of the hypertable of a data set once its data is ingested
+type ChunkCompressor interface {
+	CompressChunks(dataSet *idrf.DataSet) error
+}
+
+// UniqueIndexer creates the unique index the rows of a data set are upserted on,
+// the keys are quoted columns or expressions
+type UniqueIndexer interface {
+	CreateUniqueIndex(dataSet *idrf.DataSet, keys []string) error
+}
+
 // TSIngestor implements a TimescaleDB ingestor
 type TSIngestor struct {
 	Config           *config.IngestorConfig
 	DbConn           connections.PgxWrap
 	IngestionRoutine Routine
 	SchemaManager    schemamanagement.SchemaManager
-	cachedBundle     *idrf.Bundle
+	Checkpoints      checkpoint.Store
+	// Indexer if not nil, is called after all the data is ingested
+	Indexer Indexer
+	// Compressor if not nil, is called after all the data is ingested and indexed
+	Compressor ChunkCompressor
+	// UniqueIndexer if not nil, is called before the data is ingested when the rows are upserted
+	UniqueIndexer UniqueIndexer
+	cachedBundle  *idrf.Bundle
 }
 
 // ID returns a string identifying the ingestor instance in logs
@@ -23,13 +47,32 @@ func (i *TSIngestor) ID() string {
 	return i.Config.IngestorID
 }
 
-// Prepare creates or validates the output tables in Timescale
+// Prepare creates or validates the output tables in Timescale, and the unique index the rows are upserted on.
+// If checkpoints are enabled it also prepares the checkpoint table
 func (i *TSIngestor) Prepare(bundle *idrf.Bundle) error {
 	i.cachedBundle = bundle
-	return i.SchemaManager.PrepareDataSet(bundle.DataDef, i.Config.SchemaStrategy)
+	err := i.SchemaManager.PrepareDataSet(bundle.DataDef, i.Config.SchemaStrategy)
+	if err != nil {
+		return err
+	}
+
+	if i.UniqueIndexer != nil && i.Config.OnConflict.Upserts() {
+		columns := conflictColumns(bundle.DataDef, i.Config.ConflictColumns)
+		keys := conflictKeys(bundle.DataDef, columns, i.partitionColumn())
+		if err = i.UniqueIndexer.CreateUniqueIndex(bundle.DataDef, keys); err != nil {
+			return err
+		}
+	}
+
+	if i.Checkpoints == nil {
+		return nil
+	}
+
+	return i.Checkpoints.Init()
 }
 
-// Start consumes a data channel of idrf.Row(s) and inserts them into a TimescaleDB hypertable
+// Start consumes a data channel of idrf.Row(s) and inserts them into a TimescaleDB hypertable.
+// Once all the data is ingested, the hypertable is indexed and its old chunks are compressed if requested
 func (i *TSIngestor) Start(errChan chan error) error {
 	if i.cachedBundle == nil {
 		return fmt.Errorf("%s: Start called without calling Prepare first", i.Config.IngestorID)
@@ -37,6 +80,7 @@ func (i *TSIngestor) Start(errChan chan error) error {
 
 	dataSet := i.cachedBundle.DataDef
 	colNames := extractColumnNames(dataSet.Columns)
+	timeColumn := dataSet.ColumnNamed(dataSet.TimeColumn)
 
 	ingestArgs := &ingestDataArgs{
 		ingestorID:              i.Config.IngestorID,
@@ -49,9 +93,38 @@ func (i *TSIngestor) Start(errChan chan error) error {
 		tableName:               dataSet.DataSetName,
 		schemaName:              i.Config.Schema,
 		commitStrategy:          i.Config.CommitStrategy,
+		timeColIndex:            timeColumnIndex(dataSet),
+		timeColName:             dataSet.TimeColumn,
+		epochTime:               timeColumn != nil && timeColumn.DataType == idrf.IDRFInteger64,
+		replaceFrom:             i.Config.ResumeFrom,
+	}
+
+	if i.Config.OnConflict.Upserts() {
+		table := newTableIdentifier(i.Config.Schema, dataSet.DataSetName)
+		columns := conflictColumns(dataSet, i.Config.ConflictColumns)
+		ingestArgs.upsert = newUpsertStatements(table, dataSet, columns, i.partitionColumn(), i.Config.OnConflict)
+	}
+
+	if i.Checkpoints != nil && i.Config.CheckpointKey != nil {
+		ingestArgs.checkpoints = i.Checkpoints
+		ingestArgs.checkpointKey = *i.Config.CheckpointKey
+	}
+
+	if err := i.IngestionRoutine.ingest(ingestArgs); err != nil {
+		return err
+	}
+
+	if i.Indexer != nil {
+		if err := i.Indexer.CreateIndexes(dataSet); err != nil {
+			return err
+		}
+	}
+
+	if i.Compressor == nil {
+		return nil
 	}
 
-	return i.IngestionRoutine.ingest(ingestArgs)
+	return i.Compressor.CompressChunks(dataSet)
 }
 
 func extractColumnNames(columns []*idrf.Column) []string {
@@ -62,3 +135,22 @@ func extractColumnNames(columns []*idrf.Column) []string {
 
 	return columnNames
 }
+
+func timeColumnIndex(dataSet *idrf.DataSet) int {
+	for i, column := range dataSet.Columns {
+		if column.Name == dataSet.TimeColumn {
+			return i
+		}
+	}
+
+	return 0
+}
+
+// partitionColumn returns the column the hypertable is hash partitioned by, if any
+func (i *TSIngestor) partitionColumn() string {
+	if i.Config.SpacePartitioning == nil {
+		return ""
+	}
+
+	return i.Config.SpacePartitioning.Column
+}
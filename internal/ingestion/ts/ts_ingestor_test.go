@@ -0,0 +1,120 @@
+package ts
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/ingestion/config"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+)
+
+func TestStartRunsPostLoadSteps(t *testing.T) {
+	dataSet := &idrf.DataSet{DataSetName: "ds", TimeColumn: "time", Columns: []*idrf.Column{{Name: "time", DataType: idrf.IDRFTimestamptz}}}
+	testCases := []struct {
+		desc          string
+		ingestErr     error
+		indexErr      error
+		expectedSteps []string
+		expectErr     bool
+	}{
+		{desc: "indexes before compressing", expectedSteps: []string{"ingest", "index", "compress"}},
+		{desc: "nothing after failed ingestion", ingestErr: fmt.Errorf("error"), expectedSteps: []string{"ingest"}, expectErr: true},
+		{desc: "not compressed after failed indexing", indexErr: fmt.Errorf("error"), expectedSteps: []string{"ingest", "index"}, expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		steps := &mockPostLoad{ingestErr: tc.ingestErr, indexErr: tc.indexErr}
+		ingestor := &TSIngestor{
+			Config:           &config.IngestorConfig{IngestorID: "id"},
+			IngestionRoutine: steps,
+			Indexer:          steps,
+			Compressor:       steps,
+			cachedBundle:     &idrf.Bundle{DataDef: dataSet},
+		}
+		err := ingestor.Start(make(chan error))
+		assert.Equal(t, tc.expectErr, err != nil, tc.desc)
+		assert.Equal(t, tc.expectedSteps, steps.steps, tc.desc)
+	}
+}
+
+func TestPrepareCreatesUniqueIndex(t *testing.T) {
+	dataSet := &idrf.DataSet{DataSetName: "ds", TimeColumn: "time", Columns: []*idrf.Column{
+		{Name: "time", DataType: idrf.IDRFTimestamptz},
+		{Name: "host", DataType: idrf.IDRFString},
+	}}
+	testCases := []struct {
+		desc         string
+		onConflict   config.ConflictStrategy
+		partitioning *schemaconfig.SpacePartitioning
+		indexErr     error
+		expected     []string
+		expectErr    bool
+	}{
+		{desc: "rows are copied", onConflict: config.NoConflictAction},
+		{desc: "rows are upserted", onConflict: config.ConflictDoUpdate, expected: []string{`"time"`, `COALESCE("host", '')`}},
+		{
+			desc:         "partitioning column is indexed as it is",
+			onConflict:   config.ConflictDoUpdate,
+			partitioning: &schemaconfig.SpacePartitioning{Column: "host", Partitions: 2},
+			expected:     []string{`"time"`, `"host"`},
+		},
+		{desc: "index can't be created", onConflict: config.ConflictDoNothing, indexErr: fmt.Errorf("error"), expected: []string{`"time"`, `COALESCE("host", '')`}, expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		indexer := &mockUniqueIndexer{err: tc.indexErr}
+		ingestor := &TSIngestor{
+			Config: &config.IngestorConfig{
+				IngestorID: "id", OnConflict: tc.onConflict, ConflictColumns: []string{"host", "rp"}, SpacePartitioning: tc.partitioning,
+			},
+			SchemaManager: &mockSchemaManager{},
+			UniqueIndexer: indexer,
+		}
+		err := ingestor.Prepare(&idrf.Bundle{DataDef: dataSet})
+		assert.Equal(t, tc.expectErr, err != nil, tc.desc)
+		assert.Equal(t, tc.expected, indexer.keys, tc.desc)
+	}
+}
+
+type mockUniqueIndexer struct {
+	keys []string
+	err  error
+}
+
+func (m *mockUniqueIndexer) CreateUniqueIndex(dataSet *idrf.DataSet, keys []string) error {
+	m.keys = keys
+	return m.err
+}
+
+type mockSchemaManager struct{}
+
+func (m *mockSchemaManager) DiscoverDataSets() ([]string, error) { return nil, nil }
+func (m *mockSchemaManager) FetchDataSet(dataSetIdentifier string) (*idrf.DataSet, error) {
+	return nil, nil
+}
+func (m *mockSchemaManager) PrepareDataSet(dataSet *idrf.DataSet, strategy schemaconfig.SchemaStrategy) error {
+	return nil
+}
+
+type mockPostLoad struct {
+	steps     []string
+	ingestErr error
+	indexErr  error
+}
+
+func (m *mockPostLoad) ingest(args *ingestDataArgs) error {
+	m.steps = append(m.steps, "ingest")
+	return m.ingestErr
+}
+
+func (m *mockPostLoad) CreateIndexes(dataSet *idrf.DataSet) error {
+	m.steps = append(m.steps, "index")
+	return m.indexErr
+}
+
+func (m *mockPostLoad) CompressChunks(dataSet *idrf.DataSet) error {
+	m.steps = append(m.steps, "compress")
+	return nil
+}
@@ -0,0 +1,119 @@
+package ts
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/ingestion/config"
+)
+
+// each batch is copied to a temporary staging table of the connection, and inserted from it into the table
+// with an ON CONFLICT clause. A row can't be affected twice by the insert, so only the last copied row of
+// each key is selected. The staging table is emptied after each batch
+const (
+	stagingTableNameTemplate   = "outflux_staging_%s"
+	createStagingTableTemplate = `CREATE TEMP TABLE IF NOT EXISTS %s (LIKE %s)`
+	insertFromStagingTemplate  = `INSERT INTO %s AS existing (%s) SELECT DISTINCT ON (%[3]s) %[4]s FROM %[5]s ORDER BY %[3]s, ctid DESC ON CONFLICT (%[3]s) %[6]s`
+	truncateStagingTemplate    = `TRUNCATE %s`
+	doNothingClause            = `DO NOTHING`
+	doUpdateTemplate           = `DO UPDATE SET %s`
+)
+
+// like InfluxDB, an upserted point keeps the values of the existing point for the fields it doesn't have.
+// The fields combined in a JSONB column are merged, the new values replacing the existing ones
+const (
+	updateColumnTemplate     = `%[1]s = COALESCE(EXCLUDED.%[1]s, existing.%[1]s)`
+	updateJSONColumnTemplate = `%[1]s = COALESCE(existing.%[1]s || EXCLUDED.%[1]s, EXCLUDED.%[1]s, existing.%[1]s)`
+)
+
+// NULLs are distinct in a unique index, so a nullable key column is indexed as an expression replacing NULL
+// with an empty value. InfluxDB doesn't tell a missing tag from an empty one
+const (
+	nullableTextKeyTemplate = `COALESCE(%s, '')`
+	nullableJSONKeyTemplate = `COALESCE(%s, '{}'::jsonb)`
+)
+
+// upsertStatements holds the staging table of a data set, and the statements that upsert a batch through it
+type upsertStatements struct {
+	stagingTable  pgx.Identifier
+	createStaging string
+	insert        string
+	truncate      string
+}
+
+// newUpsertStatements returns the statements that upsert the rows of the data set into the table, on the keys of the
+// conflict columns. With ConflictDoNothing existing rows are kept, with ConflictDoUpdate their columns are updated
+func newUpsertStatements(table *pgx.Identifier, dataSet *idrf.DataSet, conflictColumns []string, partitionColumn string, strategy config.ConflictStrategy) *upsertStatements {
+	staging := pgx.Identifier{fmt.Sprintf(stagingTableNameTemplate, dataSet.DataSetName)}
+	isConflictColumn := make(map[string]bool, len(conflictColumns))
+	for _, column := range conflictColumns {
+		isConflictColumn[column] = true
+	}
+
+	quotedColumns := make([]string, len(dataSet.Columns))
+	updates := []string{}
+	for i, column := range dataSet.Columns {
+		quotedColumns[i] = pgx.Identifier{column.Name}.Sanitize()
+		if isConflictColumn[column.Name] {
+			continue
+		}
+
+		if column.DataType == idrf.IDRFJson {
+			updates = append(updates, fmt.Sprintf(updateJSONColumnTemplate, quotedColumns[i]))
+		} else {
+			updates = append(updates, fmt.Sprintf(updateColumnTemplate, quotedColumns[i]))
+		}
+	}
+
+	// a row without other columns than the conflict columns has nothing to update
+	action := doNothingClause
+	if strategy == config.ConflictDoUpdate && len(updates) > 0 {
+		action = fmt.Sprintf(doUpdateTemplate, strings.Join(updates, ", "))
+	}
+
+	columnList := strings.Join(quotedColumns, ", ")
+	keyList := strings.Join(conflictKeys(dataSet, conflictColumns, partitionColumn), ", ")
+	return &upsertStatements{
+		stagingTable:  staging,
+		createStaging: fmt.Sprintf(createStagingTableTemplate, staging.Sanitize(), table.Sanitize()),
+		insert: fmt.Sprintf(insertFromStagingTemplate,
+			table.Sanitize(), columnList, keyList, columnList, staging.Sanitize(), action),
+		truncate: fmt.Sprintf(truncateStagingTemplate, staging.Sanitize()),
+	}
+}
+
+// conflictColumns returns the columns that identify a row of the data set: the time column, followed
+// by the requested columns the data set has
+func conflictColumns(dataSet *idrf.DataSet, requested []string) []string {
+	columns := []string{dataSet.TimeColumn}
+	for _, column := range requested {
+		if column != dataSet.TimeColumn && dataSet.ColumnNamed(column) != nil {
+			columns = append(columns, column)
+		}
+	}
+
+	return columns
+}
+
+// conflictKeys returns the keys of the unique index the rows are upserted on: the quoted conflict columns, the
+// nullable ones wrapped in an expression. The partitioning column of a hypertable must be indexed as it is
+func conflictKeys(dataSet *idrf.DataSet, conflictColumns []string, partitionColumn string) []string {
+	keys := make([]string, len(conflictColumns))
+	for i, column := range conflictColumns {
+		keys[i] = pgx.Identifier{column}.Sanitize()
+		if column == dataSet.TimeColumn || column == partitionColumn {
+			continue
+		}
+
+		switch dataSet.ColumnNamed(column).DataType {
+		case idrf.IDRFString:
+			keys[i] = fmt.Sprintf(nullableTextKeyTemplate, keys[i])
+		case idrf.IDRFJson:
+			keys[i] = fmt.Sprintf(nullableJSONKeyTemplate, keys[i])
+		}
+	}
+
+	return keys
+}
@@ -0,0 +1,116 @@
+package ts
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx"
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/connections"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/ingestion/config"
+)
+
+func TestNewUpsertStatements(t *testing.T) {
+	dataSet := &idrf.DataSet{
+		DataSetName: "cpu",
+		TimeColumn:  "time",
+		Columns: []*idrf.Column{
+			{Name: "time", DataType: idrf.IDRFTimestamptz},
+			{Name: "host", DataType: idrf.IDRFString},
+			{Name: "usage", DataType: idrf.IDRFDouble},
+			{Name: "fields", DataType: idrf.IDRFJson},
+		},
+	}
+	table := &pgx.Identifier{"public", "cpu"}
+	columns := []string{"time", "host"}
+	doNothing := newUpsertStatements(table, dataSet, columns, "", config.ConflictDoNothing)
+	assert.Equal(t, pgx.Identifier{"outflux_staging_cpu"}, doNothing.stagingTable)
+	assert.Equal(t, `CREATE TEMP TABLE IF NOT EXISTS "outflux_staging_cpu" (LIKE "public"."cpu")`, doNothing.createStaging)
+	assert.Equal(t, `TRUNCATE "outflux_staging_cpu"`, doNothing.truncate)
+	assert.Equal(t,
+		`INSERT INTO "public"."cpu" AS existing ("time", "host", "usage", "fields") `+
+			`SELECT DISTINCT ON ("time", COALESCE("host", '')) "time", "host", "usage", "fields" FROM "outflux_staging_cpu" `+
+			`ORDER BY "time", COALESCE("host", ''), ctid DESC ON CONFLICT ("time", COALESCE("host", '')) DO NOTHING`,
+		doNothing.insert)
+
+	doUpdate := newUpsertStatements(table, dataSet, columns, "host", config.ConflictDoUpdate)
+	assert.Equal(t,
+		`INSERT INTO "public"."cpu" AS existing ("time", "host", "usage", "fields") `+
+			`SELECT DISTINCT ON ("time", "host") "time", "host", "usage", "fields" FROM "outflux_staging_cpu" `+
+			`ORDER BY "time", "host", ctid DESC ON CONFLICT ("time", "host") DO UPDATE SET `+
+			`"usage" = COALESCE(EXCLUDED."usage", existing."usage"), `+
+			`"fields" = COALESCE(existing."fields" || EXCLUDED."fields", EXCLUDED."fields", existing."fields")`,
+		doUpdate.insert)
+
+	// nothing to update besides the conflict columns
+	keyOnly := &idrf.DataSet{DataSetName: "cpu", TimeColumn: "time", Columns: dataSet.Columns[:2]}
+	doUpdate = newUpsertStatements(table, keyOnly, columns, "host", config.ConflictDoUpdate)
+	assert.Equal(t,
+		`INSERT INTO "public"."cpu" AS existing ("time", "host") SELECT DISTINCT ON ("time", "host") "time", "host" FROM "outflux_staging_cpu" `+
+			`ORDER BY "time", "host", ctid DESC ON CONFLICT ("time", "host") DO NOTHING`,
+		doUpdate.insert)
+}
+
+func TestConflictKeys(t *testing.T) {
+	dataSet := &idrf.DataSet{
+		DataSetName: "cpu",
+		TimeColumn:  "time",
+		Columns: []*idrf.Column{
+			{Name: "time", DataType: idrf.IDRFTimestamptz},
+			{Name: "time_ns", DataType: idrf.IDRFInteger32},
+			{Name: "host", DataType: idrf.IDRFString},
+			{Name: "region", DataType: idrf.IDRFString},
+			{Name: "tags", DataType: idrf.IDRFJson},
+		},
+	}
+
+	// a series without a host or region tag has NULL in their columns
+	assert.Equal(t,
+		[]string{`"time"`, `"time_ns"`, `COALESCE("host", '')`, `"region"`, `COALESCE("tags", '{}'::jsonb)`},
+		conflictKeys(dataSet, []string{"time", "time_ns", "host", "region", "tags"}, "region"))
+}
+
+func TestConflictColumns(t *testing.T) {
+	dataSet := &idrf.DataSet{
+		DataSetName: "cpu",
+		TimeColumn:  "time",
+		Columns:     []*idrf.Column{{Name: "time"}, {Name: "time_ns"}, {Name: "host"}, {Name: "usage"}},
+	}
+	assert.Equal(t, []string{"time"}, conflictColumns(dataSet, nil))
+	assert.Equal(t, []string{"time", "time_ns", "host"}, conflictColumns(dataSet, []string{"time_ns", "rp", "host", "time"}))
+}
+
+func TestInsertBatch(t *testing.T) {
+	// copied straight into the table
+	mock := &connections.MockPgxW{CopyFromErr: []error{nil}}
+	args := &ingestDataArgs{dbConn: mock, colNames: []string{"time"}}
+	assert.NoError(t, insertBatch(args, &pgx.Identifier{"x"}, &pgx.Tx{}, [][]interface{}{}))
+	assert.Equal(t, []pgx.Identifier{{"x"}}, mock.ExpCopyFromTab)
+	assert.Equal(t, 0, mock.CurrentExec)
+
+	// upserted through the staging table
+	upsert := &upsertStatements{stagingTable: pgx.Identifier{"staging"}, insert: "insert", truncate: "truncate"}
+	mock = &connections.MockPgxW{CopyFromErr: []error{nil}, ExecRes: []pgx.CommandTag{"", ""}, ExecErrs: []error{nil, nil}}
+	args = &ingestDataArgs{dbConn: mock, colNames: []string{"time"}, upsert: upsert}
+	assert.NoError(t, insertBatch(args, &pgx.Identifier{"x"}, &pgx.Tx{}, [][]interface{}{}))
+	assert.Equal(t, []pgx.Identifier{{"staging"}}, mock.ExpCopyFromTab)
+	assert.Equal(t, []string{"insert", "truncate"}, mock.ExpExec)
+
+	mock = &connections.MockPgxW{CopyFromErr: []error{nil}, ExecRes: []pgx.CommandTag{""}, ExecErrs: []error{errors.New("err")}}
+	args.dbConn = mock
+	assert.Panics(t, func() {
+		insertBatch(args, &pgx.Identifier{"x"}, &pgx.Tx{}, [][]interface{}{})
+	}, "should panic because of tx.Rollback")
+}
+
+func TestCreateStagingTable(t *testing.T) {
+	mock := &connections.MockPgxW{}
+	assert.NoError(t, createStagingTable(&ingestDataArgs{dbConn: mock}, &pgx.Tx{}))
+	assert.Equal(t, 0, mock.CurrentExec)
+
+	mock = &connections.MockPgxW{ExecRes: []pgx.CommandTag{""}, ExecErrs: []error{nil}}
+	args := &ingestDataArgs{dbConn: mock, upsert: &upsertStatements{createStaging: "create"}}
+	assert.NoError(t, createStagingTable(args, &pgx.Tx{}))
+	assert.Equal(t, []string{"create"}, mock.ExpExec)
+}
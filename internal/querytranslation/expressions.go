@@ -0,0 +1,367 @@
+package querytranslation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/influxql"
+)
+
+const (
+	jsonTextTemplate     = "%s->>%s"
+	numericCastTemplate  = "(%s)::double precision"
+	booleanCastTemplate  = "(%s)::boolean"
+	epochTemplate        = "to_timestamp(%d / 1e9)"
+	percentileTemplate   = "percentile_cont(%s) WITHIN GROUP (ORDER BY %s)"
+	countDistinctFormat  = "count(DISTINCT %s)"
+	timeFilterMacro      = "$__timeFilter(%s)"
+	unsupportedFunc      = "function '%s' is not supported"
+	unsupportedCondition = "condition '%s' is not supported"
+)
+
+// aggregates maps the InfluxQL aggregates and selectors to SQL, the argument is the field expression
+var aggregates = map[string]func(field string) string{
+	"count":  func(field string) string { return fmt.Sprintf("count(%s)", field) },
+	"sum":    func(field string) string { return fmt.Sprintf("sum(%s)", field) },
+	"mean":   func(field string) string { return fmt.Sprintf("avg(%s)", field) },
+	"median": func(field string) string { return fmt.Sprintf(percentileTemplate, "0.5", field) },
+	"min":    func(field string) string { return fmt.Sprintf("min(%s)", field) },
+	"max":    func(field string) string { return fmt.Sprintf("max(%s)", field) },
+	"spread": func(field string) string { return fmt.Sprintf("max(%s) - min(%s)", field, field) },
+	"stddev": func(field string) string { return fmt.Sprintf("stddev_samp(%s)", field) },
+	"first":  func(field string) string { return fmt.Sprintf("first(%s, %s)", field, quote(timeColumn)) },
+	"last":   func(field string) string { return fmt.Sprintf("last(%s, %s)", field, quote(timeColumn)) },
+}
+
+// mathFunctions maps the InfluxQL functions of a single value to SQL
+var mathFunctions = map[string]string{
+	"abs": "abs", "ceil": "ceil", "floor": "floor", "round": "round", "sqrt": "sqrt", "ln": "ln", "log10": "log",
+}
+
+var comparisons = map[influxql.Token]string{
+	influxql.EQ: "=", influxql.NEQ: "!=", influxql.LT: "<", influxql.LTE: "<=", influxql.GT: ">", influxql.GTE: ">=",
+	influxql.EQREGEX: "~", influxql.NEQREGEX: "!~",
+}
+
+var arithmetic = map[influxql.Token]string{
+	influxql.ADD: "+", influxql.SUB: "-", influxql.MUL: "*", influxql.DIV: "/", influxql.MOD: "%",
+}
+
+// value translates an expression of the SELECT clause
+func (t *translator) value(expr influxql.Expr) (string, error) {
+	switch expr := expr.(type) {
+	case *influxql.VarRef:
+		if expr.Type == influxql.Tag {
+			return t.tagRef(expr.Val), nil
+		}
+
+		return t.fieldRef(expr.Val, influxql.Float), nil
+	case *influxql.Call:
+		return t.call(expr)
+	case *influxql.BinaryExpr:
+		operator, ok := arithmetic[expr.Op]
+		if !ok {
+			return "", fmt.Errorf("operator '%s' is not supported", expr.Op)
+		}
+
+		lhs, err := t.value(expr.LHS)
+		if err != nil {
+			return "", err
+		}
+
+		rhs, err := t.value(expr.RHS)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("%s %s %s", lhs, operator, rhs), nil
+	case *influxql.ParenExpr:
+		inner, err := t.value(expr.Expr)
+		return "(" + inner + ")", err
+	case *influxql.IntegerLiteral, *influxql.NumberLiteral:
+		return expr.String(), nil
+	case *influxql.StringLiteral:
+		if name, ok := variableName(expr); ok {
+			return "$" + name, nil
+		}
+	}
+
+	return "", fmt.Errorf("expression '%s' is not supported", expr)
+}
+
+func (t *translator) call(call *influxql.Call) (string, error) {
+	if function, ok := mathFunctions[call.Name]; ok && len(call.Args) == 1 {
+		arg, err := t.value(call.Args[0])
+		return fmt.Sprintf("%s(%s)", function, arg), err
+	}
+
+	if call.Name == "percentile" && len(call.Args) == 2 {
+		field, ok := call.Args[0].(*influxql.VarRef)
+		if !ok {
+			return "", fmt.Errorf("the argument of '%s' must be a field", call)
+		}
+
+		var percentile float64
+		switch n := call.Args[1].(type) {
+		case *influxql.IntegerLiteral:
+			percentile = float64(n.Val)
+		case *influxql.NumberLiteral:
+			percentile = n.Val
+		default:
+			return "", fmt.Errorf("the percentile of '%s' must be a number", call)
+		}
+
+		return fmt.Sprintf(percentileTemplate, strconv.FormatFloat(percentile/100, 'f', -1, 64), t.fieldRef(field.Val, influxql.Float)), nil
+	}
+
+	aggregate, ok := aggregates[call.Name]
+	if !ok || len(call.Args) != 1 {
+		return "", fmt.Errorf(unsupportedFunc, call.Name)
+	}
+
+	switch arg := call.Args[0].(type) {
+	case *influxql.VarRef:
+		return aggregate(t.fieldRef(arg.Val, influxql.Float)), nil
+	case *influxql.Distinct:
+		if call.Name == "count" {
+			return fmt.Sprintf(countDistinctFormat, t.fieldRef(arg.Val, influxql.Unknown)), nil
+		}
+	case *influxql.Call:
+		distinct, ok := arg.Args[0].(*influxql.VarRef)
+		if call.Name == "count" && arg.Name == "distinct" && len(arg.Args) == 1 && ok {
+			return fmt.Sprintf(countDistinctFormat, t.fieldRef(distinct.Val, influxql.Unknown)), nil
+		}
+	}
+
+	return "", fmt.Errorf("the argument of '%s' must be a field", call)
+}
+
+// condition translates an expression of the WHERE clause, and records the bounds of the time.
+// Bounds inside of an OR are not recorded
+func (t *translator) condition(expr influxql.Expr, bounds *timeBounds) (string, error) {
+	switch expr := expr.(type) {
+	case *influxql.ParenExpr:
+		inner, err := t.condition(expr.Expr, bounds)
+		return "(" + inner + ")", err
+	case *influxql.StringLiteral:
+		if name, ok := variableName(expr); ok && name == timeFilterVariable {
+			bounds.lower, bounds.upper = true, true
+			return fmt.Sprintf(timeFilterMacro, quote(timeColumn)), nil
+		}
+	case *influxql.BinaryExpr:
+		if expr.Op == influxql.AND || expr.Op == influxql.OR {
+			if expr.Op == influxql.OR {
+				bounds = &timeBounds{}
+			}
+
+			lhs, err := t.condition(expr.LHS, bounds)
+			if err != nil {
+				return "", err
+			}
+
+			rhs, err := t.condition(expr.RHS, bounds)
+			return fmt.Sprintf("%s %s %s", lhs, expr.Op, rhs), err
+		}
+
+		return t.comparison(expr, bounds)
+	}
+
+	return "", fmt.Errorf(unsupportedCondition, expr)
+}
+
+// comparison translates the comparison of the time, a tag or a field with a value. A reference
+// without a type is a tag when compared to a string or a regular expression, otherwise a field
+func (t *translator) comparison(expr *influxql.BinaryExpr, bounds *timeBounds) (string, error) {
+	operator, ok := comparisons[expr.Op]
+	if !ok {
+		return "", fmt.Errorf(unsupportedCondition, expr)
+	}
+
+	ref, isRef := expr.LHS.(*influxql.VarRef)
+	other := expr.RHS
+	if !isRef {
+		if ref, isRef = expr.RHS.(*influxql.VarRef); !isRef {
+			return "", fmt.Errorf(unsupportedCondition, expr)
+		}
+
+		other = expr.LHS
+	}
+
+	if strings.ToLower(ref.Val) == timeColumn {
+		return t.timeComparison(expr, ref == expr.LHS, operator, bounds)
+	}
+
+	var lhs, rhs string
+	switch value := other.(type) {
+	case *influxql.RegexLiteral:
+		lhs, rhs = t.ref(ref, influxql.Tag, influxql.String), quoteLiteral(regexVariables(value.Val.String()))
+	case *influxql.StringLiteral:
+		if name, ok := variableName(value); ok {
+			lhs, rhs = t.ref(ref, influxql.Tag, influxql.String), "$"+name
+			break
+		}
+
+		lhs, rhs = t.ref(ref, influxql.Tag, influxql.String), stringLiteral(value.Val)
+		if value.Val == "" && (expr.Op == influxql.EQ || expr.Op == influxql.NEQ) {
+			// a missing tag is an empty string in InfluxQL, and a NULL in the tables
+			if expr.Op == influxql.EQ {
+				return lhs + " IS NULL", nil
+			}
+
+			return lhs + " IS NOT NULL", nil
+		}
+	case *influxql.IntegerLiteral, *influxql.NumberLiteral, *influxql.UnsignedLiteral:
+		lhs, rhs = t.ref(ref, influxql.Float, influxql.Float), value.String()
+	case *influxql.BooleanLiteral:
+		lhs, rhs = t.ref(ref, influxql.Boolean, influxql.Boolean), value.String()
+	default:
+		return "", fmt.Errorf(unsupportedCondition, expr)
+	}
+
+	if ref != expr.LHS {
+		lhs, rhs = rhs, lhs
+	}
+
+	return fmt.Sprintf("%s %s %s", lhs, operator, rhs), nil
+}
+
+func (t *translator) timeComparison(expr *influxql.BinaryExpr, timeOnLeft bool, operator string, bounds *timeBounds) (string, error) {
+	other := expr.RHS
+	if !timeOnLeft {
+		other = expr.LHS
+	}
+
+	value, err := timeValue(other, true)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case expr.Op == influxql.EQ:
+		bounds.lower, bounds.upper = true, true
+	case (expr.Op == influxql.GT || expr.Op == influxql.GTE) == timeOnLeft:
+		bounds.lower = true
+	default:
+		bounds.upper = true
+	}
+
+	if timeOnLeft {
+		return fmt.Sprintf("%s %s %s", quote(timeColumn), operator, value), nil
+	}
+
+	return fmt.Sprintf("%s %s %s", value, operator, quote(timeColumn)), nil
+}
+
+// timeValue translates a time of a condition. An absolute number or duration is the time since the
+// Unix epoch, while a duration added to or subtracted from a time is an interval
+func timeValue(expr influxql.Expr, absolute bool) (string, error) {
+	switch expr := expr.(type) {
+	case *influxql.Call:
+		if expr.Name == "now" && len(expr.Args) == 0 {
+			return "now()", nil
+		}
+	case *influxql.StringLiteral:
+		if name, ok := variableName(expr); ok {
+			return "$" + name, nil
+		}
+
+		return stringLiteral(expr.Val), nil
+	case *influxql.DurationLiteral:
+		if absolute {
+			return fmt.Sprintf(epochTemplate, expr.Val.Nanoseconds()), nil
+		}
+
+		return FormatInterval(expr.Val), nil
+	case *influxql.IntegerLiteral:
+		if absolute {
+			return fmt.Sprintf(epochTemplate, expr.Val), nil
+		}
+	case *influxql.ParenExpr:
+		inner, err := timeValue(expr.Expr, absolute)
+		return "(" + inner + ")", err
+	case *influxql.BinaryExpr:
+		if expr.Op == influxql.ADD || expr.Op == influxql.SUB {
+			lhs, err := timeValue(expr.LHS, true)
+			if err != nil {
+				return "", err
+			}
+
+			rhs, err := timeValue(expr.RHS, false)
+			return fmt.Sprintf("%s %s %s", lhs, expr.Op, rhs), err
+		}
+	}
+
+	return "", fmt.Errorf("time '%s' is not supported", expr)
+}
+
+// bucketInterval translates the interval of GROUP BY time(), a duration or a Grafana variable
+func bucketInterval(expr influxql.Expr) (string, bool) {
+	switch expr := expr.(type) {
+	case *influxql.DurationLiteral:
+		return FormatInterval(expr.Val), true
+	case *influxql.StringLiteral:
+		if name, ok := variableName(expr); ok {
+			if name == intervalVariable || name == grafanaIntervalVariable {
+				name = grafanaIntervalVariable
+			}
+
+			return "INTERVAL '$" + name + "'", true
+		}
+	}
+
+	return "", false
+}
+
+// fillValue wraps the value of a bucket filled by time_bucket_gapfill as requested by fill()
+func fillValue(fill influxql.FillOption, fillValue interface{}, value string) string {
+	switch fill {
+	case influxql.NumberFill:
+		return fmt.Sprintf("COALESCE(%s, %v)", value, fillValue)
+	case influxql.PreviousFill:
+		return fmt.Sprintf("locf(%s)", value)
+	case influxql.LinearFill:
+		return fmt.Sprintf("interpolate(%s)", value)
+	default:
+		return value
+	}
+}
+
+// ref returns the column of a reference, with the data type of the given type hint. References
+// without a type hint are handled as 'kind'
+func (t *translator) ref(ref *influxql.VarRef, kind, dataType influxql.DataType) string {
+	if ref.Type != influxql.Unknown {
+		kind = ref.Type
+	}
+
+	if kind == influxql.Tag {
+		return t.tagRef(ref.Val)
+	}
+
+	return t.fieldRef(ref.Val, dataType)
+}
+
+func (t *translator) tagRef(tag string) string {
+	if t.layout.TagsColumn == "" {
+		return quote(tag)
+	}
+
+	return fmt.Sprintf(jsonTextTemplate, quote(t.layout.TagsColumn), quoteLiteral(tag))
+}
+
+// fieldRef returns the column of a field. The values of a JSON column are cast to the data type
+func (t *translator) fieldRef(field string, dataType influxql.DataType) string {
+	if t.layout.FieldsColumn == "" {
+		return quote(field)
+	}
+
+	value := fmt.Sprintf(jsonTextTemplate, quote(t.layout.FieldsColumn), quoteLiteral(field))
+	switch dataType {
+	case influxql.Float, influxql.Integer:
+		return fmt.Sprintf(numericCastTemplate, value)
+	case influxql.Boolean:
+		return fmt.Sprintf(booleanCastTemplate, value)
+	default:
+		return value
+	}
+}
@@ -0,0 +1,455 @@
+package querytranslation
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	influxPluginID       = "influxdb"
+	postgresPluginID     = "postgres"
+	unsupportedComment   = "-- unsupported: %s\n"
+	panelFlagTemplate    = "panel '%s', query %s: %s"
+	variableFlagTemplate = "variable '%s': %s"
+)
+
+var regexValue = regexp.MustCompile(`^/.*/$`)
+
+// DashboardReport counts the rewritten queries of a dashboard, and lists the parts that could not be translated
+type DashboardReport struct {
+	Translated int
+	Flagged    []string
+}
+
+// dashboardTranslator rewrites the InfluxDB queries of a dashboard as queries of a PostgreSQL data source
+type dashboardTranslator struct {
+	layout     *Layout
+	datasource string
+	// influxInputs holds the ${DS_...} names of the InfluxDB data sources of an exported dashboard
+	influxInputs map[string]bool
+	report       *DashboardReport
+}
+
+// TranslateDashboard rewrites the InfluxDB targets of the panels of a Grafana dashboard, and the InfluxDB query variables,
+// into queries of the PostgreSQL data source with the given uid (or name, for dashboards that reference the data sources
+// by name). Queries written with the query builder are rendered to InfluxQL first. A panel is only rewritten if all of its
+// InfluxDB queries can be translated, the unsupported parts of a rewritten query are kept as comments of the SQL
+func TranslateDashboard(content []byte, layout *Layout, datasource string) ([]byte, *DashboardReport, error) {
+	var document map[string]interface{}
+	if err := json.Unmarshal(content, &document); err != nil {
+		return nil, nil, fmt.Errorf("could not parse the dashboard JSON\n%v", err)
+	}
+
+	// dashboards exported through the HTTP API are wrapped with their metadata
+	dashboard := document
+	if wrapped, ok := document["dashboard"].(map[string]interface{}); ok {
+		dashboard = wrapped
+	}
+
+	translator := &dashboardTranslator{
+		layout:       layout,
+		datasource:   datasource,
+		influxInputs: influxInputs(document),
+		report:       &DashboardReport{Flagged: []string{}},
+	}
+
+	translator.panels(dashboard["panels"])
+	// dashboards of older Grafana versions keep the panels in rows
+	if rows, ok := dashboard["rows"].([]interface{}); ok {
+		for _, row := range rows {
+			if row, ok := row.(map[string]interface{}); ok {
+				translator.panels(row["panels"])
+			}
+		}
+	}
+
+	if templating, ok := dashboard["templating"].(map[string]interface{}); ok {
+		translator.variables(templating["list"])
+	}
+
+	translated, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not write the dashboard JSON\n%v", err)
+	}
+
+	return translated, translator.report, nil
+}
+
+func influxInputs(document map[string]interface{}) map[string]bool {
+	inputs := make(map[string]bool)
+	list, _ := document["__inputs"].([]interface{})
+	for _, input := range list {
+		input, ok := input.(map[string]interface{})
+		if ok && input["pluginId"] == influxPluginID {
+			inputs[fmt.Sprintf("${%v}", input["name"])] = true
+		}
+	}
+
+	return inputs
+}
+
+func (d *dashboardTranslator) panels(panels interface{}) {
+	list, _ := panels.([]interface{})
+	for _, panel := range list {
+		panel, ok := panel.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		// collapsed rows hold their panels
+		d.panels(panel["panels"])
+		d.panel(panel)
+	}
+}
+
+// panel rewrites the InfluxDB targets of a panel, if all of them can be translated
+func (d *dashboardTranslator) panel(panel map[string]interface{}) {
+	targets, _ := panel["targets"].([]interface{})
+	title, _ := panel["title"].(string)
+	rewritten := make(map[int]map[string]interface{})
+	flagged := []string{}
+	failed := false
+	for i, target := range targets {
+		target, ok := target.(map[string]interface{})
+		if !ok || !d.isInflux(target["datasource"], panel["datasource"], target) {
+			continue
+		}
+
+		refID, _ := target["refId"].(string)
+		sql, unsupported, err := d.translateTarget(target)
+		if err != nil {
+			flagged = append(flagged, fmt.Sprintf(panelFlagTemplate, title, refID, err))
+			failed = true
+			continue
+		}
+
+		for _, part := range unsupported {
+			flagged = append(flagged, fmt.Sprintf(panelFlagTemplate, title, refID, part))
+		}
+
+		rewritten[i] = d.postgresTarget(target, sql, unsupported)
+	}
+
+	if len(rewritten) == 0 && !failed {
+		return
+	}
+
+	if failed {
+		d.report.Flagged = append(d.report.Flagged, flagged...)
+		d.report.Flagged = append(d.report.Flagged, fmt.Sprintf("panel '%s' is not rewritten", title))
+		return
+	}
+
+	for i, target := range rewritten {
+		targets[i] = target
+	}
+
+	// without a data source the panel uses the default one
+	if panel["datasource"] == nil || d.isInflux(panel["datasource"], nil, nil) {
+		panel["datasource"] = d.datasourceRef(panel["datasource"])
+	}
+
+	d.report.Translated += len(rewritten)
+	d.report.Flagged = append(d.report.Flagged, flagged...)
+}
+
+// translateTarget returns the SQL of the InfluxQL query of a target, and the parts that could not be translated
+func (d *dashboardTranslator) translateTarget(target map[string]interface{}) (string, []string, error) {
+	query := influxQuery(target)
+	unsupported := []string{}
+	if alias, _ := target["alias"].(string); alias != "" {
+		unsupported = append(unsupported, fmt.Sprintf("alias '%s' is not translated", alias))
+	}
+
+	if query == "" {
+		return "", nil, fmt.Errorf("the query is empty")
+	}
+
+	translations, err := Translate(query, d.layout)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(translations) != 1 {
+		return "", nil, fmt.Errorf("only a single statement can be translated")
+	}
+
+	translation := translations[0]
+	unsupported = append(unsupported, translation.Unsupported...)
+	if translation.SQL == "" {
+		return "", nil, fmt.Errorf("the query can't be translated: %s", strings.Join(unsupported, ", "))
+	}
+
+	return translation.SQL, unsupported, nil
+}
+
+// postgresTarget returns the target of the PostgreSQL data source for the SQL of an InfluxDB target
+func (d *dashboardTranslator) postgresTarget(target map[string]interface{}, sql string, unsupported []string) map[string]interface{} {
+	comments := ""
+	for _, part := range unsupported {
+		comments += fmt.Sprintf(unsupportedComment, part)
+	}
+
+	format := "time_series"
+	if target["resultFormat"] == "table" {
+		format = "table"
+	}
+
+	rewritten := map[string]interface{}{
+		"refId":      target["refId"],
+		"rawQuery":   true,
+		"editorMode": "code",
+		"rawSql":     comments + sql,
+		"format":     format,
+	}
+
+	if hide, ok := target["hide"]; ok {
+		rewritten["hide"] = hide
+	}
+
+	if datasource, ok := target["datasource"]; ok && datasource != nil {
+		rewritten["datasource"] = d.datasourceRef(datasource)
+	}
+
+	return rewritten
+}
+
+// variables rewrites the InfluxDB query variables
+func (d *dashboardTranslator) variables(variables interface{}) {
+	list, _ := variables.([]interface{})
+	for _, variable := range list {
+		variable, ok := variable.(map[string]interface{})
+		if !ok || variable["type"] != "query" || !d.isInflux(variable["datasource"], nil, nil) {
+			continue
+		}
+
+		name, _ := variable["name"].(string)
+		query, ok := variable["query"].(string)
+		if !ok {
+			d.report.Flagged = append(d.report.Flagged, fmt.Sprintf(variableFlagTemplate, name, "the query is not a string"))
+			continue
+		}
+
+		translations, err := Translate(query, d.layout)
+		if err != nil || len(translations) != 1 || translations[0].SQL == "" || len(translations[0].Unsupported) > 0 {
+			reason := "the query can't be translated"
+			if err == nil && len(translations) == 1 && len(translations[0].Unsupported) > 0 {
+				reason += ": " + strings.Join(translations[0].Unsupported, ", ")
+			}
+
+			d.report.Flagged = append(d.report.Flagged, fmt.Sprintf(variableFlagTemplate, name, reason))
+			continue
+		}
+
+		variable["query"] = translations[0].SQL
+		if _, ok := variable["definition"]; ok {
+			variable["definition"] = translations[0].SQL
+		}
+
+		variable["datasource"] = d.datasourceRef(variable["datasource"])
+		d.report.Translated++
+	}
+}
+
+// isInflux returns true if the data source (or the fallback data source) is InfluxDB. When the
+// data source is unknown the target is InfluxDB if it has the fields of an InfluxDB target
+func (d *dashboardTranslator) isInflux(datasource, fallback interface{}, target map[string]interface{}) bool {
+	if datasource == nil {
+		datasource = fallback
+	}
+
+	switch datasource := datasource.(type) {
+	case map[string]interface{}:
+		if pluginID, ok := datasource["type"].(string); ok {
+			return pluginID == influxPluginID
+		}
+	case string:
+		if d.influxInputs[datasource] {
+			return true
+		}
+	}
+
+	if target == nil {
+		return false
+	}
+
+	_, hasRawSQL := target["rawSql"]
+	_, hasQuery := target["query"]
+	_, hasMeasurement := target["measurement"]
+	return !hasRawSQL && (hasQuery || hasMeasurement)
+}
+
+// datasourceRef references the PostgreSQL data source in the same way as the replaced data source
+func (d *dashboardTranslator) datasourceRef(replaced interface{}) interface{} {
+	if _, ok := replaced.(string); ok {
+		return d.datasource
+	}
+
+	return map[string]interface{}{"type": postgresPluginID, "uid": d.datasource}
+}
+
+// influxQuery returns the raw query of a target, or renders the query of the query builder like Grafana does
+func influxQuery(target map[string]interface{}) string {
+	if rawQuery, _ := target["rawQuery"].(bool); rawQuery {
+		query, _ := target["query"].(string)
+		return query
+	}
+
+	measurement, _ := target["measurement"].(string)
+	if measurement == "" {
+		return ""
+	}
+
+	from := quoteIdentifier(measurement)
+	if regexValue.MatchString(measurement) {
+		from = measurement
+	}
+
+	if policy, _ := target["policy"].(string); policy != "" && policy != "default" {
+		from = quoteIdentifier(policy) + "." + from
+	}
+
+	query := "SELECT " + builderSelect(target["select"]) + " FROM " + from + " WHERE " + builderWhere(target["tags"])
+	groupBy, fill := builderGroupBy(target["groupBy"])
+	if groupBy != "" {
+		query += " GROUP BY " + groupBy
+	}
+
+	query += fill
+	if target["orderByTime"] == "DESC" {
+		query += " ORDER BY time DESC"
+	}
+
+	for _, clause := range []string{"limit", "slimit"} {
+		if value := fmt.Sprint(target[clause]); target[clause] != nil && value != "" {
+			query += " " + strings.ToUpper(clause) + " " + value
+		}
+	}
+
+	if tz, _ := target["tz"].(string); tz != "" {
+		query += " tz('" + tz + "')"
+	}
+
+	return query
+}
+
+// builderSelect renders the fields of the query builder, each one is a list of parts applied in order
+func builderSelect(selects interface{}) string {
+	list, _ := selects.([]interface{})
+	fields := []string{}
+	for _, parts := range list {
+		parts, _ := parts.([]interface{})
+		field := ""
+		for _, part := range parts {
+			partType, params := builderPart(part)
+			switch partType {
+			case "field":
+				field = quoteIdentifier(firstParam(params))
+			case "alias":
+				field += " AS " + quoteIdentifier(firstParam(params))
+			case "math":
+				field += " " + firstParam(params)
+			default:
+				field = partType + "(" + strings.Join(append([]string{field}, params...), ", ") + ")"
+			}
+		}
+
+		fields = append(fields, field)
+	}
+
+	if len(fields) == 0 {
+		return `mean("value")`
+	}
+
+	return strings.Join(fields, ", ")
+}
+
+// builderWhere renders the tag conditions of the query builder, ANDed with the time filter of the dashboard
+func builderWhere(tags interface{}) string {
+	list, _ := tags.([]interface{})
+	conditions := []string{}
+	for i, tag := range list {
+		tag, ok := tag.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		key, _ := tag["key"].(string)
+		value, _ := tag["value"].(string)
+		operator, _ := tag["operator"].(string)
+		if operator == "" {
+			operator = "="
+			if regexValue.MatchString(value) {
+				operator = "=~"
+			}
+		}
+
+		if operator != "=~" && operator != "!~" && operator != "<" && operator != ">" {
+			value = "'" + strings.Replace(strings.Replace(value, `\`, `\\`, -1), "'", `\'`, -1) + "'"
+		}
+
+		condition := quoteIdentifier(key) + " " + operator + " " + value
+		if connective, _ := tag["condition"].(string); i > 0 && connective != "" {
+			condition = connective + " " + condition
+		}
+
+		conditions = append(conditions, condition)
+	}
+
+	if len(conditions) == 0 {
+		return "$timeFilter"
+	}
+
+	return "(" + strings.Join(conditions, " ") + ") AND $timeFilter"
+}
+
+// builderGroupBy renders the GROUP BY dimensions of the query builder and its fill() clause
+func builderGroupBy(groupBy interface{}) (string, string) {
+	list, _ := groupBy.([]interface{})
+	dimensions := []string{}
+	fill := ""
+	for _, part := range list {
+		partType, params := builderPart(part)
+		switch partType {
+		case "time":
+			interval := firstParam(params)
+			if interval == "auto" || interval == "" {
+				interval = "$__interval"
+			}
+
+			dimensions = append(dimensions, "time("+interval+")")
+		case "tag":
+			dimensions = append(dimensions, quoteIdentifier(firstParam(params)))
+		case "fill":
+			fill = " fill(" + firstParam(params) + ")"
+		}
+	}
+
+	return strings.Join(dimensions, ", "), fill
+}
+
+func builderPart(part interface{}) (string, []string) {
+	partMap, _ := part.(map[string]interface{})
+	partType, _ := partMap["type"].(string)
+	rawParams, _ := partMap["params"].([]interface{})
+	params := make([]string, len(rawParams))
+	for i, param := range rawParams {
+		params[i] = fmt.Sprint(param)
+	}
+
+	return partType, params
+}
+
+func firstParam(params []string) string {
+	if len(params) == 0 {
+		return ""
+	}
+
+	return params[0]
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `\"`, -1) + `"`
+}
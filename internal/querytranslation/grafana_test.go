@@ -0,0 +1,163 @@
+package querytranslation
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testDashboard = `{
+  "__inputs": [{"name": "DS_INFLUX", "pluginId": "influxdb"}],
+  "panels": [
+    {
+      "title": "cpu",
+      "datasource": "${DS_INFLUX}",
+      "targets": [
+        {
+          "refId": "A",
+          "measurement": "cpu",
+          "policy": "default",
+          "resultFormat": "time_series",
+          "select": [[{"type": "field", "params": ["usage"]}, {"type": "mean", "params": []}]],
+          "tags": [{"key": "host", "operator": "=~", "value": "/^$host$/"}],
+          "groupBy": [{"type": "time", "params": ["$__interval"]}, {"type": "fill", "params": ["null"]}]
+        },
+        {
+          "refId": "B",
+          "rawQuery": true,
+          "hide": true,
+          "resultFormat": "table",
+          "query": "SELECT last(\"usage\") FROM \"cpu\" WHERE $timeFilter GROUP BY \"host\" LIMIT 1"
+        }
+      ]
+    },
+    {
+      "title": "derivative",
+      "datasource": {"type": "influxdb", "uid": "influx"},
+      "targets": [
+        {"refId": "A", "rawQuery": true, "query": "SELECT mean(usage) FROM cpu WHERE $timeFilter GROUP BY time(1m)"},
+        {"refId": "B", "rawQuery": true, "query": "SHOW MEASUREMENTS"}
+      ]
+    },
+    {
+      "title": "row",
+      "type": "row",
+      "panels": [
+        {
+          "title": "memory",
+          "datasource": {"type": "influxdb", "uid": "influx"},
+          "targets": [{"refId": "A", "rawQuery": true, "query": "SELECT used FROM mem WHERE $timeFilter"}]
+        }
+      ]
+    },
+    {
+      "title": "other",
+      "datasource": {"type": "prometheus", "uid": "prom"},
+      "targets": [{"refId": "A", "expr": "up"}]
+    }
+  ],
+  "templating": {
+    "list": [
+      {"name": "host", "type": "query", "datasource": "${DS_INFLUX}", "query": "SHOW TAG VALUES FROM cpu WITH KEY = \"host\""},
+      {"name": "db", "type": "query", "datasource": "${DS_INFLUX}", "query": "SHOW DATABASES"},
+      {"name": "range", "type": "interval", "query": "1m,5m"}
+    ]
+  }
+}`
+
+func TestTranslateDashboard(t *testing.T) {
+	translated, report, err := TranslateDashboard([]byte(testDashboard), &Layout{}, "pg")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, report.Translated)
+	assert.Equal(t, []string{
+		"panel 'cpu', query B: LIMIT and OFFSET apply to each series in InfluxQL and are skipped for queries grouped by tags",
+		"panel 'derivative', query B: the query can't be translated: only SELECT and SHOW TAG VALUES statements can be translated",
+		"panel 'derivative' is not rewritten",
+		"variable 'db': the query can't be translated: only SELECT and SHOW TAG VALUES statements can be translated",
+	}, report.Flagged)
+
+	var dashboard map[string]interface{}
+	assert.NoError(t, json.Unmarshal(translated, &dashboard))
+	panels := dashboard["panels"].([]interface{})
+
+	cpu := panels[0].(map[string]interface{})
+	assert.Equal(t, "pg", cpu["datasource"])
+	targets := cpu["targets"].([]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"refId":      "A",
+		"rawQuery":   true,
+		"editorMode": "code",
+		"format":     "time_series",
+		"rawSql": `SELECT time_bucket_gapfill(INTERVAL '$__interval', "time") AS "time", avg("usage") AS "mean"` + "\n" +
+			`FROM "cpu"` + "\n" +
+			`WHERE ("host" ~ '^${host:regex}$') AND $__timeFilter("time")` + "\n" +
+			`GROUP BY time_bucket_gapfill(INTERVAL '$__interval', "time")` + "\n" +
+			`ORDER BY "time"`,
+	}, targets[0])
+	second := targets[1].(map[string]interface{})
+	assert.Equal(t, "table", second["format"])
+	assert.Equal(t, true, second["hide"])
+	assert.Contains(t, second["rawSql"], "-- unsupported: LIMIT and OFFSET")
+
+	derivative := panels[1].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "influxdb", "uid": "influx"}, derivative["datasource"])
+	assert.Equal(t, "SHOW MEASUREMENTS", derivative["targets"].([]interface{})[1].(map[string]interface{})["query"])
+
+	memory := panels[2].(map[string]interface{})["panels"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "postgres", "uid": "pg"}, memory["datasource"])
+
+	other := panels[3].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"refId": "A", "expr": "up"}, other["targets"].([]interface{})[0])
+
+	variables := dashboard["templating"].(map[string]interface{})["list"].([]interface{})
+	host := variables[0].(map[string]interface{})
+	assert.Equal(t, "pg", host["datasource"])
+	assert.Equal(t, "SELECT DISTINCT \"host\" AS \"value\"\nFROM \"cpu\"\nORDER BY 1", host["query"])
+	assert.Equal(t, "SHOW DATABASES", variables[1].(map[string]interface{})["query"])
+}
+
+func TestTranslateWrappedDashboard(t *testing.T) {
+	content := `{"meta": {}, "dashboard": {"rows": [{"panels": [{"title": "p", "targets": [
+		{"refId": "A", "measurement": "cpu", "alias": "$tag_host", "select": [[{"type": "field", "params": ["usage"]}]]}
+	]}]}]}}`
+	translated, report, err := TranslateDashboard([]byte(content), &Layout{}, "pg")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Translated)
+	assert.Equal(t, []string{"panel 'p', query A: alias '$tag_host' is not translated"}, report.Flagged)
+	assert.Contains(t, string(translated), `"rawSql": "-- unsupported: alias '$tag_host' is not translated\nSELECT \"time\", \"usage\"\nFROM \"cpu\"\nWHERE $__timeFilter(\"time\")\nORDER BY \"time\""`)
+
+	_, _, err = TranslateDashboard([]byte("{"), &Layout{}, "pg")
+	assert.Error(t, err)
+}
+
+func TestInfluxQuery(t *testing.T) {
+	target := map[string]interface{}{
+		"measurement": "cpu",
+		"policy":      "autogen",
+		"select": []interface{}{[]interface{}{
+			map[string]interface{}{"type": "field", "params": []interface{}{"usage"}},
+			map[string]interface{}{"type": "max", "params": []interface{}{}},
+			map[string]interface{}{"type": "math", "params": []interface{}{"* 100"}},
+			map[string]interface{}{"type": "alias", "params": []interface{}{"pct"}},
+		}},
+		"tags": []interface{}{
+			map[string]interface{}{"key": "host", "operator": "=", "value": "a'b"},
+			map[string]interface{}{"key": "region", "condition": "OR", "value": "/eu/"},
+		},
+		"groupBy": []interface{}{
+			map[string]interface{}{"type": "time", "params": []interface{}{"auto"}},
+			map[string]interface{}{"type": "tag", "params": []interface{}{"host"}},
+			map[string]interface{}{"type": "fill", "params": []interface{}{"0"}},
+		},
+		"orderByTime": "DESC",
+		"limit":       "10",
+	}
+
+	assert.Equal(t,
+		`SELECT max("usage") * 100 AS "pct" FROM "autogen"."cpu" WHERE ("host" = 'a\'b' OR "region" =~ /eu/) AND $timeFilter `+
+			`GROUP BY time($__interval), "host" fill(0) ORDER BY time DESC LIMIT 10`,
+		influxQuery(target))
+	assert.Equal(t, "SELECT x FROM y", influxQuery(map[string]interface{}{"rawQuery": true, "query": "SELECT x FROM y"}))
+	assert.Equal(t, "", influxQuery(map[string]interface{}{}))
+}
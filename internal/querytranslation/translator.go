@@ -0,0 +1,443 @@
+// Package querytranslation translates InfluxQL queries into SQL queries over the tables created by Outflux
+// for the migrated measurements, and rewrites the InfluxDB panels of Grafana dashboards
+package querytranslation
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxql"
+	"github.com/jackc/pgx"
+)
+
+const (
+	timeColumn            = "time"
+	selectTemplate        = "SELECT %s\nFROM %s"
+	showTagValuesTemplate = "SELECT DISTINCT %s AS %s\nFROM %s"
+	whereTemplate         = "\nWHERE %s"
+	groupByTemplate       = "\nGROUP BY %s"
+	orderByTemplate       = "\nORDER BY %s"
+	limitTemplate         = "\nLIMIT %d"
+	offsetTemplate        = "\nOFFSET %d"
+	timeBucketTemplate    = "time_bucket(%s, %s)"
+	timeBucketWithOffset  = "time_bucket(%s, %s, %s)"
+	gapfillFunction       = "time_bucket_gapfill"
+	gapfillTemplate       = gapfillFunction + "(%s, %s)"
+	intervalTemplate      = "INTERVAL '%d %s'"
+	aliasTemplate         = "%s AS %s"
+)
+
+// Layout describes how the measurements were laid out in the output database by Outflux
+type Layout struct {
+	// Schema holds the tables of the measurements, empty for the search path of the connection
+	Schema string
+	// TagsColumn is the JSONB column of the tags when they were migrated with --tags-as-json, empty otherwise
+	TagsColumn string
+	// FieldsColumn is the JSONB column of the fields when they were migrated with --fields-as-json, empty otherwise
+	FieldsColumn string
+}
+
+// Translation holds the SQL query of an InfluxQL statement, and the parts of the statement
+// that could not be translated. If the statement can't be translated at all, the SQL is empty
+type Translation struct {
+	InfluxQL    string
+	SQL         string
+	Unsupported []string
+}
+
+// Translate translates each statement of an InfluxQL query into a SQL query. SELECT statements
+// are translated using time_bucket for GROUP BY time() and time_bucket_gapfill for fill(). SHOW TAG VALUES
+// statements with a single key become a SELECT DISTINCT. Grafana variables like $timeFilter and $__interval
+// are replaced with the macros of the PostgreSQL data source
+func Translate(query string, layout *Layout) ([]*Translation, error) {
+	parser := influxql.NewParser(strings.NewReader(normalizeVariables(query)))
+	parser.SetParams(variableParams(query))
+	parsed, err := parser.ParseQuery()
+	if err != nil {
+		return nil, fmt.Errorf("could not parse query '%s'\n%v", query, err)
+	}
+
+	translations := make([]*Translation, len(parsed.Statements))
+	for i, statement := range parsed.Statements {
+		translator := &translator{layout: layout}
+		translation := &Translation{InfluxQL: restoreVariables(statement.String())}
+		switch statement := statement.(type) {
+		case *influxql.SelectStatement:
+			translation.SQL = translator.translateSelect(statement)
+		case *influxql.ShowTagValuesStatement:
+			translation.SQL = translator.translateShowTagValues(statement)
+		default:
+			translator.unsupport("only SELECT and SHOW TAG VALUES statements can be translated")
+		}
+
+		translation.Unsupported = translator.unsupported
+		translations[i] = translation
+	}
+
+	return translations, nil
+}
+
+// translator keeps the parts of a single statement that could not be translated
+type translator struct {
+	layout      *Layout
+	unsupported []string
+}
+
+func (t *translator) unsupport(format string, args ...interface{}) {
+	t.unsupported = append(t.unsupported, fmt.Sprintf(format, args...))
+}
+
+// timeBounds records if the WHERE clause bounds the time, needed to fill the empty buckets
+type timeBounds struct {
+	lower bool
+	upper bool
+}
+
+func (t *translator) translateSelect(statement *influxql.SelectStatement) string {
+	measurement, ok := t.checkStructure(statement)
+	if !ok {
+		return ""
+	}
+
+	bounds := &timeBounds{}
+	conditions := []string{}
+	if statement.Condition != nil {
+		condition, err := t.condition(statement.Condition, bounds)
+		if err != nil {
+			t.unsupport("%v", err)
+			return ""
+		}
+
+		conditions = append(conditions, condition)
+	}
+
+	aggregated := isAggregated(statement)
+	bucket, tags, ok := t.dimensions(statement, aggregated, bounds, &conditions)
+	if !ok {
+		return ""
+	}
+
+	projection := []string{}
+	grouping := []string{}
+	if bucket != "" {
+		projection = append(projection, fmt.Sprintf(aliasTemplate, bucket, quote(timeColumn)))
+		grouping = append(grouping, bucket)
+	} else if !aggregated && !hasWildcard(statement) {
+		projection = append(projection, quote(timeColumn))
+	}
+
+	for _, tag := range tags {
+		projection = append(projection, t.aliased(t.tagRef(tag), tag))
+		if aggregated {
+			grouping = append(grouping, t.tagRef(tag))
+		}
+	}
+
+	gapfilled := strings.HasPrefix(bucket, gapfillFunction+"(")
+	fields := t.fields(statement, aggregated, gapfilled)
+	if len(fields) == 0 {
+		t.unsupport("no field can be translated")
+		return ""
+	}
+
+	projection = append(projection, fields...)
+	sql := fmt.Sprintf(selectTemplate, strings.Join(projection, ", "), qualifiedName(t.layout.Schema, measurement))
+	if len(conditions) > 0 {
+		sql += fmt.Sprintf(whereTemplate, strings.Join(conditions, " AND "))
+	}
+
+	if len(grouping) > 0 {
+		sql += fmt.Sprintf(groupByTemplate, strings.Join(grouping, ", "))
+	}
+
+	if bucket != "" || !aggregated {
+		order := quote(timeColumn)
+		if len(statement.SortFields) > 0 && !statement.SortFields[0].Ascending {
+			order += " DESC"
+		}
+
+		sql += fmt.Sprintf(orderByTemplate, order)
+	}
+
+	return sql + t.limit(statement.Limit, statement.Offset, len(tags) > 0)
+}
+
+// checkStructure returns the measurement of a statement selecting from a single measurement
+func (t *translator) checkStructure(statement *influxql.SelectStatement) (string, bool) {
+	if statement.Target != nil {
+		t.unsupport("INTO clauses are not supported")
+		return "", false
+	}
+
+	if len(statement.Sources) != 1 {
+		t.unsupport("only queries with a single source measurement are supported")
+		return "", false
+	}
+
+	measurement, ok := statement.Sources[0].(*influxql.Measurement)
+	if !ok || measurement.Name == "" || measurement.Regex != nil {
+		t.unsupport("the source must be a measurement, regular expressions and subqueries are not supported")
+		return "", false
+	}
+
+	if statement.SLimit > 0 || statement.SOffset > 0 {
+		t.unsupport("SLIMIT and SOFFSET are not supported")
+		return "", false
+	}
+
+	if statement.Location != nil {
+		t.unsupport("tz('%s') is not supported, the buckets are aligned to UTC", statement.Location)
+	}
+
+	return measurement.Name, true
+}
+
+// dimensions returns the time bucket and the tags of the GROUP BY clause. The bucket fills the
+// empty buckets when the time is bounded, and the upper bound defaults to now() as in InfluxDB
+func (t *translator) dimensions(statement *influxql.SelectStatement, aggregated bool, bounds *timeBounds, conditions *[]string) (string, []string, bool) {
+	bucket := ""
+	tags := []string{}
+	for _, dimension := range statement.Dimensions {
+		switch expr := dimension.Expr.(type) {
+		case *influxql.Call:
+			if expr.Name != "time" || len(expr.Args) == 0 || len(expr.Args) > 2 || !aggregated {
+				t.unsupport("GROUP BY %s is not supported", dimension)
+				return "", nil, false
+			}
+
+			interval, ok := bucketInterval(expr.Args[0])
+			if !ok {
+				t.unsupport("the interval of GROUP BY %s is not supported", dimension)
+				return "", nil, false
+			}
+
+			bucket = t.bucket(statement.Fill, interval, expr.Args[1:], bounds, conditions)
+		case *influxql.VarRef:
+			tags = append(tags, expr.Val)
+		default:
+			t.unsupport("GROUP BY %s is not supported, tags must be listed by name", dimension)
+			return "", nil, false
+		}
+	}
+
+	return bucket, tags, true
+}
+
+func (t *translator) bucket(fill influxql.FillOption, interval string, offset []influxql.Expr, bounds *timeBounds, conditions *[]string) string {
+	if len(offset) == 1 {
+		duration, ok := offset[0].(*influxql.DurationLiteral)
+		if !ok {
+			t.unsupport("the offset of GROUP BY time() must be a duration")
+			return fmt.Sprintf(timeBucketTemplate, interval, quote(timeColumn))
+		}
+
+		if fill != influxql.NoFill {
+			t.unsupport("fill() is not supported with an offset, empty buckets are skipped")
+		}
+
+		return fmt.Sprintf(timeBucketWithOffset, interval, quote(timeColumn), FormatInterval(duration.Val))
+	}
+
+	if fill == influxql.NoFill {
+		return fmt.Sprintf(timeBucketTemplate, interval, quote(timeColumn))
+	}
+
+	if !bounds.lower {
+		// without a time range there are no empty buckets to fill with nulls
+		if fill == influxql.NullFill {
+			return fmt.Sprintf(timeBucketTemplate, interval, quote(timeColumn))
+		}
+
+		t.unsupport("fill() needs a lower bound of the time in the WHERE clause, empty buckets are skipped")
+		return fmt.Sprintf(timeBucketTemplate, interval, quote(timeColumn))
+	}
+
+	if !bounds.upper {
+		*conditions = append(*conditions, fmt.Sprintf("%s <= now()", quote(timeColumn)))
+	}
+
+	return fmt.Sprintf(gapfillTemplate, interval, quote(timeColumn))
+}
+
+// fields returns the projection of the fields, aliased to the column names of the InfluxQL result
+func (t *translator) fields(statement *influxql.SelectStatement, aggregated, gapfilled bool) []string {
+	if hasWildcard(statement) {
+		if aggregated {
+			t.unsupport("wildcards are not supported in aggregated queries, fields must be listed by name")
+			return nil
+		}
+
+		return []string{"*"}
+	}
+
+	columnNames := statement.ColumnNames()
+	columnNames = columnNames[len(columnNames)-len(statement.Fields):]
+	fields := []string{}
+	for i, field := range statement.Fields {
+		if aggregated && !containsCall(field.Expr) {
+			t.unsupport("field '%s' is not aggregated in an aggregated query and is skipped", field)
+			continue
+		}
+
+		expression, err := t.value(field.Expr)
+		if err != nil {
+			t.unsupport("%v, field '%s' is skipped", err, field)
+			continue
+		}
+
+		if gapfilled {
+			expression = fillValue(statement.Fill, statement.FillValue, expression)
+		}
+
+		fields = append(fields, t.aliased(expression, columnNames[i]))
+	}
+
+	return fields
+}
+
+// limit returns the LIMIT and OFFSET clauses. InfluxQL limits each series, so the
+// limits of queries grouped by tags can't be translated
+func (t *translator) limit(limit, offset int, groupedByTags bool) string {
+	if limit == 0 && offset == 0 {
+		return ""
+	}
+
+	if groupedByTags {
+		t.unsupport("LIMIT and OFFSET apply to each series in InfluxQL and are skipped for queries grouped by tags")
+		return ""
+	}
+
+	clauses := ""
+	if limit > 0 {
+		clauses += fmt.Sprintf(limitTemplate, limit)
+	}
+
+	if offset > 0 {
+		clauses += fmt.Sprintf(offsetTemplate, offset)
+	}
+
+	return clauses
+}
+
+func (t *translator) translateShowTagValues(statement *influxql.ShowTagValuesStatement) string {
+	if len(statement.Sources) != 1 {
+		t.unsupport("only SHOW TAG VALUES of a single measurement is supported")
+		return ""
+	}
+
+	measurement, ok := statement.Sources[0].(*influxql.Measurement)
+	if !ok || measurement.Name == "" || measurement.Regex != nil {
+		t.unsupport("the source must be a measurement, regular expressions are not supported")
+		return ""
+	}
+
+	key, ok := statement.TagKeyExpr.(*influxql.StringLiteral)
+	if statement.Op != influxql.EQ || !ok {
+		t.unsupport("only a single tag key is supported, as in WITH KEY = \"key\"")
+		return ""
+	}
+
+	sql := fmt.Sprintf(showTagValuesTemplate, t.tagRef(key.Val), quote("value"), qualifiedName(t.layout.Schema, measurement.Name))
+	if statement.Condition != nil {
+		condition, err := t.condition(statement.Condition, &timeBounds{})
+		if err != nil {
+			t.unsupport("%v", err)
+			return ""
+		}
+
+		sql += fmt.Sprintf(whereTemplate, condition)
+	}
+
+	sql += fmt.Sprintf(orderByTemplate, "1")
+	return sql + t.limit(statement.Limit, statement.Offset, false)
+}
+
+// aliased aliases the expression unless it is already the quoted name
+func (t *translator) aliased(expression, name string) string {
+	if expression == quote(name) {
+		return expression
+	}
+
+	return fmt.Sprintf(aliasTemplate, expression, quote(name))
+}
+
+// isAggregated returns true if a field of the statement calls a function, or the statement is grouped by time
+func isAggregated(statement *influxql.SelectStatement) bool {
+	for _, field := range statement.Fields {
+		if containsCall(field.Expr) {
+			return true
+		}
+	}
+
+	for _, dimension := range statement.Dimensions {
+		if call, ok := dimension.Expr.(*influxql.Call); ok && call.Name == "time" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsCall(expr influxql.Expr) bool {
+	found := false
+	influxql.WalkFunc(expr, func(node influxql.Node) {
+		if _, ok := node.(*influxql.Call); ok {
+			found = true
+		}
+	})
+
+	return found
+}
+
+func hasWildcard(statement *influxql.SelectStatement) bool {
+	for _, field := range statement.Fields {
+		if _, ok := field.Expr.(*influxql.Wildcard); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FormatInterval formats a duration as a PostgreSQL interval, in the largest unit that divides it.
+// PostgreSQL intervals have a microsecond precision, so nanoseconds are truncated
+func FormatInterval(d time.Duration) string {
+	units := []struct {
+		size time.Duration
+		name string
+	}{
+		{24 * time.Hour, "day"}, {time.Hour, "hour"}, {time.Minute, "minute"},
+		{time.Second, "second"}, {time.Millisecond, "millisecond"}, {time.Microsecond, "microsecond"},
+	}
+
+	for _, unit := range units {
+		if d%unit.size == 0 {
+			count := int64(d / unit.size)
+			name := unit.name
+			if count != 1 {
+				name += "s"
+			}
+
+			return fmt.Sprintf(intervalTemplate, count, name)
+		}
+	}
+
+	return FormatInterval(d.Truncate(time.Microsecond))
+}
+
+func qualifiedName(schema, name string) string {
+	if schema == "" {
+		return quote(name)
+	}
+
+	return pgx.Identifier{schema, name}.Sanitize()
+}
+
+func quote(name string) string {
+	return pgx.Identifier{name}.Sanitize()
+}
+
+func quoteLiteral(value string) string {
+	return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
@@ -0,0 +1,138 @@
+package querytranslation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslate(t *testing.T) {
+	jsonLayout := &Layout{Schema: "s", TagsColumn: "tags", FieldsColumn: "fields"}
+	testCases := []struct {
+		desc        string
+		query       string
+		layout      *Layout
+		sql         string
+		unsupported []string
+	}{
+		{
+			desc:  "raw points of tags and fields",
+			query: `SELECT usage, host::tag FROM cpu WHERE host = 'a' AND region = '' AND usage > 0.5 ORDER BY time DESC LIMIT 10`,
+			sql: `SELECT "time", "usage", "host"` + "\n" +
+				`FROM "cpu"` + "\n" +
+				`WHERE "host" = 'a' AND "region" IS NULL AND "usage" > 0.500` + "\n" +
+				`ORDER BY "time" DESC` + "\n" +
+				`LIMIT 10`,
+		}, {
+			desc:   "json columns",
+			query:  `SELECT usage, host::tag FROM cpu WHERE host = 'a' AND up = true`,
+			layout: jsonLayout,
+			sql: `SELECT "time", ("fields"->>'usage')::double precision AS "usage", "tags"->>'host' AS "host"` + "\n" +
+				`FROM "s"."cpu"` + "\n" +
+				`WHERE "tags"->>'host' = 'a' AND ("fields"->>'up')::boolean = true` + "\n" +
+				`ORDER BY "time"`,
+		}, {
+			desc:  "aggregates filled with the previous value",
+			query: `SELECT mean(usage) * 100 AS pct, max(usage), percentile(usage, 95) FROM cpu WHERE time > now() - 1h AND (host = 'a' OR host = 'b') GROUP BY time(5m), host fill(previous)`,
+			sql: `SELECT time_bucket_gapfill(INTERVAL '5 minutes', "time") AS "time", "host", locf(avg("usage") * 100) AS "pct", locf(max("usage")) AS "max", locf(percentile_cont(0.95) WITHIN GROUP (ORDER BY "usage")) AS "percentile"` + "\n" +
+				`FROM "cpu"` + "\n" +
+				`WHERE "time" > now() - INTERVAL '1 hour' AND ("host" = 'a' OR "host" = 'b') AND "time" <= now()` + "\n" +
+				`GROUP BY time_bucket_gapfill(INTERVAL '5 minutes', "time"), "host"` + "\n" +
+				`ORDER BY "time"`,
+		}, {
+			desc:   "json aggregates with an offset and epoch times",
+			query:  `SELECT count(distinct(host)), last(usage) FROM cpu WHERE time >= 1546300800000ms AND time < 1546304400000000000 GROUP BY time(1h, 15m) fill(none)`,
+			layout: jsonLayout,
+			sql: `SELECT time_bucket(INTERVAL '1 hour', "time", INTERVAL '15 minutes') AS "time", count(DISTINCT "fields"->>'host') AS "count", last(("fields"->>'usage')::double precision, "time") AS "last"` + "\n" +
+				`FROM "s"."cpu"` + "\n" +
+				`WHERE "time" >= to_timestamp(1546300800000000000 / 1e9) AND "time" < to_timestamp(1546304400000000000 / 1e9)` + "\n" +
+				`GROUP BY time_bucket(INTERVAL '1 hour', "time", INTERVAL '15 minutes')` + "\n" +
+				`ORDER BY "time"`,
+		}, {
+			desc:  "grafana variables",
+			query: `SELECT mean("usage") FROM "cpu" WHERE "host" =~ /^$host$/ AND "dc" = '${dc}' AND $timeFilter GROUP BY time($__interval), "host" fill(0)`,
+			sql: `SELECT time_bucket_gapfill(INTERVAL '$__interval', "time") AS "time", "host", COALESCE(avg("usage"), 0) AS "mean"` + "\n" +
+				`FROM "cpu"` + "\n" +
+				`WHERE "host" ~ '^${host:regex}$' AND "dc" = ${dc:singlequote} AND $__timeFilter("time")` + "\n" +
+				`GROUP BY time_bucket_gapfill(INTERVAL '$__interval', "time"), "host"` + "\n" +
+				`ORDER BY "time"`,
+		}, {
+			desc:  "fill without a time range",
+			query: `SELECT mean(usage) FROM cpu GROUP BY time(1m) fill(linear)`,
+			sql: `SELECT time_bucket(INTERVAL '1 minute', "time") AS "time", avg("usage") AS "mean"` + "\n" +
+				`FROM "cpu"` + "\n" +
+				`GROUP BY time_bucket(INTERVAL '1 minute', "time")` + "\n" +
+				`ORDER BY "time"`,
+			unsupported: []string{"fill() needs a lower bound of the time in the WHERE clause, empty buckets are skipped"},
+		}, {
+			desc:  "unsupported field and per series limit",
+			query: `SELECT mean(usage), derivative(mean(usage), 1s) FROM cpu GROUP BY host LIMIT 5`,
+			sql: `SELECT "host", avg("usage") AS "mean"` + "\n" +
+				`FROM "cpu"` + "\n" +
+				`GROUP BY "host"`,
+			unsupported: []string{
+				"function 'derivative' is not supported, field 'derivative(mean(usage), 1s)' is skipped",
+				"LIMIT and OFFSET apply to each series in InfluxQL and are skipped for queries grouped by tags",
+			},
+		}, {
+			desc:   "tag values",
+			query:  `SHOW TAG VALUES FROM cpu WITH KEY = "host" WHERE region = 'eu'`,
+			layout: jsonLayout,
+			sql: `SELECT DISTINCT "tags"->>'host' AS "value"` + "\n" +
+				`FROM "s"."cpu"` + "\n" +
+				`WHERE "tags"->>'region' = 'eu'` + "\n" +
+				`ORDER BY 1`,
+		}, {
+			desc:        "several sources",
+			query:       `SELECT usage FROM cpu, mem`,
+			unsupported: []string{"only queries with a single source measurement are supported"},
+		}, {
+			desc:        "all tags",
+			query:       `SELECT mean(usage) FROM cpu GROUP BY time(1m), *`,
+			unsupported: []string{"GROUP BY * is not supported, tags must be listed by name"},
+		}, {
+			desc:        "aggregated wildcard",
+			query:       `SELECT mean(*) FROM cpu WHERE time > now() - 1h GROUP BY time(1m)`,
+			unsupported: []string{"the argument of 'mean(*)' must be a field, field 'mean(*)' is skipped", "no field can be translated"},
+		}, {
+			desc:        "other statements",
+			query:       `SHOW MEASUREMENTS`,
+			unsupported: []string{"only SELECT and SHOW TAG VALUES statements can be translated"},
+		},
+	}
+
+	for _, tc := range testCases {
+		layout := tc.layout
+		if layout == nil {
+			layout = &Layout{}
+		}
+
+		translations, err := Translate(tc.query, layout)
+		if !assert.NoError(t, err, tc.desc) || !assert.Equal(t, 1, len(translations), tc.desc) {
+			continue
+		}
+
+		assert.Equal(t, tc.sql, translations[0].SQL, tc.desc)
+		assert.Equal(t, tc.unsupported, translations[0].Unsupported, tc.desc)
+	}
+}
+
+func TestTranslateSeveralStatements(t *testing.T) {
+	translations, err := Translate(`SELECT a FROM m WHERE $timeFilter; SELECT b FROM n`, &Layout{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(translations))
+	assert.Equal(t, `SELECT a FROM m WHERE $timeFilter`, translations[0].InfluxQL)
+	assert.Equal(t, `SELECT b FROM n`, translations[1].InfluxQL)
+
+	_, err = Translate(`SELECT FROM`, &Layout{})
+	assert.Error(t, err)
+}
+
+func TestFormatInterval(t *testing.T) {
+	assert.Equal(t, "INTERVAL '2 days'", FormatInterval(48*time.Hour))
+	assert.Equal(t, "INTERVAL '90 minutes'", FormatInterval(90*time.Minute))
+	assert.Equal(t, "INTERVAL '1 second'", FormatInterval(time.Second))
+	assert.Equal(t, "INTERVAL '1500 milliseconds'", FormatInterval(1500*time.Millisecond))
+	assert.Equal(t, "INTERVAL '1 microsecond'", FormatInterval(time.Microsecond+time.Nanosecond))
+}
@@ -0,0 +1,70 @@
+package querytranslation
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/influxdata/influxql"
+)
+
+// Grafana replaces these variables of InfluxDB queries with a time condition and the interval of the panel
+const (
+	timeFilterVariable      = "timeFilter"
+	intervalVariable        = "interval"
+	grafanaIntervalVariable = "__interval"
+	// variableMarker starts the value bound to a variable, no string of a query can contain it
+	variableMarker = "\x00"
+)
+
+var (
+	bracedVariable  = regexp.MustCompile(`\$\{(\w+)(:[^}]*)?\}`)
+	bracketVariable = regexp.MustCompile(`\[\[(\w+)(:[^\]]*)?\]\]`)
+	variable        = regexp.MustCompile(`\$(\w+)`)
+	boundVariable   = regexp.MustCompile("'" + variableMarker + `(\w+)'`)
+)
+
+// normalizeVariables rewrites the ${var} and [[var]] variables of Grafana as $var
+func normalizeVariables(query string) string {
+	query = bracedVariable.ReplaceAllString(query, "$$$1")
+	return bracketVariable.ReplaceAllString(query, "$$$1")
+}
+
+// variableParams binds each variable of the query to a marked string, so the variables can be
+// parsed as bound parameters and told apart from the strings of the query
+func variableParams(query string) map[string]interface{} {
+	params := make(map[string]interface{})
+	for _, match := range variable.FindAllStringSubmatch(normalizeVariables(query), -1) {
+		params[match[1]] = variableMarker + match[1]
+	}
+
+	return params
+}
+
+// restoreVariables replaces the strings bound to variables in a formatted statement with the variables
+func restoreVariables(statement string) string {
+	return boundVariable.ReplaceAllString(statement, "$$$1")
+}
+
+// variableName returns the name of the variable bound to a string literal
+func variableName(literal *influxql.StringLiteral) (string, bool) {
+	if !strings.HasPrefix(literal.Val, variableMarker) {
+		return "", false
+	}
+
+	return strings.TrimPrefix(literal.Val, variableMarker), true
+}
+
+// stringLiteral quotes a string of the query. A string that is a single variable is quoted by Grafana,
+// the variables inside of a string are replaced with their raw values
+func stringLiteral(value string) string {
+	if match := variable.FindStringSubmatch(value); match != nil && match[0] == value {
+		return "${" + match[1] + ":singlequote}"
+	}
+
+	return quoteLiteral(variable.ReplaceAllString(value, "$${$1:raw}"))
+}
+
+// regexVariables replaces the variables in a regular expression with their values formatted as a regular expression
+func regexVariables(regex string) string {
+	return variable.ReplaceAllString(regex, "$${$1:regex}")
+}
@@ -0,0 +1,51 @@
+package discovery
+
+import (
+	"fmt"
+
+	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/timescale/outflux/internal/schemamanagement/influx/influxqueries"
+)
+
+const (
+	showDatabasesQuery = "SHOW DATABASES"
+	// internalDatabase holds the statistics InfluxDB records about itself, it is never migrated
+	internalDatabase = "_internal"
+)
+
+// DatabaseExplorer defines an API for discovering the databases of an InfluxDB server
+type DatabaseExplorer interface {
+	FetchDatabases(influxClient influx.Client) ([]string, error)
+}
+
+type defaultDatabaseExplorer struct {
+	queryService influxqueries.InfluxQueryService
+}
+
+// NewDatabaseExplorer creates a new implementation that can discover the databases of a server
+func NewDatabaseExplorer(queryService influxqueries.InfluxQueryService) DatabaseExplorer {
+	return &defaultDatabaseExplorer{
+		queryService: queryService,
+	}
+}
+
+// FetchDatabases returns the names of the databases of the server, without the '_internal' database
+func (e *defaultDatabaseExplorer) FetchDatabases(influxClient influx.Client) ([]string, error) {
+	result, err := e.queryService.ExecuteShowQuery(influxClient, "", showDatabasesQuery)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %s\n%v", showDatabasesQuery, err)
+	}
+
+	databases := []string{}
+	for _, valuesRow := range result.Values {
+		if len(valuesRow) != 1 {
+			return nil, fmt.Errorf("'%s' returned unexpected result, database names not represented in single column", showDatabasesQuery)
+		}
+
+		if valuesRow[0] != internalDatabase {
+			databases = append(databases, valuesRow[0])
+		}
+	}
+
+	return databases, nil
+}
@@ -0,0 +1,49 @@
+package discovery
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/schemamanagement/influx/influxqueries"
+)
+
+func TestFetchDatabases(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		result    *influxqueries.InfluxShowResult
+		err       error
+		expected  []string
+		expectErr bool
+	}{
+		{
+			desc:      "query fails",
+			err:       fmt.Errorf("error"),
+			expectErr: true,
+		}, {
+			desc:      "more than one column",
+			result:    &influxqueries.InfluxShowResult{Values: [][]string{{"db", "db"}}},
+			expectErr: true,
+		}, {
+			desc:     "no databases",
+			result:   &influxqueries.InfluxShowResult{Values: [][]string{}},
+			expected: []string{},
+		}, {
+			desc:     "internal database is skipped",
+			result:   &influxqueries.InfluxShowResult{Values: [][]string{{"_internal"}, {"db1"}, {"db2"}}},
+			expected: []string{"db1", "db2"},
+		},
+	}
+
+	for _, tc := range testCases {
+		explorer := NewDatabaseExplorer(&mockAll{sqRes: tc.result, sqErr: tc.err})
+		databases, err := explorer.FetchDatabases(&influxqueries.MockClient{})
+		if tc.expectErr {
+			assert.Error(t, err, tc.desc)
+			continue
+		}
+
+		assert.NoError(t, err, tc.desc)
+		assert.Equal(t, tc.expected, databases, tc.desc)
+	}
+}
@@ -39,7 +39,7 @@ func (fe *defaultFieldExplorer) DiscoverMeasurementFields(influxClient influx.Cl
 		return nil, fmt.Errorf("error fetching fields for measurement '%s'\n%v", measurement, err)
 	}
 
-	return convertFields(fields, onConflictConvertIntToFloat)
+	return ConvertFields(fields, onConflictConvertIntToFloat)
 }
 
 func (fe *defaultFieldExplorer) fetchMeasurementFields(influxClient influx.Client, db, rp, measurement string) ([][2]string, error) {
@@ -72,7 +72,9 @@ func (fe *defaultFieldExplorer) fetchMeasurementFields(influxClient influx.Clien
 	return fieldKeys, nil
 }
 
-func convertFields(fieldsWithType [][2]string, convertInt64ToFloat64 bool) ([]*idrf.Column, error) {
+// ConvertFields creates the IDRF columns, sorted by name, for pairs of field name and InfluxDB type.
+// A field discovered with multiple types is converted as described in DiscoverMeasurementFields
+func ConvertFields(fieldsWithType [][2]string, convertInt64ToFloat64 bool) ([]*idrf.Column, error) {
 	columnMap, err := chooseDataTypeForFields(fieldsWithType, convertInt64ToFloat64)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,82 @@
+package discovery
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/timescale/outflux/internal/schemamanagement/influx/influxqueries"
+)
+
+const (
+	newestPointQueryTemplate = `SELECT * FROM "%s"."%s" ORDER BY time DESC LIMIT 1`
+	countPointsQueryTemplate = `SELECT COUNT(*) FROM "%s"."%s" WHERE time > '%s' AND time <= '%s'`
+)
+
+// PointDensity holds the number of points of a measurement in a sampled time window
+type PointDensity struct {
+	Points uint64
+	Window time.Duration
+}
+
+// PointsPerSecond returns the average rate of the points in the sampled window
+func (d *PointDensity) PointsPerSecond() float64 {
+	return float64(d.Points) / d.Window.Seconds()
+}
+
+// PointDensityExplorer defines an API for sampling how many points an InfluxDB measurement has over time
+type PointDensityExplorer interface {
+	SamplePointDensity(influxClient influx.Client, database, rp, measure string, window time.Duration) (*PointDensity, error)
+}
+
+type defaultPointDensityExplorer struct {
+	queryService influxqueries.InfluxQueryService
+}
+
+// NewPointDensityExplorer creates a new implementation that can sample the point density of a measurement
+func NewPointDensityExplorer(queryService influxqueries.InfluxQueryService) PointDensityExplorer {
+	return &defaultPointDensityExplorer{
+		queryService: queryService,
+	}
+}
+
+// SamplePointDensity counts the points of the measurement in the window ending with its newest point, so a
+// measurement that is no longer written to is sampled as well. Returns nil if the measurement has no points.
+// COUNT(*) counts each field, the points are as many as the values of the field counted the most
+func (e *defaultPointDensityExplorer) SamplePointDensity(influxClient influx.Client, database, rp, measure string, window time.Duration) (*PointDensity, error) {
+	newest, err := fetchPointTime(e.queryService, influxClient, database, fmt.Sprintf(newestPointQueryTemplate, rp, measure))
+	if err != nil || newest == nil {
+		return nil, err
+	}
+
+	oldest := newest.Add(-window)
+	countQuery := fmt.Sprintf(countPointsQueryTemplate, rp, measure, oldest.Format(time.RFC3339Nano), newest.Format(time.RFC3339Nano))
+	results, err := e.queryService.ExecuteQuery(influxClient, database, countQuery)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %s\n%v", countQuery, err)
+	}
+
+	if len(results) != 1 || len(results[0].Series) == 0 || len(results[0].Series[0].Values) != 1 {
+		return nil, fmt.Errorf("'%s' returned an unexpected result", countQuery)
+	}
+
+	density := &PointDensity{Window: window}
+	// the first value is the time of the count
+	for _, value := range results[0].Series[0].Values[0][1:] {
+		if value == nil {
+			continue
+		}
+
+		count, err := strconv.ParseUint(fmt.Sprint(value), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' returned a count that is not a number\n%v", countQuery, err)
+		}
+
+		if count > density.Points {
+			density.Points = count
+		}
+	}
+
+	return density, nil
+}
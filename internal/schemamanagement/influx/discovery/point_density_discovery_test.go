@@ -0,0 +1,85 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/influxdata/influxdb/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/schemamanagement/influx/influxqueries"
+)
+
+func TestSamplePointDensity(t *testing.T) {
+	newest := "2020-01-02T00:00:00Z"
+	newestQuery := `SELECT * FROM "autogen"."m" ORDER BY time DESC LIMIT 1`
+	countQuery := `SELECT COUNT(*) FROM "autogen"."m" WHERE time > '2020-01-01T00:00:00Z' AND time <= '2020-01-02T00:00:00Z'`
+	newestResult := []influx.Result{{Series: []models.Row{{Columns: []string{"time", "value"}, Values: [][]interface{}{{newest, 1}}}}}}
+	countResult := func(values ...interface{}) []influx.Result {
+		row := append([]interface{}{"2020-01-01T00:00:00Z"}, values...)
+		return []influx.Result{{Series: []models.Row{{Columns: []string{"time", "count_a", "count_b"}, Values: [][]interface{}{row}}}}}
+	}
+	testCases := []struct {
+		desc      string
+		results   map[string][]influx.Result
+		expected  *PointDensity
+		expectErr bool
+	}{
+		{
+			desc:      "query fails",
+			expectErr: true,
+		}, {
+			desc:    "no points",
+			results: map[string][]influx.Result{newestQuery: {{}}},
+		}, {
+			desc:      "count fails",
+			results:   map[string][]influx.Result{newestQuery: newestResult},
+			expectErr: true,
+		}, {
+			desc:      "count is not a number",
+			results:   map[string][]influx.Result{newestQuery: newestResult, countQuery: countResult("many", nil)},
+			expectErr: true,
+		}, {
+			desc:     "field counted the most",
+			results:  map[string][]influx.Result{newestQuery: newestResult, countQuery: countResult(json.Number("8640"), nil)},
+			expected: &PointDensity{Points: 8640, Window: 24 * time.Hour},
+		}, {
+			desc:     "several fields",
+			results:  map[string][]influx.Result{newestQuery: newestResult, countQuery: countResult(json.Number("10"), json.Number("86400"))},
+			expected: &PointDensity{Points: 86400, Window: 24 * time.Hour},
+		},
+	}
+
+	for _, tc := range testCases {
+		explorer := NewPointDensityExplorer(&mockDensityQueryService{results: tc.results})
+		density, err := explorer.SamplePointDensity(&influxqueries.MockClient{}, "db", "autogen", "m", 24*time.Hour)
+		if tc.expectErr {
+			assert.Error(t, err, tc.desc)
+			continue
+		}
+
+		assert.NoError(t, err, tc.desc)
+		assert.Equal(t, tc.expected, density, tc.desc)
+	}
+
+	density := &PointDensity{Points: 86400, Window: 24 * time.Hour}
+	assert.Equal(t, 1.0, density.PointsPerSecond())
+}
+
+type mockDensityQueryService struct {
+	results map[string][]influx.Result
+}
+
+func (m *mockDensityQueryService) ExecuteQuery(client influx.Client, database, command string) ([]influx.Result, error) {
+	if results, ok := m.results[command]; ok {
+		return results, nil
+	}
+
+	return nil, fmt.Errorf("unexpected query: %s", command)
+}
+
+func (m *mockDensityQueryService) ExecuteShowQuery(influxClient influx.Client, database, query string) (*influxqueries.InfluxShowResult, error) {
+	panic("should not come here")
+}
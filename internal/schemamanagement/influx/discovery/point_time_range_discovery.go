@@ -0,0 +1,75 @@
+package discovery
+
+import (
+	"fmt"
+	"time"
+
+	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/timescale/outflux/internal/schemamanagement/influx/influxqueries"
+)
+
+const (
+	oldestPointQueryTemplate = `SELECT * FROM "%s"."%s" ORDER BY time ASC LIMIT 1`
+)
+
+// PointTimeRange holds the times of the oldest and the newest point of a measurement
+type PointTimeRange struct {
+	Oldest time.Time
+	Newest time.Time
+}
+
+// PointTimeRangeExplorer defines an API for discovering the time range of the points of an InfluxDB measurement
+type PointTimeRangeExplorer interface {
+	FetchPointTimeRange(influxClient influx.Client, database, rp, measure string) (*PointTimeRange, error)
+}
+
+type defaultPointTimeRangeExplorer struct {
+	queryService influxqueries.InfluxQueryService
+}
+
+// NewPointTimeRangeExplorer creates a new implementation that can discover the time range of a measurement
+func NewPointTimeRangeExplorer(queryService influxqueries.InfluxQueryService) PointTimeRangeExplorer {
+	return &defaultPointTimeRangeExplorer{
+		queryService: queryService,
+	}
+}
+
+// FetchPointTimeRange returns the times of the oldest and the newest point of the measurement,
+// or nil if the measurement has no points
+func (e *defaultPointTimeRangeExplorer) FetchPointTimeRange(influxClient influx.Client, database, rp, measure string) (*PointTimeRange, error) {
+	oldest, err := fetchPointTime(e.queryService, influxClient, database, fmt.Sprintf(oldestPointQueryTemplate, rp, measure))
+	if err != nil || oldest == nil {
+		return nil, err
+	}
+
+	newest, err := fetchPointTime(e.queryService, influxClient, database, fmt.Sprintf(newestPointQueryTemplate, rp, measure))
+	if err != nil || newest == nil {
+		return nil, err
+	}
+
+	return &PointTimeRange{Oldest: *oldest, Newest: *newest}, nil
+}
+
+// fetchPointTime returns the time of the single point selected by the query, or nil if no point is selected
+func fetchPointTime(queryService influxqueries.InfluxQueryService, influxClient influx.Client, database, query string) (*time.Time, error) {
+	results, err := queryService.ExecuteQuery(influxClient, database, query)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %s\n%v", query, err)
+	}
+
+	if len(results) != 1 || len(results[0].Series) == 0 || len(results[0].Series[0].Values) == 0 {
+		return nil, nil
+	}
+
+	timeAsString, ok := results[0].Series[0].Values[0][0].(string)
+	if !ok {
+		return nil, fmt.Errorf("'%s' returned a time that is not a string", query)
+	}
+
+	pointTime, err := time.Parse(time.RFC3339Nano, timeAsString)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' returned an invalid time\n%v", query, err)
+	}
+
+	return &pointTime, nil
+}
@@ -0,0 +1,67 @@
+package discovery
+
+import (
+	"testing"
+	"time"
+
+	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/influxdata/influxdb/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/schemamanagement/influx/influxqueries"
+)
+
+func TestFetchPointTimeRange(t *testing.T) {
+	oldestQuery := `SELECT * FROM "autogen"."m" ORDER BY time ASC LIMIT 1`
+	newestQuery := `SELECT * FROM "autogen"."m" ORDER BY time DESC LIMIT 1`
+	pointResult := func(pointTime interface{}) []influx.Result {
+		return []influx.Result{{Series: []models.Row{{Columns: []string{"time", "value"}, Values: [][]interface{}{{pointTime, 1}}}}}}
+	}
+	testCases := []struct {
+		desc      string
+		results   map[string][]influx.Result
+		expected  *PointTimeRange
+		expectErr bool
+	}{
+		{
+			desc:      "query fails",
+			expectErr: true,
+		}, {
+			desc:    "no points",
+			results: map[string][]influx.Result{oldestQuery: {{}}},
+		}, {
+			desc:      "time is not a string",
+			results:   map[string][]influx.Result{oldestQuery: pointResult(1)},
+			expectErr: true,
+		}, {
+			desc:      "invalid time",
+			results:   map[string][]influx.Result{oldestQuery: pointResult("yesterday")},
+			expectErr: true,
+		}, {
+			desc:      "newest query fails",
+			results:   map[string][]influx.Result{oldestQuery: pointResult("2020-01-01T00:00:00Z")},
+			expectErr: true,
+		}, {
+			desc: "time range",
+			results: map[string][]influx.Result{
+				oldestQuery: pointResult("2020-01-01T00:00:00Z"),
+				newestQuery: pointResult("2020-01-02T00:00:00.5Z"),
+			},
+			expected: &PointTimeRange{
+				Oldest: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+				Newest: time.Date(2020, time.January, 2, 0, 0, 0, 500000000, time.UTC),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		explorer := NewPointTimeRangeExplorer(&mockDensityQueryService{results: tc.results})
+		timeRange, err := explorer.FetchPointTimeRange(&influxqueries.MockClient{}, "db", "autogen", "m")
+		if tc.expectErr {
+			assert.Error(t, err, tc.desc)
+			continue
+		}
+
+		assert.NoError(t, err, tc.desc)
+		assert.Equal(t, tc.expected, timeRange, tc.desc)
+	}
+}
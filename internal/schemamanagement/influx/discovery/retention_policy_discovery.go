@@ -0,0 +1,98 @@
+package discovery
+
+import (
+	"fmt"
+	"time"
+
+	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/timescale/outflux/internal/schemamanagement/influx/influxqueries"
+)
+
+const (
+	showRetentionPoliciesQuery = "SHOW RETENTION POLICIES"
+	rpNameColumn               = "name"
+	rpDurationColumn           = "duration"
+	rpShardGroupDurationColumn = "shardGroupDuration"
+	rpDefaultColumn            = "default"
+)
+
+// RetentionPolicy holds the properties of an InfluxDB retention policy. A Duration of 0 keeps the data forever
+type RetentionPolicy struct {
+	Name               string
+	Duration           time.Duration
+	ShardGroupDuration time.Duration
+	Default            bool
+}
+
+// RetentionPolicyExplorer defines an API for discovering the retention policies of an InfluxDB database
+type RetentionPolicyExplorer interface {
+	FetchRetentionPolicies(influxClient influx.Client, database string) ([]*RetentionPolicy, error)
+}
+
+type defaultRetentionPolicyExplorer struct {
+	queryService influxqueries.InfluxQueryService
+}
+
+// NewRetentionPolicyExplorer creates a new implementation that can discover the retention policies of a database
+func NewRetentionPolicyExplorer(queryService influxqueries.InfluxQueryService) RetentionPolicyExplorer {
+	return &defaultRetentionPolicyExplorer{
+		queryService: queryService,
+	}
+}
+
+// FetchRetentionPolicies returns the retention policies of a database. The durations are returned by
+// 'SHOW RETENTION POLICIES' as Go duration strings, so ExecuteShowQuery can't be used for the query
+func (e *defaultRetentionPolicyExplorer) FetchRetentionPolicies(influxClient influx.Client, database string) ([]*RetentionPolicy, error) {
+	results, err := e.queryService.ExecuteQuery(influxClient, database, showRetentionPoliciesQuery)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %s\n%v", showRetentionPoliciesQuery, err)
+	}
+
+	if len(results) != 1 || len(results[0].Series) != 1 {
+		return nil, fmt.Errorf("'%s' returned an unexpected number of results", showRetentionPoliciesQuery)
+	}
+
+	series := results[0].Series[0]
+	columnIndexes := make(map[string]int)
+	for i, column := range series.Columns {
+		columnIndexes[column] = i
+	}
+
+	for _, column := range []string{rpNameColumn, rpDurationColumn, rpShardGroupDurationColumn, rpDefaultColumn} {
+		if _, ok := columnIndexes[column]; !ok {
+			return nil, fmt.Errorf("'%s' returned no '%s' column", showRetentionPoliciesQuery, column)
+		}
+	}
+
+	policies := make([]*RetentionPolicy, len(series.Values))
+	for i, row := range series.Values {
+		name, nameOk := row[columnIndexes[rpNameColumn]].(string)
+		isDefault, defaultOk := row[columnIndexes[rpDefaultColumn]].(bool)
+		if !nameOk || !defaultOk {
+			return nil, fmt.Errorf("'%s' returned a retention policy with an unexpected name or default value", showRetentionPoliciesQuery)
+		}
+
+		duration, err := parseRPDuration(row[columnIndexes[rpDurationColumn]])
+		if err != nil {
+			return nil, fmt.Errorf("could not parse the duration of retention policy '%s'\n%v", name, err)
+		}
+
+		shardGroupDuration, err := parseRPDuration(row[columnIndexes[rpShardGroupDurationColumn]])
+		if err != nil {
+			return nil, fmt.Errorf("could not parse the shard group duration of retention policy '%s'\n%v", name, err)
+		}
+
+		policies[i] = &RetentionPolicy{Name: name, Duration: duration, ShardGroupDuration: shardGroupDuration, Default: isDefault}
+	}
+
+	return policies, nil
+}
+
+func parseRPDuration(value interface{}) (time.Duration, error) {
+	asString, ok := value.(string)
+	if !ok {
+		return 0, fmt.Errorf("value '%v' is not a string", value)
+	}
+
+	return time.ParseDuration(asString)
+}
@@ -0,0 +1,88 @@
+package discovery
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/influxdata/influxdb/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/schemamanagement/influx/influxqueries"
+)
+
+func TestFetchRetentionPolicies(t *testing.T) {
+	columns := []string{"name", "duration", "shardGroupDuration", "replicaN", "default"}
+	testCases := []struct {
+		desc      string
+		results   []influx.Result
+		queryErr  error
+		expected  []*RetentionPolicy
+		expectErr bool
+	}{
+		{
+			desc:      "query fails",
+			queryErr:  fmt.Errorf("error"),
+			expectErr: true,
+		}, {
+			desc:      "no series",
+			results:   []influx.Result{{}},
+			expectErr: true,
+		}, {
+			desc: "missing column",
+			results: []influx.Result{{Series: []models.Row{
+				{Columns: columns[:3], Values: [][]interface{}{{"autogen", "0s", "168h0m0s"}}},
+			}}},
+			expectErr: true,
+		}, {
+			desc: "bad duration",
+			results: []influx.Result{{Series: []models.Row{
+				{Columns: columns, Values: [][]interface{}{{"autogen", "forever", "168h0m0s", 1, true}}},
+			}}},
+			expectErr: true,
+		}, {
+			desc: "default is not a bool",
+			results: []influx.Result{{Series: []models.Row{
+				{Columns: columns, Values: [][]interface{}{{"autogen", "0s", "168h0m0s", 1, "true"}}},
+			}}},
+			expectErr: true,
+		}, {
+			desc: "all good",
+			results: []influx.Result{{Series: []models.Row{
+				{Columns: columns, Values: [][]interface{}{
+					{"autogen", "0s", "168h0m0s", 1, false},
+					{"one_month", "720h0m0s", "24h0m0s", 1, true},
+				}},
+			}}},
+			expected: []*RetentionPolicy{
+				{Name: "autogen", ShardGroupDuration: 168 * time.Hour},
+				{Name: "one_month", Duration: 720 * time.Hour, ShardGroupDuration: 24 * time.Hour, Default: true},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		explorer := NewRetentionPolicyExplorer(&mockRPQueryService{results: tc.results, err: tc.queryErr})
+		policies, err := explorer.FetchRetentionPolicies(&influxqueries.MockClient{}, "db")
+		if tc.expectErr {
+			assert.Error(t, err, tc.desc)
+			continue
+		}
+
+		assert.NoError(t, err, tc.desc)
+		assert.Equal(t, tc.expected, policies, tc.desc)
+	}
+}
+
+type mockRPQueryService struct {
+	results []influx.Result
+	err     error
+}
+
+func (m *mockRPQueryService) ExecuteQuery(client influx.Client, database, command string) ([]influx.Result, error) {
+	return m.results, m.err
+}
+
+func (m *mockRPQueryService) ExecuteShowQuery(influxClient influx.Client, database, query string) (*influxqueries.InfluxShowResult, error) {
+	panic("should not come here")
+}
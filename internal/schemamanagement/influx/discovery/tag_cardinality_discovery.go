@@ -0,0 +1,78 @@
+package discovery
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/timescale/outflux/internal/schemamanagement/influx/influxqueries"
+)
+
+const (
+	showTagCardinalityQueryTemplate = `SHOW TAG VALUES CARDINALITY FROM "%s"."%s" WITH KEY = "%s"`
+)
+
+// TagCardinality holds the estimated number of values of a tag
+type TagCardinality struct {
+	Tag         string
+	Cardinality uint64
+}
+
+// TagCardinalityExplorer defines an API for estimating the number of values of the tags of an InfluxDB measurement
+type TagCardinalityExplorer interface {
+	RankTagsByCardinality(influxClient influx.Client, database, rp, measure string, tags []string) ([]*TagCardinality, error)
+}
+
+type defaultTagCardinalityExplorer struct {
+	queryService influxqueries.InfluxQueryService
+}
+
+// NewTagCardinalityExplorer creates a new implementation that can estimate the cardinality of the tags of a measurement
+func NewTagCardinalityExplorer(queryService influxqueries.InfluxQueryService) TagCardinalityExplorer {
+	return &defaultTagCardinalityExplorer{
+		queryService: queryService,
+	}
+}
+
+// RankTagsByCardinality returns the cardinality of each tag, sorted from the lowest to the highest. Tags
+// with the same cardinality keep their order. The count is a number, so ExecuteShowQuery can't be used
+func (e *defaultTagCardinalityExplorer) RankTagsByCardinality(influxClient influx.Client, database, rp, measure string, tags []string) ([]*TagCardinality, error) {
+	ranked := make([]*TagCardinality, len(tags))
+	for i, tag := range tags {
+		cardinality, err := e.fetchCardinality(influxClient, database, rp, measure, tag)
+		if err != nil {
+			return nil, err
+		}
+
+		ranked[i] = &TagCardinality{Tag: tag, Cardinality: cardinality}
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Cardinality < ranked[j].Cardinality })
+	return ranked, nil
+}
+
+func (e *defaultTagCardinalityExplorer) fetchCardinality(influxClient influx.Client, database, rp, measure, tag string) (uint64, error) {
+	query := fmt.Sprintf(showTagCardinalityQueryTemplate, rp, measure, tag)
+	results, err := e.queryService.ExecuteQuery(influxClient, database, query)
+	if err != nil {
+		return 0, fmt.Errorf("error executing query: %s\n%v", query, err)
+	}
+
+	// a tag without values returns no series
+	if len(results) != 1 || len(results[0].Series) == 0 {
+		return 0, nil
+	}
+
+	values := results[0].Series[0].Values
+	if len(values) != 1 || len(values[0]) != 1 {
+		return 0, fmt.Errorf("'%s' returned an unexpected result", query)
+	}
+
+	cardinality, err := strconv.ParseUint(fmt.Sprint(values[0][0]), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("'%s' returned a count that is not a number\n%v", query, err)
+	}
+
+	return cardinality, nil
+}
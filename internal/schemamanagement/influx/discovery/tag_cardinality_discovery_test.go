@@ -0,0 +1,87 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/influxdata/influxdb/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/schemamanagement/influx/influxqueries"
+)
+
+func TestRankTagsByCardinality(t *testing.T) {
+	count := func(value interface{}) []influx.Result {
+		return []influx.Result{{Series: []models.Row{{Columns: []string{"count"}, Values: [][]interface{}{{value}}}}}}
+	}
+	testCases := []struct {
+		desc      string
+		results   map[string][]influx.Result
+		queryErr  error
+		expected  []*TagCardinality
+		expectErr bool
+	}{
+		{
+			desc:      "query fails",
+			queryErr:  fmt.Errorf("error"),
+			expectErr: true,
+		}, {
+			desc:      "count is not a number",
+			results:   map[string][]influx.Result{"host": count("many"), "dc": count(json.Number("2"))},
+			expectErr: true,
+		}, {
+			desc: "unexpected columns",
+			results: map[string][]influx.Result{
+				"host": {{Series: []models.Row{{Columns: []string{"key", "count"}, Values: [][]interface{}{{"host", 1}}}}}},
+				"dc":   count(json.Number("2")),
+			},
+			expectErr: true,
+		}, {
+			desc: "ranked from the lowest cardinality",
+			results: map[string][]influx.Result{
+				"host":   count(json.Number("300")),
+				"dc":     count(json.Number("3")),
+				"region": count(json.Number("3")),
+				"empty":  {{}},
+			},
+			expected: []*TagCardinality{
+				{Tag: "empty", Cardinality: 0},
+				{Tag: "dc", Cardinality: 3},
+				{Tag: "region", Cardinality: 3},
+				{Tag: "host", Cardinality: 300},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		explorer := NewTagCardinalityExplorer(&mockCardinalityQueryService{results: tc.results, err: tc.queryErr})
+		ranked, err := explorer.RankTagsByCardinality(&influxqueries.MockClient{}, "db", "autogen", "m", []string{"host", "dc", "region", "empty"})
+		if tc.expectErr {
+			assert.Error(t, err, tc.desc)
+			continue
+		}
+
+		assert.NoError(t, err, tc.desc)
+		assert.Equal(t, tc.expected, ranked, tc.desc)
+	}
+}
+
+type mockCardinalityQueryService struct {
+	results map[string][]influx.Result
+	err     error
+}
+
+func (m *mockCardinalityQueryService) ExecuteQuery(client influx.Client, database, command string) ([]influx.Result, error) {
+	for tag, results := range m.results {
+		if command == fmt.Sprintf(showTagCardinalityQueryTemplate, "autogen", "m", tag) {
+			return results, nil
+		}
+	}
+
+	return nil, m.err
+}
+
+func (m *mockCardinalityQueryService) ExecuteShowQuery(influxClient influx.Client, database, query string) (*influxqueries.InfluxShowResult, error) {
+	panic("should not come here")
+}
@@ -0,0 +1,184 @@
+package influxv2
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/timescale/outflux/internal/connections"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/influx/discovery"
+	"github.com/timescale/outflux/internal/schemamanagement/influxv2/fluxqueries"
+)
+
+const (
+	measurementsQueryTemplate = `import "influxdata/influxdb/schema"
+schema.measurements(bucket: %s, start: %s)`
+	tagKeysQueryTemplate = `import "influxdata/influxdb/schema"
+schema.measurementTagKeys(bucket: %s, measurement: %s, start: %s)`
+	fieldKeysQueryTemplate = `import "influxdata/influxdb/schema"
+schema.measurementFieldKeys(bucket: %s, measurement: %s, start: %s)`
+	fieldTypesQueryTemplate = `from(bucket: %s)
+  |> range(start: %s, stop: %s)
+  |> filter(fn: (r) => r._measurement == %s)
+  |> group(columns: ["_field"])
+  |> first()`
+	valueColumn           = "_value"
+	fieldColumn           = "_field"
+	systemColumnPrefix    = "_"
+	influxFloatType       = "float"
+	influxIntegerType     = "integer"
+	influxStringType      = "string"
+	influxBooleanType     = "boolean"
+	unexpectedResultError = "query returned unexpected result, no '%s' column found"
+)
+
+// Explorer defines an API for discovering the measurements of an InfluxDB 2.x bucket
+// and their tags and fields, using the Flux schema package
+type Explorer interface {
+	DiscoverMeasurements(client connections.InfluxV2Client, bucket string) ([]string, error)
+	DiscoverMeasurementTags(client connections.InfluxV2Client, bucket, measure string) ([]*idrf.Column, error)
+	DiscoverMeasurementFields(client connections.InfluxV2Client, bucket, measure string, onConflictConvertIntToFloat bool) ([]*idrf.Column, error)
+}
+
+type defaultExplorer struct {
+	queryService fluxqueries.FluxQueryService
+}
+
+// NewExplorer creates a new instance of the Explorer
+func NewExplorer(queryService fluxqueries.FluxQueryService) Explorer {
+	return &defaultExplorer{queryService}
+}
+
+func (e *defaultExplorer) DiscoverMeasurements(client connections.InfluxV2Client, bucket string) ([]string, error) {
+	query := fmt.Sprintf(measurementsQueryTemplate, fluxqueries.QuoteString(bucket), fluxqueries.RangeStart)
+	measurements, err := e.fetchValues(client, query)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching measurements of bucket '%s'\n%v", bucket, err)
+	}
+
+	return measurements, nil
+}
+
+// DiscoverMeasurementTags retrieves the tags for a given measurement and returns an IDRF representation for them.
+func (e *defaultExplorer) DiscoverMeasurementTags(client connections.InfluxV2Client, bucket, measure string) ([]*idrf.Column, error) {
+	query := fmt.Sprintf(tagKeysQueryTemplate, fluxqueries.QuoteString(bucket), fluxqueries.QuoteString(measure), fluxqueries.RangeStart)
+	keys, err := e.fetchValues(client, query)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching tags for measurement '%s'\n%v", measure, err)
+	}
+
+	tags := []*idrf.Column{}
+	for _, key := range keys {
+		// _start, _stop, _measurement and _field are returned as tag keys
+		if strings.HasPrefix(key, systemColumnPrefix) {
+			continue
+		}
+
+		tag, err := idrf.NewColumn(key, idrf.IDRFString)
+		if err != nil {
+			return nil, fmt.Errorf("could not convert tags to IDRF\n%v", err)
+		}
+
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
+// DiscoverMeasurementFields returns the fields of a measurement with their IDRF type. The field keys are
+// listed with the Flux schema package, and the type of each field is taken from its first point, with
+// the points of all series grouped by field. Fields with multiple types are handled as in InfluxDB 1.x
+func (e *defaultExplorer) DiscoverMeasurementFields(client connections.InfluxV2Client, bucket, measure string, onConflictConvertIntToFloat bool) ([]*idrf.Column, error) {
+	query := fmt.Sprintf(fieldKeysQueryTemplate, fluxqueries.QuoteString(bucket), fluxqueries.QuoteString(measure), fluxqueries.RangeStart)
+	keys, err := e.fetchValues(client, query)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching fields for measurement '%s'\n%v", measure, err)
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("field discovery query returned unexpected result. no values returned for measure '%s'", measure)
+	}
+
+	types, err := e.fetchFieldTypes(client, bucket, measure)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching field types for measurement '%s'\n%v", measure, err)
+	}
+
+	fieldsWithType := [][2]string{}
+	for _, key := range keys {
+		if len(types[key]) == 0 {
+			return nil, fmt.Errorf("could not discover the type of field '%s', no points found", key)
+		}
+
+		for _, fieldType := range types[key] {
+			fieldsWithType = append(fieldsWithType, [2]string{key, fieldType})
+		}
+	}
+
+	return discovery.ConvertFields(fieldsWithType, onConflictConvertIntToFloat)
+}
+
+// fetchFieldTypes returns the types of the first points of each field of a measurement. A field has
+// more than one type if its points in different shards have different types
+func (e *defaultExplorer) fetchFieldTypes(client connections.InfluxV2Client, bucket, measure string) (map[string][]string, error) {
+	query := fmt.Sprintf(fieldTypesQueryTemplate, fluxqueries.QuoteString(bucket), fluxqueries.RangeStart, fluxqueries.RangeStop, fluxqueries.QuoteString(measure))
+	records, err := e.queryService.ExecuteQuery(client, query)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %s\n%v", query, err)
+	}
+
+	types := make(map[string][]string)
+	for _, record := range records {
+		valueIndex := record.Index(valueColumn)
+		field, ok := record.Value(fieldColumn).(string)
+		if valueIndex == -1 || !ok {
+			return nil, fmt.Errorf("field discovery query returned unexpected result. no field name and value found")
+		}
+
+		fieldType, err := influxFieldType(record.Columns[valueIndex].DataType)
+		if err != nil {
+			return nil, fmt.Errorf("could not discover the type of field '%s'\n%v", field, err)
+		}
+
+		types[field] = append(types[field], fieldType)
+	}
+
+	return types, nil
+}
+
+func (e *defaultExplorer) fetchValues(client connections.InfluxV2Client, query string) ([]string, error) {
+	records, err := e.queryService.ExecuteQuery(client, query)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %s\n%v", query, err)
+	}
+
+	values := make([]string, len(records))
+	for i, record := range records {
+		value, ok := record.Value(valueColumn).(string)
+		if !ok {
+			return nil, fmt.Errorf(unexpectedResultError, valueColumn)
+		}
+
+		values[i] = value
+	}
+
+	sort.Strings(values)
+	return values, nil
+}
+
+// influxFieldType maps the annotated CSV data type of a field to the type name used by InfluxDB 1.x
+func influxFieldType(csvType string) (string, error) {
+	switch csvType {
+	case fluxqueries.DoubleType:
+		return influxFloatType, nil
+	case fluxqueries.LongType, fluxqueries.UnsignedLongType:
+		return influxIntegerType, nil
+	case fluxqueries.StringType:
+		return influxStringType, nil
+	case fluxqueries.BooleanType:
+		return influxBooleanType, nil
+	default:
+		return "", fmt.Errorf("unsupported field type '%s'", csvType)
+	}
+}
@@ -0,0 +1,191 @@
+// Package fluxqueries contains helpers for executing Flux queries against the
+// v2 API of InfluxDB and decoding their annotated CSV responses
+package fluxqueries
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Data types of the columns in an annotated CSV response
+const (
+	StringType       = "string"
+	LongType         = "long"
+	UnsignedLongType = "unsignedLong"
+	DoubleType       = "double"
+	BooleanType      = "boolean"
+	dateTimePrefix   = "dateTime"
+)
+
+const (
+	annotationPrefix     = "#"
+	datatypeAnnotation   = "datatype"
+	groupAnnotation      = "group"
+	defaultAnnotation    = "default"
+	errorColumn          = "error"
+	errorReferenceColumn = "reference"
+)
+
+// Column describes a column of a table in an annotated CSV response
+type Column struct {
+	Name     string
+	DataType string
+	Default  string
+	Group    bool
+}
+
+// Record is a single row of a table in an annotated CSV response. Records of the
+// same table share the Columns slice, 'Table' is increased each time a new table header is read
+type Record struct {
+	Table   int
+	Columns []Column
+	Values  []interface{}
+}
+
+// Index returns the index of the named column in the record, or -1 if the table has no such column
+func (r *Record) Index(name string) int {
+	for i, column := range r.Columns {
+		if column.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Value returns the value of the named column, or nil if the table has no such column
+func (r *Record) Value(name string) interface{} {
+	index := r.Index(name)
+	if index == -1 {
+		return nil
+	}
+
+	return r.Values[index]
+}
+
+// AnnotatedCSVReader decodes the annotated CSV response of a Flux query into records
+// with values converted to Go types as described by the 'datatype' annotation
+type AnnotatedCSVReader struct {
+	csvReader   *csv.Reader
+	table       int
+	columns     []Column
+	annotations map[string][]string
+}
+
+// NewAnnotatedCSVReader creates a reader of the annotated CSV in 'r'
+func NewAnnotatedCSVReader(r io.Reader) *AnnotatedCSVReader {
+	csvReader := csv.NewReader(r)
+	csvReader.FieldsPerRecord = -1
+	csvReader.ReuseRecord = true
+	return &AnnotatedCSVReader{csvReader: csvReader, table: -1}
+}
+
+// Next returns the next record of the response, or io.EOF when no records are left.
+// If the response reports an error, the error is returned.
+func (r *AnnotatedCSVReader) Next() (*Record, error) {
+	for {
+		row, err := r.csvReader.Read()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(row) < 2 {
+			continue
+		}
+
+		if strings.HasPrefix(row[0], annotationPrefix) {
+			r.readAnnotation(row)
+			continue
+		}
+
+		if r.columns == nil {
+			r.readHeader(row[1:])
+			continue
+		}
+
+		if len(row)-1 != len(r.columns) {
+			return nil, fmt.Errorf("expected a row with %d values, got %d", len(r.columns), len(row)-1)
+		}
+
+		if r.isErrorTable() {
+			return nil, fmt.Errorf("flux query failed: %s", row[1])
+		}
+
+		values := make([]interface{}, len(r.columns))
+		for i, column := range r.columns {
+			if values[i], err = parseValue(row[i+1], column); err != nil {
+				return nil, fmt.Errorf("could not parse value of column '%s'\n%v", column.Name, err)
+			}
+		}
+
+		return &Record{Table: r.table, Columns: r.columns, Values: values}, nil
+	}
+}
+
+func (r *AnnotatedCSVReader) readAnnotation(row []string) {
+	if r.annotations == nil {
+		r.annotations = make(map[string][]string)
+		r.columns = nil
+	}
+
+	values := make([]string, len(row)-1)
+	copy(values, row[1:])
+	r.annotations[strings.TrimPrefix(row[0], annotationPrefix)] = values
+}
+
+func (r *AnnotatedCSVReader) readHeader(names []string) {
+	r.table++
+	r.columns = make([]Column, len(names))
+	for i, name := range names {
+		r.columns[i] = Column{
+			Name:     name,
+			DataType: annotationValue(r.annotations, datatypeAnnotation, i, StringType),
+			Default:  annotationValue(r.annotations, defaultAnnotation, i, ""),
+			Group:    annotationValue(r.annotations, groupAnnotation, i, "false") == "true",
+		}
+	}
+
+	r.annotations = nil
+}
+
+func (r *AnnotatedCSVReader) isErrorTable() bool {
+	return len(r.columns) == 2 && r.columns[0].Name == errorColumn && r.columns[1].Name == errorReferenceColumn
+}
+
+func annotationValue(annotations map[string][]string, annotation string, index int, defaultValue string) string {
+	values, ok := annotations[annotation]
+	if !ok || index >= len(values) || values[index] == "" {
+		return defaultValue
+	}
+
+	return values[index]
+}
+
+func parseValue(value string, column Column) (interface{}, error) {
+	if value == "" {
+		value = column.Default
+	}
+
+	if value == "" {
+		return nil, nil
+	}
+
+	switch {
+	case column.DataType == LongType:
+		return strconv.ParseInt(value, 10, 64)
+	case column.DataType == UnsignedLongType:
+		return strconv.ParseUint(value, 10, 64)
+	case column.DataType == DoubleType:
+		return strconv.ParseFloat(value, 64)
+	case column.DataType == BooleanType:
+		return strconv.ParseBool(value)
+	case strings.HasPrefix(column.DataType, dateTimePrefix):
+		return time.Parse(time.RFC3339Nano, value)
+	default:
+		return value, nil
+	}
+}
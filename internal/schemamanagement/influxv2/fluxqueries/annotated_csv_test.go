@@ -0,0 +1,83 @@
+package fluxqueries
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const twoTablesResponse = `#datatype,string,long,dateTime:RFC3339,string,double,long
+#group,false,false,false,true,false,false
+#default,_result,,,,,
+,result,table,_time,host,usage,count
+,,0,2019-01-01T00:00:00Z,a,1.5,
+,,0,2019-01-01T00:00:01.5Z,a,+Inf,3
+
+#datatype,string,long,dateTime:RFC3339,boolean,unsignedLong
+#group,false,false,false,false,false
+#default,_result,,,,
+,result,table,_time,ok,"weird, name"
+,,1,2019-01-01T00:00:02Z,true,18446744073709551615
+`
+
+func TestAnnotatedCSVReader(t *testing.T) {
+	reader := NewAnnotatedCSVReader(strings.NewReader(twoTablesResponse))
+	records := []*Record{}
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+
+		assert.NoError(t, err)
+		records = append(records, record)
+	}
+
+	assert.Len(t, records, 3)
+	first := records[0]
+	assert.Equal(t, 0, first.Table)
+	assert.Equal(t, Column{Name: "host", DataType: StringType, Group: true}, first.Columns[3])
+	assert.Equal(t, []interface{}{"_result", int64(0), time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), "a", 1.5, nil}, first.Values)
+	assert.Equal(t, int64(3), records[1].Value("count"))
+	assert.Nil(t, records[1].Value("missing"))
+	assert.Equal(t, time.Date(2019, 1, 1, 0, 0, 1, 500000000, time.UTC), records[1].Value("_time"))
+
+	last := records[2]
+	assert.Equal(t, 1, last.Table)
+	assert.Equal(t, 4, last.Index("weird, name"))
+	assert.Equal(t, true, last.Value("ok"))
+	assert.Equal(t, uint64(18446744073709551615), last.Value("weird, name"))
+}
+
+func TestAnnotatedCSVReaderErrors(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		response string
+	}{
+		{
+			desc: "error table",
+			response: "#datatype,string,string\n#group,true,true\n#default,,\n" +
+				",error,reference\n,failed to parse query,897\n",
+		}, {
+			desc:     "unparsable value",
+			response: "#datatype,string,long\n,result,count\n,,abc\n",
+		}, {
+			desc:     "wrong number of values",
+			response: "#datatype,string,long\n,result,count\n,,1,2\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		reader := NewAnnotatedCSVReader(strings.NewReader(tc.response))
+		_, err := reader.Next()
+		assert.Error(t, err, tc.desc)
+		assert.NotEqual(t, io.EOF, err, tc.desc)
+	}
+}
+
+func TestQuoteString(t *testing.T) {
+	assert.Equal(t, `"a \"b\" \\ \${c}"`, QuoteString(`a "b" \ ${c}`))
+}
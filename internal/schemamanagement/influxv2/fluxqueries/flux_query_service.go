@@ -0,0 +1,56 @@
+package fluxqueries
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/timescale/outflux/internal/connections"
+)
+
+// RangeStart and RangeStop are the earliest and latest times that can be used as bounds of range()
+const (
+	RangeStart = "1677-09-21T00:12:43.145224194Z"
+	RangeStop  = "2262-04-11T23:47:16.854775806Z"
+)
+
+var fluxStringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`)
+
+// FluxQueryService contains helper functions to work with the InfluxDB v2 client
+type FluxQueryService interface {
+	// ExecuteQuery executes a Flux query and reads all the records of the response
+	ExecuteQuery(client connections.InfluxV2Client, flux string) ([]*Record, error)
+}
+
+type defaultFluxQueryService struct{}
+
+// NewFluxQueryService creates a new instance of the FluxQueryService
+func NewFluxQueryService() FluxQueryService {
+	return &defaultFluxQueryService{}
+}
+
+func (s *defaultFluxQueryService) ExecuteQuery(client connections.InfluxV2Client, flux string) ([]*Record, error) {
+	response, err := client.Query(flux)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Close()
+	reader := NewAnnotatedCSVReader(response)
+	records := []*Record{}
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("could not read the response of a flux query\n%v", err)
+		}
+
+		records = append(records, record)
+	}
+}
+
+// QuoteString returns the value as a Flux string literal
+func QuoteString(value string) string {
+	return `"` + fluxStringEscaper.Replace(value) + `"`
+}
@@ -0,0 +1,61 @@
+// Package influxv2 discovers the schema of measurements stored in an InfluxDB 2.x
+// bucket through the v2 API
+package influxv2
+
+import (
+	"fmt"
+
+	"github.com/timescale/outflux/internal/connections"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+)
+
+const timeColumn = "time"
+
+// SchemaManager implements the schemamanagement.SchemaManager interface for InfluxDB 2.x buckets
+type SchemaManager struct {
+	client                      connections.InfluxV2Client
+	explorer                    Explorer
+	bucket                      string
+	onConflictConvertIntToFloat bool
+}
+
+// NewSchemaManager creates new schema manager that can discover the data sets of an InfluxDB 2.x bucket
+func NewSchemaManager(client connections.InfluxV2Client, bucket string, onConflictConvertIntToFloat bool, explorer Explorer) *SchemaManager {
+	return &SchemaManager{
+		client:                      client,
+		explorer:                    explorer,
+		bucket:                      bucket,
+		onConflictConvertIntToFloat: onConflictConvertIntToFloat,
+	}
+}
+
+// DiscoverDataSets returns a list of the measurements in the bucket
+func (sm *SchemaManager) DiscoverDataSets() ([]string, error) {
+	return sm.explorer.DiscoverMeasurements(sm.client, sm.bucket)
+}
+
+// FetchDataSet returns the idrf.DataSet describing a measurement.
+// The time column is followed by the tags and then the fields
+func (sm *SchemaManager) FetchDataSet(measure string) (*idrf.DataSet, error) {
+	tags, err := sm.explorer.DiscoverMeasurementTags(sm.client, sm.bucket, measure)
+	if err != nil {
+		return nil, fmt.Errorf("could not discover the tags of measurement '%s'\n%v", measure, err)
+	}
+
+	fields, err := sm.explorer.DiscoverMeasurementFields(sm.client, sm.bucket, measure, sm.onConflictConvertIntToFloat)
+	if err != nil {
+		return nil, fmt.Errorf("could not discover the fields of measure '%s'\n%v", measure, err)
+	}
+
+	idrfTimeColumn, _ := idrf.NewColumn(timeColumn, idrf.IDRFTimestamptz)
+	allColumns := []*idrf.Column{idrfTimeColumn}
+	allColumns = append(allColumns, tags...)
+	allColumns = append(allColumns, fields...)
+	return idrf.NewDataSet(measure, allColumns, timeColumn)
+}
+
+// PrepareDataSet NOT IMPLEMENTED
+func (sm *SchemaManager) PrepareDataSet(dataSet *idrf.DataSet, strategy schemaconfig.SchemaStrategy) error {
+	panic("not implemented")
+}
@@ -0,0 +1,137 @@
+package influxv2
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/connections"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/influxv2/fluxqueries"
+	"github.com/timescale/outflux/internal/testutils"
+)
+
+const (
+	measurementsCSV = `#datatype,string,long,string
+#group,false,false,false
+#default,_result,,
+,result,table,_value
+,,0,mem
+,,0,cpu
+`
+	tagKeysCSV = `#datatype,string,long,string
+#group,false,false,false
+#default,_result,,
+,result,table,_value
+,,0,_start
+,,0,_stop
+,,0,_field
+,,0,_measurement
+,,0,host
+`
+	fieldKeysCSV = `#datatype,string,long,string
+#group,false,false,false
+#default,_result,,
+,result,table,_value
+,,0,usage
+,,0,count
+`
+	// one table per field, and one per type of the usage field that is a double and a long in different shards
+	fieldTypesCSV = `#datatype,string,long,dateTime:RFC3339,dateTime:RFC3339,dateTime:RFC3339,double,string,string,string
+#group,false,false,false,false,false,false,true,false,false
+#default,_result,,,,,,,,
+,result,table,_start,_stop,_time,_value,_field,_measurement,host
+,,0,1677-09-21T00:12:43.145224194Z,2262-04-11T23:47:16.854775806Z,2019-01-01T00:00:00Z,1.5,usage,cpu,a
+
+#datatype,string,long,dateTime:RFC3339,dateTime:RFC3339,dateTime:RFC3339,long,string,string,string
+#group,false,false,false,false,false,false,true,false,false
+#default,_result,,,,,,,,
+,result,table,_start,_stop,_time,_value,_field,_measurement,host
+,,1,1677-09-21T00:12:43.145224194Z,2262-04-11T23:47:16.854775806Z,2019-01-02T00:00:00Z,2,usage,cpu,b
+,,2,1677-09-21T00:12:43.145224194Z,2262-04-11T23:47:16.854775806Z,2019-01-01T00:00:00Z,3,count,cpu,b
+`
+)
+
+func newTestSchemaManager(t *testing.T, responses []testutils.FluxResponse, convertIntToFloat bool) (*SchemaManager, func()) {
+	server := testutils.NewInfluxV2StandIn(responses)
+	client, err := connections.NewInfluxV2ConnectionService().NewConnection(&connections.InfluxV2ConnectionParams{
+		Server: server.URL, Token: "token", Org: "org",
+	})
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+
+	explorer := NewExplorer(fluxqueries.NewFluxQueryService())
+	return NewSchemaManager(client, "bucket", convertIntToFloat, explorer), server.Close
+}
+
+func TestDiscoverDataSets(t *testing.T) {
+	sm, closeServer := newTestSchemaManager(t, []testutils.FluxResponse{
+		{QueryContains: `schema.measurements(bucket: "bucket"`, CSV: measurementsCSV},
+	}, false)
+	defer closeServer()
+
+	measures, err := sm.DiscoverDataSets()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cpu", "mem"}, measures)
+}
+
+func TestFetchDataSet(t *testing.T) {
+	responses := []testutils.FluxResponse{
+		{QueryContains: `schema.measurementTagKeys(bucket: "bucket", measurement: "cpu"`, CSV: tagKeysCSV},
+		{QueryContains: `schema.measurementFieldKeys(bucket: "bucket", measurement: "cpu"`, CSV: fieldKeysCSV},
+		{QueryContains: `group(columns: ["_field"])`, CSV: fieldTypesCSV},
+	}
+	sm, closeServer := newTestSchemaManager(t, responses, false)
+	_, err := sm.FetchDataSet("cpu")
+	closeServer()
+	assert.Error(t, err, "usage is double and long without the int to float conversion")
+
+	sm, closeServer = newTestSchemaManager(t, responses, true)
+	defer closeServer()
+	dataSet, err := sm.FetchDataSet("cpu")
+	assert.NoError(t, err)
+	assert.Equal(t, "time", dataSet.TimeColumn)
+	assert.Equal(t, []*idrf.Column{
+		{Name: "time", DataType: idrf.IDRFTimestamptz},
+		{Name: "host", DataType: idrf.IDRFString},
+		{Name: "count", DataType: idrf.IDRFInteger64},
+		{Name: "usage", DataType: idrf.IDRFDouble},
+	}, dataSet.Columns)
+
+	_, err = sm.FetchDataSet("missing")
+	assert.Error(t, err)
+}
+
+func TestInfluxFieldType(t *testing.T) {
+	testCases := map[string]string{
+		fluxqueries.DoubleType:       influxFloatType,
+		fluxqueries.LongType:         influxIntegerType,
+		fluxqueries.UnsignedLongType: influxIntegerType,
+		fluxqueries.StringType:       influxStringType,
+		fluxqueries.BooleanType:      influxBooleanType,
+	}
+
+	for in, exp := range testCases {
+		out, err := influxFieldType(in)
+		assert.NoError(t, err)
+		assert.Equal(t, exp, out)
+	}
+
+	_, err := influxFieldType("duration")
+	assert.Error(t, err)
+}
+
+func TestDiscoverFieldsWithoutPoints(t *testing.T) {
+	sm, closeServer := newTestSchemaManager(t, []testutils.FluxResponse{
+		{QueryContains: `schema.measurementTagKeys(bucket: "bucket", measurement: "cpu"`, CSV: tagKeysCSV},
+		{QueryContains: `schema.measurementFieldKeys(bucket: "bucket", measurement: "cpu"`, CSV: fieldKeysCSV},
+		{QueryContains: `group(columns: ["_field"])`, CSV: fieldTypesCSV[:strings.Index(fieldTypesCSV, ",,2,")]},
+	}, true)
+	defer closeServer()
+
+	_, err := sm.FetchDataSet("cpu")
+	if assert.Error(t, err, "count has a key but no points") {
+		assert.Contains(t, err.Error(), "'count'")
+	}
+}
@@ -0,0 +1,241 @@
+package lineprotocol
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/influx/discovery"
+	"github.com/timescale/outflux/internal/schemamanagement/lineprotocol/lpfile"
+)
+
+const (
+	influxFloatType   = "float"
+	influxIntegerType = "integer"
+	influxStringType  = "string"
+	influxBooleanType = "boolean"
+	// maxSections is the most sections kept for a measure, more sections are merged in one
+	// that also spans the lines of the other measures between them
+	maxSections = 1000
+)
+
+// Explorer defines an API for discovering the measurements of a database in a line protocol
+// file and their tags and fields. Only the points of the database and retention policy are
+// considered when the file is an 'influx_inspect export' dump
+type Explorer interface {
+	DiscoverMeasurements(path, db, rp string) ([]string, error)
+	DiscoverMeasurementTags(path, db, rp, measure string) ([]*idrf.Column, error)
+	DiscoverMeasurementFields(path, db, rp, measure string, onConflictConvertIntToFloat bool) ([]*idrf.Column, error)
+	// DiscoverMeasurementSections returns the sections of the file that hold the points of the measure
+	DiscoverMeasurementSections(path, db, rp, measure string) ([]lpfile.Section, error)
+}
+
+// fileKey identifies the points of a file that belong to a database and retention policy
+type fileKey struct {
+	path, db, rp string
+}
+
+// measureSchema holds the tag keys and the types of each field seen in the points of a measure,
+// and the sections of the file its points are in
+type measureSchema struct {
+	tags     map[string]bool
+	fields   map[string]map[string]bool
+	sections []lpfile.Section
+}
+
+// addSection adds the line of a point to the sections of the measure. The last section is extended
+// if the previous point in the file was of the measure too
+func (s *measureSchema) addSection(line lpfile.Section, extendLast bool) {
+	if extendLast {
+		s.sections[len(s.sections)-1].End = line.End
+		return
+	}
+
+	if len(s.sections) >= maxSections {
+		s.sections = s.sections[:1]
+		s.sections[0].End = line.End
+		return
+	}
+
+	s.sections = append(s.sections, line)
+}
+
+type defaultExplorer struct {
+	lock    *sync.Mutex
+	scanned map[fileKey]map[string]*measureSchema
+}
+
+// NewExplorer creates a new instance of the Explorer. The schema is inferred from all the
+// points in the file, each file is read once and the result is reused for every measure
+func NewExplorer() Explorer {
+	return &defaultExplorer{
+		lock:    &sync.Mutex{},
+		scanned: make(map[fileKey]map[string]*measureSchema),
+	}
+}
+
+func (e *defaultExplorer) DiscoverMeasurements(path, db, rp string) ([]string, error) {
+	measures, err := e.scan(path, db, rp)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(measures))
+	for name := range measures {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// DiscoverMeasurementTags returns an IDRF column for each tag key seen in the points of the measure
+func (e *defaultExplorer) DiscoverMeasurementTags(path, db, rp, measure string) ([]*idrf.Column, error) {
+	schema, err := e.measureSchema(path, db, rp, measure)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(schema.tags))
+	for key := range schema.tags {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	tags := make([]*idrf.Column, len(keys))
+	for i, key := range keys {
+		tags[i], err = idrf.NewColumn(key, idrf.IDRFString)
+		if err != nil {
+			return nil, fmt.Errorf("could not convert tags to IDRF\n%v", err)
+		}
+	}
+
+	return tags, nil
+}
+
+// DiscoverMeasurementFields returns the fields seen in the points of the measure with their IDRF type.
+// Fields with multiple types are handled as fields with different types across shards in InfluxDB
+func (e *defaultExplorer) DiscoverMeasurementFields(path, db, rp, measure string, onConflictConvertIntToFloat bool) ([]*idrf.Column, error) {
+	schema, err := e.measureSchema(path, db, rp, measure)
+	if err != nil {
+		return nil, err
+	}
+
+	fieldsWithType := [][2]string{}
+	for field, types := range schema.fields {
+		for fieldType := range types {
+			fieldsWithType = append(fieldsWithType, [2]string{field, fieldType})
+		}
+	}
+
+	// sorted so the casts chosen for fields with multiple types are logged in a stable order
+	sort.Slice(fieldsWithType, func(i, j int) bool {
+		if fieldsWithType[i][0] != fieldsWithType[j][0] {
+			return fieldsWithType[i][0] < fieldsWithType[j][0]
+		}
+
+		return fieldsWithType[i][1] < fieldsWithType[j][1]
+	})
+	return discovery.ConvertFields(fieldsWithType, onConflictConvertIntToFloat)
+}
+
+// DiscoverMeasurementSections returns the sections of the file with the points of the measure,
+// so they are extracted without parsing the rest of the file
+func (e *defaultExplorer) DiscoverMeasurementSections(path, db, rp, measure string) ([]lpfile.Section, error) {
+	schema, err := e.measureSchema(path, db, rp, measure)
+	if err != nil {
+		return nil, err
+	}
+
+	return schema.sections, nil
+}
+
+func (e *defaultExplorer) measureSchema(path, db, rp, measure string) (*measureSchema, error) {
+	measures, err := e.scan(path, db, rp)
+	if err != nil {
+		return nil, err
+	}
+
+	schema, ok := measures[measure]
+	if !ok {
+		return nil, fmt.Errorf("measure '%s' not found in file '%s'", measure, path)
+	}
+
+	return schema, nil
+}
+
+// scan reads the whole file once per database and retention policy, and collects the
+// tag keys and field types of each measure, and the sections of the file with its points
+func (e *defaultExplorer) scan(path, db, rp string) (map[string]*measureSchema, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	key := fileKey{path, db, rp}
+	if measures, ok := e.scanned[key]; ok {
+		return measures, nil
+	}
+
+	log.Printf("Discovering the schema of the points in file: %s", path)
+	reader, err := lpfile.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer reader.Close()
+	measures := make(map[string]*measureSchema)
+	// the measure of the previous point, nil if it was not in the context
+	var previous *measureSchema
+	for {
+		point, err := reader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("could not read file '%s'\n%v", path, err)
+		}
+
+		if !point.InContext(db, rp) {
+			previous = nil
+			continue
+		}
+
+		schema, ok := measures[point.Measurement]
+		if !ok {
+			schema = &measureSchema{tags: make(map[string]bool), fields: make(map[string]map[string]bool)}
+			measures[point.Measurement] = schema
+		}
+
+		schema.addSection(reader.LastSection(), schema == previous)
+		previous = schema
+
+		for tag := range point.Tags {
+			schema.tags[tag] = true
+		}
+
+		for field, value := range point.Fields {
+			if schema.fields[field] == nil {
+				schema.fields[field] = make(map[string]bool)
+			}
+
+			schema.fields[field][influxFieldType(value)] = true
+		}
+	}
+
+	e.scanned[key] = measures
+	return measures, nil
+}
+
+// influxFieldType returns the name InfluxDB uses for the type of a parsed field value
+func influxFieldType(value interface{}) string {
+	switch value.(type) {
+	case float64:
+		return influxFloatType
+	case int64:
+		return influxIntegerType
+	case bool:
+		return influxBooleanType
+	default:
+		return influxStringType
+	}
+}
@@ -0,0 +1,68 @@
+// Package lineprotocol infers the schema of the measurements stored in a line protocol
+// file or an 'influx_inspect export' dump, without a running InfluxDB server
+package lineprotocol
+
+import (
+	"fmt"
+
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/lineprotocol/lpfile"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+)
+
+const timeColumn = "time"
+
+// SchemaManager implements the schemamanagement.SchemaManager interface for line protocol files
+type SchemaManager struct {
+	explorer                    Explorer
+	path                        string
+	db                          string
+	rp                          string
+	onConflictConvertIntToFloat bool
+}
+
+// NewSchemaManager creates new schema manager that can infer the data sets of a database in a line protocol file
+func NewSchemaManager(path, db, rp string, onConflictConvertIntToFloat bool, explorer Explorer) *SchemaManager {
+	return &SchemaManager{
+		explorer:                    explorer,
+		path:                        path,
+		db:                          db,
+		rp:                          rp,
+		onConflictConvertIntToFloat: onConflictConvertIntToFloat,
+	}
+}
+
+// DiscoverDataSets returns a list of the measurements in the file
+func (sm *SchemaManager) DiscoverDataSets() ([]string, error) {
+	return sm.explorer.DiscoverMeasurements(sm.path, sm.db, sm.rp)
+}
+
+// FetchDataSet returns the idrf.DataSet describing a measurement.
+// The time column is followed by the tags and then the fields
+func (sm *SchemaManager) FetchDataSet(measure string) (*idrf.DataSet, error) {
+	tags, err := sm.explorer.DiscoverMeasurementTags(sm.path, sm.db, sm.rp, measure)
+	if err != nil {
+		return nil, fmt.Errorf("could not discover the tags of measurement '%s'\n%v", measure, err)
+	}
+
+	fields, err := sm.explorer.DiscoverMeasurementFields(sm.path, sm.db, sm.rp, measure, sm.onConflictConvertIntToFloat)
+	if err != nil {
+		return nil, fmt.Errorf("could not discover the fields of measure '%s'\n%v", measure, err)
+	}
+
+	idrfTimeColumn, _ := idrf.NewColumn(timeColumn, idrf.IDRFTimestamptz)
+	allColumns := []*idrf.Column{idrfTimeColumn}
+	allColumns = append(allColumns, tags...)
+	allColumns = append(allColumns, fields...)
+	return idrf.NewDataSet(measure, allColumns, timeColumn)
+}
+
+// FetchSections returns the sections of the file with the points of a measurement
+func (sm *SchemaManager) FetchSections(measure string) ([]lpfile.Section, error) {
+	return sm.explorer.DiscoverMeasurementSections(sm.path, sm.db, sm.rp, measure)
+}
+
+// PrepareDataSet NOT IMPLEMENTED
+func (sm *SchemaManager) PrepareDataSet(dataSet *idrf.DataSet, strategy schemaconfig.SchemaStrategy) error {
+	panic("not implemented")
+}
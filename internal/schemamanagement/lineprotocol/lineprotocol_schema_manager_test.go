@@ -0,0 +1,127 @@
+package lineprotocol
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/lineprotocol/lpfile"
+)
+
+const exportDump = `# DDL
+CREATE DATABASE db WITH NAME autogen
+# DML
+# CONTEXT-DATABASE:db
+# CONTEXT-RETENTION-POLICY:autogen
+cpu,host=a usage=1i 1546300800000000000
+cpu,region=eu usage=1.5,up=true 1546300801000000000
+mem used=1i 1546300802000000000
+# CONTEXT-RETENTION-POLICY:other
+disk free=1i 1546300803000000000
+cpu,host=b usage="high" 1546300804000000000
+`
+
+func writeFile(t *testing.T, content string) string {
+	file, err := ioutil.TempFile("", "outflux_lp")
+	if err != nil {
+		t.Fatalf("could not create file: %v", err)
+	}
+
+	defer file.Close()
+	file.WriteString(content)
+	return file.Name()
+}
+
+func TestDiscoverDataSets(t *testing.T) {
+	path := writeFile(t, exportDump)
+	defer os.Remove(path)
+
+	sm := NewSchemaManager(path, "db", "autogen", false, NewExplorer())
+	measures, err := sm.DiscoverDataSets()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cpu", "mem"}, measures)
+
+	sm = NewSchemaManager(path, "db", "other", false, NewExplorer())
+	measures, err = sm.DiscoverDataSets()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cpu", "disk"}, measures)
+
+	sm = NewSchemaManager(path, "missing", "autogen", false, NewExplorer())
+	measures, err = sm.DiscoverDataSets()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, measures)
+
+	sm = NewSchemaManager("/does/not/exist", "db", "autogen", false, NewExplorer())
+	_, err = sm.DiscoverDataSets()
+	assert.Error(t, err)
+}
+
+func TestFetchDataSet(t *testing.T) {
+	path := writeFile(t, exportDump)
+	defer os.Remove(path)
+
+	explorer := NewExplorer()
+	sm := NewSchemaManager(path, "db", "autogen", false, explorer)
+	_, err := sm.FetchDataSet("cpu")
+	assert.Error(t, err, "usage is both integer and float")
+	_, err = sm.FetchDataSet("disk")
+	assert.Error(t, err, "disk is not in the retention policy")
+
+	sm = NewSchemaManager(path, "db", "autogen", true, explorer)
+	dataSet, err := sm.FetchDataSet("cpu")
+	assert.NoError(t, err)
+	assert.Equal(t, &idrf.DataSet{
+		DataSetName: "cpu",
+		Columns: []*idrf.Column{
+			{Name: "time", DataType: idrf.IDRFTimestamptz},
+			{Name: "host", DataType: idrf.IDRFString},
+			{Name: "region", DataType: idrf.IDRFString},
+			{Name: "up", DataType: idrf.IDRFBoolean},
+			{Name: "usage", DataType: idrf.IDRFDouble},
+		},
+		TimeColumn: "time",
+	}, dataSet)
+
+	sm = NewSchemaManager(path, "db", "other", true, explorer)
+	_, err = sm.FetchDataSet("cpu")
+	assert.NoError(t, err, "usage is only a string in the other retention policy")
+}
+
+func TestFetchSections(t *testing.T) {
+	path := writeFile(t, exportDump)
+	defer os.Remove(path)
+
+	explorer := NewExplorer()
+	sm := NewSchemaManager(path, "db", "autogen", false, explorer)
+	sections, err := sm.FetchSections("cpu")
+	assert.NoError(t, err)
+	// the two consecutive cpu points are one section, up to the mem point
+	start := int64(strings.Index(exportDump, "cpu,host=a"))
+	end := int64(strings.Index(exportDump, "mem"))
+	assert.Equal(t, []lpfile.Section{{Start: start, End: end, Line: 6, Database: "db", RetentionPolicy: "autogen"}}, sections)
+
+	sm = NewSchemaManager(path, "db", "other", false, explorer)
+	sections, err = sm.FetchSections("cpu")
+	assert.NoError(t, err)
+	start = int64(strings.Index(exportDump, "cpu,host=b"))
+	assert.Equal(t, []lpfile.Section{{Start: start, End: int64(len(exportDump)), Line: 11, Database: "db", RetentionPolicy: "other"}}, sections)
+
+	_, err = sm.FetchSections("mem")
+	assert.Error(t, err, "mem is not in the retention policy")
+}
+
+func TestAddSectionMergesTooManySections(t *testing.T) {
+	schema := &measureSchema{}
+	for i := int64(0); i < maxSections; i++ {
+		schema.addSection(lpfile.Section{Start: 2 * i, End: 2*i + 1, Line: int(2*i) + 1}, false)
+	}
+
+	schema.addSection(lpfile.Section{Start: 2 * maxSections, End: 2*maxSections + 1}, false)
+	assert.Equal(t, []lpfile.Section{{Start: 0, End: 2*maxSections + 1, Line: 1}}, schema.sections)
+
+	schema.addSection(lpfile.Section{Start: 2*maxSections + 1, End: 2*maxSections + 2}, true)
+	assert.Equal(t, int64(2*maxSections+2), schema.sections[0].End)
+}
@@ -0,0 +1,192 @@
+// Package lpfile reads the points of InfluxDB line protocol files, plain or gzipped,
+// including the dumps created with 'influx_inspect export'
+package lpfile
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+const (
+	ddlHeader              = "# DDL"
+	dmlHeader              = "# DML"
+	databaseContextPrefix  = "# CONTEXT-DATABASE:"
+	retentionContextPrefix = "# CONTEXT-RETENTION-POLICY:"
+	commentPrefix          = '#'
+	nanosecondPrecision    = "n"
+)
+
+// gzipMagic are the first bytes of a gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// noTimestamp is set as the time of points without a timestamp. It is outside the range
+// of times InfluxDB accepts, so no parsed timestamp can be equal to it
+var noTimestamp = time.Unix(0, models.MinNanoTime-1)
+
+// Point is a point read from a line protocol file. Database and RetentionPolicy are
+// taken from the context headers of an 'influx_inspect export' dump, and are empty
+// for plain line protocol files
+type Point struct {
+	Database        string
+	RetentionPolicy string
+	Measurement     string
+	Tags            map[string]string
+	Fields          map[string]interface{}
+	Time            time.Time
+}
+
+// InContext returns true if the point was exported from the database and retention policy,
+// or if it was read without an export context
+func (p *Point) InContext(db, rp string) bool {
+	return (p.Database == "" || p.Database == db) &&
+		(p.RetentionPolicy == "" || p.RetentionPolicy == rp)
+}
+
+// Reader reads the points of a line protocol file one by one. The statements in
+// the DDL section of an export are skipped, the points in the DML section are read
+// with the database and retention policy of the last context headers
+type Reader struct {
+	source          *bufio.Reader
+	closer          io.Closer
+	line            int
+	inDDL           bool
+	database        string
+	retentionPolicy string
+	// offset of the next line, and of the line of the last point read, in the decompressed content
+	offset     int64
+	lineOffset int64
+}
+
+// Open opens the line protocol file at path for reading. Gzipped files are decompressed
+func Open(path string) (*Reader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open line protocol file\n%v", err)
+	}
+
+	reader, err := NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	reader.closer = file
+	return reader, nil
+}
+
+// NewReader creates a reader of line protocol. If the content is gzipped it is decompressed
+func NewReader(r io.Reader) (*Reader, error) {
+	source := bufio.NewReader(r)
+	magic, err := source.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("could not read line protocol\n%v", err)
+	}
+
+	if bytes.Equal(magic, gzipMagic) {
+		decompressed, err := gzip.NewReader(source)
+		if err != nil {
+			return nil, fmt.Errorf("could not decompress line protocol\n%v", err)
+		}
+
+		source = bufio.NewReader(decompressed)
+	}
+
+	return &Reader{source: source}, nil
+}
+
+// Next returns the next point, or io.EOF when all points have been read
+func (r *Reader) Next() (*Point, error) {
+	for {
+		line, err := r.source.ReadBytes('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return nil, err
+		}
+
+		r.line++
+		r.lineOffset = r.offset
+		r.offset += int64(len(line))
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		} else if line[0] == commentPrefix {
+			r.readComment(string(line))
+			continue
+		} else if r.inDDL {
+			continue
+		}
+
+		return r.parsePoint(line)
+	}
+}
+
+// LastSection returns the section holding only the line of the last point read
+func (r *Reader) LastSection() Section {
+	return Section{
+		Start:           r.lineOffset,
+		End:             r.offset,
+		Line:            r.line,
+		Database:        r.database,
+		RetentionPolicy: r.retentionPolicy,
+	}
+}
+
+// Close closes the file opened with Open
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+
+	return r.closer.Close()
+}
+
+func (r *Reader) readComment(comment string) {
+	switch {
+	case comment == ddlHeader:
+		r.inDDL = true
+	case comment == dmlHeader:
+		r.inDDL = false
+	case strings.HasPrefix(comment, databaseContextPrefix):
+		r.database = strings.TrimSpace(strings.TrimPrefix(comment, databaseContextPrefix))
+	case strings.HasPrefix(comment, retentionContextPrefix):
+		r.retentionPolicy = strings.TrimSpace(strings.TrimPrefix(comment, retentionContextPrefix))
+	}
+}
+
+func (r *Reader) parsePoint(line []byte) (*Point, error) {
+	parsed, err := models.ParsePointsWithPrecision(line, noTimestamp, nanosecondPrecision)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: could not parse point\n%v", r.line, err)
+	}
+
+	point := parsed[0]
+	if point.Time().Equal(noTimestamp) {
+		return nil, fmt.Errorf("line %d: point has no timestamp", r.line)
+	}
+
+	fields, err := point.Fields()
+	if err != nil {
+		return nil, fmt.Errorf("line %d: could not parse fields\n%v", r.line, err)
+	}
+
+	tags := make(map[string]string, len(point.Tags()))
+	for _, tag := range point.Tags() {
+		tags[string(tag.Key)] = string(tag.Value)
+	}
+
+	return &Point{
+		Database:        r.database,
+		RetentionPolicy: r.retentionPolicy,
+		Measurement:     string(point.Name()),
+		Tags:            tags,
+		Fields:          fields,
+		Time:            point.Time().UTC(),
+	}, nil
+}
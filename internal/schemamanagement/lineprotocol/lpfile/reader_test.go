@@ -0,0 +1,110 @@
+package lpfile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const exportDump = `# INFLUXDB EXPORT: 1677-09-21T00:12:43Z - 2262-04-11T23:47:16Z
+# DDL
+CREATE DATABASE db WITH NAME autogen
+# DML
+# CONTEXT-DATABASE:db
+# CONTEXT-RETENTION-POLICY:autogen
+# writing tsm data
+cpu,host=a\ b,region=eu usage=1.5,count=2i,up=true 1546300800000000000
+
+cpu,host=c msg="x y" 1546300801000000000
+# CONTEXT-DATABASE:other
+mem used=1i 1546300802000000000`
+
+func readAll(t *testing.T, reader *Reader) []*Point {
+	points := []*Point{}
+	for {
+		point, err := reader.Next()
+		if err == io.EOF {
+			return points
+		} else if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		points = append(points, point)
+	}
+}
+
+func TestReadExport(t *testing.T) {
+	gzipped := &bytes.Buffer{}
+	writer := gzip.NewWriter(gzipped)
+	writer.Write([]byte(exportDump))
+	writer.Close()
+
+	t1 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	expected := []*Point{
+		{
+			Database: "db", RetentionPolicy: "autogen", Measurement: "cpu",
+			Tags:   map[string]string{"host": "a b", "region": "eu"},
+			Fields: map[string]interface{}{"usage": 1.5, "count": int64(2), "up": true},
+			Time:   t1,
+		}, {
+			Database: "db", RetentionPolicy: "autogen", Measurement: "cpu",
+			Tags:   map[string]string{"host": "c"},
+			Fields: map[string]interface{}{"msg": "x y"},
+			Time:   t1.Add(time.Second),
+		}, {
+			Database: "other", RetentionPolicy: "autogen", Measurement: "mem",
+			Tags:   map[string]string{},
+			Fields: map[string]interface{}{"used": int64(1)},
+			Time:   t1.Add(2 * time.Second),
+		},
+	}
+
+	for _, content := range []io.Reader{strings.NewReader(exportDump), gzipped} {
+		reader, err := NewReader(content)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, readAll(t, reader))
+	}
+}
+
+func TestReadPlainLineProtocol(t *testing.T) {
+	reader, _ := NewReader(strings.NewReader("cpu value=1 1546300800000000000\n"))
+	points := readAll(t, reader)
+	assert.Equal(t, 1, len(points))
+	assert.True(t, points[0].InContext("any", "autogen"))
+}
+
+func TestReadErrors(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		content string
+		err     string
+	}{
+		{desc: "bad point", content: "# DML\ncpu value= 1\n", err: "line 2: could not parse point"},
+		{desc: "no timestamp", content: "cpu value=1\n", err: "line 1: point has no timestamp"},
+	}
+
+	for _, tc := range testCases {
+		reader, _ := NewReader(strings.NewReader(tc.content))
+		_, err := reader.Next()
+		if assert.Error(t, err, tc.desc) {
+			assert.Contains(t, err.Error(), tc.err, tc.desc)
+		}
+	}
+
+	_, err := NewReader(bytes.NewReader(gzipMagic))
+	assert.Error(t, err, "truncated gzip header")
+	_, err = Open("/does/not/exist")
+	assert.Error(t, err)
+}
+
+func TestInContext(t *testing.T) {
+	point := &Point{Database: "db", RetentionPolicy: "rp"}
+	assert.True(t, point.InContext("db", "rp"))
+	assert.False(t, point.InContext("db", "autogen"))
+	assert.False(t, point.InContext("other", "rp"))
+}
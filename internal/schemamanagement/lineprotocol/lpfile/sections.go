@@ -0,0 +1,116 @@
+package lpfile
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// Section is a range of lines of a line protocol file, from the offset of its first line to the
+// offset after its last one. The offsets count the bytes of the decompressed content of a gzipped
+// file. Line is the number of the first line, and the context is the export context it is read in
+type Section struct {
+	Start           int64
+	End             int64
+	Line            int
+	Database        string
+	RetentionPolicy string
+}
+
+// SectionReader reads the points in the sections of a line protocol file, in the order of the
+// sections. The content between the sections is skipped without being parsed: a plain file is
+// seeked, and the content of a gzipped file is decompressed and discarded
+type SectionReader struct {
+	content  io.Reader
+	skip     func(n int64) error
+	closer   io.Closer
+	sections []Section
+	position int64
+	current  *Reader
+}
+
+// OpenSections opens the line protocol file at path to read the points in the sections. The
+// sections must be sorted by offset and must not overlap
+func OpenSections(path string, sections []Section) (*SectionReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open line protocol file\n%v", err)
+	}
+
+	magic := make([]byte, len(gzipMagic))
+	n, err := io.ReadFull(file, magic)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		file.Close()
+		return nil, fmt.Errorf("could not read line protocol\n%v", err)
+	}
+
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("could not read line protocol\n%v", err)
+	}
+
+	reader := &SectionReader{content: file, closer: file, sections: sections}
+	reader.skip = func(n int64) error {
+		_, err := file.Seek(n, io.SeekCurrent)
+		return err
+	}
+
+	if bytes.Equal(magic[:n], gzipMagic) {
+		decompressed, err := gzip.NewReader(bufio.NewReader(file))
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("could not decompress line protocol\n%v", err)
+		}
+
+		reader.content = decompressed
+		reader.skip = func(n int64) error {
+			_, err := io.CopyN(ioutil.Discard, decompressed, n)
+			return err
+		}
+	}
+
+	return reader, nil
+}
+
+// Next returns the next point of the sections, or io.EOF when all sections have been read
+func (r *SectionReader) Next() (*Point, error) {
+	for {
+		if r.current != nil {
+			point, err := r.current.Next()
+			if err != io.EOF {
+				return point, err
+			}
+
+			r.current = nil
+		}
+
+		if len(r.sections) == 0 {
+			return nil, io.EOF
+		}
+
+		section := r.sections[0]
+		r.sections = r.sections[1:]
+		if err := r.skip(section.Start - r.position); err != nil {
+			return nil, fmt.Errorf("could not skip to line %d\n%v", section.Line, err)
+		}
+
+		// the reader stops at the end of the section, where the content is positioned for the next one
+		r.position = section.End
+		r.current = &Reader{
+			source:          bufio.NewReader(io.LimitReader(r.content, section.End-section.Start)),
+			line:            section.Line - 1,
+			offset:          section.Start,
+			database:        section.Database,
+			retentionPolicy: section.RetentionPolicy,
+		}
+	}
+}
+
+// Close closes the file
+func (r *SectionReader) Close() error {
+	return r.closer.Close()
+}
@@ -0,0 +1,134 @@
+package lpfile
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sectionsDump = `# DDL
+CREATE DATABASE db WITH NAME autogen
+# DML
+# CONTEXT-DATABASE:db
+# CONTEXT-RETENTION-POLICY:autogen
+cpu value=1 1546300800000000000
+mem value=2 1546300800000000000
+cpu value=3 1546300801000000000
+cpu value=4 1546300802000000000
+# CONTEXT-DATABASE:other
+cpu value=5 1546300803000000000
+cpu value= 1546300804000000000
+`
+
+func writeSectionsFile(t *testing.T, gzipped bool) string {
+	file, err := ioutil.TempFile("", "outflux_lp")
+	if err != nil {
+		t.Fatalf("could not create file: %v", err)
+	}
+
+	defer file.Close()
+	var writer io.Writer = file
+	if gzipped {
+		compressed := gzip.NewWriter(file)
+		defer compressed.Close()
+		writer = compressed
+	}
+
+	if _, err = writer.Write([]byte(sectionsDump)); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+
+	return file.Name()
+}
+
+// lineSections returns the section spanning the lines from first to last of the dump, numbered from 1
+func lineSections(first, last int, db string) Section {
+	lines := strings.SplitAfter(sectionsDump, "\n")
+	section := Section{Line: first, Database: db, RetentionPolicy: "autogen"}
+	for i, line := range lines[:last] {
+		if i < first-1 {
+			section.Start += int64(len(line))
+		}
+
+		section.End += int64(len(line))
+	}
+
+	return section
+}
+
+func TestLastSection(t *testing.T) {
+	reader, _ := NewReader(strings.NewReader(sectionsDump))
+	sections := []Section{}
+	for {
+		point, err := reader.Next()
+		if err != nil {
+			break
+		}
+
+		if point.Measurement == "cpu" {
+			sections = append(sections, reader.LastSection())
+		}
+	}
+
+	assert.Equal(t, []Section{lineSections(6, 6, "db"), lineSections(8, 8, "db"), lineSections(9, 9, "db"), lineSections(11, 11, "other")}, sections)
+}
+
+func TestReadSections(t *testing.T) {
+	for _, gzipped := range []bool{false, true} {
+		path := writeSectionsFile(t, gzipped)
+		defer os.Remove(path)
+
+		reader, err := OpenSections(path, []Section{lineSections(6, 6, "db"), lineSections(8, 9, "db")})
+		if err != nil {
+			t.Fatalf("could not open file: %v", err)
+		}
+
+		values := []interface{}{}
+		for {
+			point, err := reader.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			assert.Equal(t, "cpu", point.Measurement)
+			assert.True(t, point.InContext("db", "autogen"))
+			values = append(values, point.Fields["value"])
+		}
+
+		assert.Equal(t, []interface{}{1.0, 3.0, 4.0}, values, "gzipped: %v", gzipped)
+		assert.NoError(t, reader.Close())
+
+		// the lines of a section are numbered as in the file
+		reader, err = OpenSections(path, []Section{lineSections(12, 12, "other")})
+		if err != nil {
+			t.Fatalf("could not open file: %v", err)
+		}
+
+		_, err = reader.Next()
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "line 12: could not parse point")
+		}
+
+		reader.Close()
+	}
+}
+
+func TestOpenSectionsErrors(t *testing.T) {
+	_, err := OpenSections("/does/not/exist", nil)
+	assert.Error(t, err)
+
+	path := writeSectionsFile(t, false)
+	defer os.Remove(path)
+	reader, err := OpenSections(path, []Section{})
+	assert.NoError(t, err)
+	_, err = reader.Next()
+	assert.Equal(t, io.EOF, err)
+	reader.Close()
+}
@@ -1,25 +1,44 @@
 package schemamanagement
 
 import (
+	"time"
+
 	influx "github.com/influxdata/influxdb/client/v2"
 	"github.com/timescale/outflux/internal/connections"
 	influxSchema "github.com/timescale/outflux/internal/schemamanagement/influx"
 	"github.com/timescale/outflux/internal/schemamanagement/influx/discovery"
+	"github.com/timescale/outflux/internal/schemamanagement/influxv2"
+	"github.com/timescale/outflux/internal/schemamanagement/lineprotocol"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
 	tsSchema "github.com/timescale/outflux/internal/schemamanagement/ts"
+	"github.com/timescale/outflux/internal/schemamanagement/tsm"
+	"github.com/timescale/outflux/internal/schemamanagement/tsm/tsmstorage"
 )
 
 // SchemaManagerService defines methods for creating SchemaManagers
 type SchemaManagerService interface {
 	Influx(client influx.Client, db, rp string, onConflictConvertIntToFloat bool) SchemaManager
-	TimeScale(dbConn connections.PgxWrap, schema, chunkTimeInterval string) SchemaManager
+	InfluxV2(client connections.InfluxV2Client, bucket string, onConflictConvertIntToFloat bool) SchemaManager
+	LineProtocol(path, db, rp string, onConflictConvertIntToFloat bool) SchemaManager
+	TSM(storage *tsmstorage.Storage, db, rp string, onConflictConvertIntToFloat bool) SchemaManager
+	TimeScale(dbConn connections.PgxWrap, schema, chunkTimeInterval string, retentionPeriod time.Duration, compression *schemaconfig.Compression, spacePartitioning *schemaconfig.SpacePartitioning, indexes *schemaconfig.Indexes) SchemaManager
 }
 
 // NewSchemaManagerService returns an instance of SchemaManagerService
-func NewSchemaManagerService(measureExplorer discovery.MeasureExplorer, tagExplorer discovery.TagExplorer, fieldExplorer discovery.FieldExplorer) SchemaManagerService {
+func NewSchemaManagerService(
+	measureExplorer discovery.MeasureExplorer,
+	tagExplorer discovery.TagExplorer,
+	fieldExplorer discovery.FieldExplorer,
+	v2Explorer influxv2.Explorer,
+	lineProtocolExplorer lineprotocol.Explorer,
+	tsmExplorer tsm.Explorer) SchemaManagerService {
 	return &schemaManagerService{
 		tagExplorer:     tagExplorer,
 		fieldExplorer:   fieldExplorer,
 		measureExplorer: measureExplorer,
+		v2Explorer:      v2Explorer,
+		lpExplorer:      lineProtocolExplorer,
+		tsmExplorer:     tsmExplorer,
 	}
 }
 
@@ -27,6 +46,9 @@ type schemaManagerService struct {
 	tagExplorer     discovery.TagExplorer
 	fieldExplorer   discovery.FieldExplorer
 	measureExplorer discovery.MeasureExplorer
+	v2Explorer      influxv2.Explorer
+	lpExplorer      lineprotocol.Explorer
+	tsmExplorer     tsm.Explorer
 }
 
 // Influx creates new schema manager that can discover influx data sets
@@ -34,6 +56,21 @@ func (s *schemaManagerService) Influx(client influx.Client, db, rp string, onCon
 	return influxSchema.NewSchemaManager(client, db, rp, onConflictConvertIntToFloat, s.measureExplorer, s.tagExplorer, s.fieldExplorer)
 }
 
-func (s *schemaManagerService) TimeScale(dbConn connections.PgxWrap, schema, chunkTimeInterval string) SchemaManager {
-	return tsSchema.NewTSSchemaManager(dbConn, schema, chunkTimeInterval)
+// InfluxV2 creates new schema manager that can discover the data sets of an InfluxDB 2.x bucket
+func (s *schemaManagerService) InfluxV2(client connections.InfluxV2Client, bucket string, onConflictConvertIntToFloat bool) SchemaManager {
+	return influxv2.NewSchemaManager(client, bucket, onConflictConvertIntToFloat, s.v2Explorer)
+}
+
+// LineProtocol creates new schema manager that infers the data sets of a database in a line protocol file
+func (s *schemaManagerService) LineProtocol(path, db, rp string, onConflictConvertIntToFloat bool) SchemaManager {
+	return lineprotocol.NewSchemaManager(path, db, rp, onConflictConvertIntToFloat, s.lpExplorer)
+}
+
+// TSM creates new schema manager that infers the data sets of a database stored in TSM shards
+func (s *schemaManagerService) TSM(storage *tsmstorage.Storage, db, rp string, onConflictConvertIntToFloat bool) SchemaManager {
+	return tsm.NewSchemaManager(storage, db, rp, onConflictConvertIntToFloat, s.tsmExplorer)
+}
+
+func (s *schemaManagerService) TimeScale(dbConn connections.PgxWrap, schema, chunkTimeInterval string, retentionPeriod time.Duration, compression *schemaconfig.Compression, spacePartitioning *schemaconfig.SpacePartitioning, indexes *schemaconfig.Indexes) SchemaManager {
+	return tsSchema.NewTSSchemaManager(dbConn, schema, chunkTimeInterval, retentionPeriod, compression, spacePartitioning, indexes)
 }
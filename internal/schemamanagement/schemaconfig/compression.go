@@ -0,0 +1,14 @@
+package schemaconfig
+
+import "time"
+
+// Compression holds the native compression settings of the hypertables created by Outflux
+type Compression struct {
+	// SegmentBy columns the compressed rows are grouped by, none if empty
+	SegmentBy []string
+	// OrderBy the compressed rows are sorted by, the TimescaleDB default (time descending) if empty
+	OrderBy string
+	// CompressAfter if > 0, the chunks with data older than it are compressed by a compression
+	// policy, and once the data of a measure is migrated
+	CompressAfter time.Duration
+}
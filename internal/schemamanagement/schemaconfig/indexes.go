@@ -0,0 +1,8 @@
+package schemaconfig
+
+// Indexes selects the indexes created on a hypertable once its data is loaded, creating
+// them before would slow down the inserts. Each JSONB column also gets a GIN index
+type Indexes struct {
+	// Columns each get a composite index with the time column, ordered by time descending
+	Columns []string
+}
@@ -0,0 +1,37 @@
+package schemaconfig
+
+import "fmt"
+
+// OutputType is an enum representing the kind of database the data is migrated to
+type OutputType int
+
+// Enum values for OutputType
+const (
+	// The tables are hypertables of the TimescaleDB extension
+	TimescaleDBOutput OutputType = iota + 1
+	// The tables are range partitioned on the time column by PostgreSQL, without TimescaleDB
+	PostgreSQLOutput
+)
+
+func (o OutputType) String() string {
+	switch o {
+	case TimescaleDBOutput:
+		return "TimescaleDB"
+	case PostgreSQLOutput:
+		return "PostgreSQL"
+	default:
+		panic("unknown type")
+	}
+}
+
+// ParseOutputTypeString returns the enum value matching the string, or an error
+func ParseOutputTypeString(outputType string) (OutputType, error) {
+	switch outputType {
+	case "TimescaleDB":
+		return TimescaleDBOutput, nil
+	case "PostgreSQL":
+		return PostgreSQLOutput, nil
+	default:
+		return TimescaleDBOutput, fmt.Errorf("unknown output type '%s'", outputType)
+	}
+}
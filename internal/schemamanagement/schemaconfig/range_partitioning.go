@@ -0,0 +1,17 @@
+package schemaconfig
+
+import "time"
+
+// TimeRange holds the times of the oldest and newest rows of a table, both included
+type TimeRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// RangePartitioning describes the native range partitions of a plain PostgreSQL table on its
+// time column. Each partition holds an interval of time aligned to the Unix epoch
+type RangePartitioning struct {
+	Interval time.Duration
+	// Range if not nil, the partitions covering it are created before the data is loaded
+	Range *TimeRange
+}
@@ -0,0 +1,50 @@
+package schemaconfig
+
+import "fmt"
+
+// RetentionPolicyMapping is an enum representing how the data of a retention policy
+// is kept apart from the data of other retention policies in the target database
+type RetentionPolicyMapping int
+
+// Enum values for RetentionPolicyMapping
+const (
+	// The measurements are stored in tables named after them
+	NoRPMapping RetentionPolicyMapping = iota + 1
+	// The tables are created in a schema named after the retention policy
+	RPToSchema
+	// The tables are named after the retention policy and the measurement
+	RPToTablePrefix
+	// The retention policies of a measurement share a table, with a column holding the retention policy
+	RPToColumn
+)
+
+func (m RetentionPolicyMapping) String() string {
+	switch m {
+	case NoRPMapping:
+		return "None"
+	case RPToSchema:
+		return "Schema"
+	case RPToTablePrefix:
+		return "Prefix"
+	case RPToColumn:
+		return "Column"
+	default:
+		panic("unknown type")
+	}
+}
+
+// ParseRetentionPolicyMappingString returns the enum value matching the string, or an error
+func ParseRetentionPolicyMappingString(mapping string) (RetentionPolicyMapping, error) {
+	switch mapping {
+	case "None":
+		return NoRPMapping, nil
+	case "Schema":
+		return RPToSchema, nil
+	case "Prefix":
+		return RPToTablePrefix, nil
+	case "Column":
+		return RPToColumn, nil
+	default:
+		return NoRPMapping, fmt.Errorf("unknown retention policy mapping '%s'", mapping)
+	}
+}
@@ -0,0 +1,36 @@
+package schemaconfig
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SpacePartitioning selects the column the hypertable of a measure is hash partitioned by, in
+// addition to the time column, and the number of partitions
+type SpacePartitioning struct {
+	Column     string
+	Partitions int
+}
+
+// ParseSpacePartitioningString parses a 'column:partitions' string. The number of partitions
+// must be > 0 and fit the smallint of the TimescaleDB catalog
+func ParseSpacePartitioningString(partitioning string) (*SpacePartitioning, error) {
+	parts := strings.SplitN(partitioning, ":", 2)
+	column := strings.TrimSpace(parts[0])
+	if len(parts) != 2 || column == "" {
+		return nil, fmt.Errorf("space partitioning '%s' must be formatted as column:partitions", partitioning)
+	}
+
+	partitions, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil || partitions <= 0 || partitions > math.MaxInt16 {
+		return nil, fmt.Errorf("number of partitions in '%s' must be an integer > 0 and <= %d", partitioning, math.MaxInt16)
+	}
+
+	return &SpacePartitioning{Column: column, Partitions: partitions}, nil
+}
+
+func (p *SpacePartitioning) String() string {
+	return fmt.Sprintf("%s:%d", p.Column, p.Partitions)
+}
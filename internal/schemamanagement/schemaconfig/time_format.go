@@ -0,0 +1,44 @@
+package schemaconfig
+
+import "fmt"
+
+// TimeFormat is an enum representing how the time of the points is stored
+// in the target database
+type TimeFormat int
+
+// Enum values for TimeFormat
+const (
+	// A TIMESTAMPTZ column, truncated to microseconds
+	Timestamptz TimeFormat = iota + 1
+	// A TIMESTAMPTZ column and an INTEGER column with the nanoseconds lost in the truncation
+	TimestamptzWithNanos
+	// A BIGINT column with the nanoseconds since the Unix epoch
+	EpochNanos
+)
+
+func (f TimeFormat) String() string {
+	switch f {
+	case Timestamptz:
+		return "Timestamptz"
+	case TimestamptzWithNanos:
+		return "TimestamptzWithNanos"
+	case EpochNanos:
+		return "EpochNanos"
+	default:
+		panic("unknown type")
+	}
+}
+
+// ParseTimeFormatString returns the enum value matching the string, or an error
+func ParseTimeFormatString(format string) (TimeFormat, error) {
+	switch format {
+	case "Timestamptz":
+		return Timestamptz, nil
+	case "TimestamptzWithNanos":
+		return TimestamptzWithNanos, nil
+	case "EpochNanos":
+		return EpochNanos, nil
+	default:
+		return Timestamptz, fmt.Errorf("unknown time format '%s'", format)
+	}
+}
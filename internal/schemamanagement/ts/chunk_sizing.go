@@ -0,0 +1,64 @@
+package ts
+
+import (
+	"time"
+
+	"github.com/timescale/outflux/internal/idrf"
+)
+
+// estimated on-disk sizes used to pick a chunk_time_interval. A row has a tuple header
+// and an item pointer, text and JSONB values are assumed to be short
+const (
+	rowOverheadBytes     = 28
+	textValueBytes       = 16
+	jsonValueBytes       = 64
+	MinChunkTimeInterval = time.Minute
+	MaxChunkTimeInterval = 365 * 24 * time.Hour
+)
+
+// EstimateRowBytes returns the estimated size of a row with the columns in a hypertable
+func EstimateRowBytes(columns []*idrf.Column) int {
+	size := rowOverheadBytes
+	for _, column := range columns {
+		switch column.DataType {
+		case idrf.IDRFBoolean:
+			size++
+		case idrf.IDRFInteger32, idrf.IDRFSingle:
+			size += 4
+		case idrf.IDRFString:
+			size += textValueBytes
+		case idrf.IDRFJson:
+			size += jsonValueBytes
+		default:
+			size += 8
+		}
+	}
+
+	return size
+}
+
+// ChunkTimeIntervalForDensity returns the interval a chunk of rows of rowBytes arriving at pointsPerSecond
+// must cover to hold targetBytes. It is rounded down to whole hours, or whole minutes when shorter than an
+// hour, and kept between MinChunkTimeInterval and MaxChunkTimeInterval
+func ChunkTimeIntervalForDensity(pointsPerSecond float64, rowBytes int, targetBytes uint64) time.Duration {
+	bytesPerSecond := pointsPerSecond * float64(rowBytes)
+	if bytesPerSecond <= 0 {
+		return MaxChunkTimeInterval
+	}
+
+	seconds := float64(targetBytes) / bytesPerSecond
+	if seconds >= MaxChunkTimeInterval.Seconds() {
+		return MaxChunkTimeInterval
+	}
+
+	interval := time.Duration(seconds * float64(time.Second))
+	if interval >= time.Hour {
+		return interval.Truncate(time.Hour)
+	}
+
+	if interval = interval.Truncate(time.Minute); interval < MinChunkTimeInterval {
+		return MinChunkTimeInterval
+	}
+
+	return interval
+}
@@ -0,0 +1,43 @@
+package ts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/idrf"
+)
+
+func TestEstimateRowBytes(t *testing.T) {
+	columns := []*idrf.Column{
+		{Name: "time", DataType: idrf.IDRFTimestamptz},
+		{Name: "host", DataType: idrf.IDRFString},
+		{Name: "up", DataType: idrf.IDRFBoolean},
+		{Name: "load", DataType: idrf.IDRFDouble},
+		{Name: "cpu", DataType: idrf.IDRFInteger32},
+		{Name: "tags", DataType: idrf.IDRFJson},
+	}
+	assert.Equal(t, rowOverheadBytes, EstimateRowBytes(nil))
+	assert.Equal(t, rowOverheadBytes+8+textValueBytes+1+8+4+jsonValueBytes, EstimateRowBytes(columns))
+}
+
+func TestChunkTimeIntervalForDensity(t *testing.T) {
+	megabyte := uint64(1024 * 1024)
+	testCases := []struct {
+		desc            string
+		pointsPerSecond float64
+		rowBytes        int
+		targetBytes     uint64
+		expected        time.Duration
+	}{
+		{desc: "no points", rowBytes: 100, targetBytes: megabyte, expected: MaxChunkTimeInterval},
+		{desc: "sparse points", pointsPerSecond: 0.001, rowBytes: 100, targetBytes: 256 * megabyte, expected: MaxChunkTimeInterval},
+		{desc: "rounded to hours", pointsPerSecond: 1, rowBytes: 100, targetBytes: 256 * megabyte, expected: 745 * time.Hour},
+		{desc: "rounded to minutes", pointsPerSecond: 10, rowBytes: 100, targetBytes: megabyte, expected: 17 * time.Minute},
+		{desc: "dense points", pointsPerSecond: 1000, rowBytes: 100, targetBytes: megabyte, expected: MinChunkTimeInterval},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, ChunkTimeIntervalForDensity(tc.pointsPerSecond, tc.rowBytes, tc.targetBytes), tc.desc)
+	}
+}
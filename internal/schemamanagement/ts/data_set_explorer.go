@@ -0,0 +1,78 @@
+package ts
+
+import (
+	"github.com/timescale/outflux/internal/connections"
+)
+
+const (
+	// the partitions of a partitioned table are not data sets of their own, the chunks of
+	// hypertables are in another schema
+	schemaTablesQueryTemplate = `SELECT c.relname
+	                             FROM pg_class c
+	                             JOIN pg_namespace n ON n.oid = c.relnamespace
+	                             WHERE n.nspname = $1 AND c.relkind IN ('r', 'p') AND NOT c.relispartition
+	                             ORDER BY c.relname;`
+	timeDimensionQueryTemplate = `SELECT d.column_name
+	                              FROM _timescaledb_catalog.dimension d
+	                              JOIN _timescaledb_catalog.hypertable h ON d.hypertable_id = h.id
+	                              WHERE h.schema_name = $1 AND h.table_name = $2
+	                              ORDER BY d.id ASC LIMIT 1;`
+)
+
+// dataSetExplorer finds the tables read as data sets when the database is the input of a migration
+type dataSetExplorer interface {
+	// schemaTables returns the names of the tables of a schema
+	schemaTables(db connections.PgxWrap, schemaName string) ([]string, error)
+	// timeDimension returns the column of the first dimension of a hypertable, or an empty string
+	// if the table is not a hypertable
+	timeDimension(db connections.PgxWrap, schemaName, tableName string) (string, error)
+}
+
+type defaultDataSetExplorer struct{}
+
+func (e *defaultDataSetExplorer) schemaTables(db connections.PgxWrap, schemaName string) ([]string, error) {
+	if schemaName == "" {
+		schemaName = "public"
+	}
+
+	rows, err := db.Query(schemaTablesQueryTemplate, schemaName)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	tables := []string{}
+	for rows.Next() {
+		var table string
+		if err = rows.Scan(&table); err != nil {
+			return nil, err
+		}
+
+		tables = append(tables, table)
+	}
+
+	return tables, rows.Err()
+}
+
+func (e *defaultDataSetExplorer) timeDimension(db connections.PgxWrap, schemaName, tableName string) (string, error) {
+	if schemaName == "" {
+		schemaName = "public"
+	}
+
+	rows, err := db.Query(timeDimensionQueryTemplate, schemaName, tableName)
+	if err != nil {
+		return "", err
+	}
+
+	defer rows.Close()
+	column := ""
+	if rows.Next() {
+		err = rows.Scan(&column)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return column, rows.Err()
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
 	"github.com/timescale/outflux/internal/testutils"
 )
 
@@ -29,6 +30,8 @@ func TestIsTimePartitionedBy(t *testing.T) {
 	wrongCol := "wrong_column"
 	okTable := "good_hypertable"
 	okCol := "ok_column"
+	spaceTable := "space_partitioned"
+	spaceCol := "host"
 
 	dbConn, err := testutils.OpenTSConn(db)
 	if err != nil {
@@ -51,19 +54,28 @@ func TestIsTimePartitionedBy(t *testing.T) {
 	dbConn.Exec(createWrongPartColHypertable)
 	dbConn.Exec(createWrongPartColumnName)
 	dbConn.Exec(createWrongPartColNameHypertable)
+	dbConn.Exec(fmt.Sprintf("CREATE TABLE %s (%s TIMESTAMPTZ NOT NULL, %s TEXT)", spaceTable, okCol, spaceCol))
+	dbConn.Exec(fmt.Sprintf("SELECT create_hypertable('%s','%s')", spaceTable, okCol))
+	dbConn.Exec(fmt.Sprintf("SELECT add_dimension('%s','%s', number_partitions => 4)", spaceTable, spaceCol))
 	tcs := []struct {
 		table     string
 		timeCol   string
+		space     *schemaconfig.SpacePartitioning
 		expectRes bool
 	}{
 		{table: notHypertable},
 		{table: wrongPartitionType},
 		{table: wrongPartitioningCol, timeCol: okCol},
 		{table: okTable, timeCol: okCol, expectRes: true},
+		{table: okTable, timeCol: okCol, space: &schemaconfig.SpacePartitioning{Column: spaceCol, Partitions: 4}},
+		{table: spaceTable, timeCol: okCol, expectRes: true},
+		{table: spaceTable, timeCol: okCol, space: &schemaconfig.SpacePartitioning{Column: spaceCol, Partitions: 4}, expectRes: true},
+		{table: spaceTable, timeCol: okCol, space: &schemaconfig.SpacePartitioning{Column: spaceCol, Partitions: 2}},
+		{table: spaceTable, timeCol: okCol, space: &schemaconfig.SpacePartitioning{Column: "other", Partitions: 4}},
 	}
 
 	for _, tc := range tcs {
-		res, err := checker.isTimePartitionedBy(dbConn, "", tc.table, tc.timeCol)
+		res, err := checker.isTimePartitionedBy(dbConn, "", tc.table, tc.timeCol, tc.space)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
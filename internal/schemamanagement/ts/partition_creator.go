@@ -0,0 +1,159 @@
+package ts
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/timescale/outflux/internal/connections"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+)
+
+// a plain PostgreSQL table is range partitioned on the time column, its partitions are named after
+// their start in UTC and are not created if they exist
+const (
+	partitionByRangeTemplate          = `%s PARTITION BY RANGE ("%s")`
+	createPartitionTemplate           = `CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM (%s) TO (%s)`
+	partitionNameTemplate             = "%s_%s"
+	partitionNameTimeFormat           = "20060102T150405"
+	timestampPartitionBound           = `'%s'`
+	isRangePartitionedByQueryTemplate = `SELECT p.partstrat = 'r' AND p.partnatts = 1 AND a.attname = $3
+	                                     FROM pg_partitioned_table p
+	                                     JOIN pg_class c ON c.oid = p.partrelid
+	                                     JOIN pg_namespace n ON n.oid = c.relnamespace
+	                                     JOIN pg_attribute a ON a.attrelid = p.partrelid AND a.attnum = p.partattrs[0]
+	                                     WHERE n.nspname = $1 AND c.relname = $2`
+	// maxRangePartitions is the most partitions created for the time range of a table
+	maxRangePartitions = 10000
+)
+
+type partitionCreator interface {
+	CreatePartitionedTable(db connections.PgxWrap, info *idrf.DataSet) error
+	CreatePartitions(db connections.PgxWrap, info *idrf.DataSet, partitioning *schemaconfig.RangePartitioning) (int, error)
+}
+
+type rangePartitionExplorer interface {
+	isRangePartitionedBy(db connections.PgxWrap, schema, table, column string) (bool, error)
+}
+
+func newPartitionCreator(schema string) partitionCreator {
+	return &defaultPartitionCreator{schema: schema}
+}
+
+type defaultPartitionCreator struct {
+	schema string
+}
+
+type defaultRangePartitionExplorer struct{}
+
+// CreatePartitionedTable creates a table range partitioned on its time column, without any partition
+func (d *defaultPartitionCreator) CreatePartitionedTable(dbConn connections.PgxWrap, info *idrf.DataSet) error {
+	query := fmt.Sprintf(partitionByRangeTemplate, dataSetToSQLTableDef(d.schema, info), info.TimeColumn)
+	log.Printf("Creating partitioned table with:\n %s", query)
+	_, err := dbConn.Exec(query)
+	return err
+}
+
+// CreatePartitions creates the partitions of the table covering the time range of the partitioning,
+// and returns how many partitions cover it. A BIGINT time column holds nanoseconds since the Unix epoch
+func (d *defaultPartitionCreator) CreatePartitions(dbConn connections.PgxWrap, info *idrf.DataSet, partitioning *schemaconfig.RangePartitioning) (int, error) {
+	if partitioning.Range == nil {
+		return 0, nil
+	}
+
+	starts, err := partitionStarts(partitioning.Interval, partitioning.Range)
+	if err != nil {
+		return 0, err
+	}
+
+	tableName := d.qualifiedName(info.DataSetName)
+	for _, start := range starts {
+		end := start.Add(partitioning.Interval)
+		partitionName := d.qualifiedName(fmt.Sprintf(partitionNameTemplate, info.DataSetName, start.Format(partitionNameTimeFormat)))
+		query := fmt.Sprintf(createPartitionTemplate, partitionName, tableName, partitionBound(info, start), partitionBound(info, end))
+		log.Printf("Creating partition with: %s", query)
+		if _, err := dbConn.Exec(query); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(starts), nil
+}
+
+func (d *defaultPartitionCreator) qualifiedName(name string) string {
+	if d.schema != "" {
+		return fmt.Sprintf(tableNameWithSchemaTemplate, d.schema, name)
+	}
+
+	return fmt.Sprintf(tableNameTemplate, name)
+}
+
+// isRangePartitionedBy checks that the table is range partitioned on the column alone
+func (e *defaultRangePartitionExplorer) isRangePartitionedBy(db connections.PgxWrap, schema, table, column string) (bool, error) {
+	if schema == "" {
+		schema = "public"
+	}
+
+	rows, err := db.Query(isRangePartitionedByQueryTemplate, schema, table, column)
+	if err != nil {
+		return false, err
+	}
+
+	defer rows.Close()
+	if !rows.Next() {
+		log.Printf("Table %s is not partitioned", table)
+		return false, rows.Err()
+	}
+
+	partitionedBy := false
+	err = rows.Scan(&partitionedBy)
+	return partitionedBy, err
+}
+
+// partitionStarts returns the start of each partition covering the time range, the partitions
+// are aligned to the Unix epoch. Returns an error if the range needs too many partitions
+func partitionStarts(interval time.Duration, timeRange *schemaconfig.TimeRange) ([]time.Time, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("partition interval must be > 0")
+	}
+
+	if timeRange.To.Before(timeRange.From) {
+		return nil, fmt.Errorf("the time range ends at %s, before it starts at %s", timeRange.To.Format(time.RFC3339), timeRange.From.Format(time.RFC3339))
+	}
+
+	first := floorToInterval(timeRange.From, interval)
+	last := floorToInterval(timeRange.To, interval)
+	count := (last-first)/int64(interval) + 1
+	if count > maxRangePartitions {
+		return nil, fmt.Errorf("the time range from %s to %s needs %d partitions of %s, more than %d",
+			timeRange.From.Format(time.RFC3339), timeRange.To.Format(time.RFC3339), count, interval, maxRangePartitions)
+	}
+
+	starts := make([]time.Time, 0, count)
+	for start := first; start <= last; start += int64(interval) {
+		starts = append(starts, time.Unix(0, start).UTC())
+	}
+
+	return starts, nil
+}
+
+// floorToInterval returns the nanoseconds since the Unix epoch of the start of the interval the time is in
+func floorToInterval(t time.Time, interval time.Duration) int64 {
+	nanos := t.UnixNano()
+	remainder := nanos % int64(interval)
+	if remainder < 0 {
+		remainder += int64(interval)
+	}
+
+	return nanos - remainder
+}
+
+func partitionBound(info *idrf.DataSet, bound time.Time) string {
+	if isEpochTime(info) {
+		return strconv.FormatInt(bound.UnixNano(), 10)
+	}
+
+	return fmt.Sprintf(timestampPartitionBound, bound.Format(time.RFC3339))
+}
@@ -0,0 +1,58 @@
+// +build integration
+
+package ts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+	"github.com/timescale/outflux/internal/testutils"
+)
+
+func TestIntegratedCreatePartitions(t *testing.T) {
+	db := "test_create_partitions"
+	require.NoError(t, testutils.DeleteTimescaleDb(db))
+	require.NoError(t, testutils.CreateTimescaleDb(db))
+	defer testutils.DeleteTimescaleDb(db)
+	dbConn, err := testutils.OpenTSConn(db)
+	require.NoError(t, err)
+	defer dbConn.Close()
+	dataSet := &idrf.DataSet{
+		DataSetName: "name",
+		Columns: []*idrf.Column{
+			{Name: "col1", DataType: idrf.IDRFTimestamptz},
+			{Name: "col2", DataType: idrf.IDRFInteger64},
+		},
+		TimeColumn: "col1",
+	}
+	timeRange := &schemaconfig.TimeRange{From: time.Date(2019, 1, 1, 10, 0, 0, 0, time.UTC), To: time.Date(2019, 1, 2, 10, 0, 0, 0, time.UTC)}
+	partitioning := &schemaconfig.RangePartitioning{Interval: 24 * time.Hour, Range: timeRange}
+	creator := newPartitionCreator("")
+	require.NoError(t, creator.CreatePartitionedTable(dbConn, dataSet))
+	partitions, err := creator.CreatePartitions(dbConn, dataSet, partitioning)
+	require.NoError(t, err)
+	assert.Equal(t, 2, partitions)
+	// existing partitions are kept
+	_, err = creator.CreatePartitions(dbConn, dataSet, partitioning)
+	require.NoError(t, err)
+
+	_, err = dbConn.Exec(`INSERT INTO name VALUES ('2019-01-01T00:00:00Z', 1), ('2019-01-02T23:59:59Z', 2)`)
+	require.NoError(t, err)
+	_, err = dbConn.Exec(`INSERT INTO name VALUES ('2019-01-03T00:00:00Z', 3)`)
+	assert.Error(t, err)
+
+	explorer := &defaultRangePartitionExplorer{}
+	partitioned, err := explorer.isRangePartitionedBy(dbConn, "", "name", "col1")
+	require.NoError(t, err)
+	assert.True(t, partitioned)
+	partitioned, err = explorer.isRangePartitionedBy(dbConn, "", "name", "col2")
+	require.NoError(t, err)
+	assert.False(t, partitioned)
+	partitioned, err = explorer.isRangePartitionedBy(dbConn, "", "name_20190101T000000", "col1")
+	require.NoError(t, err)
+	assert.False(t, partitioned)
+}
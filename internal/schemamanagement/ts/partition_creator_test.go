@@ -0,0 +1,138 @@
+package ts
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/connections"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+)
+
+func TestPartitionStarts(t *testing.T) {
+	day := 24 * time.Hour
+	testCases := []struct {
+		desc      string
+		interval  time.Duration
+		timeRange *schemaconfig.TimeRange
+		expected  []time.Time
+		expectErr bool
+	}{
+		{
+			desc:      "interval not positive",
+			timeRange: &schemaconfig.TimeRange{},
+			expectErr: true,
+		}, {
+			desc:      "range ends before it starts",
+			interval:  day,
+			timeRange: &schemaconfig.TimeRange{From: time.Unix(day.Nanoseconds(), 0), To: time.Unix(0, 0)},
+			expectErr: true,
+		}, {
+			desc:      "aligned to the epoch",
+			interval:  day,
+			timeRange: &schemaconfig.TimeRange{From: time.Date(2019, 1, 1, 10, 0, 0, 0, time.UTC), To: time.Date(2019, 1, 3, 0, 0, 0, 0, time.UTC)},
+			expected: []time.Time{
+				time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+				time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC),
+				time.Date(2019, 1, 3, 0, 0, 0, 0, time.UTC),
+			},
+		}, {
+			desc:      "single partition",
+			interval:  day,
+			timeRange: &schemaconfig.TimeRange{From: time.Date(2019, 1, 1, 10, 0, 0, 0, time.UTC), To: time.Date(2019, 1, 1, 11, 0, 0, 0, time.UTC)},
+			expected:  []time.Time{time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)},
+		}, {
+			desc:      "before the epoch",
+			interval:  day,
+			timeRange: &schemaconfig.TimeRange{From: time.Date(1969, 12, 31, 10, 0, 0, 0, time.UTC), To: time.Date(1970, 1, 1, 10, 0, 0, 0, time.UTC)},
+			expected:  []time.Time{time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC), time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)},
+		}, {
+			desc:      "too many partitions",
+			interval:  time.Hour,
+			timeRange: &schemaconfig.TimeRange{From: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		starts, err := partitionStarts(tc.interval, tc.timeRange)
+		if tc.expectErr {
+			assert.Error(t, err, tc.desc)
+			continue
+		}
+
+		assert.NoError(t, err, tc.desc)
+		assert.Equal(t, tc.expected, starts, tc.desc)
+	}
+}
+
+func TestCreatePartitionedTable(t *testing.T) {
+	info := &idrf.DataSet{
+		DataSetName: "tab",
+		TimeColumn:  "time",
+		Columns:     []*idrf.Column{{Name: "time", DataType: idrf.IDRFTimestamptz}, {Name: "a", DataType: idrf.IDRFDouble}},
+	}
+	db := &connections.MockPgxW{ExecRes: []pgx.CommandTag{""}, ExecErrs: []error{nil}}
+	c := newPartitionCreator("she ma")
+	assert.NoError(t, c.CreatePartitionedTable(db, info))
+	assert.Equal(t, []string{`CREATE TABLE "she ma"."tab"("time" TIMESTAMPTZ, "a" FLOAT) PARTITION BY RANGE ("time")`}, db.ExpExec)
+}
+
+func TestCreatePartitions(t *testing.T) {
+	timeRange := &schemaconfig.TimeRange{From: time.Date(2019, 1, 1, 10, 0, 0, 0, time.UTC), To: time.Date(2019, 1, 2, 10, 0, 0, 0, time.UTC)}
+	partitioning := &schemaconfig.RangePartitioning{Interval: 24 * time.Hour, Range: timeRange}
+	testCases := []struct {
+		desc      string
+		schema    string
+		timeType  idrf.DataType
+		expected  []string
+		execErrs  []error
+		expectErr bool
+	}{
+		{
+			desc:     "timestamp bounds",
+			schema:   "she ma",
+			timeType: idrf.IDRFTimestamptz,
+			execErrs: []error{nil, nil},
+			expected: []string{
+				`CREATE TABLE IF NOT EXISTS "she ma"."tab_20190101T000000" PARTITION OF "she ma"."tab" FOR VALUES FROM ('2019-01-01T00:00:00Z') TO ('2019-01-02T00:00:00Z')`,
+				`CREATE TABLE IF NOT EXISTS "she ma"."tab_20190102T000000" PARTITION OF "she ma"."tab" FOR VALUES FROM ('2019-01-02T00:00:00Z') TO ('2019-01-03T00:00:00Z')`,
+			},
+		}, {
+			desc:     "epoch bounds",
+			timeType: idrf.IDRFInteger64,
+			execErrs: []error{nil, nil},
+			expected: []string{
+				`CREATE TABLE IF NOT EXISTS "tab_20190101T000000" PARTITION OF "tab" FOR VALUES FROM (1546300800000000000) TO (1546387200000000000)`,
+				`CREATE TABLE IF NOT EXISTS "tab_20190102T000000" PARTITION OF "tab" FOR VALUES FROM (1546387200000000000) TO (1546473600000000000)`,
+			},
+		}, {
+			desc:      "error creating a partition",
+			timeType:  idrf.IDRFTimestamptz,
+			execErrs:  []error{errors.New("error")},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		info := &idrf.DataSet{DataSetName: "tab", TimeColumn: "time", Columns: []*idrf.Column{{Name: "time", DataType: tc.timeType}}}
+		db := &connections.MockPgxW{ExecRes: make([]pgx.CommandTag, len(tc.execErrs)), ExecErrs: tc.execErrs}
+		partitions, err := newPartitionCreator(tc.schema).CreatePartitions(db, info, partitioning)
+		if tc.expectErr {
+			assert.Error(t, err, tc.desc)
+			continue
+		}
+
+		assert.NoError(t, err, tc.desc)
+		assert.Equal(t, 2, partitions, tc.desc)
+		assert.Equal(t, tc.expected, db.ExpExec, tc.desc)
+	}
+
+	// no time range, no partitions
+	partitions, err := newPartitionCreator("").CreatePartitions(&connections.MockPgxW{}, &idrf.DataSet{}, &schemaconfig.RangePartitioning{Interval: time.Hour})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, partitions)
+}
@@ -0,0 +1,161 @@
+package ts
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/timescale/outflux/internal/connections"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+)
+
+// PGSchemaManager implements the schemamanagement.SchemaManager interface for plain PostgreSQL,
+// without the TimescaleDB extension. The tables are range partitioned on their time column
+type PGSchemaManager struct {
+	explorer          schemaExplorer
+	partitionExplorer rangePartitionExplorer
+	creator           tableCreator
+	partitioner       partitionCreator
+	dropper           tableDropper
+	dbConn            connections.PgxWrap
+	schema            string
+	partitioning      *schemaconfig.RangePartitioning
+	// indexes if not nil, are created once the data of a data set is loaded
+	indexes *schemaconfig.Indexes
+}
+
+// NewPGSchemaManager creates a new PostgreSQL Schema Manager. The tables it creates are partitioned
+// as described by the partitioning, and the indexes are created when CreateIndexes is called
+func NewPGSchemaManager(dbConn connections.PgxWrap, schema string, partitioning *schemaconfig.RangePartitioning, indexes *schemaconfig.Indexes) *PGSchemaManager {
+	return &PGSchemaManager{
+		dbConn:            dbConn,
+		schema:            schema,
+		partitioning:      partitioning,
+		indexes:           indexes,
+		explorer:          newSchemaExplorer(),
+		partitionExplorer: &defaultRangePartitionExplorer{},
+		creator:           newTableCreator(schema, "", nil),
+		partitioner:       newPartitionCreator(schema),
+		dropper:           newTableDropper(),
+	}
+}
+
+// DiscoverDataSets not implemented
+func (sm *PGSchemaManager) DiscoverDataSets() ([]string, error) {
+	panic(fmt.Errorf("not implemented"))
+}
+
+// FetchDataSet not implemented
+func (sm *PGSchemaManager) FetchDataSet(dataSetIdentifier string) (*idrf.DataSet, error) {
+	panic(fmt.Errorf("not implemented"))
+}
+
+// PrepareDataSet prepares a partitioned table compatible with the provided dataSet, and the partitions
+// covering the time range of the data set. With the ValidateOnly strategy no partitions are created
+func (sm *PGSchemaManager) PrepareDataSet(dataSet *idrf.DataSet, strategy schemaconfig.SchemaStrategy) error {
+	log.Printf("Selected Schema Strategy: %s", strategy.String())
+	tableExists, err := sm.explorer.tableExists(sm.dbConn, sm.schema, dataSet.DataSetName)
+	if err != nil {
+		return fmt.Errorf("could not prepare data set '%s'. Could not check if table exists. \n%v", dataSet.DataSetName, err)
+	}
+
+	switch strategy {
+	case schemaconfig.DropAndCreate, schemaconfig.DropCascadeAndCreate:
+		if tableExists {
+			log.Printf("Table %s exists, dropping it", dataSet.DataSetName)
+			cascade := strategy == schemaconfig.DropCascadeAndCreate
+			if err := sm.dropper.Drop(sm.dbConn, dataSet.DataSetName, cascade); err != nil {
+				return fmt.Errorf("selected schema strategy wanted to drop the existing table, but: %s", err.Error())
+			}
+		}
+
+		return sm.createTable(dataSet)
+	case schemaconfig.CreateIfMissing:
+		if !tableExists {
+			log.Printf("CreateIfMissing strategy: Table %s does not exist. Creating", dataSet.DataSetName)
+			return sm.createTable(dataSet)
+		}
+
+		if err := sm.validateTable(dataSet); err != nil {
+			return err
+		}
+
+		return sm.createPartitions(dataSet)
+	case schemaconfig.ValidateOnly:
+		if !tableExists {
+			return fmt.Errorf("validate only strategy selected, but '%s' doesn't exist", dataSet.DataSetName)
+		}
+
+		log.Printf("Table %s exists. Proceeding only with validation", dataSet.DataSetName)
+		return sm.validateTable(dataSet)
+	default:
+		panic("unexpected type")
+	}
+}
+
+// CreateIndexes creates the requested indexes on the partitioned table of the data set, meant to be
+// called once its data is loaded. PostgreSQL creates them on each partition
+func (sm *PGSchemaManager) CreateIndexes(dataSet *idrf.DataSet) error {
+	if sm.indexes == nil {
+		return nil
+	}
+
+	if err := sm.creator.CreateIndexes(sm.dbConn, dataSet, sm.indexes); err != nil {
+		return fmt.Errorf("could not create the indexes of table '%s'\n%v", dataSet.DataSetName, err)
+	}
+
+	return nil
+}
+
+// CreateUniqueIndex creates the unique index the rows of the data set are upserted on, meant to be
+// called before its data is loaded. The keys include the time column the table is partitioned on
+func (sm *PGSchemaManager) CreateUniqueIndex(dataSet *idrf.DataSet, keys []string) error {
+	if err := sm.creator.CreateUniqueIndex(sm.dbConn, dataSet, keys); err != nil {
+		return fmt.Errorf("could not create the unique index of table '%s' on (%s), it can't have duplicate rows\n%v", dataSet.DataSetName, strings.Join(keys, ", "), err)
+	}
+
+	return nil
+}
+
+func (sm *PGSchemaManager) createTable(dataSet *idrf.DataSet) error {
+	log.Printf("Table %s ready to be created", dataSet.DataSetName)
+	if err := sm.partitioner.CreatePartitionedTable(sm.dbConn, dataSet); err != nil {
+		return err
+	}
+
+	return sm.createPartitions(dataSet)
+}
+
+func (sm *PGSchemaManager) createPartitions(dataSet *idrf.DataSet) error {
+	partitions, err := sm.partitioner.CreatePartitions(sm.dbConn, dataSet, sm.partitioning)
+	if err != nil {
+		return fmt.Errorf("could not create the partitions of table '%s'\n%v", dataSet.DataSetName, err)
+	}
+
+	log.Printf("Table %s has %d partitions of %s covering the migrated time range", dataSet.DataSetName, partitions, sm.partitioning.Interval)
+	return nil
+}
+
+func (sm *PGSchemaManager) validateTable(dataSet *idrf.DataSet) error {
+	existingTableColumns, err := sm.explorer.fetchTableColumns(sm.dbConn, sm.schema, dataSet.DataSetName)
+	if err != nil {
+		return fmt.Errorf("could not retreive column information for table %s", dataSet.DataSetName)
+	}
+
+	if err = isExistingTableCompatible(existingTableColumns, dataSet.Columns, dataSet.TimeColumn); err != nil {
+		return fmt.Errorf("existing table in target db is not compatible with required. %v", err)
+	}
+
+	isPartitioned, err := sm.partitionExplorer.isRangePartitionedBy(sm.dbConn, sm.schema, dataSet.DataSetName, dataSet.TimeColumn)
+	if err != nil {
+		return fmt.Errorf("could not check if existing table '%s' is partitioned properly\n%v", dataSet.DataSetName, err)
+	}
+
+	if !isPartitioned {
+		return fmt.Errorf("existing table '%s' is not range partitioned by timestamp column: %s", dataSet.DataSetName, dataSet.TimeColumn)
+	}
+
+	log.Printf("existing table '%s' is partitioned properly", dataSet.DataSetName)
+	return nil
+}
@@ -0,0 +1,148 @@
+package ts
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/connections"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+)
+
+func TestPGSchemaManagerPrepareDataSet(t *testing.T) {
+	dataSet := &idrf.DataSet{
+		DataSetName: "ds",
+		Columns:     []*idrf.Column{{Name: "time", DataType: idrf.IDRFTimestamptz}},
+		TimeColumn:  "time",
+	}
+	existingColumns := []*columnDesc{{"time", "timestamp with time zone", "NO"}}
+	testCases := []struct {
+		desc             string
+		mock             *mocker
+		partitions       *partitionMocker
+		strategy         schemaconfig.SchemaStrategy
+		expectTable      bool
+		expectPartitions bool
+		expectErr        bool
+	}{
+		{
+			desc:             "table created",
+			mock:             &mocker{},
+			partitions:       &partitionMocker{},
+			strategy:         schemaconfig.CreateIfMissing,
+			expectTable:      true,
+			expectPartitions: true,
+		}, {
+			desc:             "existing table dropped and created",
+			mock:             &mocker{tableExistsR: true},
+			partitions:       &partitionMocker{},
+			strategy:         schemaconfig.DropCascadeAndCreate,
+			expectTable:      true,
+			expectPartitions: true,
+		}, {
+			desc:        "error creating the table",
+			mock:        &mocker{},
+			partitions:  &partitionMocker{tableErr: fmt.Errorf("error")},
+			strategy:    schemaconfig.DropAndCreate,
+			expectTable: true,
+			expectErr:   true,
+		}, {
+			desc:             "error creating the partitions",
+			mock:             &mocker{},
+			partitions:       &partitionMocker{partitionsErr: fmt.Errorf("error")},
+			strategy:         schemaconfig.CreateIfMissing,
+			expectTable:      true,
+			expectPartitions: true,
+			expectErr:        true,
+		}, {
+			desc:             "partitions added to the existing table",
+			mock:             &mocker{tableExistsR: true, fetcColR: existingColumns},
+			partitions:       &partitionMocker{isPartitioned: true},
+			strategy:         schemaconfig.CreateIfMissing,
+			expectPartitions: true,
+		}, {
+			desc:       "existing table is not partitioned",
+			mock:       &mocker{tableExistsR: true, fetcColR: existingColumns},
+			partitions: &partitionMocker{},
+			strategy:   schemaconfig.CreateIfMissing,
+			expectErr:  true,
+		}, {
+			desc:       "error checking the partitioning",
+			mock:       &mocker{tableExistsR: true, fetcColR: existingColumns},
+			partitions: &partitionMocker{isPartitionedErr: fmt.Errorf("error")},
+			strategy:   schemaconfig.ValidateOnly,
+			expectErr:  true,
+		}, {
+			desc:       "existing table is not compatible",
+			mock:       &mocker{tableExistsR: true},
+			partitions: &partitionMocker{isPartitioned: true},
+			strategy:   schemaconfig.ValidateOnly,
+			expectErr:  true,
+		}, {
+			desc:       "validated without creating partitions",
+			mock:       &mocker{tableExistsR: true, fetcColR: existingColumns},
+			partitions: &partitionMocker{isPartitioned: true},
+			strategy:   schemaconfig.ValidateOnly,
+		}, {
+			desc:       "validate only, table doesn't exist",
+			mock:       &mocker{},
+			partitions: &partitionMocker{},
+			strategy:   schemaconfig.ValidateOnly,
+			expectErr:  true,
+		},
+	}
+
+	partitioning := &schemaconfig.RangePartitioning{Interval: time.Hour}
+	for _, tc := range testCases {
+		manager := &PGSchemaManager{
+			explorer:          tc.mock,
+			partitionExplorer: tc.partitions,
+			creator:           tc.mock,
+			partitioner:       tc.partitions,
+			dropper:           tc.mock,
+			partitioning:      partitioning,
+		}
+
+		err := manager.PrepareDataSet(dataSet, tc.strategy)
+		assert.Equal(t, tc.expectErr, err != nil, tc.desc)
+		assert.Equal(t, tc.expectTable, tc.partitions.tableCreated, tc.desc)
+		assert.Equal(t, tc.expectPartitions, tc.partitions.partitioning == partitioning, tc.desc)
+	}
+}
+
+func TestNewPGSchemaManager(t *testing.T) {
+	partitioning := &schemaconfig.RangePartitioning{Interval: time.Hour}
+	indexes := &schemaconfig.Indexes{Columns: []string{"host"}}
+	sm := NewPGSchemaManager(&connections.MockPgxW{}, "she ma", partitioning, indexes)
+	assert.Equal(t, partitioning, sm.partitioning)
+	assert.Equal(t, indexes, sm.indexes)
+	assert.Equal(t, "she ma", sm.creator.(*defaultTableCreator).schema)
+	assert.Equal(t, "she ma", sm.partitioner.(*defaultPartitionCreator).schema)
+	assert.NotNil(t, sm.partitionExplorer)
+	assert.NotNil(t, sm.dropper)
+}
+
+type partitionMocker struct {
+	tableErr         error
+	tableCreated     bool
+	partitionsErr    error
+	partitioning     *schemaconfig.RangePartitioning
+	isPartitioned    bool
+	isPartitionedErr error
+}
+
+func (m *partitionMocker) CreatePartitionedTable(db connections.PgxWrap, info *idrf.DataSet) error {
+	m.tableCreated = true
+	return m.tableErr
+}
+
+func (m *partitionMocker) CreatePartitions(db connections.PgxWrap, info *idrf.DataSet, partitioning *schemaconfig.RangePartitioning) (int, error) {
+	m.partitioning = partitioning
+	return 0, m.partitionsErr
+}
+
+func (m *partitionMocker) isRangePartitionedBy(db connections.PgxWrap, schema, table, column string) (bool, error) {
+	return m.isPartitioned, m.isPartitionedErr
+}
@@ -6,22 +6,23 @@ import (
 
 	"github.com/timescale/outflux/internal/connections"
 	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
 )
 
 const (
 	tableExistsQueryTemplate  = "SELECT EXISTS (SELECT 1 FROM pg_tables WHERE  schemaname = $1 AND tablename = $2)"
 	tableColumnsQueryTemplate = `SELECT column_name, data_type, is_nullable
 	                             FROM information_schema.columns
-								 WHERE table_schema = $1 AND table_name = $2;`
+								 WHERE table_schema = $1 AND table_name = $2
+								 ORDER BY ordinal_position;`
 	isHypertableQueryTemplate = `SELECT EXISTS (
 									 SELECT 1 FROM _timescaledb_catalog.hypertable
 									 WHERE schema_name = $1 AND table_name=$2)`
-	hypertableDimensionsQueryTemplate = `SELECT column_name, column_type
+	hypertableDimensionsQueryTemplate = `SELECT column_name, column_type, COALESCE(num_slices, 0)
                                          FROM _timescaledb_catalog.dimension d
               							 JOIN _timescaledb_catalog.hypertable h ON d.hypertable_id = h.id
 										 WHERE h.schema_name = $1 AND h.table_name = $2
-										 ORDER BY d.id ASC
-										 LIMIT 1;`
+										 ORDER BY d.id ASC;`
 	timescaleCreatedQuery         = "SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'timescaledb')"
 	isNullableSignifyingValue     = "YES"
 	installationMetadataTableName = "installation_metadata"
@@ -43,7 +44,7 @@ type hypertableChecker interface {
 }
 
 type hypertableDimensionExplorer interface {
-	isTimePartitionedBy(db connections.PgxWrap, schema, table, timeColumn string) (bool, error)
+	isTimePartitionedBy(db connections.PgxWrap, schema, table, timeColumn string, space *schemaconfig.SpacePartitioning) (bool, error)
 }
 
 type timescaleExistsChecker interface {
@@ -198,7 +199,9 @@ func (f *defaultHyptertableChecker) isHypertable(db connections.PgxWrap, schemaN
 	return exists, nil
 }
 
-func (f *defaultHypertableDimensionExplorer) isTimePartitionedBy(db connections.PgxWrap, schema, table, timeColumn string) (bool, error) {
+// isTimePartitionedBy checks that the first dimension of the hypertable is the time column. If a space
+// partitioning is given, the hypertable must also be hash partitioned by its column in as many partitions
+func (f *defaultHypertableDimensionExplorer) isTimePartitionedBy(db connections.PgxWrap, schema, table, timeColumn string, space *schemaconfig.SpacePartitioning) (bool, error) {
 	if schema == "" {
 		schema = "public"
 	}
@@ -210,20 +213,21 @@ func (f *defaultHypertableDimensionExplorer) isTimePartitionedBy(db connections.
 
 	defer rows.Close()
 	var partitioningColumn, dimensionType string
+	var partitions int16
 
 	if !rows.Next() {
 		log.Printf("Table %s is not a hypertable", table)
 		return false, nil
 	}
 
-	err = rows.Scan(&partitioningColumn, &dimensionType)
+	err = rows.Scan(&partitioningColumn, &dimensionType, &partitions)
 	if err != nil {
 		return false, err
 	}
 
 	idrfDimType := pgTypeToIdrf(dimensionType)
-	if idrfDimType != idrf.IDRFTimestamptz && idrfDimType != idrf.IDRFTimestamp {
-		log.Printf("In order to import from influx, output hypertable should be partitioned by a timestamp, or timestamptz column")
+	if idrfDimType != idrf.IDRFTimestamptz && idrfDimType != idrf.IDRFTimestamp && idrfDimType != idrf.IDRFInteger64 {
+		log.Printf("In order to import from influx, output hypertable should be partitioned by a timestamp, timestamptz or bigint column")
 		log.Printf("Table %s is partitioned by column %s of type %s", table, partitioningColumn, dimensionType)
 		return false, nil
 	}
@@ -232,7 +236,27 @@ func (f *defaultHypertableDimensionExplorer) isTimePartitionedBy(db connections.
 		return false, nil
 	}
 
-	return true, nil
+	if space == nil {
+		return true, nil
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(&partitioningColumn, &dimensionType, &partitions); err != nil {
+			return false, err
+		}
+
+		if partitioningColumn == space.Column {
+			if int(partitions) != space.Partitions {
+				log.Printf("Table %s is partitioned by column %s in %d partitions", table, partitioningColumn, partitions)
+				return false, nil
+			}
+
+			return true, nil
+		}
+	}
+
+	log.Printf("Table %s is not partitioned by column %s", table, space.Column)
+	return false, rows.Err()
 }
 
 func (d *defaultTimescaleExistsChecker) timescaleExists(db connections.PgxWrap) (bool, error) {
@@ -0,0 +1,147 @@
+package ts
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+)
+
+// the output database is not explored when writing a script, a table is dropped if it exists, or created
+// by a block that checks that it doesn't exist. The statements of the block are executed from dollar quoted strings
+const (
+	createSchemaIfMissingTemplate    = `CREATE SCHEMA IF NOT EXISTS "%s"`
+	dropTableIfExistsTemplate        = `DROP TABLE IF EXISTS %s`
+	dropTableIfExistsCascadeTemplate = `DROP TABLE IF EXISTS %s CASCADE`
+	createIfMissingBlockTemplate     = "DO $do$\nBEGIN\n\tIF to_regclass('%s') IS NULL THEN\n%s\n\tEND IF;\nEND\n$do$"
+	executeStatementTemplate         = "\t\tEXECUTE $sql$%s$sql$;"
+)
+
+// ScriptSchemaManager returns the statements that prepare the hypertable of a data set, to be written to
+// a script instead of executed. The database the script is applied to can't be explored, so existing
+// tables are not validated
+type ScriptSchemaManager struct {
+	creator tableCreator
+	schema  string
+	// createSchema if set, the statements create the schema if it doesn't exist
+	createSchema bool
+	// retentionPeriod if > 0, created hypertables get a retention policy dropping older chunks
+	retentionPeriod time.Duration
+	// compression if not nil, is enabled on created hypertables
+	compression *schemaconfig.Compression
+	// indexes if not nil, are created once the data of a data set is loaded
+	indexes *schemaconfig.Indexes
+}
+
+// NewScriptSchemaManager creates a new Schema Manager for scripts. The statements create the hypertables
+// as the TimescaleDB Schema Manager would, and if createSchema is set they create the schema first
+func NewScriptSchemaManager(schema, chunkTimeInterval string, createSchema bool, retentionPeriod time.Duration, compression *schemaconfig.Compression, spacePartitioning *schemaconfig.SpacePartitioning, indexes *schemaconfig.Indexes) *ScriptSchemaManager {
+	return &ScriptSchemaManager{
+		schema:          schema,
+		createSchema:    createSchema,
+		retentionPeriod: retentionPeriod,
+		compression:     compression,
+		indexes:         indexes,
+		creator:         newTableCreator(schema, chunkTimeInterval, spacePartitioning),
+	}
+}
+
+// PrepareStatements returns the statements that prepare a hypertable for the data set with the schema strategy.
+// The drop strategies drop the table if it exists, CreateIfMissing creates it only if it doesn't exist, and
+// ValidateOnly returns no statements, the table must exist when the script is applied
+func (sm *ScriptSchemaManager) PrepareStatements(dataSet *idrf.DataSet, strategy schemaconfig.SchemaStrategy) ([]string, error) {
+	if strategy == schemaconfig.ValidateOnly {
+		return nil, nil
+	}
+
+	recorder := &statementRecorder{}
+	if err := sm.creator.CreateTable(recorder, dataSet); err != nil {
+		return nil, fmt.Errorf("could not write the statements creating table '%s'\n%v", dataSet.DataSetName, err)
+	}
+
+	if err := addHypertablePolicies(sm.creator, recorder, dataSet, sm.retentionPeriod, sm.compression); err != nil {
+		return nil, err
+	}
+
+	statements := []string{}
+	if sm.createSchema && sm.schema != "" {
+		statements = append(statements, fmt.Sprintf(createSchemaIfMissingTemplate, sm.schema))
+	}
+
+	tableName := sm.qualifiedName(dataSet.DataSetName)
+	switch strategy {
+	case schemaconfig.DropAndCreate:
+		statements = append(statements, fmt.Sprintf(dropTableIfExistsTemplate, tableName))
+		return append(statements, recorder.statements...), nil
+	case schemaconfig.DropCascadeAndCreate:
+		statements = append(statements, fmt.Sprintf(dropTableIfExistsCascadeTemplate, tableName))
+		return append(statements, recorder.statements...), nil
+	case schemaconfig.CreateIfMissing:
+		executes := make([]string, len(recorder.statements))
+		for i, statement := range recorder.statements {
+			executes[i] = fmt.Sprintf(executeStatementTemplate, statement)
+		}
+
+		block := fmt.Sprintf(createIfMissingBlockTemplate, escapeLiteral(tableName), strings.Join(executes, "\n"))
+		return append(statements, block), nil
+	default:
+		panic("unexpected type")
+	}
+}
+
+// IndexStatements returns the statements that create the requested indexes on the hypertable of the data set,
+// meant to be applied once its data is loaded
+func (sm *ScriptSchemaManager) IndexStatements(dataSet *idrf.DataSet) ([]string, error) {
+	if sm.indexes == nil {
+		return nil, nil
+	}
+
+	recorder := &statementRecorder{}
+	if err := sm.creator.CreateIndexes(recorder, dataSet, sm.indexes); err != nil {
+		return nil, fmt.Errorf("could not write the statements creating the indexes of table '%s'\n%v", dataSet.DataSetName, err)
+	}
+
+	return recorder.statements, nil
+}
+
+func (sm *ScriptSchemaManager) qualifiedName(name string) string {
+	if sm.schema != "" {
+		return fmt.Sprintf(tableNameWithSchemaTemplate, sm.schema, name)
+	}
+
+	return fmt.Sprintf(tableNameTemplate, name)
+}
+
+// statementRecorder implements connections.PgxWrap by recording the statements executed with it, without
+// their terminating semicolon. Statements with arguments and queries can't be recorded
+type statementRecorder struct {
+	statements []string
+}
+
+func (r *statementRecorder) Begin() (*pgx.Tx, error) {
+	return nil, fmt.Errorf("transactions can't be recorded")
+}
+
+func (r *statementRecorder) CopyFrom(tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int, error) {
+	return 0, fmt.Errorf("rows can't be copied into a recorded table")
+}
+
+func (r *statementRecorder) Exec(sql string, arguments ...interface{}) (pgx.CommandTag, error) {
+	if len(arguments) > 0 {
+		return "", fmt.Errorf("statement with arguments can't be recorded: %s", sql)
+	}
+
+	r.statements = append(r.statements, strings.TrimSuffix(sql, ";"))
+	return "", nil
+}
+
+func (r *statementRecorder) Query(sql string, args ...interface{}) (*pgx.Rows, error) {
+	return nil, fmt.Errorf("query can't be answered by a recorded database: %s", sql)
+}
+
+func (r *statementRecorder) Close() error {
+	return nil
+}
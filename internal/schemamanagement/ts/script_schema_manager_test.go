@@ -0,0 +1,90 @@
+package ts
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+)
+
+func TestScriptSchemaManagerPrepareStatements(t *testing.T) {
+	dataSet := &idrf.DataSet{
+		DataSetName: "tab",
+		Columns:     []*idrf.Column{{Name: "time", DataType: idrf.IDRFTimestamptz}, {Name: "host", DataType: idrf.IDRFString}},
+		TimeColumn:  "time",
+	}
+	createTable := `CREATE TABLE "she ma"."tab"("time" TIMESTAMPTZ, "host" TEXT)`
+	createExtension := `CREATE EXTENSION IF NOT EXISTS timescaledb`
+	createHypertable := `SELECT create_hypertable('"she ma"."tab"', 'time', chunk_time_interval => interval '1 day')`
+	addPolicy := `SELECT add_retention_policy('"she ma"."tab"', drop_after => interval '3600 seconds', if_not_exists => true)`
+	testCases := []struct {
+		desc         string
+		strategy     schemaconfig.SchemaStrategy
+		createSchema bool
+		expected     []string
+	}{
+		{
+			desc:     "validate only",
+			strategy: schemaconfig.ValidateOnly,
+		}, {
+			desc:     "drop and create",
+			strategy: schemaconfig.DropAndCreate,
+			expected: []string{`DROP TABLE IF EXISTS "she ma"."tab"`, createTable, createExtension, createHypertable, addPolicy},
+		}, {
+			desc:         "drop cascade and create the schema",
+			strategy:     schemaconfig.DropCascadeAndCreate,
+			createSchema: true,
+			expected:     []string{`CREATE SCHEMA IF NOT EXISTS "she ma"`, `DROP TABLE IF EXISTS "she ma"."tab" CASCADE`, createTable, createExtension, createHypertable, addPolicy},
+		}, {
+			desc:     "create if missing",
+			strategy: schemaconfig.CreateIfMissing,
+			expected: []string{"DO $do$\nBEGIN\n\tIF to_regclass('\"she ma\".\"tab\"') IS NULL THEN\n" +
+				"\t\tEXECUTE $sql$" + createTable + "$sql$;\n" +
+				"\t\tEXECUTE $sql$" + createExtension + "$sql$;\n" +
+				"\t\tEXECUTE $sql$" + createHypertable + "$sql$;\n" +
+				"\t\tEXECUTE $sql$" + addPolicy + "$sql$;\n" +
+				"\tEND IF;\nEND\n$do$"},
+		},
+	}
+
+	for _, tc := range testCases {
+		sm := NewScriptSchemaManager("she ma", "1 day", tc.createSchema, time.Hour, nil, nil, nil)
+		statements, err := sm.PrepareStatements(dataSet, tc.strategy)
+		assert.NoError(t, err, tc.desc)
+		assert.Equal(t, tc.expected, statements, tc.desc)
+	}
+
+	sm := &ScriptSchemaManager{creator: &mocker{tableCreateError: fmt.Errorf("error")}}
+	_, err := sm.PrepareStatements(dataSet, schemaconfig.CreateIfMissing)
+	assert.Error(t, err)
+}
+
+func TestScriptSchemaManagerIndexStatements(t *testing.T) {
+	dataSet := &idrf.DataSet{
+		DataSetName: "tab",
+		Columns:     []*idrf.Column{{Name: "time", DataType: idrf.IDRFTimestamptz}, {Name: "host", DataType: idrf.IDRFString}},
+		TimeColumn:  "time",
+	}
+	statements, err := NewScriptSchemaManager("", "", false, 0, nil, nil, nil).IndexStatements(dataSet)
+	assert.NoError(t, err)
+	assert.Empty(t, statements)
+
+	indexes := &schemaconfig.Indexes{Columns: []string{"host"}}
+	statements, err = NewScriptSchemaManager("", "", false, 0, nil, nil, indexes).IndexStatements(dataSet)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`CREATE INDEX IF NOT EXISTS "tab_host_time_idx" ON "tab" ("host", "time" DESC)`}, statements)
+}
+
+func TestStatementRecorder(t *testing.T) {
+	recorder := &statementRecorder{}
+	_, err := recorder.Exec("SELECT 1;")
+	assert.NoError(t, err)
+	_, err = recorder.Exec("SELECT $1", 1)
+	assert.Error(t, err)
+	_, err = recorder.Query("SELECT 1")
+	assert.Error(t, err)
+	assert.Equal(t, []string{"SELECT 1"}, recorder.statements)
+}
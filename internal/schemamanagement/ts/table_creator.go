@@ -8,6 +8,7 @@ import (
 
 	"github.com/timescale/outflux/internal/connections"
 	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
 )
 
 const (
@@ -25,20 +26,76 @@ const (
 	updateMetadataTemplate                 = "UPDATE %s.%s SET value=$1 WHERE key=$2"
 )
 
+// a BIGINT time column holds nanoseconds, the chunk interval is converted to nanoseconds
+const (
+	createIntegerHTQueryTemplate = `SELECT create_hypertable('%s', '%s', chunk_time_interval => (EXTRACT(EPOCH FROM interval '%s') * 1000000000)::BIGINT);`
+	defaultIntegerChunkInterval  = "7 days"
+	integerNowFuncName           = "outflux_epoch_ns_now"
+	setIntegerNowFuncTemplate    = `SELECT set_integer_now_func('%s', '%s');`
+	createIntegerNowFuncTemplate = `CREATE OR REPLACE FUNCTION %s() RETURNS BIGINT LANGUAGE SQL STABLE AS
+		$$ SELECT (EXTRACT(EPOCH FROM now()) * 1000000000)::BIGINT $$`
+)
+
+// the hypertable is hash partitioned by a column in addition to the time column. The dimension is added
+// right after the hypertable is created, while it is still empty
+const (
+	addSpaceDimensionTemplate = `SELECT add_dimension('%s', '%s', number_partitions => %d);`
+)
+
+// chunks older than the retention period of the influx retention policy are dropped by a retention policy
+const (
+	addRetentionPolicyTemplate        = `SELECT add_retention_policy('%s', drop_after => interval '%d seconds', if_not_exists => true);`
+	addIntegerRetentionPolicyTemplate = `SELECT add_retention_policy('%s', drop_after => %d::BIGINT, if_not_exists => true);`
+)
+
+// the indexes are named after the table and the indexed column, and are not created if they exist
+const (
+	createTimeIndexTemplate = `CREATE INDEX IF NOT EXISTS %s ON %s (%s, %s DESC);`
+	createGINIndexTemplate  = `CREATE INDEX IF NOT EXISTS %s ON %s USING GIN (%s);`
+	timeIndexNameTemplate   = "%s_%s_time_idx"
+	ginIndexNameTemplate    = "%s_%s_gin_idx"
+)
+
+// rows are upserted on a unique index of the columns that identify a point, it must exist before the data is loaded
+const (
+	createUniqueIndexTemplate = `CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s (%s);`
+	uniqueIndexNameTemplate   = "%s_upsert_idx"
+)
+
+// the segmentby columns are quoted identifiers in a string literal of the ALTER TABLE statement
+const (
+	enableCompressionTemplate           = `ALTER TABLE %s SET (%s)`
+	compressOption                      = `timescaledb.compress`
+	compressSegmentByOptionTemplate     = `timescaledb.compress_segmentby = '%s'`
+	compressOrderByOptionTemplate       = `timescaledb.compress_orderby = '%s'`
+	addCompressionPolicyTemplate        = `SELECT add_compression_policy('%s', compress_after => interval '%d seconds', if_not_exists => true);`
+	addIntegerCompressionPolicyTemplate = `SELECT add_compression_policy('%s', compress_after => %d::BIGINT, if_not_exists => true);`
+	compressChunksTemplate              = `SELECT count(compress_chunk(c, if_not_compressed => true)) FROM show_chunks('%s', older_than => interval '%d seconds') c;`
+	compressIntegerChunksTemplate       = `SELECT count(compress_chunk(c, if_not_compressed => true)) FROM show_chunks('%s', older_than => %s() - %d::BIGINT) c;`
+)
+
 type tableCreator interface {
 	CreateTable(connections.PgxWrap, *idrf.DataSet) error
 	CreateHypertable(connections.PgxWrap, *idrf.DataSet) error
 	CreateTimescaleExtension(connections.PgxWrap) error
+	AddRetentionPolicy(db connections.PgxWrap, info *idrf.DataSet, dropAfter time.Duration) error
+	EnableCompression(db connections.PgxWrap, info *idrf.DataSet, compression *schemaconfig.Compression) error
+	AddCompressionPolicy(db connections.PgxWrap, info *idrf.DataSet, compressAfter time.Duration) error
+	CompressChunks(db connections.PgxWrap, info *idrf.DataSet, olderThan time.Duration) (int64, error)
+	CreateIndexes(db connections.PgxWrap, info *idrf.DataSet, indexes *schemaconfig.Indexes) error
+	CreateUniqueIndex(db connections.PgxWrap, info *idrf.DataSet, keys []string) error
 	UpdateMetadata(db connections.PgxWrap, metadataTableName string) error
 }
 
-func newTableCreator(schema, chunkTimeInterval string) tableCreator {
-	return &defaultTableCreator{schema: schema, chunkTimeInterval: chunkTimeInterval}
+func newTableCreator(schema, chunkTimeInterval string, spacePartitioning *schemaconfig.SpacePartitioning) tableCreator {
+	return &defaultTableCreator{schema: schema, chunkTimeInterval: chunkTimeInterval, spacePartitioning: spacePartitioning}
 }
 
 type defaultTableCreator struct {
 	schema            string
 	chunkTimeInterval string
+	// spacePartitioning if not nil, adds a hash partitioned dimension to the created hypertables
+	spacePartitioning *schemaconfig.SpacePartitioning
 }
 
 func (d *defaultTableCreator) CreateTable(dbConn connections.PgxWrap, info *idrf.DataSet) error {
@@ -57,12 +114,17 @@ func (d *defaultTableCreator) CreateTable(dbConn connections.PgxWrap, info *idrf
 }
 
 func (d *defaultTableCreator) CreateHypertable(dbConn connections.PgxWrap, info *idrf.DataSet) error {
-	var hypertableName string
+	if d.spacePartitioning != nil && info.ColumnNamed(d.spacePartitioning.Column) == nil {
+		return fmt.Errorf("column '%s' to partition '%s' by doesn't exist", d.spacePartitioning.Column, info.DataSetName)
+	}
 
-	if d.schema != "" {
-		hypertableName = fmt.Sprintf(tableNameWithSchemaTemplate, d.schema, info.DataSetName)
-	} else {
-		hypertableName = fmt.Sprintf(tableNameTemplate, info.DataSetName)
+	hypertableName := d.qualifiedName(info.DataSetName)
+	if isEpochTime(info) {
+		if err := d.createIntegerHypertable(dbConn, hypertableName, info.TimeColumn); err != nil {
+			return err
+		}
+
+		return d.addSpaceDimension(dbConn, hypertableName)
 	}
 
 	var hypertableQuery string
@@ -73,10 +135,134 @@ func (d *defaultTableCreator) CreateHypertable(dbConn connections.PgxWrap, info
 	}
 
 	log.Printf("Creating hypertable with: %s", hypertableQuery)
-	_, err := dbConn.Exec(hypertableQuery)
+	if _, err := dbConn.Exec(hypertableQuery); err != nil {
+		return err
+	}
+
+	return d.addSpaceDimension(dbConn, hypertableName)
+}
+
+// addSpaceDimension hash partitions the hypertable by the column of the space partitioning, if one was given
+func (d *defaultTableCreator) addSpaceDimension(dbConn connections.PgxWrap, hypertableName string) error {
+	if d.spacePartitioning == nil {
+		return nil
+	}
+
+	dimensionQuery := fmt.Sprintf(addSpaceDimensionTemplate, hypertableName, d.spacePartitioning.Column, d.spacePartitioning.Partitions)
+	log.Printf("Adding space dimension with: %s", dimensionQuery)
+	_, err := dbConn.Exec(dimensionQuery)
+	return err
+}
+
+// createIntegerHypertable creates a hypertable partitioned by a BIGINT column with the nanoseconds
+// since the Unix epoch, and sets the function returning the current time in nanoseconds
+// required by the policies of integer partitioned hypertables
+func (d *defaultTableCreator) createIntegerHypertable(dbConn connections.PgxWrap, hypertableName, timeColumn string) error {
+	chunkTimeInterval := d.chunkTimeInterval
+	if chunkTimeInterval == "" {
+		chunkTimeInterval = defaultIntegerChunkInterval
+	}
+
+	hypertableQuery := fmt.Sprintf(createIntegerHTQueryTemplate, hypertableName, timeColumn, chunkTimeInterval)
+	log.Printf("Creating hypertable with: %s", hypertableQuery)
+	if _, err := dbConn.Exec(hypertableQuery); err != nil {
+		return err
+	}
+
+	nowFuncName := d.qualifiedName(integerNowFuncName)
+	if _, err := dbConn.Exec(fmt.Sprintf(createIntegerNowFuncTemplate, nowFuncName)); err != nil {
+		return fmt.Errorf("could not create function %s\n%v", nowFuncName, err)
+	}
+
+	_, err := dbConn.Exec(fmt.Sprintf(setIntegerNowFuncTemplate, hypertableName, nowFuncName))
+	return err
+}
+
+// AddRetentionPolicy adds a policy that drops the chunks of the hypertable with data older than dropAfter
+func (d *defaultTableCreator) AddRetentionPolicy(dbConn connections.PgxWrap, info *idrf.DataSet, dropAfter time.Duration) error {
+	hypertableName := d.qualifiedName(info.DataSetName)
+	policyQuery := fmt.Sprintf(addRetentionPolicyTemplate, hypertableName, int64(dropAfter/time.Second))
+	if isEpochTime(info) {
+		policyQuery = fmt.Sprintf(addIntegerRetentionPolicyTemplate, hypertableName, dropAfter.Nanoseconds())
+	}
+
+	log.Printf("Adding retention policy with: %s", policyQuery)
+	_, err := dbConn.Exec(policyQuery)
+	return err
+}
+
+// EnableCompression sets the compression options of the hypertable
+func (d *defaultTableCreator) EnableCompression(dbConn connections.PgxWrap, info *idrf.DataSet, compression *schemaconfig.Compression) error {
+	options := []string{compressOption}
+	if len(compression.SegmentBy) > 0 {
+		segmentBy := make([]string, len(compression.SegmentBy))
+		for i, column := range compression.SegmentBy {
+			segmentBy[i] = quoteIdentifier(column)
+		}
+
+		options = append(options, fmt.Sprintf(compressSegmentByOptionTemplate, escapeLiteral(strings.Join(segmentBy, ", "))))
+	}
+
+	if compression.OrderBy != "" {
+		options = append(options, fmt.Sprintf(compressOrderByOptionTemplate, escapeLiteral(compression.OrderBy)))
+	}
+
+	compressionQuery := fmt.Sprintf(enableCompressionTemplate, d.qualifiedName(info.DataSetName), strings.Join(options, ", "))
+	log.Printf("Enabling compression with: %s", compressionQuery)
+	_, err := dbConn.Exec(compressionQuery)
 	return err
 }
 
+// AddCompressionPolicy adds a policy that compresses the chunks of the hypertable with data older than compressAfter
+func (d *defaultTableCreator) AddCompressionPolicy(dbConn connections.PgxWrap, info *idrf.DataSet, compressAfter time.Duration) error {
+	hypertableName := d.qualifiedName(info.DataSetName)
+	policyQuery := fmt.Sprintf(addCompressionPolicyTemplate, hypertableName, int64(compressAfter/time.Second))
+	if isEpochTime(info) {
+		policyQuery = fmt.Sprintf(addIntegerCompressionPolicyTemplate, hypertableName, compressAfter.Nanoseconds())
+	}
+
+	log.Printf("Adding compression policy with: %s", policyQuery)
+	_, err := dbConn.Exec(policyQuery)
+	return err
+}
+
+// CompressChunks compresses the chunks of the hypertable with data older than olderThan that are not
+// compressed yet, and returns the number of chunks. The older_than of show_chunks is absolute for
+// integer time columns, so it's computed from the function returning the current time in nanoseconds
+func (d *defaultTableCreator) CompressChunks(dbConn connections.PgxWrap, info *idrf.DataSet, olderThan time.Duration) (int64, error) {
+	hypertableName := d.qualifiedName(info.DataSetName)
+	compressQuery := fmt.Sprintf(compressChunksTemplate, hypertableName, int64(olderThan/time.Second))
+	if isEpochTime(info) {
+		compressQuery = fmt.Sprintf(compressIntegerChunksTemplate, hypertableName, d.qualifiedName(integerNowFuncName), olderThan.Nanoseconds())
+	}
+
+	log.Printf("Compressing chunks with: %s", compressQuery)
+	rows, err := dbConn.Query(compressQuery)
+	if err != nil {
+		return 0, err
+	}
+
+	defer rows.Close()
+	var compressed int64
+	if !rows.Next() {
+		return 0, fmt.Errorf("compressing the chunks of '%s' returned no result", info.DataSetName)
+	}
+
+	if err = rows.Scan(&compressed); err != nil {
+		return 0, err
+	}
+
+	return compressed, nil
+}
+
+func (d *defaultTableCreator) qualifiedName(name string) string {
+	if d.schema != "" {
+		return fmt.Sprintf(tableNameWithSchemaTemplate, d.schema, name)
+	}
+
+	return fmt.Sprintf(tableNameTemplate, name)
+}
+
 func (d *defaultTableCreator) CreateTimescaleExtension(dbConn connections.PgxWrap) error {
 	log.Printf("Preparing TimescaleDB extension:\n%s", createTimescaleExtensionQuery)
 	_, err := dbConn.Exec(createTimescaleExtensionQuery)
@@ -113,6 +299,63 @@ func (d *defaultTableCreator) UpdateMetadata(dbConn connections.PgxWrap, metadat
 	return err
 }
 
+// CreateIndexes creates a (column, time DESC) index for each selected column of the hypertable, and a GIN index
+// for each JSONB column. Selected columns the data set doesn't have are skipped
+func (d *defaultTableCreator) CreateIndexes(dbConn connections.PgxWrap, info *idrf.DataSet, indexes *schemaconfig.Indexes) error {
+	hypertableName := d.qualifiedName(info.DataSetName)
+	indexQueries := []string{}
+	for _, column := range indexes.Columns {
+		if info.ColumnNamed(column) == nil {
+			log.Printf("Table %s has no column %s, it is not indexed", info.DataSetName, column)
+			continue
+		}
+
+		indexName := quoteIdentifier(fmt.Sprintf(timeIndexNameTemplate, info.DataSetName, column))
+		indexQueries = append(indexQueries, fmt.Sprintf(createTimeIndexTemplate, indexName, hypertableName, quoteIdentifier(column), quoteIdentifier(info.TimeColumn)))
+	}
+
+	for _, column := range info.Columns {
+		if column.DataType != idrf.IDRFJson {
+			continue
+		}
+
+		indexName := quoteIdentifier(fmt.Sprintf(ginIndexNameTemplate, info.DataSetName, column.Name))
+		indexQueries = append(indexQueries, fmt.Sprintf(createGINIndexTemplate, indexName, hypertableName, quoteIdentifier(column.Name)))
+	}
+
+	for _, indexQuery := range indexQueries {
+		log.Printf("Creating index with: %s", indexQuery)
+		if _, err := dbConn.Exec(indexQuery); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CreateUniqueIndex creates a unique index of the keys on the hypertable, if one with its name doesn't exist.
+// The keys are quoted columns or expressions
+func (d *defaultTableCreator) CreateUniqueIndex(dbConn connections.PgxWrap, info *idrf.DataSet, keys []string) error {
+	indexName := quoteIdentifier(fmt.Sprintf(uniqueIndexNameTemplate, info.DataSetName))
+	indexQuery := fmt.Sprintf(createUniqueIndexTemplate, indexName, d.qualifiedName(info.DataSetName), strings.Join(keys, ", "))
+	log.Printf("Creating unique index with: %s", indexQuery)
+	_, err := dbConn.Exec(indexQuery)
+	return err
+}
+
+func isEpochTime(info *idrf.DataSet) bool {
+	timeColumn := info.ColumnNamed(info.TimeColumn)
+	return timeColumn != nil && timeColumn.DataType == idrf.IDRFInteger64
+}
+
+func quoteIdentifier(identifier string) string {
+	return `"` + strings.Replace(identifier, `"`, `""`, -1) + `"`
+}
+
+func escapeLiteral(literal string) string {
+	return strings.Replace(literal, "'", "''", -1)
+}
+
 func dataSetToSQLTableDef(schema string, dataSet *idrf.DataSet) string {
 	columnDefinitions := make([]string, len(dataSet.Columns))
 	for i, column := range dataSet.Columns {
@@ -3,11 +3,13 @@ package ts
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/jackc/pgx"
 	"github.com/stretchr/testify/assert"
 	"github.com/timescale/outflux/internal/connections"
 	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
 )
 
 func TestDataSetToSQLTableDef(t *testing.T) {
@@ -158,6 +160,7 @@ func TestCreateHypertable(t *testing.T) {
 		info                *idrf.DataSet
 		schema              string
 		chunkTimeInterval   string
+		spacePartitioning   *schemaconfig.SpacePartitioning
 		expectErr           bool
 		expectNumExecCalls  int
 		expectNumQueryCalls int
@@ -207,6 +210,47 @@ func TestCreateHypertable(t *testing.T) {
 				ExecErrs: []error{nil}},
 			expectNumExecCalls: 1,
 			expectedExecs:      []string{`SELECT create_hypertable('"she ma"."` + tabName + `"', 'tajm col', chunk_time_interval => interval '1m');`},
+		}, {
+			desc: "BIGINT time column",
+			info: &idrf.DataSet{
+				Columns:     []*idrf.Column{{Name: "tajm col", DataType: idrf.IDRFInteger64}},
+				TimeColumn:  "tajm col",
+				DataSetName: tabName},
+			schema: "she ma",
+			db: &connections.MockPgxW{
+				ExecRes:  []pgx.CommandTag{"", "", ""},
+				ExecErrs: []error{nil, nil, nil}},
+			expectNumExecCalls: 3,
+			expectedExecs: []string{
+				`SELECT create_hypertable('"she ma"."` + tabName + `"', 'tajm col', chunk_time_interval => (EXTRACT(EPOCH FROM interval '7 days') * 1000000000)::BIGINT);`,
+				`CREATE OR REPLACE FUNCTION "she ma"."outflux_epoch_ns_now"() RETURNS BIGINT LANGUAGE SQL STABLE AS
+		$$ SELECT (EXTRACT(EPOCH FROM now()) * 1000000000)::BIGINT $$`,
+				`SELECT set_integer_now_func('"she ma"."` + tabName + `"', '"she ma"."outflux_epoch_ns_now"');`,
+			},
+		}, {
+			desc: "space partitioning",
+			info: &idrf.DataSet{
+				Columns:     []*idrf.Column{{Name: "tajm col", DataType: idrf.IDRFTimestamptz}, {Name: "host", DataType: idrf.IDRFString}},
+				TimeColumn:  "tajm col",
+				DataSetName: tabName},
+			spacePartitioning: &schemaconfig.SpacePartitioning{Column: "host", Partitions: 4},
+			db: &connections.MockPgxW{
+				ExecRes:  []pgx.CommandTag{"", ""},
+				ExecErrs: []error{nil, nil}},
+			expectNumExecCalls: 2,
+			expectedExecs: []string{
+				`SELECT create_hypertable('"` + tabName + `"', 'tajm col');`,
+				`SELECT add_dimension('"` + tabName + `"', 'host', number_partitions => 4);`,
+			},
+		}, {
+			desc: "space partitioning by a missing column",
+			info: &idrf.DataSet{
+				Columns:     []*idrf.Column{{Name: "tajm col", DataType: idrf.IDRFTimestamptz}},
+				TimeColumn:  "tajm col",
+				DataSetName: tabName},
+			spacePartitioning: &schemaconfig.SpacePartitioning{Column: "host", Partitions: 4},
+			db:                &connections.MockPgxW{},
+			expectErr:         true,
 		},
 	}
 	for _, tc := range testCases {
@@ -214,6 +258,7 @@ func TestCreateHypertable(t *testing.T) {
 			c := &defaultTableCreator{
 				schema:            tc.schema,
 				chunkTimeInterval: tc.chunkTimeInterval,
+				spacePartitioning: tc.spacePartitioning,
 			}
 			err := c.CreateHypertable(tc.db, tc.info)
 			if tc.expectErr {
@@ -228,3 +273,157 @@ func TestCreateHypertable(t *testing.T) {
 		})
 	}
 }
+
+func TestAddRetentionPolicy(t *testing.T) {
+	timeCol := &idrf.Column{Name: "time", DataType: idrf.IDRFTimestamptz}
+	epochCol := &idrf.Column{Name: "time", DataType: idrf.IDRFInteger64}
+	testCases := []struct {
+		desc         string
+		info         *idrf.DataSet
+		schema       string
+		expectedExec string
+	}{
+		{
+			desc:         "timestamptz time column",
+			info:         &idrf.DataSet{DataSetName: "tab", Columns: []*idrf.Column{timeCol}, TimeColumn: "time"},
+			schema:       "she ma",
+			expectedExec: `SELECT add_retention_policy('"she ma"."tab"', drop_after => interval '2592000 seconds', if_not_exists => true);`,
+		}, {
+			desc:         "epoch nanoseconds time column",
+			info:         &idrf.DataSet{DataSetName: "tab", Columns: []*idrf.Column{epochCol}, TimeColumn: "time"},
+			expectedExec: `SELECT add_retention_policy('"tab"', drop_after => 2592000000000000::BIGINT, if_not_exists => true);`,
+		},
+	}
+
+	for _, tc := range testCases {
+		db := &connections.MockPgxW{ExecRes: []pgx.CommandTag{""}, ExecErrs: []error{nil}}
+		c := &defaultTableCreator{schema: tc.schema}
+		err := c.AddRetentionPolicy(db, tc.info, 30*24*time.Hour)
+		assert.NoError(t, err, tc.desc)
+		assert.Equal(t, []string{tc.expectedExec}, db.ExpExec, tc.desc)
+	}
+}
+
+func TestEnableCompression(t *testing.T) {
+	info := &idrf.DataSet{DataSetName: "tab"}
+	testCases := []struct {
+		desc         string
+		compression  *schemaconfig.Compression
+		schema       string
+		expectedExec string
+	}{
+		{
+			desc:         "default options",
+			compression:  &schemaconfig.Compression{},
+			expectedExec: `ALTER TABLE "tab" SET (timescaledb.compress)`,
+		}, {
+			desc:         "segmentby and orderby",
+			compression:  &schemaconfig.Compression{SegmentBy: []string{"host", `it's "dc"`}, OrderBy: `"time" DESC`},
+			schema:       "she ma",
+			expectedExec: `ALTER TABLE "she ma"."tab" SET (timescaledb.compress, timescaledb.compress_segmentby = '"host", "it''s ""dc"""', timescaledb.compress_orderby = '"time" DESC')`,
+		},
+	}
+
+	for _, tc := range testCases {
+		db := &connections.MockPgxW{ExecRes: []pgx.CommandTag{""}, ExecErrs: []error{nil}}
+		c := &defaultTableCreator{schema: tc.schema}
+		err := c.EnableCompression(db, info, tc.compression)
+		assert.NoError(t, err, tc.desc)
+		assert.Equal(t, []string{tc.expectedExec}, db.ExpExec, tc.desc)
+	}
+}
+
+func TestAddCompressionPolicy(t *testing.T) {
+	timeCol := &idrf.Column{Name: "time", DataType: idrf.IDRFTimestamptz}
+	epochCol := &idrf.Column{Name: "time", DataType: idrf.IDRFInteger64}
+	testCases := []struct {
+		desc         string
+		info         *idrf.DataSet
+		expectedExec string
+	}{
+		{
+			desc:         "timestamptz time column",
+			info:         &idrf.DataSet{DataSetName: "tab", Columns: []*idrf.Column{timeCol}, TimeColumn: "time"},
+			expectedExec: `SELECT add_compression_policy('"tab"', compress_after => interval '604800 seconds', if_not_exists => true);`,
+		}, {
+			desc:         "epoch nanoseconds time column",
+			info:         &idrf.DataSet{DataSetName: "tab", Columns: []*idrf.Column{epochCol}, TimeColumn: "time"},
+			expectedExec: `SELECT add_compression_policy('"tab"', compress_after => 604800000000000::BIGINT, if_not_exists => true);`,
+		},
+	}
+
+	for _, tc := range testCases {
+		db := &connections.MockPgxW{ExecRes: []pgx.CommandTag{""}, ExecErrs: []error{nil}}
+		c := &defaultTableCreator{}
+		err := c.AddCompressionPolicy(db, tc.info, 7*24*time.Hour)
+		assert.NoError(t, err, tc.desc)
+		assert.Equal(t, []string{tc.expectedExec}, db.ExpExec, tc.desc)
+	}
+}
+
+func TestCompressChunks(t *testing.T) {
+	timeCol := &idrf.Column{Name: "time", DataType: idrf.IDRFTimestamptz}
+	epochCol := &idrf.Column{Name: "time", DataType: idrf.IDRFInteger64}
+	testCases := []struct {
+		desc          string
+		info          *idrf.DataSet
+		expectedQuery string
+	}{
+		{
+			desc: "timestamptz time column",
+			info: &idrf.DataSet{DataSetName: "tab", Columns: []*idrf.Column{timeCol}, TimeColumn: "time"},
+			expectedQuery: `SELECT count(compress_chunk(c, if_not_compressed => true)) ` +
+				`FROM show_chunks('"s"."tab"', older_than => interval '3600 seconds') c;`,
+		}, {
+			desc: "epoch nanoseconds time column",
+			info: &idrf.DataSet{DataSetName: "tab", Columns: []*idrf.Column{epochCol}, TimeColumn: "time"},
+			expectedQuery: `SELECT count(compress_chunk(c, if_not_compressed => true)) ` +
+				`FROM show_chunks('"s"."tab"', older_than => "s"."outflux_epoch_ns_now"() - 3600000000000::BIGINT) c;`,
+		},
+	}
+
+	for _, tc := range testCases {
+		db := &connections.MockPgxW{QueryRes: []*pgx.Rows{nil}, QueryErrs: []error{errors.New("generic error")}}
+		c := &defaultTableCreator{schema: "s"}
+		_, err := c.CompressChunks(db, tc.info, time.Hour)
+		assert.Error(t, err, tc.desc)
+		assert.Equal(t, []string{tc.expectedQuery}, db.ExpQ, tc.desc)
+	}
+}
+
+func TestCreateIndexes(t *testing.T) {
+	info := &idrf.DataSet{
+		DataSetName: "tab",
+		TimeColumn:  "time",
+		Columns: []*idrf.Column{
+			{Name: "time", DataType: idrf.IDRFTimestamptz},
+			{Name: "host", DataType: idrf.IDRFString},
+			{Name: "tags", DataType: idrf.IDRFJson},
+			{Name: "fields", DataType: idrf.IDRFJson},
+		},
+	}
+	db := &connections.MockPgxW{ExecRes: []pgx.CommandTag{"", "", ""}, ExecErrs: []error{nil, nil, nil}}
+	c := &defaultTableCreator{schema: "she ma"}
+	err := c.CreateIndexes(db, info, &schemaconfig.Indexes{Columns: []string{"host", "missing"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		`CREATE INDEX IF NOT EXISTS "tab_host_time_idx" ON "she ma"."tab" ("host", "time" DESC);`,
+		`CREATE INDEX IF NOT EXISTS "tab_tags_gin_idx" ON "she ma"."tab" USING GIN ("tags");`,
+		`CREATE INDEX IF NOT EXISTS "tab_fields_gin_idx" ON "she ma"."tab" USING GIN ("fields");`,
+	}, db.ExpExec)
+
+	db = &connections.MockPgxW{ExecRes: []pgx.CommandTag{""}, ExecErrs: []error{errors.New("error")}}
+	assert.Error(t, c.CreateIndexes(db, info, &schemaconfig.Indexes{}))
+	assert.Equal(t, 1, db.CurrentExec)
+}
+
+func TestCreateUniqueIndex(t *testing.T) {
+	info := &idrf.DataSet{DataSetName: "tab", TimeColumn: "time"}
+	db := &connections.MockPgxW{ExecRes: []pgx.CommandTag{""}, ExecErrs: []error{nil}}
+	c := &defaultTableCreator{schema: "she ma"}
+	assert.NoError(t, c.CreateUniqueIndex(db, info, []string{`"time"`, `COALESCE("host", '')`}))
+	assert.Equal(t, []string{`CREATE UNIQUE INDEX IF NOT EXISTS "tab_upsert_idx" ON "she ma"."tab" ("time", COALESCE("host", ''));`}, db.ExpExec)
+
+	db = &connections.MockPgxW{ExecRes: []pgx.CommandTag{""}, ExecErrs: []error{errors.New("error")}}
+	assert.Error(t, c.CreateUniqueIndex(db, info, []string{"time"}))
+}
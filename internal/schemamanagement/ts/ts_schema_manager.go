@@ -3,6 +3,8 @@ package ts
 import (
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/timescale/outflux/internal/connections"
 	"github.com/timescale/outflux/internal/idrf"
@@ -12,31 +14,90 @@ import (
 // TSSchemaManager implements the schemamanagement.SchemaManager interface for TimescaleDB
 type TSSchemaManager struct {
 	explorer schemaExplorer
+	dataSets dataSetExplorer
 	creator  tableCreator
 	dropper  tableDropper
 	dbConn   connections.PgxWrap
 	schema   string
+	// retentionPeriod if > 0, created hypertables get a retention policy dropping older chunks
+	retentionPeriod time.Duration
+	// compression if not nil, is enabled on created hypertables
+	compression *schemaconfig.Compression
+	// spacePartitioning if not nil, created hypertables are hash partitioned by its column, and
+	// existing hypertables must be
+	spacePartitioning *schemaconfig.SpacePartitioning
+	// indexes if not nil, are created once the data of a data set is loaded
+	indexes *schemaconfig.Indexes
 }
 
-// NewTSSchemaManager creates a new TimeScale Schema Manager
-func NewTSSchemaManager(dbConn connections.PgxWrap, schema, chunkTimeInterval string) *TSSchemaManager {
+// NewTSSchemaManager creates a new TimeScale Schema Manager. If the retention period is > 0,
+// a retention policy is added to the hypertables it creates, and if compression is not nil
+// it's enabled on them. If a space partitioning is given, the hypertables are also partitioned by its column.
+// The indexes are not created with the hypertables, but when CreateIndexes is called
+func NewTSSchemaManager(dbConn connections.PgxWrap, schema, chunkTimeInterval string, retentionPeriod time.Duration, compression *schemaconfig.Compression, spacePartitioning *schemaconfig.SpacePartitioning, indexes *schemaconfig.Indexes) *TSSchemaManager {
 	return &TSSchemaManager{
-		dbConn:   dbConn,
-		schema:   schema,
-		explorer: newSchemaExplorer(),
-		creator:  newTableCreator(schema, chunkTimeInterval),
-		dropper:  newTableDropper(),
+		dbConn:            dbConn,
+		schema:            schema,
+		retentionPeriod:   retentionPeriod,
+		compression:       compression,
+		spacePartitioning: spacePartitioning,
+		indexes:           indexes,
+		explorer:          newSchemaExplorer(),
+		dataSets:          &defaultDataSetExplorer{},
+		creator:           newTableCreator(schema, chunkTimeInterval, spacePartitioning),
+		dropper:           newTableDropper(),
 	}
 }
 
-// DiscoverDataSets not implemented
+// DiscoverDataSets returns the tables of the schema, when the database is the input of a migration
 func (sm *TSSchemaManager) DiscoverDataSets() ([]string, error) {
-	panic(fmt.Errorf("not implemented"))
+	tables, err := sm.dataSets.schemaTables(sm.dbConn, sm.schema)
+	if err != nil {
+		return nil, fmt.Errorf("could not list the tables of schema '%s'\n%v", sm.schema, err)
+	}
+
+	return tables, nil
 }
 
-// FetchDataSet not implemented
+// FetchDataSet describes a table of the schema as a data set, when the database is the input of a migration.
+// The time column of a hypertable is its first dimension, the one of another table its first timestamp column
 func (sm *TSSchemaManager) FetchDataSet(dataSetIdentifier string) (*idrf.DataSet, error) {
-	panic(fmt.Errorf("not implemented"))
+	columnDescs, err := sm.explorer.fetchTableColumns(sm.dbConn, sm.schema, dataSetIdentifier)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch the columns of table '%s'\n%v", dataSetIdentifier, err)
+	}
+
+	if len(columnDescs) == 0 {
+		return nil, fmt.Errorf("table '%s' doesn't exist or has no columns", dataSetIdentifier)
+	}
+
+	timeColumn := ""
+	timescaleExists, err := sm.explorer.timescaleExists(sm.dbConn)
+	if err != nil {
+		return nil, fmt.Errorf("could not check if TimescaleDB is installed\n%v", err)
+	}
+
+	if timescaleExists {
+		if timeColumn, err = sm.dataSets.timeDimension(sm.dbConn, sm.schema, dataSetIdentifier); err != nil {
+			return nil, fmt.Errorf("could not find the time dimension of table '%s'\n%v", dataSetIdentifier, err)
+		}
+	}
+
+	columns := make([]*idrf.Column, len(columnDescs))
+	for i, columnDesc := range columnDescs {
+		dataType := pgTypeToIdrf(columnDesc.dataType)
+		if dataType == idrf.IDRFUnknown {
+			return nil, fmt.Errorf("column '%s' of table '%s' has type %s, that can't be read", columnDesc.columnName, dataSetIdentifier, columnDesc.dataType)
+		}
+
+		if timeColumn == "" && (dataType == idrf.IDRFTimestamptz || dataType == idrf.IDRFTimestamp) {
+			timeColumn = columnDesc.columnName
+		}
+
+		columns[i] = &idrf.Column{Name: columnDesc.columnName, DataType: dataType}
+	}
+
+	return idrf.NewDataSet(dataSetIdentifier, columns, timeColumn)
 }
 
 // PrepareDataSet prepares a table in TimeScale compatible with the provided dataSet
@@ -108,13 +169,21 @@ func (sm *TSSchemaManager) prepareWithDropStrategy(dataSet *idrf.DataSet, strate
 	}
 
 	log.Printf("Table %s ready to be created", dataSet.DataSetName)
-	return sm.creator.CreateTable(sm.dbConn, dataSet)
+	if err := sm.creator.CreateTable(sm.dbConn, dataSet); err != nil {
+		return err
+	}
+
+	return sm.addPolicies(dataSet)
 }
 
 func (sm *TSSchemaManager) prepareWithCreateIfMissing(dataSet *idrf.DataSet, tableExists bool) error {
 	if !tableExists {
 		log.Printf("CreateIfMissing strategy: Table %s does not exist. Creating", dataSet.DataSetName)
-		return sm.creator.CreateTable(sm.dbConn, dataSet)
+		if err := sm.creator.CreateTable(sm.dbConn, dataSet); err != nil {
+			return err
+		}
+
+		return sm.addPolicies(dataSet)
 	}
 
 	if err := sm.validateColumns(dataSet); err != nil {
@@ -139,14 +208,91 @@ func (sm *TSSchemaManager) prepareWithCreateIfMissing(dataSet *idrf.DataSet, tab
 	}
 
 	if !isHypertable {
-		err := sm.creator.CreateHypertable(sm.dbConn, dataSet)
-		return err
+		if err := sm.creator.CreateHypertable(sm.dbConn, dataSet); err != nil {
+			return err
+		}
+
+		return sm.addPolicies(dataSet)
 	}
 
 	return sm.validatePartitioning(dataSet)
 
 }
 
+// addPolicies adds a retention policy to a created hypertable if a retention period was given,
+// and enables its compression if requested
+func (sm *TSSchemaManager) addPolicies(dataSet *idrf.DataSet) error {
+	return addHypertablePolicies(sm.creator, sm.dbConn, dataSet, sm.retentionPeriod, sm.compression)
+}
+
+// addHypertablePolicies adds a retention policy to a created hypertable if the retention period is > 0,
+// and enables its compression if compression is not nil
+func addHypertablePolicies(creator tableCreator, dbConn connections.PgxWrap, dataSet *idrf.DataSet, retentionPeriod time.Duration, compression *schemaconfig.Compression) error {
+	if retentionPeriod > 0 {
+		if err := creator.AddRetentionPolicy(dbConn, dataSet, retentionPeriod); err != nil {
+			return fmt.Errorf("could not add retention policy to hypertable '%s'\n%v", dataSet.DataSetName, err)
+		}
+	}
+
+	if compression == nil {
+		return nil
+	}
+
+	if err := creator.EnableCompression(dbConn, dataSet, compression); err != nil {
+		return fmt.Errorf("could not enable compression of hypertable '%s'\n%v", dataSet.DataSetName, err)
+	}
+
+	if compression.CompressAfter <= 0 {
+		return nil
+	}
+
+	if err := creator.AddCompressionPolicy(dbConn, dataSet, compression.CompressAfter); err != nil {
+		return fmt.Errorf("could not add compression policy to hypertable '%s'\n%v", dataSet.DataSetName, err)
+	}
+
+	return nil
+}
+
+// CompressChunks compresses the chunks of the hypertable of the data set with data older than the
+// compress after duration, if compression with a compress after duration was requested
+func (sm *TSSchemaManager) CompressChunks(dataSet *idrf.DataSet) error {
+	if sm.compression == nil || sm.compression.CompressAfter <= 0 {
+		return nil
+	}
+
+	compressed, err := sm.creator.CompressChunks(sm.dbConn, dataSet, sm.compression.CompressAfter)
+	if err != nil {
+		return fmt.Errorf("could not compress the chunks of hypertable '%s'\n%v", dataSet.DataSetName, err)
+	}
+
+	log.Printf("Compressed %d chunks of hypertable '%s'", compressed, dataSet.DataSetName)
+	return nil
+}
+
+// CreateIndexes creates the requested indexes on the hypertable of the data set, meant to be
+// called once its data is loaded
+func (sm *TSSchemaManager) CreateIndexes(dataSet *idrf.DataSet) error {
+	if sm.indexes == nil {
+		return nil
+	}
+
+	if err := sm.creator.CreateIndexes(sm.dbConn, dataSet, sm.indexes); err != nil {
+		return fmt.Errorf("could not create the indexes of hypertable '%s'\n%v", dataSet.DataSetName, err)
+	}
+
+	return nil
+}
+
+// CreateUniqueIndex creates the unique index the rows of the data set are upserted on, meant to be
+// called before its data is loaded. An existing table must not have duplicate rows
+func (sm *TSSchemaManager) CreateUniqueIndex(dataSet *idrf.DataSet, keys []string) error {
+	if err := sm.creator.CreateUniqueIndex(sm.dbConn, dataSet, keys); err != nil {
+		return fmt.Errorf("could not create the unique index of hypertable '%s' on (%s), it can't have duplicate rows\n%v", dataSet.DataSetName, strings.Join(keys, ", "), err)
+	}
+
+	return nil
+}
+
 func (sm *TSSchemaManager) validateColumns(dataSet *idrf.DataSet) error {
 	existingTableColumns, err := sm.explorer.fetchTableColumns(sm.dbConn, sm.schema, dataSet.DataSetName)
 	if err != nil {
@@ -162,11 +308,16 @@ func (sm *TSSchemaManager) validateColumns(dataSet *idrf.DataSet) error {
 }
 
 func (sm *TSSchemaManager) validatePartitioning(dataSet *idrf.DataSet) error {
-	isPartitionedProperly, err := sm.explorer.isTimePartitionedBy(sm.dbConn, sm.schema, dataSet.DataSetName, dataSet.TimeColumn)
+	isPartitionedProperly, err := sm.explorer.isTimePartitionedBy(sm.dbConn, sm.schema, dataSet.DataSetName, dataSet.TimeColumn, sm.spacePartitioning)
 	if err != nil {
 		return fmt.Errorf("could not check if existing hypertable '%s' is partitioned properly\n%v", dataSet.DataSetName, err)
 	}
 
+	if !isPartitionedProperly && sm.spacePartitioning != nil {
+		return fmt.Errorf("existing hypertable '%s' is not partitioned by timestamp column: %s and by column: %s in %d partitions",
+			dataSet.DataSetName, dataSet.TimeColumn, sm.spacePartitioning.Column, sm.spacePartitioning.Partitions)
+	}
+
 	if !isPartitionedProperly {
 		return fmt.Errorf("existing hypertable '%s' is not partitioned by timestamp column: %s", dataSet.DataSetName, dataSet.TimeColumn)
 	}
@@ -3,6 +3,7 @@ package ts
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/timescale/outflux/internal/connections"
@@ -188,9 +189,191 @@ func TestPrepareOk(t *testing.T) {
 	}
 }
 
+func TestPrepareDataSetAddsRetentionPolicy(t *testing.T) {
+	dataSet := &idrf.DataSet{
+		DataSetName: "ds",
+		Columns:     []*idrf.Column{{Name: "time", DataType: idrf.IDRFTimestamptz}},
+		TimeColumn:  "time",
+	}
+	existingColumns := []*columnDesc{{"time", "timestamp with time zone", "NO"}}
+	testCases := []struct {
+		desc            string
+		mock            *mocker
+		strategy        schemaconfig.SchemaStrategy
+		retentionPeriod time.Duration
+		expectPolicy    time.Duration
+		expectErr       bool
+	}{
+		{
+			desc:     "no retention period",
+			mock:     &mocker{},
+			strategy: schemaconfig.CreateIfMissing,
+		}, {
+			desc:            "table created",
+			mock:            &mocker{},
+			strategy:        schemaconfig.DropAndCreate,
+			retentionPeriod: time.Hour,
+			expectPolicy:    time.Hour,
+		}, {
+			desc:            "existing table made a hypertable",
+			mock:            &mocker{tableExistsR: true, fetcColR: existingColumns, tsExt: true},
+			strategy:        schemaconfig.CreateIfMissing,
+			retentionPeriod: time.Hour,
+			expectPolicy:    time.Hour,
+		}, {
+			desc:            "existing hypertable is left as is",
+			mock:            &mocker{tableExistsR: true, fetcColR: existingColumns, tsExt: true, isHyper: true, isTimePartBy: true},
+			strategy:        schemaconfig.CreateIfMissing,
+			retentionPeriod: time.Hour,
+		}, {
+			desc:            "error adding the policy",
+			mock:            &mocker{retentionPolicyErr: fmt.Errorf("error")},
+			strategy:        schemaconfig.CreateIfMissing,
+			retentionPeriod: time.Hour,
+			expectPolicy:    time.Hour,
+			expectErr:       true,
+		},
+	}
+
+	for _, tc := range testCases {
+		manager := &TSSchemaManager{
+			explorer:        tc.mock,
+			creator:         tc.mock,
+			dropper:         tc.mock,
+			retentionPeriod: tc.retentionPeriod,
+		}
+
+		err := manager.PrepareDataSet(dataSet, tc.strategy)
+		assert.Equal(t, tc.expectErr, err != nil, tc.desc)
+		assert.Equal(t, tc.expectPolicy, tc.mock.retentionPolicy, tc.desc)
+	}
+}
+
+func TestPrepareDataSetEnablesCompression(t *testing.T) {
+	dataSet := &idrf.DataSet{
+		DataSetName: "ds",
+		Columns:     []*idrf.Column{{Name: "time", DataType: idrf.IDRFTimestamptz}},
+		TimeColumn:  "time",
+	}
+	compression := &schemaconfig.Compression{SegmentBy: []string{"host"}, CompressAfter: 24 * time.Hour}
+	testCases := []struct {
+		desc         string
+		mock         *mocker
+		strategy     schemaconfig.SchemaStrategy
+		compression  *schemaconfig.Compression
+		expectPolicy time.Duration
+		expectErr    bool
+	}{
+		{
+			desc:     "no compression",
+			mock:     &mocker{},
+			strategy: schemaconfig.CreateIfMissing,
+		}, {
+			desc:        "compression without a policy",
+			mock:        &mocker{},
+			strategy:    schemaconfig.DropAndCreate,
+			compression: &schemaconfig.Compression{OrderBy: "time"},
+		}, {
+			desc:         "compression with a policy",
+			mock:         &mocker{},
+			strategy:     schemaconfig.CreateIfMissing,
+			compression:  compression,
+			expectPolicy: 24 * time.Hour,
+		}, {
+			desc:        "existing hypertable is left as is",
+			mock:        &mocker{tableExistsR: true, fetcColR: []*columnDesc{{"time", "timestamp with time zone", "NO"}}, tsExt: true, isHyper: true, isTimePartBy: true},
+			strategy:    schemaconfig.CreateIfMissing,
+			compression: compression,
+		}, {
+			desc:        "error enabling compression",
+			mock:        &mocker{compressionErr: fmt.Errorf("error")},
+			strategy:    schemaconfig.CreateIfMissing,
+			compression: compression,
+			expectErr:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		manager := &TSSchemaManager{
+			explorer:    tc.mock,
+			creator:     tc.mock,
+			dropper:     tc.mock,
+			compression: tc.compression,
+		}
+
+		err := manager.PrepareDataSet(dataSet, tc.strategy)
+		assert.Equal(t, tc.expectErr, err != nil, tc.desc)
+		if tc.expectErr || tc.mock.tableExistsR {
+			assert.Equal(t, time.Duration(0), tc.mock.compressionPolicy, tc.desc)
+			continue
+		}
+
+		assert.Equal(t, tc.compression, tc.mock.compression, tc.desc)
+		assert.Equal(t, tc.expectPolicy, tc.mock.compressionPolicy, tc.desc)
+	}
+}
+
+func TestSchemaManagerCompressChunks(t *testing.T) {
+	dataSet := &idrf.DataSet{DataSetName: "ds"}
+	mock := &mocker{}
+	manager := &TSSchemaManager{creator: mock}
+	assert.NoError(t, manager.CompressChunks(dataSet))
+	manager.compression = &schemaconfig.Compression{}
+	assert.NoError(t, manager.CompressChunks(dataSet))
+	assert.Equal(t, time.Duration(0), mock.compressedOlderThan)
+
+	manager.compression.CompressAfter = time.Hour
+	assert.NoError(t, manager.CompressChunks(dataSet))
+	assert.Equal(t, time.Hour, mock.compressedOlderThan)
+
+	mock.compressErr = fmt.Errorf("error")
+	assert.Error(t, manager.CompressChunks(dataSet))
+}
+
+func TestValidatePartitioningWithSpacePartitioning(t *testing.T) {
+	dataSet := &idrf.DataSet{DataSetName: "ds", TimeColumn: "time"}
+	space := &schemaconfig.SpacePartitioning{Column: "host", Partitions: 4}
+	mock := &mocker{isTimePartBy: true}
+	manager := &TSSchemaManager{explorer: mock, spacePartitioning: space}
+	assert.NoError(t, manager.validatePartitioning(dataSet))
+	assert.Equal(t, space, mock.spacePartitioning)
+
+	mock.isTimePartBy = false
+	err := manager.validatePartitioning(dataSet)
+	assert.EqualError(t, err, "existing hypertable 'ds' is not partitioned by timestamp column: time and by column: host in 4 partitions")
+}
+
+func TestSchemaManagerCreateIndexes(t *testing.T) {
+	dataSet := &idrf.DataSet{DataSetName: "ds"}
+	mock := &mocker{}
+	manager := &TSSchemaManager{creator: mock}
+	assert.NoError(t, manager.CreateIndexes(dataSet))
+	assert.Nil(t, mock.indexes)
+
+	manager.indexes = &schemaconfig.Indexes{Columns: []string{"host"}}
+	assert.NoError(t, manager.CreateIndexes(dataSet))
+	assert.Equal(t, manager.indexes, mock.indexes)
+
+	mock.indexesErr = fmt.Errorf("error")
+	assert.Error(t, manager.CreateIndexes(dataSet))
+}
+
+func TestSchemaManagerCreateUniqueIndex(t *testing.T) {
+	dataSet := &idrf.DataSet{DataSetName: "ds"}
+	mock := &mocker{}
+	manager := &TSSchemaManager{creator: mock}
+	assert.NoError(t, manager.CreateUniqueIndex(dataSet, []string{"time", "host"}))
+	assert.Equal(t, []string{"time", "host"}, mock.uniqueColumns)
+
+	mock.uniqueIndexErr = fmt.Errorf("error")
+	assert.Error(t, manager.CreateUniqueIndex(dataSet, []string{"time"}))
+}
+
 func TestNewTsSchemaManager(t *testing.T) {
-	sm := NewTSSchemaManager(&connections.MockPgxW{}, "she ma", "1m")
+	space := &schemaconfig.SpacePartitioning{Column: "host", Partitions: 4}
+	sm := NewTSSchemaManager(&connections.MockPgxW{}, "she ma", "1m", time.Hour, nil, space, nil)
 	assert.Equal(t, "she ma", sm.schema)
+	assert.Equal(t, time.Hour, sm.retentionPeriod)
 	assert.NotNil(t, sm.dbConn)
 	assert.NotNil(t, sm.explorer)
 	assert.NotNil(t, sm.creator)
@@ -198,6 +381,62 @@ func TestNewTsSchemaManager(t *testing.T) {
 	creator := sm.creator.(*defaultTableCreator)
 	assert.Equal(t, "she ma", creator.schema)
 	assert.Equal(t, "1m", creator.chunkTimeInterval)
+	assert.Equal(t, space, sm.spacePartitioning)
+	assert.Equal(t, space, creator.spacePartitioning)
+}
+
+func TestFetchDataSet(t *testing.T) {
+	columns := []*columnDesc{
+		{"host", "text", "YES"},
+		{"time", "timestamp with time zone", "NO"},
+		{"tags", "jsonb", "YES"},
+		{"epoch", "bigint", "YES"},
+	}
+	expectedColumns := []*idrf.Column{
+		{Name: "host", DataType: idrf.IDRFString},
+		{Name: "time", DataType: idrf.IDRFTimestamptz},
+		{Name: "tags", DataType: idrf.IDRFJson},
+		{Name: "epoch", DataType: idrf.IDRFInteger64},
+	}
+	testCases := []struct {
+		desc         string
+		mocker       *mocker
+		expectedTime string
+		expectErr    bool
+	}{
+		{desc: "first timestamp column of a table", mocker: &mocker{fetcColR: columns}, expectedTime: "time"},
+		{desc: "first dimension of a hypertable", mocker: &mocker{fetcColR: columns, tsExt: true, timeDimensionR: "epoch"}, expectedTime: "epoch"},
+		{desc: "first timestamp column of a table that is not a hypertable", mocker: &mocker{fetcColR: columns, tsExt: true}, expectedTime: "time"},
+		{desc: "table doesn't exist", mocker: &mocker{}, expectErr: true},
+		{desc: "error fetching columns", mocker: &mocker{fetchColError: fmt.Errorf("error")}, expectErr: true},
+		{desc: "error checking TimescaleDB", mocker: &mocker{fetcColR: columns, tsExtErr: fmt.Errorf("error")}, expectErr: true},
+		{desc: "error finding the dimension", mocker: &mocker{fetcColR: columns, tsExt: true, timeDimensionErr: fmt.Errorf("error")}, expectErr: true},
+		{desc: "no time column", mocker: &mocker{fetcColR: columns[:1]}, expectErr: true},
+		{desc: "column of an unknown type", mocker: &mocker{fetcColR: append([]*columnDesc{{"value", "numeric", "YES"}}, columns...)}, expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		sm := &TSSchemaManager{explorer: newSchemaExplorerWith(tc.mocker, tc.mocker, nil, nil, tc.mocker), dataSets: tc.mocker}
+		dataSet, err := sm.FetchDataSet("cpu")
+		if tc.expectErr {
+			assert.Error(t, err, tc.desc)
+			continue
+		}
+
+		if assert.NoError(t, err, tc.desc) {
+			assert.Equal(t, &idrf.DataSet{DataSetName: "cpu", Columns: expectedColumns, TimeColumn: tc.expectedTime}, dataSet, tc.desc)
+		}
+	}
+}
+
+func TestDiscoverDataSets(t *testing.T) {
+	sm := &TSSchemaManager{dataSets: &mocker{tablesR: []string{"cpu", "mem"}}}
+	tables, err := sm.DiscoverDataSets()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cpu", "mem"}, tables)
+	sm.dataSets = &mocker{tablesErr: fmt.Errorf("error")}
+	_, err = sm.DiscoverDataSets()
+	assert.Error(t, err)
 }
 
 func errorOnTableExistsExplorer() schemaExplorer {
@@ -293,6 +532,22 @@ type mocker struct {
 	metadataTable        string
 	metadataTableNameErr error
 	updateMetadataErr    error
+	retentionPolicyErr   error
+	retentionPolicy      time.Duration
+	compressionErr       error
+	compression          *schemaconfig.Compression
+	compressionPolicy    time.Duration
+	compressedOlderThan  time.Duration
+	compressErr          error
+	spacePartitioning    *schemaconfig.SpacePartitioning
+	indexes              *schemaconfig.Indexes
+	indexesErr           error
+	uniqueColumns        []string
+	uniqueIndexErr       error
+	tablesR              []string
+	tablesErr            error
+	timeDimensionR       string
+	timeDimensionErr     error
 }
 
 func (m *mocker) tableExists(db connections.PgxWrap, schemaName, tableName string) (bool, error) {
@@ -323,6 +578,36 @@ func (m *mocker) CreateTimescaleExtension(dbConn connections.PgxWrap) error {
 	return m.extErr
 }
 
+func (m *mocker) AddRetentionPolicy(dbConn connections.PgxWrap, info *idrf.DataSet, dropAfter time.Duration) error {
+	m.retentionPolicy = dropAfter
+	return m.retentionPolicyErr
+}
+
+func (m *mocker) EnableCompression(dbConn connections.PgxWrap, info *idrf.DataSet, compression *schemaconfig.Compression) error {
+	m.compression = compression
+	return m.compressionErr
+}
+
+func (m *mocker) AddCompressionPolicy(dbConn connections.PgxWrap, info *idrf.DataSet, compressAfter time.Duration) error {
+	m.compressionPolicy = compressAfter
+	return nil
+}
+
+func (m *mocker) CompressChunks(dbConn connections.PgxWrap, info *idrf.DataSet, olderThan time.Duration) (int64, error) {
+	m.compressedOlderThan = olderThan
+	return 1, m.compressErr
+}
+
+func (m *mocker) CreateIndexes(dbConn connections.PgxWrap, info *idrf.DataSet, indexes *schemaconfig.Indexes) error {
+	m.indexes = indexes
+	return m.indexesErr
+}
+
+func (m *mocker) CreateUniqueIndex(dbConn connections.PgxWrap, info *idrf.DataSet, columns []string) error {
+	m.uniqueColumns = columns
+	return m.uniqueIndexErr
+}
+
 func (m *mocker) Drop(db connections.PgxWrap, table string, cascade bool) error {
 	return m.dropError
 }
@@ -331,10 +616,19 @@ func (m *mocker) isHypertable(db connections.PgxWrap, schemaName, tableName stri
 	return m.isHyper, m.isHypertableErr
 }
 
-func (m *mocker) isTimePartitionedBy(db connections.PgxWrap, schema, table, time string) (bool, error) {
+func (m *mocker) isTimePartitionedBy(db connections.PgxWrap, schema, table, time string, space *schemaconfig.SpacePartitioning) (bool, error) {
+	m.spacePartitioning = space
 	return m.isTimePartBy, m.isTimePartErr
 }
 
 func (m *mocker) timescaleExists(db connections.PgxWrap) (bool, error) {
 	return m.tsExt, m.tsExtErr
 }
+
+func (m *mocker) schemaTables(db connections.PgxWrap, schemaName string) ([]string, error) {
+	return m.tablesR, m.tablesErr
+}
+
+func (m *mocker) timeDimension(db connections.PgxWrap, schemaName, tableName string) (string, error) {
+	return m.timeDimensionR, m.timeDimensionErr
+}
@@ -0,0 +1,174 @@
+package tsm
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/influx/discovery"
+	"github.com/timescale/outflux/internal/schemamanagement/tsm/tsmstorage"
+)
+
+// influxFieldTypes maps the block types of TSM files to the names InfluxDB uses for field types.
+// Unsigned integers are only written by InfluxDB with a feature flag, they are treated as integers
+var influxFieldTypes = map[byte]string{
+	tsmstorage.FloatType:    "float",
+	tsmstorage.IntegerType:  "integer",
+	tsmstorage.UnsignedType: "integer",
+	tsmstorage.BooleanType:  "boolean",
+	tsmstorage.StringType:   "string",
+}
+
+// Explorer defines an API for discovering the measurements of a database and retention
+// policy stored in TSM shards, and their tags and fields
+type Explorer interface {
+	DiscoverMeasurements(storage *tsmstorage.Storage, db, rp string) ([]string, error)
+	DiscoverMeasurementTags(storage *tsmstorage.Storage, db, rp, measure string) ([]*idrf.Column, error)
+	DiscoverMeasurementFields(storage *tsmstorage.Storage, db, rp, measure string, onConflictConvertIntToFloat bool) ([]*idrf.Column, error)
+}
+
+// storageKey identifies the shards of a database and retention policy in a storage
+type storageKey struct {
+	storage *tsmstorage.Storage
+	db, rp  string
+}
+
+// measureSchema holds the tag keys and the types of each field seen in the series of a measure
+type measureSchema struct {
+	tags   map[string]bool
+	fields map[string]map[string]bool
+}
+
+type defaultExplorer struct {
+	lock    *sync.Mutex
+	scanned map[storageKey]map[string]*measureSchema
+}
+
+// NewExplorer creates a new instance of the Explorer. The schema is inferred from the index
+// of the TSM files and the WAL of each shard, read once and reused for every measure
+func NewExplorer() Explorer {
+	return &defaultExplorer{
+		lock:    &sync.Mutex{},
+		scanned: make(map[storageKey]map[string]*measureSchema),
+	}
+}
+
+func (e *defaultExplorer) DiscoverMeasurements(storage *tsmstorage.Storage, db, rp string) ([]string, error) {
+	measures, err := e.scan(storage, db, rp)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(measures))
+	for name := range measures {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// DiscoverMeasurementTags returns an IDRF column for each tag key of the series of the measure
+func (e *defaultExplorer) DiscoverMeasurementTags(storage *tsmstorage.Storage, db, rp, measure string) ([]*idrf.Column, error) {
+	schema, err := e.measureSchema(storage, db, rp, measure)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(schema.tags))
+	for key := range schema.tags {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	tags := make([]*idrf.Column, len(keys))
+	for i, key := range keys {
+		tags[i], err = idrf.NewColumn(key, idrf.IDRFString)
+		if err != nil {
+			return nil, fmt.Errorf("could not convert tags to IDRF\n%v", err)
+		}
+	}
+
+	return tags, nil
+}
+
+// DiscoverMeasurementFields returns the fields of the measure with their IDRF type.
+// A field can have a different type in each shard, the conflicts are resolved like for a server
+func (e *defaultExplorer) DiscoverMeasurementFields(storage *tsmstorage.Storage, db, rp, measure string, onConflictConvertIntToFloat bool) ([]*idrf.Column, error) {
+	schema, err := e.measureSchema(storage, db, rp, measure)
+	if err != nil {
+		return nil, err
+	}
+
+	fieldsWithType := [][2]string{}
+	for field, types := range schema.fields {
+		for fieldType := range types {
+			fieldsWithType = append(fieldsWithType, [2]string{field, fieldType})
+		}
+	}
+
+	// sorted so the casts chosen for fields with multiple types are logged in a stable order
+	sort.Slice(fieldsWithType, func(i, j int) bool {
+		if fieldsWithType[i][0] != fieldsWithType[j][0] {
+			return fieldsWithType[i][0] < fieldsWithType[j][0]
+		}
+
+		return fieldsWithType[i][1] < fieldsWithType[j][1]
+	})
+	return discovery.ConvertFields(fieldsWithType, onConflictConvertIntToFloat)
+}
+
+func (e *defaultExplorer) measureSchema(storage *tsmstorage.Storage, db, rp, measure string) (*measureSchema, error) {
+	measures, err := e.scan(storage, db, rp)
+	if err != nil {
+		return nil, err
+	}
+
+	schema, ok := measures[measure]
+	if !ok {
+		return nil, fmt.Errorf("measure '%s' not found in the shards of %s.%s", measure, db, rp)
+	}
+
+	return schema, nil
+}
+
+// scan reads the keys of every shard of the database and retention policy once, and
+// collects the tag keys and field types of each measure
+func (e *defaultExplorer) scan(storage *tsmstorage.Storage, db, rp string) (map[string]*measureSchema, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	key := storageKey{storage, db, rp}
+	if measures, ok := e.scanned[key]; ok {
+		return measures, nil
+	}
+
+	measures := make(map[string]*measureSchema)
+	for _, shard := range storage.Shards(db, rp) {
+		log.Printf("Discovering the schema of the series in shard: %d", shard.ID)
+		err := shard.ReadKeys(func(fieldKey *tsmstorage.FieldKey) {
+			schema, ok := measures[fieldKey.Measurement]
+			if !ok {
+				schema = &measureSchema{tags: make(map[string]bool), fields: make(map[string]map[string]bool)}
+				measures[fieldKey.Measurement] = schema
+			}
+
+			for tag := range fieldKey.Tags {
+				schema.tags[tag] = true
+			}
+
+			if schema.fields[fieldKey.Field] == nil {
+				schema.fields[fieldKey.Field] = make(map[string]bool)
+			}
+
+			schema.fields[fieldKey.Field][influxFieldTypes[fieldKey.Type]] = true
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not read the keys of shard %d\n%v", shard.ID, err)
+		}
+	}
+
+	e.scanned[key] = measures
+	return measures, nil
+}
@@ -0,0 +1,63 @@
+// Package tsm infers the schema of the measurements stored in the TSM shards and WAL of
+// InfluxDB 1.x, or in a portable backup, without a running InfluxDB server
+package tsm
+
+import (
+	"fmt"
+
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+	"github.com/timescale/outflux/internal/schemamanagement/tsm/tsmstorage"
+)
+
+const timeColumn = "time"
+
+// SchemaManager implements the schemamanagement.SchemaManager interface for TSM shards
+type SchemaManager struct {
+	explorer                    Explorer
+	storage                     *tsmstorage.Storage
+	db                          string
+	rp                          string
+	onConflictConvertIntToFloat bool
+}
+
+// NewSchemaManager creates new schema manager that can infer the data sets of a database stored in TSM shards
+func NewSchemaManager(storage *tsmstorage.Storage, db, rp string, onConflictConvertIntToFloat bool, explorer Explorer) *SchemaManager {
+	return &SchemaManager{
+		explorer:                    explorer,
+		storage:                     storage,
+		db:                          db,
+		rp:                          rp,
+		onConflictConvertIntToFloat: onConflictConvertIntToFloat,
+	}
+}
+
+// DiscoverDataSets returns a list of the measurements in the shards
+func (sm *SchemaManager) DiscoverDataSets() ([]string, error) {
+	return sm.explorer.DiscoverMeasurements(sm.storage, sm.db, sm.rp)
+}
+
+// FetchDataSet returns the idrf.DataSet describing a measurement.
+// The time column is followed by the tags and then the fields
+func (sm *SchemaManager) FetchDataSet(measure string) (*idrf.DataSet, error) {
+	tags, err := sm.explorer.DiscoverMeasurementTags(sm.storage, sm.db, sm.rp, measure)
+	if err != nil {
+		return nil, fmt.Errorf("could not discover the tags of measurement '%s'\n%v", measure, err)
+	}
+
+	fields, err := sm.explorer.DiscoverMeasurementFields(sm.storage, sm.db, sm.rp, measure, sm.onConflictConvertIntToFloat)
+	if err != nil {
+		return nil, fmt.Errorf("could not discover the fields of measure '%s'\n%v", measure, err)
+	}
+
+	idrfTimeColumn, _ := idrf.NewColumn(timeColumn, idrf.IDRFTimestamptz)
+	allColumns := []*idrf.Column{idrfTimeColumn}
+	allColumns = append(allColumns, tags...)
+	allColumns = append(allColumns, fields...)
+	return idrf.NewDataSet(measure, allColumns, timeColumn)
+}
+
+// PrepareDataSet NOT IMPLEMENTED
+func (sm *SchemaManager) PrepareDataSet(dataSet *idrf.DataSet, strategy schemaconfig.SchemaStrategy) error {
+	panic("not implemented")
+}
@@ -0,0 +1,106 @@
+package tsm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/influxdata/influxdb/tsdb/engine/tsm1"
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/tsm/tsmstorage"
+)
+
+// writeShard writes a TSM file with one value for each key, keys must be sorted
+func writeShard(t *testing.T, dir string, values map[string]interface{}, keys ...string) {
+	os.MkdirAll(dir, 0700)
+	file, err := os.Create(filepath.Join(dir, "000000001-000000001.tsm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer, _ := tsm1.NewTSMWriter(file)
+	for _, key := range keys {
+		if err = writer.Write([]byte(key), []tsm1.Value{tsm1.NewValue(1, values[key])}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writer.WriteIndex()
+	writer.Close()
+}
+
+func openStorage(t *testing.T) (*tsmstorage.Storage, func()) {
+	dir, err := ioutil.TempDir("", "outflux_tsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeShard(t, filepath.Join(dir, "db", "autogen", "1"), map[string]interface{}{
+		"cpu,host=a#!~#usage":   int64(1),
+		"cpu,region=eu#!~#up":   true,
+		"cpu,region=eu#!~#used": uint64(1),
+		"mem#!~#used":           int64(1),
+	}, "cpu,host=a#!~#usage", "cpu,region=eu#!~#up", "cpu,region=eu#!~#used", "mem#!~#used")
+	writeShard(t, filepath.Join(dir, "db", "autogen", "2"), map[string]interface{}{
+		"cpu,host=b#!~#usage": 1.5,
+	}, "cpu,host=b#!~#usage")
+	writeShard(t, filepath.Join(dir, "db", "other", "3"), map[string]interface{}{
+		"disk#!~#free": "full",
+	}, "disk#!~#free")
+	storage, err := tsmstorage.Open(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return storage, func() { os.RemoveAll(dir) }
+}
+
+func TestDiscoverDataSets(t *testing.T) {
+	storage, cleanUp := openStorage(t)
+	defer cleanUp()
+
+	sm := NewSchemaManager(storage, "db", "autogen", false, NewExplorer())
+	measures, err := sm.DiscoverDataSets()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cpu", "mem"}, measures)
+
+	sm = NewSchemaManager(storage, "db", "other", false, NewExplorer())
+	measures, err = sm.DiscoverDataSets()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"disk"}, measures)
+
+	sm = NewSchemaManager(storage, "missing", "autogen", false, NewExplorer())
+	measures, err = sm.DiscoverDataSets()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, measures)
+}
+
+func TestFetchDataSet(t *testing.T) {
+	storage, cleanUp := openStorage(t)
+	defer cleanUp()
+
+	explorer := NewExplorer()
+	sm := NewSchemaManager(storage, "db", "autogen", false, explorer)
+	_, err := sm.FetchDataSet("cpu")
+	assert.Error(t, err, "usage is an integer in one shard and a float in the other")
+	_, err = sm.FetchDataSet("disk")
+	assert.Error(t, err, "disk is not in the retention policy")
+
+	sm = NewSchemaManager(storage, "db", "autogen", true, explorer)
+	dataSet, err := sm.FetchDataSet("cpu")
+	assert.NoError(t, err)
+	assert.Equal(t, &idrf.DataSet{
+		DataSetName: "cpu",
+		Columns: []*idrf.Column{
+			{Name: "time", DataType: idrf.IDRFTimestamptz},
+			{Name: "host", DataType: idrf.IDRFString},
+			{Name: "region", DataType: idrf.IDRFString},
+			{Name: "up", DataType: idrf.IDRFBoolean},
+			{Name: "usage", DataType: idrf.IDRFDouble},
+			{Name: "used", DataType: idrf.IDRFInteger64},
+		},
+		TimeColumn: "time",
+	}, dataSet)
+}
@@ -0,0 +1,294 @@
+package tsmstorage
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/tsdb/engine/tsm1"
+)
+
+// Types of the values of a field, as stored in the blocks of a TSM file
+const (
+	FloatType    = tsm1.BlockFloat64
+	IntegerType  = tsm1.BlockInteger
+	BooleanType  = tsm1.BlockBoolean
+	StringType   = tsm1.BlockString
+	UnsignedType = tsm1.BlockUnsigned
+)
+
+// FieldKey is a field of a series, with the type of its values
+type FieldKey struct {
+	Measurement string
+	Tags        map[string]string
+	Field       string
+	Type        byte
+}
+
+// Series holds the values of each field of a series in a shard, by their time in nanoseconds
+type Series struct {
+	Tags   map[string]string
+	Fields map[string]map[int64]interface{}
+}
+
+// Times returns the sorted times at which at least one field of the series has a value
+func (s *Series) Times() []int64 {
+	unique := make(map[int64]bool)
+	for _, values := range s.Fields {
+		for t := range values {
+			unique[t] = true
+		}
+	}
+
+	times := make([]int64, 0, len(unique))
+	for t := range unique {
+		times = append(times, t)
+	}
+
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	return times
+}
+
+// shardReader decodes the TSM files of a shard, and replays its WAL like InfluxDB does
+// when it loads the cache of a shard
+type shardReader struct {
+	tsm []*tsm1.TSMReader
+	// values written to the WAL, by composite key (series key and field) and time
+	wal map[string]map[int64]tsm1.Value
+}
+
+// ReadKeys calls fn for the key of each field of each series in the shard
+func (s *Shard) ReadKeys(fn func(key *FieldKey)) error {
+	reader, err := openShard(s, nil)
+	if err != nil {
+		return err
+	}
+
+	defer reader.close()
+	for _, tsmReader := range reader.tsm {
+		for i := 0; i < tsmReader.KeyCount(); i++ {
+			key, blockType := tsmReader.KeyAt(i)
+			fn(newFieldKey(key, blockType))
+		}
+	}
+
+	for key, values := range reader.wal {
+		for _, value := range values {
+			fn(newFieldKey([]byte(key), valueType(value)))
+			break
+		}
+	}
+
+	return nil
+}
+
+// ReadMeasurement calls fn for each series of the measurement in the shard, ordered by series key.
+// Values in newer TSM files replace the values at the same time in older ones, the WAL is the newest
+func (s *Shard) ReadMeasurement(measurement string, fn func(series *Series) error) error {
+	prefix := models.EscapeMeasurement([]byte(measurement))
+	reader, err := openShard(s, prefix)
+	if err != nil {
+		return err
+	}
+
+	defer reader.close()
+	fieldsBySeries := make(map[string]map[string]struct{})
+	for _, tsmReader := range reader.tsm {
+		for i := tsmReader.Seek(prefix); i < tsmReader.KeyCount(); i++ {
+			key, _ := tsmReader.KeyAt(i)
+			if !bytes.HasPrefix(key, prefix) {
+				break
+			}
+
+			addKey(fieldsBySeries, prefix, key)
+		}
+	}
+
+	for key := range reader.wal {
+		addKey(fieldsBySeries, prefix, []byte(key))
+	}
+
+	seriesKeys := make([]string, 0, len(fieldsBySeries))
+	for seriesKey := range fieldsBySeries {
+		seriesKeys = append(seriesKeys, seriesKey)
+	}
+
+	sort.Strings(seriesKeys)
+	for _, seriesKey := range seriesKeys {
+		series, err := reader.readSeries(seriesKey, fieldsBySeries[seriesKey])
+		if err != nil {
+			return fmt.Errorf("could not read series '%s' of shard %d\n%v", seriesKey, s.ID, err)
+		}
+
+		if err = fn(series); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// openShard opens the TSM files of the shard and replays the WAL entries of the keys
+// starting with prefix, all keys if prefix is nil
+func openShard(shard *Shard, prefix []byte) (*shardReader, error) {
+	reader := &shardReader{wal: make(map[string]map[int64]tsm1.Value)}
+	for _, path := range shard.TSMFiles {
+		file, err := os.Open(path)
+		if err != nil {
+			reader.close()
+			return nil, fmt.Errorf("could not open TSM file\n%v", err)
+		}
+
+		tsmReader, err := tsm1.NewTSMReader(file)
+		if err != nil {
+			file.Close()
+			reader.close()
+			return nil, fmt.Errorf("could not read TSM file '%s'\n%v", path, err)
+		}
+
+		reader.tsm = append(reader.tsm, tsmReader)
+	}
+
+	for _, path := range shard.WALFiles {
+		if err := reader.replayWAL(path, prefix); err != nil {
+			reader.close()
+			return nil, err
+		}
+	}
+
+	return reader, nil
+}
+
+func (r *shardReader) replayWAL(path string, prefix []byte) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("could not open WAL segment\n%v", err)
+	}
+
+	segment := tsm1.NewWALSegmentReader(file)
+	defer segment.Close()
+	for segment.Next() {
+		entry, err := segment.Read()
+		if err != nil {
+			// InfluxDB truncates a segment at the first corrupt entry, the rest was never acknowledged
+			log.Printf("WAL segment '%s' is corrupt after %d bytes, skipping the rest of it: %v", path, segment.Count(), err)
+			return nil
+		}
+
+		switch typed := entry.(type) {
+		case *tsm1.WriteWALEntry:
+			for key, values := range typed.Values {
+				if prefix != nil && !bytes.HasPrefix([]byte(key), prefix) {
+					continue
+				}
+
+				if r.wal[key] == nil {
+					r.wal[key] = make(map[int64]tsm1.Value)
+				}
+
+				for _, value := range values {
+					r.wal[key][value.UnixNano()] = value
+				}
+			}
+		case *tsm1.DeleteWALEntry:
+			for _, key := range typed.Keys {
+				delete(r.wal, string(key))
+			}
+		case *tsm1.DeleteRangeWALEntry:
+			for _, key := range typed.Keys {
+				for t := range r.wal[string(key)] {
+					if t >= typed.Min && t <= typed.Max {
+						delete(r.wal[string(key)], t)
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// readSeries merges the values of the fields of a series from the TSM files and the WAL
+func (r *shardReader) readSeries(seriesKey string, fields map[string]struct{}) (*Series, error) {
+	_, tags := models.ParseKeyBytes([]byte(seriesKey))
+	series := &Series{Tags: tags.Map(), Fields: make(map[string]map[int64]interface{})}
+	for field := range fields {
+		values := make(map[int64]interface{})
+		key := tsm1.SeriesFieldKeyBytes(seriesKey, field)
+		for _, tsmReader := range r.tsm {
+			if !tsmReader.Contains(key) {
+				continue
+			}
+
+			blockValues, err := tsmReader.ReadAll(key)
+			if err != nil {
+				return nil, fmt.Errorf("could not decode the values of field '%s'\n%v", field, err)
+			}
+
+			for _, value := range blockValues {
+				values[value.UnixNano()] = value.Value()
+			}
+		}
+
+		for t, value := range r.wal[string(key)] {
+			values[t] = value.Value()
+		}
+
+		series.Fields[field] = values
+	}
+
+	return series, nil
+}
+
+func (r *shardReader) close() {
+	for _, tsmReader := range r.tsm {
+		tsmReader.Close()
+	}
+}
+
+// addKey adds the field of a composite key to its series, if the series belongs to the
+// measurement. The escaped measurement name is followed by the tags or the field separator.
+// A key found in several TSM files and the WAL adds its field once
+func addKey(fieldsBySeries map[string]map[string]struct{}, measurement, key []byte) {
+	seriesKey, field := tsm1.SeriesAndFieldFromCompositeKey(key)
+	if len(seriesKey) != len(measurement) && seriesKey[len(measurement)] != ',' {
+		return
+	}
+
+	fields, ok := fieldsBySeries[string(seriesKey)]
+	if !ok {
+		fields = make(map[string]struct{})
+		fieldsBySeries[string(seriesKey)] = fields
+	}
+
+	fields[string(field)] = struct{}{}
+}
+
+func newFieldKey(key []byte, fieldType byte) *FieldKey {
+	seriesKey, field := tsm1.SeriesAndFieldFromCompositeKey(key)
+	measurement, tags := models.ParseKeyBytes(seriesKey)
+	return &FieldKey{
+		Measurement: string(measurement),
+		Tags:        tags.Map(),
+		Field:       string(field),
+		Type:        fieldType,
+	}
+}
+
+func valueType(value tsm1.Value) byte {
+	switch value.(type) {
+	case tsm1.FloatValue:
+		return FloatType
+	case tsm1.IntegerValue:
+		return IntegerType
+	case tsm1.BooleanValue:
+		return BooleanType
+	case tsm1.UnsignedValue:
+		return UnsignedType
+	default:
+		return StringType
+	}
+}
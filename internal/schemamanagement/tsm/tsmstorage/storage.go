@@ -0,0 +1,269 @@
+// Package tsmstorage reads the shards of an InfluxDB 1.x data directory, with its WAL,
+// or of a backup created with 'influxd backup -portable', without a running server
+package tsmstorage
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	tsmExtension       = ".tsm"
+	tombstoneExtension = ".tombstone"
+	walPattern         = "_*.wal"
+	manifestPattern    = "*.manifest"
+)
+
+// Shard holds the files of a shard. The TSM files are sorted from the oldest to the
+// newest generation and the WAL segments in the order they were written
+type Shard struct {
+	ID              uint64
+	Database        string
+	RetentionPolicy string
+	TSMFiles        []string
+	WALFiles        []string
+}
+
+// Storage holds the shards of a data directory or a portable backup
+type Storage struct {
+	shards  []*Shard
+	tempDir string
+}
+
+// manifest is the part of the manifest of a portable backup that lists the archived shards
+type manifest struct {
+	Files []struct {
+		Database string `json:"database"`
+		Policy   string `json:"policy"`
+		ShardID  uint64 `json:"shardID"`
+		FileName string `json:"fileName"`
+	} `json:"files"`
+}
+
+// Open finds the shards in dir. If dir contains the manifest of a portable backup, the TSM
+// files of the archived shards are extracted to a temporary directory removed on Close.
+// Otherwise dir is the data directory of InfluxDB, and walDir (optional) its WAL directory
+func Open(dir, walDir string) (*Storage, error) {
+	manifests, err := filepath.Glob(filepath.Join(dir, manifestPattern))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(manifests) == 0 {
+		shards, err := findShards(dir, walDir)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Storage{shards: shards}, nil
+	}
+
+	if walDir != "" {
+		return nil, fmt.Errorf("a WAL directory can't be used with the portable backup in '%s'", dir)
+	}
+
+	tempDir, err := ioutil.TempDir("", "outflux_backup")
+	if err != nil {
+		return nil, fmt.Errorf("could not create directory for the shards of the backup\n%v", err)
+	}
+
+	storage := &Storage{tempDir: tempDir}
+	if storage.shards, err = extractBackup(dir, manifests, tempDir); err != nil {
+		storage.Close()
+		return nil, err
+	}
+
+	return storage, nil
+}
+
+// Shards returns the shards of a database and retention policy, ordered by ID
+func (s *Storage) Shards(db, rp string) []*Shard {
+	shards := []*Shard{}
+	for _, shard := range s.shards {
+		if shard.Database == db && shard.RetentionPolicy == rp {
+			shards = append(shards, shard)
+		}
+	}
+
+	return shards
+}
+
+// Close removes the shards extracted from a backup. Closing a nil storage does nothing
+func (s *Storage) Close() error {
+	if s == nil || s.tempDir == "" {
+		return nil
+	}
+
+	return os.RemoveAll(s.tempDir)
+}
+
+// findShards lists the TSM files in dataDir/<db>/<rp>/<shard id>/ and the WAL segments in walDir/<db>/<rp>/<shard id>/
+func findShards(dataDir, walDir string) ([]*Shard, error) {
+	shards := make(map[string]*Shard)
+	tsmFiles, err := filepath.Glob(filepath.Join(dataDir, "*", "*", "*", "*"+tsmExtension))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range tsmFiles {
+		if shard := shardOf(shards, file); shard != nil {
+			shard.TSMFiles = append(shard.TSMFiles, file)
+		}
+	}
+
+	if walDir != "" {
+		walFiles, err := filepath.Glob(filepath.Join(walDir, "*", "*", "*", walPattern))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range walFiles {
+			if shard := shardOf(shards, file); shard != nil {
+				shard.WALFiles = append(shard.WALFiles, file)
+			}
+		}
+	}
+
+	if len(shards) == 0 {
+		return nil, fmt.Errorf("no shards found in '%s'", dataDir)
+	}
+
+	return sortShards(shards), nil
+}
+
+// shardOf returns the shard of a file in <db>/<rp>/<shard id>/, nil if the directory is not a shard
+func shardOf(shards map[string]*Shard, file string) *Shard {
+	shardDir := filepath.Dir(file)
+	id, err := strconv.ParseUint(filepath.Base(shardDir), 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	rpDir := filepath.Dir(shardDir)
+	db := filepath.Base(filepath.Dir(rpDir))
+	rp := filepath.Base(rpDir)
+	key := filepath.Join(db, rp, filepath.Base(shardDir))
+	shard, ok := shards[key]
+	if !ok {
+		shard = &Shard{ID: id, Database: db, RetentionPolicy: rp}
+		shards[key] = shard
+	}
+
+	return shard
+}
+
+func sortShards(shards map[string]*Shard) []*Shard {
+	sorted := make([]*Shard, 0, len(shards))
+	for _, shard := range shards {
+		// the names of TSM files and WAL segments increase with their generation
+		sort.Strings(shard.TSMFiles)
+		sort.Strings(shard.WALFiles)
+		sorted = append(sorted, shard)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	return sorted
+}
+
+// extractBackup extracts the TSM files and tombstones of the shards listed in the manifests.
+// Manifests are named after the time of the backup, a shard in a later backup replaces the earlier one
+func extractBackup(dir string, manifests []string, tempDir string) ([]*Shard, error) {
+	sort.Strings(manifests)
+	archives := make(map[string]string)
+	for _, manifestFile := range manifests {
+		content, err := ioutil.ReadFile(manifestFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read backup manifest\n%v", err)
+		}
+
+		parsed := &manifest{}
+		if err = json.Unmarshal(content, parsed); err != nil {
+			return nil, fmt.Errorf("could not parse backup manifest '%s'\n%v", manifestFile, err)
+		}
+
+		for _, file := range parsed.Files {
+			shardDir := filepath.Join(tempDir, file.Database, file.Policy, strconv.FormatUint(file.ShardID, 10))
+			archives[shardDir] = filepath.Join(dir, file.FileName)
+		}
+	}
+
+	shards := make(map[string]*Shard)
+	for shardDir, archive := range archives {
+		if err := extractShard(archive, shardDir); err != nil {
+			return nil, fmt.Errorf("could not extract shard archive '%s'\n%v", archive, err)
+		}
+
+		files, err := filepath.Glob(filepath.Join(shardDir, "*"+tsmExtension))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			shard := shardOf(shards, file)
+			shard.TSMFiles = append(shard.TSMFiles, file)
+		}
+	}
+
+	return sortShards(shards), nil
+}
+
+// extractShard extracts the TSM files and tombstones of a gzipped shard archive to shardDir
+func extractShard(archive, shardDir string) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+	decompressed, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(shardDir, 0700); err != nil {
+		return err
+	}
+
+	entries := tar.NewReader(decompressed)
+	for {
+		header, err := entries.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		name := filepath.Base(header.Name)
+		if header.Typeflag != tar.TypeReg ||
+			!(strings.HasSuffix(name, tsmExtension) || strings.HasSuffix(name, tombstoneExtension)) {
+			continue
+		}
+
+		if err = extractFile(entries, filepath.Join(shardDir, name)); err != nil {
+			return err
+		}
+	}
+}
+
+func extractFile(content io.Reader, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(file, content); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,218 @@
+package tsmstorage
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/influxdata/influxdb/tsdb/engine/tsm1"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTSM(t *testing.T, path string, values map[string][]tsm1.Value, keys ...string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer, err := tsm1.NewTSMWriter(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// keys must be written in order
+	for _, key := range keys {
+		if err = writer.Write([]byte(key), values[key]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = writer.WriteIndex(); err != nil {
+		t.Fatal(err)
+	}
+
+	writer.Close()
+}
+
+func writeWAL(t *testing.T, dir string, values map[string][]tsm1.Value, deleted string) {
+	wal := tsm1.NewWAL(dir)
+	if err := wal.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	defer wal.Close()
+	if _, err := wal.WriteMulti(values); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := wal.Delete([][]byte{[]byte(deleted)}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// createDataDir creates the data and WAL directories of a database 'db' with two shards in
+// the 'autogen' retention policy and one in 'other'
+func createDataDir(t *testing.T) (string, string) {
+	root, err := ioutil.TempDir("", "outflux_tsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dataDir := filepath.Join(root, "data")
+	walDir := filepath.Join(root, "wal")
+	writeTSM(t, filepath.Join(dataDir, "db", "autogen", "2", "000000001-000000001.tsm"), map[string][]tsm1.Value{
+		"cpu#!~#usage":        {tsm1.NewValue(1, 1.0), tsm1.NewValue(2, 2.0)},
+		"cpu,host=a#!~#up":    {tsm1.NewValue(1, true)},
+		"cpu,host=a#!~#usage": {tsm1.NewValue(1, 3.0)},
+		"cpu_load#!~#value":   {tsm1.NewValue(1, int64(4))},
+	}, "cpu#!~#usage", "cpu,host=a#!~#up", "cpu,host=a#!~#usage", "cpu_load#!~#value")
+	writeTSM(t, filepath.Join(dataDir, "db", "autogen", "2", "000000002-000000001.tsm"), map[string][]tsm1.Value{
+		"cpu#!~#usage": {tsm1.NewValue(2, 5.0)},
+	}, "cpu#!~#usage")
+	writeTSM(t, filepath.Join(dataDir, "db", "autogen", "1", "000000001-000000001.tsm"), map[string][]tsm1.Value{
+		"cpu#!~#usage": {tsm1.NewValue(0, int64(6))},
+	}, "cpu#!~#usage")
+	writeTSM(t, filepath.Join(dataDir, "db", "other", "3", "000000001-000000001.tsm"), map[string][]tsm1.Value{
+		"disk#!~#free": {tsm1.NewValue(0, "full")},
+	}, "disk#!~#free")
+	writeWAL(t, filepath.Join(walDir, "db", "autogen", "2"), map[string][]tsm1.Value{
+		"cpu,host=b#!~#usage": {tsm1.NewValue(3, uint64(7))},
+		"cpu#!~#usage":        {tsm1.NewValue(2, 8.0)},
+		"mem#!~#used":         {tsm1.NewValue(3, int64(9))},
+	}, "mem#!~#used")
+	return dataDir, walDir
+}
+
+func readAll(t *testing.T, shard *Shard, measurement string) map[string]map[string]map[int64]interface{} {
+	read := make(map[string]map[string]map[int64]interface{})
+	err := shard.ReadMeasurement(measurement, func(series *Series) error {
+		read[series.Tags["host"]] = series.Fields
+		return nil
+	})
+	assert.NoError(t, err)
+	return read
+}
+
+func TestOpenDataDir(t *testing.T) {
+	dataDir, walDir := createDataDir(t)
+	defer os.RemoveAll(filepath.Dir(dataDir))
+
+	storage, err := Open(dataDir, walDir)
+	assert.NoError(t, err)
+	defer storage.Close()
+	shards := storage.Shards("db", "autogen")
+	assert.Equal(t, 2, len(shards))
+	assert.Equal(t, uint64(1), shards[0].ID)
+	assert.Equal(t, uint64(2), shards[1].ID)
+	assert.Equal(t, 2, len(shards[1].TSMFiles))
+	assert.Equal(t, 1, len(shards[1].WALFiles))
+	assert.Equal(t, 1, len(storage.Shards("db", "other")))
+	assert.Equal(t, 0, len(storage.Shards("missing", "autogen")))
+
+	_, err = Open(walDir, "")
+	assert.Error(t, err, "no TSM files in the WAL directory")
+}
+
+func TestReadKeys(t *testing.T) {
+	dataDir, walDir := createDataDir(t)
+	defer os.RemoveAll(filepath.Dir(dataDir))
+
+	storage, _ := Open(dataDir, walDir)
+	defer storage.Close()
+	keys := make(map[string]byte)
+	err := storage.Shards("db", "autogen")[1].ReadKeys(func(key *FieldKey) {
+		keys[key.Measurement+"."+key.Tags["host"]+"."+key.Field] = key.Type
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]byte{
+		"cpu..usage":      FloatType,
+		"cpu.a.up":        BooleanType,
+		"cpu.a.usage":     FloatType,
+		"cpu.b.usage":     UnsignedType,
+		"cpu_load..value": IntegerType,
+	}, keys, "the deleted mem series is not listed")
+}
+
+func TestReadMeasurement(t *testing.T) {
+	dataDir, walDir := createDataDir(t)
+	defer os.RemoveAll(filepath.Dir(dataDir))
+
+	storage, _ := Open(dataDir, walDir)
+	defer storage.Close()
+	shards := storage.Shards("db", "autogen")
+	assert.Equal(t, map[string]map[string]map[int64]interface{}{
+		"": {"usage": {1: 1.0, 2: 8.0}},
+		"a": {
+			"up":    {1: true},
+			"usage": {1: 3.0},
+		},
+		"b": {"usage": {3: uint64(7)}},
+	}, readAll(t, shards[1], "cpu"), "newer files and the WAL replace older values, cpu_load is another measurement")
+	assert.Equal(t, map[string]map[string]map[int64]interface{}{
+		"": {"usage": {0: int64(6)}},
+	}, readAll(t, shards[0], "cpu"))
+	assert.Equal(t, 0, len(readAll(t, shards[1], "mem")))
+
+	series := &Series{Fields: map[string]map[int64]interface{}{"a": {3: 1, 1: 1}, "b": {2: 1, 1: 1}}}
+	assert.Equal(t, []int64{1, 2, 3}, series.Times())
+}
+
+func TestAddKey(t *testing.T) {
+	fieldsBySeries := make(map[string]map[string]struct{})
+	for _, key := range []string{"cpu,host=a#!~#usage", "cpu,host=a#!~#usage", "cpu,host=a#!~#up", "cpu#!~#usage", "cpu_load#!~#value"} {
+		addKey(fieldsBySeries, []byte("cpu"), []byte(key))
+	}
+
+	assert.Equal(t, map[string]map[string]struct{}{
+		"cpu,host=a": {"usage": {}, "up": {}},
+		"cpu":        {"usage": {}},
+	}, fieldsBySeries, "a key of several files is added once, cpu_load is another measurement")
+}
+
+func TestOpenPortableBackup(t *testing.T) {
+	dataDir, _ := createDataDir(t)
+	defer os.RemoveAll(filepath.Dir(dataDir))
+	backupDir := filepath.Join(filepath.Dir(dataDir), "backup")
+	os.Mkdir(backupDir, 0700)
+
+	archive, _ := os.Create(filepath.Join(backupDir, "20190101T000000Z.s2.tar.gz"))
+	compressed := gzip.NewWriter(archive)
+	entries := tar.NewWriter(compressed)
+	tsmFile := filepath.Join(dataDir, "db", "autogen", "2", "000000001-000000001.tsm")
+	content, _ := ioutil.ReadFile(tsmFile)
+	entries.WriteHeader(&tar.Header{Name: "db/autogen/2/000000001-000000001.tsm", Mode: 0600, Size: int64(len(content)), Typeflag: tar.TypeReg})
+	entries.Write(content)
+	entries.Close()
+	compressed.Close()
+	archive.Close()
+	manifest := `{"files":[{"database":"db","policy":"autogen","shardID":2,"fileName":"20190101T000000Z.s2.tar.gz"}]}`
+	ioutil.WriteFile(filepath.Join(backupDir, "20190101T000000Z.manifest"), []byte(manifest), 0600)
+
+	_, err := Open(backupDir, dataDir)
+	assert.Error(t, err, "a WAL directory can't be used with a backup")
+
+	storage, err := Open(backupDir, "")
+	assert.NoError(t, err)
+	shards := storage.Shards("db", "autogen")
+	assert.Equal(t, 1, len(shards))
+	assert.Equal(t, uint64(2), shards[0].ID)
+	assert.Equal(t, map[string]map[string]map[int64]interface{}{
+		"": {"usage": {1: 1.0, 2: 2.0}},
+		"a": {
+			"up":    {1: true},
+			"usage": {1: 3.0},
+		},
+	}, readAll(t, shards[0], "cpu"))
+
+	tempDir := storage.tempDir
+	assert.NoError(t, storage.Close())
+	_, err = os.Stat(tempDir)
+	assert.True(t, os.IsNotExist(err), "extracted shards are removed")
+}
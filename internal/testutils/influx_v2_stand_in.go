@@ -0,0 +1,38 @@
+package testutils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+// FluxResponse is the annotated CSV the InfluxDB v2 stand-in returns for queries containing a fragment
+type FluxResponse struct {
+	QueryContains string
+	CSV           string
+}
+
+// NewInfluxV2StandIn starts a server that answers Flux queries sent to /api/v2/query with the
+// first response whose fragment is contained in the query. Other queries are answered with an error
+func NewInfluxV2StandIn(responses []FluxResponse) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := struct {
+			Query string `json:"query"`
+		}{}
+		if r.URL.Path != "/api/v2/query" || json.NewDecoder(r.Body).Decode(&request) != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		for _, response := range responses {
+			if strings.Contains(request.Query, response.QueryContains) {
+				_, _ = w.Write([]byte(response.CSV))
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"code":"invalid","message":"unexpected query"}`))
+	}))
+}
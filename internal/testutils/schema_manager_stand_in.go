@@ -0,0 +1,28 @@
+package testutils
+
+import (
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+)
+
+// SchemaManagerStandIn is a schema manager that returns the same data set, or error, for any measure.
+// It lets the extractor tests describe the data set of the points they read
+type SchemaManagerStandIn struct {
+	DataSet *idrf.DataSet
+	Err     error
+}
+
+// DiscoverDataSets returns no data sets
+func (s *SchemaManagerStandIn) DiscoverDataSets() ([]string, error) {
+	return nil, nil
+}
+
+// FetchDataSet returns the data set or error of the stand-in
+func (s *SchemaManagerStandIn) FetchDataSet(string) (*idrf.DataSet, error) {
+	return s.DataSet, s.Err
+}
+
+// PrepareDataSet does nothing
+func (s *SchemaManagerStandIn) PrepareDataSet(*idrf.DataSet, schemaconfig.SchemaStrategy) error {
+	return nil
+}
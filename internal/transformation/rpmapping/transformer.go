@@ -0,0 +1,110 @@
+// Package rpmapping contains a transformer that keeps the data of a retention policy apart from the data
+// of the other retention policies, when the retention policies of a database are migrated to the same schema
+package rpmapping
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+	"github.com/timescale/outflux/internal/utils"
+)
+
+const (
+	// TablePrefixTemplate names the table of a measurement after the retention policy and the measurement
+	TablePrefixTemplate = "%s_%s"
+	// ColumnName is the name of the column holding the retention policy of the rows
+	ColumnName = "rp"
+)
+
+// Transformer names the data set after the retention policy with the RPToTablePrefix mapping,
+// or adds a column with the retention policy after the time column with the RPToColumn mapping
+type Transformer struct {
+	id                 string
+	mapping            schemaconfig.RetentionPolicyMapping
+	retentionPolicy    string
+	timeIndex          int
+	cachedInputBundle  *idrf.Bundle
+	cachedOutputBundle *idrf.Bundle
+}
+
+// NewTransformer returns a new instance of a transformer that maps the rows of a retention policy.
+// The other mappings don't change the data set, so no transformer is needed for them
+func NewTransformer(id string, mapping schemaconfig.RetentionPolicyMapping, retentionPolicy string) (*Transformer, error) {
+	if mapping != schemaconfig.RPToTablePrefix && mapping != schemaconfig.RPToColumn {
+		return nil, fmt.Errorf("%s: retention policy mapping %s doesn't require a transformation", id, mapping)
+	}
+
+	return &Transformer{id: id, mapping: mapping, retentionPolicy: retentionPolicy}, nil
+}
+
+// ID returns a string that identifies the transformer instance
+func (t *Transformer) ID() string {
+	return t.id
+}
+
+// Prepare creates the output channel and the transformed data set definition and returns them as a idrf.Bundle
+func (t *Transformer) Prepare(input *idrf.Bundle) (*idrf.Bundle, error) {
+	originDataSet := input.DataDef
+	dataSetName := originDataSet.DataSetName
+	newColumns := originDataSet.Columns
+	if t.mapping == schemaconfig.RPToTablePrefix {
+		dataSetName = fmt.Sprintf(TablePrefixTemplate, t.retentionPolicy, dataSetName)
+	} else {
+		if originDataSet.ColumnNamed(ColumnName) != nil {
+			return nil, fmt.Errorf("%s: column '%s' for the retention policy already exists", t.id, ColumnName)
+		}
+
+		newColumns = []*idrf.Column{}
+		for i, column := range originDataSet.Columns {
+			newColumns = append(newColumns, column)
+			if column.Name == originDataSet.TimeColumn {
+				t.timeIndex = i
+				newColumns = append(newColumns, &idrf.Column{Name: ColumnName, DataType: idrf.IDRFString})
+			}
+		}
+	}
+
+	newDataSet, err := idrf.NewDataSet(dataSetName, newColumns, originDataSet.TimeColumn)
+	if err != nil {
+		return nil, fmt.Errorf("%s: could not generate the transformed data set definition.\nProblem was:%v", t.id, err)
+	}
+
+	t.cachedInputBundle = input
+	t.cachedOutputBundle = &idrf.Bundle{
+		DataDef:  newDataSet,
+		DataChan: make(chan idrf.Row, cap(input.DataChan)),
+	}
+	return t.cachedOutputBundle, nil
+}
+
+// Start consumes the data channel sent as an argument in Prepare, adds the retention policy to
+// each row if needed and feeds the row to the channel returned in Prepare
+func (t *Transformer) Start(errChan chan error) error {
+	if t.cachedInputBundle == nil || t.cachedOutputBundle == nil {
+		return fmt.Errorf("%s: Prepare must be called before Start", t.id)
+	}
+
+	defer close(t.cachedOutputBundle.DataChan)
+	log.Printf("%s: starting transformation", t.id)
+	if err := utils.CheckError(errChan); err != nil {
+		log.Printf("%s: error received from outside, aborting:%v", t.id, err)
+		return nil
+	}
+
+	outputChannel := t.cachedOutputBundle.DataChan
+	for row := range t.cachedInputBundle.DataChan {
+		if t.mapping == schemaconfig.RPToTablePrefix {
+			outputChannel <- row
+			continue
+		}
+
+		newRow := make(idrf.Row, 0, len(row)+1)
+		newRow = append(newRow, row[:t.timeIndex+1]...)
+		newRow = append(newRow, t.retentionPolicy)
+		outputChannel <- append(newRow, row[t.timeIndex+1:]...)
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package rpmapping
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+)
+
+func inputBundle(columns []*idrf.Column, rows ...idrf.Row) *idrf.Bundle {
+	dataSet, _ := idrf.NewDataSet("cpu", columns, "time")
+	dataChan := make(chan idrf.Row, len(rows))
+	for _, row := range rows {
+		dataChan <- row
+	}
+
+	close(dataChan)
+	return &idrf.Bundle{DataDef: dataSet, DataChan: dataChan}
+}
+
+func transform(t *testing.T, mapping schemaconfig.RetentionPolicyMapping, input *idrf.Bundle) (*idrf.DataSet, []idrf.Row, error) {
+	transformer, err := NewTransformer("id", mapping, "one_month")
+	assert.NoError(t, err)
+	output, err := transformer.Prepare(input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows := []idrf.Row{}
+	done := make(chan error)
+	go func() { done <- transformer.Start(make(chan error, 1)) }()
+	for row := range output.DataChan {
+		rows = append(rows, row)
+	}
+
+	return output.DataDef, rows, <-done
+}
+
+func TestNewTransformer(t *testing.T) {
+	_, err := NewTransformer("id", schemaconfig.RPToSchema, "rp")
+	assert.Error(t, err)
+	_, err = NewTransformer("id", schemaconfig.NoRPMapping, "rp")
+	assert.Error(t, err)
+	_, err = NewTransformer("id", schemaconfig.RPToColumn, "rp")
+	assert.NoError(t, err)
+}
+
+func TestTablePrefix(t *testing.T) {
+	columns := []*idrf.Column{{Name: "time", DataType: idrf.IDRFTimestamptz}, {Name: "value", DataType: idrf.IDRFDouble}}
+	t1 := time.Unix(0, 0)
+	dataSet, rows, err := transform(t, schemaconfig.RPToTablePrefix, inputBundle(columns, idrf.Row{t1, 1.0}))
+	assert.NoError(t, err)
+	assert.Equal(t, "one_month_cpu", dataSet.DataSetName)
+	assert.Equal(t, columns, dataSet.Columns)
+	assert.Equal(t, []idrf.Row{{t1, 1.0}}, rows)
+}
+
+func TestColumn(t *testing.T) {
+	columns := []*idrf.Column{
+		{Name: "host", DataType: idrf.IDRFString},
+		{Name: "time", DataType: idrf.IDRFTimestamptz},
+		{Name: "value", DataType: idrf.IDRFDouble},
+	}
+	t1 := time.Unix(0, 0)
+	dataSet, rows, err := transform(t, schemaconfig.RPToColumn, inputBundle(columns, idrf.Row{"a", t1, 1.0}))
+	assert.NoError(t, err)
+	assert.Equal(t, "cpu", dataSet.DataSetName)
+	assert.Equal(t, []string{"host", "time", "rp", "value"}, columnNames(dataSet))
+	assert.Equal(t, idrf.IDRFString, dataSet.ColumnNamed("rp").DataType)
+	assert.Equal(t, []idrf.Row{{"a", t1, "one_month", 1.0}}, rows)
+
+	columns = append(columns, &idrf.Column{Name: "rp", DataType: idrf.IDRFString})
+	_, _, err = transform(t, schemaconfig.RPToColumn, inputBundle(columns))
+	assert.Error(t, err)
+}
+
+func columnNames(dataSet *idrf.DataSet) []string {
+	names := make([]string, len(dataSet.Columns))
+	for i, column := range dataSet.Columns {
+		names[i] = column.Name
+	}
+
+	return names
+}
@@ -0,0 +1,144 @@
+// Package timeformat contains a transformer that changes how the time of the rows is represented,
+// so no precision is lost when the rows are stored with the microsecond resolution of TIMESTAMPTZ
+package timeformat
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+	"github.com/timescale/outflux/internal/utils"
+)
+
+const (
+	// NanosColumnTemplate names the column holding the nanoseconds truncated from the time column
+	NanosColumnTemplate = "%s_ns"
+	nanosInMicrosecond  = 1000
+)
+
+var (
+	minEpochTime = time.Unix(0, math.MinInt64)
+	maxEpochTime = time.Unix(0, math.MaxInt64)
+)
+
+// Transformer converts the time column of the rows to the selected time format. With
+// TimestamptzWithNanos a column with the sub-microsecond part of the time is added after
+// the time column, with EpochNanos the time column becomes an Integer64
+type Transformer struct {
+	id                 string
+	format             schemaconfig.TimeFormat
+	timeIndex          int
+	cachedInputBundle  *idrf.Bundle
+	cachedOutputBundle *idrf.Bundle
+}
+
+// NewTransformer returns a new instance of a transformer that converts the time column to the format.
+// The Timestamptz format keeps the time as it is, so no transformer is needed for it
+func NewTransformer(id string, format schemaconfig.TimeFormat) (*Transformer, error) {
+	if format != schemaconfig.TimestamptzWithNanos && format != schemaconfig.EpochNanos {
+		return nil, fmt.Errorf("%s: time format %s doesn't require a transformation", id, format)
+	}
+
+	return &Transformer{id: id, format: format}, nil
+}
+
+// ID returns a string that identifies the transformer instance
+func (t *Transformer) ID() string {
+	return t.id
+}
+
+// Prepare verifies that the time column can be converted, creates the output channel
+// and the transformed data set definition and returns them as a idrf.Bundle
+func (t *Transformer) Prepare(input *idrf.Bundle) (*idrf.Bundle, error) {
+	originDataSet := input.DataDef
+	timeColumn := originDataSet.ColumnNamed(originDataSet.TimeColumn)
+	if timeColumn == nil || timeColumn.DataType != idrf.IDRFTimestamptz && timeColumn.DataType != idrf.IDRFTimestamp {
+		return nil, fmt.Errorf("%s: time column '%s' is not a timestamp", t.id, originDataSet.TimeColumn)
+	}
+
+	newColumns := []*idrf.Column{}
+	for i, column := range originDataSet.Columns {
+		if column != timeColumn {
+			newColumns = append(newColumns, column)
+			continue
+		}
+
+		t.timeIndex = i
+		if t.format == schemaconfig.EpochNanos {
+			newColumns = append(newColumns, &idrf.Column{Name: column.Name, DataType: idrf.IDRFInteger64})
+			continue
+		}
+
+		nanosColumn := fmt.Sprintf(NanosColumnTemplate, column.Name)
+		if originDataSet.ColumnNamed(nanosColumn) != nil {
+			return nil, fmt.Errorf("%s: column '%s' for the nanoseconds of the time already exists", t.id, nanosColumn)
+		}
+
+		newColumns = append(newColumns, column, &idrf.Column{Name: nanosColumn, DataType: idrf.IDRFInteger32})
+	}
+
+	newDataSet, err := idrf.NewDataSet(originDataSet.DataSetName, newColumns, originDataSet.TimeColumn)
+	if err != nil {
+		return nil, fmt.Errorf("%s: could not generate the transformed data set definition.\nProblem was:%v", t.id, err)
+	}
+
+	t.cachedInputBundle = input
+	t.cachedOutputBundle = &idrf.Bundle{
+		DataDef:  newDataSet,
+		DataChan: make(chan idrf.Row, cap(input.DataChan)),
+	}
+	return t.cachedOutputBundle, nil
+}
+
+// Start consumes the data channel sent as an argument in Prepare, converts the time of each
+// row and feeds the transformed row to the channel returned in Prepare
+func (t *Transformer) Start(errChan chan error) error {
+	if t.cachedInputBundle == nil || t.cachedOutputBundle == nil {
+		return fmt.Errorf("%s: Prepare must be called before Start", t.id)
+	}
+
+	defer close(t.cachedOutputBundle.DataChan)
+	log.Printf("%s: starting transformation", t.id)
+	if err := utils.CheckError(errChan); err != nil {
+		log.Printf("%s: error received from outside, aborting:%v", t.id, err)
+		return nil
+	}
+
+	outputChannel := t.cachedOutputBundle.DataChan
+	for row := range t.cachedInputBundle.DataChan {
+		transformed, err := t.transformRow(row)
+		if err != nil {
+			return err
+		}
+
+		outputChannel <- transformed
+	}
+
+	return nil
+}
+
+func (t *Transformer) transformRow(row idrf.Row) (idrf.Row, error) {
+	rowTime, ok := row[t.timeIndex].(time.Time)
+	if !ok {
+		return nil, fmt.Errorf("%s: value %v of the time column is not a time", t.id, row[t.timeIndex])
+	}
+
+	if t.format == schemaconfig.EpochNanos {
+		if rowTime.Before(minEpochTime) || rowTime.After(maxEpochTime) {
+			return nil, fmt.Errorf("%s: time %s can't be represented in nanoseconds since the Unix epoch", t.id, rowTime.Format(time.RFC3339Nano))
+		}
+
+		newRow := make(idrf.Row, len(row))
+		copy(newRow, row)
+		newRow[t.timeIndex] = rowTime.UnixNano()
+		return newRow, nil
+	}
+
+	newRow := make(idrf.Row, 0, len(row)+1)
+	newRow = append(newRow, row[:t.timeIndex+1]...)
+	newRow = append(newRow, int32(rowTime.Nanosecond()%nanosInMicrosecond))
+	return append(newRow, row[t.timeIndex+1:]...), nil
+}
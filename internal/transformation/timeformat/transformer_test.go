@@ -0,0 +1,93 @@
+package timeformat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/outflux/internal/idrf"
+	"github.com/timescale/outflux/internal/schemamanagement/schemaconfig"
+)
+
+func inputBundle(rows ...idrf.Row) *idrf.Bundle {
+	columns := []*idrf.Column{
+		{Name: "host", DataType: idrf.IDRFString},
+		{Name: "time", DataType: idrf.IDRFTimestamptz},
+		{Name: "value", DataType: idrf.IDRFDouble},
+	}
+	dataSet, _ := idrf.NewDataSet("ds", columns, "time")
+	dataChan := make(chan idrf.Row, len(rows))
+	for _, row := range rows {
+		dataChan <- row
+	}
+
+	close(dataChan)
+	return &idrf.Bundle{DataDef: dataSet, DataChan: dataChan}
+}
+
+func transform(t *testing.T, format schemaconfig.TimeFormat, input *idrf.Bundle) (*idrf.DataSet, []idrf.Row, error) {
+	transformer, err := NewTransformer("id", format)
+	assert.NoError(t, err)
+	output, err := transformer.Prepare(input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	errChan := make(chan error, 1)
+	rows := []idrf.Row{}
+	done := make(chan error)
+	go func() { done <- transformer.Start(errChan) }()
+	for row := range output.DataChan {
+		rows = append(rows, row)
+	}
+
+	return output.DataDef, rows, <-done
+}
+
+func TestNewTransformer(t *testing.T) {
+	_, err := NewTransformer("id", schemaconfig.Timestamptz)
+	assert.Error(t, err)
+	_, err = NewTransformer("id", schemaconfig.EpochNanos)
+	assert.NoError(t, err)
+}
+
+func TestEpochNanos(t *testing.T) {
+	t1 := time.Date(2019, 1, 1, 0, 0, 0, 123456789, time.UTC)
+	dataSet, rows, err := transform(t, schemaconfig.EpochNanos, inputBundle(idrf.Row{"a", t1, 1.0}))
+	assert.NoError(t, err)
+	assert.Equal(t, idrf.IDRFInteger64, dataSet.ColumnNamed("time").DataType)
+	assert.Equal(t, []idrf.Row{{"a", int64(1546300800123456789), 1.0}}, rows)
+
+	_, _, err = transform(t, schemaconfig.EpochNanos, inputBundle(idrf.Row{"a", time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC), 1.0}))
+	assert.Error(t, err, "time out of the int64 range")
+}
+
+func TestTimestamptzWithNanos(t *testing.T) {
+	t1 := time.Date(2019, 1, 1, 0, 0, 0, 123456789, time.UTC)
+	dataSet, rows, err := transform(t, schemaconfig.TimestamptzWithNanos, inputBundle(idrf.Row{"a", t1, 1.0}))
+	assert.NoError(t, err)
+	assert.Equal(t, []*idrf.Column{
+		{Name: "host", DataType: idrf.IDRFString},
+		{Name: "time", DataType: idrf.IDRFTimestamptz},
+		{Name: "time_ns", DataType: idrf.IDRFInteger32},
+		{Name: "value", DataType: idrf.IDRFDouble},
+	}, dataSet.Columns)
+	assert.Equal(t, []idrf.Row{{"a", t1, int32(789), 1.0}}, rows)
+
+	_, _, err = transform(t, schemaconfig.TimestamptzWithNanos, inputBundle(idrf.Row{"a", "not a time", 1.0}))
+	assert.Error(t, err)
+}
+
+func TestPrepareErrors(t *testing.T) {
+	input := inputBundle()
+	input.DataDef.Columns = append(input.DataDef.Columns, &idrf.Column{Name: "time_ns", DataType: idrf.IDRFInteger64})
+	transformer, _ := NewTransformer("id", schemaconfig.TimestamptzWithNanos)
+	_, err := transformer.Prepare(input)
+	assert.Error(t, err, "nanoseconds column already exists")
+
+	input.DataDef.Columns[1].DataType = idrf.IDRFInteger64
+	transformer, _ = NewTransformer("id", schemaconfig.EpochNanos)
+	_, err = transformer.Prepare(input)
+	assert.Error(t, err, "time column is not a timestamp")
+	assert.Error(t, transformer.Start(nil), "prepare not called")
+}